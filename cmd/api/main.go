@@ -2,8 +2,10 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"log"
+	"net"
 	"os"
 	"os/signal"
 	"syscall"
@@ -11,9 +13,15 @@ import (
 
 	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
 	"github.com/birbparty/birb-nest/internal/api"
+	"github.com/birbparty/birb-nest/internal/auth"
 	"github.com/birbparty/birb-nest/internal/cache"
 	"github.com/birbparty/birb-nest/internal/database"
+	"github.com/birbparty/birb-nest/internal/encryption"
 	"github.com/birbparty/birb-nest/internal/instance"
+	"github.com/birbparty/birb-nest/internal/mtls"
+	"github.com/birbparty/birb-nest/internal/operations"
+	"github.com/birbparty/birb-nest/internal/quota"
+	"github.com/birbparty/birb-nest/internal/storage"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/logger"
@@ -51,27 +59,103 @@ func main() {
 
 	log.Printf("🐦 Birb Nest API starting in %s mode (instance: %s)...", cfg.Mode, cfg.InstanceID)
 
-	// Initialize Redis cache
-	cacheConfig := &cache.Config{
-		Host:     cfg.Redis.Host,
-		Port:     cfg.Redis.Port,
-		Password: cfg.Redis.Password,
-		DB:       cfg.Redis.DB,
-	}
+	// Initialize cache backend
+	var cacheClient cache.Cache
+	if cfg.UsesMemoryCache() {
+		memCache, err := cache.NewMemoryCache(&cache.MemoryConfig{
+			MaxMemoryBytes:  cfg.MemoryCache.MaxMemoryMB * 1024 * 1024,
+			MaxEntries:      cfg.MemoryCache.MaxEntries,
+			EvictionPolicy:  cfg.MemoryCache.EvictionPolicy,
+			DefaultTTL:      time.Hour,
+			CleanupInterval: time.Minute,
+		})
+		if err != nil {
+			log.Fatalf("Failed to initialize in-memory cache: %v", err)
+		}
+		cacheClient = memCache
+		log.Printf("✅ Using in-memory cache (%s eviction, %d MB limit)", cfg.MemoryCache.EvictionPolicy, cfg.MemoryCache.MaxMemoryMB)
+	} else {
+		cacheConfig := &cache.Config{
+			Mode:               cfg.Redis.Mode,
+			Host:               cfg.Redis.Host,
+			Port:               cfg.Redis.Port,
+			Password:           cfg.Redis.Password,
+			DB:                 cfg.Redis.DB,
+			SentinelMasterName: cfg.Redis.SentinelMasterName,
+			SentinelAddrs:      cfg.Redis.SentinelAddrs,
+			SentinelPassword:   cfg.Redis.SentinelPassword,
+			ClusterAddrs:       cfg.Redis.ClusterAddrs,
+		}
 
-	redisCache, err := cache.NewRedisCache(cacheConfig)
-	if err != nil {
-		log.Fatalf("Failed to connect to Redis: %v", err)
+		redisCache, err := cache.NewRedisCache(cacheConfig)
+		if err != nil {
+			log.Fatalf("Failed to connect to Redis: %v", err)
+		}
+		cacheClient = redisCache
+		log.Printf("✅ Connected to Redis (%s)", redisCache.Mode())
 	}
-	defer redisCache.Close()
-	log.Println("✅ Connected to Redis")
+	defer cacheClient.Close()
 
 	// Initialize instance registry
-	registry := instance.NewRegistry(redisCache)
+	registry := instance.NewRegistry(cacheClient)
 	log.Println("✅ Initialized instance registry")
 
-	// Initialize default instance
+	// Initialize database only for primary mode
+	var db database.Interface
+	if cfg.IsPrimary() && cfg.UsesSQLite() {
+		sqliteClient, err := database.NewSQLiteClient(&database.SQLiteConfig{
+			Path: cfg.SQLite.Path,
+		}, cfg.InstanceID)
+		if err != nil {
+			log.Fatalf("Failed to open SQLite database: %v", err)
+		}
+		db = sqliteClient
+		defer db.Close()
+		log.Printf("✅ Opened SQLite database (%s)", cfg.SQLite.Path)
+	} else if cfg.IsPrimary() && cfg.PostgreSQL.Enabled {
+		dbConfig, err := database.NewConfigFromEnv()
+		if err != nil {
+			log.Fatalf("Failed to load database configuration: %v", err)
+		}
+
+		db, err = database.NewPostgreSQLClient(dbConfig, cfg.InstanceID)
+		if err != nil {
+			log.Fatalf("Failed to connect to PostgreSQL: %v", err)
+		}
+		defer db.Close()
+		log.Println("✅ Connected to PostgreSQL")
+	}
+
+	// Features beyond cache entries are backed by the primary's database;
+	// backends report the ones they do not provide with ErrUnsupported
+	features, _ := db.(database.Features)
+
+	// Persist the registry in the database; Redis and the memory cache only cache it
 	ctx := context.Background()
+	if features != nil {
+		if instanceStore, err := features.InstanceStore(); err != nil {
+			log.Printf("Warning: Instance registry is not persisted: %v", err)
+		} else {
+			registry.SetStore(instanceStore)
+			result, err := registry.Reconcile(ctx)
+			if err != nil {
+				log.Fatalf("Failed to reconcile instance registry: %v", err)
+			}
+			log.Printf("✅ Reconciled instance registry (%d imported, %d restored)", result.Imported, result.Restored)
+		}
+	}
+
+	// Evict registry entries changed by other nodes from the memory cache.
+	// Watch keeps resubscribing after Redis outages until shutdown.
+	watchCtx, stopWatch := context.WithCancel(ctx)
+	defer stopWatch()
+	go func() {
+		if err := registry.Watch(watchCtx); err != nil {
+			log.Printf("Warning: Registry invalidation disabled: %v", err)
+		}
+	}()
+
+	// Initialize default instance
 	defaultInst, err := registry.GetOrCreate(ctx, cfg.DefaultInstanceID)
 	if err != nil {
 		log.Printf("Warning: Failed to initialize default instance: %v", err)
@@ -92,34 +176,192 @@ func main() {
 		}
 	}
 
-	// Initialize database only for primary mode
-	var db database.Interface
-	if cfg.IsPrimary() && cfg.PostgreSQL.Enabled {
-		dbConfig, err := database.NewConfigFromEnv()
+	// Create handlers with mode awareness
+	handlers := api.NewHandlers(cfg, cacheClient, db, registry)
+	defer handlers.Shutdown()
+
+	// Mutual TLS: serve with the node's certificate and present it to the primary
+	var peerTLS *mtls.Reloader
+	if cfg.TLS.Enabled() {
+		peerTLS, err = mtls.NewReloader(cfg.TLS.CertFile, cfg.TLS.KeyFile, cfg.TLS.CAFile, cfg.TLS.ReloadInterval)
 		if err != nil {
-			log.Fatalf("Failed to load database configuration: %v", err)
+			log.Fatalf("Failed to load TLS certificates: %v", err)
+		}
+		peerTLS.Start()
+		defer peerTLS.Stop()
+		if cfg.IsReplica() {
+			handlers.SetPeerTLS(peerTLS.ClientConfig(cfg.PrimaryServerName()))
 		}
+		log.Printf("🔐 Mutual TLS enabled (certificates checked every %s)", cfg.TLS.ReloadInterval)
+	}
 
-		db, err = database.NewPostgreSQLClient(dbConfig, cfg.InstanceID)
+	// Require API keys; keys are stored in the database and cached in the shared cache for replicas
+	if cfg.AuthEnabled {
+		var keyStore auth.Store
+		if features != nil {
+			if keyStore, err = features.APIKeyStore(); err != nil {
+				log.Printf("Warning: API keys cannot be managed: %v", err)
+			}
+		}
+		keyring := auth.NewKeyring(cacheClient, keyStore)
+		keyring.SetRootKey(cfg.APIKey)
+		if cfg.Tokens.SigningKey != nil {
+			signer, err := auth.NewTokenSigner(cfg.Tokens.SigningKey, cfg.Tokens.VerifyKeys...)
+			if err != nil {
+				log.Fatalf("Invalid API_TOKEN_SIGNING_KEY or API_TOKEN_VERIFY_KEYS: %v", err)
+			}
+			keyring.SetTokenSigner(signer)
+			handlers.SetTokenSigner(signer, cfg.Tokens.DefaultTTL, cfg.Tokens.MaxTTL)
+			log.Printf("✅ Accepting signed client tokens (lifetime up to %s)", cfg.Tokens.MaxTTL)
+		}
+		handlers.SetKeyring(keyring)
+		if keyStore == nil && cfg.APIKey == "" && cfg.IsPrimary() {
+			log.Printf("Warning: API key authentication is enabled without API_KEY or key management; no key can be created")
+		}
+		log.Printf("🔒 API key authentication enabled (key management: %t)", keyStore != nil)
+	} else if cfg.Tokens.SigningKey != nil {
+		log.Printf("Warning: API_TOKEN_SIGNING_KEY is ignored while API key authentication is disabled")
+	}
+
+	// Encrypt values at rest with per-instance data keys; the primary keeps
+	// them in its database and replicas load them, still wrapped, from the primary
+	if cfg.Encryption.Enabled() {
+		var wrappers []encryption.KeyWrapper
+		if cfg.Encryption.KMS != "" {
+			kms, err := encryption.NewKMS(ctx, cfg.Encryption.KMS, cfg.Encryption.KMSConfig)
+			if err != nil {
+				log.Fatalf("Failed to initialize ENCRYPTION_KMS: %v", err)
+			}
+			wrappers = append(wrappers, kms)
+		}
+		if cfg.Encryption.Keyfile != "" {
+			local, err := encryption.LoadKeyfile(cfg.Encryption.Keyfile)
+			if err != nil {
+				log.Fatalf("Failed to load ENCRYPTION_KEYFILE: %v", err)
+			}
+			wrappers = append(wrappers, local...)
+		}
+
+		var keyStore encryption.KeyStore
+		var entries encryption.EntryStore
+		if features != nil {
+			store, err := features.EncryptionStore()
+			if err != nil {
+				log.Fatalf("Failed to enable encryption at rest: %v", err)
+			}
+			keyStore, entries = store, store
+		} else if cfg.IsReplica() {
+			keyStore = handlers.PrimaryKeyStore()
+		} else {
+			log.Fatalf("Encryption at rest requires a database on the primary")
+		}
+
+		manager := encryption.NewManager(keyStore, wrappers[0], wrappers[1:]...)
+		manager.SetEncryptAll(cfg.Encryption.EncryptAll)
+		reencryptor := encryption.NewReencryptor(manager, cacheClient, entries, registry, cfg.Encryption.ReencryptInterval)
+		if cfg.Encryption.ReencryptInterval > 0 {
+			reencryptor.Start()
+			defer reencryptor.Stop()
+		}
+		handlers.SetEncryption(manager, reencryptor)
+		log.Printf("🔐 Encryption at rest enabled (master key %s, all instances %t)", wrappers[0].ID(), cfg.Encryption.EncryptAll)
+	}
+
+	// Compress large values before they are cached and persisted
+	if cfg.Compression.Enabled() {
+		compressor, err := cache.NewCompressor(cfg.Compression.Encoding, cfg.Compression.Threshold)
 		if err != nil {
-			log.Fatalf("Failed to connect to PostgreSQL: %v", err)
+			log.Fatalf("Failed to configure compression: %v", err)
 		}
-		defer db.Close()
-		log.Println("✅ Connected to PostgreSQL")
+		handlers.SetCompressor(compressor)
+		log.Printf("🗜️  Compressing values of %d bytes or more with %s", cfg.Compression.Threshold, cfg.Compression.Encoding)
 	}
 
-	// Create handlers with mode awareness
-	handlers := api.NewHandlers(cfg, redisCache, db, registry)
-	defer handlers.Shutdown()
+	// Rate limit cache requests per instance and per API key; buckets live in the shared cache
+	handlers.SetRateLimiter(api.NewRateLimiter(cacheClient, cfg.RateLimit))
+
+	// Enforce per-instance resource quotas; database limits apply on the primary
+	if cfg.Quota.Enabled {
+		enforcer := quota.NewEnforcer(cacheClient, cfg.Quota.SoftLimitPercent)
+		if features != nil {
+			enforcer.SetStorageStats(features.StorageStats, cfg.Quota.StorageStatsTTL)
+		}
+		handlers.SetQuotaEnforcer(enforcer)
+
+		if cfg.Quota.RecountInterval > 0 {
+			recounter := quota.NewRecounter(cacheClient, registry, cfg.Quota.RecountInterval)
+			recounter.Start()
+			defer recounter.Stop()
+		}
+		log.Printf("✅ Enforcing instance resource quotas (warnings at %d%%)", cfg.Quota.SoftLimitPercent)
+	}
+
+	// Instance operations need direct SQL access on the primary
+	var opsDB *database.DB
+	if features != nil {
+		if opsDB, err = features.OperationsDB(); err != nil {
+			log.Printf("Warning: Instance operations are disabled: %v", err)
+		}
+	}
+	if opsDB != nil {
+		ops := operations.NewInstanceOperations(cacheClient, opsDB, registry)
+		ops.SetIDPolicy(cfg.Provisioning.IDPolicy)
+
+		archiveStore, err := storage.NewArchiveStore(cfg.Archive.StoreConfig())
+		if err != nil {
+			log.Fatalf("Failed to initialize archive store: %v", err)
+		}
+		if archiveStore != nil {
+			ops.SetArchiveStore(archiveStore, cfg.Archive.OnDelete)
+			ops.SetBackupPolicy(cfg.Archive.FullEvery, cfg.Archive.TombstoneRetention)
+			log.Printf("✅ Archiving instances to %s store", cfg.Archive.Backend)
+
+			if cfg.Archive.AutoInterval > 0 {
+				archiver := operations.NewAutoArchiver(ops, registry, cfg.Archive.AutoInterval, cfg.Archive.InactiveAfter)
+				archiver.Start()
+				defer archiver.Stop()
+				log.Printf("✅ Auto-archival every %s (inactive after %s)", cfg.Archive.AutoInterval, cfg.Archive.InactiveAfter)
+			}
+		}
+
+		ops.SetHotSetSize(cfg.Hibernation.HotSetSize)
+		if cfg.Hibernation.Interval > 0 {
+			hibernator := operations.NewHibernator(ops, registry, cfg.Hibernation.Interval, cfg.Hibernation.IdleAfter)
+			hibernator.Start()
+			defer hibernator.Stop()
+			log.Printf("✅ Hibernating instances idle for %s (hot set %d keys)", cfg.Hibernation.IdleAfter, cfg.Hibernation.HotSetSize)
+		}
+
+		reaper := operations.NewReaper(ops, registry, cfg.Reaper.Interval, operations.ReaperPolicy{
+			IdleAfter:      cfg.Reaper.IdleAfter,
+			IdleByType:     cfg.Reaper.IdleByType,
+			Protected:      []string{cfg.DefaultInstanceID},
+			DryRun:         cfg.Reaper.DryRun,
+			MaxPerRun:      cfg.Reaper.MaxPerRun,
+			DeleteInterval: cfg.Reaper.DeleteInterval,
+			RequireArchive: cfg.Reaper.RequireArchive,
+		})
+		if cfg.Reaper.Interval > 0 {
+			reaper.Start()
+			defer reaper.Stop()
+			log.Printf("✅ Instance reaper every %s (idle after %s, dry run %t)", cfg.Reaper.Interval, cfg.Reaper.IdleAfter, cfg.Reaper.DryRun)
+		}
+
+		handlers.SetInstanceOperations(ops)
+		handlers.SetReaper(reaper)
+	}
 
 	// Create Fiber app
 	app := fiber.New(fiber.Config{
 		AppName:               fmt.Sprintf("Birb Nest API - %s", cfg.Mode),
-		ErrorHandler:          fiber.DefaultErrorHandler,
+		ErrorHandler:          api.ErrorHandler,
 		ReadTimeout:           time.Duration(cfg.RequestTimeout) * time.Second,
 		WriteTimeout:          time.Duration(cfg.RequestTimeout) * time.Second,
 		IdleTimeout:           120 * time.Second,
 		DisableStartupMessage: true,
+		// Bodies over the body limit reach handlers as streams, so large
+		// values are stored in chunks as they arrive
+		StreamRequestBody: true,
 	})
 
 	// Setup middleware
@@ -133,7 +375,7 @@ func main() {
 	app.Use(cors.New(cors.Config{
 		AllowOrigins: "*",
 		AllowMethods: "GET,POST,PUT,DELETE,OPTIONS",
-		AllowHeaders: "Origin, Content-Type, Accept, Authorization, X-Instance-ID, X-Write-Timestamp",
+		AllowHeaders: "Origin, Content-Type, Accept, Authorization, X-Instance-ID, X-Write-Timestamp, X-API-Key",
 	}))
 
 	// Setup routes
@@ -153,12 +395,17 @@ func main() {
 
 		// Shutdown handlers first (to stop async writer)
 		handlers.Shutdown()
+		stopWatch()
 
 		if err := app.ShutdownWithContext(shutdownCtx); err != nil {
 			log.Printf("Server forced to shutdown: %v", err)
 		}
 	}()
 
+	if cfg.Provisioning.Strict {
+		log.Printf("🔒 Strict instance provisioning: unknown instance IDs are rejected (allowed IDs: %s)", cfg.Provisioning.IDPolicy)
+	}
+
 	// Log instance information
 	if cfg.IsReplica() {
 		log.Printf("📡 Replica instance configured to forward writes to: %s", cfg.PrimaryURL)
@@ -168,7 +415,16 @@ func main() {
 	addr := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
 	log.Printf("🚀 Birb Nest API (%s) listening on %s", cfg.Mode, addr)
 
-	if err := app.Listen(addr); err != nil {
+	if peerTLS != nil {
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+		err = app.Listener(tls.NewListener(ln, peerTLS.ServerConfig(cfg.TLS.RequireClientCert)))
+		if err != nil {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	} else if err := app.Listen(addr); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
@@ -0,0 +1,127 @@
+// Command backup inspects and maintains instance archives without a running API server.
+//
+// It reads the same ARCHIVE_* environment variables as the API:
+//
+//	backup verify <file>                          verify a local archive's checksums
+//	backup catalog <instance>                     print an instance's backup chain catalog
+//	backup compact [-until T] [-prune] <instance> merge a backup chain into one full backup
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/birbparty/birb-nest/internal/api"
+	"github.com/birbparty/birb-nest/internal/archive"
+	"github.com/birbparty/birb-nest/internal/storage"
+)
+
+func main() {
+	log.SetFlags(0)
+
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	ctx := context.Background()
+	switch cmd, args := os.Args[1], os.Args[2:]; cmd {
+	case "verify":
+		verify(args)
+	case "catalog":
+		catalog(ctx, args)
+	case "compact":
+		compact(ctx, args)
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: backup verify <file> | catalog <instance> | compact [-until RFC3339] [-prune] <instance>")
+	os.Exit(2)
+}
+
+func verify(args []string) {
+	if len(args) != 1 {
+		usage()
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		log.Fatalf("Failed to open archive: %v", err)
+	}
+	defer f.Close()
+
+	summary, err := archive.Verify(f)
+	if err != nil {
+		log.Fatalf("❌ Verification failed: %v", err)
+	}
+	printJSON(summary)
+}
+
+func catalog(ctx context.Context, args []string) {
+	if len(args) != 1 {
+		usage()
+	}
+
+	c, err := storage.LoadCatalog(ctx, openStore(), args[0])
+	if err != nil {
+		log.Fatalf("Failed to load catalog: %v", err)
+	}
+	printJSON(c)
+}
+
+func compact(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("compact", flag.ExitOnError)
+	untilFlag := fs.String("until", "", "compact the chain ending at the latest backup at or before this RFC3339 time (default now)")
+	prune := fs.Bool("prune", false, "delete the merged archives after compaction")
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		usage()
+	}
+
+	until := time.Now()
+	if *untilFlag != "" {
+		t, err := time.Parse(time.RFC3339, *untilFlag)
+		if err != nil {
+			log.Fatalf("Invalid -until: %v", err)
+		}
+		until = t
+	}
+
+	record, err := storage.CompactChain(ctx, openStore(), fs.Arg(0), until, *prune)
+	if err != nil {
+		log.Fatalf("Failed to compact backups: %v", err)
+	}
+	printJSON(record)
+}
+
+// openStore opens the archive store configured through ARCHIVE_* variables
+func openStore() storage.ArchiveStore {
+	cfg, err := api.LoadConfig()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	store, err := storage.NewArchiveStore(cfg.Archive.StoreConfig())
+	if err != nil {
+		log.Fatalf("Failed to initialize archive store: %v", err)
+	}
+	if store == nil {
+		log.Fatal("Archiving is not configured; set ARCHIVE_BACKEND")
+	}
+	return store
+}
+
+func printJSON(v interface{}) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		log.Fatalf("Failed to encode output: %v", err)
+	}
+}
@@ -3,12 +3,17 @@ module github.com/birbparty/birb-nest
 go 1.23.4
 
 require (
+	github.com/DataDog/dd-trace-go/contrib/gofiber/fiber.v2/v2 v2.2.3
+	github.com/DataDog/dd-trace-go/contrib/jackc/pgx.v5/v2 v2.2.3
+	github.com/DataDog/dd-trace-go/contrib/redis/go-redis.v9/v2 v2.2.3
 	github.com/DataDog/dd-trace-go/orchestrion/all/v2 v2.2.3
+	github.com/DataDog/dd-trace-go/v2 v2.2.3
 	github.com/DataDog/orchestrion v1.5.0
 	github.com/aws/aws-sdk-go v1.55.7
 	github.com/docker/go-connections v0.5.0
 	github.com/gofiber/fiber/v2 v2.52.8
 	github.com/jackc/pgx/v5 v5.7.5
+	github.com/klauspost/compress v1.18.0
 	github.com/lib/pq v1.10.9
 	github.com/nats-io/nats.go v1.43.0
 	github.com/prometheus/client_golang v1.22.0
@@ -26,6 +31,7 @@ require (
 	go.opentelemetry.io/otel/sdk v1.37.0
 	go.opentelemetry.io/otel/sdk/metric v1.37.0
 	go.opentelemetry.io/otel/trace v1.37.0
+	modernc.org/sqlite v1.38.2
 )
 
 require (
@@ -65,7 +71,6 @@ require (
 	github.com/DataDog/dd-trace-go/contrib/go.mongodb.org/mongo-driver.v2/v2 v2.2.3 // indirect
 	github.com/DataDog/dd-trace-go/contrib/go.mongodb.org/mongo-driver/v2 v2.2.3 // indirect
 	github.com/DataDog/dd-trace-go/contrib/gocql/gocql/v2 v2.2.3 // indirect
-	github.com/DataDog/dd-trace-go/contrib/gofiber/fiber.v2/v2 v2.2.3 // indirect
 	github.com/DataDog/dd-trace-go/contrib/gomodule/redigo/v2 v2.2.3 // indirect
 	github.com/DataDog/dd-trace-go/contrib/google.golang.org/grpc/v2 v2.2.3 // indirect
 	github.com/DataDog/dd-trace-go/contrib/gorilla/mux/v2 v2.2.3 // indirect
@@ -73,19 +78,16 @@ require (
 	github.com/DataDog/dd-trace-go/contrib/graph-gophers/graphql-go/v2 v2.2.3 // indirect
 	github.com/DataDog/dd-trace-go/contrib/graphql-go/graphql/v2 v2.2.3 // indirect
 	github.com/DataDog/dd-trace-go/contrib/hashicorp/vault/v2 v2.2.3 // indirect
-	github.com/DataDog/dd-trace-go/contrib/jackc/pgx.v5/v2 v2.2.3 // indirect
 	github.com/DataDog/dd-trace-go/contrib/julienschmidt/httprouter/v2 v2.2.3 // indirect
 	github.com/DataDog/dd-trace-go/contrib/k8s.io/client-go/v2 v2.2.3 // indirect
 	github.com/DataDog/dd-trace-go/contrib/labstack/echo.v4/v2 v2.2.3 // indirect
 	github.com/DataDog/dd-trace-go/contrib/log/slog/v2 v2.2.3 // indirect
 	github.com/DataDog/dd-trace-go/contrib/net/http/v2 v2.2.3 // indirect
-	github.com/DataDog/dd-trace-go/contrib/redis/go-redis.v9/v2 v2.2.3 // indirect
 	github.com/DataDog/dd-trace-go/contrib/redis/rueidis/v2 v2.2.3 // indirect
 	github.com/DataDog/dd-trace-go/contrib/segmentio/kafka-go/v2 v2.2.3 // indirect
 	github.com/DataDog/dd-trace-go/contrib/sirupsen/logrus/v2 v2.2.3 // indirect
 	github.com/DataDog/dd-trace-go/contrib/twitchtv/twirp/v2 v2.2.3 // indirect
 	github.com/DataDog/dd-trace-go/contrib/valkey-io/valkey-go/v2 v2.2.3 // indirect
-	github.com/DataDog/dd-trace-go/v2 v2.2.3 // indirect
 	github.com/DataDog/go-libddwaf/v4 v4.3.2 // indirect
 	github.com/DataDog/go-runtime-metrics-internal v0.0.4-0.20250721125240-fdf1ef85b633 // indirect
 	github.com/DataDog/go-sqllexer v0.1.6 // indirect
@@ -215,7 +217,6 @@ require (
 	github.com/jmespath/go-jmespath v0.4.0 // indirect
 	github.com/json-iterator/go v1.1.12 // indirect
 	github.com/julienschmidt/httprouter v1.3.0 // indirect
-	github.com/klauspost/compress v1.18.0 // indirect
 	github.com/klauspost/cpuid/v2 v2.2.5 // indirect
 	github.com/labstack/echo/v4 v4.11.1 // indirect
 	github.com/labstack/gommon v0.4.2 // indirect
@@ -245,6 +246,7 @@ require (
 	github.com/nats-io/nats-server/v2 v2.11.6 // indirect
 	github.com/nats-io/nkeys v0.4.11 // indirect
 	github.com/nats-io/nuid v1.0.1 // indirect
+	github.com/ncruces/go-strftime v0.1.9 // indirect
 	github.com/opencontainers/go-digest v1.0.0 // indirect
 	github.com/opencontainers/image-spec v1.1.1 // indirect
 	github.com/outcaste-io/ristretto v0.2.3 // indirect
@@ -261,6 +263,7 @@ require (
 	github.com/puzpuzpuz/xsync/v3 v3.5.1 // indirect
 	github.com/rcrowley/go-metrics v0.0.0-20201227073835-cf1acfcdf475 // indirect
 	github.com/redis/rueidis v1.0.55 // indirect
+	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
 	github.com/richardartoul/molecule v1.0.1-0.20240531184615-7ca0df43c0b3 // indirect
 	github.com/rivo/uniseg v0.4.7 // indirect
 	github.com/rs/zerolog v1.34.0 // indirect
@@ -334,4 +337,7 @@ require (
 	k8s.io/client-go v0.31.4 // indirect
 	k8s.io/klog/v2 v2.130.1 // indirect
 	k8s.io/utils v0.0.0-20241210054802-24370beab758 // indirect
+	modernc.org/libc v1.66.3 // indirect
+	modernc.org/mathutil v1.7.1 // indirect
+	modernc.org/memory v1.11.0 // indirect
 )
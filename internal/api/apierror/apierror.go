@@ -0,0 +1,90 @@
+// Package apierror defines the body of API error responses and their codes.
+// It is shared by the handlers and the middleware in front of them, so every
+// failure has the same shape.
+package apierror
+
+// Response is the body of every error response
+type Response struct {
+	Error   string `json:"error"`
+	Code    string `json:"code,omitempty"`
+	Details string `json:"details,omitempty"`
+
+	// InstanceID and Status extend responses about a specific instance, e.g.
+	// one that is paused or that the API key may not access
+	InstanceID string `json:"instance_id,omitempty"`
+	Status     string `json:"status,omitempty"`
+}
+
+// Error codes. Codes are stable; messages may change.
+const (
+	CodeNotFound        = "NOT_FOUND"
+	CodeInvalidRequest  = "INVALID_REQUEST"
+	CodeInternalError   = "INTERNAL_ERROR"
+	CodeVersionMismatch = "VERSION_MISMATCH"
+	CodeTimeout         = "TIMEOUT"
+	CodeRateLimited     = "RATE_LIMITED"
+	CodeConflict        = "CONFLICT"
+	CodeForbidden       = "FORBIDDEN"
+	CodeQuotaExceeded   = "QUOTA_EXCEEDED"
+
+	CodePrimaryUnavailable    = "PRIMARY_UNAVAILABLE"
+	CodeEncryptionUnavailable = "ENCRYPTION_UNAVAILABLE"
+)
+
+// Error codes for requests outside what an API key or token allows
+const (
+	CodeUnauthorized      = "UNAUTHORIZED"
+	CodeAuthUnavailable   = "AUTH_UNAVAILABLE"
+	CodeInstanceForbidden = "INSTANCE_FORBIDDEN"
+	CodeInsufficientScope = "INSUFFICIENT_SCOPE"
+	CodeKeyForbidden      = "KEY_FORBIDDEN"
+)
+
+// Error codes for cache keys and values
+const (
+	CodeInvalidKey          = "INVALID_KEY"
+	CodeKeyTooLong          = "KEY_TOO_LONG"
+	CodeReservedKey         = "RESERVED_KEY"
+	CodeValueTooLarge       = "VALUE_TOO_LARGE"
+	CodeRangeNotSatisfiable = "RANGE_NOT_SATISFIABLE"
+)
+
+// Error codes for requests naming an instance that cannot serve them
+const (
+	CodeMissingInstanceID    = "MISSING_INSTANCE_ID"
+	CodeInvalidInstanceID    = "INVALID_INSTANCE_ID"
+	CodeReservedInstanceID   = "RESERVED_INSTANCE_ID"
+	CodeInstanceIDNotAllowed = "INSTANCE_ID_NOT_ALLOWED"
+	CodeInstanceNotFound     = "INSTANCE_NOT_FOUND"
+	CodeInstanceLoadError    = "INSTANCE_LOAD_ERROR"
+	CodeInstanceUnavailable  = "INSTANCE_UNAVAILABLE"
+	CodeInstanceDeleting     = "INSTANCE_DELETING"
+	CodeInstanceInactive     = "INSTANCE_INACTIVE"
+	CodeInstancePaused       = "INSTANCE_PAUSED"
+)
+
+// New creates an error response
+func New(err string, code string) *Response {
+	return &Response{
+		Error: err,
+		Code:  code,
+	}
+}
+
+// NewWithDetails creates an error response with details
+func NewWithDetails(err string, code string, details string) *Response {
+	return &Response{
+		Error:   err,
+		Code:    code,
+		Details: details,
+	}
+}
+
+// ForInstance creates an error response about an instance
+func ForInstance(err string, code string, instanceID string) *Response {
+	return &Response{
+		Error:      err,
+		Code:       code,
+		InstanceID: instanceID,
+	}
+}
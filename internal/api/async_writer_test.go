@@ -105,7 +105,7 @@ func TestAsyncWriter_Write(t *testing.T) {
 	mockDB.On("SetWithInstance", mock.Anything, "test-key", "primary", []byte("test-value")).Return(nil)
 
 	// Write
-	writer.Write("test-key", []byte("test-value"), "primary")
+	writer.Write(context.Background(), "test-key", []byte("test-value"), "primary")
 
 	// Wait for async processing
 	time.Sleep(100 * time.Millisecond)
@@ -120,8 +120,8 @@ func TestAsyncWriter_QueueFull(t *testing.T) {
 	defer writer.Shutdown()
 
 	// Fill the queue
-	writer.Write("key1", []byte("value1"), "primary")
-	writer.Write("key2", []byte("value2"), "primary") // Should be dropped
+	writer.Write(context.Background(), "key1", []byte("value1"), "primary")
+	writer.Write(context.Background(), "key2", []byte("value2"), "primary") // Should be dropped
 
 	// No database calls expected since no workers
 	mockDB.AssertNotCalled(t, "SetWithInstance")
@@ -148,7 +148,7 @@ func TestAsyncWriter_Retry(t *testing.T) {
 	mockDB.On("SetWithInstance", mock.Anything, "retry-key", "primary", []byte("retry-value")).Return(nil).Once()
 
 	// Write
-	writer.Write("retry-key", []byte("retry-value"), "primary")
+	writer.Write(context.Background(), "retry-key", []byte("retry-value"), "primary")
 
 	// Wait for async processing and retry
 	time.Sleep(2 * time.Second)
@@ -2,8 +2,15 @@ package api
 
 import (
 	"fmt"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
+	"time"
+
+	"github.com/birbparty/birb-nest/internal/cache"
+	"github.com/birbparty/birb-nest/internal/instance"
+	"github.com/birbparty/birb-nest/internal/storage"
 )
 
 // Config holds the API configuration
@@ -18,21 +25,63 @@ type Config struct {
 	PrimaryURL        string // URL of primary API for replicas
 	DefaultInstanceID string // Default instance ID for requests without instance context
 
+	// Tenant instance provisioning
+	Provisioning ProvisioningConfig
+
 	// Async writer configuration (primary only)
 	WriteQueueSize int
 	WriteWorkers   int
 
 	// API configuration
 	APIKey          string
+	AuthEnabled     bool // require API keys on cache and admin endpoints
 	RequestTimeout  int
 	ShutdownTimeout int
 
+	// Cache backend configuration
+	CacheBackend string // "redis" or "memory"
+	MemoryCache  MemoryCacheConfig
+
 	// Redis configuration
 	Redis RedisConfig
 
+	// Database backend configuration
+	DatabaseDriver string // "postgres" or "sqlite"
+	SQLite         SQLiteConfig
+
 	// PostgreSQL configuration
 	PostgreSQL PostgreSQLConfig
 
+	// Archive configuration
+	Archive ArchiveConfig
+
+	// Instance reaper configuration
+	Reaper ReaperConfig
+
+	// Hibernation configuration
+	Hibernation HibernationConfig
+
+	// Resource quota enforcement
+	Quota QuotaConfig
+
+	// Request rate limiting
+	RateLimit RateLimitConfig
+
+	// Signed client tokens
+	Tokens TokenConfig
+
+	// Mutual TLS between replicas and the primary
+	TLS TLSConfig
+
+	// Encryption of cached values at rest
+	Encryption EncryptionConfig
+
+	// Compression of large values
+	Compression CompressionConfig
+
+	// Value size limits and chunking
+	Values ValueConfig
+
 	// Telemetry configuration
 	TelemetryEnabled bool
 	MetricsPath      string
@@ -40,10 +89,167 @@ type Config struct {
 
 // RedisConfig holds Redis configuration
 type RedisConfig struct {
+	Mode     string // "standalone", "sentinel" or "cluster"
 	Host     string
 	Port     int
 	Password string
 	DB       int
+
+	// Sentinel settings
+	SentinelMasterName string
+	SentinelAddrs      []string
+	SentinelPassword   string
+
+	// Cluster settings
+	ClusterAddrs []string
+}
+
+// MemoryCacheConfig holds in-process cache configuration (CACHE_BACKEND=memory)
+type MemoryCacheConfig struct {
+	MaxMemoryMB    int64
+	MaxEntries     int
+	EvictionPolicy string // "lru" or "lfu"
+}
+
+// SQLiteConfig holds embedded SQLite configuration (DATABASE_DRIVER=sqlite)
+type SQLiteConfig struct {
+	Path string
+}
+
+// ArchiveConfig holds instance archive store configuration
+type ArchiveConfig struct {
+	Backend       string // "none", "s3", "filesystem" or "memory"
+	Path          string
+	OnDelete      bool          // archive instances before deleting them
+	AutoInterval  time.Duration // 0 disables auto-archival
+	InactiveAfter time.Duration
+
+	FullEvery          int           // incremental backups between full backups; 0 never forces a full
+	TombstoneRetention time.Duration // how long deleted keys are remembered for incrementals
+
+	S3Endpoint       string
+	S3Region         string
+	S3Bucket         string
+	S3AccessKey      string
+	S3SecretKey      string
+	S3ForcePathStyle bool
+}
+
+// StoreConfig converts the archive settings into a storage.ArchiveConfig
+func (c ArchiveConfig) StoreConfig() storage.ArchiveConfig {
+	return storage.ArchiveConfig{
+		Backend: c.Backend,
+		Path:    c.Path,
+		S3: storage.SpacesConfig{
+			Endpoint:       c.S3Endpoint,
+			Region:         c.S3Region,
+			Bucket:         c.S3Bucket,
+			AccessKey:      c.S3AccessKey,
+			SecretKey:      c.S3SecretKey,
+			ForcePathStyle: c.S3ForcePathStyle,
+		},
+	}
+}
+
+// ProvisioningConfig controls how tenant instances come into existence
+type ProvisioningConfig struct {
+	Strict   bool               // only the admin API creates instances; unknown IDs are rejected
+	IDPolicy *instance.IDPolicy // allowlist for new instance IDs; nil allows any valid ID
+}
+
+// ReaperConfig holds idle instance reaper configuration
+type ReaperConfig struct {
+	Interval       time.Duration            // 0 disables the background reaper
+	IdleAfter      time.Duration            // default idle threshold
+	IdleByType     map[string]time.Duration // idle threshold by instance type
+	DryRun         bool
+	MaxPerRun      int
+	DeleteInterval time.Duration
+	RequireArchive bool
+}
+
+// HibernationConfig holds idle instance hibernation configuration
+type HibernationConfig struct {
+	Interval   time.Duration // 0 disables automatic hibernation
+	IdleAfter  time.Duration
+	HotSetSize int // keys loaded on wake-up; 0 loads every key
+}
+
+// QuotaConfig holds per-instance resource quota enforcement configuration
+type QuotaConfig struct {
+	Enabled          bool
+	SoftLimitPercent int           // usage that triggers warnings; 0 disables them
+	StorageStatsTTL  time.Duration // how long a database usage measurement is reused
+	RecountInterval  time.Duration // 0 disables the background cache usage recount
+}
+
+// RateLimitConfig holds request rate limits. Limits are requests per Period;
+// 0 means unlimited. Instance limits can be overridden by instance metadata.
+type RateLimitConfig struct {
+	Period         time.Duration
+	InstanceReads  int64
+	InstanceWrites int64
+	KeyReads       int64 // per API key
+	KeyWrites      int64
+}
+
+// TokenConfig holds signed client token configuration
+type TokenConfig struct {
+	SigningKey []byte   // nil disables minting and accepting tokens
+	VerifyKeys [][]byte // previous signing keys still accepted
+	DefaultTTL time.Duration
+	MaxTTL     time.Duration
+}
+
+// TLSConfig holds the certificate a node serves and presents to the primary,
+// and the CA bundle verifying its peers
+type TLSConfig struct {
+	CertFile          string
+	KeyFile           string
+	CAFile            string
+	PeerNames         []string      // accepted replica certificate names; empty accepts any the CA verifies
+	ServerName        string        // name the primary's certificate must carry; empty uses the PRIMARY_URL host
+	RequireClientCert bool          // refuse connections without a client certificate
+	ReloadInterval    time.Duration // how often the files are checked for changes; 0 disables reloading
+}
+
+// Enabled reports whether TLS is configured
+func (c *TLSConfig) Enabled() bool {
+	return c.CertFile != ""
+}
+
+// EncryptionConfig holds the master keys protecting per-instance data keys.
+// With both a KMS and a keyfile, the KMS wraps new data keys and the keyfile
+// keys only unwrap existing ones.
+type EncryptionConfig struct {
+	Keyfile           string // "<id>:<base64 key>" lines; the first wraps new data keys
+	KMS               string // name of a registered KMS
+	KMSConfig         string // passed to the KMS, e.g. a key ARN
+	EncryptAll        bool   // encrypt instances that do not turn encryption on or off
+	ReencryptInterval time.Duration
+}
+
+// Enabled reports whether a master key is configured
+func (c *EncryptionConfig) Enabled() bool {
+	return c.Keyfile != "" || c.KMS != ""
+}
+
+// CompressionConfig holds value compression configuration
+type CompressionConfig struct {
+	Encoding  string // "gzip" or "zstd"; empty disables compression
+	Threshold int    // values smaller than this many bytes are stored as is
+}
+
+// Enabled reports whether values are compressed
+func (c *CompressionConfig) Enabled() bool {
+	return c.Encoding != ""
+}
+
+// ValueConfig holds value size limits. Values larger than ChunkSize are
+// stored in chunks of that size.
+type ValueConfig struct {
+	MaxSize   int64 // largest value accepted, in bytes
+	ChunkSize int   // 0 stores every value whole
 }
 
 // PostgreSQLConfig holds PostgreSQL configuration
@@ -95,6 +301,28 @@ func LoadConfig() (*Config, error) {
 		return nil, fmt.Errorf("invalid REDIS_DB: %w", err)
 	}
 
+	// Cache backend config
+	cacheBackend := getEnvOrDefault("CACHE_BACKEND", "redis")
+	if cacheBackend != "redis" && cacheBackend != "memory" {
+		return nil, fmt.Errorf("invalid CACHE_BACKEND: %s", cacheBackend)
+	}
+
+	memoryMaxMB, err := strconv.ParseInt(getEnvOrDefault("CACHE_MEMORY_MAX_MB", "256"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CACHE_MEMORY_MAX_MB: %w", err)
+	}
+
+	memoryMaxEntries, err := strconv.Atoi(getEnvOrDefault("CACHE_MEMORY_MAX_ENTRIES", "0"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid CACHE_MEMORY_MAX_ENTRIES: %w", err)
+	}
+
+	// Database backend config
+	databaseDriver := getEnvOrDefault("DATABASE_DRIVER", "postgres")
+	if databaseDriver != "postgres" && databaseDriver != "sqlite" {
+		return nil, fmt.Errorf("invalid DATABASE_DRIVER: %s", databaseDriver)
+	}
+
 	// PostgreSQL config
 	postgresEnabled := getEnvOrDefault("POSTGRES_ENABLED", "true") == "true"
 	postgresPort, err := strconv.Atoi(getEnvOrDefault("POSTGRES_PORT", "5432"))
@@ -102,6 +330,209 @@ func LoadConfig() (*Config, error) {
 		return nil, fmt.Errorf("invalid POSTGRES_PORT: %w", err)
 	}
 
+	// Archive config
+	archiveBackend := getEnvOrDefault("ARCHIVE_BACKEND", "none")
+	switch archiveBackend {
+	case "none", "s3", "filesystem", "memory":
+	default:
+		return nil, fmt.Errorf("invalid ARCHIVE_BACKEND: %s", archiveBackend)
+	}
+
+	archiveInterval, err := time.ParseDuration(getEnvOrDefault("ARCHIVE_AUTO_INTERVAL", "0s"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ARCHIVE_AUTO_INTERVAL: %w", err)
+	}
+
+	archiveInactiveAfter, err := time.ParseDuration(getEnvOrDefault("ARCHIVE_INACTIVE_AFTER", "24h"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ARCHIVE_INACTIVE_AFTER: %w", err)
+	}
+
+	archiveFullEvery, err := strconv.Atoi(getEnvOrDefault("ARCHIVE_FULL_EVERY", "7"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ARCHIVE_FULL_EVERY: %w", err)
+	}
+
+	tombstoneRetention, err := time.ParseDuration(getEnvOrDefault("ARCHIVE_TOMBSTONE_RETENTION", "168h"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ARCHIVE_TOMBSTONE_RETENTION: %w", err)
+	}
+
+	// Provisioning config
+	provisioningMode := getEnvOrDefault("INSTANCE_PROVISIONING", "auto")
+	if provisioningMode != "auto" && provisioningMode != "strict" {
+		return nil, fmt.Errorf("invalid INSTANCE_PROVISIONING: %s", provisioningMode)
+	}
+
+	// Patterns are separated by whitespace; regular expressions often contain commas
+	idPolicy, err := instance.NewIDPolicy(strings.Fields(os.Getenv("INSTANCE_ID_PATTERNS")))
+	if err != nil {
+		return nil, fmt.Errorf("invalid INSTANCE_ID_PATTERNS: %w", err)
+	}
+
+	// Reaper config
+	reaperInterval, err := time.ParseDuration(getEnvOrDefault("REAPER_INTERVAL", "0s"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid REAPER_INTERVAL: %w", err)
+	}
+
+	reaperIdleAfter, err := time.ParseDuration(getEnvOrDefault("REAPER_IDLE_AFTER", "72h"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid REAPER_IDLE_AFTER: %w", err)
+	}
+
+	reaperIdleByType, err := parseDurationMap(os.Getenv("REAPER_IDLE_BY_TYPE"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid REAPER_IDLE_BY_TYPE: %w", err)
+	}
+
+	reaperMaxPerRun, err := strconv.Atoi(getEnvOrDefault("REAPER_MAX_PER_RUN", "50"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid REAPER_MAX_PER_RUN: %w", err)
+	}
+
+	reaperDeleteInterval, err := time.ParseDuration(getEnvOrDefault("REAPER_DELETE_INTERVAL", "1s"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid REAPER_DELETE_INTERVAL: %w", err)
+	}
+
+	// Hibernation config
+	hibernateInterval, err := time.ParseDuration(getEnvOrDefault("HIBERNATE_INTERVAL", "0s"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid HIBERNATE_INTERVAL: %w", err)
+	}
+
+	hibernateAfter, err := time.ParseDuration(getEnvOrDefault("HIBERNATE_AFTER", "1h"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid HIBERNATE_AFTER: %w", err)
+	}
+
+	hotSetSize, err := strconv.Atoi(getEnvOrDefault("HIBERNATE_HOT_SET_SIZE", "1000"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid HIBERNATE_HOT_SET_SIZE: %w", err)
+	}
+
+	// Quota config
+	quotaSoftLimit, err := strconv.Atoi(getEnvOrDefault("QUOTA_SOFT_LIMIT_PERCENT", "80"))
+	if err != nil || quotaSoftLimit < 0 || quotaSoftLimit > 100 {
+		return nil, fmt.Errorf("invalid QUOTA_SOFT_LIMIT_PERCENT: %s", os.Getenv("QUOTA_SOFT_LIMIT_PERCENT"))
+	}
+
+	quotaStatsTTL, err := time.ParseDuration(getEnvOrDefault("QUOTA_STORAGE_STATS_TTL", "30s"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid QUOTA_STORAGE_STATS_TTL: %w", err)
+	}
+
+	quotaRecountInterval, err := time.ParseDuration(getEnvOrDefault("QUOTA_RECOUNT_INTERVAL", "15m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid QUOTA_RECOUNT_INTERVAL: %w", err)
+	}
+
+	// Rate limit config
+	rateLimitPeriod, err := time.ParseDuration(getEnvOrDefault("API_RATE_LIMIT_DURATION", "1m"))
+	if err != nil || rateLimitPeriod <= 0 {
+		return nil, fmt.Errorf("invalid API_RATE_LIMIT_DURATION: %s", os.Getenv("API_RATE_LIMIT_DURATION"))
+	}
+
+	// API_RATE_LIMIT_REQUESTS is the instance limit for both reads and writes unless they are set separately
+	rateLimitRequests := getEnvOrDefault("API_RATE_LIMIT_REQUESTS", "0")
+	rateLimits := make(map[string]int64)
+	for name, fallback := range map[string]string{
+		"API_RATE_LIMIT_READS":      rateLimitRequests,
+		"API_RATE_LIMIT_WRITES":     rateLimitRequests,
+		"API_RATE_LIMIT_KEY_READS":  "0",
+		"API_RATE_LIMIT_KEY_WRITES": "0",
+	} {
+		limit, err := strconv.ParseInt(getEnvOrDefault(name, fallback), 10, 64)
+		if err != nil || limit < 0 {
+			return nil, fmt.Errorf("invalid %s: %s", name, getEnvOrDefault(name, fallback))
+		}
+		rateLimits[name] = limit
+	}
+
+	// Token config
+	tokenDefaultTTL, err := time.ParseDuration(getEnvOrDefault("API_TOKEN_DEFAULT_TTL", "15m"))
+	if err != nil || tokenDefaultTTL <= 0 {
+		return nil, fmt.Errorf("invalid API_TOKEN_DEFAULT_TTL: %s", getEnvOrDefault("API_TOKEN_DEFAULT_TTL", "15m"))
+	}
+	tokenMaxTTL, err := time.ParseDuration(getEnvOrDefault("API_TOKEN_MAX_TTL", "1h"))
+	if err != nil || tokenMaxTTL < tokenDefaultTTL {
+		return nil, fmt.Errorf("invalid API_TOKEN_MAX_TTL: must be a duration of at least API_TOKEN_DEFAULT_TTL")
+	}
+	var tokenSigningKey []byte
+	if key := os.Getenv("API_TOKEN_SIGNING_KEY"); key != "" {
+		tokenSigningKey = []byte(key)
+	}
+	var tokenVerifyKeys [][]byte
+	for _, key := range cache.SplitAddrs(os.Getenv("API_TOKEN_VERIFY_KEYS")) {
+		tokenVerifyKeys = append(tokenVerifyKeys, []byte(key))
+	}
+
+	// TLS config
+	tlsConfig := TLSConfig{
+		CertFile:          os.Getenv("TLS_CERT_FILE"),
+		KeyFile:           os.Getenv("TLS_KEY_FILE"),
+		CAFile:            os.Getenv("TLS_CA_FILE"),
+		PeerNames:         cache.SplitAddrs(os.Getenv("TLS_PEER_NAMES")),
+		ServerName:        os.Getenv("TLS_SERVER_NAME"),
+		RequireClientCert: getEnvOrDefault("TLS_REQUIRE_CLIENT_CERT", "false") == "true",
+	}
+	if (tlsConfig.CertFile != "" || tlsConfig.KeyFile != "" || tlsConfig.CAFile != "") &&
+		(tlsConfig.CertFile == "" || tlsConfig.KeyFile == "" || tlsConfig.CAFile == "") {
+		return nil, fmt.Errorf("TLS_CERT_FILE, TLS_KEY_FILE and TLS_CA_FILE must be set together")
+	}
+	tlsConfig.ReloadInterval, err = time.ParseDuration(getEnvOrDefault("TLS_RELOAD_INTERVAL", "1m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid TLS_RELOAD_INTERVAL: %w", err)
+	}
+
+	// Encryption config
+	encryptionConfig := EncryptionConfig{
+		Keyfile:    os.Getenv("ENCRYPTION_KEYFILE"),
+		KMS:        os.Getenv("ENCRYPTION_KMS"),
+		KMSConfig:  os.Getenv("ENCRYPTION_KMS_CONFIG"),
+		EncryptAll: getEnvOrDefault("ENCRYPTION_DEFAULT", "off") == "on",
+	}
+	if d := getEnvOrDefault("ENCRYPTION_DEFAULT", "off"); d != "on" && d != "off" {
+		return nil, fmt.Errorf("invalid ENCRYPTION_DEFAULT %q: must be on or off", d)
+	}
+	encryptionConfig.ReencryptInterval, err = time.ParseDuration(getEnvOrDefault("ENCRYPTION_REENCRYPT_INTERVAL", "10m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ENCRYPTION_REENCRYPT_INTERVAL: %w", err)
+	}
+
+	// Compression config
+	compressionConfig := CompressionConfig{Encoding: getEnvOrDefault("COMPRESSION_ENCODING", "off")}
+	switch compressionConfig.Encoding {
+	case "off":
+		compressionConfig.Encoding = ""
+	case "gzip", "zstd":
+	default:
+		return nil, fmt.Errorf("invalid COMPRESSION_ENCODING %q: must be off, gzip or zstd", compressionConfig.Encoding)
+	}
+	compressionConfig.Threshold, err = strconv.Atoi(getEnvOrDefault("COMPRESSION_THRESHOLD", "4096"))
+	if err != nil || compressionConfig.Threshold < 0 {
+		return nil, fmt.Errorf("invalid COMPRESSION_THRESHOLD: %s", getEnvOrDefault("COMPRESSION_THRESHOLD", "4096"))
+	}
+
+	// Value size config
+	valueConfig := ValueConfig{}
+	valueConfig.MaxSize, err = strconv.ParseInt(getEnvOrDefault("MAX_VALUE_SIZE", "67108864"), 10, 64)
+	if err != nil || valueConfig.MaxSize <= 0 {
+		return nil, fmt.Errorf("invalid MAX_VALUE_SIZE: %s", getEnvOrDefault("MAX_VALUE_SIZE", "67108864"))
+	}
+	valueConfig.ChunkSize, err = strconv.Atoi(getEnvOrDefault("VALUE_CHUNK_SIZE", "1048576"))
+	if err != nil || valueConfig.ChunkSize < 0 {
+		return nil, fmt.Errorf("invalid VALUE_CHUNK_SIZE: %s", getEnvOrDefault("VALUE_CHUNK_SIZE", "1048576"))
+	}
+
+	// API keys are required by default once a root key is configured
+	authDefault := "false"
+	if os.Getenv("API_KEY") != "" {
+		authDefault = "true"
+	}
+	authEnabled := getEnvOrDefault("API_AUTH_ENABLED", authDefault) == "true"
+
 	telemetryEnabled := getEnvOrDefault("TELEMETRY_ENABLED", "true") == "true"
 
 	return &Config{
@@ -114,13 +545,29 @@ func LoadConfig() (*Config, error) {
 		WriteQueueSize:    writeQueueSize,
 		WriteWorkers:      writeWorkers,
 		APIKey:            os.Getenv("API_KEY"),
+		AuthEnabled:       authEnabled,
 		RequestTimeout:    requestTimeout,
 		ShutdownTimeout:   shutdownTimeout,
+		CacheBackend:      cacheBackend,
+		MemoryCache: MemoryCacheConfig{
+			MaxMemoryMB:    memoryMaxMB,
+			MaxEntries:     memoryMaxEntries,
+			EvictionPolicy: getEnvOrDefault("CACHE_MEMORY_EVICTION", "lru"),
+		},
 		Redis: RedisConfig{
-			Host:     getEnvOrDefault("REDIS_HOST", "localhost"),
-			Port:     redisPort,
-			Password: os.Getenv("REDIS_PASSWORD"),
-			DB:       redisDB,
+			Mode:               getEnvOrDefault("REDIS_MODE", "standalone"),
+			Host:               getEnvOrDefault("REDIS_HOST", "localhost"),
+			Port:               redisPort,
+			Password:           os.Getenv("REDIS_PASSWORD"),
+			DB:                 redisDB,
+			SentinelMasterName: os.Getenv("REDIS_SENTINEL_MASTER"),
+			SentinelAddrs:      cache.SplitAddrs(os.Getenv("REDIS_SENTINEL_ADDRS")),
+			SentinelPassword:   os.Getenv("REDIS_SENTINEL_PASSWORD"),
+			ClusterAddrs:       cache.SplitAddrs(os.Getenv("REDIS_CLUSTER_ADDRS")),
+		},
+		DatabaseDriver: databaseDriver,
+		SQLite: SQLiteConfig{
+			Path: getEnvOrDefault("SQLITE_PATH", "birbnest.db"),
 		},
 		PostgreSQL: PostgreSQLConfig{
 			Enabled:  postgresEnabled,
@@ -131,6 +578,62 @@ func LoadConfig() (*Config, error) {
 			Database: getEnvOrDefault("POSTGRES_DATABASE", "birbnest"),
 			SSLMode:  getEnvOrDefault("POSTGRES_SSL_MODE", "disable"),
 		},
+		Archive: ArchiveConfig{
+			Backend:            archiveBackend,
+			Path:               getEnvOrDefault("ARCHIVE_PATH", "./archives"),
+			OnDelete:           getEnvOrDefault("ARCHIVE_ON_DELETE", "true") == "true",
+			AutoInterval:       archiveInterval,
+			InactiveAfter:      archiveInactiveAfter,
+			FullEvery:          archiveFullEvery,
+			TombstoneRetention: tombstoneRetention,
+			S3Endpoint:         os.Getenv("ARCHIVE_S3_ENDPOINT"),
+			S3Region:           getEnvOrDefault("ARCHIVE_S3_REGION", "us-east-1"),
+			S3Bucket:           os.Getenv("ARCHIVE_S3_BUCKET"),
+			S3AccessKey:        os.Getenv("ARCHIVE_S3_ACCESS_KEY"),
+			S3SecretKey:        os.Getenv("ARCHIVE_S3_SECRET_KEY"),
+			S3ForcePathStyle:   getEnvOrDefault("ARCHIVE_S3_FORCE_PATH_STYLE", "false") == "true",
+		},
+		Reaper: ReaperConfig{
+			Interval:       reaperInterval,
+			IdleAfter:      reaperIdleAfter,
+			IdleByType:     reaperIdleByType,
+			DryRun:         getEnvOrDefault("REAPER_DRY_RUN", "false") == "true",
+			MaxPerRun:      reaperMaxPerRun,
+			DeleteInterval: reaperDeleteInterval,
+			RequireArchive: getEnvOrDefault("REAPER_REQUIRE_ARCHIVE", "true") == "true",
+		},
+		Hibernation: HibernationConfig{
+			Interval:   hibernateInterval,
+			IdleAfter:  hibernateAfter,
+			HotSetSize: hotSetSize,
+		},
+		Provisioning: ProvisioningConfig{
+			Strict:   provisioningMode == "strict",
+			IDPolicy: idPolicy,
+		},
+		Quota: QuotaConfig{
+			Enabled:          getEnvOrDefault("QUOTA_ENFORCEMENT", "true") == "true",
+			SoftLimitPercent: quotaSoftLimit,
+			StorageStatsTTL:  quotaStatsTTL,
+			RecountInterval:  quotaRecountInterval,
+		},
+		RateLimit: RateLimitConfig{
+			Period:         rateLimitPeriod,
+			InstanceReads:  rateLimits["API_RATE_LIMIT_READS"],
+			InstanceWrites: rateLimits["API_RATE_LIMIT_WRITES"],
+			KeyReads:       rateLimits["API_RATE_LIMIT_KEY_READS"],
+			KeyWrites:      rateLimits["API_RATE_LIMIT_KEY_WRITES"],
+		},
+		Tokens: TokenConfig{
+			SigningKey: tokenSigningKey,
+			VerifyKeys: tokenVerifyKeys,
+			DefaultTTL: tokenDefaultTTL,
+			MaxTTL:     tokenMaxTTL,
+		},
+		TLS:              tlsConfig,
+		Encryption:       encryptionConfig,
+		Compression:      compressionConfig,
+		Values:           valueConfig,
 		TelemetryEnabled: telemetryEnabled,
 		MetricsPath:      getEnvOrDefault("METRICS_PATH", "/metrics"),
 	}, nil
@@ -143,6 +646,23 @@ func getEnvOrDefault(key, defaultValue string) string {
 	return defaultValue
 }
 
+// parseDurationMap parses comma-separated name=duration pairs such as "temporary=30m,dungeon=6h"
+func parseDurationMap(s string) (map[string]time.Duration, error) {
+	m := make(map[string]time.Duration)
+	for _, item := range cache.SplitAddrs(s) {
+		name, value, ok := strings.Cut(item, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("expected name=duration, got %q", item)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		m[strings.TrimSpace(name)] = d
+	}
+	return m, nil
+}
+
 // IsPrimary returns true if this is the primary instance
 func (c *Config) IsPrimary() bool {
 	return c.Mode == "primary"
@@ -152,3 +672,25 @@ func (c *Config) IsPrimary() bool {
 func (c *Config) IsReplica() bool {
 	return c.Mode == "replica"
 }
+
+// UsesMemoryCache returns true if the in-process cache backend is selected
+func (c *Config) UsesMemoryCache() bool {
+	return c.CacheBackend == "memory"
+}
+
+// UsesSQLite returns true if the embedded SQLite database backend is selected
+func (c *Config) UsesSQLite() bool {
+	return c.DatabaseDriver == "sqlite"
+}
+
+// PrimaryServerName returns the name the primary's TLS certificate must
+// carry: TLS_SERVER_NAME, or else the host of PRIMARY_URL, which may be an IP
+func (c *Config) PrimaryServerName() string {
+	if c.TLS.ServerName != "" {
+		return c.TLS.ServerName
+	}
+	if u, err := url.Parse(c.PrimaryURL); err == nil {
+		return u.Hostname()
+	}
+	return ""
+}
@@ -3,6 +3,8 @@ package api
 import (
 	"encoding/json"
 	"time"
+
+	"github.com/birbparty/birb-nest/internal/api/apierror"
 )
 
 // CacheRequest represents the request body for cache operations
@@ -23,12 +25,8 @@ type CacheResponse struct {
 	UpdatedAt time.Time              `json:"updated_at"`
 }
 
-// ErrorResponse represents an error response
-type ErrorResponse struct {
-	Error   string `json:"error"`
-	Code    string `json:"code,omitempty"`
-	Details string `json:"details,omitempty"`
-}
+// ErrorResponse represents an error response; the middleware writes the same type
+type ErrorResponse = apierror.Response
 
 // HealthResponse represents the health check response
 type HealthResponse struct {
@@ -87,31 +85,29 @@ type ListKeysResponse struct {
 	Limit      int      `json:"limit"`
 }
 
-// Error codes
+// Error codes, defined in apierror
 const (
-	ErrCodeNotFound        = "NOT_FOUND"
-	ErrCodeInvalidRequest  = "INVALID_REQUEST"
-	ErrCodeInternalError   = "INTERNAL_ERROR"
-	ErrCodeVersionMismatch = "VERSION_MISMATCH"
-	ErrCodeTimeout         = "TIMEOUT"
-	ErrCodeRateLimited     = "RATE_LIMITED"
+	ErrCodeNotFound        = apierror.CodeNotFound
+	ErrCodeInvalidRequest  = apierror.CodeInvalidRequest
+	ErrCodeInternalError   = apierror.CodeInternalError
+	ErrCodeVersionMismatch = apierror.CodeVersionMismatch
+	ErrCodeTimeout         = apierror.CodeTimeout
+	ErrCodeRateLimited     = apierror.CodeRateLimited
+	ErrCodeConflict        = apierror.CodeConflict
+	ErrCodeForbidden       = apierror.CodeForbidden
+	ErrCodeQuotaExceeded   = apierror.CodeQuotaExceeded
+
+	ErrCodePrimaryUnavailable = apierror.CodePrimaryUnavailable
 )
 
 // NewErrorResponse creates a new error response
 func NewErrorResponse(err string, code string) *ErrorResponse {
-	return &ErrorResponse{
-		Error: err,
-		Code:  code,
-	}
+	return apierror.New(err, code)
 }
 
 // NewErrorResponseWithDetails creates a new error response with details
 func NewErrorResponseWithDetails(err string, code string, details string) *ErrorResponse {
-	return &ErrorResponse{
-		Error:   err,
-		Code:    code,
-		Details: details,
-	}
+	return apierror.NewWithDetails(err, code, details)
 }
 
 // ConvertToCacheResponse converts internal models to API response
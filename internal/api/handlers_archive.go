@@ -0,0 +1,217 @@
+package api
+
+import (
+	"errors"
+	"time"
+
+	"github.com/birbparty/birb-nest/internal/archive"
+	"github.com/birbparty/birb-nest/internal/operations"
+	"github.com/birbparty/birb-nest/internal/storage"
+	"github.com/gofiber/fiber/v2"
+)
+
+// ArchiveListResponse lists the archives of an instance
+type ArchiveListResponse struct {
+	InstanceID string                `json:"instance_id"`
+	Archives   []storage.ArchiveInfo `json:"archives"`
+}
+
+// ArchiveRequest selects the kind of backup to archive
+type ArchiveRequest struct {
+	Incremental bool `json:"incremental,omitempty"`
+}
+
+// RestoreRequest selects the state to restore. An archive key restores that
+// archive's chain; otherwise the latest backup at or before point_in_time
+// (default now) is restored.
+type RestoreRequest struct {
+	ArchiveKey  string     `json:"archive_key,omitempty"`
+	PointInTime *time.Time `json:"point_in_time,omitempty"`
+	DryRun      bool       `json:"dry_run,omitempty"`
+}
+
+// RestoreResponse reports a completed or validated restore
+type RestoreResponse struct {
+	InstanceID string           `json:"instance_id"`
+	ArchiveKey string           `json:"archive_key"`
+	Chain      []string         `json:"chain"`
+	RestoredTo *time.Time       `json:"restored_to,omitempty"`
+	Status     string           `json:"status"`
+	Summary    *archive.Summary `json:"summary,omitempty"`
+}
+
+// CompactRequest selects the backup chain to compact
+type CompactRequest struct {
+	Until *time.Time `json:"until,omitempty"`
+	Prune bool       `json:"prune,omitempty"`
+}
+
+// ArchiveInstance handles POST /v1/instances/:id/archive
+func (h *Handlers) ArchiveInstance(c *fiber.Ctx) error {
+	if !h.archivingEnabled() {
+		return archivingUnavailable(c)
+	}
+	instanceID := c.Params("id")
+
+	var req ArchiveRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(
+				NewErrorResponse("Invalid request body", ErrCodeInvalidRequest))
+		}
+	}
+
+	archiveFn := h.ops.ArchiveInstance
+	if req.Incremental {
+		archiveFn = h.ops.ArchiveIncremental
+	}
+
+	info, err := archiveFn(c.UserContext(), instanceID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(
+			NewErrorResponseWithDetails("Failed to archive instance", ErrCodeInternalError, err.Error()))
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(info)
+}
+
+// ListArchives handles GET /v1/instances/:id/archives
+func (h *Handlers) ListArchives(c *fiber.Ctx) error {
+	if !h.archivingEnabled() {
+		return archivingUnavailable(c)
+	}
+	instanceID := c.Params("id")
+
+	archives, err := h.ops.ListArchives(c.UserContext(), instanceID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(
+			NewErrorResponseWithDetails("Failed to list archives", ErrCodeInternalError, err.Error()))
+	}
+	if archives == nil {
+		archives = []storage.ArchiveInfo{}
+	}
+
+	return c.JSON(ArchiveListResponse{
+		InstanceID: instanceID,
+		Archives:   archives,
+	})
+}
+
+// RestoreInstance handles POST /v1/instances/:id/restore
+func (h *Handlers) RestoreInstance(c *fiber.Ctx) error {
+	if !h.archivingEnabled() {
+		return archivingUnavailable(c)
+	}
+	instanceID := c.Params("id")
+
+	var req RestoreRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(
+				NewErrorResponse("Invalid request body", ErrCodeInvalidRequest))
+		}
+	}
+
+	opts := operations.RestoreOptions{
+		ArchiveKey: req.ArchiveKey,
+		DryRun:     req.DryRun,
+	}
+	if req.PointInTime != nil {
+		opts.PointInTime = *req.PointInTime
+	}
+
+	result, err := h.ops.RestoreFromArchive(c.UserContext(), instanceID, opts)
+	if err != nil {
+		return archiveError(c, "Failed to restore instance", err)
+	}
+
+	status := "restored"
+	if result.DryRun {
+		status = "verified"
+	}
+
+	resp := RestoreResponse{
+		InstanceID: instanceID,
+		ArchiveKey: result.ArchiveKey,
+		Chain:      result.Chain,
+		Status:     status,
+		Summary:    result.Summary,
+	}
+	if !result.RestoredTo.IsZero() {
+		resp.RestoredTo = &result.RestoredTo
+	}
+	return c.JSON(resp)
+}
+
+// GetBackupCatalog handles GET /v1/instances/:id/backups
+func (h *Handlers) GetBackupCatalog(c *fiber.Ctx) error {
+	if !h.archivingEnabled() {
+		return archivingUnavailable(c)
+	}
+
+	catalog, err := h.ops.BackupCatalog(c.UserContext(), c.Params("id"))
+	if err != nil {
+		return archiveError(c, "Failed to load backup catalog", err)
+	}
+	return c.JSON(catalog)
+}
+
+// CompactBackups handles POST /v1/instances/:id/backups/compact
+func (h *Handlers) CompactBackups(c *fiber.Ctx) error {
+	if !h.archivingEnabled() {
+		return archivingUnavailable(c)
+	}
+
+	var req CompactRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(
+				NewErrorResponse("Invalid request body", ErrCodeInvalidRequest))
+		}
+	}
+
+	var until time.Time
+	if req.Until != nil {
+		until = *req.Until
+	}
+
+	record, err := h.ops.CompactBackups(c.UserContext(), c.Params("id"), until, req.Prune)
+	if err != nil {
+		return archiveError(c, "Failed to compact backups", err)
+	}
+	return c.JSON(record)
+}
+
+// archiveError maps archive and backup chain errors to responses
+func archiveError(c *fiber.Ctx, msg string, err error) error {
+	switch {
+	case isStatusConflict(err):
+		return c.Status(fiber.StatusConflict).JSON(
+			NewErrorResponseWithDetails("Instance status does not allow this operation", ErrCodeConflict, err.Error()))
+	case errors.Is(err, storage.ErrArchiveNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(
+			NewErrorResponse("Archive not found", ErrCodeNotFound))
+	case errors.Is(err, storage.ErrNoRestorePoint):
+		return c.Status(fiber.StatusNotFound).JSON(
+			NewErrorResponseWithDetails("No backup at or before the requested time", ErrCodeNotFound, err.Error()))
+	case errors.Is(err, archive.ErrChecksumMismatch), errors.Is(err, archive.ErrCorrupt),
+		errors.Is(err, archive.ErrUnsupportedFormat), errors.Is(err, archive.ErrUnsorted),
+		errors.Is(err, storage.ErrBrokenChain):
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(
+			NewErrorResponseWithDetails("Archive failed verification", ErrCodeInvalidRequest, err.Error()))
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(
+			NewErrorResponseWithDetails(msg, ErrCodeInternalError, err.Error()))
+	}
+}
+
+// archivingEnabled reports whether instance operations and an archive store are configured
+func (h *Handlers) archivingEnabled() bool {
+	return h.ops != nil && h.ops.ArchiveStore() != nil
+}
+
+func archivingUnavailable(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusServiceUnavailable).JSON(
+		NewErrorResponseWithDetails("Archiving is not available", ErrCodeInternalError,
+			operations.ErrArchivingDisabled.Error()))
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/birbparty/birb-nest/internal/instance"
+	"github.com/birbparty/birb-nest/internal/operations"
+	"github.com/gofiber/fiber/v2"
+)
+
+// CloneRequest configures an instance clone
+type CloneRequest struct {
+	TargetID  string `json:"target_id,omitempty"`  // generated from the source ID when empty
+	KeyPrefix string `json:"key_prefix,omitempty"` // only copy keys with this prefix
+	WarmCache bool   `json:"warm_cache,omitempty"` // load copied keys into the cache
+}
+
+// CloneInstance handles POST /v1/instances/:id/clone
+func (h *Handlers) CloneInstance(c *fiber.Ctx) error {
+	if h.ops == nil {
+		return instanceOpsUnavailable(c)
+	}
+	sourceID := c.Params("id")
+
+	var req CloneRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(
+				NewErrorResponse("Invalid request body", ErrCodeInvalidRequest))
+		}
+	}
+
+	if ok, err := allowInstance(c, req.TargetID); !ok {
+		return err
+	}
+
+	result, err := h.ops.CloneInstance(c.UserContext(), sourceID, operations.CloneOptions{
+		TargetID:  req.TargetID,
+		KeyPrefix: req.KeyPrefix,
+		WarmCache: req.WarmCache,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, instance.ErrInstanceNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(
+				NewErrorResponse("Source instance not found", ErrCodeNotFound))
+		case isInstanceIDRejected(err):
+			return rejectInstanceID(c, err)
+		case isStatusConflict(err):
+			return c.Status(fiber.StatusConflict).JSON(
+				NewErrorResponseWithDetails("Instance status changed during clone", ErrCodeConflict, err.Error()))
+		case errors.Is(err, instance.ErrInstanceExists):
+			return c.Status(fiber.StatusConflict).JSON(
+				NewErrorResponseWithDetails("Target instance already exists", ErrCodeConflict, err.Error()))
+		default:
+			return c.Status(fiber.StatusInternalServerError).JSON(
+				NewErrorResponseWithDetails("Failed to clone instance", ErrCodeInternalError, err.Error()))
+		}
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(result)
+}
+
+func instanceOpsUnavailable(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusServiceUnavailable).JSON(
+		NewErrorResponseWithDetails("Instance operations are not available", ErrCodeInternalError,
+			"instance operations require a PostgreSQL primary"))
+}
@@ -0,0 +1,308 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/birbparty/birb-nest/internal/api/apierror"
+	"github.com/birbparty/birb-nest/internal/encryption"
+	"github.com/birbparty/birb-nest/internal/instance"
+	"github.com/gofiber/fiber/v2"
+)
+
+// ErrCodeEncryptionUnavailable is returned when a value cannot be encrypted or
+// decrypted, e.g. because its data key cannot be loaded
+const ErrCodeEncryptionUnavailable = apierror.CodeEncryptionUnavailable
+
+// reencryptTimeout bounds the re-encryption started by a key rotation
+const reencryptTimeout = 30 * time.Minute
+
+// DataKeyInfo describes a data key without its key material
+type DataKeyInfo struct {
+	ID          string     `json:"id"`
+	MasterKeyID string     `json:"master_key_id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	RetiredAt   *time.Time `json:"retired_at,omitempty"`
+}
+
+// EncryptionStatusResponse describes an instance's encryption at rest
+type EncryptionStatusResponse struct {
+	InstanceID string        `json:"instance_id"`
+	Enabled    bool          `json:"enabled"`
+	Keys       []DataKeyInfo `json:"keys"` // newest first; the first without retired_at is active
+}
+
+// SetEncryptionRequest turns encryption on or off for an instance
+type SetEncryptionRequest struct {
+	Enabled *bool `json:"enabled"`
+}
+
+// DataKeyRequest asks the primary for an instance's active data key
+type DataKeyRequest struct {
+	InstanceID string `json:"instance_id"`
+}
+
+// SetEncryption encrypts cached and persisted values at rest. reencryptor,
+// if set, re-encrypts an instance's values right after its key is rotated.
+func (h *Handlers) SetEncryption(manager *encryption.Manager, reencryptor *encryption.Reencryptor) {
+	h.encryption = manager
+	h.reencryptor = reencryptor
+	h.contextCache.SetSealer(manager)
+}
+
+// seal encrypts the value of a key for the instance in ctx before it is persisted
+func (h *Handlers) seal(ctx context.Context, key string, value []byte) ([]byte, error) {
+	if h.encryption == nil {
+		return value, nil
+	}
+	return h.encryption.Seal(ctx, key, value)
+}
+
+// open decrypts the value of a key read from the database
+func (h *Handlers) open(ctx context.Context, key string, value []byte) ([]byte, error) {
+	if h.encryption == nil {
+		return value, nil
+	}
+	return h.encryption.Open(ctx, key, value)
+}
+
+// GetInstanceEncryption handles GET /v1/instances/:id/encryption
+func (h *Handlers) GetInstanceEncryption(c *fiber.Ctx) error {
+	if h.encryption == nil {
+		return encryptionUnavailable(c)
+	}
+	instanceID := c.Params("id")
+	inst, err := h.registry.Get(c.UserContext(), instanceID)
+	if err != nil {
+		return instanceLookupError(c, err)
+	}
+
+	resp := EncryptionStatusResponse{InstanceID: instanceID, Enabled: h.encryption.Enabled(inst), Keys: []DataKeyInfo{}}
+	keys, err := h.encryption.Keys(c.UserContext(), instanceID)
+	if err != nil {
+		return encryptionError(c, "Failed to list data keys", err)
+	}
+	for _, key := range keys {
+		resp.Keys = append(resp.Keys, dataKeyInfo(key))
+	}
+	return c.JSON(resp)
+}
+
+// SetInstanceEncryption handles PUT /v1/instances/:id/encryption. Values
+// written before encryption was turned on are encrypted in the background.
+func (h *Handlers) SetInstanceEncryption(c *fiber.Ctx) error {
+	if h.encryption == nil {
+		return encryptionUnavailable(c)
+	}
+	var req SetEncryptionRequest
+	if err := c.BodyParser(&req); err != nil || req.Enabled == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(
+			NewErrorResponse("enabled is required", ErrCodeInvalidRequest))
+	}
+
+	ctx := c.UserContext()
+	inst, err := h.registry.Get(ctx, c.Params("id"))
+	if err != nil {
+		return instanceLookupError(c, err)
+	}
+	updated := inst.Clone()
+	updated.Metadata[encryption.MetaEncryption] = "off"
+	if *req.Enabled {
+		updated.Metadata[encryption.MetaEncryption] = "on"
+	}
+	if err := h.registry.Update(ctx, updated); err != nil {
+		if isStatusConflict(err) {
+			return c.Status(fiber.StatusConflict).JSON(
+				NewErrorResponseWithDetails("Instance changed during the update", ErrCodeConflict, err.Error()))
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(
+			NewErrorResponseWithDetails("Failed to update instance", ErrCodeInternalError, err.Error()))
+	}
+
+	return c.JSON(EncryptionStatusResponse{InstanceID: updated.InstanceID, Enabled: *req.Enabled, Keys: []DataKeyInfo{}})
+}
+
+// RotateInstanceKey handles POST /v1/instances/:id/encryption/rotate. The
+// instance's values are re-encrypted with the new key in the background.
+func (h *Handlers) RotateInstanceKey(c *fiber.Ctx) error {
+	if h.encryption == nil {
+		return encryptionUnavailable(c)
+	}
+	instanceID := c.Params("id")
+	if _, err := h.registry.Get(c.UserContext(), instanceID); err != nil {
+		return instanceLookupError(c, err)
+	}
+
+	key, err := h.encryption.Rotate(c.UserContext(), instanceID)
+	if err != nil {
+		return encryptionError(c, "Failed to rotate data key", err)
+	}
+
+	if h.reencryptor != nil {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), reencryptTimeout)
+			defer cancel()
+			rewritten, err := h.reencryptor.ReencryptInstance(ctx, instanceID)
+			if err != nil {
+				log.Printf("Warning: Failed to re-encrypt instance %s after key rotation: %v", instanceID, err)
+			}
+			log.Printf("Re-encrypted %d values of instance %s with data key %s", rewritten, instanceID, key.ID)
+		}()
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(dataKeyInfo(key))
+}
+
+// GetActiveDataKey handles POST /v1/encryption/keys, which replicas call for
+// the wrapped active data key of an instance; it is created on first use
+func (h *Handlers) GetActiveDataKey(c *fiber.Ctx) error {
+	if h.encryption == nil {
+		return encryptionUnavailable(c)
+	}
+	var req DataKeyRequest
+	if err := c.BodyParser(&req); err != nil || req.InstanceID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(
+			NewErrorResponse("instance_id is required", ErrCodeInvalidRequest))
+	}
+	if ok, err := allowInstance(c, req.InstanceID); !ok {
+		return err
+	}
+
+	key, err := h.encryption.ActiveKey(c.UserContext(), req.InstanceID)
+	if err != nil {
+		return encryptionError(c, "Failed to load data key", err)
+	}
+	return c.JSON(key)
+}
+
+// GetDataKey handles GET /v1/encryption/keys/:id, which replicas call for
+// the wrapped data key of a sealed value
+func (h *Handlers) GetDataKey(c *fiber.Ctx) error {
+	if h.encryption == nil {
+		return encryptionUnavailable(c)
+	}
+	key, err := h.encryption.Key(c.UserContext(), c.Params("id"))
+	if err != nil {
+		return encryptionError(c, "Failed to load data key", err)
+	}
+	if ok, err := allowInstance(c, key.InstanceID); !ok {
+		return err
+	}
+	return c.JSON(key)
+}
+
+// dataKeyInfo describes a data key without its key material
+func dataKeyInfo(key *encryption.DataKey) DataKeyInfo {
+	return DataKeyInfo{ID: key.ID, MasterKeyID: key.MasterKeyID, CreatedAt: key.CreatedAt, RetiredAt: key.RetiredAt}
+}
+
+// instanceLookupError responds to a failed registry lookup
+func instanceLookupError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, instance.ErrInstanceNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(
+			NewErrorResponse("Instance not found", ErrCodeNotFound))
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(
+		NewErrorResponseWithDetails("Failed to load instance", ErrCodeInternalError, err.Error()))
+}
+
+// encryptionError responds to a failed data key operation
+func encryptionError(c *fiber.Ctx, msg string, err error) error {
+	if errors.Is(err, encryption.ErrDataKeyNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(
+			NewErrorResponseWithDetails(msg, ErrCodeNotFound, err.Error()))
+	}
+	return sealError(c, msg, err)
+}
+
+// sealError responds to a value that could not be encrypted or decrypted.
+// Corrupt values are internal errors; anything else, such as a data key that
+// cannot be loaded right now, is reported as unavailable.
+func sealError(c *fiber.Ctx, msg string, err error) error {
+	if errors.Is(err, encryption.ErrInvalidEnvelope) {
+		return c.Status(fiber.StatusInternalServerError).JSON(
+			NewErrorResponseWithDetails(msg, ErrCodeInternalError, err.Error()))
+	}
+	return c.Status(fiber.StatusServiceUnavailable).JSON(
+		NewErrorResponseWithDetails(msg, ErrCodeEncryptionUnavailable, err.Error()))
+}
+
+// encryptionUnavailable responds when encryption at rest is not configured
+func encryptionUnavailable(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusServiceUnavailable).JSON(
+		NewErrorResponseWithDetails("Encryption at rest is not available", ErrCodeEncryptionUnavailable,
+			"set ENCRYPTION_KEYFILE or ENCRYPTION_KMS"))
+}
+
+// PrimaryKeyStore returns a read-only data key store backed by the primary,
+// for replicas. Data keys travel wrapped; replicas need the same master keys.
+func (h *Handlers) PrimaryKeyStore() encryption.KeyStore {
+	return &primaryKeyStore{h: h}
+}
+
+// primaryKeyStore loads data keys from the primary's /v1/encryption/keys
+type primaryKeyStore struct {
+	h *Handlers
+}
+
+// SaveDataKey is not supported on replicas
+func (s *primaryKeyStore) SaveDataKey(ctx context.Context, key *encryption.DataKey) error {
+	return encryption.ErrReadOnlyStore
+}
+
+// GetDataKey loads a data key by ID from the primary
+func (s *primaryKeyStore) GetDataKey(ctx context.Context, id string) (*encryption.DataKey, error) {
+	return s.do(ctx, http.MethodGet, "/v1/encryption/keys/"+id, nil)
+}
+
+// ActiveDataKey loads an instance's active data key from the primary, which
+// creates it on first use
+func (s *primaryKeyStore) ActiveDataKey(ctx context.Context, instanceID string) (*encryption.DataKey, error) {
+	body, err := json.Marshal(DataKeyRequest{InstanceID: instanceID})
+	if err != nil {
+		return nil, err
+	}
+	return s.do(ctx, http.MethodPost, "/v1/encryption/keys", body)
+}
+
+// ListDataKeys is not supported on replicas
+func (s *primaryKeyStore) ListDataKeys(ctx context.Context, instanceID string) ([]*encryption.DataKey, error) {
+	return nil, encryption.ErrReadOnlyStore
+}
+
+// do sends a request to the primary and decodes the data key it returns
+func (s *primaryKeyStore) do(ctx context.Context, method, path string, body []byte) (*encryption.DataKey, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.h.primaryURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create data key request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	s.h.setPrimaryAuth(req)
+
+	resp, err := s.h.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach primary: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, encryption.ErrDataKeyNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("primary returned status %d: %s", resp.StatusCode, msg)
+	}
+
+	var key encryption.DataKey
+	if err := json.NewDecoder(resp.Body).Decode(&key); err != nil {
+		return nil, fmt.Errorf("failed to decode data key: %w", err)
+	}
+	return &key, nil
+}
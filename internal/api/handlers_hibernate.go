@@ -0,0 +1,37 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/birbparty/birb-nest/internal/instance"
+	"github.com/gofiber/fiber/v2"
+)
+
+// HibernateInstance handles POST /v1/instances/:id/hibernate
+func (h *Handlers) HibernateInstance(c *fiber.Ctx) error {
+	if h.ops == nil {
+		return instanceOpsUnavailable(c)
+	}
+	instanceID := c.Params("id")
+
+	if err := h.ops.HibernateInstance(c.UserContext(), instanceID); err != nil {
+		switch {
+		case errors.Is(err, instance.ErrInstanceNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(
+				NewErrorResponse("Instance not found", ErrCodeNotFound))
+		case errors.Is(err, instance.ErrInstanceNotActive), isStatusConflict(err):
+			return c.Status(fiber.StatusConflict).JSON(
+				NewErrorResponseWithDetails("Instance is not active", ErrCodeConflict, err.Error()))
+		default:
+			return c.Status(fiber.StatusInternalServerError).JSON(
+				NewErrorResponseWithDetails("Failed to hibernate instance", ErrCodeInternalError, err.Error()))
+		}
+	}
+
+	inst, err := h.registry.Get(c.UserContext(), instanceID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(
+			NewErrorResponseWithDetails("Failed to read instance", ErrCodeInternalError, err.Error()))
+	}
+	return c.JSON(inst)
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/birbparty/birb-nest/internal/instance"
+	"github.com/gofiber/fiber/v2"
+)
+
+// InstanceHistoryResponse lists an instance's status transitions, newest first
+type InstanceHistoryResponse struct {
+	InstanceID  string                `json:"instance_id"`
+	Transitions []instance.Transition `json:"transitions"`
+}
+
+// GetInstanceHistory handles GET /v1/instances/:id/history
+func (h *Handlers) GetInstanceHistory(c *fiber.Ctx) error {
+	instanceID := c.Params("id")
+
+	limit := c.QueryInt("limit", 100)
+	if limit <= 0 || limit > 1000 {
+		return c.Status(fiber.StatusBadRequest).JSON(
+			NewErrorResponse("limit must be between 1 and 1000", ErrCodeInvalidRequest))
+	}
+
+	transitions, err := h.registry.History(c.UserContext(), instanceID, limit)
+	if err != nil {
+		if errors.Is(err, instance.ErrHistoryUnavailable) {
+			return instanceOpsUnavailable(c)
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(
+			NewErrorResponseWithDetails("Failed to read instance history", ErrCodeInternalError, err.Error()))
+	}
+
+	return c.JSON(InstanceHistoryResponse{InstanceID: instanceID, Transitions: transitions})
+}
+
+// isStatusConflict reports whether an operation lost a race for the instance
+// status or asked for a transition the state machine does not allow
+func isStatusConflict(err error) bool {
+	return errors.Is(err, instance.ErrInstanceConflict) || errors.Is(err, instance.ErrInvalidTransition)
+}
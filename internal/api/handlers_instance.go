@@ -3,17 +3,27 @@ package api
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"mime"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/birbparty/birb-nest/internal/api/middleware"
+	"github.com/birbparty/birb-nest/internal/auth"
 	"github.com/birbparty/birb-nest/internal/cache"
 	"github.com/birbparty/birb-nest/internal/database"
+	"github.com/birbparty/birb-nest/internal/encryption"
 	"github.com/birbparty/birb-nest/internal/instance"
+	"github.com/birbparty/birb-nest/internal/operations"
+	"github.com/birbparty/birb-nest/internal/quota"
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -28,6 +38,26 @@ type Handlers struct {
 	httpClient      *http.Client
 	defaultInstance string // default instance ID for legacy support
 	mode            string // "primary" or "replica"
+
+	ops    *operations.InstanceOperations // nil unless instance operations are available
+	reaper *operations.Reaper             // nil unless instance operations are available
+	quotas *quota.Enforcer                // nil when quotas are not enforced
+	limits *RateLimiter                   // nil when requests are not rate limited
+
+	keyring *auth.Keyring // nil when API keys are not required
+	apiKey  string        // sent to the primary with forwarded requests
+
+	tokens      *auth.TokenSigner // nil when signed tokens are not minted
+	tokenTTL    time.Duration
+	tokenMaxTTL time.Duration
+
+	encryption  *encryption.Manager     // nil when values are stored in plaintext
+	reencryptor *encryption.Reencryptor // nil on nodes that do not re-encrypt after rotation
+
+	compressor *cache.Compressor // nil when values are stored uncompressed
+
+	maxValueSize int64 // 0 accepts values of any size
+	chunkSize    int   // values larger than this are stored in chunks; 0 stores them whole
 }
 
 // NewHandlers creates handlers based on deployment mode
@@ -40,6 +70,9 @@ func NewHandlers(cfg *Config, cacheClient cache.Cache, db database.Interface, re
 		mode:            cfg.Mode,
 		primaryURL:      cfg.PrimaryURL,
 		defaultInstance: cfg.InstanceID,
+		apiKey:          cfg.APIKey,
+		maxValueSize:    cfg.Values.MaxSize,
+		chunkSize:       cfg.Values.ChunkSize,
 		httpClient: &http.Client{
 			Timeout: 10 * time.Second,
 		},
@@ -54,18 +87,54 @@ func NewHandlers(cfg *Config, cacheClient cache.Cache, db database.Interface, re
 	return h
 }
 
+// SetInstanceOperations enables instance administration endpoints (primary only)
+func (h *Handlers) SetInstanceOperations(ops *operations.InstanceOperations) {
+	h.ops = ops
+}
+
+// Rehydrate wakes a hibernated instance. Replicas leave this to the primary,
+// which wakes the instance when it receives forwarded requests.
+func (h *Handlers) Rehydrate(instanceID string) {
+	if h.ops != nil {
+		h.ops.Rehydrate(instanceID)
+	}
+}
+
+// SetRateLimiter enables request rate limiting on the cache endpoints
+func (h *Handlers) SetRateLimiter(limiter *RateLimiter) {
+	h.limits = limiter
+}
+
+// SetReaper enables the reaper endpoints (primary only)
+func (h *Handlers) SetReaper(reaper *operations.Reaper) {
+	h.reaper = reaper
+}
+
+// SetCompressor compresses large values before they are cached and persisted
+func (h *Handlers) SetCompressor(compressor *cache.Compressor) {
+	h.compressor = compressor
+}
+
 // Set handles cache set operations with mode-aware behavior
 func (h *Handlers) Set(c *fiber.Ctx) error {
 	ctx := c.UserContext()
 	key := c.Params("key")
-	if key == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "key is required",
-		})
+	if ok, err := validateKeys(c, key); !ok {
+		return err
+	}
+	if ok, err := allowCacheKeys(c, key); !ok {
+		return err
 	}
 
-	value := c.Body()
+	// The request's buffers are reused once the handler returns, but the async
+	// writer and the replica forward use the key and value after that
+	key = strings.Clone(key)
+	contentType := strings.Clone(c.Get(fiber.HeaderContentType))
 	timestamp := time.Now()
+	if mediaType, _, _ := mime.ParseMediaType(contentType); cache.IsReservedContentType(mediaType) {
+		return c.Status(fiber.StatusBadRequest).JSON(
+			NewErrorResponse("Content type "+mediaType+" is reserved", ErrCodeInvalidRequest))
+	}
 
 	// Extract instance context
 	instCtx, hasInstance := middleware.ExtractInstanceContext(c)
@@ -83,12 +152,44 @@ func (h *Handlers) Set(c *fiber.Ctx) error {
 		}
 	}
 
+	// Extract source instance ID from context or header
+	sourceInstance := instanceID
+	if instanceHeader := c.Get("X-Instance-ID"); instanceHeader != "" {
+		sourceInstance = instanceHeader
+	}
+
+	// Values that may be larger than a chunk are stored as they arrive
+	if h.isLargeValue(c) {
+		return h.setChunked(c, key, contentType, instanceID, sourceInstance, timestamp)
+	}
+	value, err := h.readValue(c)
+	if errors.Is(err, errValueTooLarge) {
+		return valueTooLarge(c, h.maxValueSize)
+	} else if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(
+			NewErrorResponseWithDetails("Failed to read request body", ErrCodeInvalidRequest, err.Error()))
+	}
+	previous := h.storedManifest(ctx, key, instanceID, false)
+
+	// Reject writes that would exceed the instance's quota
+	if rejected, err := h.checkWriteQuota(c, key, len(value)); rejected {
+		return err
+	}
+
+	// Keep the content type with the value and compress it if it is large,
+	// then encrypt once for both the cache and PostgreSQL; replicas forward
+	// the uncompressed plaintext
+	stored, err := h.seal(ctx, key, h.encodeValue(instanceID, contentType, value))
+	if err != nil {
+		RecordCacheOperation("set", "error", instanceID, h.mode)
+		return sealError(c, "Failed to encrypt value", err)
+	}
+
 	// 1. Always write to local Redis first (using context-aware cache)
-	if err := h.contextCache.Set(ctx, key, value, 0); err != nil {
+	if err := h.contextCache.Set(ctx, key, stored, 0); err != nil {
 		RecordCacheOperation("set", "error", instanceID, h.mode)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to write to cache",
-		})
+		return c.Status(fiber.StatusInternalServerError).JSON(
+			NewErrorResponseWithDetails("Failed to write to cache", ErrCodeInternalError, err.Error()))
 	}
 	RecordCacheOperation("set", "success", instanceID, h.mode)
 
@@ -96,16 +197,16 @@ func (h *Handlers) Set(c *fiber.Ctx) error {
 	if h.isPrimary {
 		// Primary: async write to PostgreSQL
 		if h.asyncWriter != nil {
-			// Extract source instance ID from context or header
-			sourceInstance := instanceID
-			if instanceHeader := c.Get("X-Instance-ID"); instanceHeader != "" {
-				sourceInstance = instanceHeader
-			}
-			h.asyncWriter.Write(ctx, key, value, sourceInstance)
+			h.asyncWriter.Write(ctx, key, stored, sourceInstance)
 		}
 	} else {
 		// Replica: forward to primary asynchronously
-		go h.forwardWriteToPrimary(key, value, timestamp, instanceID)
+		go h.forwardWriteToPrimary(key, value, contentType, timestamp, instanceID)
+	}
+
+	// The chunks of a value stored in chunks are dropped once it is replaced
+	if previous != nil {
+		go h.dropChunks(context.WithoutCancel(ctx), previous, instanceID)
 	}
 
 	return c.SendStatus(fiber.StatusOK)
@@ -115,10 +216,11 @@ func (h *Handlers) Set(c *fiber.Ctx) error {
 func (h *Handlers) Get(c *fiber.Ctx) error {
 	ctx := c.UserContext()
 	key := c.Params("key")
-	if key == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "key is required",
-		})
+	if ok, err := validateKeys(c, key); !ok {
+		return err
+	}
+	if ok, err := allowCacheKeys(c, key); !ok {
+		return err
 	}
 
 	// Extract instance context
@@ -134,7 +236,11 @@ func (h *Handlers) Get(c *fiber.Ctx) error {
 	value, err := h.contextCache.Get(ctx, key)
 	if err == nil {
 		RecordCacheOperation("get", "hit", instanceID, h.mode)
-		return c.Send(value)
+		return h.sendValue(c, value, instanceID)
+	}
+	if errors.Is(err, cache.ErrSealing) {
+		RecordCacheOperation("get", "error", instanceID, h.mode)
+		return sealError(c, "Failed to decrypt value", err)
 	}
 	RecordCacheOperation("get", "miss", instanceID, h.mode)
 
@@ -144,14 +250,18 @@ func (h *Handlers) Get(c *fiber.Ctx) error {
 		if h.asyncWriter != nil && h.asyncWriter.db != nil {
 			value, err = h.asyncWriter.db.GetWithInstance(ctx, key, instanceID)
 			if err != nil {
-				return c.SendStatus(fiber.StatusNotFound)
+				return keyNotFound(c)
 			}
 
-			// Repopulate cache
+			// Repopulate cache; the stored value is already encrypted if needed
 			h.contextCache.Set(ctx, key, value, 0)
-			return c.Send(value)
+			plaintext, err := h.open(ctx, key, value)
+			if err != nil {
+				return sealError(c, "Failed to decrypt value", err)
+			}
+			return h.sendValue(c, plaintext, instanceID)
 		}
-		return c.SendStatus(fiber.StatusNotFound)
+		return keyNotFound(c)
 	} else {
 		// Replica queries primary
 		return h.queryPrimary(c, key, instanceID)
@@ -162,10 +272,11 @@ func (h *Handlers) Get(c *fiber.Ctx) error {
 func (h *Handlers) Delete(c *fiber.Ctx) error {
 	ctx := c.UserContext()
 	key := c.Params("key")
-	if key == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "key is required",
-		})
+	if ok, err := validateKeys(c, key); !ok {
+		return err
+	}
+	if ok, err := allowCacheKeys(c, key); !ok {
+		return err
 	}
 
 	// Extract instance context
@@ -177,7 +288,11 @@ func (h *Handlers) Delete(c *fiber.Ctx) error {
 		go h.registry.UpdateLastActive(ctx, instanceID)
 	}
 
-	// Delete from local cache (using context-aware cache)
+	// Delete from local cache (using context-aware cache), along with the
+	// chunks of a value stored in chunks
+	if manifest := h.storedManifest(ctx, key, instanceID, h.isPrimary); manifest != nil {
+		go h.dropChunks(context.WithoutCancel(ctx), manifest, instanceID)
+	}
 	err := h.contextCache.Delete(ctx, key)
 	if err != nil {
 		RecordCacheOperation("delete", "error", instanceID, h.mode)
@@ -292,7 +407,7 @@ func (h *Handlers) Metrics(c *fiber.Ctx) error {
 }
 
 // forwardWriteToPrimary asynchronously forwards writes from replica to primary
-func (h *Handlers) forwardWriteToPrimary(key string, value []byte, timestamp time.Time, instanceID string) {
+func (h *Handlers) forwardWriteToPrimary(key string, value []byte, contentType string, timestamp time.Time, instanceID string) {
 	url := fmt.Sprintf("%s/v1/cache/%s", h.primaryURL, key)
 
 	req, err := http.NewRequest("PUT", url, bytes.NewReader(value))
@@ -305,7 +420,10 @@ func (h *Handlers) forwardWriteToPrimary(key string, value []byte, timestamp tim
 	// Add instance ID and timestamp headers
 	req.Header.Set("X-Instance-ID", instanceID)
 	req.Header.Set("X-Write-Timestamp", timestamp.Format(time.RFC3339Nano))
-	req.Header.Set("Content-Type", "application/octet-stream")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	h.setPrimaryAuth(req)
 
 	resp, err := h.httpClient.Do(req)
 	if err != nil {
@@ -335,6 +453,7 @@ func (h *Handlers) forwardDeleteToPrimary(key string, instanceID string) {
 	}
 
 	req.Header.Set("X-Instance-ID", instanceID)
+	h.setPrimaryAuth(req)
 
 	resp, err := h.httpClient.Do(req)
 	if err != nil {
@@ -344,6 +463,21 @@ func (h *Handlers) forwardDeleteToPrimary(key string, instanceID string) {
 	defer resp.Body.Close()
 }
 
+// SetPeerTLS makes requests to the primary over mutual TLS
+func (h *Handlers) SetPeerTLS(config *tls.Config) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = config
+	h.httpClient.Transport = transport
+}
+
+// setPrimaryAuth authenticates a request to the primary with this node's
+// API_KEY; replicas have already checked the client's own key
+func (h *Handlers) setPrimaryAuth(req *http.Request) {
+	if h.apiKey != "" {
+		req.Header.Set("X-API-Key", h.apiKey)
+	}
+}
+
 // queryPrimary queries the primary instance on cache miss
 func (h *Handlers) queryPrimary(c *fiber.Ctx, key string, instanceID string) error {
 	url := fmt.Sprintf("%s/v1/cache/%s", h.primaryURL, key)
@@ -351,49 +485,48 @@ func (h *Handlers) queryPrimary(c *fiber.Ctx, key string, instanceID string) err
 	req, err := http.NewRequestWithContext(c.UserContext(), "GET", url, nil)
 	if err != nil {
 		RecordPrimaryQuery(instanceID, "error")
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to create primary request",
-		})
+		return c.Status(fiber.StatusInternalServerError).JSON(
+			NewErrorResponseWithDetails("Failed to create primary request", ErrCodeInternalError, err.Error()))
 	}
 
 	req.Header.Set("X-Instance-ID", instanceID)
+	req.Header.Set("Accept", manifestAccept)
+	req.Header.Set("Accept-Encoding", acceptedEncodings)
+	h.setPrimaryAuth(req)
 
 	resp, err := h.httpClient.Do(req)
 	if err != nil {
 		log.Printf("Failed to query primary: %v", err)
 		RecordPrimaryQuery(instanceID, "error")
-		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
-			"error": "Primary unavailable",
-		})
+		return c.Status(fiber.StatusServiceUnavailable).JSON(
+			NewErrorResponseWithDetails("Primary unavailable", ErrCodePrimaryUnavailable, err.Error()))
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode == http.StatusNotFound {
 		RecordPrimaryQuery(instanceID, "not_found")
-		return c.SendStatus(fiber.StatusNotFound)
+		return keyNotFound(c)
 	}
 
 	if resp.StatusCode != http.StatusOK {
 		RecordPrimaryQuery(instanceID, "error")
-		return c.Status(resp.StatusCode).JSON(fiber.Map{
-			"error": "Primary returned error",
-		})
+		return primaryError(c, resp)
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		RecordPrimaryQuery(instanceID, "error")
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to read primary response",
-		})
+		return c.Status(fiber.StatusInternalServerError).JSON(
+			NewErrorResponseWithDetails("Failed to read primary response", ErrCodeInternalError, err.Error()))
 	}
 
 	RecordPrimaryQuery(instanceID, "success")
 
 	// Cache it locally for future reads
-	h.contextCache.Set(c.UserContext(), key, body, 0)
+	value := h.primaryValue(resp, instanceID, body)
+	h.contextCache.Set(c.UserContext(), key, value, 0)
 
-	return c.Send(body)
+	return h.sendValue(c, value, instanceID)
 }
 
 // BatchGet handles batch get operations
@@ -404,15 +537,19 @@ func (h *Handlers) BatchGet(c *fiber.Ctx) error {
 	}
 
 	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
+		return c.Status(fiber.StatusBadRequest).JSON(
+			NewErrorResponseWithDetails("Invalid request body", ErrCodeInvalidRequest, err.Error()))
 	}
 
 	if len(req.Keys) == 0 {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Keys array cannot be empty",
-		})
+		return c.Status(fiber.StatusBadRequest).JSON(
+			NewErrorResponse("Keys array cannot be empty", ErrCodeInvalidRequest))
+	}
+	if ok, err := validateKeys(c, req.Keys...); !ok {
+		return err
+	}
+	if ok, err := allowCacheKeys(c, req.Keys...); !ok {
+		return err
 	}
 
 	// Extract instance context (optional for batch)
@@ -424,14 +561,37 @@ func (h *Handlers) BatchGet(c *fiber.Ctx) error {
 	}
 
 	results := make(map[string]json.RawMessage)
+	binary := make(map[string]string)
+	chunked := []string{}
 	missing := []string{}
+	addResult := func(key string, value []byte) error {
+		// Values stored in chunks are too large for a batch response
+		if cache.ParseManifest(value) != nil {
+			chunked = append(chunked, key)
+			return nil
+		}
+		entry, contentType, err := batchValue(value)
+		if err != nil {
+			return err
+		}
+		results[key] = entry
+		if contentType != "" {
+			binary[key] = contentType
+		}
+		return nil
+	}
 
 	// Get from local cache (using context-aware cache)
-	cacheResults, _ := h.contextCache.GetMultiple(ctx, req.Keys)
+	cacheResults, err := h.contextCache.GetMultiple(ctx, req.Keys)
+	if errors.Is(err, cache.ErrSealing) {
+		return sealError(c, "Failed to decrypt values", err)
+	}
 
 	for _, key := range req.Keys {
 		if value, ok := cacheResults[key]; ok {
-			results[key] = json.RawMessage(value)
+			if err := addResult(key, value); err != nil {
+				return valueError(c, err)
+			}
 		} else {
 			missing = append(missing, key)
 		}
@@ -443,19 +603,15 @@ func (h *Handlers) BatchGet(c *fiber.Ctx) error {
 		for _, key := range missing {
 			// Could optimize this with a batch endpoint on primary
 			queryCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
-			url := fmt.Sprintf("%s/v1/cache/%s", h.primaryURL, key)
-			req, _ := http.NewRequestWithContext(queryCtx, "GET", url, nil)
-			req.Header.Set("X-Instance-ID", instanceID)
-
-			resp, err := h.httpClient.Do(req)
+			value, err := h.fetchFromPrimary(queryCtx, key, instanceID)
 			cancel()
 
-			if err == nil && resp.StatusCode == http.StatusOK {
-				body, _ := io.ReadAll(resp.Body)
-				resp.Body.Close()
-				results[key] = json.RawMessage(body)
+			if err == nil {
+				if err := addResult(key, value); err != nil {
+					return valueError(c, err)
+				}
 				// Cache locally
-				h.contextCache.Set(ctx, key, body, 0)
+				h.contextCache.Set(ctx, key, value, 0)
 			}
 		}
 	}
@@ -463,15 +619,102 @@ func (h *Handlers) BatchGet(c *fiber.Ctx) error {
 	// Recalculate missing
 	finalMissing := []string{}
 	for _, key := range req.Keys {
-		if _, ok := results[key]; !ok {
+		if _, ok := results[key]; !ok && !slices.Contains(chunked, key) {
 			finalMissing = append(finalMissing, key)
 		}
 	}
 
-	return c.JSON(fiber.Map{
+	response := fiber.Map{
 		"entries": results,
 		"missing": finalMissing,
-	})
+	}
+	if len(binary) > 0 {
+		response["binary"] = binary
+	}
+	if len(chunked) > 0 {
+		response["chunked"] = chunked
+	}
+	return c.JSON(response)
+}
+
+// acceptedEncodings are the content encodings replicas accept from the
+// primary, so compressed values are cached without being recompressed
+var acceptedEncodings = cache.EncodingZstd + ", " + cache.EncodingGzip
+
+// encodeValue encodes a value for storage, compressing it if it is large
+func (h *Handlers) encodeValue(instanceID, contentType string, body []byte) []byte {
+	value, compressed := h.compressor.Encode(contentType, body)
+	if compressed > 0 {
+		RecordCompression(instanceID, h.compressor.Encoding(), len(body), compressed)
+	}
+	return value
+}
+
+// primaryValue encodes a value read from the primary for storage. Bodies the
+// primary sent compressed are kept compressed.
+func (h *Handlers) primaryValue(resp *http.Response, instanceID string, body []byte) []byte {
+	contentType := resp.Header.Get("Content-Type")
+	switch encoding := resp.Header.Get("Content-Encoding"); encoding {
+	case cache.EncodingGzip, cache.EncodingZstd:
+		return cache.EncodeCompressed(contentType, encoding, body)
+	}
+	return h.encodeValue(instanceID, contentType, body)
+}
+
+// acceptsEncoding reports whether an Accept-Encoding header accepts a content
+// encoding, either by name or with "*", and without q=0
+func acceptsEncoding(header, encoding string) bool {
+	for _, item := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(item, ";")
+		name = strings.TrimSpace(name)
+		if !strings.EqualFold(name, encoding) && name != "*" {
+			continue
+		}
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if weight, err := strconv.ParseFloat(q, 64); err == nil && weight == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// batchValue returns a value as a batch response entry. JSON is embedded as
+// is; anything else is base64-encoded and its content type returned.
+func batchValue(value []byte) (json.RawMessage, string, error) {
+	contentType, body, err := cache.DecodeValue(value)
+	if err != nil {
+		return nil, "", err
+	}
+	if cache.IsJSONContentType(contentType) && json.Valid(body) {
+		return json.RawMessage(body), "", nil
+	}
+	encoded, _ := json.Marshal(body)
+	return json.RawMessage(encoded), contentType, nil
+}
+
+// valueError responds to a stored value that cannot be decompressed
+func valueError(c *fiber.Ctx, err error) error {
+	return c.Status(fiber.StatusInternalServerError).JSON(
+		NewErrorResponseWithDetails("Failed to decompress value", ErrCodeInternalError, err.Error()))
+}
+
+// keyNotFound responds to a read of a key that holds no value
+func keyNotFound(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusNotFound).JSON(NewErrorResponse("Key not found", ErrCodeNotFound))
+}
+
+// primaryError relays an error response from the primary. Errors without a
+// code, e.g. from a proxy in between, are reported as the primary failing.
+func primaryError(c *fiber.Ctx, resp *http.Response) error {
+	var primaryErr ErrorResponse
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err := json.Unmarshal(body, &primaryErr); err != nil || primaryErr.Code == "" {
+		return c.Status(resp.StatusCode).JSON(
+			NewErrorResponseWithDetails("Primary returned error", ErrCodePrimaryUnavailable, resp.Status))
+	}
+	return c.Status(resp.StatusCode).JSON(&primaryErr)
 }
 
 // Shutdown gracefully shuts down the handlers
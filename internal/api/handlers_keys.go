@@ -0,0 +1,211 @@
+package api
+
+import (
+	"errors"
+	"time"
+
+	"github.com/birbparty/birb-nest/internal/api/apierror"
+	"github.com/birbparty/birb-nest/internal/auth"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Error codes for requests outside what an API key or token allows
+const (
+	ErrCodeUnauthorized      = apierror.CodeUnauthorized
+	ErrCodeInstanceForbidden = apierror.CodeInstanceForbidden
+	ErrCodeInsufficientScope = apierror.CodeInsufficientScope
+	ErrCodeKeyForbidden      = apierror.CodeKeyForbidden
+)
+
+// CreateKeyRequest describes a new API key
+type CreateKeyRequest struct {
+	Name      string       `json:"name"`
+	Scopes    []auth.Scope `json:"scopes"`
+	Instances []string     `json:"instances"` // instance ID glob patterns; "*" allows every instance
+	ExpiresAt *time.Time   `json:"expires_at,omitempty"`
+}
+
+// RotateKeyRequest configures a key rotation
+type RotateKeyRequest struct {
+	GracePeriod string `json:"grace_period,omitempty"` // how long the old key keeps working, e.g. "24h"
+}
+
+// KeySecretResponse returns a new key with its secret, which is never shown again
+type KeySecretResponse struct {
+	Key    *auth.Key `json:"key"`
+	Secret string    `json:"secret"`
+}
+
+// KeyListResponse lists API keys, newest first
+type KeyListResponse struct {
+	Keys []*auth.Key `json:"keys"`
+}
+
+// SetKeyring enables API key authentication and the key management endpoints
+func (h *Handlers) SetKeyring(keyring *auth.Keyring) {
+	h.keyring = keyring
+}
+
+// CreateKey handles POST /v1/keys
+func (h *Handlers) CreateKey(c *fiber.Ctx) error {
+	if h.keyring == nil {
+		return keysUnavailable(c)
+	}
+
+	var req CreateKeyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(
+			NewErrorResponse("Invalid request body", ErrCodeInvalidRequest))
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return c.Status(fiber.StatusBadRequest).JSON(
+			NewErrorResponse("expires_at must be in the future", ErrCodeInvalidRequest))
+	}
+
+	key, secret, err := h.keyring.Create(c.UserContext(), auth.CreateOptions{
+		Name:      req.Name,
+		Scopes:    req.Scopes,
+		Instances: req.Instances,
+		ExpiresAt: req.ExpiresAt,
+	})
+	if err != nil {
+		return keyError(c, "Failed to create API key", err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(KeySecretResponse{Key: key, Secret: secret})
+}
+
+// ListKeys handles GET /v1/keys
+func (h *Handlers) ListKeys(c *fiber.Ctx) error {
+	if h.keyring == nil {
+		return keysUnavailable(c)
+	}
+
+	keys, err := h.keyring.List(c.UserContext())
+	if err != nil {
+		return keyError(c, "Failed to list API keys", err)
+	}
+
+	return c.JSON(KeyListResponse{Keys: keys})
+}
+
+// GetKey handles GET /v1/keys/:id
+func (h *Handlers) GetKey(c *fiber.Ctx) error {
+	if h.keyring == nil {
+		return keysUnavailable(c)
+	}
+
+	key, err := h.keyring.Get(c.UserContext(), c.Params("id"))
+	if err != nil {
+		return keyError(c, "Failed to get API key", err)
+	}
+
+	return c.JSON(key)
+}
+
+// RotateKey handles POST /v1/keys/:id/rotate
+func (h *Handlers) RotateKey(c *fiber.Ctx) error {
+	if h.keyring == nil {
+		return keysUnavailable(c)
+	}
+
+	var req RotateKeyRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(
+				NewErrorResponse("Invalid request body", ErrCodeInvalidRequest))
+		}
+	}
+	var grace time.Duration
+	if req.GracePeriod != "" {
+		var err error
+		if grace, err = time.ParseDuration(req.GracePeriod); err != nil || grace < 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(
+				NewErrorResponse("grace_period must be a non-negative duration", ErrCodeInvalidRequest))
+		}
+	}
+
+	key, secret, err := h.keyring.Rotate(c.UserContext(), c.Params("id"), grace)
+	if err != nil {
+		return keyError(c, "Failed to rotate API key", err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(KeySecretResponse{Key: key, Secret: secret})
+}
+
+// RevokeKey handles DELETE /v1/keys/:id
+func (h *Handlers) RevokeKey(c *fiber.Ctx) error {
+	if h.keyring == nil {
+		return keysUnavailable(c)
+	}
+
+	key, err := h.keyring.Revoke(c.UserContext(), c.Params("id"))
+	if err != nil {
+		return keyError(c, "Failed to revoke API key", err)
+	}
+
+	return c.JSON(key)
+}
+
+// allowInstance checks that the request's API key may access an instance
+// named in the request body; route middleware only sees path parameters. An
+// empty ID, which the server generates, needs a key allowing every instance.
+// If access is denied the error response has been written and ok is false.
+func allowInstance(c *fiber.Ctx, instanceID string) (ok bool, err error) {
+	key, authenticated := auth.KeyFromContext(c.UserContext())
+	if !authenticated || key.AllowsAllInstances() {
+		return true, nil
+	}
+	if instanceID == "" {
+		return false, c.Status(fiber.StatusBadRequest).JSON(
+			NewErrorResponse("An instance ID is required for API keys limited to specific instances", ErrCodeInvalidRequest))
+	}
+	if !key.AllowsInstance(instanceID) {
+		return false, c.Status(fiber.StatusForbidden).JSON(
+			NewErrorResponseWithDetails("API key may not access this instance", ErrCodeInstanceForbidden, instanceID))
+	}
+	return true, nil
+}
+
+// allowCacheKeys checks that the request's API key or token may access cache
+// keys; tokens can be limited to key prefixes. If access is denied the error
+// response has been written and ok is false.
+func allowCacheKeys(c *fiber.Ctx, keys ...string) (ok bool, err error) {
+	apiKey, authenticated := auth.KeyFromContext(c.UserContext())
+	if !authenticated {
+		return true, nil
+	}
+	for _, key := range keys {
+		if !apiKey.AllowsCacheKey(key) {
+			return false, c.Status(fiber.StatusForbidden).JSON(
+				NewErrorResponseWithDetails("Token may not access this key", ErrCodeKeyForbidden, key))
+		}
+	}
+	return true, nil
+}
+
+// keyError maps keyring errors to responses
+func keyError(c *fiber.Ctx, msg string, err error) error {
+	switch {
+	case errors.Is(err, auth.ErrStoreUnavailable):
+		return keysUnavailable(c)
+	case errors.Is(err, auth.ErrKeyNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(
+			NewErrorResponse("API key not found", ErrCodeNotFound))
+	case errors.Is(err, auth.ErrInvalidKey):
+		return c.Status(fiber.StatusConflict).JSON(
+			NewErrorResponseWithDetails(msg, ErrCodeConflict, err.Error()))
+	case errors.Is(err, auth.ErrInvalidScope), errors.Is(err, auth.ErrNoInstances), errors.Is(err, auth.ErrInvalidPattern):
+		return c.Status(fiber.StatusBadRequest).JSON(
+			NewErrorResponseWithDetails(msg, ErrCodeInvalidRequest, err.Error()))
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(
+			NewErrorResponseWithDetails(msg, ErrCodeInternalError, err.Error()))
+	}
+}
+
+func keysUnavailable(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusServiceUnavailable).JSON(
+		NewErrorResponseWithDetails("API key management is not available", ErrCodeInternalError,
+			"API keys are stored in PostgreSQL on the primary"))
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/birbparty/birb-nest/internal/instance"
+	"github.com/gofiber/fiber/v2"
+)
+
+// CreateInstanceRequest describes a new instance
+type CreateInstanceRequest struct {
+	InstanceID    string                  `json:"instance_id"`
+	GameType      string                  `json:"game_type,omitempty"`
+	Region        string                  `json:"region,omitempty"`
+	IsPermanent   bool                    `json:"is_permanent,omitempty"`
+	ResourceQuota *instance.ResourceQuota `json:"resource_quota,omitempty"` // defaults to DefaultResourceQuota
+	Metadata      map[string]string       `json:"metadata,omitempty"`
+}
+
+// CreateInstance handles POST /v1/instances; with strict provisioning this is
+// the only way to create an instance
+func (h *Handlers) CreateInstance(c *fiber.Ctx) error {
+	if h.ops == nil {
+		return instanceOpsUnavailable(c)
+	}
+
+	var req CreateInstanceRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(
+			NewErrorResponse("Invalid request body", ErrCodeInvalidRequest))
+	}
+
+	if ok, err := allowInstance(c, req.InstanceID); !ok {
+		return err
+	}
+
+	inst := instance.NewContext(req.InstanceID)
+	if req.GameType != "" {
+		inst.GameType = req.GameType
+	}
+	if req.Region != "" {
+		inst.Region = req.Region
+	}
+	if req.ResourceQuota != nil {
+		inst.ResourceQuota = req.ResourceQuota
+	}
+	for k, v := range req.Metadata {
+		inst.Metadata[k] = v
+	}
+	inst.IsPermanent = req.IsPermanent
+
+	if err := h.ops.CreateInstance(c.UserContext(), inst); err != nil {
+		switch {
+		case isInstanceIDRejected(err), errors.Is(err, instance.ErrEmptyInstanceID):
+			return rejectInstanceID(c, err)
+		case errors.Is(err, instance.ErrInstanceExists):
+			return c.Status(fiber.StatusConflict).JSON(
+				NewErrorResponseWithDetails("Instance already exists", ErrCodeConflict, err.Error()))
+		default:
+			return c.Status(fiber.StatusInternalServerError).JSON(
+				NewErrorResponseWithDetails("Failed to create instance", ErrCodeInternalError, err.Error()))
+		}
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(inst)
+}
+
+// GetInstance handles GET /v1/instances/:id
+func (h *Handlers) GetInstance(c *fiber.Ctx) error {
+	inst, err := h.registry.Get(c.UserContext(), c.Params("id"))
+	if err != nil {
+		if errors.Is(err, instance.ErrInstanceNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(
+				NewErrorResponse("Instance not found", ErrCodeNotFound))
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(
+			NewErrorResponseWithDetails("Failed to read instance", ErrCodeInternalError, err.Error()))
+	}
+	return c.JSON(inst)
+}
+
+// isInstanceIDRejected reports whether a new instance ID failed the naming
+// rules or the provisioning allowlist
+func isInstanceIDRejected(err error) bool {
+	return errors.Is(err, instance.ErrInvalidInstanceID) || errors.Is(err, instance.ErrInstanceIDNotAllowed)
+}
+
+// rejectInstanceID responds to an instance ID that may not be created
+func rejectInstanceID(c *fiber.Ctx, err error) error {
+	if errors.Is(err, instance.ErrInstanceIDNotAllowed) {
+		return c.Status(fiber.StatusForbidden).JSON(
+			NewErrorResponseWithDetails("Instance ID is not allowed", ErrCodeForbidden, err.Error()))
+	}
+	return c.Status(fiber.StatusBadRequest).JSON(
+		NewErrorResponseWithDetails("Invalid instance ID", ErrCodeInvalidRequest, err.Error()))
+}
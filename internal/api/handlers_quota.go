@@ -0,0 +1,101 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/birbparty/birb-nest/internal/instance"
+	"github.com/birbparty/birb-nest/internal/quota"
+	"github.com/gofiber/fiber/v2"
+)
+
+// InstanceUsageResponse reports an instance's resource usage against its quota
+type InstanceUsageResponse struct {
+	InstanceID string                  `json:"instance_id"`
+	Quota      *instance.ResourceQuota `json:"quota"`
+	Limits     quota.Limits            `json:"limits"`
+	Usage      *quota.Usage            `json:"usage"`
+	Warnings   []quota.Warning         `json:"warnings"`
+}
+
+// SetQuotaEnforcer enables resource quota enforcement and the usage endpoint
+func (h *Handlers) SetQuotaEnforcer(enforcer *quota.Enforcer) {
+	h.quotas = enforcer
+}
+
+// GetInstanceUsage handles GET /v1/instances/:id/usage
+func (h *Handlers) GetInstanceUsage(c *fiber.Ctx) error {
+	if h.quotas == nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(
+			NewErrorResponse("Resource quotas are disabled", ErrCodeInternalError))
+	}
+
+	instanceID := c.Params("id")
+	inst, err := h.registry.Get(c.UserContext(), instanceID)
+	if err != nil {
+		if errors.Is(err, instance.ErrInstanceNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(
+				NewErrorResponse("Instance not found", ErrCodeNotFound))
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(
+			NewErrorResponseWithDetails("Failed to load instance", ErrCodeInternalError, err.Error()))
+	}
+
+	usage := h.quotas.Usage(c.UserContext(), instanceID)
+	warnings := h.quotas.Warnings(inst, usage)
+	if warnings == nil {
+		warnings = []quota.Warning{}
+	}
+
+	return c.JSON(InstanceUsageResponse{
+		InstanceID: instanceID,
+		Quota:      inst.ResourceQuota,
+		Limits:     quota.LimitsOf(inst.ResourceQuota),
+		Usage:      usage,
+		Warnings:   warnings,
+	})
+}
+
+// checkWriteQuota rejects a write that would exceed the instance's quota and
+// flags usage past the soft limit in the X-Quota-Warning header. It returns
+// true when the request was rejected.
+func (h *Handlers) checkWriteQuota(c *fiber.Ctx, key string, size int) (bool, error) {
+	instCtx, ok := instance.ExtractContext(c.UserContext())
+	if h.quotas == nil || !ok {
+		return false, nil
+	}
+
+	usage, err := h.quotas.CheckWrite(c.UserContext(), instCtx, key, size)
+	if err != nil {
+		return true, quotaRejected(c, instCtx.InstanceID, err)
+	}
+
+	if warnings := h.quotas.Warnings(instCtx, usage); len(warnings) > 0 {
+		parts := make([]string, len(warnings))
+		for i, w := range warnings {
+			parts[i] = w.String()
+		}
+		c.Set("X-Quota-Warning", strings.Join(parts, ", "))
+	}
+	return false, nil
+}
+
+// quotaRejected answers a request refused by a quota: 429 for too many
+// requests in flight, 507 Insufficient Storage for size and key limits
+func quotaRejected(c *fiber.Ctx, instanceID string, err error) error {
+	var limitErr *quota.LimitError
+	if !errors.As(err, &limitErr) {
+		return c.Status(fiber.StatusInternalServerError).JSON(
+			NewErrorResponseWithDetails("Failed to check resource quota", ErrCodeInternalError, err.Error()))
+	}
+	RecordQuotaRejection(instanceID, limitErr.Resource)
+
+	details := fmt.Sprintf("%s: %d of %d used", limitErr.Resource, limitErr.Used, limitErr.Limit)
+	if errors.Is(err, quota.ErrTooManyRequests) {
+		return c.Status(fiber.StatusTooManyRequests).JSON(
+			NewErrorResponseWithDetails("Too many concurrent requests for instance", ErrCodeRateLimited, details))
+	}
+	return c.Status(fiber.StatusInsufficientStorage).JSON(
+		NewErrorResponseWithDetails("Instance resource quota exceeded", ErrCodeQuotaExceeded, details))
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/birbparty/birb-nest/internal/operations"
+	"github.com/gofiber/fiber/v2"
+)
+
+// ReaperRunRequest configures a manual reaper run
+type ReaperRunRequest struct {
+	DryRun bool `json:"dry_run,omitempty"` // a reaper configured for dry runs always dry-runs
+}
+
+// ReaperAuditResponse lists reaper audit entries
+type ReaperAuditResponse struct {
+	Entries []operations.ReaperAuditEntry `json:"entries"`
+}
+
+// RunReaper handles POST /v1/reaper/run
+func (h *Handlers) RunReaper(c *fiber.Ctx) error {
+	if h.reaper == nil {
+		return instanceOpsUnavailable(c)
+	}
+
+	var req ReaperRunRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(
+				NewErrorResponse("Invalid request body", ErrCodeInvalidRequest))
+		}
+	}
+
+	result, err := h.reaper.Run(c.UserContext(), req.DryRun || h.reaper.Policy().DryRun)
+	if err != nil {
+		switch {
+		case errors.Is(err, operations.ErrReaperBusy):
+			return c.Status(fiber.StatusConflict).JSON(
+				NewErrorResponse("Reaper run already in progress", ErrCodeConflict))
+		case errors.Is(err, operations.ErrArchivingDisabled):
+			return archivingUnavailable(c)
+		default:
+			return c.Status(fiber.StatusInternalServerError).JSON(
+				NewErrorResponseWithDetails("Reaper run failed", ErrCodeInternalError, err.Error()))
+		}
+	}
+
+	return c.JSON(result)
+}
+
+// ListReaperAudit handles GET /v1/reaper/audit
+func (h *Handlers) ListReaperAudit(c *fiber.Ctx) error {
+	if h.ops == nil {
+		return instanceOpsUnavailable(c)
+	}
+
+	limit := c.QueryInt("limit", 100)
+	if limit <= 0 || limit > 1000 {
+		return c.Status(fiber.StatusBadRequest).JSON(
+			NewErrorResponse("limit must be between 1 and 1000", ErrCodeInvalidRequest))
+	}
+
+	entries, err := h.ops.ListReaperAudit(c.UserContext(), c.Query("instance_id"), limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(
+			NewErrorResponseWithDetails("Failed to list reaper audit", ErrCodeInternalError, err.Error()))
+	}
+
+	return c.JSON(ReaperAuditResponse{Entries: entries})
+}
+
+// GetInstanceTombstone handles GET /v1/instances/:id/tombstone
+func (h *Handlers) GetInstanceTombstone(c *fiber.Ctx) error {
+	if h.ops == nil {
+		return instanceOpsUnavailable(c)
+	}
+
+	tombstone, err := h.ops.GetInstanceTombstone(c.UserContext(), c.Params("id"))
+	if err != nil {
+		if errors.Is(err, operations.ErrTombstoneNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(
+				NewErrorResponse("Instance tombstone not found", ErrCodeNotFound))
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(
+			NewErrorResponseWithDetails("Failed to get instance tombstone", ErrCodeInternalError, err.Error()))
+	}
+
+	return c.JSON(tombstone)
+}
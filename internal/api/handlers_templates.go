@@ -0,0 +1,154 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/birbparty/birb-nest/internal/instance"
+	"github.com/birbparty/birb-nest/internal/operations"
+	"github.com/gofiber/fiber/v2"
+)
+
+// CreateTemplateRequest freezes an instance as a named template
+type CreateTemplateRequest struct {
+	Name             string                  `json:"name"`
+	SourceInstanceID string                  `json:"source_instance_id"`
+	GameType         string                  `json:"game_type,omitempty"`      // defaults to the source's game type
+	ResourceQuota    *instance.ResourceQuota `json:"resource_quota,omitempty"` // defaults to the source's quota
+	Metadata         map[string]string       `json:"metadata,omitempty"`
+}
+
+// TemplateListResponse lists templates
+type TemplateListResponse struct {
+	Templates []*operations.Template `json:"templates"`
+}
+
+// CreateFromTemplateRequest configures an instance created from a template
+type CreateFromTemplateRequest struct {
+	InstanceID string            `json:"instance_id,omitempty"` // generated from the template name when empty
+	Metadata   map[string]string `json:"metadata,omitempty"`    // merged over the template's metadata
+}
+
+// CreateTemplate handles POST /v1/templates
+func (h *Handlers) CreateTemplate(c *fiber.Ctx) error {
+	if h.ops == nil {
+		return instanceOpsUnavailable(c)
+	}
+
+	var req CreateTemplateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(
+			NewErrorResponse("Invalid request body", ErrCodeInvalidRequest))
+	}
+	if req.SourceInstanceID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(
+			NewErrorResponse("source_instance_id is required", ErrCodeInvalidRequest))
+	}
+	if ok, err := allowInstance(c, req.SourceInstanceID); !ok {
+		return err
+	}
+
+	tmpl, err := h.ops.CreateTemplate(c.UserContext(), req.Name, req.SourceInstanceID, operations.TemplateOptions{
+		GameType:      req.GameType,
+		ResourceQuota: req.ResourceQuota,
+		Metadata:      req.Metadata,
+	})
+	if err != nil {
+		return templateError(c, "Failed to create template", err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(tmpl)
+}
+
+// ListTemplates handles GET /v1/templates
+func (h *Handlers) ListTemplates(c *fiber.Ctx) error {
+	if h.ops == nil {
+		return instanceOpsUnavailable(c)
+	}
+
+	templates, err := h.ops.ListTemplates(c.UserContext())
+	if err != nil {
+		return templateError(c, "Failed to list templates", err)
+	}
+
+	return c.JSON(TemplateListResponse{Templates: templates})
+}
+
+// GetTemplate handles GET /v1/templates/:name
+func (h *Handlers) GetTemplate(c *fiber.Ctx) error {
+	if h.ops == nil {
+		return instanceOpsUnavailable(c)
+	}
+
+	tmpl, err := h.ops.GetTemplate(c.UserContext(), c.Params("name"))
+	if err != nil {
+		return templateError(c, "Failed to get template", err)
+	}
+
+	return c.JSON(tmpl)
+}
+
+// DeleteTemplate handles DELETE /v1/templates/:name
+func (h *Handlers) DeleteTemplate(c *fiber.Ctx) error {
+	if h.ops == nil {
+		return instanceOpsUnavailable(c)
+	}
+
+	if err := h.ops.DeleteTemplate(c.UserContext(), c.Params("name")); err != nil {
+		return templateError(c, "Failed to delete template", err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// CreateFromTemplate handles POST /v1/templates/:name/instances
+func (h *Handlers) CreateFromTemplate(c *fiber.Ctx) error {
+	if h.ops == nil {
+		return instanceOpsUnavailable(c)
+	}
+
+	var req CreateFromTemplateRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(
+				NewErrorResponse("Invalid request body", ErrCodeInvalidRequest))
+		}
+	}
+
+	if ok, err := allowInstance(c, req.InstanceID); !ok {
+		return err
+	}
+
+	inst, err := h.ops.CreateFromTemplate(c.UserContext(), c.Params("name"), operations.FromTemplateOptions{
+		InstanceID: req.InstanceID,
+		Metadata:   req.Metadata,
+	})
+	if err != nil {
+		return templateError(c, "Failed to create instance from template", err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(inst)
+}
+
+// templateError maps template errors to responses
+func templateError(c *fiber.Ctx, msg string, err error) error {
+	switch {
+	case errors.Is(err, operations.ErrTemplateNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(
+			NewErrorResponse("Template not found", ErrCodeNotFound))
+	case errors.Is(err, instance.ErrInstanceNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(
+			NewErrorResponse("Source instance not found", ErrCodeNotFound))
+	case isInstanceIDRejected(err):
+		return rejectInstanceID(c, err)
+	case errors.Is(err, operations.ErrInvalidTemplateName), errors.Is(err, instance.ErrReservedInstanceID):
+		return c.Status(fiber.StatusBadRequest).JSON(
+			NewErrorResponseWithDetails(msg, ErrCodeInvalidRequest, err.Error()))
+	case errors.Is(err, operations.ErrTemplateExists), errors.Is(err, operations.ErrTemplateInUse),
+		errors.Is(err, instance.ErrInstanceExists):
+		return c.Status(fiber.StatusConflict).JSON(
+			NewErrorResponseWithDetails(msg, ErrCodeConflict, err.Error()))
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(
+			NewErrorResponseWithDetails(msg, ErrCodeInternalError, err.Error()))
+	}
+}
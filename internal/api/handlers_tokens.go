@@ -0,0 +1,104 @@
+package api
+
+import (
+	"errors"
+	"time"
+
+	"github.com/birbparty/birb-nest/internal/auth"
+	"github.com/birbparty/birb-nest/internal/instance"
+	"github.com/gofiber/fiber/v2"
+)
+
+// CreateTokenRequest describes a signed client token
+type CreateTokenRequest struct {
+	InstanceID  string       `json:"instance_id"`
+	Subject     string       `json:"subject,omitempty"`      // e.g. the player ID
+	KeyPrefixes []string     `json:"key_prefixes,omitempty"` // e.g. ["player:42:"]; empty allows every key
+	Operations  []auth.Scope `json:"operations"`             // read and/or write
+	TTL         string       `json:"ttl,omitempty"`          // defaults to API_TOKEN_DEFAULT_TTL
+}
+
+// CreateTokenResponse returns a signed client token
+type CreateTokenResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// SetTokenSigner enables minting signed client tokens
+func (h *Handlers) SetTokenSigner(signer *auth.TokenSigner, defaultTTL, maxTTL time.Duration) {
+	h.tokens = signer
+	h.tokenTTL = defaultTTL
+	h.tokenMaxTTL = maxTTL
+}
+
+// CreateToken handles POST /v1/tokens. The caller's API key must allow the
+// instance and every operation the token grants.
+func (h *Handlers) CreateToken(c *fiber.Ctx) error {
+	if h.tokens == nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(
+			NewErrorResponseWithDetails("Signed tokens are not available", ErrCodeInternalError,
+				"set API_TOKEN_SIGNING_KEY and enable API key authentication"))
+	}
+
+	var req CreateTokenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(
+			NewErrorResponse("Invalid request body", ErrCodeInvalidRequest))
+	}
+	if req.InstanceID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(
+			NewErrorResponse("instance_id is required", ErrCodeInvalidRequest))
+	}
+	// A pattern would pass allowInstance against a key's own pattern and
+	// then act as a glob in the token's instance list
+	if err := instance.ValidateID(req.InstanceID); err != nil {
+		return rejectInstanceID(c, err)
+	}
+
+	ttl := h.tokenTTL
+	if req.TTL != "" {
+		parsed, err := time.ParseDuration(req.TTL)
+		if err != nil || parsed <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(
+				NewErrorResponse("ttl must be a positive duration", ErrCodeInvalidRequest))
+		}
+		ttl = parsed
+	}
+	if h.tokenMaxTTL > 0 && ttl > h.tokenMaxTTL {
+		return c.Status(fiber.StatusBadRequest).JSON(
+			NewErrorResponseWithDetails("ttl exceeds the maximum token lifetime", ErrCodeInvalidRequest, h.tokenMaxTTL.String()))
+	}
+
+	if ok, err := allowInstance(c, req.InstanceID); !ok {
+		return err
+	}
+	if key, ok := auth.KeyFromContext(c.UserContext()); ok {
+		for _, op := range req.Operations {
+			if !key.HasScope(op) {
+				return c.Status(fiber.StatusForbidden).JSON(
+					NewErrorResponseWithDetails("API key lacks a scope the token would grant", ErrCodeInsufficientScope, string(op)))
+			}
+		}
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+	token, err := h.tokens.Sign(&auth.TokenClaims{
+		Subject:     req.Subject,
+		Instance:    req.InstanceID,
+		KeyPrefixes: req.KeyPrefixes,
+		Operations:  req.Operations,
+		IssuedAt:    now.Unix(),
+		ExpiresAt:   expiresAt.Unix(),
+	})
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return c.Status(fiber.StatusBadRequest).JSON(
+				NewErrorResponseWithDetails("Invalid token request", ErrCodeInvalidRequest, err.Error()))
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(
+			NewErrorResponseWithDetails("Failed to sign token", ErrCodeInternalError, err.Error()))
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(CreateTokenResponse{Token: token, ExpiresAt: time.Unix(expiresAt.Unix(), 0)})
+}
@@ -0,0 +1,516 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/birbparty/birb-nest/internal/api/apierror"
+	"github.com/birbparty/birb-nest/internal/api/middleware"
+	"github.com/birbparty/birb-nest/internal/auth"
+	"github.com/birbparty/birb-nest/internal/cache"
+	"github.com/birbparty/birb-nest/internal/instance"
+	"github.com/gofiber/fiber/v2"
+)
+
+// ErrCodeValueTooLarge is returned for values larger than the maximum value size
+const ErrCodeValueTooLarge = apierror.CodeValueTooLarge
+
+// ErrCodeRangeNotSatisfiable is returned for ranges outside a value
+const ErrCodeRangeNotSatisfiable = apierror.CodeRangeNotSatisfiable
+
+// Error codes for cache keys that fail validation
+const (
+	ErrCodeInvalidKey  = apierror.CodeInvalidKey
+	ErrCodeKeyTooLong  = apierror.CodeKeyTooLong
+	ErrCodeReservedKey = apierror.CodeReservedKey
+)
+
+// errValueTooLarge is returned while reading a value larger than the maximum value size
+var errValueTooLarge = errors.New("value too large")
+
+// validateKeys checks cache keys from a request before they reach the cache
+// or the database. Replicas may read the internal keys value chunks are
+// stored under. If a key is invalid the error response has been written and
+// ok is false.
+func validateKeys(c *fiber.Ctx, keys ...string) (ok bool, err error) {
+	for _, key := range keys {
+		err := instance.ValidateKey(key)
+		if err == nil || (errors.Is(err, instance.ErrReservedKey) && readsInternalKey(c, key)) {
+			continue
+		}
+
+		code := ErrCodeInvalidKey
+		var instErr *instance.InstanceError
+		if errors.As(err, &instErr) {
+			code = instErr.Code
+		}
+		return false, c.Status(fiber.StatusBadRequest).JSON(
+			NewErrorResponseWithDetails("Invalid key", code, err.Error()))
+	}
+	return true, nil
+}
+
+// readsInternalKey reports whether a request reads an internal key on behalf
+// of a replica: one identified by its certificate or the root key, or any
+// caller when authentication is off
+func readsInternalKey(c *fiber.Ctx, key string) bool {
+	if c.Method() != fiber.MethodGet || !strings.HasPrefix(key, instance.InternalKeyPrefix) {
+		return false
+	}
+	if middleware.PeerName(c) != "" {
+		return true
+	}
+	apiKey, authenticated := auth.KeyFromContext(c.UserContext())
+	return !authenticated || apiKey.HasScope(auth.ScopeAdmin)
+}
+
+// readValue reads a whole request body of at most the maximum value size
+func (h *Handlers) readValue(c *fiber.Ctx) ([]byte, error) {
+	if h.maxValueSize > 0 && int64(c.Request().Header.ContentLength()) > h.maxValueSize {
+		return nil, errValueTooLarge
+	}
+
+	var value []byte
+	if stream := c.Request().BodyStream(); stream != nil {
+		var err error
+		value, err = io.ReadAll(io.LimitReader(stream, h.valueLimit()+1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+	} else {
+		value = bytes.Clone(c.Body())
+	}
+	if int64(len(value)) > h.valueLimit() {
+		return nil, errValueTooLarge
+	}
+	return value, nil
+}
+
+// valueLimit returns the maximum value size, or the largest size when unlimited
+func (h *Handlers) valueLimit() int64 {
+	if h.maxValueSize > 0 {
+		return h.maxValueSize
+	}
+	return math.MaxInt64 - 1
+}
+
+// isLargeValue reports whether a request body is stored in chunks: its
+// length is unknown or larger than a chunk
+func (h *Handlers) isLargeValue(c *fiber.Ctx) bool {
+	length := c.Request().Header.ContentLength()
+	return h.chunkSize > 0 && (length < 0 || length > h.chunkSize)
+}
+
+// setChunked stores a large request body in chunks as it arrives, then its
+// manifest under the key. Replicas stream the body through to the primary.
+func (h *Handlers) setChunked(c *fiber.Ctx, key, contentType, instanceID, sourceInstance string, timestamp time.Time) error {
+	ctx := c.UserContext()
+	if h.maxValueSize > 0 && int64(c.Request().Header.ContentLength()) > h.maxValueSize {
+		return valueTooLarge(c, h.maxValueSize)
+	}
+	if !h.isPrimary {
+		return h.forwardStreamToPrimary(c, key, contentType, instanceID, timestamp)
+	}
+
+	length := c.Request().Header.ContentLength()
+	if length > 0 {
+		if rejected, err := h.checkWriteQuota(c, key, length); rejected {
+			return err
+		}
+	}
+
+	if contentType == "" {
+		contentType = cache.ContentTypeBinary
+	}
+	manifest, err := cache.NewManifest(contentType, h.chunkSize)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(
+			NewErrorResponseWithDetails("Failed to store value", ErrCodeInternalError, err.Error()))
+	}
+	previous := h.storedManifest(ctx, key, instanceID, false)
+
+	body := c.Request().BodyStream()
+	if body == nil {
+		body = bytes.NewReader(c.Body())
+	}
+	// abort drops the chunks written so far
+	abort := func() {
+		go h.dropChunks(context.WithoutCancel(ctx), manifest, instanceID)
+	}
+
+	buf := make([]byte, h.chunkSize)
+	for {
+		n, err := io.ReadFull(body, buf)
+		if n > 0 {
+			manifest.Size += int64(n)
+			if manifest.Size > h.valueLimit() {
+				abort()
+				return valueTooLarge(c, h.maxValueSize)
+			}
+			if err := h.storeChunk(ctx, manifest.ChunkKey(manifest.Chunks), bytes.Clone(buf[:n]), instanceID, sourceInstance); err != nil {
+				abort()
+				return sealError(c, "Failed to store value", err)
+			}
+			manifest.Chunks++
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			abort()
+			return c.Status(fiber.StatusBadRequest).JSON(
+				NewErrorResponseWithDetails("Failed to read request body", ErrCodeInvalidRequest, err.Error()))
+		}
+	}
+
+	// Bodies of unknown length are checked against the quota once they are read
+	if length < 0 {
+		if rejected, err := h.checkWriteQuota(c, key, int(manifest.Size)); rejected {
+			abort()
+			return err
+		}
+	}
+
+	stored, err := h.seal(ctx, key, manifest.Encode())
+	if err == nil {
+		err = h.contextCache.Set(ctx, key, stored, 0)
+	}
+	if err != nil {
+		abort()
+		RecordCacheOperation("set", "error", instanceID, h.mode)
+		return sealError(c, "Failed to store value", err)
+	}
+	RecordCacheOperation("set", "success", instanceID, h.mode)
+	if h.asyncWriter != nil {
+		h.asyncWriter.Write(ctx, key, stored, sourceInstance)
+	}
+
+	if previous != nil {
+		go h.dropChunks(context.WithoutCancel(ctx), previous, instanceID)
+	}
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// storeChunk writes one chunk of a value to the cache and, on the primary, to the database
+func (h *Handlers) storeChunk(ctx context.Context, key string, chunk []byte, instanceID, sourceInstance string) error {
+	stored, err := h.seal(ctx, key, h.encodeValue(instanceID, cache.ContentTypeBinary, chunk))
+	if err != nil {
+		return err
+	}
+	if err := h.contextCache.Set(ctx, key, stored, 0); err != nil {
+		return fmt.Errorf("failed to cache chunk: %w", err)
+	}
+	if h.asyncWriter != nil {
+		h.asyncWriter.Write(ctx, key, stored, sourceInstance)
+	}
+	return nil
+}
+
+// storedManifest returns the manifest of a key's current value, or nil if it
+// is not stored in chunks. The database is only consulted if fromDatabase is set.
+func (h *Handlers) storedManifest(ctx context.Context, key, instanceID string, fromDatabase bool) *cache.Manifest {
+	if h.chunkSize <= 0 {
+		return nil
+	}
+	value, err := h.contextCache.Get(ctx, key)
+	if err != nil && fromDatabase && h.asyncWriter != nil && h.asyncWriter.db != nil {
+		var stored []byte
+		if stored, err = h.asyncWriter.db.GetWithInstance(ctx, key, instanceID); err == nil {
+			value, err = h.open(ctx, key, stored)
+		}
+	}
+	if err != nil {
+		return nil
+	}
+	return cache.ParseManifest(value)
+}
+
+// dropChunks deletes the chunks of a value that was replaced or deleted
+func (h *Handlers) dropChunks(ctx context.Context, manifest *cache.Manifest, instanceID string) {
+	for _, key := range manifest.ChunkKeys() {
+		h.contextCache.Delete(ctx, key)
+		if h.isPrimary && h.asyncWriter != nil && h.asyncWriter.db != nil {
+			h.asyncWriter.db.DeleteWithInstance(ctx, key, instanceID)
+		}
+	}
+}
+
+// forwardStreamToPrimary streams a large request body to the primary and
+// waits for it to be stored; the replica's cached copy of the key is dropped
+func (h *Handlers) forwardStreamToPrimary(c *fiber.Ctx, key, contentType, instanceID string, timestamp time.Time) error {
+	ctx := c.UserContext()
+	body := c.Request().BodyStream()
+	if body == nil {
+		body = bytes.NewReader(c.Body())
+	}
+
+	url := fmt.Sprintf("%s/v1/cache/%s", h.primaryURL, key)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, io.LimitReader(body, h.valueLimit()+1))
+	if err != nil {
+		RecordWriteForward(instanceID, "error")
+		return c.Status(fiber.StatusInternalServerError).JSON(
+			NewErrorResponseWithDetails("Failed to create primary request", ErrCodeInternalError, err.Error()))
+	}
+	req.ContentLength = int64(c.Request().Header.ContentLength())
+	req.Header.Set("X-Instance-ID", instanceID)
+	req.Header.Set("X-Write-Timestamp", timestamp.Format(time.RFC3339Nano))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	h.setPrimaryAuth(req)
+
+	// The upload can take longer than the client's timeout for small requests
+	client := *h.httpClient
+	client.Timeout = 0
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Printf("Failed to forward write to primary: %v", err)
+		RecordWriteForward(instanceID, "error")
+		return c.Status(fiber.StatusServiceUnavailable).JSON(
+			NewErrorResponseWithDetails("Primary unavailable", ErrCodePrimaryUnavailable, err.Error()))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		RecordWriteForward(instanceID, "error")
+		return primaryError(c, resp)
+	}
+	RecordWriteForward(instanceID, "success")
+
+	if previous := h.storedManifest(ctx, key, instanceID, false); previous != nil {
+		go h.dropChunks(context.WithoutCancel(ctx), previous, instanceID)
+	}
+	h.contextCache.Delete(ctx, key)
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// sendValue responds with a stored value and its content type. Compressed
+// values are sent as is to clients that accept their content encoding, and
+// values stored in chunks are streamed. Single byte ranges are supported.
+func (h *Handlers) sendValue(c *fiber.Ctx, value []byte, instanceID string) error {
+	if manifest := cache.ParseManifest(value); manifest != nil {
+		// Replicas cache the manifest and fetch the chunks they need
+		if strings.Contains(c.Get(fiber.HeaderAccept), cache.ContentTypeManifest) {
+			_, _, body := cache.ParseValue(value)
+			c.Set(fiber.HeaderContentType, cache.ContentTypeManifest)
+			return c.Send(body)
+		}
+		return h.sendChunked(c, manifest, instanceID)
+	}
+
+	contentType, encoding, body := cache.ParseValue(value)
+	c.Set(fiber.HeaderContentType, contentType)
+	c.Set(fiber.HeaderAcceptRanges, "bytes")
+	if encoding != "" {
+		c.Vary(fiber.HeaderAcceptEncoding)
+		if c.Get(fiber.HeaderRange) == "" && acceptsEncoding(c.Get(fiber.HeaderAcceptEncoding), encoding) {
+			c.Set(fiber.HeaderContentEncoding, encoding)
+			return c.Send(body)
+		}
+		var err error
+		if body, err = cache.Decompress(encoding, body); err != nil {
+			return valueError(c, err)
+		}
+	}
+
+	start, end, err := byteRange(c, int64(len(body)))
+	if err != nil {
+		return rangeNotSatisfiable(c, int64(len(body)))
+	}
+	return c.Send(body[start : end+1])
+}
+
+// sendChunked streams the requested range of a value stored in chunks. The
+// first chunk is read before responding so a missing value is still an error.
+func (h *Handlers) sendChunked(c *fiber.Ctx, manifest *cache.Manifest, instanceID string) error {
+	c.Set(fiber.HeaderContentType, manifest.ContentType)
+	c.Set(fiber.HeaderAcceptRanges, "bytes")
+	start, end, err := byteRange(c, manifest.Size)
+	if err != nil {
+		return rangeNotSatisfiable(c, manifest.Size)
+	}
+
+	// The body is read after the handler returns
+	ctx := context.WithoutCancel(c.UserContext())
+	r := &chunkReader{
+		manifest:  manifest,
+		offset:    start,
+		remaining: end - start + 1,
+		fetch: func(index int) ([]byte, error) {
+			return h.fetchChunk(ctx, manifest, index, instanceID)
+		},
+	}
+	if r.remaining > 0 {
+		if err := r.load(); err != nil {
+			if errors.Is(err, cache.ErrSealing) {
+				return sealError(c, "Failed to decrypt value", err)
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(
+				NewErrorResponseWithDetails("Failed to read value", ErrCodeInternalError, err.Error()))
+		}
+	}
+	return c.SendStream(r, int(r.remaining))
+}
+
+// fetchChunk returns a chunk of a value from the cache, falling back to the
+// database on the primary and to the primary on replicas
+func (h *Handlers) fetchChunk(ctx context.Context, manifest *cache.Manifest, index int, instanceID string) ([]byte, error) {
+	key := manifest.ChunkKey(index)
+	value, err := h.contextCache.Get(ctx, key)
+	if errors.Is(err, cache.ErrSealing) {
+		return nil, err
+	}
+	if err != nil {
+		if h.isPrimary {
+			if h.asyncWriter == nil || h.asyncWriter.db == nil {
+				return nil, fmt.Errorf("%w: chunk %d is missing", cache.ErrIncompleteValue, index)
+			}
+			stored, dbErr := h.asyncWriter.db.GetWithInstance(ctx, key, instanceID)
+			if dbErr != nil {
+				return nil, fmt.Errorf("%w: chunk %d: %v", cache.ErrIncompleteValue, index, dbErr)
+			}
+			h.contextCache.Set(ctx, key, stored, 0)
+			if value, err = h.open(ctx, key, stored); err != nil {
+				return nil, err
+			}
+		} else {
+			if value, err = h.fetchFromPrimary(ctx, key, instanceID); err != nil {
+				return nil, fmt.Errorf("%w: chunk %d: %v", cache.ErrIncompleteValue, index, err)
+			}
+			h.contextCache.Set(ctx, key, value, 0)
+		}
+	}
+
+	_, chunk, err := cache.DecodeValue(value)
+	if err != nil {
+		return nil, err
+	}
+	return chunk, nil
+}
+
+// fetchFromPrimary reads a value from the primary, encoded for storage
+func (h *Handlers) fetchFromPrimary(ctx context.Context, key, instanceID string) ([]byte, error) {
+	url := fmt.Sprintf("%s/v1/cache/%s", h.primaryURL, key)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create primary request: %w", err)
+	}
+	req.Header.Set("X-Instance-ID", instanceID)
+	req.Header.Set("Accept", manifestAccept)
+	req.Header.Set("Accept-Encoding", acceptedEncodings)
+	h.setPrimaryAuth(req)
+
+	resp, err := h.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach primary: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, cache.ErrKeyNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("primary returned status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read primary response: %w", err)
+	}
+	return h.primaryValue(resp, instanceID, body), nil
+}
+
+// manifestAccept is the Accept header replicas send to the primary, which
+// then sends manifests rather than whole values stored in chunks
+const manifestAccept = cache.ContentTypeManifest + ", */*"
+
+// chunkReader reads a range of a value stored in chunks, fetching each chunk
+// when it is reached
+type chunkReader struct {
+	manifest  *cache.Manifest
+	fetch     func(index int) ([]byte, error)
+	offset    int64 // next byte of the value to read
+	remaining int64
+	chunk     []byte // the chunk holding offset once loaded
+	index     int
+}
+
+// load fetches the chunk holding the next byte to read
+func (r *chunkReader) load() error {
+	index := int(r.offset / int64(r.manifest.ChunkSize))
+	if r.chunk != nil && r.index == index {
+		return nil
+	}
+	chunk, err := r.fetch(index)
+	if err != nil {
+		return err
+	}
+	r.chunk, r.index = chunk, index
+	return nil
+}
+
+// Read implements io.Reader
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if r.remaining <= 0 {
+		return 0, io.EOF
+	}
+	if err := r.load(); err != nil {
+		return 0, err
+	}
+
+	within := r.offset - int64(r.index)*int64(r.manifest.ChunkSize)
+	if within >= int64(len(r.chunk)) {
+		return 0, fmt.Errorf("%w: chunk %d is short", cache.ErrIncompleteValue, r.index)
+	}
+	available := r.chunk[within:]
+	if int64(len(available)) > r.remaining {
+		available = available[:r.remaining]
+	}
+	n := copy(p, available)
+	r.offset += int64(n)
+	r.remaining -= int64(n)
+	return n, nil
+}
+
+// byteRange returns the first and last byte of a value of size bytes to
+// send. Requests for a single byte range are answered with 206 Partial
+// Content; anything else that is not unsatisfiable gets the whole value.
+func byteRange(c *fiber.Ctx, size int64) (int64, int64, error) {
+	if c.Get(fiber.HeaderRange) == "" {
+		return 0, size - 1, nil
+	}
+	r, err := c.Range(int(size))
+	if errors.Is(err, fiber.ErrRangeUnsatisfiable) {
+		return 0, 0, err
+	}
+	if err != nil || r.Type != "bytes" || len(r.Ranges) != 1 {
+		return 0, size - 1, nil
+	}
+
+	start, end := int64(r.Ranges[0].Start), int64(r.Ranges[0].End)
+	c.Status(fiber.StatusPartialContent)
+	c.Set(fiber.HeaderContentRange, "bytes "+strconv.FormatInt(start, 10)+"-"+strconv.FormatInt(end, 10)+"/"+strconv.FormatInt(size, 10))
+	return start, end, nil
+}
+
+// rangeNotSatisfiable responds to a range outside a value of size bytes
+func rangeNotSatisfiable(c *fiber.Ctx, size int64) error {
+	c.Set(fiber.HeaderContentRange, "bytes */"+strconv.FormatInt(size, 10))
+	return c.Status(fiber.StatusRequestedRangeNotSatisfiable).JSON(
+		NewErrorResponse("Requested range not satisfiable", ErrCodeRangeNotSatisfiable))
+}
+
+// valueTooLarge responds to a value larger than the maximum value size
+func valueTooLarge(c *fiber.Ctx, maxSize int64) error {
+	return c.Status(fiber.StatusRequestEntityTooLarge).JSON(
+		NewErrorResponseWithDetails("Value too large", ErrCodeValueTooLarge,
+			fmt.Sprintf("values are limited to %d bytes", maxSize)))
+}
@@ -0,0 +1,273 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/birbparty/birb-nest/internal/cache"
+	"github.com/birbparty/birb-nest/internal/instance"
+	"github.com/birbparty/birb-nest/internal/quota"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestChunkReader(t *testing.T) {
+	chunks := [][]byte{[]byte("abcd"), []byte("efgh"), []byte("ij")}
+	short := [][]byte{[]byte("abcd"), []byte("ef"), []byte("ij")}
+	missing := [][]byte{[]byte("abcd"), nil, []byte("ij")}
+
+	tests := []struct {
+		name       string
+		chunks     [][]byte // nil chunks are missing
+		start, end int64
+		want       string
+		wantErr    error
+	}{
+		{"whole value", chunks, 0, 9, "abcdefghij", nil},
+		{"within a chunk", chunks, 5, 6, "fg", nil},
+		{"across chunk boundaries", chunks, 3, 8, "defghi", nil},
+		{"last chunk only", chunks, 8, 9, "ij", nil},
+		{"short chunk", short, 0, 9, "abcdef", cache.ErrIncompleteValue},
+		{"missing chunk", missing, 2, 9, "cd", cache.ErrIncompleteValue},
+		{"missing chunk outside the range", missing, 8, 9, "ij", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fetched []int
+			r := &chunkReader{
+				manifest:  &cache.Manifest{ChunkSize: 4, Size: 10, Chunks: 3},
+				offset:    tt.start,
+				remaining: tt.end - tt.start + 1,
+				fetch: func(index int) ([]byte, error) {
+					fetched = append(fetched, index)
+					if tt.chunks[index] == nil {
+						return nil, fmt.Errorf("%w: chunk %d is missing", cache.ErrIncompleteValue, index)
+					}
+					return tt.chunks[index], nil
+				},
+			}
+
+			// Reading a byte at a time must not fetch a chunk more than once
+			got, err := io.ReadAll(iotest.OneByteReader(r))
+			if string(got) != tt.want {
+				t.Errorf("read %q, want %q", got, tt.want)
+			}
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+			for i := 1; i < len(fetched); i++ {
+				if fetched[i] <= fetched[i-1] {
+					t.Errorf("fetched chunks %v, want each once and in order", fetched)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestByteRange(t *testing.T) {
+	app := fiber.New()
+	app.Get("/", func(c *fiber.Ctx) error {
+		start, end, err := byteRange(c, 10)
+		if err != nil {
+			return rangeNotSatisfiable(c, 10)
+		}
+		return c.SendString(fmt.Sprintf("%d-%d", start, end))
+	})
+
+	tests := []struct {
+		name             string
+		header           string
+		wantStatus       int
+		wantBody         string
+		wantContentRange string
+	}{
+		{"no range", "", fiber.StatusOK, "0-9", ""},
+		{"closed", "bytes=2-5", fiber.StatusPartialContent, "2-5", "bytes 2-5/10"},
+		{"suffix", "bytes=-3", fiber.StatusPartialContent, "7-9", "bytes 7-9/10"},
+		{"open-ended", "bytes=4-", fiber.StatusPartialContent, "4-9", "bytes 4-9/10"},
+		{"end past the value", "bytes=8-20", fiber.StatusPartialContent, "8-9", "bytes 8-9/10"},
+		{"multi-range", "bytes=0-1,4-5", fiber.StatusOK, "0-9", ""},
+		{"other unit", "items=0-1", fiber.StatusOK, "0-9", ""},
+		{"unsatisfiable", "bytes=10-12", fiber.StatusRequestedRangeNotSatisfiable, "", "bytes */10"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(fiber.HeaderRange, tt.header)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("app.Test() error = %v", err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantBody != "" && string(body) != tt.wantBody {
+				t.Errorf("range = %s, want %s", body, tt.wantBody)
+			}
+			if got := resp.Header.Get(fiber.HeaderContentRange); got != tt.wantContentRange {
+				t.Errorf("Content-Range = %q, want %q", got, tt.wantContentRange)
+			}
+		})
+	}
+}
+
+// valuesEnv serves the cache endpoints of a primary without a database that
+// stores values larger than 4 bytes in chunks
+type valuesEnv struct {
+	app   *fiber.App
+	cache *cache.MemoryCache
+	quota *instance.ResourceQuota // of every request's instance
+}
+
+func newValuesEnv(t *testing.T) *valuesEnv {
+	t.Helper()
+	mc, err := cache.NewMemoryCache(&cache.MemoryConfig{})
+	if err != nil {
+		t.Fatalf("NewMemoryCache() error = %v", err)
+	}
+	t.Cleanup(func() { mc.Close() })
+
+	h := NewHandlers(&Config{Mode: "primary", Values: ValueConfig{MaxSize: 1 << 10, ChunkSize: 4}}, mc, nil, instance.NewRegistry(mc))
+	h.SetQuotaEnforcer(quota.NewEnforcer(mc, 0))
+
+	env := &valuesEnv{cache: mc}
+	env.app = fiber.New(fiber.Config{StreamRequestBody: true, ErrorHandler: ErrorHandler})
+	env.app.Use(func(c *fiber.Ctx) error {
+		instCtx := instance.NewContext("game-1")
+		instCtx.ResourceQuota = env.quota
+		c.SetUserContext(instance.InjectContext(c.UserContext(), instCtx))
+		return c.Next()
+	})
+	env.app.Put("/:key", h.Set)
+	env.app.Get("/:key", h.Get)
+	return env
+}
+
+// do sends a request, with a body of unknown length if chunked is set
+func (e *valuesEnv) do(t *testing.T, method, key, body string, chunked bool, headers map[string]string) (*http.Response, string) {
+	t.Helper()
+	req := httptest.NewRequest(method, "/"+key, strings.NewReader(body))
+	if chunked {
+		req = httptest.NewRequest(method, "/"+key, io.NopCloser(strings.NewReader(body)))
+		req.ContentLength = -1
+		req.TransferEncoding = []string{"chunked"}
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := e.app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("app.Test(%s /%s) error = %v", method, key, err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	return resp, string(data)
+}
+
+// manifest returns the manifest cached under a key, or nil
+func (e *valuesEnv) manifest(key string) *cache.Manifest {
+	value, err := e.cache.Get(context.Background(), instance.NewKeyBuilder("game-1").CacheKey(key))
+	if err != nil {
+		return nil
+	}
+	return cache.ParseManifest(value)
+}
+
+// chunks returns the number of chunk keys in the cache
+func (e *valuesEnv) chunks(t *testing.T) int {
+	t.Helper()
+	keys, err := e.cache.Scan(context.Background(), instance.NewKeyBuilder("game-1").CacheKey(cache.ChunkKeyPrefix)+"*", 100)
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	return len(keys)
+}
+
+// waitForChunks waits until the cache holds want chunk keys; chunks of
+// replaced and rejected values are dropped in the background
+func (e *valuesEnv) waitForChunks(t *testing.T, want int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for e.chunks(t) != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("cache holds %d chunks, want %d", e.chunks(t), want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSetAndGet_Chunked(t *testing.T) {
+	env := newValuesEnv(t)
+	for _, chunked := range []bool{false, true} {
+		resp, body := env.do(t, http.MethodPut, "big", "abcdefghij", chunked, map[string]string{"Content-Type": "text/plain"})
+		if resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("PUT (chunked %v) = %d %s", chunked, resp.StatusCode, body)
+		}
+		if m := env.manifest("big"); m == nil || m.Chunks != 3 || m.Size != 10 {
+			t.Fatalf("manifest after PUT (chunked %v) = %+v, want 3 chunks of 10 bytes", chunked, m)
+		}
+
+		resp, body = env.do(t, http.MethodGet, "big", "", false, nil)
+		if resp.StatusCode != fiber.StatusOK || body != "abcdefghij" || resp.Header.Get("Content-Type") != "text/plain" {
+			t.Errorf("GET = %d %q %s, want the whole value as text/plain", resp.StatusCode, body, resp.Header.Get("Content-Type"))
+		}
+		resp, body = env.do(t, http.MethodGet, "big", "", false, map[string]string{"Range": "bytes=3-8"})
+		if resp.StatusCode != fiber.StatusPartialContent || body != "defghi" {
+			t.Errorf("GET bytes=3-8 = %d %q, want 206 defghi", resp.StatusCode, body)
+		}
+	}
+	// The second PUT replaced the first value and dropped its chunks
+	env.waitForChunks(t, 3)
+
+	// A small value replacing a chunked one drops its chunks too
+	if resp, body := env.do(t, http.MethodPut, "big", "ab", false, nil); resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("PUT small = %d %s", resp.StatusCode, body)
+	}
+	env.waitForChunks(t, 0)
+	if _, body := env.do(t, http.MethodGet, "big", "", false, nil); body != "ab" {
+		t.Errorf("GET after replacing = %q, want ab", body)
+	}
+}
+
+func TestSet_ChunkedQuota(t *testing.T) {
+	env := newValuesEnv(t)
+	if resp, body := env.do(t, http.MethodPut, "small", "ab", false, nil); resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("PUT small = %d %s", resp.StatusCode, body)
+	}
+	env.quota = &instance.ResourceQuota{MaxKeys: 1}
+
+	// Known lengths are checked before any chunk is stored, unknown ones
+	// once the body is read, after which the stored chunks are dropped
+	for _, chunked := range []bool{false, true} {
+		resp, body := env.do(t, http.MethodPut, "big", "abcdefghij", chunked, nil)
+		if resp.StatusCode != fiber.StatusInsufficientStorage || !strings.Contains(body, ErrCodeQuotaExceeded) {
+			t.Errorf("PUT (chunked %v) over quota = %d %s, want 507 %s", chunked, resp.StatusCode, body, ErrCodeQuotaExceeded)
+		}
+		env.waitForChunks(t, 0)
+		if m := env.manifest("big"); m != nil {
+			t.Errorf("PUT (chunked %v) over quota stored manifest %+v", chunked, m)
+		}
+	}
+}
+
+func TestSet_ReservedContentTypes(t *testing.T) {
+	env := newValuesEnv(t)
+	for _, contentType := range []string{cache.ContentTypeManifest, cache.ContentTypeSealed + "; v=1"} {
+		resp, body := env.do(t, http.MethodPut, "k", "{}", false, map[string]string{"Content-Type": contentType})
+		if resp.StatusCode != fiber.StatusBadRequest || !strings.Contains(body, "reserved") {
+			t.Errorf("PUT as %s = %d %s, want 400", contentType, resp.StatusCode, body)
+		}
+	}
+}
@@ -51,6 +51,35 @@ var (
 		Help: "Total number of queries to primary on cache miss",
 	}, []string{"instance_id", "result"})
 
+	// Quota metrics
+	quotaRejections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "birbnest_quota_rejections_total",
+		Help: "Total number of requests rejected by instance resource quotas",
+	}, []string{"instance_id", "resource"})
+
+	// Rate limit metrics
+	rateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "birbnest_rate_limited_total",
+		Help: "Total number of requests rejected by rate limits",
+	}, []string{"instance_id", "scope", "class"})
+
+	// Compression metrics; the ratio of two rates is the overall compression ratio
+	compressionInputBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "birbnest_compression_input_bytes_total",
+		Help: "Total size of values before compression",
+	}, []string{"instance_id", "encoding"})
+
+	compressionOutputBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "birbnest_compression_output_bytes_total",
+		Help: "Total size of values after compression",
+	}, []string{"instance_id", "encoding"})
+
+	compressionRatio = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "birbnest_compression_ratio",
+		Help:    "Compressed size divided by original size of compressed values",
+		Buckets: []float64{.05, .1, .2, .3, .4, .5, .6, .7, .8, .9, 1},
+	}, []string{"instance_id", "encoding"})
+
 	// System health
 	healthStatus = promauto.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "birbnest_health_status",
@@ -126,6 +155,23 @@ func RecordPrimaryQuery(instanceID, result string) {
 	primaryQueries.WithLabelValues(instanceID, result).Inc()
 }
 
+// RecordQuotaRejection records a request rejected by an instance quota
+func RecordQuotaRejection(instanceID, resource string) {
+	quotaRejections.WithLabelValues(instanceID, resource).Inc()
+}
+
+// RecordRateLimited records a request rejected by a rate limit
+func RecordRateLimited(instanceID, scope, class string) {
+	rateLimited.WithLabelValues(instanceID, scope, class).Inc()
+}
+
+// RecordCompression records a value compressed from size to compressed bytes
+func RecordCompression(instanceID, encoding string, size, compressed int) {
+	compressionInputBytes.WithLabelValues(instanceID, encoding).Add(float64(size))
+	compressionOutputBytes.WithLabelValues(instanceID, encoding).Add(float64(compressed))
+	compressionRatio.WithLabelValues(instanceID, encoding).Observe(float64(compressed) / float64(size))
+}
+
 // InitializeAsyncMetrics initializes async writer metrics
 func InitializeAsyncMetrics(instanceID string, queueCapacity int) {
 	asyncQueueCapacity.WithLabelValues(instanceID).Set(float64(queueCapacity))
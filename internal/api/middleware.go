@@ -1,9 +1,14 @@
 package api
 
 import (
+	"errors"
 	"fmt"
+	"log"
 	"time"
 
+	"github.com/birbparty/birb-nest/internal/api/middleware"
+	"github.com/birbparty/birb-nest/internal/instance"
+	"github.com/birbparty/birb-nest/internal/quota"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/logger"
@@ -45,39 +50,54 @@ func SetupMiddleware(app *fiber.App) {
 // errorHandler creates a custom error handling middleware
 func errorHandler() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		err := c.Next()
-		if err != nil {
-			// Default to 500 Internal Server Error
-			code := fiber.StatusInternalServerError
-			message := "Internal Server Error"
-			errCode := ErrCodeInternalError
-
-			// Check if it's a Fiber error
-			if e, ok := err.(*fiber.Error); ok {
-				code = e.Code
-				message = e.Message
-			}
+		if err := c.Next(); err != nil {
+			return ErrorHandler(c, err)
+		}
+		return nil
+	}
+}
 
-			// Map common errors to appropriate codes
-			switch code {
-			case fiber.StatusNotFound:
-				errCode = ErrCodeNotFound
-			case fiber.StatusBadRequest:
-				errCode = ErrCodeInvalidRequest
-			case fiber.StatusRequestTimeout:
-				errCode = ErrCodeTimeout
-			case fiber.StatusTooManyRequests:
-				errCode = ErrCodeRateLimited
-			}
+// ErrorHandler responds to errors returned by handlers and by Fiber itself,
+// such as oversized bodies, with an ErrorResponse. It is meant for
+// fiber.Config.ErrorHandler.
+func ErrorHandler(c *fiber.Ctx, err error) error {
+	// Default to 500 Internal Server Error
+	code := fiber.StatusInternalServerError
+	message := "Internal Server Error"
+	errCode := ErrCodeInternalError
+
+	// Check if it's a Fiber error
+	var e *fiber.Error
+	if errors.As(err, &e) {
+		code = e.Code
+		message = e.Message
+	}
 
-			// Log the error
-			fmt.Printf("Error: %v, Path: %s, Method: %s\n", err, c.Path(), c.Method())
+	// Map common errors to appropriate codes
+	switch code {
+	case fiber.StatusNotFound:
+		errCode = ErrCodeNotFound
+	case fiber.StatusBadRequest, fiber.StatusMethodNotAllowed, fiber.StatusUnsupportedMediaType:
+		errCode = ErrCodeInvalidRequest
+	case fiber.StatusRequestTimeout:
+		errCode = ErrCodeTimeout
+	case fiber.StatusTooManyRequests:
+		errCode = ErrCodeRateLimited
+	case fiber.StatusRequestEntityTooLarge:
+		errCode = ErrCodeValueTooLarge
+	case fiber.StatusUnauthorized:
+		errCode = ErrCodeUnauthorized
+	case fiber.StatusForbidden:
+		errCode = ErrCodeForbidden
+	}
 
-			// Return JSON error response
-			return c.Status(code).JSON(NewErrorResponse(message, errCode))
-		}
-		return nil
+	// Log the error
+	if code >= fiber.StatusInternalServerError {
+		log.Printf("Error: %v, Path: %s, Method: %s", err, c.Path(), c.Method())
 	}
+
+	// Return JSON error response
+	return c.Status(code).JSON(NewErrorResponse(message, errCode))
 }
 
 // timingMiddleware adds request timing headers
@@ -102,19 +122,9 @@ func timingMiddleware() fiber.Handler {
 func ValidateAPIKey(apiKey string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		if apiKey != "" {
-			// Get API key from header
-			key := c.Get("X-API-Key")
-			if key == "" {
-				// Try Authorization header
-				auth := c.Get("Authorization")
-				if auth != "" && len(auth) > 7 && auth[:7] == "Bearer " {
-					key = auth[7:]
-				}
-			}
-
-			if key != apiKey {
+			if middleware.ExtractAPIKey(c) != apiKey {
 				return c.Status(fiber.StatusUnauthorized).JSON(
-					NewErrorResponse("Invalid or missing API key", "UNAUTHORIZED"),
+					NewErrorResponse("Invalid or missing API key", ErrCodeUnauthorized),
 				)
 			}
 		}
@@ -122,52 +132,28 @@ func ValidateAPIKey(apiKey string) fiber.Handler {
 	}
 }
 
-// RateLimiter creates a simple in-memory rate limiter
-func RateLimiter(requestsPerMinute int) fiber.Handler {
-	type client struct {
-		count     int
-		lastReset time.Time
+// ActorMiddleware records the caller as the actor for instance status changes
+func ActorMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.SetUserContext(instance.WithActor(c.UserContext(), "api:"+c.IP()))
+		return c.Next()
 	}
+}
 
-	clients := make(map[string]*client)
-
+// QuotaMiddleware counts requests in flight per instance and rejects those
+// over the instance's concurrency limit. A nil enforcer disables it.
+func QuotaMiddleware(enforcer *quota.Enforcer) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		// Get client IP
-		ip := c.IP()
-
-		now := time.Now()
-
-		// Get or create client entry
-		cl, exists := clients[ip]
-		if !exists {
-			cl = &client{
-				count:     0,
-				lastReset: now,
-			}
-			clients[ip] = cl
-		}
-
-		// Reset counter if a minute has passed
-		if now.Sub(cl.lastReset) > time.Minute {
-			cl.count = 0
-			cl.lastReset = now
+		instCtx, ok := instance.ExtractContext(c.UserContext())
+		if enforcer == nil || !ok {
+			return c.Next()
 		}
 
-		// Check rate limit
-		if cl.count >= requestsPerMinute {
-			return c.Status(fiber.StatusTooManyRequests).JSON(
-				NewErrorResponse("Rate limit exceeded", ErrCodeRateLimited),
-			)
+		release, err := enforcer.Acquire(instCtx)
+		if err != nil {
+			return quotaRejected(c, instCtx.InstanceID, err)
 		}
-
-		// Increment counter
-		cl.count++
-
-		// Add rate limit headers
-		c.Set("X-RateLimit-Limit", fmt.Sprintf("%d", requestsPerMinute))
-		c.Set("X-RateLimit-Remaining", fmt.Sprintf("%d", requestsPerMinute-cl.count))
-		c.Set("X-RateLimit-Reset", fmt.Sprintf("%d", cl.lastReset.Add(time.Minute).Unix()))
-
+		defer release()
 		return c.Next()
 	}
 }
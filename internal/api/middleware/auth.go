@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/birbparty/birb-nest/internal/api/apierror"
+	"github.com/birbparty/birb-nest/internal/auth"
+	"github.com/gofiber/fiber/v2"
+)
+
+// ExtractAPIKey returns the API key from the X-API-Key header or a bearer token
+func ExtractAPIKey(c *fiber.Ctx) string {
+	if key := c.Get("X-API-Key"); key != "" {
+		return key
+	}
+	if header := c.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		return header[len("Bearer "):]
+	}
+	return ""
+}
+
+// RequireAPIKey creates middleware authenticating admin requests. An empty
+// scope requires read for GET and HEAD requests and admin otherwise. When
+// instanceParam is set, the key must also allow the instance named by that
+// route parameter; such middleware must be attached to the route, as group
+// middleware runs before route parameters are known, and rejects requests
+// without the parameter. Signed tokens are rejected; they only grant cache
+// access. A nil keyring disables authentication.
+func RequireAPIKey(keyring *auth.Keyring, scope auth.Scope, instanceParam string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if keyring == nil {
+			return c.Next()
+		}
+
+		required := scope
+		if required == "" {
+			required = methodScope(c, auth.ScopeAdmin)
+		}
+		instanceID := ""
+		if instanceParam != "" {
+			// Fail closed when the parameter is missing, e.g. on group middleware
+			if instanceID = c.Params(instanceParam); instanceID == "" {
+				return c.Status(fiber.StatusBadRequest).JSON(apierror.New("instance ID is required", apierror.CodeMissingInstanceID))
+			}
+		}
+		if ok, err := authenticate(c, keyring, required, instanceID); !ok {
+			return err
+		}
+		if key, _ := auth.KeyFromContext(c.UserContext()); key.IsToken() {
+			return c.Status(fiber.StatusForbidden).JSON(apierror.New("signed tokens may only access cache endpoints", apierror.CodeInsufficientScope))
+		}
+		return c.Next()
+	}
+}
+
+// RequireAllInstances creates middleware rejecting API keys that are limited
+// to some instances. It must run after the key was authenticated.
+func RequireAllInstances() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if key, ok := auth.KeyFromContext(c.UserContext()); ok && !key.AllowsAllInstances() {
+			return c.Status(fiber.StatusForbidden).JSON(apierror.New("API key is limited to specific instances", apierror.CodeInstanceForbidden))
+		}
+		return c.Next()
+	}
+}
+
+// methodScope returns the scope a request needs: read for GET and HEAD
+// requests, otherwise the given scope
+func methodScope(c *fiber.Ctx, write auth.Scope) auth.Scope {
+	if c.Method() == fiber.MethodGet || c.Method() == fiber.MethodHead {
+		return auth.ScopeRead
+	}
+	return write
+}
+
+// authenticate checks the request's API key for a scope and, if instanceID is
+// set, access to that instance. Replicas identified by their TLS certificate
+// need no key. On success the key is stored in the request context; otherwise
+// the error response has been written and ok is false.
+func authenticate(c *fiber.Ctx, keyring *auth.Keyring, scope auth.Scope, instanceID string) (ok bool, err error) {
+	var key *auth.Key
+	if peer := PeerName(c); peer != "" {
+		key = auth.PeerKey(peer)
+	} else {
+		key, err = keyring.Authenticate(c.UserContext(), ExtractAPIKey(c))
+	}
+	if err != nil {
+		if errors.Is(err, auth.ErrMissingKey) || errors.Is(err, auth.ErrInvalidKey) {
+			return false, c.Status(fiber.StatusUnauthorized).JSON(apierror.New("invalid or missing API key", apierror.CodeUnauthorized))
+		}
+		log.Printf("Failed to authenticate API key: %v", err)
+		return false, c.Status(fiber.StatusServiceUnavailable).JSON(apierror.New("failed to authenticate API key", apierror.CodeAuthUnavailable))
+	}
+
+	if !key.HasScope(scope) {
+		return false, c.Status(fiber.StatusForbidden).JSON(apierror.New("API key lacks the "+string(scope)+" scope", apierror.CodeInsufficientScope))
+	}
+	if instanceID != "" && !key.AllowsInstance(instanceID) {
+		return false, c.Status(fiber.StatusForbidden).JSON(apierror.ForInstance("API key may not access this instance", apierror.CodeInstanceForbidden, instanceID))
+	}
+
+	c.SetUserContext(auth.WithKey(c.UserContext(), key))
+	return true, nil
+}
@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/birbparty/birb-nest/internal/api/apierror"
+	"github.com/birbparty/birb-nest/internal/auth"
+	"github.com/birbparty/birb-nest/internal/cache"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestRequireAPIKey(t *testing.T) {
+	mc, err := cache.NewMemoryCache(&cache.MemoryConfig{})
+	if err != nil {
+		t.Fatalf("NewMemoryCache() error = %v", err)
+	}
+	defer mc.Close()
+	keyring := auth.NewKeyring(mc, nil)
+	keyring.SetRootKey("root-secret")
+
+	// Keys are created on a primary sharing the cache
+	primary := auth.NewKeyring(mc, &fakeStore{})
+	_, reader, err := primary.Create(context.Background(), auth.CreateOptions{Scopes: []auth.Scope{auth.ScopeRead}, Instances: []string{"game-*"}})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	signer, err := auth.NewTokenSigner([]byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatalf("NewTokenSigner() error = %v", err)
+	}
+	keyring.SetTokenSigner(signer)
+	token, _ := signer.Sign(&auth.TokenClaims{Instance: "game-1", Operations: []auth.Scope{auth.ScopeRead},
+		ExpiresAt: time.Now().Add(time.Minute).Unix()})
+
+	app := fiber.New()
+	app.All("/instances/:id", RequireAPIKey(keyring, "", "id"), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	tests := []struct {
+		name, method, path, header, value string
+		want                              int
+		wantCode                          string
+	}{
+		{"missing key", "GET", "/instances/game-1", "", "", fiber.StatusUnauthorized, apierror.CodeUnauthorized},
+		{"unknown key", "GET", "/instances/game-1", "X-API-Key", "bnk_nope", fiber.StatusUnauthorized, apierror.CodeUnauthorized},
+		{"read", "GET", "/instances/game-1", "X-API-Key", reader, fiber.StatusOK, ""},
+		{"bearer token", "GET", "/instances/game-1", "Authorization", "Bearer " + reader, fiber.StatusOK, ""},
+		{"admin scope required", "POST", "/instances/game-1", "X-API-Key", reader, fiber.StatusForbidden, apierror.CodeInsufficientScope},
+		{"other instance", "GET", "/instances/lobby", "X-API-Key", reader, fiber.StatusForbidden, apierror.CodeInstanceForbidden},
+		{"root key", "POST", "/instances/lobby", "X-API-Key", "root-secret", fiber.StatusOK, ""},
+		{"signed token", "GET", "/instances/game-1", "Authorization", "Bearer " + token, fiber.StatusForbidden, apierror.CodeInsufficientScope},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("app.Test() error = %v", err)
+			}
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+			if tt.wantCode == "" {
+				return
+			}
+			var body apierror.Response
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || body.Code != tt.wantCode || body.Error == "" {
+				t.Errorf("body = %+v (%v), want an error with code %s", body, err, tt.wantCode)
+			}
+			if tt.wantCode == apierror.CodeInstanceForbidden && body.InstanceID != "lobby" {
+				t.Errorf("instance_id = %q, want lobby", body.InstanceID)
+			}
+		})
+	}
+}
+
+func TestRequireAPIKey_GroupFailsClosed(t *testing.T) {
+	mc, err := cache.NewMemoryCache(&cache.MemoryConfig{})
+	if err != nil {
+		t.Fatalf("NewMemoryCache() error = %v", err)
+	}
+	defer mc.Close()
+	keyring := auth.NewKeyring(mc, nil)
+	keyring.SetRootKey("root-secret")
+
+	// Group middleware cannot see :id, so it must not skip the instance check
+	app := fiber.New()
+	app.Group("/instances", RequireAPIKey(keyring, "", "id")).Get("/:id", func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	req := httptest.NewRequest("GET", "/instances/lobby", nil)
+	req.Header.Set("X-API-Key", "root-secret")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("app.Test() error = %v", err)
+	}
+	if resp.StatusCode != fiber.StatusBadRequest {
+		t.Errorf("status = %d, want %d", resp.StatusCode, fiber.StatusBadRequest)
+	}
+}
+
+// fakeStore is a Store that only accepts saves
+type fakeStore struct{ auth.Store }
+
+func (s *fakeStore) SaveKey(ctx context.Context, key *auth.Key) error { return nil }
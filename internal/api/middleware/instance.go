@@ -1,18 +1,34 @@
 package middleware
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"strings"
 
+	"github.com/birbparty/birb-nest/internal/api/apierror"
+	"github.com/birbparty/birb-nest/internal/auth"
 	"github.com/birbparty/birb-nest/internal/instance"
 	"github.com/gofiber/fiber/v2"
 )
 
+// Rehydrator wakes hibernated instances in the background
+type Rehydrator interface {
+	Rehydrate(instanceID string)
+}
+
 // InstanceMiddleware extracts and validates instance context from requests
 type InstanceMiddleware struct {
 	registry          *instance.Registry
-	required          bool   // whether instance context is required
-	defaultInstanceID string // default instance ID for requests without context
+	required          bool       // whether instance context is required
+	defaultInstanceID string     // default instance ID for requests without context
+	rehydrator        Rehydrator // nil rejects requests for inactive instances
+
+	strict   bool               // reject unknown instance IDs instead of creating them
+	idPolicy *instance.IDPolicy // IDs that may be created on first use
+
+	keyring *auth.Keyring // nil disables authentication
+	scope   auth.Scope    // scope requests need; empty means by method
 }
 
 // NewInstanceMiddleware creates a new instance middleware
@@ -29,11 +45,37 @@ func (m *InstanceMiddleware) SetDefaultInstanceID(id string) {
 	m.defaultInstanceID = id
 }
 
+// SetRehydrator lets requests for inactive (hibernated) instances through,
+// waking the instance in the background
+func (m *InstanceMiddleware) SetRehydrator(r Rehydrator) {
+	m.rehydrator = r
+}
+
+// SetProvisioning controls instance creation on first use. In strict mode
+// unknown instance IDs are rejected with INSTANCE_NOT_FOUND and instances are
+// only created through the admin API; otherwise new IDs must pass the policy.
+func (m *InstanceMiddleware) SetProvisioning(strict bool, policy *instance.IDPolicy) {
+	m.strict = strict
+	m.idPolicy = policy
+}
+
+// SetAuthenticator requires requests to carry an API key with a scope and
+// access to the requested instance. An empty scope requires read for GET and
+// HEAD requests and write otherwise.
+func (m *InstanceMiddleware) SetAuthenticator(keyring *auth.Keyring, scope auth.Scope) {
+	m.keyring = keyring
+	m.scope = scope
+}
+
 // Handle is the Fiber middleware function
 func (m *InstanceMiddleware) Handle() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		// Extract instance ID from header or query parameter
+		// Extract instance ID from header or query parameter; signed tokens
+		// name their instance themselves
 		instanceID := m.extractInstanceID(c)
+		if instanceID == "" && m.keyring != nil {
+			instanceID = m.keyring.TokenInstance(ExtractAPIKey(c))
+		}
 
 		// Use default instance ID if none provided
 		var usingDefault bool
@@ -44,27 +86,38 @@ func (m *InstanceMiddleware) Handle() fiber.Handler {
 
 		// If no instance ID but required, return error
 		if instanceID == "" && m.required {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": "instance ID is required",
-				"code":  "MISSING_INSTANCE_ID",
-			})
+			return c.Status(fiber.StatusBadRequest).JSON(apierror.New("instance ID is required", apierror.CodeMissingInstanceID))
+		}
+
+		// Check the API key before the instance is looked up or created
+		if m.keyring != nil {
+			scope := m.scope
+			if scope == "" {
+				scope = methodScope(c, auth.ScopeWrite)
+			}
+			if ok, err := authenticate(c, m.keyring, scope, instanceID); !ok {
+				return err
+			}
 		}
 
-		// Load instance context from registry (creates if doesn't exist)
-		instCtx, err := m.registry.GetOrCreate(c.UserContext(), instanceID)
+		// Load instance context from registry (creates it unless provisioning is strict)
+		instCtx, err := m.loadInstance(c.UserContext(), instanceID)
 		if err != nil {
 			// Check if it's a not found error
 			if err == instance.ErrInstanceNotFound {
-				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-					"error": "instance not found",
-					"code":  "INSTANCE_NOT_FOUND",
-				})
+				return c.Status(fiber.StatusNotFound).JSON(apierror.New("instance not found", apierror.CodeInstanceNotFound))
+			}
+			if err == instance.ErrReservedInstanceID {
+				return c.Status(fiber.StatusBadRequest).JSON(apierror.New("instance ID is reserved", apierror.CodeReservedInstanceID))
+			}
+			if errors.Is(err, instance.ErrInvalidInstanceID) {
+				return c.Status(fiber.StatusBadRequest).JSON(apierror.New(err.Error(), apierror.CodeInvalidInstanceID))
+			}
+			if errors.Is(err, instance.ErrInstanceIDNotAllowed) {
+				return c.Status(fiber.StatusForbidden).JSON(apierror.New(err.Error(), apierror.CodeInstanceIDNotAllowed))
 			}
 			// Other errors
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "failed to load instance context",
-				"code":  "INSTANCE_LOAD_ERROR",
-			})
+			return c.Status(fiber.StatusInternalServerError).JSON(apierror.New("failed to load instance context", apierror.CodeInstanceLoadError))
 		}
 
 		// Mark global instance as permanent if it was just created
@@ -77,32 +130,32 @@ func (m *InstanceMiddleware) Handle() fiber.Handler {
 			c.Locals("default_instance_created", true)
 		}
 
-		// Validate instance can accept requests
-		if !instCtx.CanAcceptRequests() {
+		// Wake hibernated instances; reads fall back to the database until loaded
+		if instCtx.Status == instance.StatusInactive && m.rehydrator != nil {
+			m.rehydrator.Rehydrate(instanceID)
+			c.Set("X-Instance-Rehydrating", "true")
+		} else if !instCtx.CanAcceptRequests() {
 			statusCode := fiber.StatusServiceUnavailable
 			errorMessage := "instance is not accepting requests"
-			errorCode := "INSTANCE_UNAVAILABLE"
+			errorCode := apierror.CodeInstanceUnavailable
 
 			// Specific handling for different statuses
 			switch instCtx.Status {
 			case instance.StatusDeleting:
 				statusCode = fiber.StatusGone
 				errorMessage = "instance is being deleted"
-				errorCode = "INSTANCE_DELETING"
+				errorCode = apierror.CodeInstanceDeleting
 			case instance.StatusInactive:
 				errorMessage = "instance is inactive"
-				errorCode = "INSTANCE_INACTIVE"
+				errorCode = apierror.CodeInstanceInactive
 			case instance.StatusPaused:
 				errorMessage = "instance is paused"
-				errorCode = "INSTANCE_PAUSED"
+				errorCode = apierror.CodeInstancePaused
 			}
 
-			return c.Status(statusCode).JSON(fiber.Map{
-				"error":       errorMessage,
-				"code":        errorCode,
-				"instance_id": instanceID,
-				"status":      string(instCtx.Status),
-			})
+			resp := apierror.ForInstance(errorMessage, errorCode, instanceID)
+			resp.Status = string(instCtx.Status)
+			return c.Status(statusCode).JSON(resp)
 		}
 
 		// Inject context into request context
@@ -122,6 +175,29 @@ func (m *InstanceMiddleware) Handle() fiber.Handler {
 	}
 }
 
+// loadInstance returns an instance's context, creating unknown instances
+// unless provisioning is strict. The default instance is always created on
+// demand.
+func (m *InstanceMiddleware) loadInstance(ctx context.Context, instanceID string) (*instance.Context, error) {
+	if instance.IsReservedID(instanceID) {
+		return nil, instance.ErrReservedInstanceID
+	}
+
+	instCtx, err := m.registry.Get(ctx, instanceID)
+	if err != instance.ErrInstanceNotFound {
+		return instCtx, err
+	}
+	if instanceID != m.defaultInstanceID {
+		if m.strict {
+			return nil, instance.ErrInstanceNotFound
+		}
+		if err := m.idPolicy.Check(instanceID); err != nil {
+			return nil, err
+		}
+	}
+	return m.registry.GetOrCreate(ctx, instanceID)
+}
+
 // extractInstanceID extracts the instance ID from the request
 func (m *InstanceMiddleware) extractInstanceID(c *fiber.Ctx) string {
 	// 1. Check header first (preferred)
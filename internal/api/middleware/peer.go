@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"github.com/birbparty/birb-nest/internal/mtls"
+	"github.com/gofiber/fiber/v2"
+)
+
+// peerLocal is the fiber.Ctx local holding the name of an authenticated replica
+const peerLocal = "replica_peer"
+
+// ForwardingHeaders may only be set by replicas forwarding requests
+var ForwardingHeaders = []string{"X-Write-Timestamp"}
+
+// PeerMiddleware identifies replicas by their verified TLS client certificate.
+// allowed lists the accepted certificate names; empty accepts any certificate
+// the CA bundle verifies. Forwarding headers are removed from requests of
+// other clients.
+func PeerMiddleware(allowed []string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if name, ok := mtls.PeerName(c.Context().TLSConnectionState(), allowed); ok {
+			c.Locals(peerLocal, name)
+			return c.Next()
+		}
+		for _, header := range ForwardingHeaders {
+			c.Request().Header.Del(header)
+		}
+		return c.Next()
+	}
+}
+
+// PeerName returns the name of the replica that sent a request, or "" for
+// requests not from an authenticated replica
+func PeerName(c *fiber.Ctx) string {
+	name, _ := c.Locals(peerLocal).(string)
+	return name
+}
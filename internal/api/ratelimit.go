@@ -0,0 +1,171 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/birbparty/birb-nest/internal/api/middleware"
+	"github.com/birbparty/birb-nest/internal/cache"
+	"github.com/birbparty/birb-nest/internal/instance"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Rate limit classes; reads and writes are limited separately
+const (
+	RateLimitReads  = "reads"
+	RateLimitWrites = "writes"
+)
+
+// Instance metadata overriding the configured per-instance limits, in
+// requests per rate limit period ("0" is unlimited)
+const (
+	MetaRateLimitReads  = "rate_limit_reads"
+	MetaRateLimitWrites = "rate_limit_writes"
+)
+
+// RateLimiter limits requests per instance and per API key with token
+// buckets kept in the cache, so every node sharing a Redis shares the limits
+type RateLimiter struct {
+	buckets cache.TokenBucket
+	config  RateLimitConfig
+}
+
+// rateLimitResult is the state of one bucket after a request
+type rateLimitResult struct {
+	scope   string // "instance" or "api_key"
+	limit   int64
+	allowed bool
+	tokens  float64
+}
+
+// NewRateLimiter creates a rate limiter. It returns nil, which allows every
+// request, when no limit is configured or the cache cannot hold token buckets.
+func NewRateLimiter(cacheClient cache.Cache, config RateLimitConfig) *RateLimiter {
+	buckets, ok := cacheClient.(cache.TokenBucket)
+	if !ok {
+		log.Printf("Warning: Cache backend cannot hold rate limit buckets; rate limiting disabled")
+		return nil
+	}
+	return &RateLimiter{buckets: buckets, config: config}
+}
+
+// Handle returns middleware limiting requests of a class. An empty class
+// treats GET and HEAD requests as reads and everything else as writes.
+// Requests are allowed if the cache fails, so an outage never blocks traffic.
+func (l *RateLimiter) Handle(class string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if l == nil {
+			return c.Next()
+		}
+
+		requestClass := class
+		if requestClass == "" {
+			requestClass = RateLimitWrites
+			if c.Method() == fiber.MethodGet || c.Method() == fiber.MethodHead {
+				requestClass = RateLimitReads
+			}
+		}
+
+		var results []rateLimitResult
+		instanceID := ""
+		if instCtx, ok := instance.ExtractContext(c.UserContext()); ok {
+			instanceID = instCtx.InstanceID
+			if limit := l.instanceLimit(instCtx, requestClass); limit > 0 {
+				key := instance.NewKeyBuilder(instanceID).BuildKey("ratelimit", requestClass)
+				if result, ok := l.take(c, key, limit); ok {
+					result.scope = "instance"
+					results = append(results, result)
+				}
+			}
+		}
+		if apiKey := middleware.ExtractAPIKey(c); apiKey != "" {
+			if limit := l.keyLimit(requestClass); limit > 0 {
+				sum := sha256.Sum256([]byte(apiKey))
+				key := "ratelimit:apikey:" + hex.EncodeToString(sum[:16]) + ":" + requestClass
+				if result, ok := l.take(c, key, limit); ok {
+					result.scope = "api_key"
+					results = append(results, result)
+				}
+			}
+		}
+		if len(results) == 0 {
+			return c.Next()
+		}
+
+		// Report the bucket that denied the request, or the one closest to empty
+		reported := results[0]
+		for _, result := range results[1:] {
+			if reported.allowed && (!result.allowed || result.tokens < reported.tokens) {
+				reported = result
+			}
+		}
+		l.setHeaders(c, reported)
+
+		if !reported.allowed {
+			RecordRateLimited(instanceID, reported.scope, requestClass)
+			retryAfter := l.seconds(1-reported.tokens, reported.limit)
+			c.Set(fiber.HeaderRetryAfter, strconv.FormatInt(retryAfter, 10))
+			return c.Status(fiber.StatusTooManyRequests).JSON(
+				NewErrorResponseWithDetails("Rate limit exceeded", ErrCodeRateLimited,
+					fmt.Sprintf("%s %s: %d per %s", reported.scope, requestClass, reported.limit, l.config.Period)))
+		}
+		return c.Next()
+	}
+}
+
+// instanceLimit returns an instance's limit for a class, preferring its metadata
+func (l *RateLimiter) instanceLimit(instCtx *instance.Context, class string) int64 {
+	meta, limit := MetaRateLimitReads, l.config.InstanceReads
+	if class == RateLimitWrites {
+		meta, limit = MetaRateLimitWrites, l.config.InstanceWrites
+	}
+	if value, ok := instCtx.Metadata[meta]; ok {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err == nil && parsed >= 0 {
+			return parsed
+		}
+		log.Printf("Warning: Ignoring invalid %s %q on instance %s", meta, value, instCtx.InstanceID)
+	}
+	return limit
+}
+
+// keyLimit returns the per-API-key limit for a class
+func (l *RateLimiter) keyLimit(class string) int64 {
+	if class == RateLimitWrites {
+		return l.config.KeyWrites
+	}
+	return l.config.KeyReads
+}
+
+// take takes a token from a bucket; it returns false if the cache failed
+func (l *RateLimiter) take(c *fiber.Ctx, key string, limit int64) (rateLimitResult, bool) {
+	allowed, tokens, err := l.buckets.TakeToken(c.UserContext(), key, limit, l.config.Period)
+	if err != nil {
+		log.Printf("Warning: Rate limiting skipped for %s: %v", key, err)
+		return rateLimitResult{}, false
+	}
+	return rateLimitResult{limit: limit, allowed: allowed, tokens: tokens}, true
+}
+
+// setHeaders adds the RateLimit-Limit, RateLimit-Remaining and
+// RateLimit-Reset headers; Reset is the seconds until the bucket is full
+func (l *RateLimiter) setHeaders(c *fiber.Ctx, result rateLimitResult) {
+	c.Set("RateLimit-Limit", strconv.FormatInt(result.limit, 10))
+	c.Set("RateLimit-Remaining", strconv.FormatInt(int64(math.Floor(result.tokens)), 10))
+	c.Set("RateLimit-Reset", strconv.FormatInt(l.seconds(float64(result.limit)-result.tokens, result.limit), 10))
+}
+
+// seconds returns how long a bucket with the given limit takes to refill
+// the given number of tokens, rounded up to whole seconds
+func (l *RateLimiter) seconds(tokens float64, limit int64) int64 {
+	if tokens <= 0 {
+		return 0
+	}
+	refill := time.Duration(tokens * float64(l.config.Period) / float64(limit))
+	return int64(math.Ceil(refill.Seconds()))
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/birbparty/birb-nest/internal/cache"
+	"github.com/birbparty/birb-nest/internal/instance"
+	"github.com/gofiber/fiber/v2"
+)
+
+func newRateLimitedApp(t *testing.T, config RateLimitConfig, metadata map[string]string) *fiber.App {
+	t.Helper()
+	mc, err := cache.NewMemoryCache(&cache.MemoryConfig{})
+	if err != nil {
+		t.Fatalf("NewMemoryCache() error = %v", err)
+	}
+	t.Cleanup(func() { mc.Close() })
+
+	limiter := NewRateLimiter(mc, config)
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		instCtx := instance.NewContext(c.Get("X-Instance-ID"))
+		for k, v := range metadata {
+			instCtx.Metadata[k] = v
+		}
+		c.SetUserContext(instance.InjectContext(c.UserContext(), instCtx))
+		return c.Next()
+	})
+	app.Use(limiter.Handle(""))
+	app.All("/:key", func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) })
+	return app
+}
+
+func doRequest(t *testing.T, app *fiber.App, method, instanceID, apiKey string) (int, map[string]string) {
+	t.Helper()
+	req := httptest.NewRequest(method, "/k", nil)
+	req.Header.Set("X-Instance-ID", instanceID)
+	if apiKey != "" {
+		req.Header.Set("X-API-Key", apiKey)
+	}
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("app.Test() error = %v", err)
+	}
+	headers := map[string]string{}
+	for _, name := range []string{"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After"} {
+		headers[name] = resp.Header.Get(name)
+	}
+	return resp.StatusCode, headers
+}
+
+func TestRateLimiter_SeparateReadAndWriteLimits(t *testing.T) {
+	app := newRateLimitedApp(t, RateLimitConfig{Period: time.Hour, InstanceReads: 3, InstanceWrites: 1}, nil)
+
+	status, headers := doRequest(t, app, "PUT", "game-1", "")
+	if status != fiber.StatusOK || headers["RateLimit-Limit"] != "1" || headers["RateLimit-Remaining"] != "0" {
+		t.Fatalf("first write = %d %v", status, headers)
+	}
+	status, headers = doRequest(t, app, "PUT", "game-1", "")
+	if status != fiber.StatusTooManyRequests || headers["Retry-After"] == "" {
+		t.Errorf("second write = %d %v, want 429 with Retry-After", status, headers)
+	}
+
+	// Reads have their own bucket, and other instances are unaffected
+	if status, headers = doRequest(t, app, "GET", "game-1", ""); status != fiber.StatusOK || headers["RateLimit-Remaining"] != "2" {
+		t.Errorf("read = %d %v, want 200 with 2 remaining", status, headers)
+	}
+	if status, _ = doRequest(t, app, "PUT", "game-2", ""); status != fiber.StatusOK {
+		t.Errorf("write to another instance = %d, want 200", status)
+	}
+}
+
+func TestRateLimiter_APIKeyAndMetadataLimits(t *testing.T) {
+	app := newRateLimitedApp(t, RateLimitConfig{Period: time.Hour, InstanceReads: 1, KeyReads: 2},
+		map[string]string{MetaRateLimitReads: "0"})
+
+	// Metadata lifts the instance limit; the API key limit still applies across instances
+	for i, instanceID := range []string{"game-1", "game-2"} {
+		if status, _ := doRequest(t, app, "GET", instanceID, "secret"); status != fiber.StatusOK {
+			t.Fatalf("read %d = %d, want 200", i, status)
+		}
+	}
+	if status, _ := doRequest(t, app, "GET", "game-3", "secret"); status != fiber.StatusTooManyRequests {
+		t.Errorf("read over API key limit = %d, want 429", status)
+	}
+	if status, headers := doRequest(t, app, "GET", "game-3", ""); status != fiber.StatusOK || headers["RateLimit-Limit"] != "" {
+		t.Errorf("read without API key = %d %v, want 200 without headers", status, headers)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"net/url"
 
 	"github.com/birbparty/birb-nest/internal/api/middleware"
+	"github.com/birbparty/birb-nest/internal/auth"
 	"github.com/birbparty/birb-nest/internal/instance"
 	"github.com/gofiber/fiber/v2"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -15,13 +16,31 @@ func SetupInstanceRoutes(app *fiber.App, handlers *Handlers, cfg *Config, regist
 	// Apply Prometheus metrics middleware globally
 	app.Use(PrometheusMetricsMiddleware(cfg.InstanceID, cfg.Mode))
 
+	// Identify replicas by their client certificate; only they may set
+	// forwarding headers, which are dropped from every other request even
+	// when TLS is disabled
+	app.Use(middleware.PeerMiddleware(cfg.TLS.PeerNames))
+
 	// API v1 group
-	v1 := app.Group("/v1")
+	v1 := app.Group("/v1", ActorMiddleware())
+
+	// Batch operations with optional instance middleware. Registered before the
+	// /cache group so the group's middleware does not run a second time.
+	optMiddleware := middleware.NewInstanceMiddleware(registry, false)
+	optMiddleware.SetDefaultInstanceID(cfg.DefaultInstanceID)
+	optMiddleware.SetRehydrator(handlers)
+	optMiddleware.SetProvisioning(cfg.Provisioning.Strict, cfg.Provisioning.IDPolicy)
+	optMiddleware.SetAuthenticator(handlers.keyring, auth.ScopeRead)
+	v1.Post("/cache/batch/get", optMiddleware.Handle(), handlers.limits.Handle(RateLimitReads),
+		QuotaMiddleware(handlers.quotas), handlers.BatchGet)
 
 	// Cache endpoints with required instance middleware
 	reqMiddleware := middleware.NewInstanceMiddleware(registry, true)
 	reqMiddleware.SetDefaultInstanceID(cfg.DefaultInstanceID)
-	cache := v1.Group("/cache", reqMiddleware.Handle())
+	reqMiddleware.SetRehydrator(handlers)
+	reqMiddleware.SetProvisioning(cfg.Provisioning.Strict, cfg.Provisioning.IDPolicy)
+	reqMiddleware.SetAuthenticator(handlers.keyring, "")
+	cache := v1.Group("/cache", reqMiddleware.Handle(), handlers.limits.Handle(""), QuotaMiddleware(handlers.quotas))
 
 	// Single key operations
 	cache.Get("/:key", handlers.Get)
@@ -29,10 +48,56 @@ func SetupInstanceRoutes(app *fiber.App, handlers *Handlers, cfg *Config, regist
 	cache.Put("/:key", handlers.Set)
 	cache.Delete("/:key", handlers.Delete)
 
-	// Batch operations with optional instance middleware
-	optMiddleware := middleware.NewInstanceMiddleware(registry, false)
-	optMiddleware.SetDefaultInstanceID(cfg.DefaultInstanceID)
-	v1.Post("/cache/batch/get", optMiddleware.Handle(), handlers.BatchGet)
+	// Instance administration endpoints; GET requests need the read scope,
+	// others the admin scope, and the key must allow the instance. Group
+	// middleware runs before route parameters are known, so the instance
+	// check is attached to each route.
+	instances := v1.Group("/instances")
+	instances.Post("/", middleware.RequireAPIKey(handlers.keyring, "", ""), handlers.CreateInstance)
+	byID := middleware.RequireAPIKey(handlers.keyring, "", "id")
+	instances.Get("/:id", byID, handlers.GetInstance)
+	instances.Post("/:id/archive", byID, handlers.ArchiveInstance)
+	instances.Get("/:id/archives", byID, handlers.ListArchives)
+	instances.Post("/:id/restore", byID, handlers.RestoreInstance)
+	instances.Get("/:id/backups", byID, handlers.GetBackupCatalog)
+	instances.Post("/:id/backups/compact", byID, handlers.CompactBackups)
+	instances.Post("/:id/clone", byID, handlers.CloneInstance)
+	instances.Get("/:id/tombstone", byID, handlers.GetInstanceTombstone)
+	instances.Post("/:id/hibernate", byID, handlers.HibernateInstance)
+	instances.Get("/:id/history", byID, handlers.GetInstanceHistory)
+	instances.Get("/:id/usage", byID, handlers.GetInstanceUsage)
+	instances.Get("/:id/encryption", byID, handlers.GetInstanceEncryption)
+	instances.Put("/:id/encryption", byID, handlers.SetInstanceEncryption)
+	instances.Post("/:id/encryption/rotate", byID, handlers.RotateInstanceKey)
+
+	// Template endpoints
+	templates := v1.Group("/templates", middleware.RequireAPIKey(handlers.keyring, "", ""))
+	templates.Post("/", handlers.CreateTemplate)
+	templates.Get("/", handlers.ListTemplates)
+	templates.Get("/:name", handlers.GetTemplate)
+	templates.Delete("/:name", handlers.DeleteTemplate)
+	templates.Post("/:name/instances", handlers.CreateFromTemplate)
+
+	// Reaper endpoints act on every instance
+	reaper := v1.Group("/reaper", middleware.RequireAPIKey(handlers.keyring, "", ""), middleware.RequireAllInstances())
+	reaper.Post("/run", handlers.RunReaper)
+	reaper.Get("/audit", handlers.ListReaperAudit)
+
+	// Signed client tokens; the caller's key must cover what the token grants
+	v1.Post("/tokens", middleware.RequireAPIKey(handlers.keyring, auth.ScopeRead, ""), handlers.CreateToken)
+
+	// Wrapped data keys for replicas, which seal and open values themselves
+	dataKeys := v1.Group("/encryption/keys", middleware.RequireAPIKey(handlers.keyring, auth.ScopeWrite, ""))
+	dataKeys.Post("/", handlers.GetActiveDataKey)
+	dataKeys.Get("/:id", handlers.GetDataKey)
+
+	// API key management; keys limited to some instances cannot manage keys
+	keys := v1.Group("/keys", middleware.RequireAPIKey(handlers.keyring, auth.ScopeAdmin, ""), middleware.RequireAllInstances())
+	keys.Post("/", handlers.CreateKey)
+	keys.Get("/", handlers.ListKeys)
+	keys.Get("/:id", handlers.GetKey)
+	keys.Post("/:id/rotate", handlers.RotateKey)
+	keys.Delete("/:id", handlers.RevokeKey)
 
 	// Health endpoint (no auth required)
 	app.Get("/health", handlers.Health)
@@ -61,6 +126,46 @@ func SetupInstanceRoutes(app *fiber.App, handlers *Handlers, cfg *Config, regist
 					"delete": "DELETE /v1/cache/:key",
 					"batch":  "POST /v1/cache/batch/get",
 				},
+				"instances": fiber.Map{
+					"create":    "POST /v1/instances",
+					"get":       "GET /v1/instances/:id",
+					"archive":   "POST /v1/instances/:id/archive",
+					"archives":  "GET /v1/instances/:id/archives",
+					"restore":   "POST /v1/instances/:id/restore",
+					"backups":   "GET /v1/instances/:id/backups",
+					"compact":   "POST /v1/instances/:id/backups/compact",
+					"clone":     "POST /v1/instances/:id/clone",
+					"tombstone": "GET /v1/instances/:id/tombstone",
+					"hibernate": "POST /v1/instances/:id/hibernate",
+					"history":   "GET /v1/instances/:id/history",
+					"usage":     "GET /v1/instances/:id/usage",
+					"encryption": fiber.Map{
+						"get":    "GET /v1/instances/:id/encryption",
+						"set":    "PUT /v1/instances/:id/encryption",
+						"rotate": "POST /v1/instances/:id/encryption/rotate",
+					},
+				},
+				"templates": fiber.Map{
+					"create":       "POST /v1/templates",
+					"list":         "GET /v1/templates",
+					"get":          "GET /v1/templates/:name",
+					"delete":       "DELETE /v1/templates/:name",
+					"new_instance": "POST /v1/templates/:name/instances",
+				},
+				"reaper": fiber.Map{
+					"run":   "POST /v1/reaper/run",
+					"audit": "GET /v1/reaper/audit",
+				},
+				"tokens": fiber.Map{
+					"create": "POST /v1/tokens",
+				},
+				"keys": fiber.Map{
+					"create": "POST /v1/keys",
+					"list":   "GET /v1/keys",
+					"get":    "GET /v1/keys/:id",
+					"rotate": "POST /v1/keys/:id/rotate",
+					"revoke": "DELETE /v1/keys/:id",
+				},
 				"health":  "GET /health",
 				"metrics": "GET /metrics",
 			},
@@ -69,9 +174,8 @@ func SetupInstanceRoutes(app *fiber.App, handlers *Handlers, cfg *Config, regist
 
 	// 404 handler
 	app.Use(func(c *fiber.Ctx) error {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "Endpoint not found",
-		})
+		return c.Status(fiber.StatusNotFound).JSON(
+			NewErrorResponse("Endpoint not found", ErrCodeNotFound))
 	})
 }
 
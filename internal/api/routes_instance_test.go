@@ -0,0 +1,109 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/birbparty/birb-nest/internal/auth"
+	"github.com/birbparty/birb-nest/internal/cache"
+	"github.com/birbparty/birb-nest/internal/instance"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestSetupInstanceRoutes_InstanceScopedKeys(t *testing.T) {
+	mc, err := cache.NewMemoryCache(&cache.MemoryConfig{})
+	if err != nil {
+		t.Fatalf("NewMemoryCache() error = %v", err)
+	}
+	defer mc.Close()
+
+	keyring := auth.NewKeyring(mc, &keyStore{})
+	_, secret, err := keyring.Create(context.Background(), auth.CreateOptions{
+		Scopes: []auth.Scope{auth.ScopeRead, auth.ScopeWrite, auth.ScopeAdmin}, Instances: []string{"game-*"}})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	cfg := &Config{Mode: "primary", InstanceID: "global", DefaultInstanceID: "global"}
+	registry := instance.NewRegistry(mc)
+	h := NewHandlers(cfg, mc, nil, registry)
+	h.SetKeyring(keyring)
+	app := fiber.New()
+	SetupInstanceRoutes(app, h, cfg, registry)
+
+	tests := []struct {
+		method, path string
+	}{
+		{http.MethodGet, "/v1/instances/lobby"},
+		{http.MethodPost, "/v1/instances/lobby/archive"},
+		{http.MethodPost, "/v1/instances/lobby/restore"},
+		{http.MethodPost, "/v1/instances/lobby/hibernate"},
+		{http.MethodGet, "/v1/instances/lobby/history"},
+		{http.MethodGet, "/v1/instances/lobby/usage"},
+		{http.MethodPut, "/v1/instances/lobby/encryption"},
+		{http.MethodPost, "/v1/instances/lobby/encryption/rotate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("X-API-Key", secret)
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("app.Test() error = %v", err)
+			}
+			var body ErrorResponse
+			json.NewDecoder(resp.Body).Decode(&body)
+			if resp.StatusCode != fiber.StatusForbidden || body.Code != ErrCodeInstanceForbidden {
+				t.Errorf("%s %s = %d %+v, want 403 %s", tt.method, tt.path, resp.StatusCode, body, ErrCodeInstanceForbidden)
+			}
+		})
+	}
+
+	// The key still reaches the handlers of the instances it allows
+	req := httptest.NewRequest(http.MethodPost, "/v1/instances/game-1/archive", nil)
+	req.Header.Set("X-API-Key", secret)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("app.Test() error = %v", err)
+	}
+	if resp.StatusCode == fiber.StatusForbidden || resp.StatusCode == fiber.StatusUnauthorized {
+		t.Errorf("POST /v1/instances/game-1/archive = %d, want the request to pass authentication", resp.StatusCode)
+	}
+}
+
+// keyStore is an auth.Store that only accepts saves; keys are read from the cache
+type keyStore struct{ auth.Store }
+
+func (s *keyStore) SaveKey(ctx context.Context, key *auth.Key) error { return nil }
+
+func TestSetupInstanceRoutes_StripsForwardingHeaders(t *testing.T) {
+	mc, err := cache.NewMemoryCache(&cache.MemoryConfig{})
+	if err != nil {
+		t.Fatalf("NewMemoryCache() error = %v", err)
+	}
+	defer mc.Close()
+
+	// Without TLS no request comes from a verified replica
+	cfg := &Config{Mode: "primary", InstanceID: "global", DefaultInstanceID: "global"}
+	registry := instance.NewRegistry(mc)
+	app := fiber.New()
+	var forwarded string
+	app.Use(func(c *fiber.Ctx) error {
+		err := c.Next()
+		forwarded = c.Get("X-Write-Timestamp")
+		return err
+	})
+	SetupInstanceRoutes(app, NewHandlers(cfg, mc, nil, registry), cfg, registry)
+
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	req.Header.Set("X-Write-Timestamp", "2000-01-01T00:00:00Z")
+	if _, err := app.Test(req); err != nil {
+		t.Fatalf("app.Test() error = %v", err)
+	}
+	if forwarded != "" {
+		t.Errorf("X-Write-Timestamp = %q after the routes ran, want it dropped", forwarded)
+	}
+}
@@ -0,0 +1,389 @@
+package archive
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/birbparty/birb-nest/internal/database"
+	"github.com/birbparty/birb-nest/internal/instance"
+)
+
+func testEntries(n int) []*database.BackupEntry {
+	base := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	entries := make([]*database.BackupEntry, n)
+	for i := range entries {
+		entries[i] = &database.BackupEntry{
+			InstanceID: "inst_archive",
+			Key:        fmt.Sprintf("key:%05d", i),
+			Value:      json.RawMessage(fmt.Sprintf(`{"n":%d,"pad":"%s"}`, i, strings.Repeat("x", 64))),
+			Version:    1,
+			CreatedAt:  base,
+			UpdatedAt:  base.Add(time.Duration(i) * time.Second),
+		}
+	}
+	return entries
+}
+
+func writeArchive(t *testing.T, entries []*database.BackupEntry) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, &Manifest{
+		InstanceID: "inst_archive",
+		Instance:   instance.NewContext("inst_archive"),
+		KeyCount:   int64(len(entries)),
+		SourceNode: "test-node",
+		CreatedAt:  time.Now().UTC(),
+	})
+	if err != nil {
+		t.Fatalf("NewWriter() error = %v", err)
+	}
+	for _, entry := range entries {
+		if err := w.Write(entry); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+func readAll(t *testing.T, data []byte) ([]*database.BackupEntry, *Reader, error) {
+	t.Helper()
+
+	r, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer r.Close()
+
+	var entries []*database.BackupEntry
+	for {
+		entry, err := r.Next()
+		if err == io.EOF {
+			return entries, r, nil
+		}
+		if err != nil {
+			return entries, r, err
+		}
+		entries = append(entries, entry)
+	}
+}
+
+func TestArchive_RoundTrip(t *testing.T) {
+	// Span several chunks
+	want := testEntries(2500)
+	data := writeArchive(t, want)
+
+	got, r, err := readAll(t, data)
+	if err != nil {
+		t.Fatalf("read error = %v", err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("read %d entries, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].Key != want[i].Key || string(got[i].Value) != string(want[i].Value) {
+			t.Fatalf("entry %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	if r.Legacy() {
+		t.Error("Legacy() = true for versioned archive")
+	}
+	m := r.Manifest()
+	if m.FormatVersion != FormatVersion || m.Compression != "zstd" || m.KeyCount != 2500 || m.SourceNode != "test-node" {
+		t.Errorf("Manifest() = %+v", m)
+	}
+	if m.Instance == nil || m.Instance.InstanceID != "inst_archive" {
+		t.Errorf("Manifest().Instance = %+v", m.Instance)
+	}
+	if tr := r.Trailer(); tr == nil || tr.Chunks != 3 || tr.Entries != 2500 {
+		t.Errorf("Trailer() = %+v, want 3 chunks and 2500 entries", tr)
+	}
+}
+
+func TestArchive_Compresses(t *testing.T) {
+	entries := testEntries(1000)
+	data := writeArchive(t, entries)
+
+	var plain bytes.Buffer
+	for _, entry := range entries {
+		line, _ := json.Marshal(entry)
+		plain.Write(line)
+		plain.WriteByte('\n')
+	}
+
+	if len(data) >= plain.Len()/2 {
+		t.Errorf("archive is %d bytes, plain JSONL %d; expected substantial compression", len(data), plain.Len())
+	}
+}
+
+func TestArchive_Empty(t *testing.T) {
+	data := writeArchive(t, nil)
+
+	got, r, err := readAll(t, data)
+	if err != nil {
+		t.Fatalf("read error = %v", err)
+	}
+	if len(got) != 0 || r.Trailer().Chunks != 0 {
+		t.Errorf("empty archive read %d entries, %d chunks", len(got), r.Trailer().Chunks)
+	}
+}
+
+func TestArchive_DetectsCorruption(t *testing.T) {
+	data := writeArchive(t, testEntries(1500))
+
+	tests := []struct {
+		name   string
+		mutate func([]byte) []byte
+		want   error
+	}{
+		{
+			name: "flipped byte in chunk",
+			mutate: func(b []byte) []byte {
+				b[len(b)/2] ^= 0xff
+				return b
+			},
+			want: nil, // either checksum or decompression failure
+		},
+		{
+			name: "truncated",
+			mutate: func(b []byte) []byte {
+				return b[:len(b)-10]
+			},
+			want: ErrCorrupt,
+		},
+		{
+			name: "missing trailer",
+			mutate: func(b []byte) []byte {
+				// Drop the trailer frame entirely
+				idx := bytes.LastIndexByte(b, frameTrailer)
+				return b[:idx]
+			},
+			want: ErrCorrupt,
+		},
+		{
+			name: "tampered trailer checksum",
+			mutate: func(b []byte) []byte {
+				idx := bytes.LastIndex(b, []byte(`"sha256":"`)) + len(`"sha256":"`)
+				if b[idx] == '0' {
+					b[idx] = '1'
+				} else {
+					b[idx] = '0'
+				}
+				return b
+			},
+			want: ErrChecksumMismatch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			corrupt := tt.mutate(append([]byte(nil), data...))
+			_, _, err := readAll(t, corrupt)
+			if err == nil {
+				t.Fatal("corrupt archive read without error")
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("error = %v, want %v", err, tt.want)
+			}
+			if tt.want == nil && !errors.Is(err, ErrCorrupt) && !errors.Is(err, ErrChecksumMismatch) {
+				t.Errorf("error = %v, want ErrCorrupt or ErrChecksumMismatch", err)
+			}
+		})
+	}
+}
+
+func TestArchive_UnsupportedVersion(t *testing.T) {
+	data := writeArchive(t, testEntries(1))
+	data[5] = 99
+
+	if _, err := NewReader(bytes.NewReader(data)); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("NewReader() error = %v, want ErrUnsupportedFormat", err)
+	}
+}
+
+func TestArchive_ManifestKeyCountMismatch(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, &Manifest{InstanceID: "inst_archive", KeyCount: 5})
+	if err != nil {
+		t.Fatalf("NewWriter() error = %v", err)
+	}
+	_ = w.Write(testEntries(1)[0])
+	if err := w.Close(); err == nil {
+		t.Error("Close() succeeded although fewer keys than declared were written")
+	}
+}
+
+func TestReader_LegacyJSONL(t *testing.T) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, entry := range testEntries(3) {
+		_ = enc.Encode(entry)
+	}
+
+	got, r, err := readAll(t, buf.Bytes())
+	if err != nil {
+		t.Fatalf("read error = %v", err)
+	}
+	if !r.Legacy() || r.Manifest() != nil {
+		t.Errorf("Legacy() = %v, Manifest() = %v", r.Legacy(), r.Manifest())
+	}
+	if len(got) != 3 || got[2].Key != "key:00002" {
+		t.Errorf("legacy read = %d entries", len(got))
+	}
+}
+
+func TestVerify(t *testing.T) {
+	summary, err := Verify(bytes.NewReader(writeArchive(t, testEntries(10))))
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if summary.Legacy || summary.Entries != 10 || summary.Manifest == nil || summary.Trailer == nil {
+		t.Errorf("Verify() = %+v", summary)
+	}
+
+	if _, err := Verify(strings.NewReader("not json\n")); err == nil {
+		t.Error("Verify() accepted invalid legacy input")
+	}
+}
+
+func writeManifestArchive(t *testing.T, manifest *Manifest, entries []*database.BackupEntry) *Reader {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, manifest)
+	if err != nil {
+		t.Fatalf("NewWriter() error = %v", err)
+	}
+	for _, entry := range entries {
+		if err := w.Write(entry); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	r, err := NewReader(&buf)
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+	return r
+}
+
+func TestMerge(t *testing.T) {
+	entry := func(key, value string) *database.BackupEntry {
+		return &database.BackupEntry{InstanceID: "inst_archive", Key: key, Value: json.RawMessage(value)}
+	}
+	tombstone := func(key string) *database.BackupEntry {
+		return &database.BackupEntry{InstanceID: "inst_archive", Key: key, Deleted: true}
+	}
+
+	full := writeManifestArchive(t, &Manifest{InstanceID: "inst_archive"}, []*database.BackupEntry{
+		entry("a", `1`), entry("b", `1`), entry("c", `1`),
+	})
+	inc1 := writeManifestArchive(t, &Manifest{InstanceID: "inst_archive", Kind: KindIncremental, Parent: "full"},
+		[]*database.BackupEntry{entry("b", `2`), tombstone("c"), entry("d", `2`)})
+	inc2 := writeManifestArchive(t, &Manifest{InstanceID: "inst_archive", Kind: KindIncremental, Parent: "inc1"},
+		[]*database.BackupEntry{tombstone("a"), entry("c", `3`)})
+
+	var out bytes.Buffer
+	trailer, err := Merge(&out, &Manifest{InstanceID: "inst_archive"}, full, inc1, inc2)
+	if err != nil {
+		t.Fatalf("Merge() error = %v", err)
+	}
+	if trailer.Entries != 3 {
+		t.Errorf("merged %d entries, want 3", trailer.Entries)
+	}
+
+	got, r, err := readAll(t, out.Bytes())
+	if err != nil {
+		t.Fatalf("read merged archive: %v", err)
+	}
+	if r.Manifest().IsIncremental() {
+		t.Error("merged archive is marked incremental")
+	}
+
+	want := map[string]string{"b": `2`, "c": `3`, "d": `2`}
+	if len(got) != len(want) {
+		t.Fatalf("merged keys = %d, want %d", len(got), len(want))
+	}
+	for _, e := range got {
+		if e.Deleted || string(e.Value) != want[e.Key] {
+			t.Errorf("merged %s = %s (deleted=%v), want %s", e.Key, e.Value, e.Deleted, want[e.Key])
+		}
+	}
+}
+
+func TestMerge_RejectsUnsortedInput(t *testing.T) {
+	unsorted := writeManifestArchive(t, &Manifest{InstanceID: "inst_archive"}, []*database.BackupEntry{
+		{Key: "b", Value: json.RawMessage(`1`)},
+		{Key: "a", Value: json.RawMessage(`1`)},
+	})
+
+	if _, err := Merge(io.Discard, &Manifest{InstanceID: "inst_archive"}, unsorted); !errors.Is(err, ErrUnsorted) {
+		t.Errorf("Merge() error = %v, want ErrUnsorted", err)
+	}
+}
+
+func TestMerge_ByteOrderedKeys(t *testing.T) {
+	// Byte order puts upper case and most punctuation before lower case,
+	// unlike the collations of most locales
+	entry := func(key string) *database.BackupEntry {
+		return &database.BackupEntry{InstanceID: "inst_archive", Key: key, Value: json.RawMessage(`1`)}
+	}
+	full := writeManifestArchive(t, &Manifest{InstanceID: "inst_archive"}, []*database.BackupEntry{
+		entry("Player:1"), entry("Zone"), entry("a-b"), entry("a.b"), entry("aB"), entry("a_b"), entry("ab"),
+	})
+	inc := writeManifestArchive(t, &Manifest{InstanceID: "inst_archive", Kind: KindIncremental, Parent: "full"},
+		[]*database.BackupEntry{entry("Player:2"), entry("a:b"), entry("zone")})
+
+	var out bytes.Buffer
+	if _, err := Merge(&out, &Manifest{InstanceID: "inst_archive"}, full, inc); err != nil {
+		t.Fatalf("Merge() error = %v", err)
+	}
+	got, _, err := readAll(t, out.Bytes())
+	if err != nil {
+		t.Fatalf("read merged archive: %v", err)
+	}
+
+	want := []string{"Player:1", "Player:2", "Zone", "a-b", "a.b", "a:b", "aB", "a_b", "ab", "zone"}
+	if len(got) != len(want) {
+		t.Fatalf("merged %d keys, want %d", len(got), len(want))
+	}
+	for i, e := range got {
+		if e.Key != want[i] {
+			t.Errorf("merged key %d = %q, want %q", i, e.Key, want[i])
+		}
+	}
+
+	// Dictionary order, as a non-C collation sorts them, is rejected
+	dictionary := writeManifestArchive(t, &Manifest{InstanceID: "inst_archive"}, []*database.BackupEntry{
+		entry("ab"), entry("aB"), entry("Zone"),
+	})
+	if _, err := Merge(io.Discard, &Manifest{InstanceID: "inst_archive"}, dictionary); !errors.Is(err, ErrUnsorted) {
+		t.Errorf("Merge() of dictionary-ordered keys error = %v, want ErrUnsorted", err)
+	}
+}
+
+func TestReader_AcceptsVersion1(t *testing.T) {
+	data := writeArchive(t, testEntries(2))
+	data[5] = 1
+
+	r, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+	if r.Manifest().Kind != KindFull {
+		t.Errorf("version 1 manifest kind = %q, want %q", r.Manifest().Kind, KindFull)
+	}
+}
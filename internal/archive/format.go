@@ -0,0 +1,108 @@
+// Package archive implements the versioned instance archive format.
+//
+// An archive is a stream of length-prefixed frames following a magic header:
+//
+//	magic    "BNAR" + uint16 format version
+//	manifest frame  JSON Manifest describing the instance and contents
+//	chunk frames    SHA-256 of the raw chunk, entry count, zstd-compressed JSONL
+//	trailer frame   JSON Trailer with totals and the SHA-256 of every preceding byte
+//
+// Each frame is a one-byte type, a big-endian uint32 payload length and the
+// payload. Readers also accept the legacy plain-JSONL backup format.
+package archive
+
+import (
+	"errors"
+	"time"
+
+	"github.com/birbparty/birb-nest/internal/instance"
+)
+
+// FormatVersion is the archive format version written by this package.
+// Version 2 added incremental backups and tombstone entries.
+const FormatVersion = 2
+
+// minFormatVersion is the oldest archive format version readers accept
+const minFormatVersion = 1
+
+// Backup kinds recorded in the manifest
+const (
+	KindFull        = "full"
+	KindIncremental = "incremental"
+)
+
+// ContentType is the MIME type of archives in this format
+const ContentType = "application/vnd.birbnest.archive"
+
+// FileExtension is the conventional extension for archives in this format
+const FileExtension = ".bnar"
+
+// magic identifies an archive stream
+var magic = [4]byte{'B', 'N', 'A', 'R'}
+
+// Frame types
+const (
+	frameManifest byte = 'M'
+	frameChunk    byte = 'C'
+	frameTrailer  byte = 'T'
+)
+
+const (
+	// DefaultChunkEntries is the maximum number of entries per chunk
+	DefaultChunkEntries = 1000
+
+	// DefaultChunkBytes is the uncompressed size at which a chunk is flushed
+	DefaultChunkBytes = 4 << 20
+
+	// maxFrameSize guards readers against corrupt length prefixes
+	maxFrameSize = 256 << 20
+)
+
+// Errors returned while reading archives
+var (
+	ErrChecksumMismatch  = errors.New("archive checksum mismatch")
+	ErrCorrupt           = errors.New("archive is corrupt")
+	ErrUnsupportedFormat = errors.New("unsupported archive format version")
+	ErrUnsorted          = errors.New("archive entries are not sorted by key")
+)
+
+// Manifest is the header describing an archive's contents.
+// An incremental archive holds the keys changed or deleted after Since, up to
+// Until, and names the archive it was taken on top of as Parent.
+type Manifest struct {
+	FormatVersion int               `json:"format_version"`
+	Kind          string            `json:"kind,omitempty"` // empty in version 1 archives, which are always full
+	InstanceID    string            `json:"instance_id"`
+	Instance      *instance.Context `json:"instance,omitempty"`
+	KeyCount      int64             `json:"key_count"`
+	Tombstones    int64             `json:"tombstones,omitempty"`
+	Parent        string            `json:"parent,omitempty"`
+	Since         time.Time         `json:"since,omitempty"`
+	Until         time.Time         `json:"until,omitempty"`
+	OldestUpdate  time.Time         `json:"oldest_update,omitempty"`
+	NewestUpdate  time.Time         `json:"newest_update,omitempty"`
+	SourceNode    string            `json:"source_node,omitempty"`
+	CreatedAt     time.Time         `json:"created_at"`
+	Compression   string            `json:"compression"`
+}
+
+// IsIncremental reports whether the archive only holds changes since its parent
+func (m *Manifest) IsIncremental() bool {
+	return m.Kind == KindIncremental
+}
+
+// Trailer closes an archive with totals and the whole-file checksum
+type Trailer struct {
+	Chunks            int    `json:"chunks"`
+	Entries           int64  `json:"entries"`
+	UncompressedBytes int64  `json:"uncompressed_bytes"`
+	SHA256            string `json:"sha256"`
+}
+
+// Summary describes an archive after it has been fully read and verified
+type Summary struct {
+	Legacy   bool      `json:"legacy"`
+	Manifest *Manifest `json:"manifest,omitempty"`
+	Trailer  *Trailer  `json:"trailer,omitempty"`
+	Entries  int64     `json:"entries"`
+}
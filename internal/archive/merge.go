@@ -0,0 +1,94 @@
+package archive
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/birbparty/birb-nest/internal/database"
+)
+
+// mergeSource tracks the current entry of one archive being merged
+type mergeSource struct {
+	r    *Reader
+	head *database.BackupEntry
+}
+
+func (s *mergeSource) advance() error {
+	prev := s.head
+	entry, err := s.r.Next()
+	if err == io.EOF {
+		s.head = nil
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if prev != nil && entry.Key <= prev.Key {
+		return fmt.Errorf("%w: %q follows %q", ErrUnsorted, entry.Key, prev.Key)
+	}
+	s.head = entry
+	return nil
+}
+
+// Merge folds a backup chain into a single full archive written to w.
+// Sources must be ordered oldest first and sorted by key, as BackupInstance
+// writes them; for each key the newest source wins and tombstones drop the key.
+// Only one entry per source is held in memory at a time.
+func Merge(w io.Writer, manifest *Manifest, sources ...*Reader) (*Trailer, error) {
+	m := *manifest
+	m.Kind = KindFull
+	m.KeyCount = 0 // unknown until the merge completes
+	m.Tombstones = 0
+	m.Parent = ""
+	m.Since = time.Time{}
+
+	aw, err := NewWriter(w, &m)
+	if err != nil {
+		return nil, err
+	}
+
+	heads := make([]*mergeSource, len(sources))
+	for i, r := range sources {
+		heads[i] = &mergeSource{r: r}
+		if err := heads[i].advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	for {
+		// Find the smallest key; later sources override earlier ones
+		var winner *database.BackupEntry
+		for _, s := range heads {
+			if s.head == nil {
+				continue
+			}
+			if winner == nil || s.head.Key <= winner.Key {
+				winner = s.head
+			}
+		}
+		if winner == nil {
+			break
+		}
+
+		key := winner.Key
+		if !winner.Deleted {
+			if err := aw.Write(winner); err != nil {
+				return nil, err
+			}
+		}
+		for _, s := range heads {
+			if s.head != nil && s.head.Key == key {
+				if err := s.advance(); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	if err := aw.Close(); err != nil {
+		return nil, err
+	}
+	trailer := aw.Trailer()
+	return &trailer, nil
+}
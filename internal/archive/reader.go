@@ -0,0 +1,262 @@
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/birbparty/birb-nest/internal/database"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Reader decodes backup entries from an archive or a legacy JSONL backup.
+// Checksums are verified as chunks are read; Next only returns io.EOF once
+// the trailer has been verified, so callers must treat any other error as a
+// reason to discard everything read so far.
+type Reader struct {
+	br      *bufio.Reader
+	hash    hash.Hash
+	decoder *zstd.Decoder
+
+	legacy      *json.Decoder
+	manifest    *Manifest
+	trailer     *Trailer
+	lines       *bufio.Scanner
+	chunkLeft   int
+	chunks      int
+	entries     int64
+	uncompBytes int64
+	done        bool
+}
+
+// NewReader detects the archive format and reads the manifest if present
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReaderSize(r, 64<<10)
+
+	head, err := br.Peek(len(magic))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to read archive header: %w", err)
+	}
+	if !bytes.Equal(head, magic[:]) {
+		// Plain JSONL from before the archive format existed
+		return &Reader{br: br, legacy: json.NewDecoder(br)}, nil
+	}
+
+	ar := &Reader{br: br, hash: sha256.New()}
+
+	header := make([]byte, 6)
+	if err := ar.readFull(header); err != nil {
+		return nil, err
+	}
+	if version := binary.BigEndian.Uint16(header[4:]); version < minFormatVersion || version > FormatVersion {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedFormat, version)
+	}
+
+	frameType, payload, err := ar.readFrame()
+	if err != nil {
+		return nil, err
+	}
+	if frameType != frameManifest {
+		return nil, fmt.Errorf("%w: expected manifest frame", ErrCorrupt)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(payload, &manifest); err != nil {
+		return nil, fmt.Errorf("%w: invalid manifest: %v", ErrCorrupt, err)
+	}
+	if manifest.Kind == "" {
+		manifest.Kind = KindFull
+	}
+	ar.manifest = &manifest
+
+	decoder, err := zstd.NewReader(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create zstd decoder: %w", err)
+	}
+	ar.decoder = decoder
+
+	return ar, nil
+}
+
+// Legacy reports whether the input is a plain JSONL backup without checksums
+func (ar *Reader) Legacy() bool {
+	return ar.legacy != nil
+}
+
+// Manifest returns the archive manifest, or nil for legacy backups
+func (ar *Reader) Manifest() *Manifest {
+	return ar.manifest
+}
+
+// Trailer returns the verified trailer once Next has returned io.EOF
+func (ar *Reader) Trailer() *Trailer {
+	return ar.trailer
+}
+
+// Next returns the next entry, or io.EOF once the archive has been fully verified
+func (ar *Reader) Next() (*database.BackupEntry, error) {
+	if ar.done {
+		return nil, io.EOF
+	}
+
+	if ar.legacy != nil {
+		var entry database.BackupEntry
+		if err := ar.legacy.Decode(&entry); err != nil {
+			if errors.Is(err, io.EOF) {
+				ar.done = true
+			}
+			return nil, err
+		}
+		ar.entries++
+		return &entry, nil
+	}
+
+	for ar.chunkLeft == 0 {
+		if err := ar.nextChunk(); err != nil {
+			return nil, err
+		}
+		if ar.done {
+			return nil, io.EOF
+		}
+	}
+
+	if !ar.lines.Scan() {
+		return nil, fmt.Errorf("%w: chunk ended early", ErrCorrupt)
+	}
+
+	var entry database.BackupEntry
+	if err := json.Unmarshal(ar.lines.Bytes(), &entry); err != nil {
+		return nil, fmt.Errorf("%w: invalid entry: %v", ErrCorrupt, err)
+	}
+	ar.chunkLeft--
+	ar.entries++
+	return &entry, nil
+}
+
+// Close releases decoder resources
+func (ar *Reader) Close() {
+	if ar.decoder != nil {
+		ar.decoder.Close()
+	}
+}
+
+// nextChunk loads and verifies the next chunk, or verifies the trailer at the end
+func (ar *Reader) nextChunk() error {
+	// Capture the running hash before the trailer frame is consumed
+	sumBefore := ar.hash.Sum(nil)
+
+	frameType, payload, err := ar.readFrame()
+	if err != nil {
+		return err
+	}
+
+	switch frameType {
+	case frameChunk:
+		if len(payload) < sha256.Size+4 {
+			return fmt.Errorf("%w: chunk too short", ErrCorrupt)
+		}
+		wantSum := payload[:sha256.Size]
+		count := int(binary.BigEndian.Uint32(payload[sha256.Size:]))
+
+		raw, err := ar.decoder.DecodeAll(payload[sha256.Size+4:], nil)
+		if err != nil {
+			return fmt.Errorf("%w: failed to decompress chunk %d: %v", ErrCorrupt, ar.chunks, err)
+		}
+		if gotSum := sha256.Sum256(raw); !bytes.Equal(gotSum[:], wantSum) {
+			return fmt.Errorf("%w: chunk %d", ErrChecksumMismatch, ar.chunks)
+		}
+
+		ar.lines = bufio.NewScanner(bytes.NewReader(raw))
+		ar.lines.Buffer(make([]byte, 0, 64<<10), len(raw)+1)
+		ar.chunkLeft = count
+		ar.chunks++
+		ar.uncompBytes += int64(len(raw))
+		return nil
+
+	case frameTrailer:
+		var trailer Trailer
+		if err := json.Unmarshal(payload, &trailer); err != nil {
+			return fmt.Errorf("%w: invalid trailer: %v", ErrCorrupt, err)
+		}
+		if trailer.SHA256 != hex.EncodeToString(sumBefore) {
+			return fmt.Errorf("%w: whole-file checksum", ErrChecksumMismatch)
+		}
+		if trailer.Chunks != ar.chunks || trailer.Entries != ar.entries || trailer.UncompressedBytes != ar.uncompBytes {
+			return fmt.Errorf("%w: trailer totals do not match contents", ErrCorrupt)
+		}
+		if ar.manifest.KeyCount != 0 && ar.manifest.KeyCount != ar.entries {
+			return fmt.Errorf("%w: manifest declares %d keys, archive holds %d", ErrCorrupt, ar.manifest.KeyCount, ar.entries)
+		}
+		ar.trailer = &trailer
+		ar.done = true
+		return nil
+
+	default:
+		return fmt.Errorf("%w: unknown frame type %q", ErrCorrupt, frameType)
+	}
+}
+
+func (ar *Reader) readFrame() (byte, []byte, error) {
+	header := make([]byte, 5)
+	if err := ar.readFull(header); err != nil {
+		return 0, nil, err
+	}
+
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > maxFrameSize {
+		return 0, nil, fmt.Errorf("%w: frame of %d bytes exceeds limit", ErrCorrupt, size)
+	}
+
+	payload := make([]byte, size)
+	if err := ar.readFull(payload); err != nil {
+		return 0, nil, err
+	}
+	return header[0], payload, nil
+}
+
+func (ar *Reader) readFull(p []byte) error {
+	if _, err := io.ReadFull(ar.br, p); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return fmt.Errorf("%w: truncated", ErrCorrupt)
+		}
+		return fmt.Errorf("failed to read archive: %w", err)
+	}
+	ar.hash.Write(p)
+	return nil
+}
+
+// Verify reads an entire archive, checking every checksum without applying it
+func Verify(r io.Reader) (*Summary, error) {
+	ar, err := NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer ar.Close()
+
+	for {
+		if _, err := ar.Next(); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	return ar.Summary(), nil
+}
+
+// Summary describes what has been read so far
+func (ar *Reader) Summary() *Summary {
+	return &Summary{
+		Legacy:   ar.Legacy(),
+		Manifest: ar.manifest,
+		Trailer:  ar.trailer,
+		Entries:  ar.entries,
+	}
+}
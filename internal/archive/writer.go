@@ -0,0 +1,170 @@
+package archive
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/birbparty/birb-nest/internal/database"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Writer encodes backup entries into the archive format
+type Writer struct {
+	w       io.Writer
+	hash    hash.Hash // whole-file checksum of every byte before the trailer
+	encoder *zstd.Encoder
+
+	chunk        bytes.Buffer
+	chunkEntries int
+	maxEntries   int
+	maxBytes     int
+
+	manifest *Manifest
+	trailer  Trailer
+	closed   bool
+}
+
+// NewWriter writes the archive header and manifest to w
+func NewWriter(w io.Writer, manifest *Manifest) (*Writer, error) {
+	encoder, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create zstd encoder: %w", err)
+	}
+
+	m := *manifest
+	m.FormatVersion = FormatVersion
+	m.Compression = "zstd"
+	if m.Kind == "" {
+		m.Kind = KindFull
+	}
+
+	aw := &Writer{
+		w:          w,
+		hash:       sha256.New(),
+		encoder:    encoder,
+		maxEntries: DefaultChunkEntries,
+		maxBytes:   DefaultChunkBytes,
+		manifest:   &m,
+	}
+
+	header := make([]byte, 6)
+	copy(header, magic[:])
+	binary.BigEndian.PutUint16(header[4:], FormatVersion)
+	if err := aw.write(header); err != nil {
+		return nil, err
+	}
+
+	payload, err := json.Marshal(aw.manifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	if err := aw.writeFrame(frameManifest, payload); err != nil {
+		return nil, err
+	}
+
+	return aw, nil
+}
+
+// Write appends a backup entry, flushing a chunk once it is full
+func (aw *Writer) Write(entry *database.BackupEntry) error {
+	if aw.closed {
+		return fmt.Errorf("archive writer is closed")
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode entry: %w", err)
+	}
+	aw.chunk.Write(line)
+	aw.chunk.WriteByte('\n')
+	aw.chunkEntries++
+
+	if aw.chunkEntries >= aw.maxEntries || aw.chunk.Len() >= aw.maxBytes {
+		return aw.flushChunk()
+	}
+	return nil
+}
+
+// Close flushes the final chunk and writes the trailer; it does not close the underlying writer
+func (aw *Writer) Close() error {
+	if aw.closed {
+		return nil
+	}
+	aw.closed = true
+	defer aw.encoder.Close()
+
+	if err := aw.flushChunk(); err != nil {
+		return err
+	}
+
+	if aw.manifest.KeyCount != 0 && aw.manifest.KeyCount != aw.trailer.Entries {
+		return fmt.Errorf("manifest declares %d keys but %d were written", aw.manifest.KeyCount, aw.trailer.Entries)
+	}
+
+	aw.trailer.SHA256 = hex.EncodeToString(aw.hash.Sum(nil))
+	payload, err := json.Marshal(aw.trailer)
+	if err != nil {
+		return fmt.Errorf("failed to encode trailer: %w", err)
+	}
+
+	// The trailer is not part of the hash it carries
+	aw.hash = nil
+	return aw.writeFrame(frameTrailer, payload)
+}
+
+// Trailer returns the trailer written by Close
+func (aw *Writer) Trailer() Trailer {
+	return aw.trailer
+}
+
+func (aw *Writer) flushChunk() error {
+	if aw.chunkEntries == 0 {
+		return nil
+	}
+
+	raw := aw.chunk.Bytes()
+	sum := sha256.Sum256(raw)
+
+	payload := make([]byte, 0, sha256.Size+4+len(raw)/2)
+	payload = append(payload, sum[:]...)
+	payload = binary.BigEndian.AppendUint32(payload, uint32(aw.chunkEntries))
+	payload = aw.encoder.EncodeAll(raw, payload)
+
+	if err := aw.writeFrame(frameChunk, payload); err != nil {
+		return err
+	}
+
+	aw.trailer.Chunks++
+	aw.trailer.Entries += int64(aw.chunkEntries)
+	aw.trailer.UncompressedBytes += int64(len(raw))
+	aw.chunk.Reset()
+	aw.chunkEntries = 0
+	return nil
+}
+
+func (aw *Writer) writeFrame(frameType byte, payload []byte) error {
+	header := make([]byte, 5)
+	header[0] = frameType
+	binary.BigEndian.PutUint32(header[1:], uint32(len(payload)))
+
+	if err := aw.write(header); err != nil {
+		return err
+	}
+	return aw.write(payload)
+}
+
+func (aw *Writer) write(p []byte) error {
+	if aw.hash != nil {
+		aw.hash.Write(p)
+	}
+	if _, err := aw.w.Write(p); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	return nil
+}
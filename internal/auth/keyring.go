@@ -0,0 +1,339 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// cachePrefix prefixes the cache entries of keys, by secret hash
+	cachePrefix = "auth:key:"
+
+	// cacheTTL is how long a key stays in the shared cache after it was last
+	// saved or read from the store
+	cacheTTL = 24 * time.Hour
+
+	// memCacheTTL bounds how long a node keeps using a key after it changes elsewhere
+	memCacheTTL = 30 * time.Second
+
+	// touchInterval throttles last-used updates per key
+	touchInterval = time.Minute
+
+	// RootKeyID identifies the key configured with API_KEY
+	RootKeyID = "root"
+)
+
+// Store persists API keys. Keys are looked up by the hash of their secret.
+type Store interface {
+	// SaveKey creates or replaces a key, including its hash
+	SaveKey(ctx context.Context, key *Key) error
+
+	// GetKey returns a key by ID or ErrKeyNotFound
+	GetKey(ctx context.Context, id string) (*Key, error)
+
+	// GetKeyByHash returns the key with a secret hash or ErrKeyNotFound
+	GetKeyByHash(ctx context.Context, hash string) (*Key, error)
+
+	// ListKeys returns every key, newest first
+	ListKeys(ctx context.Context) ([]*Key, error)
+
+	// TouchKey records when a key was last used
+	TouchKey(ctx context.Context, id string, at time.Time) error
+}
+
+// Cache is the subset of cache operations the keyring uses
+type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+}
+
+// CreateOptions describes a new API key
+type CreateOptions struct {
+	Name      string
+	Scopes    []Scope
+	Instances []string
+	ExpiresAt *time.Time
+}
+
+// memEntry is a key cached in process memory
+type memEntry struct {
+	key        *Key
+	expiration time.Time
+}
+
+// Keyring authenticates API keys. Keys are stored in the store and cached in
+// Redis by secret hash, so replicas sharing the primary's Redis can check keys
+// without a database. Changes reach other nodes' memory caches within 30s.
+type Keyring struct {
+	cache    Cache
+	store    Store // nil on replicas; keys are then read from the cache only
+	rootHash string
+	tokens   *TokenSigner // nil rejects signed tokens
+
+	mu          sync.Mutex
+	memCache    map[string]*memEntry // secret hash -> key
+	lastTouched map[string]time.Time // key ID -> last recorded use
+}
+
+// NewKeyring creates a keyring. store may be nil.
+func NewKeyring(cache Cache, store Store) *Keyring {
+	return &Keyring{
+		cache:       cache,
+		store:       store,
+		memCache:    make(map[string]*memEntry),
+		lastTouched: make(map[string]time.Time),
+	}
+}
+
+// SetRootKey accepts secret as an admin key for every instance. It is meant
+// for bootstrapping and for replicas forwarding writes to the primary.
+func (k *Keyring) SetRootKey(secret string) {
+	if secret != "" {
+		k.rootHash = HashSecret(secret)
+	}
+}
+
+// SetTokenSigner accepts signed client tokens verified by signer
+func (k *Keyring) SetTokenSigner(signer *TokenSigner) {
+	k.tokens = signer
+}
+
+// Authenticate returns the active key for a secret or signed token
+func (k *Keyring) Authenticate(ctx context.Context, secret string) (*Key, error) {
+	if secret == "" {
+		return nil, ErrMissingKey
+	}
+	if k.tokens != nil && IsToken(secret) {
+		claims, err := k.tokens.Verify(secret, time.Now())
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+		}
+		return claims.Key(), nil
+	}
+
+	hash := HashSecret(secret)
+	if k.rootHash != "" && subtle.ConstantTimeCompare([]byte(hash), []byte(k.rootHash)) == 1 {
+		return &Key{ID: RootKeyID, Name: "API_KEY", Scopes: []Scope{ScopeAdmin}, Instances: []string{"*"}}, nil
+	}
+
+	key, err := k.lookup(ctx, hash)
+	if err != nil {
+		if errors.Is(err, ErrKeyNotFound) {
+			return nil, ErrInvalidKey
+		}
+		return nil, err
+	}
+	now := time.Now()
+	if !key.Active(now) {
+		return nil, ErrInvalidKey
+	}
+
+	k.touch(key, now)
+	return key, nil
+}
+
+// TokenInstance returns the instance a valid signed token is limited to, or ""
+// for anything else
+func (k *Keyring) TokenInstance(secret string) string {
+	if k.tokens == nil || !IsToken(secret) {
+		return ""
+	}
+	claims, err := k.tokens.Verify(secret, time.Now())
+	if err != nil {
+		return ""
+	}
+	return claims.Instance
+}
+
+// Create stores a new key and returns it with its secret. The secret is
+// only ever returned here.
+func (k *Keyring) Create(ctx context.Context, opts CreateOptions) (*Key, string, error) {
+	if k.store == nil {
+		return nil, "", ErrStoreUnavailable
+	}
+	if err := ValidateScopes(opts.Scopes); err != nil {
+		return nil, "", err
+	}
+	if err := ValidatePatterns(opts.Instances); err != nil {
+		return nil, "", err
+	}
+
+	id, secret, err := generateSecret()
+	if err != nil {
+		return nil, "", err
+	}
+	key := &Key{
+		ID:        id,
+		Name:      opts.Name,
+		Prefix:    secret[:len(SecretPrefix)+len(id)],
+		Hash:      HashSecret(secret),
+		Scopes:    opts.Scopes,
+		Instances: opts.Instances,
+		CreatedAt: time.Now(),
+		ExpiresAt: opts.ExpiresAt,
+	}
+	if err := k.save(ctx, key); err != nil {
+		return nil, "", err
+	}
+	return key, secret, nil
+}
+
+// Rotate replaces a key with a new one carrying the same name, scopes,
+// instances and expiry. The old key keeps working for the grace period so
+// clients can switch over; a zero grace period revokes it immediately.
+func (k *Keyring) Rotate(ctx context.Context, id string, grace time.Duration) (*Key, string, error) {
+	old, err := k.Get(ctx, id)
+	if err != nil {
+		return nil, "", err
+	}
+	now := time.Now()
+	if !old.Active(now) {
+		return nil, "", fmt.Errorf("%w: key %s is no longer active", ErrInvalidKey, id)
+	}
+
+	key, secret, err := k.Create(ctx, CreateOptions{
+		Name:      old.Name,
+		Scopes:    old.Scopes,
+		Instances: old.Instances,
+		ExpiresAt: old.ExpiresAt,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	old.ReplacedBy = key.ID
+	if grace > 0 {
+		until := now.Add(grace)
+		if old.ExpiresAt == nil || until.Before(*old.ExpiresAt) {
+			old.ExpiresAt = &until
+		}
+	} else {
+		old.RevokedAt = &now
+	}
+	if err := k.save(ctx, old); err != nil {
+		return nil, "", fmt.Errorf("failed to retire rotated key: %w", err)
+	}
+	return key, secret, nil
+}
+
+// Revoke disables a key
+func (k *Keyring) Revoke(ctx context.Context, id string) (*Key, error) {
+	key, err := k.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if key.RevokedAt == nil {
+		now := time.Now()
+		key.RevokedAt = &now
+		if err := k.save(ctx, key); err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+// Get returns a key by ID
+func (k *Keyring) Get(ctx context.Context, id string) (*Key, error) {
+	if k.store == nil {
+		return nil, ErrStoreUnavailable
+	}
+	return k.store.GetKey(ctx, id)
+}
+
+// List returns every key, newest first
+func (k *Keyring) List(ctx context.Context) ([]*Key, error) {
+	if k.store == nil {
+		return nil, ErrStoreUnavailable
+	}
+	return k.store.ListKeys(ctx)
+}
+
+// save writes a key to the store and refreshes its cached copies
+func (k *Keyring) save(ctx context.Context, key *Key) error {
+	if err := k.store.SaveKey(ctx, key); err != nil {
+		return fmt.Errorf("failed to save API key: %w", err)
+	}
+
+	k.mu.Lock()
+	delete(k.memCache, key.Hash)
+	k.mu.Unlock()
+	k.cacheKey(ctx, key)
+	return nil
+}
+
+// lookup finds a key by secret hash in memory, then the cache, then the store
+func (k *Keyring) lookup(ctx context.Context, hash string) (*Key, error) {
+	k.mu.Lock()
+	if entry, ok := k.memCache[hash]; ok && time.Now().Before(entry.expiration) {
+		k.mu.Unlock()
+		return entry.key, nil
+	}
+	k.mu.Unlock()
+
+	var key *Key
+	if data, err := k.cache.Get(ctx, cachePrefix+hash); err == nil {
+		key = &Key{}
+		if err := json.Unmarshal(data, key); err != nil {
+			key = nil
+		} else {
+			key.Hash = hash
+		}
+	}
+	if key == nil {
+		if k.store == nil {
+			return nil, ErrKeyNotFound
+		}
+		var err error
+		if key, err = k.store.GetKeyByHash(ctx, hash); err != nil {
+			return nil, err
+		}
+		k.cacheKey(ctx, key)
+	}
+
+	k.mu.Lock()
+	k.memCache[hash] = &memEntry{key: key, expiration: time.Now().Add(memCacheTTL)}
+	k.mu.Unlock()
+	return key, nil
+}
+
+// cacheKey writes a key to the shared cache; failures are logged
+func (k *Keyring) cacheKey(ctx context.Context, key *Key) {
+	data, err := json.Marshal(key)
+	if err != nil {
+		log.Printf("Warning: Failed to encode API key %s: %v", key.ID, err)
+		return
+	}
+	if err := k.cache.Set(ctx, cachePrefix+key.Hash, data, cacheTTL); err != nil {
+		log.Printf("Warning: Failed to cache API key %s: %v", key.ID, err)
+	}
+}
+
+// touch records a key's use in the store at most once per touchInterval
+func (k *Keyring) touch(key *Key, now time.Time) {
+	if k.store == nil {
+		return
+	}
+
+	k.mu.Lock()
+	if now.Sub(k.lastTouched[key.ID]) < touchInterval {
+		k.mu.Unlock()
+		return
+	}
+	k.lastTouched[key.ID] = now
+	k.mu.Unlock()
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := k.store.TouchKey(ctx, key.ID, now); err != nil {
+			log.Printf("Warning: Failed to record use of API key %s: %v", key.ID, err)
+		}
+	}()
+}
@@ -0,0 +1,174 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/birbparty/birb-nest/internal/cache"
+)
+
+// memStore is an in-memory Store
+type memStore struct {
+	mu   sync.Mutex
+	keys map[string]Key
+}
+
+func newMemStore() *memStore {
+	return &memStore{keys: make(map[string]Key)}
+}
+
+func (s *memStore) SaveKey(ctx context.Context, key *Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[key.ID] = *key
+	return nil
+}
+
+func (s *memStore) GetKey(ctx context.Context, id string) (*Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, ok := s.keys[id]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	return &key, nil
+}
+
+func (s *memStore) GetKeyByHash(ctx context.Context, hash string) (*Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range s.keys {
+		if key.Hash == hash {
+			return &key, nil
+		}
+	}
+	return nil, ErrKeyNotFound
+}
+
+func (s *memStore) ListKeys(ctx context.Context) ([]*Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := []*Key{}
+	for _, key := range s.keys {
+		keys = append(keys, &key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.After(keys[j].CreatedAt) })
+	return keys, nil
+}
+
+func (s *memStore) TouchKey(ctx context.Context, id string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if key, ok := s.keys[id]; ok {
+		key.LastUsedAt = &at
+		s.keys[id] = key
+	}
+	return nil
+}
+
+func newTestKeyring(t *testing.T) (*Keyring, *cache.MemoryCache) {
+	t.Helper()
+	mc, err := cache.NewMemoryCache(&cache.MemoryConfig{})
+	if err != nil {
+		t.Fatalf("NewMemoryCache() error = %v", err)
+	}
+	t.Cleanup(func() { mc.Close() })
+	return NewKeyring(mc, newMemStore()), mc
+}
+
+func TestKeyring_CreateAndAuthenticate(t *testing.T) {
+	ctx := context.Background()
+	k, _ := newTestKeyring(t)
+
+	key, secret, err := k.Create(ctx, CreateOptions{Name: "game servers", Scopes: []Scope{ScopeRead}, Instances: []string{"game-*"}})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	got, err := k.Authenticate(ctx, secret)
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if got.ID != key.ID || !got.HasScope(ScopeRead) || got.HasScope(ScopeWrite) {
+		t.Errorf("Authenticate() = %+v, want read-only key %s", got, key.ID)
+	}
+	if !got.AllowsInstance("game-1") || got.AllowsInstance("lobby") {
+		t.Errorf("AllowsInstance() does not follow pattern game-*")
+	}
+
+	if _, err := k.Authenticate(ctx, secret+"x"); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Authenticate(wrong secret) error = %v, want ErrInvalidKey", err)
+	}
+	if _, err := k.Authenticate(ctx, ""); !errors.Is(err, ErrMissingKey) {
+		t.Errorf("Authenticate(\"\") error = %v, want ErrMissingKey", err)
+	}
+	if _, _, err := k.Create(ctx, CreateOptions{Scopes: []Scope{"owner"}, Instances: []string{"*"}}); !errors.Is(err, ErrInvalidScope) {
+		t.Errorf("Create(unknown scope) error = %v, want ErrInvalidScope", err)
+	}
+	if _, _, err := k.Create(ctx, CreateOptions{Scopes: []Scope{ScopeRead}}); !errors.Is(err, ErrNoInstances) {
+		t.Errorf("Create(no instances) error = %v, want ErrNoInstances", err)
+	}
+}
+
+func TestKeyring_RotateAndRevoke(t *testing.T) {
+	ctx := context.Background()
+	k, _ := newTestKeyring(t)
+
+	old, oldSecret, err := k.Create(ctx, CreateOptions{Scopes: []Scope{ScopeWrite}, Instances: []string{"*"}})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	// The old key keeps working during the grace period
+	key, secret, err := k.Rotate(ctx, old.ID, time.Hour)
+	if err != nil {
+		t.Fatalf("Rotate() error = %v", err)
+	}
+	if _, err := k.Authenticate(ctx, oldSecret); err != nil {
+		t.Errorf("Authenticate(old secret) during grace period error = %v", err)
+	}
+	if stored, _ := k.Get(ctx, old.ID); stored.ReplacedBy != key.ID || stored.ExpiresAt == nil {
+		t.Errorf("rotated key = %+v, want replaced by %s with an expiry", stored, key.ID)
+	}
+
+	// Without a grace period the old key stops working at once
+	if _, _, err := k.Rotate(ctx, key.ID, 0); err != nil {
+		t.Fatalf("Rotate() error = %v", err)
+	}
+	if _, err := k.Authenticate(ctx, secret); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Authenticate(rotated secret) error = %v, want ErrInvalidKey", err)
+	}
+
+	if _, err := k.Revoke(ctx, old.ID); err != nil {
+		t.Fatalf("Revoke() error = %v", err)
+	}
+	if _, err := k.Authenticate(ctx, oldSecret); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Authenticate(revoked secret) error = %v, want ErrInvalidKey", err)
+	}
+}
+
+func TestKeyring_ReplicaAndRootKey(t *testing.T) {
+	ctx := context.Background()
+	primary, mc := newTestKeyring(t)
+	_, secret, err := primary.Create(ctx, CreateOptions{Scopes: []Scope{ScopeRead}, Instances: []string{"*"}})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	// A keyring without a store reads keys from the shared cache
+	replica := NewKeyring(mc, nil)
+	replica.SetRootKey("root-secret")
+	if _, err := replica.Authenticate(ctx, secret); err != nil {
+		t.Errorf("replica Authenticate() error = %v", err)
+	}
+	root, err := replica.Authenticate(ctx, "root-secret")
+	if err != nil || root.ID != RootKeyID || !root.HasScope(ScopeAdmin) || !root.AllowsAllInstances() {
+		t.Errorf("Authenticate(root) = %+v, %v, want admin key for every instance", root, err)
+	}
+	if _, _, err := replica.Create(ctx, CreateOptions{Scopes: []Scope{ScopeRead}, Instances: []string{"*"}}); !errors.Is(err, ErrStoreUnavailable) {
+		t.Errorf("replica Create() error = %v, want ErrStoreUnavailable", err)
+	}
+}
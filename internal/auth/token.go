@@ -0,0 +1,179 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/birbparty/birb-nest/internal/instance"
+)
+
+const (
+	// TokenKeyID identifies keys derived from signed tokens
+	TokenKeyID = "token"
+
+	// MinSigningKeyLength is the shortest accepted token signing key, in bytes
+	MinSigningKeyLength = 32
+)
+
+// tokenHeader is the fixed JWT header of every token; only HS256 is accepted
+var tokenHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+var (
+	// ErrInvalidToken is returned for malformed tokens and bad signatures
+	ErrInvalidToken = errors.New("invalid token")
+
+	// ErrTokenExpired is returned for tokens past their expiry
+	ErrTokenExpired = errors.New("token expired")
+
+	// ErrSigningKeyTooShort is returned for signing keys under MinSigningKeyLength bytes
+	ErrSigningKeyTooShort = fmt.Errorf("token signing key must be at least %d bytes", MinSigningKeyLength)
+)
+
+// TokenClaims are the claims of a signed client token. Tokens are HS256
+// JWTs, so servers can also mint them with any JWT library.
+type TokenClaims struct {
+	Subject     string   `json:"sub,omitempty"` // e.g. the player ID, for logs
+	Instance    string   `json:"inst"`
+	KeyPrefixes []string `json:"pfx,omitempty"` // cache key prefixes; empty allows every key
+	Operations  []Scope  `json:"ops"`           // read and/or write
+	IssuedAt    int64    `json:"iat"`
+	ExpiresAt   int64    `json:"exp"`
+}
+
+// Validate checks that the claims name a single valid instance, grant only
+// read and write and expire. Instance IDs never contain glob characters, so
+// a token cannot widen into a pattern when its claim becomes Key.Instances.
+func (c *TokenClaims) Validate() error {
+	if c.Instance == "" {
+		return fmt.Errorf("%w: instance is required", ErrInvalidToken)
+	}
+	if err := instance.ValidateID(c.Instance); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if len(c.Operations) == 0 {
+		return fmt.Errorf("%w: at least one operation is required", ErrInvalidToken)
+	}
+	for _, op := range c.Operations {
+		if op != ScopeRead && op != ScopeWrite {
+			return fmt.Errorf("%w: operation %q", ErrInvalidToken, op)
+		}
+	}
+	if c.ExpiresAt == 0 {
+		return fmt.Errorf("%w: expiry is required", ErrInvalidToken)
+	}
+	return nil
+}
+
+// Key returns the access a token grants as a key limited to one instance
+func (c *TokenClaims) Key() *Key {
+	expiresAt := time.Unix(c.ExpiresAt, 0)
+	return &Key{
+		ID:          TokenKeyID,
+		Name:        c.Subject,
+		Scopes:      c.Operations,
+		Instances:   []string{c.Instance},
+		KeyPrefixes: c.KeyPrefixes,
+		CreatedAt:   time.Unix(c.IssuedAt, 0),
+		ExpiresAt:   &expiresAt,
+	}
+}
+
+// TokenSigner signs and verifies client tokens
+type TokenSigner struct {
+	keys [][]byte // the first signs; all verify, so old keys can be phased out
+}
+
+// NewTokenSigner creates a signer. Tokens are signed with signingKey and
+// verified with it or any of verifyKeys.
+func NewTokenSigner(signingKey []byte, verifyKeys ...[]byte) (*TokenSigner, error) {
+	keys := append([][]byte{signingKey}, verifyKeys...)
+	for _, key := range keys {
+		if len(key) < MinSigningKeyLength {
+			return nil, ErrSigningKeyTooShort
+		}
+	}
+	return &TokenSigner{keys: keys}, nil
+}
+
+// Sign returns a signed token for the claims
+func (s *TokenSigner) Sign(claims *TokenClaims) (string, error) {
+	if err := claims.Validate(); err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode token claims: %w", err)
+	}
+
+	signed := tokenHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sign(s.keys[0], signed)), nil
+}
+
+// Verify checks a token's signature and expiry and returns its claims
+func (s *TokenSigner) Verify(token string, now time.Time) (*TokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed", ErrInvalidToken)
+	}
+	if parts[0] != tokenHeader {
+		// Other JWT libraries may order or extend the header differently
+		var header struct {
+			Alg string `json:"alg"`
+		}
+		data, err := base64.RawURLEncoding.DecodeString(parts[0])
+		if err != nil || json.Unmarshal(data, &header) != nil || header.Alg != "HS256" {
+			return nil, fmt.Errorf("%w: unsupported header", ErrInvalidToken)
+		}
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed signature", ErrInvalidToken)
+	}
+
+	signed := parts[0] + "." + parts[1]
+	valid := false
+	for _, key := range s.keys {
+		if hmac.Equal(signature, sign(key, signed)) {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return nil, fmt.Errorf("%w: bad signature", ErrInvalidToken)
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed claims", ErrInvalidToken)
+	}
+	var claims TokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("%w: malformed claims", ErrInvalidToken)
+	}
+	if err := claims.Validate(); err != nil {
+		return nil, err
+	}
+	if now.Unix() >= claims.ExpiresAt {
+		return nil, ErrTokenExpired
+	}
+	return &claims, nil
+}
+
+// IsToken reports whether a credential has the shape of a signed token
+// rather than an API key secret
+func IsToken(credential string) bool {
+	return !strings.HasPrefix(credential, SecretPrefix) && strings.Count(credential, ".") == 2
+}
+
+// sign returns the HMAC-SHA256 of a token's header and claims
+func sign(key []byte, signed string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(signed))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	testSigningKey = []byte("0123456789abcdef0123456789abcdef")
+	testOldKey     = []byte("fedcba9876543210fedcba9876543210")
+)
+
+func testClaims(now time.Time) *TokenClaims {
+	return &TokenClaims{
+		Subject:     "player-42",
+		Instance:    "game-1",
+		KeyPrefixes: []string{"player:42:"},
+		Operations:  []Scope{ScopeRead, ScopeWrite},
+		IssuedAt:    now.Unix(),
+		ExpiresAt:   now.Add(15 * time.Minute).Unix(),
+	}
+}
+
+func TestTokenSigner_SignAndVerify(t *testing.T) {
+	signer, err := NewTokenSigner(testSigningKey, testOldKey)
+	if err != nil {
+		t.Fatalf("NewTokenSigner() error = %v", err)
+	}
+	now := time.Now()
+	token, err := signer.Sign(testClaims(now))
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+
+	claims, err := signer.Verify(token, now)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	key := claims.Key()
+	if !key.IsToken() || !key.AllowsInstance("game-1") || key.AllowsInstance("game-2") {
+		t.Errorf("Key() = %+v, want token key for game-1", key)
+	}
+	if !key.AllowsCacheKey("player:42:inventory") || key.AllowsCacheKey("player:43:inventory") {
+		t.Errorf("AllowsCacheKey() does not follow prefix player:42:")
+	}
+
+	if _, err := signer.Verify(token, now.Add(time.Hour)); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("Verify(expired) error = %v, want ErrTokenExpired", err)
+	}
+
+	// Tampered claims fail the signature check
+	parts := strings.Split(token, ".")
+	forged := strings.Replace(string(mustDecode(t, parts[1])), "game-1", "game-2", 1)
+	parts[1] = base64.RawURLEncoding.EncodeToString([]byte(forged))
+	if _, err := signer.Verify(strings.Join(parts, "."), now); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Verify(forged) error = %v, want ErrInvalidToken", err)
+	}
+
+	// Tokens signed with a previous key still verify; unknown keys do not
+	old, _ := NewTokenSigner(testOldKey)
+	oldToken, _ := old.Sign(testClaims(now))
+	if _, err := signer.Verify(oldToken, now); err != nil {
+		t.Errorf("Verify(previous key) error = %v", err)
+	}
+	if _, err := old.Verify(token, now); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Verify(unknown key) error = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestTokenSigner_Rejects(t *testing.T) {
+	if _, err := NewTokenSigner([]byte("short")); !errors.Is(err, ErrSigningKeyTooShort) {
+		t.Errorf("NewTokenSigner(short key) error = %v, want ErrSigningKeyTooShort", err)
+	}
+
+	signer, _ := NewTokenSigner(testSigningKey)
+	claims := testClaims(time.Now())
+	claims.Operations = []Scope{ScopeAdmin}
+	if _, err := signer.Sign(claims); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Sign(admin operation) error = %v, want ErrInvalidToken", err)
+	}
+
+	// Instance claims are literal IDs, never patterns
+	for _, pattern := range []string{"*", "game-*", "game-[12]", "game-?"} {
+		claims = testClaims(time.Now())
+		claims.Instance = pattern
+		if _, err := signer.Sign(claims); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("Sign(instance %q) error = %v, want ErrInvalidToken", pattern, err)
+		}
+
+		// Tokens minted elsewhere with a pattern fail verification
+		payload := `{"inst":"` + pattern + `","ops":["read"],"exp":` + fmt.Sprint(time.Now().Add(time.Hour).Unix()) + `}`
+		signed := tokenHeader + "." + base64.RawURLEncoding.EncodeToString([]byte(payload))
+		minted := signed + "." + base64.RawURLEncoding.EncodeToString(sign(testSigningKey, signed))
+		if _, err := signer.Verify(minted, time.Now()); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("Verify(instance %q) error = %v, want ErrInvalidToken", pattern, err)
+		}
+	}
+
+	// The "none" algorithm is never accepted
+	token, _ := signer.Sign(testClaims(time.Now()))
+	parts := strings.Split(token, ".")
+	none := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." + parts[1] + "."
+	if _, err := signer.Verify(none, time.Now()); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Verify(alg none) error = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestKeyring_Tokens(t *testing.T) {
+	ctx := context.Background()
+	k, _ := newTestKeyring(t)
+	signer, _ := NewTokenSigner(testSigningKey)
+	token, _ := signer.Sign(testClaims(time.Now()))
+
+	if _, err := k.Authenticate(ctx, token); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Authenticate(token) without signer error = %v, want ErrInvalidKey", err)
+	}
+	k.SetTokenSigner(signer)
+	key, err := k.Authenticate(ctx, token)
+	if err != nil || !key.IsToken() || key.Name != "player-42" {
+		t.Errorf("Authenticate(token) = %+v, %v", key, err)
+	}
+	if got := k.TokenInstance(token); got != "game-1" {
+		t.Errorf("TokenInstance() = %q, want game-1", got)
+	}
+}
+
+func mustDecode(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return b
+}
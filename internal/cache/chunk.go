@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/birbparty/birb-nest/internal/instance"
+)
+
+// ChunkKeyPrefix starts the keys the chunks of large values are stored under.
+// It is an internal key prefix, so clients cannot write chunks.
+const ChunkKeyPrefix = instance.InternalKeyPrefix + "chunk:"
+
+// ErrIncompleteValue is returned when a chunk of a value stored in chunks is missing
+var ErrIncompleteValue = errors.New("chunked value is incomplete")
+
+// Manifest describes a value stored in chunks. The manifest is stored under
+// the value's key and each chunk under a key of its own, so no single cache
+// or database write holds the whole value.
+type Manifest struct {
+	ID          string `json:"id"` // random, so chunks of successive writes do not collide
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	ChunkSize   int    `json:"chunk_size"`
+	Chunks      int    `json:"chunks"`
+}
+
+// NewManifest creates an empty manifest with a random ID
+func NewManifest(contentType string, chunkSize int) (*Manifest, error) {
+	id := make([]byte, 12)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("failed to generate manifest ID: %w", err)
+	}
+	return &Manifest{ID: hex.EncodeToString(id), ContentType: contentType, ChunkSize: chunkSize}, nil
+}
+
+// ChunkKey returns the key of a chunk
+func (m *Manifest) ChunkKey(index int) string {
+	return fmt.Sprintf("%s%s:%d", ChunkKeyPrefix, m.ID, index)
+}
+
+// ChunkKeys returns the keys of all chunks in order
+func (m *Manifest) ChunkKeys() []string {
+	keys := make([]string, m.Chunks)
+	for i := range keys {
+		keys[i] = m.ChunkKey(i)
+	}
+	return keys
+}
+
+// Encode returns the manifest as it is cached and persisted
+func (m *Manifest) Encode() []byte {
+	data, _ := json.Marshal(m)
+	return EncodeValue(ContentTypeManifest, data)
+}
+
+// ParseManifest returns the manifest of a value stored in chunks, or nil if
+// the value is stored whole
+func ParseManifest(value []byte) *Manifest {
+	contentType, _, body := ParseValue(value)
+	if contentType != ContentTypeManifest {
+		return nil
+	}
+	var m Manifest
+	if err := json.Unmarshal(body, &m); err != nil || m.ID == "" || m.ChunkSize <= 0 {
+		return nil
+	}
+	return &m
+}
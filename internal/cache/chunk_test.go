@@ -0,0 +1,48 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestManifest_RoundTrip(t *testing.T) {
+	m, err := NewManifest("video/mp4", 1024)
+	if err != nil {
+		t.Fatalf("NewManifest() error = %v", err)
+	}
+	m.Size, m.Chunks = 2500, 3
+
+	parsed := ParseManifest(m.Encode())
+	if parsed == nil || *parsed != *m {
+		t.Fatalf("ParseManifest() = %+v, want %+v", parsed, m)
+	}
+	keys := parsed.ChunkKeys()
+	if len(keys) != 3 || keys[2] != ChunkKeyPrefix+m.ID+":2" {
+		t.Errorf("ChunkKeys() = %v, want 3 keys under %s", keys, ChunkKeyPrefix)
+	}
+
+	if other, _ := NewManifest("video/mp4", 1024); other.ID == m.ID {
+		t.Error("NewManifest() reused an ID")
+	}
+}
+
+func TestParseManifest_NotManifests(t *testing.T) {
+	for name, value := range map[string][]byte{
+		"json":          []byte(`{"id":"x","chunk_size":1}`),
+		"binary":        EncodeValue(ContentTypeBinary, append(append([]byte{}, manifestPrefix...), `{"id":"x","chunk_size":1}`...)),
+		"typed":         EncodeValue("text/plain", []byte("hello")),
+		"bad manifest":  EncodeValue(ContentTypeManifest, []byte(`{"id":""}`)),
+		"not even JSON": EncodeValue(ContentTypeManifest, []byte("nope")),
+		"empty":         nil,
+	} {
+		if m := ParseManifest(value); m != nil {
+			t.Errorf("ParseManifest(%s) = %+v, want nil", name, m)
+		}
+	}
+
+	// Opaque bytes that look like a manifest keep their content type
+	lookalike := append(append([]byte{}, manifestPrefix...), 'x')
+	if contentType, body, _ := DecodeValue(EncodeValue("", lookalike)); contentType != ContentTypeBinary || !strings.HasPrefix(string(body), string(manifestPrefix)) {
+		t.Errorf("DecodeValue() of manifest lookalike = %q, %q", contentType, body)
+	}
+}
@@ -0,0 +1,135 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Content encodings of compressed values; the names match HTTP's
+// Content-Encoding so compressed bodies can be served as is
+const (
+	EncodingGzip = "gzip"
+	EncodingZstd = "zstd"
+)
+
+// ErrUnknownEncoding is returned for content encodings that are not supported
+var ErrUnknownEncoding = errors.New("unknown content encoding")
+
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+	zstdErr     error
+)
+
+// zstdCodec returns the shared zstd encoder and decoder, which are safe for
+// concurrent EncodeAll and DecodeAll calls
+func zstdCodec() (*zstd.Encoder, *zstd.Decoder, error) {
+	zstdOnce.Do(func() {
+		zstdEncoder, zstdErr = zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+		if zstdErr != nil {
+			return
+		}
+		zstdDecoder, zstdErr = zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
+	})
+	return zstdEncoder, zstdDecoder, zstdErr
+}
+
+// Compressor compresses values of at least threshold bytes before they are
+// cached and persisted. Values that do not get smaller are stored as is.
+type Compressor struct {
+	encoding  string
+	threshold int
+}
+
+// NewCompressor creates a compressor for a content encoding
+func NewCompressor(encoding string, threshold int) (*Compressor, error) {
+	switch encoding {
+	case EncodingGzip, EncodingZstd:
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownEncoding, encoding)
+	}
+	if _, _, err := zstdCodec(); err != nil {
+		return nil, fmt.Errorf("failed to create zstd codec: %w", err)
+	}
+	return &Compressor{encoding: encoding, threshold: threshold}, nil
+}
+
+// Encoding returns the content encoding of compressed values
+func (c *Compressor) Encoding() string {
+	return c.encoding
+}
+
+// Encode returns a value encoded for storage, compressed when it is large
+// enough and compression makes it smaller. compressed is the size of the
+// compressed body, or 0 if the value was stored as is. Manifests are never
+// compressed.
+func (c *Compressor) Encode(contentType string, body []byte) (value []byte, compressed int) {
+	if c == nil || len(body) < c.threshold || len(body) == 0 || contentType == ContentTypeManifest {
+		return EncodeValue(contentType, body), 0
+	}
+	out, err := Compress(c.encoding, body)
+	if err != nil || len(out) >= len(body) {
+		return EncodeValue(contentType, body), 0
+	}
+	contentType = contentTypeOf(contentType, json.Valid(body))
+	return EncodeCompressed(contentType, c.encoding, out), len(out)
+}
+
+// Compress compresses a body with a content encoding
+func Compress(encoding string, body []byte) ([]byte, error) {
+	switch encoding {
+	case EncodingZstd:
+		encoder, _, err := zstdCodec()
+		if err != nil {
+			return nil, err
+		}
+		return encoder.EncodeAll(body, make([]byte, 0, len(body)/2)), nil
+	case EncodingGzip:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(body); err != nil {
+			return nil, fmt.Errorf("failed to gzip value: %w", err)
+		}
+		if err := w.Close(); err != nil {
+			return nil, fmt.Errorf("failed to gzip value: %w", err)
+		}
+		return buf.Bytes(), nil
+	}
+	return nil, fmt.Errorf("%w %q", ErrUnknownEncoding, encoding)
+}
+
+// Decompress decompresses a body compressed with a content encoding
+func Decompress(encoding string, body []byte) ([]byte, error) {
+	switch encoding {
+	case EncodingZstd:
+		_, decoder, err := zstdCodec()
+		if err != nil {
+			return nil, err
+		}
+		out, err := decoder.DecodeAll(body, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress zstd value: %w", err)
+		}
+		return out, nil
+	case EncodingGzip:
+		r, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress gzip value: %w", err)
+		}
+		defer r.Close()
+		out, err := io.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress gzip value: %w", err)
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("%w %q", ErrUnknownEncoding, encoding)
+}
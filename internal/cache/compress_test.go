@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"testing"
+)
+
+func TestCompressor_Encode(t *testing.T) {
+	large := bytes.Repeat([]byte(`{"player":"birb","score":42},`), 100)
+	large = append(append([]byte{'['}, large[:len(large)-1]...), ']')
+	random := make([]byte, 8192)
+	rand.Read(random)
+
+	for _, encoding := range []string{EncodingGzip, EncodingZstd} {
+		t.Run(encoding, func(t *testing.T) {
+			c, err := NewCompressor(encoding, 1024)
+			if err != nil {
+				t.Fatalf("NewCompressor() error = %v", err)
+			}
+
+			value, compressed := c.Encode("", large)
+			if compressed == 0 || compressed >= len(large) {
+				t.Fatalf("Encode() compressed %d bytes to %d, want smaller", len(large), compressed)
+			}
+			if contentType, gotEncoding, _ := ParseValue(value); contentType != ContentTypeJSON || gotEncoding != encoding {
+				t.Errorf("ParseValue() = %q, %q, want %q, %q", contentType, gotEncoding, ContentTypeJSON, encoding)
+			}
+			contentType, body, err := DecodeValue(value)
+			if err != nil || contentType != ContentTypeJSON || !bytes.Equal(body, large) {
+				t.Errorf("DecodeValue() = %q, %d bytes, %v, want the original JSON", contentType, len(body), err)
+			}
+
+			// Small and incompressible values are stored as is
+			if value, compressed := c.Encode("text/plain", []byte("small")); compressed != 0 || !bytes.Equal(value, EncodeValue("text/plain", []byte("small"))) {
+				t.Errorf("Encode() of a small value = %q, %d, want it uncompressed", value, compressed)
+			}
+			if value, compressed := c.Encode(ContentTypeBinary, random); compressed != 0 || !bytes.Equal(value, random) {
+				t.Errorf("Encode() of random bytes compressed them to %d bytes, want them as is", compressed)
+			}
+		})
+	}
+
+	var disabled *Compressor
+	if value, compressed := disabled.Encode("", large); compressed != 0 || !bytes.Equal(value, large) {
+		t.Error("Encode() on a nil compressor changed the value")
+	}
+}
+
+func TestNewCompressor_UnknownEncoding(t *testing.T) {
+	if _, err := NewCompressor("br", 0); !errors.Is(err, ErrUnknownEncoding) {
+		t.Errorf("NewCompressor(br) error = %v, want ErrUnknownEncoding", err)
+	}
+	if _, _, err := DecodeValue(EncodeCompressed(ContentTypeJSON, "br", []byte("x"))); !errors.Is(err, ErrUnknownEncoding) {
+		t.Errorf("DecodeValue() of an unknown encoding error = %v, want ErrUnknownEncoding", err)
+	}
+}
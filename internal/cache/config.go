@@ -4,17 +4,36 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
+// Redis deployment modes
+const (
+	ModeStandalone = "standalone"
+	ModeSentinel   = "sentinel"
+	ModeCluster    = "cluster"
+)
+
 // Config holds cache configuration
 type Config struct {
+	// Redis deployment mode: standalone, sentinel or cluster
+	Mode string
+
 	// Redis connection settings
 	Host     string
 	Port     int
 	Password string
 	DB       int
 
+	// Sentinel settings (sentinel mode only)
+	SentinelMasterName string
+	SentinelAddrs      []string
+	SentinelPassword   string
+
+	// Cluster settings (cluster mode only)
+	ClusterAddrs []string
+
 	// Connection pool settings
 	MaxRetries      int
 	MinRetryBackoff time.Duration
@@ -57,22 +76,33 @@ func NewConfigFromEnv() (*Config, error) {
 		return nil, fmt.Errorf("invalid CACHE_DEFAULT_TTL: %w", err)
 	}
 
-	return &Config{
-		Host:            getEnvOrDefault("REDIS_HOST", "localhost"),
-		Port:            port,
-		Password:        os.Getenv("REDIS_PASSWORD"),
-		DB:              db,
-		MaxRetries:      3,
-		MinRetryBackoff: 8 * time.Millisecond,
-		MaxRetryBackoff: 512 * time.Millisecond,
-		DialTimeout:     5 * time.Second,
-		ReadTimeout:     3 * time.Second,
-		WriteTimeout:    3 * time.Second,
-		PoolSize:        poolSize,
-		MinIdleConns:    minIdleConns,
-		MaxIdleTime:     5 * time.Minute,
-		DefaultTTL:      defaultTTL,
-	}, nil
+	cfg := &Config{
+		Mode:               getEnvOrDefault("REDIS_MODE", ModeStandalone),
+		Host:               getEnvOrDefault("REDIS_HOST", "localhost"),
+		Port:               port,
+		Password:           os.Getenv("REDIS_PASSWORD"),
+		DB:                 db,
+		SentinelMasterName: os.Getenv("REDIS_SENTINEL_MASTER"),
+		SentinelAddrs:      SplitAddrs(os.Getenv("REDIS_SENTINEL_ADDRS")),
+		SentinelPassword:   os.Getenv("REDIS_SENTINEL_PASSWORD"),
+		ClusterAddrs:       SplitAddrs(os.Getenv("REDIS_CLUSTER_ADDRS")),
+		MaxRetries:         3,
+		MinRetryBackoff:    8 * time.Millisecond,
+		MaxRetryBackoff:    512 * time.Millisecond,
+		DialTimeout:        5 * time.Second,
+		ReadTimeout:        3 * time.Second,
+		WriteTimeout:       3 * time.Second,
+		PoolSize:           poolSize,
+		MinIdleConns:       minIdleConns,
+		MaxIdleTime:        5 * time.Minute,
+		DefaultTTL:         defaultTTL,
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
 }
 
 // Address returns the Redis server address
@@ -80,6 +110,44 @@ func (c *Config) Address() string {
 	return fmt.Sprintf("%s:%d", c.Host, c.Port)
 }
 
+// Validate checks that the settings required by the configured mode are present
+func (c *Config) Validate() error {
+	switch c.Mode {
+	case "", ModeStandalone:
+		return nil
+	case ModeSentinel:
+		if c.SentinelMasterName == "" {
+			return fmt.Errorf("REDIS_SENTINEL_MASTER is required in sentinel mode")
+		}
+		if len(c.SentinelAddrs) == 0 {
+			return fmt.Errorf("REDIS_SENTINEL_ADDRS is required in sentinel mode")
+		}
+		return nil
+	case ModeCluster:
+		if len(c.ClusterAddrs) == 0 {
+			return fmt.Errorf("REDIS_CLUSTER_ADDRS is required in cluster mode")
+		}
+		if c.DB != 0 {
+			return fmt.Errorf("REDIS_DB must be 0 in cluster mode")
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid REDIS_MODE: %s", c.Mode)
+	}
+}
+
+// SplitAddrs parses a comma-separated list of host:port addresses or other
+// values, trimming spaces and skipping empty entries
+func SplitAddrs(s string) []string {
+	var addrs []string
+	for _, addr := range strings.Split(s, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
 func getEnvOrDefault(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
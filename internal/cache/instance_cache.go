@@ -2,6 +2,9 @@ package cache
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/birbparty/birb-nest/internal/instance"
@@ -122,20 +125,29 @@ func (ic *InstanceCache) KeyBuilder() *instance.KeyBuilder {
 }
 
 // Scan performs a pattern-based scan for keys belonging to this instance
-// The pattern parameter is applied after the instance prefix
+// The pattern parameter is applied after the instance cache prefix and the
+// returned keys have that prefix stripped, so they can be passed back to Get
 func (ic *InstanceCache) Scan(ctx context.Context, pattern string, count int) ([]string, error) {
-	// For Redis SCAN operations, we need to handle this at the Redis client level
-	// This is a placeholder that would need to be implemented in the Redis client
-	// For now, we'll just build the pattern
-	scanPattern := ic.keyBuilder.BuildPattern(pattern)
+	scanner, ok := ic.client.(Scanner)
+	if !ok {
+		return nil, NewCacheError("underlying cache does not support scan", false)
+	}
 
-	// This would need to be implemented in the underlying cache client
-	// as it requires Redis-specific SCAN operations
-	_ = scanPattern
-	_ = count
+	if pattern == "" {
+		pattern = "*"
+	}
+	prefix := ic.keyBuilder.CacheKey("")
+	scanPattern := prefix + pattern
 
-	// Return empty for now - actual implementation would be in redis.go
-	return []string{}, nil
+	keys, err := scanner.Scan(ctx, scanPattern, count)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, key := range keys {
+		keys[i] = strings.TrimPrefix(key, prefix)
+	}
+	return keys, nil
 }
 
 // Context-aware methods that extract instance from context
@@ -188,9 +200,21 @@ func (ic *InstanceCache) ExistsWithContext(ctx context.Context, key string) (boo
 	return ic.client.Exists(ctx, instanceKey)
 }
 
+// ErrSealing is returned when a value cannot be encrypted or decrypted
+var ErrSealing = errors.New("failed to encrypt or decrypt value")
+
+// Sealer encrypts values before they are cached and decrypts them on read.
+// Values are bound to the cache key they are stored under. Seal returns values
+// that are already sealed unchanged, and Open values that were never sealed.
+type Sealer interface {
+	Seal(ctx context.Context, key string, value []byte) ([]byte, error)
+	Open(ctx context.Context, key string, value []byte) ([]byte, error)
+}
+
 // ContextCache provides a fully context-aware cache interface
 type ContextCache struct {
 	client Cache
+	sealer Sealer // nil stores values as given
 }
 
 // NewContextCache creates a new context-aware cache
@@ -200,6 +224,35 @@ func NewContextCache(client Cache) *ContextCache {
 	}
 }
 
+// SetSealer encrypts values on Set and decrypts them on Get
+func (cc *ContextCache) SetSealer(sealer Sealer) {
+	cc.sealer = sealer
+}
+
+// seal encrypts a value when a sealer is set
+func (cc *ContextCache) seal(ctx context.Context, key string, value []byte) ([]byte, error) {
+	if cc.sealer == nil {
+		return value, nil
+	}
+	sealed, err := cc.sealer.Seal(ctx, key, value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrSealing, err)
+	}
+	return sealed, nil
+}
+
+// open decrypts a value when a sealer is set
+func (cc *ContextCache) open(ctx context.Context, key string, value []byte) ([]byte, error) {
+	if cc.sealer == nil {
+		return value, nil
+	}
+	opened, err := cc.sealer.Open(ctx, key, value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrSealing, err)
+	}
+	return opened, nil
+}
+
 // Get retrieves a value using instance ID from context
 func (cc *ContextCache) Get(ctx context.Context, key string) ([]byte, error) {
 	instanceID := instance.ExtractInstanceID(ctx)
@@ -208,10 +261,15 @@ func (cc *ContextCache) Get(ctx context.Context, key string) ([]byte, error) {
 	}
 	kb := instance.NewKeyBuilder(instanceID)
 	instanceKey := kb.CacheKey(key)
-	return cc.client.Get(ctx, instanceKey)
+	value, err := cc.client.Get(ctx, instanceKey)
+	if err != nil {
+		return nil, err
+	}
+	return cc.open(ctx, key, value)
 }
 
-// Set stores a value using instance ID from context
+// Set stores a value using instance ID from context, counting it towards the
+// instance's usage when the backend tracks usage
 func (cc *ContextCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
 	instanceID := instance.ExtractInstanceID(ctx)
 	if instanceID == "" {
@@ -219,6 +277,13 @@ func (cc *ContextCache) Set(ctx context.Context, key string, value []byte, ttl t
 	}
 	kb := instance.NewKeyBuilder(instanceID)
 	instanceKey := kb.CacheKey(key)
+	value, err := cc.seal(ctx, key, value)
+	if err != nil {
+		return err
+	}
+	if tracker, ok := cc.client.(UsageTracker); ok {
+		return tracker.SetTracked(ctx, instanceKey, value, ttl, kb.UsageKey())
+	}
 	return cc.client.Set(ctx, instanceKey, value, ttl)
 }
 
@@ -230,6 +295,9 @@ func (cc *ContextCache) Delete(ctx context.Context, key string) error {
 	}
 	kb := instance.NewKeyBuilder(instanceID)
 	instanceKey := kb.CacheKey(key)
+	if tracker, ok := cc.client.(UsageTracker); ok {
+		return tracker.DeleteTracked(ctx, instanceKey, kb.UsageKey())
+	}
 	return cc.client.Delete(ctx, instanceKey)
 }
 
@@ -281,7 +349,11 @@ func (cc *ContextCache) GetMultiple(ctx context.Context, keys []string) (map[str
 	transformedResults := make(map[string][]byte)
 	for instanceKey, value := range results {
 		if originalKey, ok := keyMap[instanceKey]; ok {
-			transformedResults[originalKey] = value
+			opened, err := cc.open(ctx, originalKey, value)
+			if err != nil {
+				return nil, err
+			}
+			transformedResults[originalKey] = opened
 		}
 	}
 
@@ -299,8 +371,12 @@ func (cc *ContextCache) SetMultiple(ctx context.Context, items map[string][]byte
 	// Transform items to use instance-aware keys
 	instanceItems := make(map[string][]byte)
 	for key, value := range items {
+		sealed, err := cc.seal(ctx, key, value)
+		if err != nil {
+			return err
+		}
 		instanceKey := kb.CacheKey(key)
-		instanceItems[instanceKey] = value
+		instanceItems[instanceKey] = sealed
 	}
 
 	return cc.client.SetMultiple(ctx, instanceItems, ttl)
@@ -2,106 +2,27 @@ package cache
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
-	"time"
-)
-
-// mockCache is a mock implementation of the Cache interface for testing
-type mockCache struct {
-	data   map[string][]byte
-	closed bool
-}
-
-func newMockCache() *mockCache {
-	return &mockCache{
-		data: make(map[string][]byte),
-	}
-}
-
-func (m *mockCache) Get(ctx context.Context, key string) ([]byte, error) {
-	if m.closed {
-		return nil, ErrCacheClosed
-	}
-	value, ok := m.data[key]
-	if !ok {
-		return nil, ErrKeyNotFound
-	}
-	return value, nil
-}
-
-func (m *mockCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
-	if m.closed {
-		return ErrCacheClosed
-	}
-	m.data[key] = value
-	return nil
-}
-
-func (m *mockCache) Delete(ctx context.Context, key string) error {
-	if m.closed {
-		return ErrCacheClosed
-	}
-	delete(m.data, key)
-	return nil
-}
-
-func (m *mockCache) Exists(ctx context.Context, key string) (bool, error) {
-	if m.closed {
-		return false, ErrCacheClosed
-	}
-	_, ok := m.data[key]
-	return ok, nil
-}
 
-func (m *mockCache) GetMultiple(ctx context.Context, keys []string) (map[string][]byte, error) {
-	if m.closed {
-		return nil, ErrCacheClosed
-	}
-	results := make(map[string][]byte)
-	for _, key := range keys {
-		if value, ok := m.data[key]; ok {
-			results[key] = value
-		}
-	}
-	return results, nil
-}
-
-func (m *mockCache) SetMultiple(ctx context.Context, items map[string][]byte, ttl time.Duration) error {
-	if m.closed {
-		return ErrCacheClosed
-	}
-	for key, value := range items {
-		m.data[key] = value
-	}
-	return nil
-}
-
-func (m *mockCache) DeleteMultiple(ctx context.Context, keys []string) error {
-	if m.closed {
-		return ErrCacheClosed
-	}
-	for _, key := range keys {
-		delete(m.data, key)
-	}
-	return nil
-}
+	"github.com/birbparty/birb-nest/internal/instance"
+)
 
-func (m *mockCache) Ping(ctx context.Context) error {
-	if m.closed {
-		return ErrCacheClosed
+// newTestCache returns an in-memory cache backing the instance cache under test
+func newTestCache(t *testing.T) *MemoryCache {
+	t.Helper()
+	mc, err := NewMemoryCache(&MemoryConfig{})
+	if err != nil {
+		t.Fatalf("NewMemoryCache() error = %v", err)
 	}
-	return nil
-}
-
-func (m *mockCache) Close() error {
-	m.closed = true
-	return nil
+	return mc
 }
 
 func TestInstanceCache_BasicOperations(t *testing.T) {
 	ctx := context.Background()
-	mock := newMockCache()
+	mock := newTestCache(t)
 	instanceID := "inst_1719432000_abc12345"
 	ic := NewInstanceCache(mock, instanceID)
 
@@ -116,9 +37,9 @@ func TestInstanceCache_BasicOperations(t *testing.T) {
 		}
 
 		// Verify key was transformed
-		expectedKey := fmt.Sprintf("instance:%s:cache:%s", instanceID, key)
-		if _, ok := mock.data[expectedKey]; !ok {
-			t.Errorf("Expected key %s not found in mock cache", expectedKey)
+		expectedKey := fmt.Sprintf("instance:{%s}:cache:%s", instanceID, key)
+		if ok, _ := mock.Exists(ctx, expectedKey); !ok {
+			t.Errorf("Expected key %s not found in backing cache", expectedKey)
 		}
 
 		// Get value
@@ -181,7 +102,7 @@ func TestInstanceCache_BasicOperations(t *testing.T) {
 
 func TestInstanceCache_MultipleOperations(t *testing.T) {
 	ctx := context.Background()
-	mock := newMockCache()
+	mock := newTestCache(t)
 	instanceID := "inst_123"
 	ic := NewInstanceCache(mock, instanceID)
 
@@ -258,7 +179,7 @@ func TestInstanceCache_MultipleOperations(t *testing.T) {
 
 func TestInstanceCache_EmptyInstance(t *testing.T) {
 	ctx := context.Background()
-	mock := newMockCache()
+	mock := newTestCache(t)
 	ic := NewInstanceCache(mock, "") // Empty instance ID
 
 	// Test that keys are not prefixed
@@ -273,20 +194,20 @@ func TestInstanceCache_EmptyInstance(t *testing.T) {
 
 		// Verify key was NOT transformed (should be cache:testkey)
 		expectedKey := "cache:testkey"
-		if _, ok := mock.data[expectedKey]; !ok {
-			t.Errorf("Expected key %s not found in mock cache", expectedKey)
+		if ok, _ := mock.Exists(ctx, expectedKey); !ok {
+			t.Errorf("Expected key %s not found in backing cache", expectedKey)
 		}
 
 		// Verify instance prefix was NOT added
 		instanceKey := fmt.Sprintf("instance::cache:%s", key)
-		if _, ok := mock.data[instanceKey]; ok {
+		if ok, _ := mock.Exists(ctx, instanceKey); ok {
 			t.Error("Found instance-prefixed key when instance ID is empty")
 		}
 	})
 }
 
 func TestInstanceCache_UtilityMethods(t *testing.T) {
-	mock := newMockCache()
+	mock := newTestCache(t)
 	instanceID := "inst_456"
 	ic := NewInstanceCache(mock, instanceID)
 
@@ -321,7 +242,7 @@ func TestInstanceCache_UtilityMethods(t *testing.T) {
 
 func TestInstanceCache_ConnectionMethods(t *testing.T) {
 	ctx := context.Background()
-	mock := newMockCache()
+	mock := newTestCache(t)
 	ic := NewInstanceCache(mock, "inst_789")
 
 	t.Run("Ping", func(t *testing.T) {
@@ -344,7 +265,7 @@ func TestInstanceCache_ConnectionMethods(t *testing.T) {
 
 func TestInstanceCache_IsolationBetweenInstances(t *testing.T) {
 	ctx := context.Background()
-	mock := newMockCache()
+	mock := newTestCache(t)
 
 	// Create two instance caches with different instance IDs
 	ic1 := NewInstanceCache(mock, "inst_game1")
@@ -379,15 +300,15 @@ func TestInstanceCache_IsolationBetweenInstances(t *testing.T) {
 		t.Errorf("ic2.Get() = %v, want %v", string(got2), string(value2))
 	}
 
-	// Verify the actual keys in mock cache are different
-	key1 := fmt.Sprintf("instance:inst_game1:cache:%s", key)
-	key2 := fmt.Sprintf("instance:inst_game2:cache:%s", key)
+	// Verify the actual keys in the backing cache are different
+	key1 := fmt.Sprintf("instance:{inst_game1}:cache:%s", key)
+	key2 := fmt.Sprintf("instance:{inst_game2}:cache:%s", key)
 
-	if _, ok := mock.data[key1]; !ok {
-		t.Errorf("Key %s not found in mock cache", key1)
+	if ok, _ := mock.Exists(ctx, key1); !ok {
+		t.Errorf("Key %s not found in backing cache", key1)
 	}
-	if _, ok := mock.data[key2]; !ok {
-		t.Errorf("Key %s not found in mock cache", key2)
+	if ok, _ := mock.Exists(ctx, key2); !ok {
+		t.Errorf("Key %s not found in backing cache", key2)
 	}
 
 	// Delete from one instance shouldn't affect the other
@@ -407,7 +328,7 @@ func TestInstanceCache_IsolationBetweenInstances(t *testing.T) {
 
 func TestInstanceCache_RealWorldScenarios(t *testing.T) {
 	ctx := context.Background()
-	mock := newMockCache()
+	mock := newTestCache(t)
 
 	// Scenario: WebAPI creates instances for different game sessions
 	t.Run("Game session isolation", func(t *testing.T) {
@@ -446,3 +367,97 @@ func TestInstanceCache_RealWorldScenarios(t *testing.T) {
 		}
 	})
 }
+
+func TestInstanceCache_Scan(t *testing.T) {
+	ctx := context.Background()
+	mock := newTestCache(t)
+	ic1 := NewInstanceCache(mock, "inst_game1")
+	ic2 := NewInstanceCache(mock, "inst_game2")
+
+	ic1.Set(ctx, "player:1", []byte("a"), 0)
+	ic1.Set(ctx, "player:2", []byte("b"), 0)
+	ic1.Set(ctx, "world", []byte("c"), 0)
+	ic2.Set(ctx, "player:3", []byte("d"), 0)
+
+	keys, err := ic1.Scan(ctx, "player:*", 100)
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	if len(keys) != 2 {
+		t.Fatalf("Scan() returned %d keys, want 2: %v", len(keys), keys)
+	}
+
+	// Returned keys can be passed straight back to Get
+	for _, key := range keys {
+		if _, err := ic1.Get(ctx, key); err != nil {
+			t.Errorf("Get(%q) error = %v", key, err)
+		}
+	}
+
+	all, err := ic1.Scan(ctx, "", 100)
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	if len(all) != 3 {
+		t.Errorf("Scan(\"\") returned %d keys, want 3: %v", len(all), all)
+	}
+}
+
+// prefixSealer marks sealed values with a prefix naming their key and fails
+// to open "bad:" values and values of other keys
+type prefixSealer struct{}
+
+func (prefixSealer) Seal(ctx context.Context, key string, value []byte) ([]byte, error) {
+	if strings.HasPrefix(string(value), "sealed:") {
+		return value, nil
+	}
+	return append([]byte("sealed:"+key+":"), value...), nil
+}
+
+func (prefixSealer) Open(ctx context.Context, key string, value []byte) ([]byte, error) {
+	if strings.HasPrefix(string(value), "bad:") {
+		return nil, errors.New("cannot open")
+	}
+	if !strings.HasPrefix(string(value), "sealed:") {
+		return value, nil
+	}
+	plain, ok := strings.CutPrefix(string(value), "sealed:"+key+":")
+	if !ok {
+		return nil, errors.New("sealed for another key")
+	}
+	return []byte(plain), nil
+}
+
+func TestContextCache_Sealer(t *testing.T) {
+	mc := newTestCache(t)
+	cc := NewContextCache(mc)
+	cc.SetSealer(prefixSealer{})
+	ctx := instance.InjectContext(context.Background(), instance.NewContext("game-1"))
+	kb := instance.NewKeyBuilder("game-1")
+
+	if err := cc.Set(ctx, "a", []byte("secret"), 0); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if stored, _ := mc.Get(ctx, kb.CacheKey("a")); string(stored) != "sealed:a:secret" {
+		t.Errorf("stored value = %q, want it sealed", stored)
+	}
+	if got, err := cc.Get(ctx, "a"); err != nil || string(got) != "secret" {
+		t.Errorf("Get() = %q, %v, want secret", got, err)
+	}
+
+	cc.SetMultiple(ctx, map[string][]byte{"b": []byte("other")}, 0)
+	got, err := cc.GetMultiple(ctx, []string{"a", "b"})
+	if err != nil || string(got["a"]) != "secret" || string(got["b"]) != "other" {
+		t.Errorf("GetMultiple() = %q, %v", got, err)
+	}
+
+	mc.Set(ctx, kb.CacheKey("broken"), []byte("bad:value"), 0)
+	if _, err := cc.Get(ctx, "broken"); !errors.Is(err, ErrSealing) {
+		t.Errorf("Get() of a value that cannot be opened error = %v, want ErrSealing", err)
+	}
+	stored, _ := mc.Get(ctx, kb.CacheKey("a"))
+	mc.Set(ctx, kb.CacheKey("moved"), stored, 0)
+	if _, err := cc.Get(ctx, "moved"); !errors.Is(err, ErrSealing) {
+		t.Errorf("Get() of a value sealed for another key error = %v, want ErrSealing", err)
+	}
+}
@@ -35,6 +35,62 @@ type Cache interface {
 	Close() error
 }
 
+// Scanner is implemented by caches that can enumerate keys by pattern
+type Scanner interface {
+	// Scan returns all keys matching a Redis-style glob pattern.
+	// count is a batch-size hint for backends that iterate incrementally.
+	Scan(ctx context.Context, pattern string, count int) ([]string, error)
+}
+
+// PubSub is implemented by caches that can broadcast messages between nodes
+type PubSub interface {
+	// Publish sends a message to every subscriber of a channel
+	Publish(ctx context.Context, channel string, message []byte) error
+
+	// Subscribe delivers messages on a channel to handler until ctx is done.
+	// Delivery is at most once and messages may arrive out of order.
+	Subscribe(ctx context.Context, channel string, handler func(message []byte)) error
+}
+
+// CompareAndSwapper is implemented by caches that can replace a value atomically
+type CompareAndSwapper interface {
+	// CompareAndSwap sets key to value only if it currently holds old and
+	// reports whether the swap happened
+	CompareAndSwap(ctx context.Context, key string, old, value []byte, ttl time.Duration) (bool, error)
+}
+
+// ExclusiveSetter is implemented by caches that can create a key atomically
+type ExclusiveSetter interface {
+	// SetNX sets key to value only if the key does not exist and reports
+	// whether it was set
+	SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+}
+
+// UsageTracker is implemented by caches that can count keys and bytes per
+// usage key as values are written. The value key and its usage key must map
+// to the same Redis Cluster slot.
+type UsageTracker interface {
+	// SetTracked stores a value and adjusts the counts held in usageKey
+	SetTracked(ctx context.Context, key string, value []byte, ttl time.Duration, usageKey string) error
+
+	// DeleteTracked removes a value and adjusts the counts held in usageKey
+	DeleteTracked(ctx context.Context, key, usageKey string) error
+
+	// Usage returns the key and byte counts held in usageKey
+	Usage(ctx context.Context, usageKey string) (keys, bytes int64, err error)
+
+	// SetUsage overwrites the counts held in usageKey
+	SetUsage(ctx context.Context, usageKey string, keys, bytes int64) error
+}
+
+// TokenBucket is implemented by caches that can run rate limiting token buckets atomically
+type TokenBucket interface {
+	// TakeToken takes a token from the bucket at key, which holds up to burst
+	// tokens and refills completely over period. It reports whether a token
+	// was taken and how many tokens are left.
+	TakeToken(ctx context.Context, key string, burst int64, period time.Duration) (bool, float64, error)
+}
+
 // Common errors
 var (
 	ErrKeyNotFound = NewCacheError("key not found", true)
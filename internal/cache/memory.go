@@ -0,0 +1,978 @@
+package cache
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Eviction policies for the in-memory cache
+const (
+	EvictionLRU = "lru"
+	EvictionLFU = "lfu"
+)
+
+// entryOverhead approximates the bookkeeping bytes held per entry on top of key and value
+const entryOverhead = 96
+
+// MemoryConfig holds in-memory cache configuration
+type MemoryConfig struct {
+	// MaxMemoryBytes caps the approximate memory used by keys and values (0 = unlimited)
+	MaxMemoryBytes int64
+	// MaxEntries caps the number of stored keys (0 = unlimited)
+	MaxEntries int
+	// EvictionPolicy selects which entry is dropped when a limit is hit: "lru" or "lfu"
+	EvictionPolicy string
+	// DefaultTTL is applied when Set is called with a zero TTL (0 = no expiry)
+	DefaultTTL time.Duration
+	// CleanupInterval controls how often expired entries are swept (0 = lazy expiry only)
+	CleanupInterval time.Duration
+}
+
+// DefaultMemoryConfig returns in-memory cache settings suitable for a dev/edge node
+func DefaultMemoryConfig() *MemoryConfig {
+	return &MemoryConfig{
+		MaxMemoryBytes:  256 * 1024 * 1024, // 256MB
+		EvictionPolicy:  EvictionLRU,
+		DefaultTTL:      time.Hour,
+		CleanupInterval: time.Minute,
+	}
+}
+
+// MemoryStats holds in-memory cache statistics
+type MemoryStats struct {
+	Entries     int    `json:"entries"`
+	MemoryBytes int64  `json:"memory_bytes"`
+	Hits        int64  `json:"hits"`
+	Misses      int64  `json:"misses"`
+	Evictions   int64  `json:"evictions"`
+	Expirations int64  `json:"expirations"`
+	Policy      string `json:"policy"`
+}
+
+// memoryEntry is a single cached value
+type memoryEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time // zero means no expiry
+	size      int64
+	usageKey  string // usage counts the entry is included in, if stored by SetTracked
+
+	// Eviction bookkeeping
+	elem *list.Element
+	freq int
+}
+
+func (e *memoryEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+// MemoryCache implements Cache interface in process memory
+type MemoryCache struct {
+	mu      sync.Mutex
+	config  *MemoryConfig
+	entries map[string]*memoryEntry
+	policy  evictionPolicy
+	used    int64
+	closed  bool
+	stop    chan struct{}
+	stats   MemoryStats
+
+	// Per-instance usage counters kept by SetTracked and DeleteTracked
+	usage map[string]*usageCount
+
+	// Rate limiting buckets kept by TakeToken
+	buckets map[string]*tokenBucket
+
+	// In-process pub/sub; guarded separately so handlers may use the cache
+	subsMu  sync.Mutex
+	subs    map[string]map[int]func(message []byte)
+	nextSub int
+}
+
+// NewMemoryCache creates a new in-memory cache instance
+func NewMemoryCache(config *MemoryConfig) (*MemoryCache, error) {
+	if config == nil {
+		config = DefaultMemoryConfig()
+	}
+
+	var policy evictionPolicy
+	switch config.EvictionPolicy {
+	case "", EvictionLRU:
+		policy = newLRUPolicy()
+	case EvictionLFU:
+		policy = newLFUPolicy()
+	default:
+		return nil, fmt.Errorf("invalid eviction policy: %s", config.EvictionPolicy)
+	}
+
+	m := &MemoryCache{
+		config:  config,
+		entries: make(map[string]*memoryEntry),
+		policy:  policy,
+		stop:    make(chan struct{}),
+		usage:   make(map[string]*usageCount),
+		buckets: make(map[string]*tokenBucket),
+	}
+
+	if config.CleanupInterval > 0 {
+		go m.janitor(config.CleanupInterval)
+	}
+
+	return m, nil
+}
+
+// Get retrieves a value from the cache
+func (m *MemoryCache) Get(ctx context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil, ErrCacheClosed
+	}
+
+	entry := m.lookup(key, time.Now())
+	if entry == nil {
+		m.stats.Misses++
+		return nil, ErrKeyNotFound
+	}
+
+	m.stats.Hits++
+	m.policy.touch(entry)
+	return cloneBytes(entry.value), nil
+}
+
+// Set stores a value in the cache with optional TTL
+func (m *MemoryCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return ErrCacheClosed
+	}
+
+	return m.set(key, value, ttl, time.Now())
+}
+
+// Delete removes a value from the cache
+func (m *MemoryCache) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return ErrCacheClosed
+	}
+
+	if _, ok := m.usage[key]; ok {
+		delete(m.usage, key)
+		return nil
+	}
+	if m.lookup(key, time.Now()) == nil {
+		return ErrKeyNotFound
+	}
+
+	m.remove(m.entries[key])
+	return nil
+}
+
+// Exists checks if a key exists in the cache
+func (m *MemoryCache) Exists(ctx context.Context, key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return false, ErrCacheClosed
+	}
+
+	return m.lookup(key, time.Now()) != nil, nil
+}
+
+// GetMultiple retrieves multiple values from the cache
+func (m *MemoryCache) GetMultiple(ctx context.Context, keys []string) (map[string][]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil, ErrCacheClosed
+	}
+
+	now := time.Now()
+	result := make(map[string][]byte)
+	for _, key := range keys {
+		entry := m.lookup(key, now)
+		if entry == nil {
+			m.stats.Misses++
+			continue
+		}
+		m.stats.Hits++
+		m.policy.touch(entry)
+		result[key] = cloneBytes(entry.value)
+	}
+
+	return result, nil
+}
+
+// SetMultiple stores multiple values in the cache
+func (m *MemoryCache) SetMultiple(ctx context.Context, items map[string][]byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return ErrCacheClosed
+	}
+
+	now := time.Now()
+	for key, value := range items {
+		if err := m.set(key, value, ttl, now); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteMultiple removes multiple values from the cache
+func (m *MemoryCache) DeleteMultiple(ctx context.Context, keys []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return ErrCacheClosed
+	}
+
+	for _, key := range keys {
+		if entry, ok := m.entries[key]; ok {
+			m.remove(entry)
+		}
+		delete(m.usage, key)
+	}
+
+	return nil
+}
+
+// Scan returns all live keys matching a Redis-style glob pattern.
+// The count hint is accepted for interface parity with Redis and ignored.
+func (m *MemoryCache) Scan(ctx context.Context, pattern string, count int) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil, ErrCacheClosed
+	}
+
+	now := time.Now()
+	keys := []string{}
+	for key, entry := range m.entries {
+		if entry.expired(now) {
+			continue
+		}
+		if globMatch(pattern, key) {
+			keys = append(keys, key)
+		}
+	}
+	for key := range m.usage {
+		if globMatch(pattern, key) {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys, nil
+}
+
+// CompareAndSwap sets a key to value only if it currently holds old
+func (m *MemoryCache) CompareAndSwap(ctx context.Context, key string, old, value []byte, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return false, ErrCacheClosed
+	}
+
+	now := time.Now()
+	entry := m.lookup(key, now)
+	if entry == nil || !bytes.Equal(entry.value, old) {
+		return false, nil
+	}
+	if err := m.set(key, value, ttl, now); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// SetNX sets a key to value only if it does not exist
+func (m *MemoryCache) SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return false, ErrCacheClosed
+	}
+
+	now := time.Now()
+	if m.lookup(key, now) != nil {
+		return false, nil
+	}
+	if err := m.set(key, value, ttl, now); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// usageCount holds the key and byte counts of one usage key
+type usageCount struct {
+	keys  int64
+	bytes int64
+}
+
+// SetTracked stores a value and adjusts the key and byte counts in usageKey
+func (m *MemoryCache) SetTracked(ctx context.Context, key string, value []byte, ttl time.Duration, usageKey string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return ErrCacheClosed
+	}
+
+	now := time.Now()
+	var oldKeys, oldBytes int64
+	if entry := m.lookup(key, now); entry != nil && entry.usageKey == "" {
+		oldKeys, oldBytes = 1, int64(len(entry.value))
+	}
+	if err := m.set(key, value, ttl, now); err != nil {
+		return err
+	}
+
+	// set already adjusted the counts of an entry that was tracked
+	if entry := m.entries[key]; entry.usageKey == "" {
+		count := m.usageCount(usageKey)
+		count.keys += 1 - oldKeys
+		count.bytes += int64(len(value)) - oldBytes
+		entry.usageKey = usageKey
+	}
+	return nil
+}
+
+// DeleteTracked removes a value and adjusts the key and byte counts in usageKey
+func (m *MemoryCache) DeleteTracked(ctx context.Context, key, usageKey string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return ErrCacheClosed
+	}
+
+	entry := m.lookup(key, time.Now())
+	if entry == nil {
+		return ErrKeyNotFound
+	}
+
+	// remove adjusts the counts of tracked entries
+	if entry.usageKey == "" {
+		count := m.usageCount(usageKey)
+		count.keys--
+		count.bytes -= int64(len(entry.value))
+	}
+	m.remove(entry)
+	return nil
+}
+
+// Usage returns the key and byte counts held in usageKey
+func (m *MemoryCache) Usage(ctx context.Context, usageKey string) (int64, int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return 0, 0, ErrCacheClosed
+	}
+
+	if count, ok := m.usage[usageKey]; ok {
+		return count.keys, count.bytes, nil
+	}
+	return 0, 0, nil
+}
+
+// SetUsage overwrites the key and byte counts held in usageKey
+func (m *MemoryCache) SetUsage(ctx context.Context, usageKey string, keys, bytes int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return ErrCacheClosed
+	}
+
+	m.usage[usageKey] = &usageCount{keys: keys, bytes: bytes}
+	return nil
+}
+
+// usageCount returns the counters for usageKey, creating them at zero.
+// Callers must hold m.mu.
+func (m *MemoryCache) usageCount(usageKey string) *usageCount {
+	count, ok := m.usage[usageKey]
+	if !ok {
+		count = &usageCount{}
+		m.usage[usageKey] = count
+	}
+	return count
+}
+
+// untrack removes an entry stored by SetTracked from its usage counts, so
+// evicted and expired entries stop counting too. Callers must hold m.mu.
+func (m *MemoryCache) untrack(entry *memoryEntry) {
+	if entry.usageKey == "" {
+		return
+	}
+	if count, ok := m.usage[entry.usageKey]; ok {
+		count.keys--
+		count.bytes -= int64(len(entry.value))
+	}
+	entry.usageKey = ""
+}
+
+// tokenBucket is a rate limiting bucket
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+	period  time.Duration
+}
+
+// TakeToken takes a token from a rate limiting bucket
+func (m *MemoryCache) TakeToken(ctx context.Context, key string, burst int64, period time.Duration) (bool, float64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return false, 0, ErrCacheClosed
+	}
+
+	now := time.Now()
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(burst), updated: now}
+		m.buckets[key] = b
+	}
+	b.period = period
+	if period > 0 {
+		b.tokens += float64(now.Sub(b.updated)) * float64(burst) / float64(period)
+	}
+	if b.tokens > float64(burst) {
+		b.tokens = float64(burst)
+	}
+	b.updated = now
+
+	if b.tokens < 1 {
+		return false, b.tokens, nil
+	}
+	b.tokens--
+	return true, b.tokens, nil
+}
+
+// removeFullBuckets drops buckets that have had time to refill completely.
+// Callers must hold m.mu.
+func (m *MemoryCache) removeFullBuckets(now time.Time) {
+	for key, b := range m.buckets {
+		if now.Sub(b.updated) >= b.period {
+			delete(m.buckets, key)
+		}
+	}
+}
+
+// Publish delivers a message to every subscriber of a channel in this process
+func (m *MemoryCache) Publish(ctx context.Context, channel string, message []byte) error {
+	m.subsMu.Lock()
+	defer m.subsMu.Unlock()
+
+	for _, handler := range m.subs[channel] {
+		msg := append([]byte(nil), message...)
+		go handler(msg)
+	}
+	return nil
+}
+
+// Subscribe delivers messages on a channel to handler until ctx is done
+func (m *MemoryCache) Subscribe(ctx context.Context, channel string, handler func(message []byte)) error {
+	m.subsMu.Lock()
+	if m.subs == nil {
+		m.subs = make(map[string]map[int]func(message []byte))
+	}
+	if m.subs[channel] == nil {
+		m.subs[channel] = make(map[int]func(message []byte))
+	}
+	id := m.nextSub
+	m.nextSub++
+	m.subs[channel][id] = handler
+	m.subsMu.Unlock()
+
+	<-ctx.Done()
+
+	m.subsMu.Lock()
+	delete(m.subs[channel], id)
+	m.subsMu.Unlock()
+	return nil
+}
+
+// Ping checks if the cache is healthy
+func (m *MemoryCache) Ping(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return ErrCacheClosed
+	}
+	return nil
+}
+
+// Close stops background cleanup and releases all entries
+func (m *MemoryCache) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil
+	}
+
+	m.closed = true
+	close(m.stop)
+	m.entries = make(map[string]*memoryEntry)
+	m.usage = make(map[string]*usageCount)
+	m.buckets = make(map[string]*tokenBucket)
+	m.policy.reset()
+	m.used = 0
+	return nil
+}
+
+// TTL returns the remaining time to live of a key
+func (m *MemoryCache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return 0, ErrCacheClosed
+	}
+
+	now := time.Now()
+	entry := m.lookup(key, now)
+	if entry == nil {
+		return 0, ErrKeyNotFound
+	}
+
+	// Key exists but has no TTL
+	if entry.expiresAt.IsZero() {
+		return 0, nil
+	}
+
+	return entry.expiresAt.Sub(now), nil
+}
+
+// Expire sets a new expiration time for a key
+func (m *MemoryCache) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return ErrCacheClosed
+	}
+
+	now := time.Now()
+	entry := m.lookup(key, now)
+	if entry == nil {
+		return ErrKeyNotFound
+	}
+
+	entry.expiresAt = now.Add(ttl)
+	return nil
+}
+
+// FlushDB removes all entries
+func (m *MemoryCache) FlushDB(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return ErrCacheClosed
+	}
+
+	m.entries = make(map[string]*memoryEntry)
+	m.usage = make(map[string]*usageCount)
+	m.buckets = make(map[string]*tokenBucket)
+	m.policy.reset()
+	m.used = 0
+	return nil
+}
+
+// Stats returns in-memory cache statistics
+func (m *MemoryCache) Stats() MemoryStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := m.stats
+	stats.Entries = len(m.entries)
+	stats.MemoryBytes = m.used
+	stats.Policy = m.policy.name()
+	return stats
+}
+
+// lookup returns the live entry for key, dropping it if it has expired.
+// Callers must hold m.mu.
+func (m *MemoryCache) lookup(key string, now time.Time) *memoryEntry {
+	entry, ok := m.entries[key]
+	if !ok {
+		return nil
+	}
+	if entry.expired(now) {
+		m.remove(entry)
+		m.stats.Expirations++
+		return nil
+	}
+	return entry
+}
+
+// set inserts or replaces an entry and enforces limits. Callers must hold m.mu.
+func (m *MemoryCache) set(key string, value []byte, ttl time.Duration, now time.Time) error {
+	// Use default TTL if not specified
+	if ttl == 0 {
+		ttl = m.config.DefaultTTL
+	}
+
+	size := int64(len(key) + len(value) + entryOverhead)
+	if m.config.MaxMemoryBytes > 0 && size > m.config.MaxMemoryBytes {
+		return NewCacheError("value exceeds cache memory limit", false)
+	}
+
+	entry, exists := m.entries[key]
+	if exists {
+		m.used -= entry.size
+		if count, ok := m.usage[entry.usageKey]; ok {
+			count.bytes += int64(len(value) - len(entry.value))
+		}
+		entry.value = cloneBytes(value)
+		entry.size = size
+		m.policy.touch(entry)
+	} else {
+		entry = &memoryEntry{
+			key:   key,
+			value: cloneBytes(value),
+			size:  size,
+		}
+		m.entries[key] = entry
+		m.policy.add(entry)
+	}
+
+	entry.expiresAt = time.Time{}
+	if ttl > 0 {
+		entry.expiresAt = now.Add(ttl)
+	}
+	m.used += size
+
+	m.evict(entry, now)
+	return nil
+}
+
+// evict drops entries until the cache is back within its limits, never
+// evicting the entry that was just written. Callers must hold m.mu.
+func (m *MemoryCache) evict(keep *memoryEntry, now time.Time) {
+	for m.overLimit() {
+		// Prefer reclaiming space from expired entries first
+		if m.removeExpired(now, 1) > 0 {
+			continue
+		}
+
+		victim := m.policy.victim(keep)
+		if victim == nil {
+			return
+		}
+		m.remove(victim)
+		m.stats.Evictions++
+	}
+}
+
+func (m *MemoryCache) overLimit() bool {
+	if m.config.MaxEntries > 0 && len(m.entries) > m.config.MaxEntries {
+		return true
+	}
+	return m.config.MaxMemoryBytes > 0 && m.used > m.config.MaxMemoryBytes
+}
+
+// remove deletes an entry, its eviction bookkeeping and its usage counts.
+// Callers must hold m.mu.
+func (m *MemoryCache) remove(entry *memoryEntry) {
+	m.untrack(entry)
+	delete(m.entries, entry.key)
+	m.policy.remove(entry)
+	m.used -= entry.size
+}
+
+// removeExpired deletes up to limit expired entries (limit <= 0 means all).
+// Callers must hold m.mu.
+func (m *MemoryCache) removeExpired(now time.Time, limit int) int {
+	removed := 0
+	for _, entry := range m.entries {
+		if limit > 0 && removed >= limit {
+			break
+		}
+		if entry.expired(now) {
+			m.remove(entry)
+			m.stats.Expirations++
+			removed++
+		}
+	}
+	return removed
+}
+
+// janitor periodically sweeps expired entries until the cache is closed
+func (m *MemoryCache) janitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.mu.Lock()
+			if !m.closed {
+				m.removeExpired(time.Now(), 0)
+				m.removeFullBuckets(time.Now())
+			}
+			m.mu.Unlock()
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+// Eviction policies
+
+// evictionPolicy tracks access order or frequency to pick eviction victims
+type evictionPolicy interface {
+	name() string
+	add(e *memoryEntry)
+	touch(e *memoryEntry)
+	remove(e *memoryEntry)
+	victim(keep *memoryEntry) *memoryEntry
+	reset()
+}
+
+// lruPolicy evicts the least recently used entry
+type lruPolicy struct {
+	order *list.List // front = most recently used
+}
+
+func newLRUPolicy() *lruPolicy {
+	return &lruPolicy{order: list.New()}
+}
+
+func (p *lruPolicy) name() string { return EvictionLRU }
+
+func (p *lruPolicy) add(e *memoryEntry) {
+	e.elem = p.order.PushFront(e)
+}
+
+func (p *lruPolicy) touch(e *memoryEntry) {
+	p.order.MoveToFront(e.elem)
+}
+
+func (p *lruPolicy) remove(e *memoryEntry) {
+	p.order.Remove(e.elem)
+	e.elem = nil
+}
+
+func (p *lruPolicy) victim(keep *memoryEntry) *memoryEntry {
+	for elem := p.order.Back(); elem != nil; elem = elem.Prev() {
+		if e := elem.Value.(*memoryEntry); e != keep {
+			return e
+		}
+	}
+	return nil
+}
+
+func (p *lruPolicy) reset() {
+	p.order.Init()
+}
+
+// lfuPolicy evicts the least frequently used entry, breaking ties by recency
+type lfuPolicy struct {
+	buckets map[int]*list.List // access count -> entries, front = most recent
+	minFreq int
+}
+
+func newLFUPolicy() *lfuPolicy {
+	return &lfuPolicy{buckets: make(map[int]*list.List)}
+}
+
+func (p *lfuPolicy) name() string { return EvictionLFU }
+
+func (p *lfuPolicy) bucket(freq int) *list.List {
+	b, ok := p.buckets[freq]
+	if !ok {
+		b = list.New()
+		p.buckets[freq] = b
+	}
+	return b
+}
+
+func (p *lfuPolicy) add(e *memoryEntry) {
+	e.freq = 1
+	e.elem = p.bucket(1).PushFront(e)
+	p.minFreq = 1
+}
+
+func (p *lfuPolicy) touch(e *memoryEntry) {
+	p.unlink(e)
+	e.freq++
+	e.elem = p.bucket(e.freq).PushFront(e)
+	if p.minFreq == 0 {
+		p.minFreq = e.freq
+	}
+}
+
+func (p *lfuPolicy) remove(e *memoryEntry) {
+	p.unlink(e)
+	e.elem = nil
+}
+
+// unlink detaches an entry from its frequency bucket, keeping minFreq accurate
+func (p *lfuPolicy) unlink(e *memoryEntry) {
+	b := p.buckets[e.freq]
+	b.Remove(e.elem)
+	if b.Len() == 0 {
+		delete(p.buckets, e.freq)
+		if p.minFreq == e.freq {
+			p.minFreq = p.lowestFreq()
+		}
+	}
+}
+
+func (p *lfuPolicy) lowestFreq() int {
+	lowest := 0
+	for freq := range p.buckets {
+		if lowest == 0 || freq < lowest {
+			lowest = freq
+		}
+	}
+	return lowest
+}
+
+func (p *lfuPolicy) victim(keep *memoryEntry) *memoryEntry {
+	if len(p.buckets) == 0 {
+		return nil
+	}
+
+	// Walk buckets from the lowest frequency upwards
+	freq := p.minFreq
+	for checked := 0; checked < len(p.buckets); freq++ {
+		b, ok := p.buckets[freq]
+		if !ok {
+			continue
+		}
+		checked++
+		for elem := b.Back(); elem != nil; elem = elem.Prev() {
+			if e := elem.Value.(*memoryEntry); e != keep {
+				return e
+			}
+		}
+	}
+	return nil
+}
+
+func (p *lfuPolicy) reset() {
+	p.buckets = make(map[int]*list.List)
+	p.minFreq = 0
+}
+
+// Helpers
+
+func cloneBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	c := make([]byte, len(b))
+	copy(c, b)
+	return c
+}
+
+// globMatch reports whether key matches a Redis-style glob pattern
+// supporting *, ?, [abc], [^abc], [a-z] and backslash escapes
+func globMatch(pattern, key string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			// Collapse consecutive stars
+			for len(pattern) > 0 && pattern[0] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := 0; i <= len(key); i++ {
+				if globMatch(pattern, key[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(key) == 0 {
+				return false
+			}
+			pattern, key = pattern[1:], key[1:]
+		case '[':
+			if len(key) == 0 {
+				return false
+			}
+			end := strings.IndexByte(pattern[1:], ']')
+			if end < 0 {
+				// Unterminated class matches literally
+				if key[0] != '[' {
+					return false
+				}
+				pattern, key = pattern[1:], key[1:]
+				continue
+			}
+			if !matchClass(pattern[1:end+1], key[0]) {
+				return false
+			}
+			pattern, key = pattern[end+2:], key[1:]
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if len(key) == 0 || key[0] != pattern[0] {
+				return false
+			}
+			pattern, key = pattern[1:], key[1:]
+		}
+	}
+	return len(key) == 0
+}
+
+// matchClass matches a single byte against the contents of a [...] class
+func matchClass(class string, c byte) bool {
+	negate := false
+	if len(class) > 0 && class[0] == '^' {
+		negate = true
+		class = class[1:]
+	}
+
+	matched := false
+	for i := 0; i < len(class); i++ {
+		if i+2 < len(class) && class[i+1] == '-' {
+			lo, hi := class[i], class[i+2]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			if c >= lo && c <= hi {
+				matched = true
+			}
+			i += 2
+			continue
+		}
+		if class[i] == c {
+			matched = true
+		}
+	}
+
+	return matched != negate
+}
@@ -0,0 +1,24 @@
+package cache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/birbparty/birb-nest/internal/cache"
+	"github.com/birbparty/birb-nest/tests/conformance"
+)
+
+func TestMemoryCache_Conformance(t *testing.T) {
+	conformance.RunCacheSuite(t, func(t *testing.T) cache.Cache {
+		c, err := cache.NewMemoryCache(&cache.MemoryConfig{
+			MaxMemoryBytes:  64 << 20,
+			EvictionPolicy:  cache.EvictionLRU,
+			DefaultTTL:      time.Hour,
+			CleanupInterval: 50 * time.Millisecond,
+		})
+		if err != nil {
+			t.Fatalf("NewMemoryCache() error = %v", err)
+		}
+		return c
+	}, conformance.CacheOptions{})
+}
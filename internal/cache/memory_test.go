@@ -0,0 +1,386 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemoryCache_TTL(t *testing.T) {
+	ctx := context.Background()
+	mc, err := NewMemoryCache(&MemoryConfig{})
+	if err != nil {
+		t.Fatalf("NewMemoryCache() error = %v", err)
+	}
+	defer mc.Close()
+
+	if err := mc.Set(ctx, "short", []byte("v"), 20*time.Millisecond); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if err := mc.Set(ctx, "forever", []byte("v"), 0); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	if ttl, err := mc.TTL(ctx, "short"); err != nil || ttl <= 0 {
+		t.Errorf("TTL() = %v, %v; want positive duration", ttl, err)
+	}
+	if ttl, err := mc.TTL(ctx, "forever"); err != nil || ttl != 0 {
+		t.Errorf("TTL() = %v, %v; want 0 for key without expiry", ttl, err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+
+	if _, err := mc.Get(ctx, "short"); err != ErrKeyNotFound {
+		t.Errorf("Get() after expiry error = %v, want %v", err, ErrKeyNotFound)
+	}
+	if exists, _ := mc.Exists(ctx, "forever"); !exists {
+		t.Error("key without TTL expired")
+	}
+	if stats := mc.Stats(); stats.Expirations != 1 {
+		t.Errorf("Stats().Expirations = %d, want 1", stats.Expirations)
+	}
+}
+
+func TestMemoryCache_DefaultTTL(t *testing.T) {
+	ctx := context.Background()
+	mc, _ := NewMemoryCache(&MemoryConfig{DefaultTTL: 20 * time.Millisecond})
+	defer mc.Close()
+
+	mc.Set(ctx, "k", []byte("v"), 0)
+	time.Sleep(30 * time.Millisecond)
+
+	if exists, _ := mc.Exists(ctx, "k"); exists {
+		t.Error("default TTL was not applied")
+	}
+}
+
+func TestMemoryCache_Janitor(t *testing.T) {
+	ctx := context.Background()
+	mc, _ := NewMemoryCache(&MemoryConfig{CleanupInterval: 10 * time.Millisecond})
+	defer mc.Close()
+
+	mc.Set(ctx, "k", []byte("v"), 5*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+
+	if stats := mc.Stats(); stats.Entries != 0 {
+		t.Errorf("Stats().Entries = %d after cleanup, want 0", stats.Entries)
+	}
+}
+
+func TestMemoryCache_LRUEviction(t *testing.T) {
+	ctx := context.Background()
+	mc, _ := NewMemoryCache(&MemoryConfig{MaxEntries: 3, EvictionPolicy: EvictionLRU})
+	defer mc.Close()
+
+	mc.Set(ctx, "a", []byte("1"), 0)
+	mc.Set(ctx, "b", []byte("2"), 0)
+	mc.Set(ctx, "c", []byte("3"), 0)
+
+	// Touch "a" so "b" becomes least recently used
+	mc.Get(ctx, "a")
+	mc.Set(ctx, "d", []byte("4"), 0)
+
+	if exists, _ := mc.Exists(ctx, "b"); exists {
+		t.Error("least recently used key b was not evicted")
+	}
+	for _, key := range []string{"a", "c", "d"} {
+		if exists, _ := mc.Exists(ctx, key); !exists {
+			t.Errorf("key %s was evicted unexpectedly", key)
+		}
+	}
+	if stats := mc.Stats(); stats.Evictions != 1 {
+		t.Errorf("Stats().Evictions = %d, want 1", stats.Evictions)
+	}
+}
+
+func TestMemoryCache_LFUEviction(t *testing.T) {
+	ctx := context.Background()
+	mc, _ := NewMemoryCache(&MemoryConfig{MaxEntries: 3, EvictionPolicy: EvictionLFU})
+	defer mc.Close()
+
+	mc.Set(ctx, "a", []byte("1"), 0)
+	mc.Set(ctx, "b", []byte("2"), 0)
+	mc.Set(ctx, "c", []byte("3"), 0)
+
+	// a and c are read often, b only once
+	for i := 0; i < 3; i++ {
+		mc.Get(ctx, "a")
+		mc.Get(ctx, "c")
+	}
+	mc.Get(ctx, "b")
+
+	mc.Set(ctx, "d", []byte("4"), 0)
+
+	if exists, _ := mc.Exists(ctx, "b"); exists {
+		t.Error("least frequently used key b was not evicted")
+	}
+	if exists, _ := mc.Exists(ctx, "d"); !exists {
+		t.Error("newly written key d was evicted")
+	}
+}
+
+func TestMemoryCache_MemoryLimit(t *testing.T) {
+	ctx := context.Background()
+	limit := int64(4 * (entryOverhead + 1 + 100))
+	mc, _ := NewMemoryCache(&MemoryConfig{MaxMemoryBytes: limit})
+	defer mc.Close()
+
+	value := make([]byte, 100)
+	for i := 0; i < 10; i++ {
+		if err := mc.Set(ctx, fmt.Sprintf("%d", i), value, 0); err != nil {
+			t.Fatalf("Set() error = %v", err)
+		}
+	}
+
+	stats := mc.Stats()
+	if stats.MemoryBytes > limit {
+		t.Errorf("Stats().MemoryBytes = %d, exceeds limit %d", stats.MemoryBytes, limit)
+	}
+	if stats.Entries != 4 {
+		t.Errorf("Stats().Entries = %d, want 4", stats.Entries)
+	}
+
+	// A single value larger than the whole cache is rejected
+	if err := mc.Set(ctx, "huge", make([]byte, limit), 0); err == nil {
+		t.Error("Set() of oversized value succeeded, want error")
+	}
+}
+
+func TestMemoryCache_Scan(t *testing.T) {
+	ctx := context.Background()
+	mc, _ := NewMemoryCache(&MemoryConfig{})
+	defer mc.Close()
+
+	mc.SetMultiple(ctx, map[string][]byte{
+		"instance:{inst_1}:cache:player:1": []byte("a"),
+		"instance:{inst_1}:cache:player:2": []byte("b"),
+		"instance:{inst_1}:cache:world":    []byte("c"),
+		"instance:{inst_2}:cache:player:1": []byte("d"),
+	}, 0)
+
+	keys, err := mc.Scan(ctx, "instance:{inst_1}:cache:player:*", 100)
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	sort.Strings(keys)
+	want := []string{"instance:{inst_1}:cache:player:1", "instance:{inst_1}:cache:player:2"}
+	if fmt.Sprint(keys) != fmt.Sprint(want) {
+		t.Errorf("Scan() = %v, want %v", keys, want)
+	}
+}
+
+func TestGlobMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		key     string
+		want    bool
+	}{
+		{"*", "anything", true},
+		{"player:*", "player:42", true},
+		{"player:*", "world:42", false},
+		{"player:?", "player:4", true},
+		{"player:?", "player:42", false},
+		{"player:[0-3]", "player:2", true},
+		{"player:[0-3]", "player:7", false},
+		{"player:[^0-3]", "player:7", true},
+		{"a\\*b", "a*b", true},
+		{"a\\*b", "axb", false},
+		{"instance:{inst_1}:*", "instance:{inst_1}:cache:x", true},
+		{"*:cache:*", "instance:{inst_1}:cache:x", true},
+	}
+
+	for _, tt := range tests {
+		if got := globMatch(tt.pattern, tt.key); got != tt.want {
+			t.Errorf("globMatch(%q, %q) = %v, want %v", tt.pattern, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestMemoryCache_Closed(t *testing.T) {
+	ctx := context.Background()
+	mc, _ := NewMemoryCache(nil)
+	mc.Close()
+
+	if _, err := mc.Get(ctx, "k"); err != ErrCacheClosed {
+		t.Errorf("Get() after Close() error = %v, want %v", err, ErrCacheClosed)
+	}
+	if err := mc.Set(ctx, "k", nil, 0); err != ErrCacheClosed {
+		t.Errorf("Set() after Close() error = %v, want %v", err, ErrCacheClosed)
+	}
+	if err := mc.Close(); err != nil {
+		t.Errorf("second Close() error = %v", err)
+	}
+}
+
+func TestMemoryCache_Concurrency(t *testing.T) {
+	ctx := context.Background()
+	mc, _ := NewMemoryCache(&MemoryConfig{MaxEntries: 50, EvictionPolicy: EvictionLFU})
+	defer mc.Close()
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				key := fmt.Sprintf("k%d", (w*200+i)%80)
+				mc.Set(ctx, key, []byte(key), 0)
+				mc.Get(ctx, key)
+				if i%10 == 0 {
+					mc.Delete(ctx, key)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if stats := mc.Stats(); stats.Entries > 50 {
+		t.Errorf("Stats().Entries = %d, exceeds MaxEntries", stats.Entries)
+	}
+}
+
+func TestNewMemoryCache_InvalidPolicy(t *testing.T) {
+	if _, err := NewMemoryCache(&MemoryConfig{EvictionPolicy: "random"}); err == nil {
+		t.Error("NewMemoryCache() with invalid policy succeeded, want error")
+	}
+}
+
+func TestMemoryCache_PubSub(t *testing.T) {
+	mc, err := NewMemoryCache(&MemoryConfig{})
+	if err != nil {
+		t.Fatalf("NewMemoryCache() error = %v", err)
+	}
+	defer mc.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	received := make(chan string, 1)
+	done := make(chan struct{})
+	go func() {
+		mc.Subscribe(ctx, "events", func(message []byte) {
+			select {
+			case received <- string(message):
+			default:
+			}
+		})
+		close(done)
+	}()
+
+	// Publish until the subscription is registered
+	deadline := time.After(time.Second)
+	for delivered := false; !delivered; {
+		if err := mc.Publish(context.Background(), "events", []byte("hello")); err != nil {
+			t.Fatalf("Publish() error = %v", err)
+		}
+		select {
+		case msg := <-received:
+			if msg != "hello" {
+				t.Errorf("received %q, want %q", msg, "hello")
+			}
+			delivered = true
+		case <-time.After(5 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("message never delivered")
+		}
+	}
+
+	cancel()
+	<-done
+	mc.subsMu.Lock()
+	remaining := len(mc.subs["events"])
+	mc.subsMu.Unlock()
+	if remaining != 0 {
+		t.Errorf("%d subscribers left after cancel, want 0", remaining)
+	}
+}
+
+func TestMemoryCache_CompareAndSwap(t *testing.T) {
+	ctx := context.Background()
+	mc, err := NewMemoryCache(&MemoryConfig{})
+	if err != nil {
+		t.Fatalf("NewMemoryCache() error = %v", err)
+	}
+	defer mc.Close()
+
+	if swapped, err := mc.CompareAndSwap(ctx, "k", []byte("a"), []byte("b"), 0); err != nil || swapped {
+		t.Errorf("CompareAndSwap() on missing key = %v, %v; want false", swapped, err)
+	}
+	mc.Set(ctx, "k", []byte("a"), 0)
+	if swapped, err := mc.CompareAndSwap(ctx, "k", []byte("x"), []byte("b"), 0); err != nil || swapped {
+		t.Errorf("CompareAndSwap() with wrong old value = %v, %v; want false", swapped, err)
+	}
+	if swapped, err := mc.CompareAndSwap(ctx, "k", []byte("a"), []byte("b"), 0); err != nil || !swapped {
+		t.Errorf("CompareAndSwap() = %v, %v; want true", swapped, err)
+	}
+	if got, _ := mc.Get(ctx, "k"); string(got) != "b" {
+		t.Errorf("Get() = %q, want %q", got, "b")
+	}
+}
+
+func TestMemoryCache_UsageTracking(t *testing.T) {
+	ctx := context.Background()
+	mc, err := NewMemoryCache(&MemoryConfig{})
+	if err != nil {
+		t.Fatalf("NewMemoryCache() error = %v", err)
+	}
+	defer mc.Close()
+
+	usageKey := "instance:{a}:usage"
+	mc.SetTracked(ctx, "instance:{a}:cache:k1", []byte("12345"), 0, usageKey)
+	mc.SetTracked(ctx, "instance:{a}:cache:k2", []byte("123"), 0, usageKey)
+	mc.SetTracked(ctx, "instance:{a}:cache:k1", []byte("12"), 0, usageKey) // overwrite shrinks
+	if keys, bytes, _ := mc.Usage(ctx, usageKey); keys != 2 || bytes != 5 {
+		t.Errorf("Usage() = %d keys, %d bytes; want 2, 5", keys, bytes)
+	}
+
+	if err := mc.DeleteTracked(ctx, "instance:{a}:cache:k2", usageKey); err != nil {
+		t.Fatalf("DeleteTracked() error = %v", err)
+	}
+	if err := mc.DeleteTracked(ctx, "instance:{a}:cache:k2", usageKey); err != ErrKeyNotFound {
+		t.Errorf("DeleteTracked() on missing key error = %v, want ErrKeyNotFound", err)
+	}
+	if keys, bytes, _ := mc.Usage(ctx, usageKey); keys != 1 || bytes != 2 {
+		t.Errorf("Usage() = %d keys, %d bytes; want 1, 2", keys, bytes)
+	}
+
+	// Usage keys are scanned and deleted like other keys
+	keys, _ := mc.Scan(ctx, "instance:{a}:*", 100)
+	if len(keys) != 2 {
+		t.Errorf("Scan() = %v, want the value and usage keys", keys)
+	}
+	mc.DeleteMultiple(ctx, keys)
+	if keys, bytes, _ := mc.Usage(ctx, usageKey); keys != 0 || bytes != 0 {
+		t.Errorf("Usage() after delete = %d keys, %d bytes; want 0, 0", keys, bytes)
+	}
+}
+
+func TestMemoryCache_UsageEvictionAndExpiry(t *testing.T) {
+	ctx := context.Background()
+	for _, policy := range []string{EvictionLRU, EvictionLFU} {
+		t.Run(policy, func(t *testing.T) {
+			mc, _ := NewMemoryCache(&MemoryConfig{MaxEntries: 3, EvictionPolicy: policy})
+			defer mc.Close()
+
+			usageKey := "instance:{a}:usage"
+			for i := 0; i < 5; i++ {
+				mc.SetTracked(ctx, fmt.Sprintf("instance:{a}:cache:k%d", i), []byte("1234"), 0, usageKey)
+			}
+			// Plain writes over tracked entries keep the counts in step
+			mc.Set(ctx, "instance:{a}:cache:k4", []byte("12"), 0)
+			if keys, bytes, _ := mc.Usage(ctx, usageKey); keys != 3 || bytes != 10 {
+				t.Errorf("Usage() after evictions = %d keys, %d bytes; want 3, 10", keys, bytes)
+			}
+
+			mc.SetTracked(ctx, "instance:{a}:cache:k4", []byte("123"), 10*time.Millisecond, usageKey)
+			time.Sleep(20 * time.Millisecond)
+			if exists, _ := mc.Exists(ctx, "instance:{a}:cache:k4"); exists {
+				t.Fatal("expired key still exists")
+			}
+			if keys, bytes, _ := mc.Usage(ctx, usageKey); keys != 2 || bytes != 8 {
+				t.Errorf("Usage() after expiry = %d keys, %d bytes; want 2, 8", keys, bytes)
+			}
+		})
+	}
+}
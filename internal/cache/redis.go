@@ -4,6 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	redistrace "github.com/DataDog/dd-trace-go/contrib/redis/go-redis.v9/v2"
@@ -12,7 +15,7 @@ import (
 
 // RedisCache implements Cache interface using Redis
 type RedisCache struct {
-	client *redis.Client
+	client redis.UniversalClient
 	config *Config
 }
 
@@ -22,21 +25,12 @@ func NewRedisCache(config *Config) (*RedisCache, error) {
 		return nil, fmt.Errorf("config cannot be nil")
 	}
 
-	// Create Redis client with tracing
-	client := redis.NewClient(&redis.Options{
-		Addr:            config.Address(),
-		Password:        config.Password,
-		DB:              config.DB,
-		MaxRetries:      config.MaxRetries,
-		MinRetryBackoff: config.MinRetryBackoff,
-		MaxRetryBackoff: config.MaxRetryBackoff,
-		DialTimeout:     config.DialTimeout,
-		ReadTimeout:     config.ReadTimeout,
-		WriteTimeout:    config.WriteTimeout,
-		PoolSize:        config.PoolSize,
-		MinIdleConns:    config.MinIdleConns,
-		ConnMaxIdleTime: config.MaxIdleTime,
-	})
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Create Redis client for the configured deployment mode
+	client := newRedisClient(config)
 
 	// Wrap client with Datadog tracing
 	redistrace.WrapClient(client, redistrace.WithService("birb-nest-redis"))
@@ -46,7 +40,8 @@ func NewRedisCache(config *Config) (*RedisCache, error) {
 	defer cancel()
 
 	if err := client.Ping(ctx).Err(); err != nil {
-		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to Redis (%s): %w", config.modeName(), err)
 	}
 
 	return &RedisCache{
@@ -55,6 +50,76 @@ func NewRedisCache(config *Config) (*RedisCache, error) {
 	}, nil
 }
 
+// newRedisClient builds a standalone, Sentinel-backed failover or Cluster client
+func newRedisClient(config *Config) redis.UniversalClient {
+	switch config.Mode {
+	case ModeSentinel:
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:       config.SentinelMasterName,
+			SentinelAddrs:    config.SentinelAddrs,
+			SentinelPassword: config.SentinelPassword,
+			Password:         config.Password,
+			DB:               config.DB,
+			MaxRetries:       config.MaxRetries,
+			MinRetryBackoff:  config.MinRetryBackoff,
+			MaxRetryBackoff:  config.MaxRetryBackoff,
+			DialTimeout:      config.DialTimeout,
+			ReadTimeout:      config.ReadTimeout,
+			WriteTimeout:     config.WriteTimeout,
+			PoolSize:         config.PoolSize,
+			MinIdleConns:     config.MinIdleConns,
+			ConnMaxIdleTime:  config.MaxIdleTime,
+		})
+	case ModeCluster:
+		return redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:           config.ClusterAddrs,
+			Password:        config.Password,
+			MaxRetries:      config.MaxRetries,
+			MinRetryBackoff: config.MinRetryBackoff,
+			MaxRetryBackoff: config.MaxRetryBackoff,
+			DialTimeout:     config.DialTimeout,
+			ReadTimeout:     config.ReadTimeout,
+			WriteTimeout:    config.WriteTimeout,
+			PoolSize:        config.PoolSize,
+			MinIdleConns:    config.MinIdleConns,
+			ConnMaxIdleTime: config.MaxIdleTime,
+		})
+	default:
+		return redis.NewClient(&redis.Options{
+			Addr:            config.Address(),
+			Password:        config.Password,
+			DB:              config.DB,
+			MaxRetries:      config.MaxRetries,
+			MinRetryBackoff: config.MinRetryBackoff,
+			MaxRetryBackoff: config.MaxRetryBackoff,
+			DialTimeout:     config.DialTimeout,
+			ReadTimeout:     config.ReadTimeout,
+			WriteTimeout:    config.WriteTimeout,
+			PoolSize:        config.PoolSize,
+			MinIdleConns:    config.MinIdleConns,
+			ConnMaxIdleTime: config.MaxIdleTime,
+		})
+	}
+}
+
+// modeName returns the deployment mode, defaulting to standalone
+func (c *Config) modeName() string {
+	if c.Mode == "" {
+		return ModeStandalone
+	}
+	return c.Mode
+}
+
+// isCluster reports whether commands may be routed to different cluster nodes
+func (r *RedisCache) isCluster() bool {
+	return r.config.Mode == ModeCluster
+}
+
+// Mode returns the Redis deployment mode in use
+func (r *RedisCache) Mode() string {
+	return r.config.modeName()
+}
+
 // Get retrieves a value from the cache
 func (r *RedisCache) Get(ctx context.Context, key string) ([]byte, error) {
 	val, err := r.client.Get(ctx, key).Bytes()
@@ -111,6 +176,11 @@ func (r *RedisCache) GetMultiple(ctx context.Context, keys []string) (map[string
 		return make(map[string][]byte), nil
 	}
 
+	// Keys spread over several cluster slots cannot share a single MGET
+	if r.isCluster() && !sameHashSlot(keys) {
+		return r.getMultiplePipelined(ctx, keys)
+	}
+
 	// Use MGET for batch retrieval
 	values, err := r.client.MGet(ctx, keys...).Result()
 	if err != nil {
@@ -130,6 +200,29 @@ func (r *RedisCache) GetMultiple(ctx context.Context, keys []string) (map[string
 	return result, nil
 }
 
+// getMultiplePipelined fetches keys with individual GETs in one pipeline,
+// letting the cluster client route each command to the owning node
+func (r *RedisCache) getMultiplePipelined(ctx context.Context, keys []string) (map[string][]byte, error) {
+	pipe := r.client.Pipeline()
+	cmds := make([]*redis.StringCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.Get(ctx, key)
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, NewCacheError("failed to get multiple keys", true).WithError(err)
+	}
+
+	result := make(map[string][]byte)
+	for i, cmd := range cmds {
+		if val, err := cmd.Bytes(); err == nil {
+			result[keys[i]] = val
+		}
+	}
+
+	return result, nil
+}
+
 // SetMultiple stores multiple values in the cache
 func (r *RedisCache) SetMultiple(ctx context.Context, items map[string][]byte, ttl time.Duration) error {
 	if len(items) == 0 {
@@ -162,6 +255,18 @@ func (r *RedisCache) DeleteMultiple(ctx context.Context, keys []string) error {
 		return nil
 	}
 
+	// Multi-key DEL must stay within a single cluster slot
+	if r.isCluster() && !sameHashSlot(keys) {
+		pipe := r.client.Pipeline()
+		for _, key := range keys {
+			pipe.Del(ctx, key)
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			return NewCacheError("failed to delete multiple keys", true).WithError(err)
+		}
+		return nil
+	}
+
 	result := r.client.Del(ctx, keys...)
 	if err := result.Err(); err != nil {
 		return NewCacheError("failed to delete multiple keys", true).WithError(err)
@@ -170,6 +275,248 @@ func (r *RedisCache) DeleteMultiple(ctx context.Context, keys []string) error {
 	return nil
 }
 
+// Scan returns all keys matching a glob pattern using incremental SCAN.
+// In cluster mode every master is scanned.
+func (r *RedisCache) Scan(ctx context.Context, pattern string, count int) ([]string, error) {
+	if count <= 0 {
+		count = 100
+	}
+
+	if cluster, ok := r.client.(*redis.ClusterClient); ok {
+		var mu sync.Mutex
+		keys := []string{}
+		err := cluster.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+			found, err := scanAll(ctx, client, pattern, count)
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			keys = append(keys, found...)
+			mu.Unlock()
+			return nil
+		})
+		if err != nil {
+			return nil, NewCacheError("failed to scan keys", true).WithError(err)
+		}
+		return keys, nil
+	}
+
+	keys, err := scanAll(ctx, r.client, pattern, count)
+	if err != nil {
+		return nil, NewCacheError("failed to scan keys", true).WithError(err)
+	}
+	return keys, nil
+}
+
+// scanAll iterates a SCAN cursor to completion
+func scanAll(ctx context.Context, client redis.Cmdable, pattern string, count int) ([]string, error) {
+	keys := []string{}
+	iter := client.Scan(ctx, 0, pattern, int64(count)).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// compareAndSwapScript sets KEYS[1] to ARGV[2] if it holds ARGV[1]; ARGV[3] is the TTL in ms
+var compareAndSwapScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then
+    return 0
+end
+local ttl = tonumber(ARGV[3])
+if ttl > 0 then
+    redis.call('SET', KEYS[1], ARGV[2], 'PX', ttl)
+else
+    redis.call('SET', KEYS[1], ARGV[2])
+end
+return 1
+`)
+
+// CompareAndSwap sets a key to value only if it currently holds old
+func (r *RedisCache) CompareAndSwap(ctx context.Context, key string, old, value []byte, ttl time.Duration) (bool, error) {
+	swapped, err := compareAndSwapScript.Run(ctx, r.client, []string{key}, old, value, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, NewCacheError("failed to compare and swap key", true).WithError(err)
+	}
+	return swapped == 1, nil
+}
+
+// SetNX sets a key to value only if it does not exist
+func (r *RedisCache) SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	set, err := r.client.SetNX(ctx, key, value, ttl).Result()
+	if err != nil {
+		return false, NewCacheError("failed to set key if absent", true).WithError(err)
+	}
+	return set, nil
+}
+
+// setTrackedScript sets KEYS[1] to ARGV[1] with TTL ARGV[2] in ms and adjusts
+// the key and byte counts in the hash KEYS[2]
+var setTrackedScript = redis.NewScript(`
+local old = 0
+local existed = redis.call('EXISTS', KEYS[1])
+if existed == 1 then
+    old = redis.call('STRLEN', KEYS[1])
+end
+local ttl = tonumber(ARGV[2])
+if ttl > 0 then
+    redis.call('SET', KEYS[1], ARGV[1], 'PX', ttl)
+else
+    redis.call('SET', KEYS[1], ARGV[1])
+end
+if existed == 0 then
+    redis.call('HINCRBY', KEYS[2], 'keys', 1)
+end
+redis.call('HINCRBY', KEYS[2], 'bytes', string.len(ARGV[1]) - old)
+return 1
+`)
+
+// deleteTrackedScript deletes KEYS[1] and adjusts the counts in the hash KEYS[2]
+var deleteTrackedScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+    return 0
+end
+local old = redis.call('STRLEN', KEYS[1])
+redis.call('DEL', KEYS[1])
+redis.call('HINCRBY', KEYS[2], 'keys', -1)
+redis.call('HINCRBY', KEYS[2], 'bytes', -old)
+return 1
+`)
+
+// SetTracked stores a value and adjusts the key and byte counts in usageKey
+func (r *RedisCache) SetTracked(ctx context.Context, key string, value []byte, ttl time.Duration, usageKey string) error {
+	// Use default TTL if not specified
+	if ttl == 0 {
+		ttl = r.config.DefaultTTL
+	}
+
+	if err := setTrackedScript.Run(ctx, r.client, []string{key, usageKey}, value, ttl.Milliseconds()).Err(); err != nil {
+		return NewCacheError("failed to set key", true).WithError(err)
+	}
+	return nil
+}
+
+// DeleteTracked removes a value and adjusts the key and byte counts in usageKey
+func (r *RedisCache) DeleteTracked(ctx context.Context, key, usageKey string) error {
+	deleted, err := deleteTrackedScript.Run(ctx, r.client, []string{key, usageKey}).Int()
+	if err != nil {
+		return NewCacheError("failed to delete key", true).WithError(err)
+	}
+	if deleted == 0 {
+		return ErrKeyNotFound
+	}
+	return nil
+}
+
+// Usage returns the key and byte counts held in usageKey
+func (r *RedisCache) Usage(ctx context.Context, usageKey string) (int64, int64, error) {
+	values, err := r.client.HMGet(ctx, usageKey, "keys", "bytes").Result()
+	if err != nil {
+		return 0, 0, NewCacheError("failed to get usage", true).WithError(err)
+	}
+
+	counts := make([]int64, len(values))
+	for i, v := range values {
+		if s, ok := v.(string); ok {
+			if counts[i], err = strconv.ParseInt(s, 10, 64); err != nil {
+				return 0, 0, NewCacheError("invalid usage counter", false).WithError(err)
+			}
+		}
+	}
+	return counts[0], counts[1], nil
+}
+
+// SetUsage overwrites the key and byte counts held in usageKey
+func (r *RedisCache) SetUsage(ctx context.Context, usageKey string, keys, bytes int64) error {
+	if err := r.client.HSet(ctx, usageKey, "keys", keys, "bytes", bytes).Err(); err != nil {
+		return NewCacheError("failed to set usage", true).WithError(err)
+	}
+	return nil
+}
+
+// takeTokenScript takes a token from the bucket hash KEYS[1] holding up to
+// ARGV[1] tokens that refills over ARGV[2] ms. Redis server time is used so
+// nodes with skewed clocks share buckets fairly.
+var takeTokenScript = redis.NewScript(`
+redis.replicate_commands()
+local burst = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+    tokens = burst
+    ts = now
+end
+tokens = math.min(burst, tokens + math.max(0, now - ts) * burst / period)
+local allowed = 0
+if tokens >= 1 then
+    tokens = tokens - 1
+    allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], period)
+return {allowed, tostring(tokens)}
+`)
+
+// TakeToken takes a token from a rate limiting bucket
+func (r *RedisCache) TakeToken(ctx context.Context, key string, burst int64, period time.Duration) (bool, float64, error) {
+	result, err := takeTokenScript.Run(ctx, r.client, []string{key}, burst, period.Milliseconds()).Slice()
+	if err != nil || len(result) != 2 {
+		return false, 0, NewCacheError("failed to take token", true).WithError(err)
+	}
+
+	allowed, _ := result[0].(int64)
+	s, _ := result[1].(string)
+	tokens, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return false, 0, NewCacheError("invalid token count", false).WithError(err)
+	}
+	return allowed == 1, tokens, nil
+}
+
+// Publish sends a message to a pub/sub channel
+func (r *RedisCache) Publish(ctx context.Context, channel string, message []byte) error {
+	if err := r.client.Publish(ctx, channel, message).Err(); err != nil {
+		return NewCacheError("failed to publish message", true).WithError(err)
+	}
+	return nil
+}
+
+// Subscribe delivers messages on a pub/sub channel to handler until ctx is done.
+// go-redis reconnects and resubscribes on its own; messages sent while
+// disconnected are lost.
+func (r *RedisCache) Subscribe(ctx context.Context, channel string, handler func(message []byte)) error {
+	pubsub := r.client.Subscribe(ctx, channel)
+	defer pubsub.Close()
+
+	// Wait for the subscription to be confirmed so no later publish is missed
+	if _, err := pubsub.Receive(ctx); err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return NewCacheError("failed to subscribe", true).WithError(err)
+	}
+
+	messages := pubsub.Channel()
+	for {
+		select {
+		case msg, ok := <-messages:
+			if !ok {
+				return nil
+			}
+			handler([]byte(msg.Payload))
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
 // Ping checks if the cache is healthy
 func (r *RedisCache) Ping(ctx context.Context) error {
 	if err := r.client.Ping(ctx).Err(); err != nil {
@@ -196,6 +543,11 @@ func (r *RedisCache) Stats() *redis.PoolStats {
 
 // FlushDB flushes the current database (use with caution!)
 func (r *RedisCache) FlushDB(ctx context.Context) error {
+	if cluster, ok := r.client.(*redis.ClusterClient); ok {
+		return cluster.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+			return client.FlushDB(ctx).Err()
+		})
+	}
 	return r.client.FlushDB(ctx).Err()
 }
 
@@ -232,3 +584,28 @@ func (r *RedisCache) Expire(ctx context.Context, key string, ttl time.Duration)
 
 	return nil
 }
+
+// hashTag returns the portion of a key Redis Cluster hashes to pick a slot:
+// the contents of the first non-empty {...} section, or the whole key
+func hashTag(key string) string {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			return key[start+1 : start+1+end]
+		}
+	}
+	return key
+}
+
+// sameHashSlot reports whether all keys are guaranteed to hash to the same slot
+func sameHashSlot(keys []string) bool {
+	if len(keys) < 2 {
+		return true
+	}
+	tag := hashTag(keys[0])
+	for _, key := range keys[1:] {
+		if hashTag(key) != tag {
+			return false
+		}
+	}
+	return true
+}
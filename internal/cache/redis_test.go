@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/birbparty/birb-nest/internal/instance"
+)
+
+func TestHashTag(t *testing.T) {
+	tests := []struct {
+		name string
+		key  string
+		want string
+	}{
+		{name: "tagged instance key", key: "instance:{inst_123}:cache:player", want: "inst_123"},
+		{name: "untagged key", key: "registry:instance:inst_123", want: "registry:instance:inst_123"},
+		{name: "empty tag", key: "instance:{}:cache:player", want: "instance:{}:cache:player"},
+		{name: "unterminated tag", key: "instance:{inst_123:cache", want: "instance:{inst_123:cache"},
+		{name: "first tag wins", key: "{a}:{b}", want: "a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hashTag(tt.key); got != tt.want {
+				t.Errorf("hashTag(%q) = %q, want %q", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSameHashSlot(t *testing.T) {
+	kb1 := instance.NewKeyBuilder("inst_game1")
+	kb2 := instance.NewKeyBuilder("inst_game2")
+
+	t.Run("keys of one instance share a slot", func(t *testing.T) {
+		keys := []string{kb1.CacheKey("a"), kb1.CacheKey("b"), kb1.TableKey("users", "1")}
+		if !sameHashSlot(keys) {
+			t.Error("sameHashSlot() = false for keys of a single instance")
+		}
+	})
+
+	t.Run("keys of different instances", func(t *testing.T) {
+		keys := []string{kb1.CacheKey("a"), kb2.CacheKey("a")}
+		if sameHashSlot(keys) {
+			t.Error("sameHashSlot() = true for keys of different instances")
+		}
+	})
+
+	t.Run("single key", func(t *testing.T) {
+		if !sameHashSlot([]string{"anything"}) {
+			t.Error("sameHashSlot() = false for a single key")
+		}
+	})
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{name: "default standalone", cfg: Config{}, wantErr: false},
+		{name: "sentinel", cfg: Config{Mode: ModeSentinel, SentinelMasterName: "mymaster", SentinelAddrs: []string{"s1:26379"}}, wantErr: false},
+		{name: "sentinel without master", cfg: Config{Mode: ModeSentinel, SentinelAddrs: []string{"s1:26379"}}, wantErr: true},
+		{name: "sentinel without addrs", cfg: Config{Mode: ModeSentinel, SentinelMasterName: "mymaster"}, wantErr: true},
+		{name: "cluster", cfg: Config{Mode: ModeCluster, ClusterAddrs: []string{"n1:6379", "n2:6379"}}, wantErr: false},
+		{name: "cluster without addrs", cfg: Config{Mode: ModeCluster}, wantErr: true},
+		{name: "cluster with non-zero DB", cfg: Config{Mode: ModeCluster, ClusterAddrs: []string{"n1:6379"}, DB: 2}, wantErr: true},
+		{name: "unknown mode", cfg: Config{Mode: "replicated"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSplitAddrs(t *testing.T) {
+	got := SplitAddrs(" s1:26379, s2:26379,,s3:26379 ")
+	want := []string{"s1:26379", "s2:26379", "s3:26379"}
+	if len(got) != len(want) {
+		t.Fatalf("SplitAddrs() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("SplitAddrs()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+
+	if addrs := SplitAddrs(""); len(addrs) != 0 {
+		t.Errorf("SplitAddrs(\"\") = %v, want empty", addrs)
+	}
+}
@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/birbparty/birb-nest/internal/instance"
+)
+
+// ErrUsageUnsupported is returned when the cache backend cannot track usage
+var ErrUsageUnsupported = NewCacheError("cache backend does not track usage", false)
+
+// InstanceUsage is the number of keys and value bytes an instance holds in the cache
+type InstanceUsage struct {
+	Keys  int64 `json:"keys"`
+	Bytes int64 `json:"bytes"`
+}
+
+// GetInstanceUsage returns the tracked cache usage of an instance
+func GetInstanceUsage(ctx context.Context, client Cache, instanceID string) (*InstanceUsage, error) {
+	tracker, ok := client.(UsageTracker)
+	if !ok {
+		return nil, ErrUsageUnsupported
+	}
+
+	keys, bytes, err := tracker.Usage(ctx, instance.NewKeyBuilder(instanceID).UsageKey())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cache usage: %w", err)
+	}
+	return &InstanceUsage{Keys: keys, Bytes: bytes}, nil
+}
+
+// RecountInstanceUsage counts an instance's cached keys and bytes and resets
+// its usage counters to match. This corrects drift from expired and evicted
+// keys and from bulk loads that bypass tracking; writes racing with the
+// recount may be miscounted until the next one.
+func RecountInstanceUsage(ctx context.Context, client Cache, instanceID string) (*InstanceUsage, error) {
+	tracker, ok := client.(UsageTracker)
+	if !ok {
+		return nil, ErrUsageUnsupported
+	}
+	scanner, ok := client.(Scanner)
+	if !ok {
+		return nil, ErrUsageUnsupported
+	}
+
+	kb := instance.NewKeyBuilder(instanceID)
+	keys, err := scanner.Scan(ctx, kb.BuildPattern("cache"), 1000)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan cache keys: %w", err)
+	}
+
+	usage := &InstanceUsage{}
+	for start := 0; start < len(keys); start += 1000 {
+		end := start + 1000
+		if end > len(keys) {
+			end = len(keys)
+		}
+		values, err := client.GetMultiple(ctx, keys[start:end])
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cache keys: %w", err)
+		}
+		for _, value := range values {
+			usage.Keys++
+			usage.Bytes += int64(len(value))
+		}
+	}
+
+	if err := tracker.SetUsage(ctx, kb.UsageKey(), usage.Keys, usage.Bytes); err != nil {
+		return nil, fmt.Errorf("failed to reset cache usage: %w", err)
+	}
+	return usage, nil
+}
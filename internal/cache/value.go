@@ -0,0 +1,167 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"strings"
+)
+
+const (
+	// ContentTypeJSON is the content type of JSON values, which are stored as is
+	ContentTypeJSON = "application/json"
+
+	// ContentTypeBinary is the content type of opaque values sent without one
+	ContentTypeBinary = "application/octet-stream"
+
+	// ContentTypeManifest is the content type of manifests of values stored
+	// in chunks; see Manifest
+	ContentTypeManifest = "application/vnd.birbnest.manifest+json"
+
+	// ContentTypeSealed is the content type of values encrypted at rest; see
+	// Sealer. The body is opaque to everything but the sealer.
+	ContentTypeSealed = "application/vnd.birbnest.sealed"
+)
+
+var (
+	// typedPrefix starts values stored with their content type. JSON cannot
+	// start with a NUL byte, so plain JSON values are never mistaken for typed ones.
+	typedPrefix = []byte("\x00ct\x00")
+
+	// compressedPrefix starts compressed values, which are stored with their
+	// content encoding and content type
+	compressedPrefix = []byte("\x00cz\x00")
+
+	// manifestPrefix starts manifests of values stored in chunks
+	manifestPrefix = []byte("\x00cm\x00")
+
+	// sealedPrefix starts values encrypted at rest
+	sealedPrefix = []byte("\x00ce\x00")
+)
+
+// EncodeValue returns a value as it is cached and persisted. Values that
+// DecodeValue would type correctly on their own are kept as is: JSON sent as
+// application/json and opaque bytes that are not JSON. Anything else is
+// prefixed with its content type so it survives the round trip.
+// Manifests and sealed values have prefixes of their own.
+func EncodeValue(contentType string, body []byte) []byte {
+	valid := json.Valid(body)
+	contentType = contentTypeOf(contentType, valid)
+	switch {
+	case contentType == ContentTypeManifest:
+		return frame(manifestPrefix, body)
+	case contentType == ContentTypeSealed:
+		return frame(sealedPrefix, body)
+	case contentType == ContentTypeJSON && valid:
+		return body
+	case contentType == ContentTypeBinary && !valid && !isFramed(body):
+		return body
+	}
+	return frame(typedPrefix, body, contentType)
+}
+
+// EncodeCompressed returns a body compressed with a content encoding as it
+// is cached and persisted
+func EncodeCompressed(contentType, encoding string, body []byte) []byte {
+	return frame(compressedPrefix, body, encoding, contentType)
+}
+
+// ParseValue splits a value encoded by EncodeValue or EncodeCompressed into
+// its content type, its content encoding ("" unless compressed) and its body
+// as stored. Values written before content types were kept are JSON when they
+// parse as JSON and binary otherwise.
+func ParseValue(value []byte) (string, string, []byte) {
+	if rest, ok := bytes.CutPrefix(value, compressedPrefix); ok {
+		if encoding, rest, ok := bytes.Cut(rest, []byte{0}); ok {
+			if contentType, body, ok := bytes.Cut(rest, []byte{0}); ok {
+				return string(contentType), string(encoding), body
+			}
+		}
+	}
+	if body, ok := bytes.CutPrefix(value, manifestPrefix); ok {
+		return ContentTypeManifest, "", body
+	}
+	if body, ok := bytes.CutPrefix(value, sealedPrefix); ok {
+		return ContentTypeSealed, "", body
+	}
+	if rest, ok := bytes.CutPrefix(value, typedPrefix); ok {
+		if contentType, body, ok := bytes.Cut(rest, []byte{0}); ok {
+			return string(contentType), "", body
+		}
+	}
+	if json.Valid(value) {
+		return ContentTypeJSON, "", value
+	}
+	return ContentTypeBinary, "", value
+}
+
+// DecodeValue returns the content type and the decompressed body of a value
+// encoded by EncodeValue or EncodeCompressed
+func DecodeValue(value []byte) (string, []byte, error) {
+	contentType, encoding, body := ParseValue(value)
+	if encoding == "" {
+		return contentType, body, nil
+	}
+	body, err := Decompress(encoding, body)
+	if err != nil {
+		return "", nil, err
+	}
+	return contentType, body, nil
+}
+
+// IsSealed reports whether a value was encoded as ContentTypeSealed. Client
+// values never are: EncodeValue frames every body that starts with a NUL byte.
+func IsSealed(value []byte) bool {
+	return bytes.HasPrefix(value, sealedPrefix)
+}
+
+// IsReservedContentType reports whether a media type is used for values the
+// server writes itself, which clients must not send
+func IsReservedContentType(mediaType string) bool {
+	return mediaType == ContentTypeManifest || mediaType == ContentTypeSealed
+}
+
+// IsJSONContentType reports whether a content type names JSON, such as
+// application/json or application/problem+json
+func IsJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == ContentTypeJSON || strings.HasSuffix(mediaType, "+json")
+}
+
+// contentTypeOf returns the content type a value is stored with: the one it
+// was sent with, or JSON or binary depending on whether it is valid JSON
+func contentTypeOf(contentType string, validJSON bool) string {
+	contentType = strings.TrimSpace(contentType)
+	switch {
+	case contentType != "":
+		return contentType
+	case validJSON:
+		return ContentTypeJSON
+	}
+	return ContentTypeBinary
+}
+
+// isFramed reports whether a body could be mistaken for an encoded value
+// that is not stored as is. Every prefix starts with a NUL byte, including
+// ones added later, so any such body is framed.
+func isFramed(body []byte) bool {
+	return len(body) > 0 && body[0] == 0
+}
+
+// frame joins a prefix, NUL-terminated fields and a body
+func frame(prefix, body []byte, fields ...string) []byte {
+	size := len(prefix) + len(body)
+	for _, field := range fields {
+		size += len(field) + 1
+	}
+	encoded := make([]byte, 0, size)
+	encoded = append(encoded, prefix...)
+	for _, field := range fields {
+		encoded = append(encoded, field...)
+		encoded = append(encoded, 0)
+	}
+	return append(encoded, body...)
+}
@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncodeValue_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        []byte
+		wantType    string
+		wantPlain   bool // stored without a content type prefix
+	}{
+		{"json", "application/json", []byte(`{"a":1}`), ContentTypeJSON, true},
+		{"json without content type", "", []byte(`[1,2]`), ContentTypeJSON, true},
+		{"bytes without content type", "", []byte{0x01, 0x02}, ContentTypeBinary, true},
+		{"opaque bytes", ContentTypeBinary, []byte("9821f3fe"), ContentTypeBinary, true},
+		{"opaque bytes that parse as JSON", ContentTypeBinary, []byte(`42`), ContentTypeBinary, false},
+		{"image", "image/png", []byte{0x89, 'P', 'N', 'G', 0x00, 0xff}, "image/png", false},
+		{"json with parameters", "application/json; charset=utf-8", []byte(`{}`), "application/json; charset=utf-8", false},
+		{"invalid json", "application/json", []byte(`{nope`), ContentTypeJSON, false},
+		{"bytes that look typed", ContentTypeBinary, append(append([]byte{}, typedPrefix...), 'x'), ContentTypeBinary, false},
+		{"bytes that look compressed", ContentTypeBinary, append(append([]byte{}, compressedPrefix...), 'x'), ContentTypeBinary, false},
+		{"bytes that look sealed", "", append(append([]byte{}, sealedPrefix...), 'x'), ContentTypeBinary, false},
+		{"bytes starting with NUL", "", []byte{0x00, 'x', 0x00}, ContentTypeBinary, false},
+		{"sealed", ContentTypeSealed, []byte("1\x00key\x00data"), ContentTypeSealed, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded := EncodeValue(tt.contentType, tt.body)
+			if plain := bytes.Equal(encoded, tt.body); plain != tt.wantPlain {
+				t.Errorf("EncodeValue() = %q, stored plain %v, want %v", encoded, plain, tt.wantPlain)
+			}
+			if sealed := IsSealed(encoded); sealed != (tt.wantType == ContentTypeSealed) {
+				t.Errorf("IsSealed(%q) = %v", encoded, sealed)
+			}
+
+			contentType, body, err := DecodeValue(encoded)
+			if err != nil {
+				t.Fatalf("DecodeValue() error = %v", err)
+			}
+			if contentType != tt.wantType || !bytes.Equal(body, tt.body) {
+				t.Errorf("DecodeValue() = %q, %q, want %q, %q", contentType, body, tt.wantType, tt.body)
+			}
+		})
+	}
+}
+
+func TestIsJSONContentType(t *testing.T) {
+	for contentType, want := range map[string]bool{
+		"application/json":                true,
+		"application/json; charset=utf-8": true,
+		"application/problem+json":        true,
+		"Application/JSON":                true,
+		"text/plain":                      false,
+		"application/octet-stream":        false,
+		"":                                false,
+	} {
+		if got := IsJSONContentType(contentType); got != want {
+			t.Errorf("IsJSONContentType(%q) = %v, want %v", contentType, got, want)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/birbparty/birb-nest/internal/auth"
+	"github.com/jackc/pgx/v5"
+)
+
+// APIKeyRepository persists API keys in the api_keys table.
+// It implements auth.Store.
+type APIKeyRepository struct {
+	db *DB
+}
+
+// NewAPIKeyRepository creates a new API key repository
+func NewAPIKeyRepository(db *DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+var _ auth.Store = (*APIKeyRepository)(nil)
+
+const apiKeyColumns = `id, name, prefix, key_hash, scopes, instances, created_at, expires_at, last_used_at, revoked_at, replaced_by`
+
+// SaveKey creates or replaces a key
+func (r *APIKeyRepository) SaveKey(ctx context.Context, key *auth.Key) error {
+	scopes, err := json.Marshal(key.Scopes)
+	if err != nil {
+		return fmt.Errorf("failed to encode scopes: %w", err)
+	}
+	instances, err := json.Marshal(key.Instances)
+	if err != nil {
+		return fmt.Errorf("failed to encode instance patterns: %w", err)
+	}
+
+	_, err = r.db.Exec(ctx, `
+		INSERT INTO api_keys (`+apiKeyColumns+`)
+		VALUES ($1, $2, $3, $4, $5::jsonb, $6::jsonb, $7, $8, $9, $10, $11)
+		ON CONFLICT (id) DO UPDATE SET
+			name = EXCLUDED.name,
+			scopes = EXCLUDED.scopes,
+			instances = EXCLUDED.instances,
+			expires_at = EXCLUDED.expires_at,
+			last_used_at = GREATEST(api_keys.last_used_at, EXCLUDED.last_used_at),
+			revoked_at = EXCLUDED.revoked_at,
+			replaced_by = EXCLUDED.replaced_by
+	`, key.ID, key.Name, key.Prefix, key.Hash, string(scopes), string(instances),
+		key.CreatedAt, key.ExpiresAt, key.LastUsedAt, key.RevokedAt, key.ReplacedBy)
+	if err != nil {
+		return fmt.Errorf("failed to save API key: %w", err)
+	}
+	return nil
+}
+
+// GetKey returns a key by ID or auth.ErrKeyNotFound
+func (r *APIKeyRepository) GetKey(ctx context.Context, id string) (*auth.Key, error) {
+	return scanAPIKey(r.db.QueryRow(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE id = $1`, id))
+}
+
+// GetKeyByHash returns the key with a secret hash or auth.ErrKeyNotFound
+func (r *APIKeyRepository) GetKeyByHash(ctx context.Context, hash string) (*auth.Key, error) {
+	return scanAPIKey(r.db.QueryRow(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE key_hash = $1`, hash))
+}
+
+// ListKeys returns every key, newest first
+func (r *APIKeyRepository) ListKeys(ctx context.Context) ([]*auth.Key, error) {
+	rows, err := r.db.Query(ctx, `SELECT `+apiKeyColumns+` FROM api_keys ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []*auth.Key{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+	return keys, nil
+}
+
+// TouchKey records when a key was last used; earlier times are ignored
+func (r *APIKeyRepository) TouchKey(ctx context.Context, id string, at time.Time) error {
+	if _, err := r.db.Exec(ctx, `
+		UPDATE api_keys SET last_used_at = $2
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < $2)
+	`, id, at); err != nil {
+		return fmt.Errorf("failed to record API key use: %w", err)
+	}
+	return nil
+}
+
+// scanAPIKey reads an api_keys row
+func scanAPIKey(row pgx.Row) (*auth.Key, error) {
+	var (
+		key       auth.Key
+		scopes    []byte
+		instances []byte
+	)
+	if err := row.Scan(&key.ID, &key.Name, &key.Prefix, &key.Hash, &scopes, &instances,
+		&key.CreatedAt, &key.ExpiresAt, &key.LastUsedAt, &key.RevokedAt, &key.ReplacedBy); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, auth.ErrKeyNotFound
+		}
+		return nil, fmt.Errorf("failed to scan API key: %w", err)
+	}
+	if err := json.Unmarshal(scopes, &key.Scopes); err != nil {
+		return nil, fmt.Errorf("failed to decode scopes: %w", err)
+	}
+	if err := json.Unmarshal(instances, &key.Instances); err != nil {
+		return nil, fmt.Errorf("failed to decode instance patterns: %w", err)
+	}
+	return &key, nil
+}
@@ -25,18 +25,42 @@ func (r *CacheRepository) Get(ctx context.Context, key string) (*CacheEntry, err
 	return r.GetWithInstance(ctx, key, "global")
 }
 
-// GetWithInstance retrieves a cache entry by key and instance
+// layeredEntries selects an instance's own entries matching keyMatch and, for
+// instances bound to a base (template), base entries the instance has neither
+// overwritten nor deleted. Own entries have layer 0 and base entries layer 1.
+// keyMatch uses $1 for the key(s) and $2 is the instance ID.
+func layeredEntries(keyMatch string) string {
+	return fmt.Sprintf(`
+		SELECT key, value, value_bytes, content_type, content_encoding, created_at, updated_at, version, ttl, metadata, 0 AS layer
+		FROM cache_entries
+		WHERE %[1]s AND instance_id = $2
+		UNION ALL
+		SELECT e.key, e.value, e.value_bytes, e.content_type, e.content_encoding, e.created_at, e.updated_at, e.version, e.ttl, e.metadata, 1
+		FROM instance_bases b
+		JOIN cache_entries e ON e.instance_id = b.base_instance_id AND e.%[1]s
+		WHERE b.instance_id = $2
+		AND NOT EXISTS (
+			SELECT 1 FROM instance_whiteouts w WHERE w.instance_id = $2 AND w.key = e.key
+		)
+	`, keyMatch)
+}
+
+// GetWithInstance retrieves a cache entry by key and instance, reading through to the instance's template
 func (r *CacheRepository) GetWithInstance(ctx context.Context, key, instanceID string) (*CacheEntry, error) {
 	query := `
-		SELECT key, value, instance_id, created_at, updated_at, version, ttl, metadata
-		FROM cache_entries
-		WHERE key = $1 AND instance_id = $2
+		SELECT key, value, value_bytes, content_type, content_encoding, $2::text, created_at, updated_at, version, ttl, metadata
+		FROM (` + layeredEntries("key = $1") + `) layers
+		ORDER BY layer
+		LIMIT 1
 	`
 
 	var entry CacheEntry
 	err := r.db.QueryRow(ctx, query, key, instanceID).Scan(
 		&entry.Key,
 		&entry.Value,
+		&entry.ValueBytes,
+		&entry.ContentType,
+		&entry.ContentEncoding,
 		&entry.InstanceID,
 		&entry.CreatedAt,
 		&entry.UpdatedAt,
@@ -63,21 +87,26 @@ func (r *CacheRepository) GetWithInstance(ctx context.Context, key, instanceID s
 }
 
 // Set creates or updates a cache entry (backward compatibility)
-func (r *CacheRepository) Set(ctx context.Context, key string, value json.RawMessage, ttl *int, metadata json.RawMessage) error {
+func (r *CacheRepository) Set(ctx context.Context, key string, value []byte, ttl *int, metadata json.RawMessage) error {
 	return r.SetWithInstance(ctx, key, "global", value, ttl, metadata)
 }
 
-// SetWithInstance creates or updates a cache entry with instance awareness
-func (r *CacheRepository) SetWithInstance(ctx context.Context, key, instanceID string, value json.RawMessage, ttl *int, metadata json.RawMessage) error {
+// SetWithInstance creates or updates a cache entry with instance awareness.
+// value is encoded with cache.EncodeValue; see SplitValue for how it is stored.
+func (r *CacheRepository) SetWithInstance(ctx context.Context, key, instanceID string, value []byte, ttl *int, metadata json.RawMessage) error {
 	if metadata == nil {
 		metadata = json.RawMessage("{}")
 	}
+	stored := SplitValue(value)
 
 	query := `
-		INSERT INTO cache_entries (key, value, instance_id, ttl, metadata, version)
-		VALUES ($1, $2, $3, $4, $5, 1)
+		INSERT INTO cache_entries (key, value, value_bytes, content_type, content_encoding, instance_id, ttl, metadata, version)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, 1)
 		ON CONFLICT (instance_id, key) DO UPDATE SET
 			value = EXCLUDED.value,
+			value_bytes = EXCLUDED.value_bytes,
+			content_type = EXCLUDED.content_type,
+			content_encoding = EXCLUDED.content_encoding,
 			ttl = EXCLUDED.ttl,
 			metadata = EXCLUDED.metadata,
 			updated_at = CURRENT_TIMESTAMP,
@@ -86,7 +115,7 @@ func (r *CacheRepository) SetWithInstance(ctx context.Context, key, instanceID s
 	`
 
 	var version int
-	err := r.db.QueryRow(ctx, query, key, value, instanceID, ttl, metadata).Scan(&version)
+	err := r.db.QueryRow(ctx, query, key, stored.JSON, stored.Bytes, stored.ContentType, stored.ContentEncoding, instanceID, ttl, metadata).Scan(&version)
 	if err != nil {
 		return fmt.Errorf("failed to set cache entry: %w", err)
 	}
@@ -95,20 +124,21 @@ func (r *CacheRepository) SetWithInstance(ctx context.Context, key, instanceID s
 }
 
 // SetWithVersion creates or updates a cache entry with optimistic locking
-func (r *CacheRepository) SetWithVersion(ctx context.Context, key string, value json.RawMessage, ttl *int, metadata json.RawMessage, expectedVersion int) error {
+func (r *CacheRepository) SetWithVersion(ctx context.Context, key string, value []byte, ttl *int, metadata json.RawMessage, expectedVersion int) error {
 	if metadata == nil {
 		metadata = json.RawMessage("{}")
 	}
+	stored := SplitValue(value)
 
 	query := `
 		UPDATE cache_entries
-		SET value = $2, ttl = $3, metadata = $4, updated_at = CURRENT_TIMESTAMP
-		WHERE key = $1 AND version = $5
+		SET value = $2, value_bytes = $3, content_type = $4, content_encoding = $5, ttl = $6, metadata = $7, updated_at = CURRENT_TIMESTAMP
+		WHERE key = $1 AND version = $8
 		RETURNING version
 	`
 
 	var newVersion int
-	err := r.db.QueryRow(ctx, query, key, value, ttl, metadata, expectedVersion).Scan(&newVersion)
+	err := r.db.QueryRow(ctx, query, key, stored.JSON, stored.Bytes, stored.ContentType, stored.ContentEncoding, ttl, metadata, expectedVersion).Scan(&newVersion)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return ErrVersionMismatch
@@ -124,16 +154,31 @@ func (r *CacheRepository) Delete(ctx context.Context, key string) error {
 	return r.DeleteWithInstance(ctx, key, "global")
 }
 
-// DeleteWithInstance removes a cache entry with instance awareness
+// DeleteWithInstance removes a cache entry with instance awareness.
+// Deleting a key inherited from a template records a whiteout that hides it.
 func (r *CacheRepository) DeleteWithInstance(ctx context.Context, key, instanceID string) error {
-	query := `DELETE FROM cache_entries WHERE key = $1 AND instance_id = $2`
+	query := `
+		WITH deleted AS (
+			DELETE FROM cache_entries WHERE key = $1 AND instance_id = $2
+			RETURNING 1
+		), whiteout AS (
+			INSERT INTO instance_whiteouts (instance_id, key)
+			SELECT b.instance_id, $1
+			FROM instance_bases b
+			JOIN cache_entries e ON e.instance_id = b.base_instance_id AND e.key = $1
+			WHERE b.instance_id = $2
+			ON CONFLICT (instance_id, key) DO NOTHING
+			RETURNING 1
+		)
+		SELECT (SELECT COUNT(*) FROM deleted) + (SELECT COUNT(*) FROM whiteout)
+	`
 
-	result, err := r.db.Exec(ctx, query, key, instanceID)
-	if err != nil {
+	var affected int64
+	if err := r.db.QueryRow(ctx, query, key, instanceID).Scan(&affected); err != nil {
 		return fmt.Errorf("failed to delete cache entry: %w", err)
 	}
 
-	if result.RowsAffected() == 0 {
+	if affected == 0 {
 		return ErrNotFound
 	}
 
@@ -145,13 +190,17 @@ func (r *CacheRepository) Exists(ctx context.Context, key string) (bool, error)
 	return r.ExistsWithInstance(ctx, key, "global")
 }
 
-// ExistsWithInstance checks if a cache entry exists with instance awareness
+// ExistsWithInstance checks if a cache entry exists with instance awareness, including template keys
 func (r *CacheRepository) ExistsWithInstance(ctx context.Context, key, instanceID string) (bool, error) {
 	query := `
 		SELECT EXISTS(
-			SELECT 1 FROM cache_entries 
-			WHERE key = $1 AND instance_id = $2
-			AND (ttl IS NULL OR updated_at + interval '1 second' * ttl > CURRENT_TIMESTAMP)
+			SELECT 1 FROM (
+				SELECT ttl, updated_at
+				FROM (` + layeredEntries("key = $1") + `) layers
+				ORDER BY layer
+				LIMIT 1
+			) top
+			WHERE ttl IS NULL OR updated_at + interval '1 second' * ttl > CURRENT_TIMESTAMP
 		)
 	`
 
@@ -171,7 +220,7 @@ func (r *CacheRepository) BatchGet(ctx context.Context, keys []string) ([]*Cache
 	}
 
 	query := `
-		SELECT key, value, instance_id, created_at, updated_at, version, ttl, metadata
+		SELECT key, value, value_bytes, content_type, content_encoding, instance_id, created_at, updated_at, version, ttl, metadata
 		FROM cache_entries
 		WHERE key = ANY($1)
 	`
@@ -188,6 +237,9 @@ func (r *CacheRepository) BatchGet(ctx context.Context, keys []string) ([]*Cache
 		err := rows.Scan(
 			&entry.Key,
 			&entry.Value,
+			&entry.ValueBytes,
+			&entry.ContentType,
+			&entry.ContentEncoding,
 			&entry.InstanceID,
 			&entry.CreatedAt,
 			&entry.UpdatedAt,
@@ -212,16 +264,16 @@ func (r *CacheRepository) BatchGet(ctx context.Context, keys []string) ([]*Cache
 	return entries, nil
 }
 
-// BatchGetWithInstance retrieves multiple cache entries with instance awareness
+// BatchGetWithInstance retrieves multiple cache entries with instance awareness, including template keys
 func (r *CacheRepository) BatchGetWithInstance(ctx context.Context, keys []string, instanceID string) ([]*CacheEntry, error) {
 	if len(keys) == 0 {
 		return []*CacheEntry{}, nil
 	}
 
 	query := `
-		SELECT key, value, instance_id, created_at, updated_at, version, ttl, metadata
-		FROM cache_entries
-		WHERE key = ANY($1) AND instance_id = $2
+		SELECT DISTINCT ON (key) key, value, value_bytes, content_type, content_encoding, $2::text, created_at, updated_at, version, ttl, metadata
+		FROM (` + layeredEntries("key = ANY($1)") + `) layers
+		ORDER BY key, layer
 	`
 
 	rows, err := r.db.Query(ctx, query, keys, instanceID)
@@ -236,6 +288,9 @@ func (r *CacheRepository) BatchGetWithInstance(ctx context.Context, keys []strin
 		err := rows.Scan(
 			&entry.Key,
 			&entry.Value,
+			&entry.ValueBytes,
+			&entry.ContentType,
+			&entry.ContentEncoding,
 			&entry.InstanceID,
 			&entry.CreatedAt,
 			&entry.UpdatedAt,
@@ -413,4 +468,5 @@ func (r *CacheRepository) RecordMetric(ctx context.Context, operation string, ke
 var (
 	ErrNotFound        = errors.New("cache entry not found")
 	ErrVersionMismatch = errors.New("version mismatch")
+	ErrUnsupported     = errors.New("not supported by this database backend")
 )
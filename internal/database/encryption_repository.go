@@ -0,0 +1,139 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/birbparty/birb-nest/internal/cache"
+	"github.com/birbparty/birb-nest/internal/encryption"
+	"github.com/jackc/pgx/v5"
+)
+
+// EncryptionRepository persists data keys in the instance_data_keys table and
+// rewrites encrypted values in cache_entries.
+// It implements encryption.KeyStore and encryption.EntryStore.
+type EncryptionRepository struct {
+	db *DB
+}
+
+// NewEncryptionRepository creates a new encryption repository
+func NewEncryptionRepository(db *DB) *EncryptionRepository {
+	return &EncryptionRepository{db: db}
+}
+
+var (
+	_ encryption.KeyStore   = (*EncryptionRepository)(nil)
+	_ encryption.EntryStore = (*EncryptionRepository)(nil)
+)
+
+const dataKeyColumns = `id, instance_id, master_key_id, wrapped_key, created_at, retired_at`
+
+// SaveDataKey creates a data key or records its retirement
+func (r *EncryptionRepository) SaveDataKey(ctx context.Context, key *encryption.DataKey) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO instance_data_keys (`+dataKeyColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (id) DO UPDATE SET retired_at = EXCLUDED.retired_at
+	`, key.ID, key.InstanceID, key.MasterKeyID, key.Wrapped, key.CreatedAt, key.RetiredAt)
+	if err != nil {
+		return fmt.Errorf("failed to save data key: %w", err)
+	}
+	return nil
+}
+
+// GetDataKey returns a data key by ID or encryption.ErrDataKeyNotFound
+func (r *EncryptionRepository) GetDataKey(ctx context.Context, id string) (*encryption.DataKey, error) {
+	return scanDataKey(r.db.QueryRow(ctx, `SELECT `+dataKeyColumns+` FROM instance_data_keys WHERE id = $1`, id))
+}
+
+// ActiveDataKey returns an instance's newest data key that is not retired
+func (r *EncryptionRepository) ActiveDataKey(ctx context.Context, instanceID string) (*encryption.DataKey, error) {
+	return scanDataKey(r.db.QueryRow(ctx, `
+		SELECT `+dataKeyColumns+` FROM instance_data_keys
+		WHERE instance_id = $1 AND retired_at IS NULL
+		ORDER BY created_at DESC LIMIT 1
+	`, instanceID))
+}
+
+// ListDataKeys returns an instance's data keys, newest first
+func (r *EncryptionRepository) ListDataKeys(ctx context.Context, instanceID string) ([]*encryption.DataKey, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT `+dataKeyColumns+` FROM instance_data_keys
+		WHERE instance_id = $1 ORDER BY created_at DESC
+	`, instanceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list data keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []*encryption.DataKey{}
+	for rows.Next() {
+		key, err := scanDataKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list data keys: %w", err)
+	}
+	return keys, nil
+}
+
+// StaleEntries returns entries of an instance whose values are not sealed
+// with keyID, in key order after the key after
+func (r *EncryptionRepository) StaleEntries(ctx context.Context, instanceID, keyID, after string, limit int) ([]encryption.Entry, error) {
+	prefix := encryption.SealedPrefix(keyID)
+	rows, err := r.db.Query(ctx, `
+		SELECT key, value, value_bytes, content_type, content_encoding FROM cache_entries
+		WHERE instance_id = $1 AND key > $2
+		  AND (content_type <> $3 OR value_bytes IS NULL
+		       OR substring(value_bytes FROM 1 FOR $4) <> $5)
+		ORDER BY key LIMIT $6
+	`, instanceID, after, cache.ContentTypeSealed, len(prefix), prefix, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stale entries: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []encryption.Entry{}
+	for rows.Next() {
+		var row CacheEntry
+		if err := rows.Scan(&row.Key, &row.Value, &row.ValueBytes, &row.ContentType, &row.ContentEncoding); err != nil {
+			return nil, fmt.Errorf("failed to scan entry: %w", err)
+		}
+		entries = append(entries, encryption.Entry{Key: row.Key, Value: row.Data()})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list stale entries: %w", err)
+	}
+	return entries, nil
+}
+
+// ReplaceEntry sets an entry's value only if it still holds old
+func (r *EncryptionRepository) ReplaceEntry(ctx context.Context, instanceID, key string, old, value []byte) (bool, error) {
+	from, to := SplitValue(old), SplitValue(value)
+	tag, err := r.db.Exec(ctx, `
+		UPDATE cache_entries SET value = $7::jsonb, value_bytes = $8, content_type = $9, content_encoding = $10
+		WHERE instance_id = $1 AND key = $2 AND content_type = $3 AND content_encoding = $4
+		  AND value IS NOT DISTINCT FROM $5::jsonb AND value_bytes IS NOT DISTINCT FROM $6
+	`, instanceID, key, from.ContentType, from.ContentEncoding, from.JSON, from.Bytes,
+		to.JSON, to.Bytes, to.ContentType, to.ContentEncoding)
+	if err != nil {
+		return false, fmt.Errorf("failed to replace entry: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// scanDataKey reads an instance_data_keys row
+func scanDataKey(row pgx.Row) (*encryption.DataKey, error) {
+	var key encryption.DataKey
+	if err := row.Scan(&key.ID, &key.InstanceID, &key.MasterKeyID, &key.Wrapped, &key.CreatedAt, &key.RetiredAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, encryption.ErrDataKeyNotFound
+		}
+		return nil, fmt.Errorf("failed to scan data key: %w", err)
+	}
+	return &key, nil
+}
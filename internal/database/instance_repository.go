@@ -0,0 +1,257 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/birbparty/birb-nest/internal/instance"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// InstanceRepository persists instance contexts in the instances table.
+// It implements instance.Store.
+type InstanceRepository struct {
+	db *DB
+}
+
+// NewInstanceRepository creates a new instance repository
+func NewInstanceRepository(db *DB) *InstanceRepository {
+	return &InstanceRepository{db: db}
+}
+
+var (
+	_ instance.Store         = (*InstanceRepository)(nil)
+	_ instance.TransitionLog = (*InstanceRepository)(nil)
+)
+
+const instanceColumns = `instance_id, game_type, region, status, is_permanent, resource_quota, metadata, created_at, last_active, version`
+
+// Load returns an instance or instance.ErrInstanceNotFound
+func (r *InstanceRepository) Load(ctx context.Context, instanceID string) (*instance.Context, error) {
+	instCtx, err := scanInstance(r.db.QueryRow(ctx,
+		`SELECT `+instanceColumns+` FROM instances WHERE instance_id = $1`, instanceID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, instance.ErrInstanceNotFound
+	}
+	return instCtx, err
+}
+
+// Save creates or replaces an instance. A write carrying an older version
+// than the stored row is ignored, so delayed writers cannot undo newer changes.
+func (r *InstanceRepository) Save(ctx context.Context, instCtx *instance.Context) error {
+	return saveInstance(ctx, r.db, instCtx)
+}
+
+// Create inserts a new instance and its creation transition in one
+// transaction, or returns instance.ErrInstanceExists if the row is taken
+func (r *InstanceRepository) Create(ctx context.Context, instCtx *instance.Context, transition *instance.Transition) error {
+	quota, metadata, err := encodeInstance(instCtx)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := tx.Exec(ctx, `
+		INSERT INTO instances (`+instanceColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6::jsonb, $7::jsonb, $8, $9, $10)
+		ON CONFLICT (instance_id) DO NOTHING
+	`, instCtx.InstanceID, instCtx.GameType, instCtx.Region, string(instCtx.Status), instCtx.IsPermanent,
+		quota, metadata, instCtx.CreatedAt, instCtx.LastActive, instCtx.Version)
+	if err != nil {
+		return fmt.Errorf("failed to create instance: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return instance.ErrInstanceExists
+	}
+	if err := recordTransition(ctx, tx, transition); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit instance: %w", err)
+	}
+	return nil
+}
+
+// SaveTransition saves an instance and appends a status transition in one transaction
+func (r *InstanceRepository) SaveTransition(ctx context.Context, instCtx *instance.Context, transition *instance.Transition) error {
+	tx, err := r.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := saveInstance(ctx, tx, instCtx); err != nil {
+		return err
+	}
+	if err := recordTransition(ctx, tx, transition); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transition: %w", err)
+	}
+	return nil
+}
+
+// ListTransitions returns an instance's status transitions, newest first
+func (r *InstanceRepository) ListTransitions(ctx context.Context, instanceID string, limit int) ([]instance.Transition, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT instance_id, from_status, to_status, reason, actor, version, created_at
+		FROM instance_status_history
+		WHERE instance_id = $1
+		ORDER BY id DESC
+		LIMIT $2
+	`, instanceID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list transitions: %w", err)
+	}
+	defer rows.Close()
+
+	transitions := []instance.Transition{}
+	for rows.Next() {
+		var (
+			t        instance.Transition
+			from, to string
+		)
+		if err := rows.Scan(&t.InstanceID, &from, &to, &t.Reason, &t.Actor, &t.Version, &t.At); err != nil {
+			return nil, fmt.Errorf("failed to scan transition: %w", err)
+		}
+		t.From = instance.InstanceStatus(from)
+		t.To = instance.InstanceStatus(to)
+		transitions = append(transitions, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list transitions: %w", err)
+	}
+	return transitions, nil
+}
+
+// execer is satisfied by both *DB and pgx.Tx
+type execer interface {
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+}
+
+// recordTransition appends a status transition to the instance's history
+func recordTransition(ctx context.Context, db execer, transition *instance.Transition) error {
+	if _, err := db.Exec(ctx, `
+		INSERT INTO instance_status_history (instance_id, from_status, to_status, reason, actor, version, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, transition.InstanceID, string(transition.From), string(transition.To), transition.Reason, transition.Actor,
+		transition.Version, transition.At); err != nil {
+		return fmt.Errorf("failed to record transition: %w", err)
+	}
+	return nil
+}
+
+// encodeInstance returns the JSON columns of an instance row
+func encodeInstance(instCtx *instance.Context) (quota, metadata string, err error) {
+	quotaJSON, err := json.Marshal(instCtx.ResourceQuota)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to encode resource quota: %w", err)
+	}
+	metadataJSON, err := json.Marshal(instCtx.Metadata)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to encode metadata: %w", err)
+	}
+	return string(quotaJSON), string(metadataJSON), nil
+}
+
+// saveInstance upserts an instance row unless the stored version is newer
+func saveInstance(ctx context.Context, db execer, instCtx *instance.Context) error {
+	quota, metadata, err := encodeInstance(instCtx)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(ctx, `
+		INSERT INTO instances (`+instanceColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6::jsonb, $7::jsonb, $8, $9, $10)
+		ON CONFLICT (instance_id) DO UPDATE SET
+			game_type = EXCLUDED.game_type,
+			region = EXCLUDED.region,
+			status = EXCLUDED.status,
+			is_permanent = EXCLUDED.is_permanent,
+			resource_quota = EXCLUDED.resource_quota,
+			metadata = EXCLUDED.metadata,
+			created_at = EXCLUDED.created_at,
+			last_active = EXCLUDED.last_active,
+			version = EXCLUDED.version
+		WHERE instances.version <= EXCLUDED.version
+	`, instCtx.InstanceID, instCtx.GameType, instCtx.Region, string(instCtx.Status), instCtx.IsPermanent,
+		quota, metadata, instCtx.CreatedAt, instCtx.LastActive, instCtx.Version)
+	if err != nil {
+		return fmt.Errorf("failed to save instance: %w", err)
+	}
+	return nil
+}
+
+// Delete removes an instance
+func (r *InstanceRepository) Delete(ctx context.Context, instanceID string) error {
+	if _, err := r.db.Exec(ctx, `DELETE FROM instances WHERE instance_id = $1`, instanceID); err != nil {
+		return fmt.Errorf("failed to delete instance: %w", err)
+	}
+	return nil
+}
+
+// List returns every stored instance ordered by ID
+func (r *InstanceRepository) List(ctx context.Context) ([]*instance.Context, error) {
+	rows, err := r.db.Query(ctx, `SELECT `+instanceColumns+` FROM instances ORDER BY instance_id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list instances: %w", err)
+	}
+	defer rows.Close()
+
+	var instances []*instance.Context
+	for rows.Next() {
+		instCtx, err := scanInstance(rows)
+		if err != nil {
+			return nil, err
+		}
+		instances = append(instances, instCtx)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list instances: %w", err)
+	}
+	return instances, nil
+}
+
+// scanInstance reads an instance row; JSON columns are decoded here so a NULL
+// quota maps to nil
+func scanInstance(row pgx.Row) (*instance.Context, error) {
+	var (
+		instCtx  instance.Context
+		status   string
+		quota    []byte
+		metadata []byte
+	)
+	if err := row.Scan(&instCtx.InstanceID, &instCtx.GameType, &instCtx.Region, &status, &instCtx.IsPermanent,
+		&quota, &metadata, &instCtx.CreatedAt, &instCtx.LastActive, &instCtx.Version); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan instance: %w", err)
+	}
+	instCtx.Status = instance.InstanceStatus(status)
+
+	if len(quota) > 0 && string(quota) != "null" {
+		if err := json.Unmarshal(quota, &instCtx.ResourceQuota); err != nil {
+			return nil, fmt.Errorf("failed to decode resource quota: %w", err)
+		}
+	}
+	instCtx.Metadata = make(map[string]string)
+	if len(metadata) > 0 {
+		if err := json.Unmarshal(metadata, &instCtx.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to decode metadata: %w", err)
+		}
+	}
+	return &instCtx, nil
+}
@@ -2,11 +2,13 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
-	"fmt"
-	"log"
 
+	"github.com/birbparty/birb-nest/internal/auth"
+	"github.com/birbparty/birb-nest/internal/encryption"
 	"github.com/birbparty/birb-nest/internal/instance"
+	"github.com/jackc/pgx/v5/stdlib"
 )
 
 // Interface defines the database operations interface
@@ -56,13 +58,41 @@ type Interface interface {
 	ExistsFromContext(ctx context.Context, key string) (bool, error)
 }
 
+// Features gives access to the stores behind features beyond cache entries.
+// Backends return ErrUnsupported for the features they do not provide.
+type Features interface {
+	// InstanceStore returns the persistent store of the instance registry
+	InstanceStore() (instance.Store, error)
+
+	// APIKeyStore returns the store of managed API keys
+	APIKeyStore() (auth.Store, error)
+
+	// EncryptionStore returns the store of data keys and encrypted entries
+	EncryptionStore() (EncryptionStore, error)
+
+	// StorageStats returns the rows and bytes an instance stores
+	StorageStats(ctx context.Context, instanceID string) (*instance.MonitoringStats, error)
+
+	// OperationsDB returns the connection pool instance operations run on
+	OperationsDB() (*DB, error)
+}
+
+// EncryptionStore stores data keys and finds and replaces entries to re-encrypt
+type EncryptionStore interface {
+	encryption.KeyStore
+	encryption.EntryStore
+}
+
 // PostgreSQLClient implements the Interface for PostgreSQL
 type PostgreSQLClient struct {
 	db         *DB
+	sqlDB      *sql.DB // the pool behind database/sql, for monitoring queries
 	repo       *CacheRepository
 	instanceID string
 }
 
+var _ Features = (*PostgreSQLClient)(nil)
+
 // NewPostgreSQLClient creates a new PostgreSQL client
 func NewPostgreSQLClient(cfg *Config, instanceID string) (Interface, error) {
 	db, err := NewDB(cfg)
@@ -72,6 +102,7 @@ func NewPostgreSQLClient(cfg *Config, instanceID string) (Interface, error) {
 
 	return &PostgreSQLClient{
 		db:         db,
+		sqlDB:      stdlib.OpenDBFromPool(db.Pool()),
 		repo:       NewCacheRepository(db),
 		instanceID: instanceID,
 	}, nil
@@ -88,7 +119,7 @@ func (c *PostgreSQLClient) GetWithInstance(ctx context.Context, key, instanceID
 	if err != nil {
 		return nil, err
 	}
-	return entry.Value, nil
+	return entry.Data(), nil
 }
 
 // Set stores a value in the database
@@ -96,27 +127,20 @@ func (c *PostgreSQLClient) Set(ctx context.Context, key string, value []byte) er
 	return c.SetWithInstance(ctx, key, c.instanceID, value)
 }
 
-// SetWithInstance stores a value with instance awareness
+// SetWithInstance stores a value with instance awareness. JSON values go to
+// the JSONB value column and anything else to value_bytes.
 func (c *PostgreSQLClient) SetWithInstance(ctx context.Context, key, instanceID string, value []byte) error {
-	// Validate that value is valid JSON before passing to repository
-	// The cache_entries.value column is JSONB and requires valid JSON
-	jsonValue := value
-
-	// Check if the value is already valid JSON
-	if !json.Valid(value) {
-		// Value is not valid JSON, wrap it as a JSON string
-		wrapped, err := json.Marshal(value)
-		if err != nil {
-			return fmt.Errorf("failed to encode non-JSON value as JSON: %w", err)
-		}
-		jsonValue = wrapped
-
-		// Log the conversion for debugging
-		log.Printf("Warning: Non-JSON value for key '%s' in instance '%s' was wrapped as JSON. Original length: %d bytes",
-			key, instanceID, len(value))
-	}
+	return c.repo.SetWithInstance(ctx, key, instanceID, value, nil, nil)
+}
+
+// SetEntry creates or updates a cache entry with TTL (seconds) and metadata
+func (c *PostgreSQLClient) SetEntry(ctx context.Context, key, instanceID string, value json.RawMessage, ttl *int, metadata json.RawMessage) error {
+	return c.repo.SetWithInstance(ctx, key, instanceID, value, ttl, metadata)
+}
 
-	return c.repo.SetWithInstance(ctx, key, instanceID, jsonValue, nil, nil)
+// BatchGetWithInstance retrieves multiple cache entries of an instance in one query
+func (c *PostgreSQLClient) BatchGetWithInstance(ctx context.Context, keys []string, instanceID string) ([]*CacheEntry, error) {
+	return c.repo.BatchGetWithInstance(ctx, keys, instanceID)
 }
 
 // Delete removes a value from the database
@@ -146,10 +170,41 @@ func (c *PostgreSQLClient) Health(ctx context.Context) error {
 
 // Close closes the database connection
 func (c *PostgreSQLClient) Close() error {
+	c.sqlDB.Close()
 	c.db.Close()
 	return nil
 }
 
+// DB returns the underlying connection pool for direct SQL access
+func (c *PostgreSQLClient) DB() *DB {
+	return c.db
+}
+
+// InstanceStore returns the instances table
+func (c *PostgreSQLClient) InstanceStore() (instance.Store, error) {
+	return NewInstanceRepository(c.db), nil
+}
+
+// APIKeyStore returns the api_keys table
+func (c *PostgreSQLClient) APIKeyStore() (auth.Store, error) {
+	return NewAPIKeyRepository(c.db), nil
+}
+
+// EncryptionStore returns the instance_data_keys table and cache entries
+func (c *PostgreSQLClient) EncryptionStore() (EncryptionStore, error) {
+	return NewEncryptionRepository(c.db), nil
+}
+
+// StorageStats returns the rows and bytes an instance stores
+func (c *PostgreSQLClient) StorageStats(ctx context.Context, instanceID string) (*instance.MonitoringStats, error) {
+	return instance.GetInstanceStats(ctx, c.sqlDB, instanceID)
+}
+
+// OperationsDB returns the underlying connection pool
+func (c *PostgreSQLClient) OperationsDB() (*DB, error) {
+	return c.db, nil
+}
+
 // GetFromContext retrieves a value using instance ID from context
 func (c *PostgreSQLClient) GetFromContext(ctx context.Context, key string) ([]byte, error) {
 	instanceID := instance.ExtractInstanceID(ctx)
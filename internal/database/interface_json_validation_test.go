@@ -1,11 +1,14 @@
 package database
 
 import (
+	"bytes"
 	"encoding/json"
 	"testing"
+
+	"github.com/birbparty/birb-nest/internal/cache"
 )
 
-// TestJSONValidation tests the JSON validation logic used in SetWithInstance
+// TestJSONValidation tests how SetWithInstance picks the column for a value
 func TestJSONValidation(t *testing.T) {
 	tests := []struct {
 		name          string
@@ -84,19 +87,18 @@ func TestJSONValidation(t *testing.T) {
 					tt.description, isValid, tt.shouldBeValid, string(tt.input))
 			}
 
-			// If invalid, test that marshaling wraps it properly
-			if !isValid {
-				wrapped, err := json.Marshal(tt.input)
-				if err != nil {
-					t.Errorf("Failed to marshal invalid input: %v", err)
-				}
-
-				// Verify the wrapped version is valid JSON
-				if !json.Valid(wrapped) {
-					t.Errorf("Wrapped value is not valid JSON: %q", string(wrapped))
-				}
+			// Valid JSON goes to the JSONB column; anything else is kept as bytes
+			stored := SplitValue(tt.input)
+			if isValid && (stored.ContentType != cache.ContentTypeJSON || !bytes.Equal(stored.JSON, tt.input) || stored.Bytes != nil) {
+				t.Errorf("SplitValue(%q) = %+v, want the JSON column", tt.input, stored)
+			}
+			if !isValid && (stored.ContentType != cache.ContentTypeBinary || stored.JSON != nil || !bytes.Equal(stored.Bytes, tt.input)) {
+				t.Errorf("SplitValue(%q) = %+v, want the bytes column", tt.input, stored)
+			}
 
-				t.Logf("Invalid input %q was wrapped as: %s", string(tt.input), string(wrapped))
+			// Join returns the value's canonical encoding, which frames bytes starting with NUL
+			if joined, want := stored.Join(), cache.EncodeValue("", tt.input); !bytes.Equal(joined, want) {
+				t.Errorf("Join() = %q, want %q", joined, want)
 			}
 		})
 	}
@@ -123,17 +125,59 @@ func TestJSONValidationWithProductionErrors(t *testing.T) {
 				t.Errorf("Expected %q to be invalid JSON, but it was valid", errToken)
 			}
 
-			// Verify wrapping makes them valid
-			wrapped, err := json.Marshal(input)
-			if err != nil {
-				t.Errorf("Failed to wrap %q: %v", errToken, err)
+			// They are stored byte for byte instead of being wrapped as JSON
+			if stored := SplitValue(input); stored.JSON != nil || string(stored.Bytes) != errToken {
+				t.Errorf("SplitValue(%q) = %+v, want the bytes column", errToken, stored)
 			}
+		})
+	}
+}
 
-			if !json.Valid(wrapped) {
-				t.Errorf("Wrapped version of %q is not valid JSON: %q", errToken, string(wrapped))
-			}
+// TestSplitValue_ContentTypes tests that content types pick the column
+func TestSplitValue_ContentTypes(t *testing.T) {
+	tests := []struct {
+		contentType string
+		body        string
+		wantJSON    bool
+	}{
+		{"application/json", `{"a":1}`, true},
+		{"application/problem+json", `{"title":"oops"}`, true},
+		{"application/json; charset=utf-8", `[1,2]`, true},
+		{"application/json", `not json`, false},
+		{"text/plain", `"looks like json"`, false},
+		{"image/png", "\x89PNG\x00", false},
+		{cache.ContentTypeSealed, "1\x00key\x00ciphertext", false},
+	}
 
-			t.Logf("Production error token %q wrapped as: %s", errToken, string(wrapped))
-		})
+	for _, tt := range tests {
+		encoded := cache.EncodeValue(tt.contentType, []byte(tt.body))
+		stored := SplitValue(encoded)
+		if stored.ContentType != tt.contentType {
+			t.Errorf("SplitValue() content type = %q, want %q", stored.ContentType, tt.contentType)
+		}
+		if (stored.JSON != nil) != tt.wantJSON || (stored.Bytes != nil) == tt.wantJSON {
+			t.Errorf("SplitValue(%s %q) = %+v, want JSON column %v", tt.contentType, tt.body, stored, tt.wantJSON)
+		}
+		if joined := stored.Join(); !bytes.Equal(joined, encoded) {
+			t.Errorf("Join() = %q, want %q", joined, encoded)
+		}
+	}
+}
+
+// TestSplitValue_Compressed tests that compressed values keep their encoding
+// and are stored as bytes even when they hold JSON
+func TestSplitValue_Compressed(t *testing.T) {
+	body, err := cache.Compress(cache.EncodingZstd, []byte(`{"a":1}`))
+	if err != nil {
+		t.Fatalf("Compress() error = %v", err)
+	}
+	encoded := cache.EncodeCompressed(cache.ContentTypeJSON, cache.EncodingZstd, body)
+
+	stored := SplitValue(encoded)
+	if stored.ContentEncoding != cache.EncodingZstd || stored.JSON != nil || !bytes.Equal(stored.Bytes, body) {
+		t.Errorf("SplitValue() = %+v, want the zstd body in the bytes column", stored)
+	}
+	if joined := stored.Join(); !bytes.Equal(joined, encoded) {
+		t.Errorf("Join() = %q, want %q", joined, encoded)
 	}
 }
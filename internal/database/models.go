@@ -5,18 +5,109 @@ import (
 	"encoding/json"
 	"errors"
 	"time"
+
+	"github.com/birbparty/birb-nest/internal/cache"
 )
 
-// CacheEntry represents a cache entry in the database
+// CacheEntry represents a cache entry in the database. Uncompressed JSON
+// values are kept in Value and any other value in ValueBytes.
 type CacheEntry struct {
-	Key        string          `db:"key" json:"key"`
-	Value      json.RawMessage `db:"value" json:"value"`
-	InstanceID string          `db:"instance_id" json:"instance_id"`
-	CreatedAt  time.Time       `db:"created_at" json:"created_at"`
-	UpdatedAt  time.Time       `db:"updated_at" json:"updated_at"`
-	Version    int             `db:"version" json:"version"`
-	TTL        *int            `db:"ttl" json:"ttl,omitempty"`
-	Metadata   json.RawMessage `db:"metadata" json:"metadata"`
+	Key             string          `db:"key" json:"key"`
+	Value           json.RawMessage `db:"value" json:"value,omitempty"`
+	ValueBytes      []byte          `db:"value_bytes" json:"value_bytes,omitempty"`
+	ContentType     string          `db:"content_type" json:"content_type"`
+	ContentEncoding string          `db:"content_encoding" json:"content_encoding,omitempty"`
+	InstanceID      string          `db:"instance_id" json:"instance_id"`
+	CreatedAt       time.Time       `db:"created_at" json:"created_at"`
+	UpdatedAt       time.Time       `db:"updated_at" json:"updated_at"`
+	Version         int             `db:"version" json:"version"`
+	TTL             *int            `db:"ttl" json:"ttl,omitempty"`
+	Metadata        json.RawMessage `db:"metadata" json:"metadata"`
+}
+
+// Data returns the entry's value encoded as it is cached
+func (c *CacheEntry) Data() []byte {
+	return StoredValue{c.ContentType, c.ContentEncoding, c.Value, c.ValueBytes}.Join()
+}
+
+// StoredValue is a value split across the cache_entries columns
+type StoredValue struct {
+	ContentType     string          // content_type
+	ContentEncoding string          // content_encoding; empty unless compressed
+	JSON            json.RawMessage // value (JSONB)
+	Bytes           []byte          // value_bytes (BYTEA)
+}
+
+// SplitValue separates a value encoded by cache.EncodeValue or
+// cache.EncodeCompressed into its columns. Valid JSON with a JSON content type
+// goes to the JSONB column; anything else, including compressed JSON, is kept
+// byte for byte.
+func SplitValue(value []byte) StoredValue {
+	contentType, encoding, body := cache.ParseValue(value)
+	if encoding == "" && cache.IsJSONContentType(contentType) && json.Valid(body) {
+		return StoredValue{ContentType: contentType, JSON: json.RawMessage(body)}
+	}
+	if body == nil {
+		body = []byte{}
+	}
+	return StoredValue{ContentType: contentType, ContentEncoding: encoding, Bytes: body}
+}
+
+// Join is the inverse of SplitValue. Rows written before content types were
+// stored have an empty content type and hold JSON.
+func (v StoredValue) Join() []byte {
+	contentType := v.ContentType
+	if contentType == "" {
+		contentType = cache.ContentTypeJSON
+	}
+	switch {
+	case v.ContentEncoding != "":
+		return cache.EncodeCompressed(contentType, v.ContentEncoding, v.Bytes)
+	case v.JSON != nil:
+		return cache.EncodeValue(contentType, v.JSON)
+	}
+	return cache.EncodeValue(contentType, v.Bytes)
+}
+
+// BackupEntry is a single JSONL line in an instance backup.
+// Incremental backups record deleted keys as entries with Deleted set and
+// UpdatedAt holding the deletion time.
+// Binary values are base64-encoded in ValueBytes.
+type BackupEntry struct {
+	InstanceID  string          `json:"instance_id"`
+	Key         string          `json:"key"`
+	Deleted     bool            `json:"deleted,omitempty"`
+	Value       json.RawMessage `json:"value,omitempty"`
+	ValueBytes  []byte          `json:"value_bytes,omitempty"`
+	ContentType string          `json:"content_type,omitempty"`
+	Encoding    string          `json:"content_encoding,omitempty"`
+	Version     int             `json:"version"`
+	TTL         *int            `json:"ttl,omitempty"`
+	Metadata    json.RawMessage `json:"metadata,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// NewBackupEntry converts a cache entry into its backup representation
+func NewBackupEntry(entry *CacheEntry) BackupEntry {
+	return BackupEntry{
+		InstanceID:  entry.InstanceID,
+		Key:         entry.Key,
+		Value:       entry.Value,
+		ValueBytes:  entry.ValueBytes,
+		ContentType: entry.ContentType,
+		Encoding:    entry.ContentEncoding,
+		Version:     entry.Version,
+		TTL:         entry.TTL,
+		Metadata:    entry.Metadata,
+		CreatedAt:   entry.CreatedAt,
+		UpdatedAt:   entry.UpdatedAt,
+	}
+}
+
+// Data returns the entry's value encoded with its content type
+func (e *BackupEntry) Data() []byte {
+	return StoredValue{e.ContentType, e.Encoding, e.Value, e.ValueBytes}.Join()
 }
 
 // DLQEntry represents a dead letter queue entry
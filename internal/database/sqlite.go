@@ -0,0 +1,591 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"time"
+
+	"github.com/birbparty/birb-nest/internal/auth"
+	"github.com/birbparty/birb-nest/internal/cache"
+	"github.com/birbparty/birb-nest/internal/instance"
+
+	// Pure-Go SQLite driver registered as "sqlite"
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteConfig holds embedded SQLite configuration
+type SQLiteConfig struct {
+	Path         string        // database file path, or ":memory:"
+	BusyTimeout  time.Duration // how long writers wait for the database lock
+	MaxOpenConns int
+}
+
+// sqliteSchema creates the cache_entries table mirroring the PostgreSQL
+// layout; value holds JSON and binary values alike
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS cache_entries (
+	instance_id  TEXT NOT NULL DEFAULT '',
+	key          TEXT NOT NULL,
+	value        BLOB NOT NULL,
+	content_type TEXT NOT NULL DEFAULT 'application/json',
+	content_encoding TEXT NOT NULL DEFAULT '',
+	created_at  INTEGER NOT NULL,
+	updated_at  INTEGER NOT NULL,
+	version     INTEGER NOT NULL DEFAULT 1,
+	ttl         INTEGER,
+	metadata    TEXT NOT NULL DEFAULT '{}',
+	PRIMARY KEY (instance_id, key)
+);
+CREATE INDEX IF NOT EXISTS idx_cache_entries_updated_at ON cache_entries(instance_id, updated_at);
+CREATE INDEX IF NOT EXISTS idx_cache_entries_ttl ON cache_entries(ttl) WHERE ttl IS NOT NULL;
+`
+
+// sqliteLiveCondition filters out entries whose TTL has elapsed; timestamps are unix nanoseconds
+const sqliteLiveCondition = `(ttl IS NULL OR ttl <= 0 OR updated_at + ttl * 1000000000 > ?)`
+
+// SQLiteClient implements the Interface for an embedded SQLite database
+type SQLiteClient struct {
+	db         *sql.DB
+	instanceID string
+}
+
+var _ Features = (*SQLiteClient)(nil)
+
+// NewSQLiteClient opens (and if needed creates) an embedded SQLite database
+func NewSQLiteClient(cfg *SQLiteConfig, instanceID string) (*SQLiteClient, error) {
+	if cfg == nil || cfg.Path == "" {
+		return nil, fmt.Errorf("sqlite path cannot be empty")
+	}
+
+	busyTimeout := cfg.BusyTimeout
+	if busyTimeout == 0 {
+		busyTimeout = 5 * time.Second
+	}
+
+	params := url.Values{}
+	params.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", busyTimeout.Milliseconds()))
+	params.Add("_pragma", "foreign_keys(1)")
+	if cfg.Path != ":memory:" {
+		params.Add("_pragma", "journal_mode(WAL)")
+		params.Add("_pragma", "synchronous(NORMAL)")
+	}
+
+	db, err := sql.Open("sqlite", "file:"+cfg.Path+"?"+params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+
+	// An in-memory database exists per connection, so it must not be pooled
+	maxOpen := cfg.MaxOpenConns
+	if cfg.Path == ":memory:" || maxOpen <= 0 {
+		maxOpen = 1
+	}
+	db.SetMaxOpenConns(maxOpen)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := db.ExecContext(ctx, sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize sqlite schema: %w", err)
+	}
+	if err := migrateSQLite(ctx, db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQLiteClient{
+		db:         db,
+		instanceID: instanceID,
+	}, nil
+}
+
+// sqliteColumns are the columns added after the first release, with their
+// definitions, in the order they were introduced
+var sqliteColumns = [][2]string{
+	{"content_type", "TEXT NOT NULL DEFAULT 'application/json'"},
+	{"content_encoding", "TEXT NOT NULL DEFAULT ''"},
+}
+
+// migrateSQLite adds columns introduced after a database file was created
+func migrateSQLite(ctx context.Context, db *sql.DB) error {
+	for _, column := range sqliteColumns {
+		var exists bool
+		if err := db.QueryRowContext(ctx,
+			`SELECT COUNT(*) > 0 FROM pragma_table_info('cache_entries') WHERE name = ?`, column[0],
+		).Scan(&exists); err != nil {
+			return fmt.Errorf("failed to inspect sqlite schema: %w", err)
+		}
+		if exists {
+			continue
+		}
+		if _, err := db.ExecContext(ctx,
+			`ALTER TABLE cache_entries ADD COLUMN `+column[0]+` `+column[1],
+		); err != nil {
+			return fmt.Errorf("failed to add %s column: %w", column[0], err)
+		}
+	}
+	return nil
+}
+
+// Get retrieves a value from the database
+func (c *SQLiteClient) Get(ctx context.Context, key string) ([]byte, error) {
+	return c.GetWithInstance(ctx, key, c.instanceID)
+}
+
+// GetWithInstance retrieves a value with instance awareness
+func (c *SQLiteClient) GetWithInstance(ctx context.Context, key, instanceID string) ([]byte, error) {
+	entry, err := c.GetEntry(ctx, key, instanceID)
+	if err != nil {
+		return nil, err
+	}
+	return entry.Data(), nil
+}
+
+// GetEntry retrieves a full cache entry by key and instance
+func (c *SQLiteClient) GetEntry(ctx context.Context, key, instanceID string) (*CacheEntry, error) {
+	query := `
+		SELECT key, value, content_type, content_encoding, instance_id, created_at, updated_at, version, ttl, metadata
+		FROM cache_entries
+		WHERE key = ? AND instance_id = ?
+	`
+
+	entry, err := scanSQLiteEntry(c.db.QueryRowContext(ctx, query, key, instanceID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get cache entry: %w", err)
+	}
+
+	// Check if entry is expired
+	if entry.IsExpired() {
+		_ = c.DeleteWithInstance(ctx, key, instanceID)
+		return nil, ErrNotFound
+	}
+
+	return entry, nil
+}
+
+// Set stores a value in the database
+func (c *SQLiteClient) Set(ctx context.Context, key string, value []byte) error {
+	return c.SetWithInstance(ctx, key, c.instanceID, value)
+}
+
+// SetWithInstance stores a value with instance awareness
+func (c *SQLiteClient) SetWithInstance(ctx context.Context, key, instanceID string, value []byte) error {
+	return c.setEntry(ctx, key, instanceID, value, nil, nil)
+}
+
+// SetEntry creates or updates a cache entry with TTL (seconds) and metadata
+func (c *SQLiteClient) SetEntry(ctx context.Context, key, instanceID string, value json.RawMessage, ttl *int, metadata json.RawMessage) error {
+	return c.setEntry(ctx, key, instanceID, value, ttl, metadata)
+}
+
+// setEntry stores a value encoded with cache.EncodeValue or cache.EncodeCompressed
+func (c *SQLiteClient) setEntry(ctx context.Context, key, instanceID string, value []byte, ttl *int, metadata json.RawMessage) error {
+	if metadata == nil {
+		metadata = json.RawMessage("{}")
+	}
+	contentType, encoding, body := cache.ParseValue(value)
+
+	now := time.Now().UnixNano()
+	query := `
+		INSERT INTO cache_entries (key, value, content_type, content_encoding, instance_id, ttl, metadata, version, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, 1, ?, ?)
+		ON CONFLICT (instance_id, key) DO UPDATE SET
+			value = excluded.value,
+			content_type = excluded.content_type,
+			content_encoding = excluded.content_encoding,
+			ttl = excluded.ttl,
+			metadata = excluded.metadata,
+			updated_at = excluded.updated_at,
+			version = cache_entries.version + 1
+	`
+
+	if _, err := c.db.ExecContext(ctx, query, key, body, contentType, encoding, instanceID, ttl, string(metadata), now, now); err != nil {
+		return fmt.Errorf("failed to set cache entry: %w", err)
+	}
+
+	return nil
+}
+
+// SetWithVersion updates a cache entry with optimistic locking
+func (c *SQLiteClient) SetWithVersion(ctx context.Context, key, instanceID string, value []byte, ttl *int, metadata json.RawMessage, expectedVersion int) error {
+	if metadata == nil {
+		metadata = json.RawMessage("{}")
+	}
+	contentType, encoding, body := cache.ParseValue(value)
+
+	query := `
+		UPDATE cache_entries
+		SET value = ?, content_type = ?, content_encoding = ?, ttl = ?, metadata = ?, updated_at = ?, version = version + 1
+		WHERE key = ? AND instance_id = ? AND version = ?
+	`
+
+	result, err := c.db.ExecContext(ctx, query, body, contentType, encoding, ttl, string(metadata), time.Now().UnixNano(), key, instanceID, expectedVersion)
+	if err != nil {
+		return fmt.Errorf("failed to update cache entry with version: %w", err)
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrVersionMismatch
+	}
+
+	return nil
+}
+
+// Delete removes a value from the database
+func (c *SQLiteClient) Delete(ctx context.Context, key string) error {
+	return c.DeleteWithInstance(ctx, key, c.instanceID)
+}
+
+// DeleteWithInstance removes a value with instance awareness
+func (c *SQLiteClient) DeleteWithInstance(ctx context.Context, key, instanceID string) error {
+	result, err := c.db.ExecContext(ctx, `DELETE FROM cache_entries WHERE key = ? AND instance_id = ?`, key, instanceID)
+	if err != nil {
+		return fmt.Errorf("failed to delete cache entry: %w", err)
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Exists checks if a key exists in the database
+func (c *SQLiteClient) Exists(ctx context.Context, key string) (bool, error) {
+	return c.ExistsWithInstance(ctx, key, c.instanceID)
+}
+
+// ExistsWithInstance checks if a key exists with instance awareness
+func (c *SQLiteClient) ExistsWithInstance(ctx context.Context, key, instanceID string) (bool, error) {
+	query := `
+		SELECT EXISTS(
+			SELECT 1 FROM cache_entries
+			WHERE key = ? AND instance_id = ? AND ` + sqliteLiveCondition + `
+		)
+	`
+
+	var exists bool
+	if err := c.db.QueryRowContext(ctx, query, key, instanceID, time.Now().UnixNano()).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to check cache entry existence: %w", err)
+	}
+
+	return exists, nil
+}
+
+// BatchGetWithInstance retrieves multiple cache entries with instance awareness
+func (c *SQLiteClient) BatchGetWithInstance(ctx context.Context, keys []string, instanceID string) ([]*CacheEntry, error) {
+	if len(keys) == 0 {
+		return []*CacheEntry{}, nil
+	}
+
+	args := make([]interface{}, 0, len(keys)+2)
+	args = append(args, instanceID, time.Now().UnixNano())
+	placeholders := ""
+	for i, key := range keys {
+		if i > 0 {
+			placeholders += ", "
+		}
+		placeholders += "?"
+		args = append(args, key)
+	}
+
+	query := `
+		SELECT key, value, content_type, content_encoding, instance_id, created_at, updated_at, version, ttl, metadata
+		FROM cache_entries
+		WHERE instance_id = ? AND ` + sqliteLiveCondition + ` AND key IN (` + placeholders + `)
+	`
+
+	rows, err := c.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to batch get cache entries: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []*CacheEntry
+	for rows.Next() {
+		entry, err := scanSQLiteEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan cache entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating cache entries: %w", err)
+	}
+
+	return entries, nil
+}
+
+// GetKeysByInstance returns live cache keys for a specific instance
+func (c *SQLiteClient) GetKeysByInstance(ctx context.Context, instanceID string, offset, limit int) ([]string, error) {
+	query := `
+		SELECT key
+		FROM cache_entries
+		WHERE instance_id = ? AND ` + sqliteLiveCondition + `
+		ORDER BY key
+		LIMIT ? OFFSET ?
+	`
+
+	rows, err := c.db.QueryContext(ctx, query, instanceID, time.Now().UnixNano(), limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get instance keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("failed to scan key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+// DeleteByInstance removes all entries for a specific instance
+func (c *SQLiteClient) DeleteByInstance(ctx context.Context, instanceID string) (int, error) {
+	result, err := c.db.ExecContext(ctx, `DELETE FROM cache_entries WHERE instance_id = ?`, instanceID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete instance entries: %w", err)
+	}
+
+	affected, _ := result.RowsAffected()
+	return int(affected), nil
+}
+
+// CleanupExpired removes up to batchSize expired entries
+func (c *SQLiteClient) CleanupExpired(ctx context.Context, batchSize int) (int, error) {
+	query := `
+		DELETE FROM cache_entries
+		WHERE rowid IN (
+			SELECT rowid FROM cache_entries
+			WHERE ttl IS NOT NULL AND ttl > 0 AND updated_at + ttl * 1000000000 <= ?
+			LIMIT ?
+		)
+	`
+
+	result, err := c.db.ExecContext(ctx, query, time.Now().UnixNano(), batchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to cleanup expired entries: %w", err)
+	}
+
+	affected, _ := result.RowsAffected()
+	return int(affected), nil
+}
+
+// BackupInstance exports instance data as plain JSONL, which archive readers accept as a legacy backup
+func (c *SQLiteClient) BackupInstance(ctx context.Context, instanceID string, w io.Writer) (int, error) {
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT key, value, content_type, content_encoding, instance_id, created_at, updated_at, version, ttl, metadata
+		FROM cache_entries
+		WHERE instance_id = ?
+		ORDER BY key
+	`, instanceID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query instance data: %w", err)
+	}
+	defer rows.Close()
+
+	encoder := json.NewEncoder(w)
+	count := 0
+	for rows.Next() {
+		entry, err := scanSQLiteEntry(rows)
+		if err != nil {
+			return count, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		if err := encoder.Encode(NewBackupEntry(entry)); err != nil {
+			return count, fmt.Errorf("failed to encode entry: %w", err)
+		}
+		count++
+	}
+
+	if err := rows.Err(); err != nil {
+		return count, fmt.Errorf("error iterating instance data: %w", err)
+	}
+
+	return count, nil
+}
+
+// RestoreInstance imports JSONL backup data into instanceID within a single transaction
+func (c *SQLiteClient) RestoreInstance(ctx context.Context, instanceID string, r io.Reader) (int, error) {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	insertQuery := `
+		INSERT INTO cache_entries (instance_id, key, value, content_type, content_encoding, version, ttl, metadata, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (instance_id, key) DO UPDATE SET
+			value = excluded.value,
+			content_type = excluded.content_type,
+			content_encoding = excluded.content_encoding,
+			version = excluded.version,
+			ttl = excluded.ttl,
+			metadata = excluded.metadata,
+			updated_at = excluded.updated_at
+	`
+
+	decoder := json.NewDecoder(r)
+	count := 0
+	for {
+		var entry BackupEntry
+		if err := decoder.Decode(&entry); err == io.EOF {
+			break
+		} else if err != nil {
+			return 0, fmt.Errorf("failed to decode entry: %w", err)
+		}
+
+		metadata := entry.Metadata
+		if metadata == nil {
+			metadata = json.RawMessage("{}")
+		}
+
+		contentType, encoding, body := cache.ParseValue(entry.Data())
+
+		// Use provided instanceID instead of the one in backup
+		if _, err := tx.ExecContext(ctx, insertQuery, instanceID, entry.Key, body, contentType, encoding,
+			entry.Version, entry.TTL, string(metadata), entry.CreatedAt.UnixNano(), entry.UpdatedAt.UnixNano()); err != nil {
+			return 0, fmt.Errorf("failed to insert entry: %w", err)
+		}
+		count++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return count, nil
+}
+
+// Health checks if the database is healthy
+func (c *SQLiteClient) Health(ctx context.Context) error {
+	return c.db.PingContext(ctx)
+}
+
+// Close closes the database connection
+func (c *SQLiteClient) Close() error {
+	return c.db.Close()
+}
+
+// DB returns the underlying database handle for direct access
+func (c *SQLiteClient) DB() *sql.DB {
+	return c.db
+}
+
+// InstanceStore returns ErrUnsupported; the registry lives in the cache only
+func (c *SQLiteClient) InstanceStore() (instance.Store, error) {
+	return nil, sqliteUnsupported("the persistent instance registry")
+}
+
+// APIKeyStore returns ErrUnsupported; only the root key and signed tokens work
+func (c *SQLiteClient) APIKeyStore() (auth.Store, error) {
+	return nil, sqliteUnsupported("API key management")
+}
+
+// EncryptionStore returns ErrUnsupported
+func (c *SQLiteClient) EncryptionStore() (EncryptionStore, error) {
+	return nil, sqliteUnsupported("encryption at rest")
+}
+
+// StorageStats returns the live rows and value bytes an instance stores
+func (c *SQLiteClient) StorageStats(ctx context.Context, instanceID string) (*instance.MonitoringStats, error) {
+	stats := &instance.MonitoringStats{InstanceID: instanceID}
+	err := c.db.QueryRowContext(ctx, `
+		SELECT COUNT(*), COALESCE(SUM(length(value)), 0) FROM cache_entries
+		WHERE instance_id = ? AND `+sqliteLiveCondition,
+		instanceID, time.Now().UnixNano()).Scan(&stats.RowCount, &stats.DataSizeBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get instance stats: %w", err)
+	}
+	return stats, nil
+}
+
+// OperationsDB returns ErrUnsupported; archives, backups, clones, templates,
+// hibernation and the reaper run on PostgreSQL
+func (c *SQLiteClient) OperationsDB() (*DB, error) {
+	return nil, sqliteUnsupported("instance operations")
+}
+
+// sqliteUnsupported wraps ErrUnsupported for a feature SQLite does not provide
+func sqliteUnsupported(feature string) error {
+	return fmt.Errorf("%s: %w (use PostgreSQL)", feature, ErrUnsupported)
+}
+
+// GetFromContext retrieves a value using instance ID from context
+func (c *SQLiteClient) GetFromContext(ctx context.Context, key string) ([]byte, error) {
+	return c.GetWithInstance(ctx, key, c.contextInstance(ctx))
+}
+
+// SetFromContext stores a value using instance ID from context
+func (c *SQLiteClient) SetFromContext(ctx context.Context, key string, value []byte) error {
+	return c.SetWithInstance(ctx, key, c.contextInstance(ctx), value)
+}
+
+// DeleteFromContext removes a value using instance ID from context
+func (c *SQLiteClient) DeleteFromContext(ctx context.Context, key string) error {
+	return c.DeleteWithInstance(ctx, key, c.contextInstance(ctx))
+}
+
+// ExistsFromContext checks if a key exists using instance ID from context
+func (c *SQLiteClient) ExistsFromContext(ctx context.Context, key string) (bool, error) {
+	return c.ExistsWithInstance(ctx, key, c.contextInstance(ctx))
+}
+
+// contextInstance returns the instance ID from context, falling back to the client default
+func (c *SQLiteClient) contextInstance(ctx context.Context) string {
+	if instanceID := instance.ExtractInstanceID(ctx); instanceID != "" {
+		return instanceID
+	}
+	return c.instanceID
+}
+
+// rowScanner abstracts *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanSQLiteEntry reads a cache entry row, converting unix-nanosecond timestamps
+func scanSQLiteEntry(row rowScanner) (*CacheEntry, error) {
+	var (
+		entry     CacheEntry
+		value     []byte
+		metadata  string
+		createdAt int64
+		updatedAt int64
+	)
+
+	if err := row.Scan(&entry.Key, &value, &entry.ContentType, &entry.ContentEncoding, &entry.InstanceID, &createdAt, &updatedAt,
+		&entry.Version, &entry.TTL, &metadata); err != nil {
+		return nil, err
+	}
+
+	// Match the PostgreSQL columns: uncompressed JSON in Value and anything else in ValueBytes
+	if entry.ContentEncoding == "" && cache.IsJSONContentType(entry.ContentType) && json.Valid(value) {
+		entry.Value = json.RawMessage(value)
+	} else {
+		entry.ValueBytes = value
+	}
+	entry.Metadata = json.RawMessage(metadata)
+	entry.CreatedAt = time.Unix(0, createdAt)
+	entry.UpdatedAt = time.Unix(0, updatedAt)
+	return &entry, nil
+}
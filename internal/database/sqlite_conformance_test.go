@@ -0,0 +1,21 @@
+package database_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/birbparty/birb-nest/internal/database"
+	"github.com/birbparty/birb-nest/tests/conformance"
+)
+
+func TestSQLiteClient_Conformance(t *testing.T) {
+	conformance.RunDatabaseSuite(t, func(t *testing.T) database.Interface {
+		db, err := database.NewSQLiteClient(&database.SQLiteConfig{
+			Path: filepath.Join(t.TempDir(), "conformance.db"),
+		}, "global")
+		if err != nil {
+			t.Fatalf("NewSQLiteClient() error = %v", err)
+		}
+		return db
+	}, conformance.DatabaseOptions{})
+}
@@ -0,0 +1,302 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/birbparty/birb-nest/internal/cache"
+	"github.com/birbparty/birb-nest/internal/instance"
+)
+
+func newTestSQLite(t *testing.T) *SQLiteClient {
+	t.Helper()
+	client, err := NewSQLiteClient(&SQLiteConfig{
+		Path: filepath.Join(t.TempDir(), "test.db"),
+	}, "global")
+	if err != nil {
+		t.Fatalf("NewSQLiteClient() error = %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestSQLiteClient_ImplementsInterface(t *testing.T) {
+	var _ Interface = (*SQLiteClient)(nil)
+}
+
+func TestNewSQLiteClient_EmptyPath(t *testing.T) {
+	if _, err := NewSQLiteClient(&SQLiteConfig{}, "global"); err == nil {
+		t.Error("expected error for empty path")
+	}
+}
+
+func TestSQLiteClient_CRUD(t *testing.T) {
+	client := newTestSQLite(t)
+	ctx := context.Background()
+
+	if _, err := client.Get(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get() missing error = %v, want ErrNotFound", err)
+	}
+
+	if err := client.Set(ctx, "user:1", []byte(`{"name":"birb"}`)); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	got, err := client.Get(ctx, "user:1")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if string(got) != `{"name":"birb"}` {
+		t.Errorf("Get() = %s", got)
+	}
+
+	exists, err := client.Exists(ctx, "user:1")
+	if err != nil || !exists {
+		t.Errorf("Exists() = %v, %v; want true", exists, err)
+	}
+
+	if err := client.Delete(ctx, "user:1"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := client.Delete(ctx, "user:1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete() twice error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSQLiteClient_BinaryValues(t *testing.T) {
+	client := newTestSQLite(t)
+	ctx := context.Background()
+
+	if err := client.Set(ctx, "raw", []byte("9821f3fe")); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if got, err := client.Get(ctx, "raw"); err != nil || string(got) != "9821f3fe" {
+		t.Errorf("Get() = %q, %v, want the bytes unchanged", got, err)
+	}
+
+	png := cache.EncodeValue("image/png", []byte{0x89, 'P', 'N', 'G', 0x00})
+	if err := client.Set(ctx, "image", png); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	entry, err := client.GetEntry(ctx, "image", "global")
+	if err != nil {
+		t.Fatalf("GetEntry() error = %v", err)
+	}
+	if entry.ContentType != "image/png" || entry.Value != nil || !bytes.Equal(entry.ValueBytes, []byte{0x89, 'P', 'N', 'G', 0x00}) {
+		t.Errorf("GetEntry() = %q %q %v, want the PNG in ValueBytes", entry.ContentType, entry.Value, entry.ValueBytes)
+	}
+	if got, _ := client.Get(ctx, "image"); !bytes.Equal(got, png) {
+		t.Errorf("Get() = %q, want %q", got, png)
+	}
+}
+
+func TestSQLiteClient_InstanceIsolation(t *testing.T) {
+	client := newTestSQLite(t)
+	ctx := context.Background()
+
+	if err := client.SetWithInstance(ctx, "key", "inst_a", []byte(`"a"`)); err != nil {
+		t.Fatalf("SetWithInstance() error = %v", err)
+	}
+	if err := client.SetWithInstance(ctx, "key", "inst_b", []byte(`"b"`)); err != nil {
+		t.Fatalf("SetWithInstance() error = %v", err)
+	}
+
+	got, _ := client.GetWithInstance(ctx, "key", "inst_a")
+	if string(got) != `"a"` {
+		t.Errorf("inst_a value = %s, want \"a\"", got)
+	}
+
+	instCtx := instance.InjectContext(ctx, instance.NewContext("inst_b"))
+	got, _ = client.GetFromContext(instCtx, "key")
+	if string(got) != `"b"` {
+		t.Errorf("inst_b value = %s, want \"b\"", got)
+	}
+
+	deleted, err := client.DeleteByInstance(ctx, "inst_a")
+	if err != nil || deleted != 1 {
+		t.Errorf("DeleteByInstance() = %d, %v; want 1", deleted, err)
+	}
+	if exists, _ := client.ExistsWithInstance(ctx, "key", "inst_b"); !exists {
+		t.Error("deleting inst_a removed inst_b data")
+	}
+}
+
+func TestSQLiteClient_TTLExpiry(t *testing.T) {
+	client := newTestSQLite(t)
+	ctx := context.Background()
+
+	ttl := 1
+	if err := client.SetEntry(ctx, "short", "global", json.RawMessage(`1`), &ttl, nil); err != nil {
+		t.Fatalf("SetEntry() error = %v", err)
+	}
+	if exists, _ := client.Exists(ctx, "short"); !exists {
+		t.Fatal("entry should exist before TTL elapses")
+	}
+
+	// Age the entry instead of sleeping
+	past := time.Now().Add(-2 * time.Second).UnixNano()
+	if _, err := client.DB().Exec(`UPDATE cache_entries SET updated_at = ? WHERE key = 'short'`, past); err != nil {
+		t.Fatalf("failed to age entry: %v", err)
+	}
+
+	if exists, _ := client.Exists(ctx, "short"); exists {
+		t.Error("Exists() = true for expired entry")
+	}
+	if _, err := client.Get(ctx, "short"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() expired error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSQLiteClient_CleanupExpired(t *testing.T) {
+	client := newTestSQLite(t)
+	ctx := context.Background()
+
+	ttl := 1
+	for i := 0; i < 5; i++ {
+		if err := client.SetEntry(ctx, fmt.Sprintf("k%d", i), "global", json.RawMessage(`1`), &ttl, nil); err != nil {
+			t.Fatalf("SetEntry() error = %v", err)
+		}
+	}
+	_ = client.Set(ctx, "keep", []byte(`1`))
+
+	past := time.Now().Add(-2 * time.Second).UnixNano()
+	if _, err := client.DB().Exec(`UPDATE cache_entries SET updated_at = ? WHERE ttl IS NOT NULL`, past); err != nil {
+		t.Fatalf("failed to age entries: %v", err)
+	}
+
+	removed, err := client.CleanupExpired(ctx, 3)
+	if err != nil || removed != 3 {
+		t.Errorf("CleanupExpired(3) = %d, %v; want 3", removed, err)
+	}
+	removed, _ = client.CleanupExpired(ctx, 100)
+	if removed != 2 {
+		t.Errorf("CleanupExpired(100) = %d, want 2", removed)
+	}
+	if exists, _ := client.Exists(ctx, "keep"); !exists {
+		t.Error("entry without TTL was cleaned up")
+	}
+}
+
+func TestSQLiteClient_SetWithVersion(t *testing.T) {
+	client := newTestSQLite(t)
+	ctx := context.Background()
+
+	_ = client.Set(ctx, "counter", []byte(`1`))
+	_ = client.Set(ctx, "counter", []byte(`2`))
+
+	entry, err := client.GetEntry(ctx, "counter", "global")
+	if err != nil {
+		t.Fatalf("GetEntry() error = %v", err)
+	}
+	if entry.Version != 2 {
+		t.Fatalf("Version = %d, want 2", entry.Version)
+	}
+
+	if err := client.SetWithVersion(ctx, "counter", "global", json.RawMessage(`3`), nil, nil, 1); !errors.Is(err, ErrVersionMismatch) {
+		t.Errorf("SetWithVersion() stale error = %v, want ErrVersionMismatch", err)
+	}
+	if err := client.SetWithVersion(ctx, "counter", "global", json.RawMessage(`3`), nil, nil, 2); err != nil {
+		t.Errorf("SetWithVersion() error = %v", err)
+	}
+}
+
+func TestSQLiteClient_BatchGetAndKeys(t *testing.T) {
+	client := newTestSQLite(t)
+	ctx := context.Background()
+
+	for i := 0; i < 10; i++ {
+		_ = client.SetWithInstance(ctx, fmt.Sprintf("key%02d", i), "inst_batch", []byte(fmt.Sprintf("%d", i)))
+	}
+
+	entries, err := client.BatchGetWithInstance(ctx, []string{"key01", "key05", "missing"}, "inst_batch")
+	if err != nil {
+		t.Fatalf("BatchGetWithInstance() error = %v", err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("BatchGetWithInstance() returned %d entries, want 2", len(entries))
+	}
+
+	keys, err := client.GetKeysByInstance(ctx, "inst_batch", 2, 3)
+	if err != nil {
+		t.Fatalf("GetKeysByInstance() error = %v", err)
+	}
+	want := []string{"key02", "key03", "key04"}
+	if fmt.Sprint(keys) != fmt.Sprint(want) {
+		t.Errorf("GetKeysByInstance() = %v, want %v", keys, want)
+	}
+}
+
+func TestSQLiteClient_BackupRestore(t *testing.T) {
+	client := newTestSQLite(t)
+	ctx := context.Background()
+
+	_ = client.SetWithInstance(ctx, "a", "inst_src", []byte(`{"x":1}`))
+	_ = client.SetWithInstance(ctx, "b", "inst_src", []byte(`[1,2]`))
+
+	var buf bytes.Buffer
+	count, err := client.BackupInstance(ctx, "inst_src", &buf)
+	if err != nil || count != 2 {
+		t.Fatalf("BackupInstance() = %d, %v; want 2", count, err)
+	}
+
+	restored, err := client.RestoreInstance(ctx, "inst_dst", &buf)
+	if err != nil || restored != 2 {
+		t.Fatalf("RestoreInstance() = %d, %v; want 2", restored, err)
+	}
+
+	got, err := client.GetWithInstance(ctx, "a", "inst_dst")
+	if err != nil || string(got) != `{"x":1}` {
+		t.Errorf("restored value = %s, %v", got, err)
+	}
+}
+
+func TestSQLiteClient_InMemory(t *testing.T) {
+	client, err := NewSQLiteClient(&SQLiteConfig{Path: ":memory:"}, "global")
+	if err != nil {
+		t.Fatalf("NewSQLiteClient() error = %v", err)
+	}
+	defer client.Close()
+
+	ctx := context.Background()
+	if err := client.Set(ctx, "k", []byte(`true`)); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if exists, _ := client.Exists(ctx, "k"); !exists {
+		t.Error("Exists() = false after Set on :memory: database")
+	}
+}
+
+func TestSQLiteClient_ConcurrentWrites(t *testing.T) {
+	client := newTestSQLite(t)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 50)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- client.SetWithInstance(ctx, fmt.Sprintf("k%d", i%10), "inst_conc", []byte(fmt.Sprintf("%d", i)))
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("concurrent SetWithInstance() error = %v", err)
+		}
+	}
+
+	keys, _ := client.GetKeysByInstance(ctx, "inst_conc", 0, 100)
+	if len(keys) != 10 {
+		t.Errorf("got %d keys, want 10", len(keys))
+	}
+}
@@ -0,0 +1,102 @@
+// Package encryption encrypts cached values at rest with per-instance data
+// keys, which are stored wrapped by a master key
+package encryption
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"github.com/birbparty/birb-nest/internal/cache"
+)
+
+// envelopeVersion is the format version written into every envelope
+const envelopeVersion = "1"
+
+// ErrInvalidEnvelope is returned for sealed values that cannot be decoded or
+// fail authentication
+var ErrInvalidEnvelope = errors.New("invalid encrypted value")
+
+// IsSealed reports whether a value is an encryption envelope: a value of
+// content type cache.ContentTypeSealed with the body
+// "<version>\x00<data key ID>\x00<nonce and ciphertext>". Clients cannot
+// store values of that content type, so only values sealed here are opened.
+func IsSealed(value []byte) bool {
+	return cache.IsSealed(value)
+}
+
+// SealedKeyID returns the ID of the data key a sealed value was encrypted with
+func SealedKeyID(value []byte) (string, error) {
+	keyID, _, err := parseEnvelope(value)
+	return keyID, err
+}
+
+// SealedPrefix returns the start of the stored body of values sealed with a
+// data key, as kept in the value_bytes column
+func SealedPrefix(keyID string) []byte {
+	return []byte(envelopeVersion + "\x00" + keyID + "\x00")
+}
+
+// sealValue encrypts a value with AES-GCM, binding it to the data key, the
+// instance that owns the key and the cache key it is stored under
+func sealValue(aead cipher.AEAD, keyID, instanceID, key string, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	body := SealedPrefix(keyID)
+	body = append(body, nonce...)
+	body = aead.Seal(body, nonce, plaintext, []byte(additionalData(keyID, instanceID, key)))
+	return cache.EncodeValue(cache.ContentTypeSealed, body), nil
+}
+
+// openValue decrypts nonce-prefixed data sealed with the additional data ad
+func openValue(aead cipher.AEAD, ad string, data []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, fmt.Errorf("%w: too short", ErrInvalidEnvelope)
+	}
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(ad))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidEnvelope, err)
+	}
+	return plaintext, nil
+}
+
+// additionalData is the authenticated data of a sealed value. The cache key
+// comes last, so a NUL byte in it cannot shift the other fields.
+func additionalData(keyID, instanceID, key string) string {
+	return keyID + "\x00" + instanceID + "\x00" + key
+}
+
+// parseEnvelope returns the data key ID and encrypted data of a sealed value
+func parseEnvelope(value []byte) (keyID string, data []byte, err error) {
+	contentType, _, body := cache.ParseValue(value)
+	if contentType != cache.ContentTypeSealed {
+		return "", nil, fmt.Errorf("%w: not sealed", ErrInvalidEnvelope)
+	}
+	version, rest, ok := bytes.Cut(body, []byte{0})
+	if !ok || string(version) != envelopeVersion {
+		return "", nil, fmt.Errorf("%w: unsupported format", ErrInvalidEnvelope)
+	}
+	id, data, ok := bytes.Cut(rest, []byte{0})
+	if !ok || len(id) == 0 {
+		return "", nil, fmt.Errorf("%w: unsupported format", ErrInvalidEnvelope)
+	}
+	return string(id), data, nil
+}
+
+// newAEAD returns AES-256-GCM for a 32-byte key
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("key must be %d bytes, got %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,196 @@
+package encryption
+
+import (
+	"bufio"
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KeySize is the size of data keys and keyfile master keys, in bytes (AES-256)
+const KeySize = 32
+
+var (
+	// ErrDataKeyNotFound is returned when no data key has the requested ID, or
+	// an instance has no active data key
+	ErrDataKeyNotFound = errors.New("data key not found")
+
+	// ErrUnknownMasterKey is returned when a data key was wrapped by a master
+	// key that is not configured
+	ErrUnknownMasterKey = errors.New("unknown master key")
+
+	// ErrReadOnlyStore is returned when data keys are changed on a node that
+	// can only read them
+	ErrReadOnlyStore = errors.New("data keys can only be changed on the primary")
+
+	// ErrUnknownKMS is returned for KMS names that were never registered
+	ErrUnknownKMS = errors.New("unknown KMS")
+)
+
+// KeyWrapper protects data keys with a master key that never leaves it. The
+// keyfile wrapper is built in; KMS wrappers are added with RegisterKMS.
+type KeyWrapper interface {
+	// ID names the master key; it is stored with every data key it wraps
+	ID() string
+
+	// Wrap encrypts a data key
+	Wrap(ctx context.Context, key []byte) ([]byte, error)
+
+	// Unwrap decrypts a data key wrapped by Wrap
+	Unwrap(ctx context.Context, wrapped []byte) ([]byte, error)
+}
+
+// KMSFactory creates a wrapper for a KMS from its configuration string, e.g.
+// a key ARN or resource name
+type KMSFactory func(ctx context.Context, config string) (KeyWrapper, error)
+
+var (
+	kmsMu        sync.RWMutex
+	kmsFactories = map[string]KMSFactory{}
+)
+
+// RegisterKMS makes a KMS available by name, typically from the init function
+// of a package linked into the server
+func RegisterKMS(name string, factory KMSFactory) {
+	kmsMu.Lock()
+	defer kmsMu.Unlock()
+	kmsFactories[name] = factory
+}
+
+// NewKMS creates a wrapper for a registered KMS
+func NewKMS(ctx context.Context, name, config string) (KeyWrapper, error) {
+	kmsMu.RLock()
+	factory, ok := kmsFactories[name]
+	kmsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w %q (registered: %s)", ErrUnknownKMS, name, strings.Join(registeredKMS(), ", "))
+	}
+	return factory(ctx, config)
+}
+
+// registeredKMS returns the names of registered KMS wrappers
+func registeredKMS() []string {
+	kmsMu.RLock()
+	defer kmsMu.RUnlock()
+	names := make([]string, 0, len(kmsFactories))
+	for name := range kmsFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// localWrapper wraps data keys with a master key held in memory
+type localWrapper struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// NewLocalWrapper returns a wrapper for a 32-byte master key
+func NewLocalWrapper(id string, key []byte) (KeyWrapper, error) {
+	if id == "" || strings.ContainsAny(id, ": \t") {
+		return nil, fmt.Errorf("invalid master key ID %q", id)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid master key %s: %w", id, err)
+	}
+	return &localWrapper{id: id, aead: aead}, nil
+}
+
+// ID returns the master key ID
+func (w *localWrapper) ID() string {
+	return w.id
+}
+
+// Wrap encrypts a data key with the master key
+func (w *localWrapper) Wrap(ctx context.Context, key []byte) ([]byte, error) {
+	nonce := make([]byte, w.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return w.aead.Seal(nonce, nonce, key, []byte(w.id)), nil
+}
+
+// Unwrap decrypts a data key wrapped by this master key
+func (w *localWrapper) Unwrap(ctx context.Context, wrapped []byte) ([]byte, error) {
+	return openValue(w.aead, w.id, wrapped)
+}
+
+// LoadKeyfile reads master keys from a file with one "<id>:<base64 key>" line
+// per key. The first key wraps new data keys; the others only unwrap existing
+// ones, so a new master key can be added at the top and old ones dropped once
+// every data key has been rotated. Empty lines and lines starting with # are
+// ignored.
+func LoadKeyfile(path string) ([]KeyWrapper, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open keyfile: %w", err)
+	}
+	defer f.Close()
+
+	var wrappers []KeyWrapper
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		id, encoded, ok := strings.Cut(text, ":")
+		if !ok {
+			return nil, fmt.Errorf("keyfile line %d: expected <id>:<base64 key>", line)
+		}
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("keyfile line %d: %w", line, err)
+		}
+		wrapper, err := NewLocalWrapper(strings.TrimSpace(id), key)
+		if err != nil {
+			return nil, fmt.Errorf("keyfile line %d: %w", line, err)
+		}
+		wrappers = append(wrappers, wrapper)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read keyfile: %w", err)
+	}
+	if len(wrappers) == 0 {
+		return nil, fmt.Errorf("keyfile %s holds no keys", path)
+	}
+	return wrappers, nil
+}
+
+// DataKey is an instance's data key, stored wrapped by a master key. An
+// instance encrypts new values with its newest active data key; retired keys
+// only decrypt values until they are re-encrypted.
+type DataKey struct {
+	ID          string     `json:"id"`
+	InstanceID  string     `json:"instance_id"`
+	MasterKeyID string     `json:"master_key_id"`
+	Wrapped     []byte     `json:"wrapped"`
+	CreatedAt   time.Time  `json:"created_at"`
+	RetiredAt   *time.Time `json:"retired_at,omitempty"`
+}
+
+// KeyStore persists data keys
+type KeyStore interface {
+	// SaveDataKey creates a data key or records its retirement
+	SaveDataKey(ctx context.Context, key *DataKey) error
+
+	// GetDataKey returns a data key by ID or ErrDataKeyNotFound
+	GetDataKey(ctx context.Context, id string) (*DataKey, error)
+
+	// ActiveDataKey returns an instance's newest data key that is not retired,
+	// or ErrDataKeyNotFound
+	ActiveDataKey(ctx context.Context, instanceID string) (*DataKey, error)
+
+	// ListDataKeys returns an instance's data keys, newest first
+	ListDataKeys(ctx context.Context, instanceID string) ([]*DataKey, error)
+}
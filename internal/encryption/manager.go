@@ -0,0 +1,310 @@
+package encryption
+
+import (
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/birbparty/birb-nest/internal/cache"
+	"github.com/birbparty/birb-nest/internal/instance"
+)
+
+const (
+	// MetaEncryption is the instance metadata key that turns encryption "on"
+	// or "off" for an instance, overriding the default
+	MetaEncryption = "encryption"
+
+	// activeKeyTTL bounds how long a node keeps sealing with an instance's
+	// data key after it was rotated elsewhere
+	activeKeyTTL = time.Minute
+)
+
+// activeEntry is an instance's active data key ID cached in memory
+type activeEntry struct {
+	keyID      string
+	expiration time.Time
+}
+
+// dataCipher is an unwrapped data key
+type dataCipher struct {
+	instanceID string // the instance the key belongs to
+	aead       cipher.AEAD
+}
+
+// Manager seals and opens values with per-instance data keys. Unwrapped data
+// keys are kept in memory only. It implements cache.Sealer.
+type Manager struct {
+	store    KeyStore
+	current  KeyWrapper            // wraps new data keys
+	wrappers map[string]KeyWrapper // master key ID -> wrapper, for unwrapping
+	all      bool                  // encrypt instances without MetaEncryption
+
+	mu       sync.Mutex
+	ciphers  map[string]*dataCipher  // data key ID -> cipher; data keys never change
+	active   map[string]*activeEntry // instance ID -> active data key
+	createMu sync.Mutex              // serializes data key creation
+}
+
+var _ cache.Sealer = (*Manager)(nil)
+
+// NewManager creates a manager that wraps new data keys with current and
+// unwraps existing ones with current or any of older
+func NewManager(store KeyStore, current KeyWrapper, older ...KeyWrapper) *Manager {
+	wrappers := map[string]KeyWrapper{current.ID(): current}
+	for _, w := range older {
+		if _, ok := wrappers[w.ID()]; !ok {
+			wrappers[w.ID()] = w
+		}
+	}
+	return &Manager{
+		store:    store,
+		current:  current,
+		wrappers: wrappers,
+		ciphers:  make(map[string]*dataCipher),
+		active:   make(map[string]*activeEntry),
+	}
+}
+
+// SetEncryptAll encrypts instances that do not set MetaEncryption
+func (m *Manager) SetEncryptAll(all bool) {
+	m.all = all
+}
+
+// Enabled reports whether new values of an instance are encrypted
+func (m *Manager) Enabled(inst *instance.Context) bool {
+	switch inst.Metadata[MetaEncryption] {
+	case "on":
+		return true
+	case "off":
+		return false
+	}
+	return m.all
+}
+
+// Seal encrypts the value of a cache key for the instance in ctx when
+// encryption is enabled for it. Values that are already sealed are returned
+// unchanged, so values read back from the database keep their data key.
+func (m *Manager) Seal(ctx context.Context, key string, value []byte) ([]byte, error) {
+	if IsSealed(value) {
+		return value, nil
+	}
+	instanceID := "global"
+	enabled := m.all
+	if inst, ok := instance.ExtractContext(ctx); ok {
+		instanceID = inst.InstanceID
+		enabled = m.Enabled(inst)
+	}
+	if !enabled {
+		return value, nil
+	}
+	return m.SealFor(ctx, instanceID, key, value)
+}
+
+// SealFor encrypts the value of a cache key with an instance's active data
+// key, creating the key on first use
+func (m *Manager) SealFor(ctx context.Context, instanceID, key string, value []byte) ([]byte, error) {
+	keyID, aead, err := m.activeCipher(ctx, instanceID)
+	if err != nil {
+		return nil, err
+	}
+	return sealValue(aead, keyID, instanceID, key, value)
+}
+
+// Open decrypts the sealed value of a cache key. The data key it names must
+// belong to the instance in ctx or to an instance it holds copies of through
+// a clone or a template (see instance.MetaDataFrom). Values that are not
+// sealed are returned unchanged.
+func (m *Manager) Open(ctx context.Context, key string, value []byte) ([]byte, error) {
+	if !IsSealed(value) {
+		return value, nil
+	}
+	keyID, data, err := parseEnvelope(value)
+	if err != nil {
+		return nil, err
+	}
+	dc, err := m.cipher(ctx, keyID)
+	if err != nil {
+		return nil, err
+	}
+	if !mayOpen(ctx, dc.instanceID) {
+		return nil, fmt.Errorf("%w: data key %s belongs to instance %s", ErrInvalidEnvelope, keyID, dc.instanceID)
+	}
+	return openValue(dc.aead, additionalData(keyID, dc.instanceID, key), data)
+}
+
+// mayOpen reports whether the instance in ctx may read values sealed with a
+// data key of owner
+func mayOpen(ctx context.Context, owner string) bool {
+	inst, ok := instance.ExtractContext(ctx)
+	if !ok {
+		return owner == "global"
+	}
+	return owner == inst.InstanceID || slices.Contains(inst.DataFrom(), owner)
+}
+
+// ActiveKey returns an instance's active data key, creating it on first use
+func (m *Manager) ActiveKey(ctx context.Context, instanceID string) (*DataKey, error) {
+	key, err := m.store.ActiveDataKey(ctx, instanceID)
+	if errors.Is(err, ErrDataKeyNotFound) {
+		m.createMu.Lock()
+		defer m.createMu.Unlock()
+		// Another request may have created it while we waited
+		key, err = m.store.ActiveDataKey(ctx, instanceID)
+		if errors.Is(err, ErrDataKeyNotFound) {
+			return m.createKey(ctx, instanceID)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load active data key: %w", err)
+	}
+	return key, nil
+}
+
+// Key returns a data key by ID
+func (m *Manager) Key(ctx context.Context, id string) (*DataKey, error) {
+	return m.store.GetDataKey(ctx, id)
+}
+
+// Keys returns an instance's data keys, newest first
+func (m *Manager) Keys(ctx context.Context, instanceID string) ([]*DataKey, error) {
+	return m.store.ListDataKeys(ctx, instanceID)
+}
+
+// Rotate gives an instance a new data key and retires its previous ones.
+// Values sealed with retired keys stay readable until the Reencryptor
+// rewrites them.
+func (m *Manager) Rotate(ctx context.Context, instanceID string) (*DataKey, error) {
+	m.createMu.Lock()
+	defer m.createMu.Unlock()
+
+	old, err := m.store.ListDataKeys(ctx, instanceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list data keys: %w", err)
+	}
+	key, err := m.createKey(ctx, instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for _, k := range old {
+		if k.RetiredAt != nil {
+			continue
+		}
+		k.RetiredAt = &now
+		if err := m.store.SaveDataKey(ctx, k); err != nil {
+			return nil, fmt.Errorf("failed to retire data key %s: %w", k.ID, err)
+		}
+	}
+
+	m.mu.Lock()
+	m.active[instanceID] = &activeEntry{keyID: key.ID, expiration: now.Add(activeKeyTTL)}
+	m.mu.Unlock()
+	return key, nil
+}
+
+// createKey generates, wraps and stores a new data key for an instance
+func (m *Manager) createKey(ctx context.Context, instanceID string) (*DataKey, error) {
+	plain := make([]byte, KeySize)
+	if _, err := rand.Read(plain); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("failed to generate data key ID: %w", err)
+	}
+	wrapped, err := m.current.Wrap(ctx, plain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+
+	key := &DataKey{
+		ID:          hex.EncodeToString(id),
+		InstanceID:  instanceID,
+		MasterKeyID: m.current.ID(),
+		Wrapped:     wrapped,
+		CreatedAt:   time.Now(),
+	}
+	if err := m.store.SaveDataKey(ctx, key); err != nil {
+		return nil, fmt.Errorf("failed to save data key: %w", err)
+	}
+	return key, nil
+}
+
+// activeCipher returns the ID and cipher of an instance's active data key
+func (m *Manager) activeCipher(ctx context.Context, instanceID string) (string, cipher.AEAD, error) {
+	m.mu.Lock()
+	entry, ok := m.active[instanceID]
+	m.mu.Unlock()
+	if ok && time.Now().Before(entry.expiration) {
+		dc, err := m.cipher(ctx, entry.keyID)
+		if err != nil {
+			return "", nil, err
+		}
+		return entry.keyID, dc.aead, nil
+	}
+
+	key, err := m.ActiveKey(ctx, instanceID)
+	if err != nil {
+		return "", nil, err
+	}
+	dc, err := m.unwrap(ctx, key)
+	if err != nil {
+		return "", nil, err
+	}
+	m.mu.Lock()
+	m.active[instanceID] = &activeEntry{keyID: key.ID, expiration: time.Now().Add(activeKeyTTL)}
+	m.mu.Unlock()
+	return key.ID, dc.aead, nil
+}
+
+// cipher returns the cipher of a data key, loading and unwrapping it once
+func (m *Manager) cipher(ctx context.Context, keyID string) (*dataCipher, error) {
+	m.mu.Lock()
+	dc, ok := m.ciphers[keyID]
+	m.mu.Unlock()
+	if ok {
+		return dc, nil
+	}
+
+	key, err := m.store.GetDataKey(ctx, keyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load data key %s: %w", keyID, err)
+	}
+	return m.unwrap(ctx, key)
+}
+
+// unwrap decrypts a data key with its master key and caches its cipher
+func (m *Manager) unwrap(ctx context.Context, key *DataKey) (*dataCipher, error) {
+	m.mu.Lock()
+	dc, ok := m.ciphers[key.ID]
+	m.mu.Unlock()
+	if ok {
+		return dc, nil
+	}
+
+	wrapper, ok := m.wrappers[key.MasterKeyID]
+	if !ok {
+		return nil, fmt.Errorf("%w %q for data key %s", ErrUnknownMasterKey, key.MasterKeyID, key.ID)
+	}
+	plain, err := wrapper.Unwrap(ctx, key.Wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key %s: %w", key.ID, err)
+	}
+	aead, err := newAEAD(plain)
+	if err != nil {
+		return nil, fmt.Errorf("invalid data key %s: %w", key.ID, err)
+	}
+
+	dc = &dataCipher{instanceID: key.InstanceID, aead: aead}
+	m.mu.Lock()
+	m.ciphers[key.ID] = dc
+	m.mu.Unlock()
+	return dc, nil
+}
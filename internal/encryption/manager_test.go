@@ -0,0 +1,332 @@
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/birbparty/birb-nest/internal/cache"
+	"github.com/birbparty/birb-nest/internal/instance"
+)
+
+// memKeyStore is an in-memory KeyStore
+type memKeyStore struct {
+	mu   sync.Mutex
+	keys []DataKey // in creation order
+}
+
+func (s *memKeyStore) SaveDataKey(ctx context.Context, key *DataKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.keys {
+		if s.keys[i].ID == key.ID {
+			s.keys[i] = *key
+			return nil
+		}
+	}
+	s.keys = append(s.keys, *key)
+	return nil
+}
+
+func (s *memKeyStore) GetDataKey(ctx context.Context, id string) (*DataKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range s.keys {
+		if key.ID == id {
+			return &key, nil
+		}
+	}
+	return nil, ErrDataKeyNotFound
+}
+
+func (s *memKeyStore) ActiveDataKey(ctx context.Context, instanceID string) (*DataKey, error) {
+	keys, _ := s.ListDataKeys(ctx, instanceID)
+	for _, key := range keys {
+		if key.RetiredAt == nil {
+			return key, nil
+		}
+	}
+	return nil, ErrDataKeyNotFound
+}
+
+func (s *memKeyStore) ListDataKeys(ctx context.Context, instanceID string) ([]*DataKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := []*DataKey{}
+	for i := len(s.keys) - 1; i >= 0; i-- {
+		if key := s.keys[i]; key.InstanceID == instanceID {
+			keys = append(keys, &key)
+		}
+	}
+	return keys, nil
+}
+
+// memEntryStore is an in-memory EntryStore
+type memEntryStore struct {
+	values map[string][]byte
+}
+
+func (s *memEntryStore) StaleEntries(ctx context.Context, instanceID, keyID, after string, limit int) ([]Entry, error) {
+	entries := []Entry{}
+	for key, value := range s.values {
+		if sealedWith, err := SealedKeyID(value); key > after && (err != nil || sealedWith != keyID) {
+			entries = append(entries, Entry{Key: key, Value: value})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries, nil
+}
+
+func (s *memEntryStore) ReplaceEntry(ctx context.Context, instanceID, key string, old, value []byte) (bool, error) {
+	if !bytes.Equal(s.values[key], old) {
+		return false, nil
+	}
+	s.values[key] = value
+	return true, nil
+}
+
+func newWrapper(t *testing.T, id string) KeyWrapper {
+	t.Helper()
+	key := make([]byte, KeySize)
+	rand.Read(key)
+	w, err := NewLocalWrapper(id, key)
+	if err != nil {
+		t.Fatalf("NewLocalWrapper() error = %v", err)
+	}
+	return w
+}
+
+// instanceCtx returns a context for an instance with encryption turned on or off
+func instanceCtx(id, setting string) context.Context {
+	inst := instance.NewContext(id)
+	inst.Metadata[MetaEncryption] = setting
+	return instance.InjectContext(context.Background(), inst)
+}
+
+func TestManager_SealAndOpen(t *testing.T) {
+	m := NewManager(&memKeyStore{}, newWrapper(t, "master-1"))
+	ctx := instanceCtx("game-1", "on")
+	plain := []byte(`{"player":"birb","email":"birb@example.com"}`)
+
+	sealed, err := m.Seal(ctx, "profile", plain)
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+	if !IsSealed(sealed) || bytes.Contains(sealed, []byte("birb@example.com")) {
+		t.Fatalf("Seal() = %q, want an envelope without the plaintext", sealed)
+	}
+	if again, _ := m.Seal(ctx, "profile", sealed); !bytes.Equal(again, sealed) {
+		t.Error("Seal() of a sealed value changed it")
+	}
+
+	opened, err := m.Open(ctx, "profile", sealed)
+	if err != nil || !bytes.Equal(opened, plain) {
+		t.Errorf("Open() = %s, %v, want %s", opened, err, plain)
+	}
+	if opened, err := m.Open(ctx, "profile", plain); err != nil || !bytes.Equal(opened, plain) {
+		t.Errorf("Open() of plaintext = %s, %v, want it unchanged", opened, err)
+	}
+
+	if out, _ := m.Seal(instanceCtx("game-2", "off"), "profile", plain); !bytes.Equal(out, plain) {
+		t.Error("Seal() encrypted a value of an instance with encryption off")
+	}
+	m.SetEncryptAll(true)
+	if out, _ := m.Seal(instanceCtx("game-3", ""), "profile", plain); !IsSealed(out) {
+		t.Error("Seal() with encryption for all instances left a value in plaintext")
+	}
+
+	// Ciphertext is authenticated and bound to its cache key
+	tampered := bytes.Clone(sealed)
+	tampered[len(tampered)-1] ^= 1
+	if _, err := m.Open(ctx, "profile", tampered); !errors.Is(err, ErrInvalidEnvelope) {
+		t.Errorf("Open() of a tampered value error = %v, want ErrInvalidEnvelope", err)
+	}
+	if _, err := m.Open(ctx, "settings", sealed); !errors.Is(err, ErrInvalidEnvelope) {
+		t.Errorf("Open() of a value moved to another key error = %v, want ErrInvalidEnvelope", err)
+	}
+}
+
+func TestManager_ClientValuesThatLookSealed(t *testing.T) {
+	m := NewManager(&memKeyStore{}, newWrapper(t, "master-1"))
+	tests := []struct {
+		name        string
+		contentType string
+		body        []byte
+	}{
+		{"old JSON envelope", cache.ContentTypeJSON, []byte(`{"$enc":"hello"}`)},
+		{"bytes starting with NUL", cache.ContentTypeBinary, []byte("\x00ce\x001\x00key\x00data")},
+		{"typed bytes starting with NUL", "image/png", []byte{0x00, 0x01}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value := cache.EncodeValue(tt.contentType, tt.body)
+			if IsSealed(value) {
+				t.Fatalf("IsSealed(%q) = true for a client value", value)
+			}
+
+			for _, setting := range []string{"on", "off"} {
+				ctx := instanceCtx("game-1", setting)
+				stored, err := m.Seal(ctx, "key", value)
+				if err != nil {
+					t.Fatalf("Seal() with encryption %s error = %v", setting, err)
+				}
+				if sealed := IsSealed(stored); sealed != (setting == "on") {
+					t.Errorf("Seal() with encryption %s sealed = %v", setting, sealed)
+				}
+				opened, err := m.Open(ctx, "key", stored)
+				if err != nil {
+					t.Fatalf("Open() with encryption %s error = %v", setting, err)
+				}
+				contentType, body, _ := cache.DecodeValue(opened)
+				if contentType != tt.contentType || !bytes.Equal(body, tt.body) {
+					t.Errorf("round trip with encryption %s = %q, %q, want %q, %q",
+						setting, contentType, body, tt.contentType, tt.body)
+				}
+			}
+		})
+	}
+}
+
+func TestManager_OpenChecksInstance(t *testing.T) {
+	m := NewManager(&memKeyStore{}, newWrapper(t, "master-1"))
+	sealed, err := m.Seal(instanceCtx("game-1", "on"), "save", []byte(`"gold"`))
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+
+	// A value replayed into another instance names a data key of game-1
+	if _, err := m.Open(instanceCtx("game-2", "on"), "save", sealed); !errors.Is(err, ErrInvalidEnvelope) {
+		t.Errorf("Open() in another instance error = %v, want ErrInvalidEnvelope", err)
+	}
+	if _, err := m.Open(context.Background(), "save", sealed); !errors.Is(err, ErrInvalidEnvelope) {
+		t.Errorf("Open() without an instance error = %v, want ErrInvalidEnvelope", err)
+	}
+
+	// Clones and instances created from templates read the values they copied
+	clone := instance.NewContext("game-1-clone")
+	clone.AddDataFrom("game-1")
+	opened, err := m.Open(instance.InjectContext(context.Background(), clone), "save", sealed)
+	if err != nil || string(opened) != `"gold"` {
+		t.Errorf("Open() in a clone = %s, %v, want \"gold\"", opened, err)
+	}
+}
+
+func TestManager_RotateAndMasterKeys(t *testing.T) {
+	store := &memKeyStore{}
+	oldMaster := newWrapper(t, "master-1")
+	m := NewManager(store, oldMaster)
+	ctx := instanceCtx("game-1", "on")
+
+	before, _ := m.Seal(ctx, "k", []byte("before"))
+	rotated, err := m.Rotate(ctx, "game-1")
+	if err != nil {
+		t.Fatalf("Rotate() error = %v", err)
+	}
+	after, _ := m.Seal(ctx, "k", []byte("after"))
+
+	if id, _ := SealedKeyID(after); id != rotated.ID {
+		t.Errorf("value sealed after rotation uses key %s, want %s", id, rotated.ID)
+	}
+	if id, _ := SealedKeyID(before); id == rotated.ID {
+		t.Error("value sealed before rotation uses the new key")
+	}
+	keys, _ := m.Keys(ctx, "game-1")
+	if len(keys) != 2 || keys[0].RetiredAt != nil || keys[1].RetiredAt == nil {
+		t.Errorf("Keys() after rotation = %+v, want the new key active and the old one retired", keys)
+	}
+
+	// A new master key wraps new data keys; the old one still unwraps existing ones
+	fresh := NewManager(store, newWrapper(t, "master-2"), oldMaster)
+	if opened, err := fresh.Open(ctx, "k", before); err != nil || string(opened) != "before" {
+		t.Errorf("Open() with the old master key listed = %q, %v", opened, err)
+	}
+	if key, _ := fresh.Rotate(ctx, "game-1"); key == nil || key.MasterKeyID != "master-2" {
+		t.Errorf("Rotate() wrapped the data key with %+v, want master-2", key)
+	}
+
+	unknown := NewManager(store, newWrapper(t, "master-3"))
+	if _, err := unknown.Open(ctx, "k", after); !errors.Is(err, ErrUnknownMasterKey) {
+		t.Errorf("Open() without the master key error = %v, want ErrUnknownMasterKey", err)
+	}
+}
+
+func TestLoadKeyfile(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, KeySize))
+	path := filepath.Join(t.TempDir(), "keys")
+	os.WriteFile(path, []byte("# current key first\nnew:"+key+"\n\nold: "+key+"\n"), 0o600)
+
+	wrappers, err := LoadKeyfile(path)
+	if err != nil {
+		t.Fatalf("LoadKeyfile() error = %v", err)
+	}
+	if len(wrappers) != 2 || wrappers[0].ID() != "new" || wrappers[1].ID() != "old" {
+		t.Errorf("LoadKeyfile() = %d wrappers, want new then old", len(wrappers))
+	}
+
+	os.WriteFile(path, []byte("short:"+base64.StdEncoding.EncodeToString([]byte("too short"))+"\n"), 0o600)
+	if _, err := LoadKeyfile(path); err == nil {
+		t.Error("LoadKeyfile() accepted a short key")
+	}
+}
+
+func TestReencryptor_ReencryptInstance(t *testing.T) {
+	ctx := context.Background()
+	mc, err := cache.NewMemoryCache(&cache.MemoryConfig{})
+	if err != nil {
+		t.Fatalf("NewMemoryCache() error = %v", err)
+	}
+	registry := instance.NewRegistry(mc)
+	inst := instance.NewContext("game-1")
+	inst.Metadata[MetaEncryption] = "on"
+	if err := registry.Register(ctx, inst); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	m := NewManager(&memKeyStore{}, newWrapper(t, "master-1"))
+	instCtx := instance.InjectContext(ctx, inst)
+	oldSealed, _ := m.Seal(instCtx, "sealed", []byte(`"old"`))
+
+	// Values sealed with the retired key and values written before encryption
+	kb := instance.NewKeyBuilder("game-1")
+	mc.Set(ctx, kb.CacheKey("sealed"), oldSealed, 0)
+	mc.Set(ctx, kb.CacheKey("plain"), []byte(`"plain"`), 0)
+	entries := &memEntryStore{values: map[string][]byte{"sealed": oldSealed, "plain": []byte(`"plain"`)}}
+
+	key, err := m.Rotate(ctx, "game-1")
+	if err != nil {
+		t.Fatalf("Rotate() error = %v", err)
+	}
+	r := NewReencryptor(m, mc, entries, registry, 0)
+	rewritten, err := r.RunOnce(ctx)
+	if err != nil || rewritten != 4 {
+		t.Fatalf("RunOnce() = %d, %v, want 4 values rewritten", rewritten, err)
+	}
+
+	want := map[string]string{"sealed": `"old"`, "plain": `"plain"`}
+	for name, plain := range want {
+		cached, _ := mc.Get(ctx, kb.CacheKey(name))
+		for where, value := range map[string][]byte{"cache": cached, "database": entries.values[name]} {
+			if id, err := SealedKeyID(value); err != nil || id != key.ID {
+				t.Errorf("%s value %s sealed with %q, %v, want %s", where, name, id, err, key.ID)
+			}
+			if opened, _ := m.Open(instCtx, name, value); string(opened) != plain {
+				t.Errorf("%s value %s = %s, want %s", where, name, opened, plain)
+			}
+		}
+	}
+
+	if rewritten, _ := r.RunOnce(ctx); rewritten != 0 {
+		t.Errorf("second RunOnce() rewrote %d values, want 0", rewritten)
+	}
+}
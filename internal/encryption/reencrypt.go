@@ -0,0 +1,246 @@
+package encryption
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/birbparty/birb-nest/internal/cache"
+	"github.com/birbparty/birb-nest/internal/instance"
+)
+
+// reencryptBatch is how many values are read and rewritten at a time
+const reencryptBatch = 500
+
+// Entry is a persisted cache value
+type Entry struct {
+	Key   string
+	Value []byte
+}
+
+// EntryStore reads and rewrites persisted cache values
+type EntryStore interface {
+	// StaleEntries returns up to limit entries of an instance with keys after
+	// after, in key order, whose values are not sealed with keyID
+	StaleEntries(ctx context.Context, instanceID, keyID, after string, limit int) ([]Entry, error)
+
+	// ReplaceEntry sets an entry's value only if it still holds old and
+	// reports whether it did
+	ReplaceEntry(ctx context.Context, instanceID, key string, old, value []byte) (bool, error)
+}
+
+// Reencryptor periodically rewrites the values of encrypted instances that
+// are not sealed with the instance's active data key: values sealed before a
+// rotation and values written before encryption was enabled. Values changed
+// while they are rewritten are left to the next run.
+type Reencryptor struct {
+	manager  *Manager
+	cache    cache.Cache
+	entries  EntryStore // nil on replicas, which only rewrite their cache
+	registry *instance.Registry
+	interval time.Duration
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewReencryptor creates a re-encryptor that runs every interval
+func NewReencryptor(manager *Manager, cacheClient cache.Cache, entries EntryStore, registry *instance.Registry, interval time.Duration) *Reencryptor {
+	return &Reencryptor{
+		manager:  manager,
+		cache:    cacheClient,
+		entries:  entries,
+		registry: registry,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start begins the background re-encryption loop
+func (r *Reencryptor) Start() {
+	go func() {
+		defer close(r.done)
+
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), r.interval)
+				if rewritten, err := r.RunOnce(ctx); err != nil {
+					log.Printf("Re-encryption failed: %v", err)
+				} else if rewritten > 0 {
+					log.Printf("Re-encrypted %d values", rewritten)
+				}
+				cancel()
+			case <-r.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop halts the re-encryption loop and waits for an in-progress run to finish
+func (r *Reencryptor) Stop() {
+	close(r.stop)
+	<-r.done
+}
+
+// RunOnce re-encrypts every encrypted instance and returns how many values
+// were rewritten
+func (r *Reencryptor) RunOnce(ctx context.Context) (int, error) {
+	instances, err := r.registry.List(ctx, instance.ListFilter{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list instances: %w", err)
+	}
+
+	total := 0
+	for _, inst := range instances {
+		if !r.manager.Enabled(inst) || inst.Status == instance.StatusDeleting {
+			continue
+		}
+		rewritten, err := r.ReencryptInstance(ctx, inst.InstanceID)
+		total += rewritten
+		if err != nil {
+			log.Printf("Warning: Failed to re-encrypt instance %s: %v", inst.InstanceID, err)
+		}
+	}
+	return total, nil
+}
+
+// ReencryptInstance rewrites an instance's cached and persisted values with
+// its active data key and returns how many were rewritten
+func (r *Reencryptor) ReencryptInstance(ctx context.Context, instanceID string) (int, error) {
+	inst, err := r.registry.Get(ctx, instanceID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load instance: %w", err)
+	}
+	// Values are opened as the instance, which may hold values sealed with
+	// the data keys of instances it was cloned from
+	ctx = instance.InjectContext(ctx, inst)
+
+	key, err := r.manager.ActiveKey(ctx, instanceID)
+	if err != nil {
+		return 0, err
+	}
+
+	rewritten, err := r.reencryptCache(ctx, instanceID, key)
+	if err != nil {
+		return rewritten, err
+	}
+	if r.entries == nil {
+		return rewritten, nil
+	}
+	persisted, err := r.reencryptEntries(ctx, instanceID, key)
+	return rewritten + persisted, err
+}
+
+// reencryptCache rewrites the instance's cached values
+func (r *Reencryptor) reencryptCache(ctx context.Context, instanceID string, key *DataKey) (int, error) {
+	scanner, ok := r.cache.(cache.Scanner)
+	if !ok {
+		return 0, nil
+	}
+	swapper, ok := r.cache.(cache.CompareAndSwapper)
+	if !ok {
+		return 0, nil
+	}
+
+	kb := instance.NewKeyBuilder(instanceID)
+	prefix := kb.CacheKey("")
+	keys, err := scanner.Scan(ctx, kb.BuildPattern("cache"), 1000)
+	if err != nil {
+		return 0, fmt.Errorf("failed to scan cache keys: %w", err)
+	}
+
+	rewritten := 0
+	for start := 0; start < len(keys); start += reencryptBatch {
+		end := min(start+reencryptBatch, len(keys))
+		values, err := r.cache.GetMultiple(ctx, keys[start:end])
+		if err != nil {
+			return rewritten, fmt.Errorf("failed to read cache keys: %w", err)
+		}
+		for cacheKey, value := range values {
+			resealed, ok, err := r.reseal(ctx, key, instanceID, strings.TrimPrefix(cacheKey, prefix), value)
+			if err != nil {
+				log.Printf("Warning: Failed to re-encrypt cache key %s: %v", cacheKey, err)
+				continue
+			}
+			if !ok {
+				continue
+			}
+			swapped, err := swapper.CompareAndSwap(ctx, cacheKey, value, resealed, 0)
+			if err != nil {
+				return rewritten, fmt.Errorf("failed to rewrite cache key %s: %w", cacheKey, err)
+			}
+			if swapped {
+				rewritten++
+			}
+		}
+	}
+	return rewritten, nil
+}
+
+// reencryptEntries rewrites the instance's persisted values
+func (r *Reencryptor) reencryptEntries(ctx context.Context, instanceID string, key *DataKey) (int, error) {
+	rewritten := 0
+	after := ""
+	for {
+		entries, err := r.entries.StaleEntries(ctx, instanceID, key.ID, after, reencryptBatch)
+		if err != nil {
+			return rewritten, fmt.Errorf("failed to list stale entries: %w", err)
+		}
+		for _, entry := range entries {
+			resealed, ok, err := r.reseal(ctx, key, instanceID, entry.Key, entry.Value)
+			if err != nil {
+				log.Printf("Warning: Failed to re-encrypt key %s of instance %s: %v", entry.Key, instanceID, err)
+				continue
+			}
+			if !ok {
+				continue
+			}
+			replaced, err := r.entries.ReplaceEntry(ctx, instanceID, entry.Key, entry.Value, resealed)
+			if err != nil {
+				return rewritten, fmt.Errorf("failed to rewrite key %s: %w", entry.Key, err)
+			}
+			if replaced {
+				rewritten++
+			}
+		}
+		if len(entries) < reencryptBatch {
+			return rewritten, nil
+		}
+		after = entries[len(entries)-1].Key
+	}
+}
+
+// reseal returns the value of an instance's cache key sealed with dataKey and
+// whether it differs from value
+func (r *Reencryptor) reseal(ctx context.Context, dataKey *DataKey, instanceID, key string, value []byte) ([]byte, bool, error) {
+	if IsSealed(value) {
+		sealedWith, err := SealedKeyID(value)
+		if err != nil {
+			return nil, false, err
+		}
+		if sealedWith == dataKey.ID {
+			return nil, false, nil
+		}
+	}
+
+	plaintext, err := r.manager.Open(ctx, key, value)
+	if err != nil {
+		return nil, false, err
+	}
+	dc, err := r.manager.unwrap(ctx, dataKey)
+	if err != nil {
+		return nil, false, err
+	}
+	resealed, err := sealValue(dc.aead, dataKey.ID, instanceID, key, plaintext)
+	if err != nil {
+		return nil, false, err
+	}
+	return resealed, true, nil
+}
@@ -4,6 +4,8 @@ package instance
 import (
 	"context"
 	"encoding/json"
+	"slices"
+	"strings"
 	"time"
 )
 
@@ -30,12 +32,26 @@ const (
 	InstanceTypeTemporary = "temporary"
 )
 
+// TemplateIDPrefix prefixes the reserved instance IDs that hold template data
+const TemplateIDPrefix = "__template__:"
+
+// MetaDataFrom lists, comma-separated, the instances whose stored values an
+// instance holds copies of after a clone or through a template. Copied values
+// stay encrypted with the data keys of the instance that wrote them.
+const MetaDataFrom = "data_from"
+
+// IsReservedID reports whether an instance ID is reserved for internal use
+func IsReservedID(instanceID string) bool {
+	return strings.HasPrefix(instanceID, TemplateIDPrefix)
+}
+
 // ResourceQuota defines resource limits for an instance
 type ResourceQuota struct {
 	MaxMemoryMB   int64 `json:"max_memory_mb"`
 	MaxStorageGB  int64 `json:"max_storage_gb"`
 	MaxCPUCores   int   `json:"max_cpu_cores"`
 	MaxConcurrent int   `json:"max_concurrent_connections"`
+	MaxKeys       int64 `json:"max_keys,omitempty"` // 0 means unlimited
 }
 
 // Context contains complete instance information including metadata and resource limits
@@ -49,6 +65,8 @@ type Context struct {
 	Metadata      map[string]string `json:"metadata"`
 	ResourceQuota *ResourceQuota    `json:"resource_quota,omitempty"`
 	IsPermanent   bool              `json:"is_permanent"`
+	// Version increases on every registry write so nodes can order changes
+	Version int64 `json:"version"`
 }
 
 // DefaultResourceQuota returns generous default resource limits
@@ -111,10 +129,41 @@ func (c *Context) CanAcceptRequests() bool {
 // CanBeAutoDeleted returns true if the instance can be automatically deleted
 func (c *Context) CanBeAutoDeleted() bool {
 	return !c.IsPermanent &&
-		c.Status == StatusActive &&
+		(c.Status == StatusActive || c.Status == StatusInactive) &&
 		time.Since(c.CreatedAt) >= 30*time.Minute
 }
 
+// Type returns the instance type recorded in the "type" metadata key
+func (c *Context) Type() string {
+	return c.Metadata["type"]
+}
+
+// DataFrom returns the instances listed in MetaDataFrom
+func (c *Context) DataFrom() []string {
+	if c.Metadata[MetaDataFrom] == "" {
+		return nil
+	}
+	return strings.Split(c.Metadata[MetaDataFrom], ",")
+}
+
+// AddDataFrom records that the instance holds copies of the values of the
+// given instances
+func (c *Context) AddDataFrom(instanceIDs ...string) {
+	sources := c.DataFrom()
+	for _, id := range instanceIDs {
+		if id != "" && id != c.InstanceID && !slices.Contains(sources, id) {
+			sources = append(sources, id)
+		}
+	}
+	if len(sources) == 0 {
+		return
+	}
+	if c.Metadata == nil {
+		c.Metadata = make(map[string]string)
+	}
+	c.Metadata[MetaDataFrom] = strings.Join(sources, ",")
+}
+
 // UpdateLastActive updates the last active timestamp to now
 func (c *Context) UpdateLastActive() {
 	c.LastActive = time.Now()
@@ -131,6 +180,7 @@ func (c *Context) Clone() *Context {
 		Status:      c.Status,
 		Metadata:    make(map[string]string),
 		IsPermanent: c.IsPermanent,
+		Version:     c.Version,
 	}
 
 	// Deep copy metadata
@@ -145,6 +195,7 @@ func (c *Context) Clone() *Context {
 			MaxStorageGB:  c.ResourceQuota.MaxStorageGB,
 			MaxCPUCores:   c.ResourceQuota.MaxCPUCores,
 			MaxConcurrent: c.ResourceQuota.MaxConcurrent,
+			MaxKeys:       c.ResourceQuota.MaxKeys,
 		}
 	}
 
@@ -166,15 +217,28 @@ func (c *Context) Validate() error {
 	if c.InstanceID == "" {
 		return ErrEmptyInstanceID
 	}
+	if IsReservedID(c.InstanceID) {
+		return ErrReservedInstanceID
+	}
 	return nil
 }
 
 // Errors for instance context operations
 var (
-	ErrEmptyInstanceID     = &InstanceError{Code: "EMPTY_INSTANCE_ID", Message: "instance ID cannot be empty"}
-	ErrInstanceNotFound    = &InstanceError{Code: "INSTANCE_NOT_FOUND", Message: "instance not found"}
-	ErrInstanceNotActive   = &InstanceError{Code: "INSTANCE_NOT_ACTIVE", Message: "instance is not active"}
-	ErrInvalidInstanceData = &InstanceError{Code: "INVALID_INSTANCE_DATA", Message: "invalid instance data"}
+	ErrEmptyInstanceID      = &InstanceError{Code: "EMPTY_INSTANCE_ID", Message: "instance ID cannot be empty"}
+	ErrInstanceNotFound     = &InstanceError{Code: "INSTANCE_NOT_FOUND", Message: "instance not found"}
+	ErrInstanceExists       = &InstanceError{Code: "INSTANCE_EXISTS", Message: "instance already exists"}
+	ErrInstanceNotActive    = &InstanceError{Code: "INSTANCE_NOT_ACTIVE", Message: "instance is not active"}
+	ErrInvalidInstanceData  = &InstanceError{Code: "INVALID_INSTANCE_DATA", Message: "invalid instance data"}
+	ErrReservedInstanceID   = &InstanceError{Code: "RESERVED_INSTANCE_ID", Message: "instance ID is reserved"}
+	ErrInvalidTransition    = &InstanceError{Code: "INVALID_STATUS_TRANSITION", Message: "invalid instance status transition"}
+	ErrInstanceConflict     = &InstanceError{Code: "INSTANCE_CONFLICT", Message: "instance was changed concurrently"}
+	ErrHistoryUnavailable   = &InstanceError{Code: "HISTORY_UNAVAILABLE", Message: "instance history requires a persistent store"}
+	ErrInvalidInstanceID    = &InstanceError{Code: "INVALID_INSTANCE_ID", Message: "invalid instance ID"}
+	ErrInstanceIDNotAllowed = &InstanceError{Code: "INSTANCE_ID_NOT_ALLOWED", Message: "instance ID is not allowed"}
+	ErrInvalidKey           = &InstanceError{Code: "INVALID_KEY", Message: "invalid key"}
+	ErrKeyTooLong           = &InstanceError{Code: "KEY_TOO_LONG", Message: "key is too long"}
+	ErrReservedKey          = &InstanceError{Code: "RESERVED_KEY", Message: "key is reserved"}
 )
 
 // InstanceError represents an instance-related error
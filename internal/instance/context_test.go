@@ -95,6 +95,19 @@ func TestContext_UpdateLastActive(t *testing.T) {
 	}
 }
 
+func TestContext_AddDataFrom(t *testing.T) {
+	ctx := NewContext("clone-2")
+	if sources := ctx.DataFrom(); sources != nil {
+		t.Errorf("DataFrom() of a new instance = %v, want none", sources)
+	}
+
+	ctx.AddDataFrom("clone-1", "original")
+	ctx.AddDataFrom("original", "clone-2", "")
+	if got := ctx.Metadata[MetaDataFrom]; got != "clone-1,original" {
+		t.Errorf("Metadata[MetaDataFrom] = %q, want clone-1,original", got)
+	}
+}
+
 func TestContext_Clone(t *testing.T) {
 	original := NewContext("test-instance")
 	original.GameType = "mmorpg"
@@ -181,6 +194,11 @@ func TestContext_Validate(t *testing.T) {
 			ctx:       &Context{InstanceID: ""},
 			wantError: true,
 		},
+		{
+			name:      "reserved template ID",
+			ctx:       NewContext(TemplateIDPrefix + "crypt"),
+			wantError: true,
+		},
 	}
 
 	for _, tt := range tests {
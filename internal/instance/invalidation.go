@@ -0,0 +1,146 @@
+package instance
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// RegistryChannel is the pub/sub channel carrying registry change notifications
+const RegistryChannel = "registry:instance:changes"
+
+// Registry change operations
+const (
+	registryOpUpdate = "update"
+	registryOpDelete = "delete"
+)
+
+// ErrInvalidationUnsupported is returned by Watch when the cache backend has no pub/sub
+var ErrInvalidationUnsupported = errors.New("cache backend does not support pub/sub")
+
+// registryChange is the message published when a registry entry changes
+type registryChange struct {
+	InstanceID string `json:"instance_id"`
+	Version    int64  `json:"version"`
+	Op         string `json:"op"`
+	Node       string `json:"node"`
+}
+
+// pubSub is implemented by cache backends that can broadcast between nodes
+type pubSub interface {
+	Publish(ctx context.Context, channel string, message []byte) error
+	Subscribe(ctx context.Context, channel string, handler func(message []byte)) error
+}
+
+// Delays between attempts to restore a lost registry subscription
+var (
+	watchRetryMin = time.Second
+	watchRetryMax = 30 * time.Second
+)
+
+// Watch evicts memory cache entries changed by other nodes until ctx is done.
+// Notifications older than the cached copy are ignored, so out-of-order
+// delivery never brings back a stale context. A failed or dropped
+// subscription is retried with exponential backoff; notifications may have
+// been missed meanwhile, so the memory cache is cleared on each retry.
+func (r *Registry) Watch(ctx context.Context) error {
+	ps, ok := r.cache.(pubSub)
+	if !ok {
+		return ErrInvalidationUnsupported
+	}
+
+	delay := watchRetryMin
+	for {
+		started := time.Now()
+		err := ps.Subscribe(ctx, RegistryChannel, r.handleChange)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if time.Since(started) > watchRetryMax {
+			delay = watchRetryMin // the subscription was healthy for a while
+		}
+		if err != nil {
+			log.Printf("Warning: Failed to watch registry changes, retrying in %v: %v", delay, err)
+		} else {
+			log.Printf("Warning: Registry change subscription closed, retrying in %v", delay)
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil
+		}
+		delay = min(delay*2, watchRetryMax)
+		r.ClearMemoryCache()
+	}
+}
+
+// publishChange tells other nodes that an instance changed. Failures are
+// logged; other nodes then catch up when their memory cache entry expires.
+func (r *Registry) publishChange(ctx context.Context, op, instanceID string, version int64) {
+	ps, ok := r.cache.(pubSub)
+	if !ok {
+		return
+	}
+
+	data, err := json.Marshal(registryChange{
+		InstanceID: instanceID,
+		Version:    version,
+		Op:         op,
+		Node:       r.nodeID,
+	})
+	if err != nil {
+		log.Printf("Warning: Failed to encode registry change for %s: %v", instanceID, err)
+		return
+	}
+	if err := ps.Publish(ctx, RegistryChannel, data); err != nil {
+		log.Printf("Warning: Failed to publish registry change for %s: %v", instanceID, err)
+	}
+}
+
+// handleChange applies a change notification from another node
+func (r *Registry) handleChange(message []byte) {
+	var change registryChange
+	if err := json.Unmarshal(message, &change); err != nil || change.InstanceID == "" {
+		log.Printf("Warning: Ignoring malformed registry change: %s", message)
+		return
+	}
+	if change.Node == r.nodeID {
+		return
+	}
+
+	r.memCacheMu.Lock()
+	defer r.memCacheMu.Unlock()
+
+	if existing, ok := r.memCache[change.InstanceID]; ok &&
+		existing.version >= change.Version && existing.expiration.After(time.Now()) {
+		return // already have this change or a newer one
+	}
+	r.memCache[change.InstanceID] = &cacheEntry{
+		version:    change.Version,
+		expiration: time.Now().Add(CacheTTL),
+	}
+}
+
+// nextVersion returns a version greater than current. Versions follow the
+// wall clock so that writes from different nodes order correctly.
+func nextVersion(current int64) int64 {
+	if now := time.Now().UnixNano(); now > current {
+		return now
+	}
+	return current + 1
+}
+
+// newNodeID returns a random ID distinguishing this process's notifications
+func newNodeID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("node-%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
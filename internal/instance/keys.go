@@ -11,8 +11,49 @@ const (
 	Separator = ":"
 	// Prefix is the namespace prefix for instance-scoped keys
 	Prefix = "instance"
+
+	// MaxKeyLength is the longest cache key clients may use, in bytes; the
+	// database stores keys in VARCHAR(255) columns
+	MaxKeyLength = 255
+
+	// InternalKeyPrefix starts keys the server stores for itself, such as the
+	// chunks of large values
+	InternalKeyPrefix = "__"
 )
 
+// ReservedKeyPrefixes may not start client keys: internal keys, and
+// instance-prefixed keys that ParseKey would attribute to an instance
+var ReservedKeyPrefixes = []string{InternalKeyPrefix, Prefix + Separator}
+
+// ValidateKey checks a client cache key: 1-255 printable ASCII characters
+// other than space and the Redis pattern and hash tag characters *?[]\{},
+// not starting with a reserved prefix
+func ValidateKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("%w: key cannot be empty", ErrInvalidKey)
+	}
+	if len(key) > MaxKeyLength {
+		return fmt.Errorf("%w: %d bytes, at most %d are allowed", ErrKeyTooLong, len(key), MaxKeyLength)
+	}
+	for i := 0; i < len(key); i++ {
+		if b := key[i]; b <= ' ' || b > '~' || strings.IndexByte(`*?[]\{}`, b) >= 0 {
+			return fmt.Errorf("%w: character %q at offset %d is not allowed", ErrInvalidKey, b, i)
+		}
+	}
+	for _, prefix := range ReservedKeyPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return fmt.Errorf("%w: keys may not start with %q", ErrReservedKey, prefix)
+		}
+	}
+	return nil
+}
+
+// HashTag wraps an instance ID in a Redis Cluster hash tag so that every key
+// belonging to the instance maps to the same slot
+func HashTag(instanceID string) string {
+	return "{" + instanceID + "}"
+}
+
 // KeyBuilder handles instance-aware key generation and parsing
 type KeyBuilder struct {
 	instanceID string
@@ -27,6 +68,7 @@ func NewKeyBuilder(instanceID string) *KeyBuilder {
 
 // BuildKey constructs an instance-aware key from components
 // Format: instance:{instance_id}:{component}:{identifiers}
+// The braces are a literal Redis Cluster hash tag, keeping multi-key operations on one slot.
 // If instanceID is empty, returns components joined without instance prefix (backward compatibility)
 func (kb *KeyBuilder) BuildKey(components ...string) string {
 	if kb.instanceID == "" {
@@ -35,19 +77,20 @@ func (kb *KeyBuilder) BuildKey(components ...string) string {
 	}
 
 	// Build instance-prefixed key
-	parts := []string{Prefix, kb.instanceID}
+	parts := []string{Prefix, HashTag(kb.instanceID)}
 	parts = append(parts, components...)
 	return strings.Join(parts, Separator)
 }
 
 // ParseKey extracts the instance ID and components from a key
+// Both hash-tagged and legacy untagged instance segments are accepted.
 // Returns empty instanceID if the key is not instance-prefixed
 func (kb *KeyBuilder) ParseKey(key string) (instanceID string, components []string) {
 	parts := strings.Split(key, Separator)
 
 	// Check if key has instance prefix
 	if len(parts) >= 2 && parts[0] == Prefix {
-		instanceID = parts[1]
+		instanceID = strings.TrimSuffix(strings.TrimPrefix(parts[1], "{"), "}")
 		if len(parts) > 2 {
 			components = parts[2:]
 		}
@@ -70,7 +113,7 @@ func (kb *KeyBuilder) InstanceID() string {
 }
 
 // BuildPattern creates a pattern for scanning keys by instance
-// Returns pattern like "instance:inst_123:*" or "*" for empty instance
+// Returns pattern like "instance:{inst_123}:*" or "*" for empty instance
 func (kb *KeyBuilder) BuildPattern(prefix string) string {
 	if kb.instanceID == "" {
 		if prefix == "" {
@@ -79,7 +122,7 @@ func (kb *KeyBuilder) BuildPattern(prefix string) string {
 		return fmt.Sprintf("%s*", prefix)
 	}
 
-	basePattern := fmt.Sprintf("%s:%s", Prefix, kb.instanceID)
+	basePattern := fmt.Sprintf("%s:%s", Prefix, HashTag(kb.instanceID))
 	if prefix != "" {
 		return fmt.Sprintf("%s:%s*", basePattern, prefix)
 	}
@@ -93,20 +136,37 @@ func (kb *KeyBuilder) IsInstanceKey(key string) bool {
 		return !strings.HasPrefix(key, Prefix+Separator)
 	}
 
-	expectedPrefix := fmt.Sprintf("%s:%s:", Prefix, kb.instanceID)
-	return strings.HasPrefix(key, expectedPrefix)
+	return kb.matchPrefix(key) != ""
 }
 
 // StripInstance removes the instance prefix from a key if present
 func (kb *KeyBuilder) StripInstance(key string) string {
-	if kb.instanceID == "" || !kb.IsInstanceKey(key) {
+	if kb.instanceID == "" {
 		return key
 	}
 
-	prefix := fmt.Sprintf("%s:%s:", Prefix, kb.instanceID)
+	prefix := kb.matchPrefix(key)
+	if prefix == "" {
+		return key
+	}
 	return strings.TrimPrefix(key, prefix)
 }
 
+// matchPrefix returns the instance prefix the key starts with, accepting the
+// legacy untagged form written before keys were hash-tagged
+func (kb *KeyBuilder) matchPrefix(key string) string {
+	tagged := fmt.Sprintf("%s:%s:", Prefix, HashTag(kb.instanceID))
+	if strings.HasPrefix(key, tagged) {
+		return tagged
+	}
+
+	legacy := fmt.Sprintf("%s:%s:", Prefix, kb.instanceID)
+	if strings.HasPrefix(key, legacy) {
+		return legacy
+	}
+	return ""
+}
+
 // Common key component helpers
 
 // TableKey builds a key for table data
@@ -135,3 +195,8 @@ func (kb *KeyBuilder) SchemaKey(table string) string {
 func (kb *KeyBuilder) EventLogKey(timestamp string) string {
 	return kb.BuildKey("eventlog", timestamp)
 }
+
+// UsageKey builds the key holding the instance's cache usage counters
+func (kb *KeyBuilder) UsageKey() string {
+	return kb.BuildKey("usage")
+}
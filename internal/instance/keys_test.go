@@ -1,6 +1,7 @@
 package instance
 
 import (
+	"errors"
 	"strings"
 	"testing"
 )
@@ -49,13 +50,13 @@ func TestKeyBuilder_BuildKey(t *testing.T) {
 			name:       "with instance - single component",
 			instanceID: "inst_123",
 			components: []string{"cache", "user123"},
-			want:       "instance:inst_123:cache:user123",
+			want:       "instance:{inst_123}:cache:user123",
 		},
 		{
 			name:       "with instance - multiple components",
 			instanceID: "inst_123",
 			components: []string{"table", "users", "row", "456"},
-			want:       "instance:inst_123:table:users:row:456",
+			want:       "instance:{inst_123}:table:users:row:456",
 		},
 		{
 			name:       "empty instance - backward compatibility",
@@ -67,7 +68,7 @@ func TestKeyBuilder_BuildKey(t *testing.T) {
 			name:       "webapi format instance ID",
 			instanceID: "inst_1719432000_abc12345",
 			components: []string{"index", "users_by_name", "john"},
-			want:       "instance:inst_1719432000_abc12345:index:users_by_name:john",
+			want:       "instance:{inst_1719432000_abc12345}:index:users_by_name:john",
 		},
 	}
 
@@ -91,12 +92,18 @@ func TestKeyBuilder_ParseKey(t *testing.T) {
 	}{
 		{
 			name:           "instance key - simple",
-			key:            "instance:inst_123:cache:user456",
+			key:            "instance:{inst_123}:cache:user456",
 			wantInstanceID: "inst_123",
 			wantComponents: []string{"cache", "user456"},
 		},
 		{
 			name:           "instance key - complex",
+			key:            "instance:{inst_1719432000_abc12345}:table:users:row:789",
+			wantInstanceID: "inst_1719432000_abc12345",
+			wantComponents: []string{"table", "users", "row", "789"},
+		},
+		{
+			name:           "legacy untagged instance key",
 			key:            "instance:inst_1719432000_abc12345:table:users:row:789",
 			wantInstanceID: "inst_1719432000_abc12345",
 			wantComponents: []string{"table", "users", "row", "789"},
@@ -148,13 +155,13 @@ func TestKeyBuilder_BuildPattern(t *testing.T) {
 			name:       "with instance - no prefix",
 			instanceID: "inst_123",
 			prefix:     "",
-			want:       "instance:inst_123:*",
+			want:       "instance:{inst_123}:*",
 		},
 		{
 			name:       "with instance - with prefix",
 			instanceID: "inst_123",
 			prefix:     "cache",
-			want:       "instance:inst_123:cache*",
+			want:       "instance:{inst_123}:cache*",
 		},
 		{
 			name:       "empty instance - no prefix",
@@ -191,13 +198,19 @@ func TestKeyBuilder_IsInstanceKey(t *testing.T) {
 		{
 			name:       "matching instance key",
 			instanceID: "inst_123",
+			key:        "instance:{inst_123}:cache:data",
+			want:       true,
+		},
+		{
+			name:       "matching legacy untagged key",
+			instanceID: "inst_123",
 			key:        "instance:inst_123:cache:data",
 			want:       true,
 		},
 		{
 			name:       "different instance key",
 			instanceID: "inst_123",
-			key:        "instance:inst_456:cache:data",
+			key:        "instance:{inst_456}:cache:data",
 			want:       false,
 		},
 		{
@@ -215,7 +228,7 @@ func TestKeyBuilder_IsInstanceKey(t *testing.T) {
 		{
 			name:       "empty instance - instance key",
 			instanceID: "",
-			key:        "instance:inst_123:cache:data",
+			key:        "instance:{inst_123}:cache:data",
 			want:       false,
 		},
 	}
@@ -241,14 +254,20 @@ func TestKeyBuilder_StripInstance(t *testing.T) {
 		{
 			name:       "matching instance key",
 			instanceID: "inst_123",
+			key:        "instance:{inst_123}:cache:data",
+			want:       "cache:data",
+		},
+		{
+			name:       "matching legacy untagged key",
+			instanceID: "inst_123",
 			key:        "instance:inst_123:cache:data",
 			want:       "cache:data",
 		},
 		{
 			name:       "different instance key",
 			instanceID: "inst_123",
-			key:        "instance:inst_456:cache:data",
-			want:       "instance:inst_456:cache:data",
+			key:        "instance:{inst_456}:cache:data",
+			want:       "instance:{inst_456}:cache:data",
 		},
 		{
 			name:       "non-instance key",
@@ -259,8 +278,8 @@ func TestKeyBuilder_StripInstance(t *testing.T) {
 		{
 			name:       "empty instance",
 			instanceID: "",
-			key:        "instance:inst_123:cache:data",
-			want:       "instance:inst_123:cache:data",
+			key:        "instance:{inst_123}:cache:data",
+			want:       "instance:{inst_123}:cache:data",
 		},
 	}
 
@@ -286,27 +305,27 @@ func TestKeyBuilder_Helpers(t *testing.T) {
 		{
 			name: "TableKey",
 			fn:   func() string { return kb.TableKey("users", "456") },
-			want: "instance:inst_123:table:users:row:456",
+			want: "instance:{inst_123}:table:users:row:456",
 		},
 		{
 			name: "IndexKey",
 			fn:   func() string { return kb.IndexKey("users_by_name", "john") },
-			want: "instance:inst_123:index:users_by_name:john",
+			want: "instance:{inst_123}:index:users_by_name:john",
 		},
 		{
 			name: "CacheKey",
 			fn:   func() string { return kb.CacheKey("session", "user123") },
-			want: "instance:inst_123:cache:session:user123",
+			want: "instance:{inst_123}:cache:session:user123",
 		},
 		{
 			name: "SchemaKey",
 			fn:   func() string { return kb.SchemaKey("users") },
-			want: "instance:inst_123:schema:users",
+			want: "instance:{inst_123}:schema:users",
 		},
 		{
 			name: "EventLogKey",
 			fn:   func() string { return kb.EventLogKey("1234567890") },
-			want: "instance:inst_123:eventlog:1234567890",
+			want: "instance:{inst_123}:eventlog:1234567890",
 		},
 	}
 
@@ -336,7 +355,7 @@ func TestKeyBuilder_BackwardCompatibility(t *testing.T) {
 	}
 
 	// Should reject instance-prefixed keys
-	if kb.IsInstanceKey("instance:inst_123:cache:user123") {
+	if kb.IsInstanceKey("instance:{inst_123}:cache:user123") {
 		t.Error("Empty instance should reject instance-prefixed keys")
 	}
 }
@@ -353,13 +372,13 @@ func TestKeyBuilder_RealWorldScenarios(t *testing.T) {
 			name:       "overworld instance",
 			instanceID: "inst_1719432000_abc12345",
 			scenario:   "player data cache",
-			expected:   "instance:inst_1719432000_abc12345:cache:player:p123",
+			expected:   "instance:{inst_1719432000_abc12345}:cache:player:p123",
 		},
 		{
 			name:       "dungeon instance",
 			instanceID: "inst_1719432100_xyz98765",
 			scenario:   "monster spawn table",
-			expected:   "instance:inst_1719432100_xyz98765:table:monster_spawns:row:m456",
+			expected:   "instance:{inst_1719432100_xyz98765}:table:monster_spawns:row:m456",
 		},
 		{
 			name:       "cross-instance query protection",
@@ -385,7 +404,7 @@ func TestKeyBuilder_RealWorldScenarios(t *testing.T) {
 					t.Errorf("Monster table key = %v, want %v", got, tt.expected)
 				}
 			case "trying to access another instance":
-				otherKey := "instance:inst_9999999999_other:cache:data"
+				otherKey := "instance:{inst_9999999999_other}:cache:data"
 				got := kb.IsInstanceKey(otherKey)
 				if got {
 					t.Error("Should not allow access to other instance keys")
@@ -394,3 +413,30 @@ func TestKeyBuilder_RealWorldScenarios(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want error
+	}{
+		{"user:12345", nil},
+		{"world/42.bin", nil},
+		{"a_b-c@d=e+f", nil},
+		{strings.Repeat("k", MaxKeyLength), nil},
+		{"", ErrInvalidKey},
+		{"has space", ErrInvalidKey},
+		{"tab\tkey", ErrInvalidKey},
+		{"glob*", ErrInvalidKey},
+		{"tag{x}", ErrInvalidKey},
+		{"café", ErrInvalidKey},
+		{strings.Repeat("k", MaxKeyLength+1), ErrKeyTooLong},
+		{"__chunk:abc:0", ErrReservedKey},
+		{"instance:other:cache:x", ErrReservedKey},
+	}
+
+	for _, tt := range tests {
+		if err := ValidateKey(tt.key); !errors.Is(err, tt.want) {
+			t.Errorf("ValidateKey(%q) = %v, want %v", tt.key, err, tt.want)
+		}
+	}
+}
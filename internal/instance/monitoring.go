@@ -25,8 +25,8 @@ func GetInstanceStats(ctx context.Context, db *sql.DB, instanceID string) (*Moni
 	err := db.QueryRowContext(ctx, `
         SELECT 
             COUNT(*) as row_count,
-            COALESCE(SUM(pg_column_size(value)), 0) as data_size,
-            pg_size_pretty(COALESCE(SUM(pg_column_size(value)), 0)) as size_pretty
+            COALESCE(SUM(COALESCE(pg_column_size(value), pg_column_size(value_bytes))), 0) as data_size,
+            pg_size_pretty(COALESCE(SUM(COALESCE(pg_column_size(value), pg_column_size(value_bytes))), 0)) as size_pretty
         FROM cache_entries
         WHERE instance_id = $1
     `, instanceID).Scan(&stats.RowCount, &stats.DataSizeBytes, &stats.DataSizePretty)
@@ -45,7 +45,7 @@ func GetLargestInstances(ctx context.Context, db *sql.DB, limit int) ([]*Monitor
             SELECT 
                 instance_id,
                 COUNT(*) as row_count,
-                SUM(pg_column_size(value)) as data_size
+                SUM(COALESCE(pg_column_size(value), pg_column_size(value_bytes))) as data_size
             FROM cache_entries
             GROUP BY instance_id
         )
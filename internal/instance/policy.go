@@ -0,0 +1,71 @@
+package instance
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// instanceIDPattern restricts new instance IDs to URL- and key-safe characters
+var instanceIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,127}$`)
+
+// ValidateID checks an instance ID against the naming rules for new
+// instances: 1-128 letters, digits, '.', '_' or '-', starting with a letter
+// or digit. Reserved IDs never pass.
+func ValidateID(instanceID string) error {
+	if instanceID == "" {
+		return ErrEmptyInstanceID
+	}
+	if !instanceIDPattern.MatchString(instanceID) {
+		return fmt.Errorf("%w: %q", ErrInvalidInstanceID, instanceID)
+	}
+	return nil
+}
+
+// IDPolicy decides which instance IDs may be created. A nil policy applies
+// the naming rules only.
+type IDPolicy struct {
+	patterns []*regexp.Regexp
+}
+
+// NewIDPolicy compiles an allowlist of regular expressions. Each pattern must
+// match the whole ID; an empty list allows every valid ID.
+func NewIDPolicy(patterns []string) (*IDPolicy, error) {
+	p := &IDPolicy{}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(`^(?:` + pattern + `)$`)
+		if err != nil {
+			return nil, fmt.Errorf("invalid instance ID pattern %q: %w", pattern, err)
+		}
+		p.patterns = append(p.patterns, re)
+	}
+	return p, nil
+}
+
+// Check reports whether a new instance may use an ID
+func (p *IDPolicy) Check(instanceID string) error {
+	if err := ValidateID(instanceID); err != nil {
+		return err
+	}
+	if p == nil || len(p.patterns) == 0 {
+		return nil
+	}
+	for _, re := range p.patterns {
+		if re.MatchString(instanceID) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %q", ErrInstanceIDNotAllowed, instanceID)
+}
+
+// String lists the allowlist patterns
+func (p *IDPolicy) String() string {
+	if p == nil || len(p.patterns) == 0 {
+		return "*"
+	}
+	patterns := make([]string, len(p.patterns))
+	for i, re := range p.patterns {
+		patterns[i] = strings.TrimSuffix(strings.TrimPrefix(re.String(), "^(?:"), ")$")
+	}
+	return strings.Join(patterns, ",")
+}
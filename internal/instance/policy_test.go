@@ -0,0 +1,63 @@
+package instance
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateID(t *testing.T) {
+	tests := []struct {
+		id   string
+		want error
+	}{
+		{"dungeon-42", nil},
+		{"inst_1719432000_abc12345", nil},
+		{"eu.shard.7", nil},
+		{"", ErrEmptyInstanceID},
+		{"-leading-dash", ErrInvalidInstanceID},
+		{"has space", ErrInvalidInstanceID},
+		{"slash/id", ErrInvalidInstanceID},
+		{TemplateIDPrefix + "base", ErrInvalidInstanceID},
+		{strings.Repeat("a", 129), ErrInvalidInstanceID},
+	}
+
+	for _, tt := range tests {
+		if err := ValidateID(tt.id); !errors.Is(err, tt.want) {
+			t.Errorf("ValidateID(%q) = %v, want %v", tt.id, err, tt.want)
+		}
+	}
+}
+
+func TestIDPolicy(t *testing.T) {
+	policy, err := NewIDPolicy([]string{`dungeon-[0-9]+`, `overworld-(eu|us)`})
+	if err != nil {
+		t.Fatalf("NewIDPolicy() error = %v", err)
+	}
+
+	tests := []struct {
+		id   string
+		want error
+	}{
+		{"dungeon-42", nil},
+		{"overworld-eu", nil},
+		{"dungeon-42x", ErrInstanceIDNotAllowed}, // patterns match the whole ID
+		{"xdungeon-42", ErrInstanceIDNotAllowed},
+		{"anything", ErrInstanceIDNotAllowed},
+		{"bad id", ErrInvalidInstanceID},
+	}
+	for _, tt := range tests {
+		if err := policy.Check(tt.id); !errors.Is(err, tt.want) {
+			t.Errorf("Check(%q) = %v, want %v", tt.id, err, tt.want)
+		}
+	}
+
+	var open *IDPolicy
+	if err := open.Check("anything"); err != nil {
+		t.Errorf("nil policy Check() = %v, want nil", err)
+	}
+
+	if _, err := NewIDPolicy([]string{"("}); err == nil {
+		t.Error("NewIDPolicy() accepted an invalid pattern")
+	}
+}
@@ -1,8 +1,11 @@
 package instance
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"log"
 	"strings"
 	"sync"
 	"time"
@@ -17,6 +20,8 @@ const (
 	CacheTTL = 5 * time.Minute
 	// ActivityUpdateInterval is the minimum interval between activity updates
 	ActivityUpdateInterval = 1 * time.Minute
+	// maxModifyAttempts bounds compare-and-set retries under contention
+	maxModifyAttempts = 10
 )
 
 // CacheInterface defines the minimal cache operations needed by Registry
@@ -29,15 +34,19 @@ type CacheInterface interface {
 // Registry manages instance metadata storage and retrieval
 type Registry struct {
 	cache          CacheInterface
+	store          Store // nil keeps contexts in the cache only
 	memCache       map[string]*cacheEntry
 	memCacheMu     sync.RWMutex
 	lastActivityMu sync.Mutex
 	lastActivity   map[string]time.Time
+	nodeID         string // identifies this registry's invalidation messages
 }
 
-// cacheEntry represents an in-memory cached instance
+// cacheEntry represents an in-memory cached instance. An entry with a nil
+// context records an invalidation, so older copies are not cached again.
 type cacheEntry struct {
 	context    *Context
+	version    int64
 	expiration time.Time
 }
 
@@ -47,15 +56,23 @@ func NewRegistry(cacheClient CacheInterface) *Registry {
 		cache:        cacheClient,
 		memCache:     make(map[string]*cacheEntry),
 		lastActivity: make(map[string]time.Time),
+		nodeID:       newNodeID(),
 	}
 }
 
+// SetStore makes a durable store the source of truth for instance contexts
+func (r *Registry) SetStore(store Store) {
+	r.store = store
+}
+
 // buildKey constructs a Redis key for an instance
 func (r *Registry) buildKey(instanceID string) string {
 	return fmt.Sprintf("%s:%s", RegistryKeyPrefix, instanceID)
 }
 
-// Register creates or updates an instance in the registry
+// Register creates or replaces an instance in the registry. Changes to an
+// existing instance should go through Update or Transition, which do not
+// overwrite concurrent changes.
 func (r *Registry) Register(ctx context.Context, instCtx *Context) error {
 	if err := instCtx.Validate(); err != nil {
 		return err
@@ -63,7 +80,81 @@ func (r *Registry) Register(ctx context.Context, instCtx *Context) error {
 
 	// Update last active time
 	instCtx.UpdateLastActive()
+	instCtx.Version = nextVersion(instCtx.Version)
+
+	// Persist first; the caches are only updated once the store has the change
+	transition := r.newTransition(ctx, instCtx, "", "registered")
+	if err := r.persist(ctx, instCtx, transition); err != nil {
+		return err
+	}
+	if err := r.cacheContext(ctx, instCtx); err != nil {
+		return err
+	}
+
+	r.publishChange(ctx, registryOpUpdate, instCtx.InstanceID, instCtx.Version)
+	return nil
+}
 
+// Create registers a new instance and fails with ErrInstanceExists if the ID
+// is taken. The store, or Redis without one, inserts the entry only if it is
+// absent, so of several concurrent creators exactly one succeeds.
+func (r *Registry) Create(ctx context.Context, instCtx *Context) error {
+	if err := instCtx.Validate(); err != nil {
+		return err
+	}
+
+	instCtx.UpdateLastActive()
+	instCtx.Version = nextVersion(instCtx.Version)
+
+	if r.store != nil {
+		transition := r.newTransition(ctx, instCtx, "", "created")
+		if err := r.store.Create(ctx, instCtx, transition); err != nil {
+			if errors.Is(err, ErrInstanceExists) {
+				return fmt.Errorf("%w: %s", ErrInstanceExists, instCtx.InstanceID)
+			}
+			return fmt.Errorf("failed to persist instance: %w", err)
+		}
+		if err := r.cacheContext(ctx, instCtx); err != nil {
+			return err
+		}
+	} else {
+		data, err := instCtx.MarshalBinary()
+		if err != nil {
+			return fmt.Errorf("failed to serialize context: %w", err)
+		}
+		created, err := r.setIfAbsent(ctx, r.buildKey(instCtx.InstanceID), data)
+		if err != nil {
+			return fmt.Errorf("failed to store in Redis: %w", err)
+		}
+		if !created {
+			return fmt.Errorf("%w: %s", ErrInstanceExists, instCtx.InstanceID)
+		}
+		r.updateMemCache(instCtx)
+	}
+
+	r.publishChange(ctx, registryOpUpdate, instCtx.InstanceID, instCtx.Version)
+	return nil
+}
+
+// setIfAbsent creates a Redis record only if the key is free. Cache backends
+// without an atomic create fall back to a read followed by a write.
+func (r *Registry) setIfAbsent(ctx context.Context, key string, value []byte) (bool, error) {
+	if setter, ok := r.cache.(exclusiveSetter); ok {
+		return setter.SetNX(ctx, key, value, DefaultTTL)
+	}
+
+	if _, err := r.cache.Get(ctx, key); err == nil {
+		return false, nil
+	}
+	if err := r.cache.Set(ctx, key, value, DefaultTTL); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// cacheContext writes a context to Redis and the memory cache. With a store,
+// a Redis failure only costs a cache miss, so it is logged rather than returned.
+func (r *Registry) cacheContext(ctx context.Context, instCtx *Context) error {
 	// Serialize context
 	data, err := instCtx.MarshalBinary()
 	if err != nil {
@@ -73,7 +164,10 @@ func (r *Registry) Register(ctx context.Context, instCtx *Context) error {
 	// Store in Redis with TTL
 	key := r.buildKey(instCtx.InstanceID)
 	if err := r.cache.Set(ctx, key, data, DefaultTTL); err != nil {
-		return fmt.Errorf("failed to store in Redis: %w", err)
+		if r.store == nil {
+			return fmt.Errorf("failed to store in Redis: %w", err)
+		}
+		log.Printf("Warning: Failed to cache instance %s in Redis: %v", instCtx.InstanceID, err)
 	}
 
 	// Update memory cache
@@ -97,6 +191,9 @@ func (r *Registry) Get(ctx context.Context, instanceID string) (*Context, error)
 	key := r.buildKey(instanceID)
 	data, err := r.cache.Get(ctx, key)
 	if err != nil {
+		if r.store != nil {
+			return r.loadFromStore(ctx, instanceID)
+		}
 		if strings.Contains(err.Error(), "not found") || strings.Contains(err.Error(), "nil") {
 			return nil, ErrInstanceNotFound
 		}
@@ -118,11 +215,30 @@ func (r *Registry) Get(ctx context.Context, instanceID string) (*Context, error)
 	return instCtx, nil
 }
 
+// loadFromStore reads an instance missing from Redis from the store and caches it again
+func (r *Registry) loadFromStore(ctx context.Context, instanceID string) (*Context, error) {
+	instCtx, err := r.store.Load(ctx, instanceID)
+	if err != nil {
+		if errors.Is(err, ErrInstanceNotFound) {
+			return nil, ErrInstanceNotFound
+		}
+		return nil, fmt.Errorf("failed to load instance: %w", err)
+	}
+
+	if err := r.cacheContext(ctx, instCtx); err != nil {
+		return nil, err
+	}
+	return instCtx, nil
+}
+
 // GetOrCreate retrieves an instance or creates a new one if not found
 func (r *Registry) GetOrCreate(ctx context.Context, instanceID string) (*Context, error) {
 	if instanceID == "" {
 		return nil, ErrEmptyInstanceID
 	}
+	if IsReservedID(instanceID) {
+		return nil, ErrReservedInstanceID
+	}
 
 	// Try to get existing
 	instCtx, err := r.Get(ctx, instanceID)
@@ -130,10 +246,13 @@ func (r *Registry) GetOrCreate(ctx context.Context, instanceID string) (*Context
 		return instCtx, nil
 	}
 
-	// If not found, create new
+	// If not found, create new; another node may have created it meanwhile
 	if err == ErrInstanceNotFound {
 		instCtx = NewContext(instanceID)
-		if err := r.Register(ctx, instCtx); err != nil {
+		if err := r.Create(ctx, instCtx); err != nil {
+			if errors.Is(err, ErrInstanceExists) {
+				return r.Get(ctx, instanceID)
+			}
 			return nil, fmt.Errorf("failed to register new instance: %w", err)
 		}
 		return instCtx, nil
@@ -142,23 +261,79 @@ func (r *Registry) GetOrCreate(ctx context.Context, instanceID string) (*Context
 	return nil, err
 }
 
-// Update updates an existing instance in the registry
+// Update replaces an existing instance's fields other than its status and
+// creation time. It fails with ErrInstanceConflict if the status no longer
+// matches instCtx; status changes go through Transition.
 func (r *Registry) Update(ctx context.Context, instCtx *Context) error {
 	if err := instCtx.Validate(); err != nil {
 		return err
 	}
 
-	// Check if instance exists
-	existing, err := r.Get(ctx, instCtx.InstanceID)
+	updated, err := r.modify(ctx, instCtx.InstanceID, func(current *Context) (*Transition, error) {
+		if current.Status != instCtx.Status {
+			return nil, fmt.Errorf("%w: instance %s is %s, not %s", ErrInstanceConflict,
+				instCtx.InstanceID, current.Status, instCtx.Status)
+		}
+		createdAt, version := current.CreatedAt, current.Version
+		*current = *instCtx.Clone()
+		current.CreatedAt = createdAt
+		current.Version = version
+		current.UpdateLastActive()
+		return nil, nil
+	})
 	if err != nil {
 		return err
 	}
 
-	// Preserve created_at from existing
-	instCtx.CreatedAt = existing.CreatedAt
+	instCtx.CreatedAt = updated.CreatedAt
+	instCtx.LastActive = updated.LastActive
+	instCtx.Version = updated.Version
+	r.publishChange(ctx, registryOpUpdate, updated.InstanceID, updated.Version)
+	return nil
+}
 
-	// Update and save
-	return r.Register(ctx, instCtx)
+// Transition moves an instance from one status to another, recording the
+// reason and the actor from ctx. The write is a compare-and-set on the Redis
+// record, so it fails with ErrInstanceConflict if the instance has left
+// status from in the meantime. mutate, if set, applies further changes in the
+// same write.
+func (r *Registry) Transition(ctx context.Context, instanceID string, from, to InstanceStatus, reason string, mutate func(*Context)) (*Context, error) {
+	if !CanTransition(from, to) {
+		return nil, fmt.Errorf("%w: %s to %s", ErrInvalidTransition, from, to)
+	}
+
+	updated, err := r.modify(ctx, instanceID, func(current *Context) (*Transition, error) {
+		if current.Status != from {
+			return nil, fmt.Errorf("%w: instance %s is %s, not %s", ErrInstanceConflict,
+				instanceID, current.Status, from)
+		}
+		if mutate != nil {
+			mutate(current)
+		}
+		current.Status = to
+		current.UpdateLastActive()
+		return r.newTransition(ctx, current, from, reason), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Instance %s: %s -> %s by %s (%s)", instanceID, from, to, ActorFromContext(ctx), reason)
+	r.publishChange(ctx, registryOpUpdate, instanceID, updated.Version)
+	return updated, nil
+}
+
+// History returns an instance's status transitions, newest first
+func (r *Registry) History(ctx context.Context, instanceID string, limit int) ([]Transition, error) {
+	transitions, ok := r.store.(TransitionLog)
+	if !ok {
+		return nil, ErrHistoryUnavailable
+	}
+	history, err := transitions.ListTransitions(ctx, instanceID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list transitions: %w", err)
+	}
+	return history, nil
 }
 
 // Delete removes an instance from the registry
@@ -167,6 +342,12 @@ func (r *Registry) Delete(ctx context.Context, instanceID string) error {
 		return ErrEmptyInstanceID
 	}
 
+	if r.store != nil {
+		if err := r.store.Delete(ctx, instanceID); err != nil {
+			return fmt.Errorf("failed to delete persisted instance: %w", err)
+		}
+	}
+
 	// Remove from Redis
 	key := r.buildKey(instanceID)
 	if err := r.cache.Delete(ctx, key); err != nil {
@@ -183,6 +364,7 @@ func (r *Registry) Delete(ctx context.Context, instanceID string) error {
 	delete(r.lastActivity, instanceID)
 	r.lastActivityMu.Unlock()
 
+	r.publishChange(ctx, registryOpDelete, instanceID, nextVersion(0))
 	return nil
 }
 
@@ -193,21 +375,147 @@ func (r *Registry) UpdateLastActive(ctx context.Context, instanceID string) erro
 		return nil
 	}
 
-	// Get current context
-	instCtx, err := r.Get(ctx, instanceID)
-	if err != nil {
-		return err
+	// Other nodes' copies are only behind on last_active, which is not worth
+	// a broadcast on every request
+	_, err := r.modify(ctx, instanceID, func(current *Context) (*Transition, error) {
+		current.UpdateLastActive()
+		return nil, nil
+	})
+	return err
+}
+
+// modify applies fn to the current Redis record and writes the result with
+// compare-and-set, retrying with a fresh read when another writer got there
+// first. fn may return a transition to record alongside the change.
+func (r *Registry) modify(ctx context.Context, instanceID string, fn func(current *Context) (*Transition, error)) (*Context, error) {
+	if instanceID == "" {
+		return nil, ErrEmptyInstanceID
 	}
+	key := r.buildKey(instanceID)
 
-	// Update last active
-	instCtx.UpdateLastActive()
+	reloaded := false
+	for attempt := 0; attempt < maxModifyAttempts; attempt++ {
+		old, err := r.cache.Get(ctx, key)
+		if err != nil {
+			if r.store == nil || reloaded {
+				if strings.Contains(err.Error(), "not found") || strings.Contains(err.Error(), "nil") {
+					return nil, ErrInstanceNotFound
+				}
+				return nil, fmt.Errorf("failed to get from Redis: %w", err)
+			}
+			// Expired or flushed; restore the record from the store and retry
+			if _, err := r.loadFromStore(ctx, instanceID); err != nil {
+				return nil, err
+			}
+			reloaded = true
+			continue
+		}
+
+		current := &Context{}
+		if err := current.UnmarshalBinary(old); err != nil {
+			return nil, fmt.Errorf("failed to deserialize context: %w", err)
+		}
+		transition, err := fn(current)
+		if err != nil {
+			return nil, err
+		}
+		current.Version = nextVersion(current.Version)
+		if transition != nil {
+			transition.Version = current.Version
+		}
+
+		data, err := current.MarshalBinary()
+		if err != nil {
+			return nil, fmt.Errorf("failed to serialize context: %w", err)
+		}
+		swapped, err := r.compareAndSwap(ctx, key, old, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update Redis: %w", err)
+		}
+		if !swapped {
+			continue
+		}
 
-	// Save back to registry
-	return r.Register(ctx, instCtx)
+		if err := r.persist(ctx, current, transition); err != nil {
+			// Put the previous record back unless it has changed again
+			if _, revertErr := r.compareAndSwap(ctx, key, data, old); revertErr != nil {
+				log.Printf("Warning: Failed to revert instance %s in Redis: %v", instanceID, revertErr)
+			}
+			return nil, err
+		}
+		r.updateMemCache(current)
+		return current, nil
+	}
+
+	return nil, fmt.Errorf("%w: instance %s kept changing", ErrInstanceConflict, instanceID)
+}
+
+// compareAndSwap replaces a Redis record only if it still holds old. Cache
+// backends without an atomic swap fall back to a read followed by a write.
+func (r *Registry) compareAndSwap(ctx context.Context, key string, old, value []byte) (bool, error) {
+	if swapper, ok := r.cache.(compareAndSwapper); ok {
+		return swapper.CompareAndSwap(ctx, key, old, value, DefaultTTL)
+	}
+
+	current, err := r.cache.Get(ctx, key)
+	if err != nil || !bytes.Equal(current, old) {
+		return false, nil
+	}
+	if err := r.cache.Set(ctx, key, value, DefaultTTL); err != nil {
+		return false, err
+	}
+	return true, nil
 }
 
-// List retrieves all instances matching the filter criteria
+// persist saves a context to the store, together with its transition when
+// the store keeps a history
+func (r *Registry) persist(ctx context.Context, instCtx *Context, transition *Transition) error {
+	if r.store == nil {
+		return nil
+	}
+	if history, ok := r.store.(TransitionLog); ok && transition != nil {
+		if err := history.SaveTransition(ctx, instCtx, transition); err != nil {
+			return fmt.Errorf("failed to persist instance: %w", err)
+		}
+		return nil
+	}
+	if err := r.store.Save(ctx, instCtx); err != nil {
+		return fmt.Errorf("failed to persist instance: %w", err)
+	}
+	return nil
+}
+
+// newTransition describes a status change of instCtx made by the actor in ctx
+func (r *Registry) newTransition(ctx context.Context, instCtx *Context, from InstanceStatus, reason string) *Transition {
+	return &Transition{
+		InstanceID: instCtx.InstanceID,
+		From:       from,
+		To:         instCtx.Status,
+		Reason:     reason,
+		Actor:      ActorFromContext(ctx),
+		Version:    instCtx.Version,
+		At:         time.Now(),
+	}
+}
+
+// List retrieves all instances matching the filter criteria. With a store,
+// instances whose cache entries expired are included.
 func (r *Registry) List(ctx context.Context, filter ListFilter) ([]*Context, error) {
+	if r.store != nil {
+		stored, err := r.store.List(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list instances: %w", err)
+		}
+
+		var instances []*Context
+		for _, instCtx := range stored {
+			if filter.matches(instCtx) {
+				instances = append(instances, instCtx)
+			}
+		}
+		return instances, nil
+	}
+
 	// This is a simplified implementation
 	// In production, you might want to use Redis SCAN or maintain indices
 
@@ -225,21 +533,66 @@ func (r *Registry) List(ctx context.Context, filter ListFilter) ([]*Context, err
 			continue // Skip failed instances
 		}
 
-		// Apply filters
-		if filter.Status != "" && instCtx.Status != filter.Status {
-			continue
+		if filter.matches(instCtx) {
+			instances = append(instances, instCtx)
 		}
-		if filter.Region != "" && instCtx.Region != filter.Region {
+	}
+
+	return instances, nil
+}
+
+// Reconcile brings Redis and the store in line at startup. Instances only
+// in Redis, such as those registered before the store existed, are saved to
+// the store; every stored instance is then written back to Redis, so the
+// store wins where the two disagree.
+func (r *Registry) Reconcile(ctx context.Context) (*ReconcileResult, error) {
+	result := &ReconcileResult{}
+	if r.store == nil {
+		return result, nil
+	}
+
+	stored, err := r.store.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stored instances: %w", err)
+	}
+	known := make(map[string]bool, len(stored))
+	for _, instCtx := range stored {
+		known[instCtx.InstanceID] = true
+	}
+
+	keys, err := r.scanKeys(ctx, fmt.Sprintf("%s:*", RegistryKeyPrefix))
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan keys: %w", err)
+	}
+	for _, key := range keys {
+		instanceID := strings.TrimPrefix(key, RegistryKeyPrefix+":")
+		if known[instanceID] {
 			continue
 		}
-		if filter.GameType != "" && instCtx.GameType != filter.GameType {
+
+		data, err := r.cache.Get(ctx, key)
+		if err != nil {
+			continue // expired since the scan
+		}
+		instCtx := &Context{}
+		if err := instCtx.UnmarshalBinary(data); err != nil || instCtx.Validate() != nil {
+			log.Printf("Warning: Skipping unreadable registry entry %s", key)
 			continue
 		}
+		if err := r.store.Save(ctx, instCtx); err != nil {
+			return nil, fmt.Errorf("failed to import instance %s: %w", instanceID, err)
+		}
+		result.Imported++
+	}
 
-		instances = append(instances, instCtx)
+	for _, instCtx := range stored {
+		if err := r.cacheContext(ctx, instCtx); err != nil {
+			return nil, fmt.Errorf("failed to cache instance %s: %w", instCtx.InstanceID, err)
+		}
+		result.Restored++
 	}
 
-	return instances, nil
+	return result, nil
 }
 
 // ListFilter defines criteria for filtering instances
@@ -249,6 +602,20 @@ type ListFilter struct {
 	GameType string
 }
 
+// matches reports whether an instance passes the filter
+func (f ListFilter) matches(instCtx *Context) bool {
+	if f.Status != "" && instCtx.Status != f.Status {
+		return false
+	}
+	if f.Region != "" && instCtx.Region != f.Region {
+		return false
+	}
+	if f.GameType != "" && instCtx.GameType != f.GameType {
+		return false
+	}
+	return true
+}
+
 // Memory cache management
 
 func (r *Registry) getFromMemCache(instanceID string) *Context {
@@ -256,7 +623,7 @@ func (r *Registry) getFromMemCache(instanceID string) *Context {
 	entry, exists := r.memCache[instanceID]
 	r.memCacheMu.RUnlock()
 
-	if !exists || entry.expiration.Before(time.Now()) {
+	if !exists || entry.context == nil || entry.expiration.Before(time.Now()) {
 		return nil
 	}
 
@@ -266,11 +633,18 @@ func (r *Registry) getFromMemCache(instanceID string) *Context {
 
 func (r *Registry) updateMemCache(instCtx *Context) {
 	r.memCacheMu.Lock()
+	defer r.memCacheMu.Unlock()
+
+	// Never replace a newer copy, or an invalidation, with an older read
+	if existing, ok := r.memCache[instCtx.InstanceID]; ok &&
+		existing.version > instCtx.Version && existing.expiration.After(time.Now()) {
+		return
+	}
 	r.memCache[instCtx.InstanceID] = &cacheEntry{
 		context:    instCtx.Clone(),
+		version:    instCtx.Version,
 		expiration: time.Now().Add(CacheTTL),
 	}
-	r.memCacheMu.Unlock()
 }
 
 // Activity throttling
@@ -302,11 +676,27 @@ func (r *Registry) refreshTTL(ctx context.Context, instanceID string) {
 }
 
 func (r *Registry) scanKeys(ctx context.Context, pattern string) ([]string, error) {
-	// This is a placeholder implementation
-	// In a real implementation, you would use Redis SCAN command
-	// For now, we'll return an empty slice
-	// The actual SCAN implementation would be in the Redis client
-	return []string{}, nil
+	// Key enumeration is only available when the cache backend supports SCAN
+	scanner, ok := r.cache.(keyScanner)
+	if !ok {
+		return []string{}, nil
+	}
+	return scanner.Scan(ctx, pattern, 100)
+}
+
+// keyScanner is implemented by cache backends that can enumerate keys by pattern
+type keyScanner interface {
+	Scan(ctx context.Context, pattern string, count int) ([]string, error)
+}
+
+// compareAndSwapper is implemented by cache backends that can replace a value atomically
+type compareAndSwapper interface {
+	CompareAndSwap(ctx context.Context, key string, old, value []byte, ttl time.Duration) (bool, error)
+}
+
+// exclusiveSetter is implemented by cache backends that can create a key atomically
+type exclusiveSetter interface {
+	SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
 }
 
 // Stats returns registry statistics
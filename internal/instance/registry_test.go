@@ -2,6 +2,10 @@ package instance
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -116,6 +120,103 @@ func TestRegistry_GetOrCreate(t *testing.T) {
 	}
 }
 
+// LockingMockCache is a MockCache safe for concurrent use with an atomic SetNX
+type LockingMockCache struct {
+	mu sync.Mutex
+	*MockCache
+}
+
+func (m *LockingMockCache) Get(ctx context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.MockCache.Get(ctx, key)
+}
+
+func (m *LockingMockCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.MockCache.Set(ctx, key, value, ttl)
+}
+
+func (m *LockingMockCache) SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.data[key]; ok {
+		return false, nil
+	}
+	m.data[key] = value
+	return true, nil
+}
+
+func TestRegistry_Create(t *testing.T) {
+	tests := []struct {
+		name  string
+		store bool
+	}{
+		{name: "cache only"},
+		{name: "with store", store: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			registry := NewRegistry(NewMockCache())
+			if tt.store {
+				registry.SetStore(NewMockStore())
+			}
+
+			first := NewContext("arena-1")
+			first.Region = "eu"
+			if err := registry.Create(ctx, first); err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+
+			second := NewContext("arena-1")
+			second.Region = "us"
+			if err := registry.Create(ctx, second); !errors.Is(err, ErrInstanceExists) {
+				t.Fatalf("second Create() error = %v, want ErrInstanceExists", err)
+			}
+
+			registry.ClearMemoryCache()
+			got, err := registry.Get(ctx, "arena-1")
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if got.Region != "eu" {
+				t.Errorf("Region = %q, want the first creator's eu", got.Region)
+			}
+		})
+	}
+}
+
+func TestRegistry_CreateConcurrent(t *testing.T) {
+	registry := NewRegistry(&LockingMockCache{MockCache: NewMockCache()})
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	created := 0
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := registry.Create(context.Background(), NewContext("arena-1"))
+			if err != nil && !errors.Is(err, ErrInstanceExists) {
+				t.Errorf("Create() error = %v", err)
+			}
+			if err == nil {
+				mu.Lock()
+				created++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if created != 1 {
+		t.Errorf("%d concurrent Create() calls succeeded, want 1", created)
+	}
+}
+
 func TestRegistry_Update(t *testing.T) {
 	cache := NewMockCache()
 	registry := NewRegistry(cache)
@@ -129,13 +230,15 @@ func TestRegistry_Update(t *testing.T) {
 		t.Fatalf("Register failed: %v", err)
 	}
 
-	// Update it
-	instCtx.Status = StatusPaused
+	// Update it; status changes go through Transition
 	instCtx.GameType = "updated-game"
 	err = registry.Update(ctx, instCtx)
 	if err != nil {
 		t.Fatalf("Update failed: %v", err)
 	}
+	if _, err := registry.Transition(ctx, "test-instance", StatusActive, StatusPaused, "test", nil); err != nil {
+		t.Fatalf("Transition failed: %v", err)
+	}
 
 	// Verify update
 	retrieved, err := registry.Get(ctx, "test-instance")
@@ -309,3 +412,368 @@ func TestRegistry_ActivityThrottling(t *testing.T) {
 		t.Error("Immediate activity update should be throttled")
 	}
 }
+
+// MockStore implements Store and TransitionLog for testing
+type MockStore struct {
+	data        map[string]*Context
+	transitions []Transition
+}
+
+func NewMockStore() *MockStore {
+	return &MockStore{data: make(map[string]*Context)}
+}
+
+func (m *MockStore) Load(ctx context.Context, instanceID string) (*Context, error) {
+	if instCtx, ok := m.data[instanceID]; ok {
+		return instCtx.Clone(), nil
+	}
+	return nil, ErrInstanceNotFound
+}
+
+func (m *MockStore) Save(ctx context.Context, instCtx *Context) error {
+	m.data[instCtx.InstanceID] = instCtx.Clone()
+	return nil
+}
+
+func (m *MockStore) Create(ctx context.Context, instCtx *Context, transition *Transition) error {
+	if _, ok := m.data[instCtx.InstanceID]; ok {
+		return ErrInstanceExists
+	}
+	return m.SaveTransition(ctx, instCtx, transition)
+}
+
+func (m *MockStore) SaveTransition(ctx context.Context, instCtx *Context, transition *Transition) error {
+	m.transitions = append(m.transitions, *transition)
+	return m.Save(ctx, instCtx)
+}
+
+func (m *MockStore) ListTransitions(ctx context.Context, instanceID string, limit int) ([]Transition, error) {
+	var transitions []Transition
+	for i := len(m.transitions) - 1; i >= 0 && len(transitions) < limit; i-- {
+		if m.transitions[i].InstanceID == instanceID {
+			transitions = append(transitions, m.transitions[i])
+		}
+	}
+	return transitions, nil
+}
+
+func (m *MockStore) Delete(ctx context.Context, instanceID string) error {
+	delete(m.data, instanceID)
+	return nil
+}
+
+func (m *MockStore) List(ctx context.Context) ([]*Context, error) {
+	var instances []*Context
+	for _, instCtx := range m.data {
+		instances = append(instances, instCtx.Clone())
+	}
+	return instances, nil
+}
+
+// ScanningMockCache adds key enumeration to MockCache
+type ScanningMockCache struct {
+	*MockCache
+}
+
+func (m *ScanningMockCache) Scan(ctx context.Context, pattern string, count int) ([]string, error) {
+	prefix := strings.TrimSuffix(pattern, "*")
+	var keys []string
+	for key := range m.data {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func TestRegistry_StoreSurvivesCacheFlush(t *testing.T) {
+	cache := NewMockCache()
+	store := NewMockStore()
+	registry := NewRegistry(cache)
+	registry.SetStore(store)
+
+	ctx := context.Background()
+	instCtx := NewContext("dungeon-1")
+	instCtx.IsPermanent = true
+	instCtx.ResourceQuota.MaxMemoryMB = 256
+	if err := registry.Register(ctx, instCtx); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	// Simulate a Redis flush and a fresh process
+	cache.data = make(map[string][]byte)
+	registry.ClearMemoryCache()
+
+	got, err := registry.GetOrCreate(ctx, "dungeon-1")
+	if err != nil {
+		t.Fatalf("GetOrCreate failed: %v", err)
+	}
+	if !got.IsPermanent || got.ResourceQuota.MaxMemoryMB != 256 {
+		t.Errorf("GetOrCreate recreated defaults: %+v", got)
+	}
+	if _, ok := cache.data[registry.buildKey("dungeon-1")]; !ok {
+		t.Error("instance not cached again after store load")
+	}
+
+	if err := registry.Delete(ctx, "dungeon-1"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, ok := store.data["dungeon-1"]; ok {
+		t.Error("Delete left the instance in the store")
+	}
+}
+
+func TestRegistry_ListUsesStore(t *testing.T) {
+	store := NewMockStore()
+	registry := NewRegistry(NewMockCache())
+	registry.SetStore(store)
+
+	ctx := context.Background()
+	for _, id := range []string{"a", "b"} {
+		if err := registry.Register(ctx, NewContext(id)); err != nil {
+			t.Fatalf("Register failed: %v", err)
+		}
+	}
+	paused := NewContext("c")
+	paused.Status = StatusPaused
+	if err := registry.Register(ctx, paused); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	active, err := registry.List(ctx, ListFilter{Status: StatusActive})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(active) != 2 {
+		t.Errorf("List returned %d active instances, want 2", len(active))
+	}
+}
+
+func TestRegistry_Reconcile(t *testing.T) {
+	cache := &ScanningMockCache{NewMockCache()}
+	registry := NewRegistry(cache)
+	ctx := context.Background()
+
+	// Registered before the store existed
+	if err := registry.Register(ctx, NewContext("legacy")); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	store := NewMockStore()
+	stored := NewContext("stored")
+	stored.Status = StatusPaused
+	store.data["stored"] = stored
+	registry.SetStore(store)
+
+	result, err := registry.Reconcile(ctx)
+	if err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if result.Imported != 1 || result.Restored != 1 {
+		t.Errorf("Reconcile() = %+v, want 1 imported and 1 restored", result)
+	}
+	if _, ok := store.data["legacy"]; !ok {
+		t.Error("Reconcile did not import the cache-only instance")
+	}
+	if _, ok := cache.data[registry.buildKey("stored")]; !ok {
+		t.Error("Reconcile did not cache the stored instance")
+	}
+}
+
+// PubSubMockCache adds synchronous pub/sub to MockCache
+type PubSubMockCache struct {
+	*MockCache
+	mu         sync.Mutex
+	handlers   []func(message []byte)
+	subscribed chan struct{}
+}
+
+func (m *PubSubMockCache) Publish(ctx context.Context, channel string, message []byte) error {
+	m.mu.Lock()
+	handlers := append([]func(message []byte){}, m.handlers...)
+	m.mu.Unlock()
+	for _, handler := range handlers {
+		handler(message)
+	}
+	return nil
+}
+
+func (m *PubSubMockCache) Subscribe(ctx context.Context, channel string, handler func(message []byte)) error {
+	m.mu.Lock()
+	m.handlers = append(m.handlers, handler)
+	m.mu.Unlock()
+	m.subscribed <- struct{}{}
+	<-ctx.Done()
+	return nil
+}
+
+func TestRegistry_CrossNodeInvalidation(t *testing.T) {
+	cache := &PubSubMockCache{MockCache: NewMockCache(), subscribed: make(chan struct{})}
+	nodeA := NewRegistry(cache)
+	nodeB := NewRegistry(cache)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for _, r := range []*Registry{nodeA, nodeB} {
+		go r.Watch(ctx)
+		<-cache.subscribed
+	}
+
+	instCtx := NewContext("shard-1")
+	if err := nodeA.Register(ctx, instCtx); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if _, err := nodeB.Get(ctx, "shard-1"); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+
+	// A change on node A must not be hidden by node B's memory cache
+	if _, err := nodeA.Transition(ctx, "shard-1", StatusActive, StatusPaused, "test", nil); err != nil {
+		t.Fatalf("Transition failed: %v", err)
+	}
+	got, err := nodeB.Get(ctx, "shard-1")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.Status != StatusPaused {
+		t.Errorf("node B status = %s, want %s", got.Status, StatusPaused)
+	}
+
+	// A delayed notification older than the cached copy is ignored
+	stale, _ := json.Marshal(registryChange{InstanceID: "shard-1", Version: got.Version - 1, Op: registryOpUpdate, Node: "other"})
+	nodeB.handleChange(stale)
+	if nodeB.getFromMemCache("shard-1") == nil {
+		t.Error("out-of-order notification evicted a newer cached copy")
+	}
+
+	if err := nodeA.Delete(ctx, "shard-1"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := nodeB.Get(ctx, "shard-1"); err != ErrInstanceNotFound {
+		t.Errorf("Get after remote delete error = %v, want %v", err, ErrInstanceNotFound)
+	}
+}
+
+// FlakyPubSubMockCache fails the first subscriptions, then subscribes
+// like PubSubMockCache
+type FlakyPubSubMockCache struct {
+	*PubSubMockCache
+	failures int
+	attempts int
+}
+
+func (m *FlakyPubSubMockCache) Subscribe(ctx context.Context, channel string, handler func(message []byte)) error {
+	m.attempts++
+	if m.attempts <= m.failures {
+		return errors.New("connection refused")
+	}
+	return m.PubSubMockCache.Subscribe(ctx, channel, handler)
+}
+
+func TestRegistry_WatchRetries(t *testing.T) {
+	prevMin, prevMax := watchRetryMin, watchRetryMax
+	watchRetryMin, watchRetryMax = time.Millisecond, 4*time.Millisecond
+	defer func() { watchRetryMin, watchRetryMax = prevMin, prevMax }()
+
+	cache := &FlakyPubSubMockCache{
+		PubSubMockCache: &PubSubMockCache{MockCache: NewMockCache(), subscribed: make(chan struct{})},
+		failures:        3,
+	}
+	registry := NewRegistry(cache)
+	registry.updateMemCache(NewContext("stale"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- registry.Watch(ctx) }()
+
+	select {
+	case <-cache.subscribed:
+	case err := <-done:
+		t.Fatalf("Watch() returned %v before subscribing", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Watch() did not retry the subscription")
+	}
+	if cache.attempts != 4 {
+		t.Errorf("subscribe attempts = %d, want 4", cache.attempts)
+	}
+	if registry.getFromMemCache("stale") != nil {
+		t.Error("memory cache kept entries across a lost subscription")
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Watch() error = %v, want nil after cancel", err)
+	}
+}
+
+func TestRegistry_WatchUnsupported(t *testing.T) {
+	registry := NewRegistry(NewMockCache())
+	if err := registry.Watch(context.Background()); err != ErrInvalidationUnsupported {
+		t.Errorf("Watch() error = %v, want %v", err, ErrInvalidationUnsupported)
+	}
+}
+
+func TestRegistry_Transition(t *testing.T) {
+	store := NewMockStore()
+	registry := NewRegistry(NewMockCache())
+	registry.SetStore(store)
+
+	ctx := WithActor(context.Background(), "tester")
+	if err := registry.Register(ctx, NewContext("raid-1")); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	got, err := registry.Transition(ctx, "raid-1", StatusActive, StatusMigrating, "load", func(c *Context) {
+		c.Metadata["step"] = "loading"
+	})
+	if err != nil {
+		t.Fatalf("Transition failed: %v", err)
+	}
+	if got.Status != StatusMigrating || got.Metadata["step"] != "loading" {
+		t.Errorf("Transition() = %s %v, want migrating with metadata", got.Status, got.Metadata)
+	}
+
+	// A second writer that still expects active loses the race
+	if _, err := registry.Transition(ctx, "raid-1", StatusActive, StatusDeleting, "delete", nil); !errors.Is(err, ErrInstanceConflict) {
+		t.Errorf("stale Transition error = %v, want %v", err, ErrInstanceConflict)
+	}
+	if _, err := registry.Transition(ctx, "raid-1", StatusMigrating, StatusPaused, "pause", nil); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("invalid Transition error = %v, want %v", err, ErrInvalidTransition)
+	}
+
+	// Update cannot change the status or restore a stale one
+	stale := NewContext("raid-1")
+	if err := registry.Update(ctx, stale); !errors.Is(err, ErrInstanceConflict) {
+		t.Errorf("Update with stale status error = %v, want %v", err, ErrInstanceConflict)
+	}
+
+	// Activity updates keep the current status
+	registry.lastActivity = make(map[string]time.Time)
+	if err := registry.UpdateLastActive(ctx, "raid-1"); err != nil {
+		t.Fatalf("UpdateLastActive failed: %v", err)
+	}
+	if current, _ := registry.Get(ctx, "raid-1"); current.Status != StatusMigrating {
+		t.Errorf("status after UpdateLastActive = %s, want %s", current.Status, StatusMigrating)
+	}
+
+	history, err := registry.History(ctx, "raid-1", 10)
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("History returned %d transitions, want 2", len(history))
+	}
+	if h := history[0]; h.From != StatusActive || h.To != StatusMigrating || h.Reason != "load" || h.Actor != "tester" {
+		t.Errorf("latest transition = %+v", h)
+	}
+	if h := history[1]; h.From != "" || h.To != StatusActive || h.Reason != "registered" {
+		t.Errorf("first transition = %+v", h)
+	}
+}
+
+func TestRegistry_HistoryUnavailable(t *testing.T) {
+	registry := NewRegistry(NewMockCache())
+	if _, err := registry.History(context.Background(), "raid-1", 10); err != ErrHistoryUnavailable {
+		t.Errorf("History() error = %v, want %v", err, ErrHistoryUnavailable)
+	}
+}
@@ -0,0 +1,65 @@
+package instance
+
+import (
+	"context"
+	"time"
+)
+
+// ActorSystem is the actor recorded for changes made by the service itself
+const ActorSystem = "system"
+
+// validTransitions lists the statuses each status may move to. Deleting is
+// final; the instance is removed from the registry once its data is gone.
+var validTransitions = map[InstanceStatus][]InstanceStatus{
+	StatusActive:    {StatusInactive, StatusMigrating, StatusPaused, StatusDeleting},
+	StatusInactive:  {StatusActive, StatusMigrating, StatusPaused, StatusDeleting},
+	StatusMigrating: {StatusActive, StatusInactive, StatusDeleting},
+	StatusPaused:    {StatusActive, StatusInactive, StatusMigrating, StatusDeleting},
+	StatusDeleting:  {},
+}
+
+// CanTransition reports whether an instance may move from one status to another
+func CanTransition(from, to InstanceStatus) bool {
+	for _, status := range validTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Transition records one change of an instance's status
+type Transition struct {
+	InstanceID string         `json:"instance_id"`
+	From       InstanceStatus `json:"from"` // empty when the instance was registered
+	To         InstanceStatus `json:"to"`
+	Reason     string         `json:"reason"`
+	Actor      string         `json:"actor"`
+	Version    int64          `json:"version"`
+	At         time.Time      `json:"at"`
+}
+
+// TransitionLog is implemented by stores that keep a status history
+type TransitionLog interface {
+	// SaveTransition saves a context and appends its transition atomically
+	SaveTransition(ctx context.Context, instCtx *Context, transition *Transition) error
+
+	// ListTransitions returns an instance's transitions, newest first
+	ListTransitions(ctx context.Context, instanceID string, limit int) ([]Transition, error)
+}
+
+// actorKey is used for storing the acting principal in context.Context
+type actorKey struct{}
+
+// WithActor records who is changing instances in a Go context
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor recorded in a Go context, or ActorSystem
+func ActorFromContext(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return ActorSystem
+}
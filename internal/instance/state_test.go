@@ -0,0 +1,37 @@
+package instance
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to InstanceStatus
+		want     bool
+	}{
+		{StatusActive, StatusMigrating, true},
+		{StatusInactive, StatusMigrating, true},
+		{StatusMigrating, StatusActive, true},
+		{StatusPaused, StatusDeleting, true},
+		{StatusMigrating, StatusPaused, false},
+		{StatusMigrating, StatusMigrating, false},
+		{StatusDeleting, StatusActive, false},
+		{"", StatusActive, false},
+	}
+
+	for _, tt := range tests {
+		if got := CanTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanTransition(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestActorFromContext(t *testing.T) {
+	if got := ActorFromContext(context.Background()); got != ActorSystem {
+		t.Errorf("ActorFromContext() = %q, want %q", got, ActorSystem)
+	}
+	if got := ActorFromContext(WithActor(context.Background(), "api:10.0.0.1")); got != "api:10.0.0.1" {
+		t.Errorf("ActorFromContext() = %q, want %q", got, "api:10.0.0.1")
+	}
+}
@@ -0,0 +1,29 @@
+package instance
+
+import "context"
+
+// Store persists instance contexts durably. When a Registry has a store it is
+// the source of truth, and Redis and the in-memory cache only cache it.
+type Store interface {
+	// Load returns an instance or ErrInstanceNotFound
+	Load(ctx context.Context, instanceID string) (*Context, error)
+
+	// Save creates or replaces an instance
+	Save(ctx context.Context, instCtx *Context) error
+
+	// Create saves a new instance together with its creation transition.
+	// It returns ErrInstanceExists if the instance is already stored.
+	Create(ctx context.Context, instCtx *Context, transition *Transition) error
+
+	// Delete removes an instance; deleting a missing instance is not an error
+	Delete(ctx context.Context, instanceID string) error
+
+	// List returns every stored instance
+	List(ctx context.Context) ([]*Context, error)
+}
+
+// ReconcileResult reports what Reconcile changed
+type ReconcileResult struct {
+	Imported int `json:"imported"` // cache-only instances saved to the store
+	Restored int `json:"restored"` // stored instances written back to the cache
+}
@@ -0,0 +1,231 @@
+// Package mtls provides mutual TLS between replicas and the primary, with
+// certificates reloaded from disk without a restart
+package mtls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNoCACertificates is returned when the CA file holds no PEM certificates
+	ErrNoCACertificates = errors.New("no CA certificates found")
+
+	// ErrNoServerName is returned when there is no name to check the
+	// primary's certificate against
+	ErrNoServerName = errors.New("no server name to verify the primary certificate against")
+)
+
+// Reloader holds a certificate and CA pool loaded from files and reloads
+// them when the files change. TLS configs built from it always use the
+// latest successfully loaded files; a failed reload keeps the previous ones.
+type Reloader struct {
+	certFile string
+	keyFile  string
+	caFile   string
+	interval time.Duration
+
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	pool     *x509.CertPool
+	modTimes [3]time.Time
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewReloader loads a certificate, its key and a CA bundle, which verifies
+// peers. The files are checked for changes every interval once started.
+func NewReloader(certFile, keyFile, caFile string, interval time.Duration) (*Reloader, error) {
+	r := &Reloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		caFile:   caFile,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if _, err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Start begins watching the files for changes
+func (r *Reloader) Start() {
+	go r.run()
+}
+
+// Stop stops watching the files and waits for the watcher to exit
+func (r *Reloader) Stop() {
+	close(r.stop)
+	<-r.done
+}
+
+func (r *Reloader) run() {
+	defer close(r.done)
+	if r.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			if reloaded, err := r.Reload(); err != nil {
+				log.Printf("Warning: Failed to reload TLS certificates, keeping the previous ones: %v", err)
+			} else if reloaded {
+				log.Printf("Reloaded TLS certificates from %s", r.certFile)
+			}
+		}
+	}
+}
+
+// Reload loads the files again if any of them changed since the last load
+// and reports whether they were reloaded
+func (r *Reloader) Reload() (bool, error) {
+	var modTimes [3]time.Time
+	for i, name := range []string{r.certFile, r.keyFile, r.caFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return false, fmt.Errorf("failed to stat %s: %w", name, err)
+		}
+		modTimes[i] = info.ModTime()
+	}
+
+	r.mu.RLock()
+	unchanged := r.cert != nil && modTimes == r.modTimes
+	r.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("failed to load certificate: %w", err)
+	}
+	caPEM, err := os.ReadFile(r.caFile)
+	if err != nil {
+		return false, fmt.Errorf("failed to read CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return false, fmt.Errorf("%w in %s", ErrNoCACertificates, r.caFile)
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.pool = pool
+	r.modTimes = modTimes
+	r.mu.Unlock()
+	return true, nil
+}
+
+// current returns the loaded certificate and CA pool
+func (r *Reloader) current() (*tls.Certificate, *x509.CertPool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, r.pool
+}
+
+// ServerConfig returns a TLS config for the primary's listener. Client
+// certificates are verified against the CA bundle when presented; with
+// requireClientCert, connections without one are refused.
+func (r *Reloader) ServerConfig(requireClientCert bool) *tls.Config {
+	clientAuth := tls.VerifyClientCertIfGiven
+	if requireClientCert {
+		clientAuth = tls.RequireAndVerifyClientCert
+	}
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cert, pool := r.current()
+			return &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*cert},
+				ClientCAs:    pool,
+				ClientAuth:   clientAuth,
+			}, nil
+		},
+	}
+}
+
+// ClientConfig returns a TLS config for replicas connecting to the primary.
+// The primary's certificate is verified against the CA bundle and must be
+// issued for serverName: a DNS name, or an IP address matched against the
+// certificate's IP SANs. An empty serverName falls back to the name sent in
+// SNI, which is never set for IP literal URLs, so those connections fail
+// rather than skip the hostname check.
+func (r *Reloader) ClientConfig(serverName string) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: serverName,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, _ := r.current()
+			return cert, nil
+		},
+		// The standard verification cannot pick up a reloaded CA pool, so it
+		// is replaced by the same checks against the current pool
+		InsecureSkipVerify: true,
+		VerifyConnection: func(state tls.ConnectionState) error {
+			if len(state.PeerCertificates) == 0 {
+				return errors.New("primary presented no certificate")
+			}
+			name := serverName
+			if name == "" {
+				name = state.ServerName
+			}
+			if name == "" {
+				return ErrNoServerName
+			}
+			_, pool := r.current()
+			opts := x509.VerifyOptions{
+				DNSName:       name,
+				Roots:         pool,
+				Intermediates: x509.NewCertPool(),
+			}
+			for _, cert := range state.PeerCertificates[1:] {
+				opts.Intermediates.AddCert(cert)
+			}
+			_, err := state.PeerCertificates[0].Verify(opts)
+			return err
+		},
+	}
+}
+
+// PeerName returns the identity of a verified client certificate: the first
+// of its DNS names and common name in allowed, or with no allowed names its
+// common name (or first DNS name). ok is false for unverified or unknown peers.
+func PeerName(state *tls.ConnectionState, allowed []string) (name string, ok bool) {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return "", false
+	}
+	leaf := state.VerifiedChains[0][0]
+	names := append([]string{leaf.Subject.CommonName}, leaf.DNSNames...)
+
+	if len(allowed) == 0 {
+		for _, name := range names {
+			if name != "" {
+				return name, true
+			}
+		}
+		return "", false
+	}
+	for _, name := range names {
+		for _, a := range allowed {
+			if name != "" && name == a {
+				return name, true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,244 @@
+package mtls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCA issues certificates for tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate() error = %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue writes a certificate for name, usable as server and client, and the
+// CA bundle into dir
+func (ca *testCA) issue(t *testing.T, dir, name string, serial int64) (certFile, keyFile, caFile string) {
+	t.Helper()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name, "localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("CreateCertificate() error = %v", err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+
+	certFile = filepath.Join(dir, "tls.crt")
+	keyFile = filepath.Join(dir, "tls.key")
+	caFile = filepath.Join(dir, "ca.crt")
+	writeFile(t, certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	writeFile(t, keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	writeFile(t, caFile, ca.pem)
+	return certFile, keyFile, caFile
+}
+
+func writeFile(t *testing.T, name string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(name, data, 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+}
+
+// newPeerServer starts a TLS server answering with the client's peer name
+func newPeerServer(t *testing.T, server *Reloader, allowed []string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, _ := PeerName(r.TLS, allowed)
+		io.WriteString(w, name)
+	}))
+	srv.TLS = server.ServerConfig(false)
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func get(client *http.Client, url string) (string, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return string(body), err
+}
+
+func TestReloader_MutualTLS(t *testing.T) {
+	ca := newTestCA(t)
+	serverCert, serverKey, serverCA := ca.issue(t, t.TempDir(), "primary", 2)
+	server, err := NewReloader(serverCert, serverKey, serverCA, 0)
+	if err != nil {
+		t.Fatalf("NewReloader(server) error = %v", err)
+	}
+	certFile, keyFile, caFile := ca.issue(t, t.TempDir(), "replica-1", 3)
+	client, err := NewReloader(certFile, keyFile, caFile, 0)
+	if err != nil {
+		t.Fatalf("NewReloader(client) error = %v", err)
+	}
+	url := newPeerServer(t, server, []string{"replica-1"}).URL
+
+	peer := &http.Client{Transport: &http.Transport{TLSClientConfig: client.ClientConfig("127.0.0.1")}}
+	if name, err := get(peer, url); err != nil || name != "replica-1" {
+		t.Errorf("peer request = %q, %v, want replica-1", name, err)
+	}
+
+	// Clients without a certificate connect but are not peers
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(ca.pem)
+	anonymous := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	if name, err := get(anonymous, url); err != nil || name != "" {
+		t.Errorf("anonymous request = %q, %v, want no peer name", name, err)
+	}
+
+	// A certificate from another CA is refused
+	other := newTestCA(t)
+	otherCert, otherKey, _ := other.issue(t, t.TempDir(), "replica-1", 4)
+	writeFile(t, certFile, mustRead(t, otherCert))
+	writeFile(t, keyFile, mustRead(t, otherKey))
+	bumpModTime(t, certFile, keyFile)
+	if reloaded, err := client.Reload(); err != nil || !reloaded {
+		t.Fatalf("Reload() = %t, %v, want reloaded", reloaded, err)
+	}
+	fresh := &http.Client{Transport: &http.Transport{TLSClientConfig: client.ClientConfig("127.0.0.1")}}
+	if _, err := get(fresh, url); err == nil {
+		t.Errorf("request with an untrusted certificate succeeded")
+	}
+}
+
+func TestReloader_ClientConfigChecksServerName(t *testing.T) {
+	ca := newTestCA(t)
+	serverCert, serverKey, serverCA := ca.issue(t, t.TempDir(), "primary", 2)
+	server, err := NewReloader(serverCert, serverKey, serverCA, 0)
+	if err != nil {
+		t.Fatalf("NewReloader(server) error = %v", err)
+	}
+	certFile, keyFile, caFile := ca.issue(t, t.TempDir(), "replica-1", 3)
+	client, err := NewReloader(certFile, keyFile, caFile, 0)
+	if err != nil {
+		t.Fatalf("NewReloader(client) error = %v", err)
+	}
+	// The URL is an IP literal, so no name is sent in SNI
+	url := newPeerServer(t, server, nil).URL
+
+	tests := []struct {
+		serverName string
+		wantErr    bool
+	}{
+		{serverName: "127.0.0.1"},       // IP SAN
+		{serverName: "primary"},         // DNS name
+		{serverName: "", wantErr: true}, // nothing to check against
+		{serverName: "10.0.0.5", wantErr: true},
+		{serverName: "replica-2", wantErr: true},
+	}
+	for _, tt := range tests {
+		peer := &http.Client{Transport: &http.Transport{TLSClientConfig: client.ClientConfig(tt.serverName)}}
+		_, err := get(peer, url)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ClientConfig(%q) request error = %v, wantErr %v", tt.serverName, err, tt.wantErr)
+		}
+	}
+}
+
+func TestReloader_KeepsCertificatesOnFailedReload(t *testing.T) {
+	ca := newTestCA(t)
+	certFile, keyFile, caFile := ca.issue(t, t.TempDir(), "primary", 2)
+	r, err := NewReloader(certFile, keyFile, caFile, 0)
+	if err != nil {
+		t.Fatalf("NewReloader() error = %v", err)
+	}
+	if reloaded, err := r.Reload(); err != nil || reloaded {
+		t.Errorf("Reload() of unchanged files = %t, %v, want no reload", reloaded, err)
+	}
+
+	before, _ := r.current()
+	writeFile(t, certFile, []byte("not a certificate"))
+	bumpModTime(t, certFile)
+	if _, err := r.Reload(); err == nil {
+		t.Fatal("Reload() of a broken certificate succeeded")
+	}
+	if after, _ := r.current(); after != before {
+		t.Error("failed reload replaced the certificate")
+	}
+}
+
+func TestPeerName(t *testing.T) {
+	leaf := &x509.Certificate{Subject: pkix.Name{CommonName: "replica-1"}, DNSNames: []string{"replica-1.nest.svc"}}
+	state := &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{leaf}}}
+
+	if name, ok := PeerName(state, nil); !ok || name != "replica-1" {
+		t.Errorf("PeerName(any) = %q, %t", name, ok)
+	}
+	if name, ok := PeerName(state, []string{"replica-1.nest.svc"}); !ok || name != "replica-1.nest.svc" {
+		t.Errorf("PeerName(DNS name) = %q, %t", name, ok)
+	}
+	if _, ok := PeerName(state, []string{"replica-2"}); ok {
+		t.Error("PeerName() accepted a name outside the allowed list")
+	}
+	if _, ok := PeerName(&tls.ConnectionState{PeerCertificates: []*x509.Certificate{leaf}}, nil); ok {
+		t.Error("PeerName() accepted an unverified certificate")
+	}
+}
+
+func mustRead(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	return data
+}
+
+// bumpModTime moves modification times forward so coarse file system
+// timestamps still register a change
+func bumpModTime(t *testing.T, names ...string) {
+	t.Helper()
+	later := time.Now().Add(time.Minute)
+	for _, name := range names {
+		if err := os.Chtimes(name, later, later); err != nil {
+			t.Fatalf("Chtimes() error = %v", err)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/birbparty/birb-nest/internal/archive"
+	"github.com/birbparty/birb-nest/internal/instance"
+	"github.com/birbparty/birb-nest/internal/storage"
+)
+
+// Instance metadata keys maintained by archival
+const (
+	MetaLastArchived   = "last_archived"
+	MetaLastArchiveKey = "last_archive_key"
+)
+
+// ErrArchivingDisabled is returned when no archive store is configured
+var ErrArchivingDisabled = fmt.Errorf("archiving is not configured")
+
+// SetArchiveStore configures where instance archives are written.
+// When archiveOnDelete is set, DeleteInstance archives data before removing it.
+func (o *InstanceOperations) SetArchiveStore(store storage.ArchiveStore, archiveOnDelete bool) {
+	o.archives = store
+	o.archiveOnDelete = archiveOnDelete
+}
+
+// ArchiveStore returns the configured archive store, or nil if archiving is disabled
+func (o *InstanceOperations) ArchiveStore() storage.ArchiveStore {
+	return o.archives
+}
+
+// ArchiveInstance streams a full backup of an instance into the archive store
+func (o *InstanceOperations) ArchiveInstance(ctx context.Context, instanceID string) (*storage.ArchiveInfo, error) {
+	return o.archiveBackup(ctx, instanceID, false)
+}
+
+// recordArchive records the latest archive on the instance when it is registered
+func (o *InstanceOperations) recordArchive(ctx context.Context, instanceID, key string, at time.Time) {
+	inst, err := o.registry.Get(ctx, instanceID)
+	if err != nil {
+		return
+	}
+	inst.Metadata[MetaLastArchived] = at.UTC().Format(time.RFC3339)
+	inst.Metadata[MetaLastArchiveKey] = key
+	if err := o.registry.Update(ctx, inst); err != nil {
+		log.Printf("Warning: Failed to record archive for instance %s: %v", instanceID, err)
+	}
+}
+
+// ListArchives returns an instance's archives, oldest first
+func (o *InstanceOperations) ListArchives(ctx context.Context, instanceID string) ([]storage.ArchiveInfo, error) {
+	if o.archives == nil {
+		return nil, ErrArchivingDisabled
+	}
+	return o.archives.List(ctx, storage.InstanceArchivePrefix(instanceID))
+}
+
+// RestoreOptions controls RestoreFromArchive
+type RestoreOptions struct {
+	ArchiveKey  string    // restore the state captured by this archive and its chain
+	PointInTime time.Time // otherwise the latest backup taken at or before this time; zero means now
+	DryRun      bool      // verify checksums without writing anything
+}
+
+// RestoreResult reports what RestoreFromArchive restored or would restore
+type RestoreResult struct {
+	ArchiveKey string           `json:"archive_key"`
+	Chain      []string         `json:"chain"`
+	RestoredTo time.Time        `json:"restored_to,omitempty"`
+	DryRun     bool             `json:"dry_run"`
+	Summary    *archive.Summary `json:"summary"`
+}
+
+// RestoreFromArchive replaces an instance's data with the state captured by a
+// backup chain, replaying the full backup and then each incremental. Every
+// archive is verified before the restore transaction commits.
+func (o *InstanceOperations) RestoreFromArchive(ctx context.Context, instanceID string, opts RestoreOptions) (*RestoreResult, error) {
+	if o.archives == nil {
+		return nil, ErrArchivingDisabled
+	}
+
+	unlock := o.lockCatalog(instanceID)
+	defer unlock()
+
+	chain, err := o.resolveChain(ctx, instanceID, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var summaries []*archive.Summary
+	if opts.DryRun {
+		summaries, err = o.verifyChain(ctx, chain)
+	} else {
+		summaries, err = o.replayChain(ctx, instanceID, chain)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tip := chain[len(chain)-1]
+	result := &RestoreResult{
+		ArchiveKey: tip.Key,
+		RestoredTo: tip.Until,
+		DryRun:     opts.DryRun,
+		Summary:    summaries[len(summaries)-1],
+	}
+	for _, record := range chain {
+		result.Chain = append(result.Chain, record.Key)
+	}
+	if opts.DryRun {
+		return result, nil
+	}
+	log.Printf("Restored instance %s from %d archives ending at %s", instanceID, len(chain), tip.Key)
+
+	// Later backups no longer describe the instance, so the next one must be full
+	if catalog, err := storage.LoadCatalog(ctx, o.archives, instanceID); err == nil {
+		catalog.NeedsFull = true
+		if err := storage.SaveCatalog(ctx, o.archives, catalog); err != nil {
+			log.Printf("Warning: Failed to update backup catalog for instance %s: %v", instanceID, err)
+		}
+	}
+
+	// Make sure the instance is registered and rebuild its cache from the restored data
+	if _, err := o.registry.GetOrCreate(ctx, instanceID); err != nil {
+		return result, fmt.Errorf("failed to register restored instance: %w", err)
+	}
+	if _, err := o.deleteCacheKeys(ctx, instanceID); err != nil {
+		log.Printf("Warning: Failed to clear cache for restored instance %s: %v", instanceID, err)
+	}
+	if err := o.LoadInstance(ctx, instanceID); err != nil {
+		return result, fmt.Errorf("failed to load restored instance: %w", err)
+	}
+
+	return result, nil
+}
+
+// AutoArchiver periodically archives instances that have gone idle
+type AutoArchiver struct {
+	ops           *InstanceOperations
+	registry      *instance.Registry
+	interval      time.Duration
+	inactiveAfter time.Duration
+	stop          chan struct{}
+	done          chan struct{}
+}
+
+// NewAutoArchiver creates an archiver that runs every interval and archives
+// instances idle for longer than inactiveAfter that changed since their last archive
+func NewAutoArchiver(ops *InstanceOperations, registry *instance.Registry, interval, inactiveAfter time.Duration) *AutoArchiver {
+	return &AutoArchiver{
+		ops:           ops,
+		registry:      registry,
+		interval:      interval,
+		inactiveAfter: inactiveAfter,
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+}
+
+// Start begins the background archival loop
+func (a *AutoArchiver) Start() {
+	go func() {
+		defer close(a.done)
+
+		ticker := time.NewTicker(a.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), a.interval)
+				if archived, err := a.RunOnce(ctx); err != nil {
+					log.Printf("Auto-archival failed: %v", err)
+				} else if archived > 0 {
+					log.Printf("Auto-archived %d instances", archived)
+				}
+				cancel()
+			case <-a.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop halts the archival loop and waits for an in-progress run to finish
+func (a *AutoArchiver) Stop() {
+	close(a.stop)
+	<-a.done
+}
+
+// RunOnce archives every eligible instance, incrementally where possible, and
+// prunes expired tombstones. It returns how many instances were archived.
+func (a *AutoArchiver) RunOnce(ctx context.Context) (int, error) {
+	instances, err := a.registry.List(ctx, instance.ListFilter{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list instances: %w", err)
+	}
+
+	archived := 0
+	now := time.Now()
+	for _, inst := range instances {
+		if !a.needsArchive(inst, now) {
+			continue
+		}
+		if _, err := a.ops.ArchiveIncremental(ctx, inst.InstanceID); err != nil {
+			log.Printf("Warning: Failed to auto-archive instance %s: %v", inst.InstanceID, err)
+			continue
+		}
+		archived++
+	}
+
+	if pruned, err := a.ops.PruneTombstones(ctx); err != nil {
+		log.Printf("Warning: %v", err)
+	} else if pruned > 0 {
+		log.Printf("Pruned %d expired tombstones", pruned)
+	}
+
+	return archived, nil
+}
+
+// needsArchive reports whether an idle instance has activity newer than its last archive
+func (a *AutoArchiver) needsArchive(inst *instance.Context, now time.Time) bool {
+	if inst.Status == instance.StatusDeleting || now.Sub(inst.LastActive) < a.inactiveAfter {
+		return false
+	}
+
+	lastArchived, err := time.Parse(time.RFC3339, inst.Metadata[MetaLastArchived])
+	if err != nil {
+		return true // never archived
+	}
+	return inst.LastActive.After(lastArchived)
+}
@@ -0,0 +1,69 @@
+package operations
+
+import (
+	"testing"
+	"time"
+
+	"github.com/birbparty/birb-nest/internal/instance"
+)
+
+func TestAutoArchiver_NeedsArchive(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	archiver := NewAutoArchiver(nil, nil, time.Minute, time.Hour)
+
+	tests := []struct {
+		name         string
+		lastActive   time.Time
+		status       instance.InstanceStatus
+		lastArchived string
+		want         bool
+	}{
+		{
+			name:       "recently active",
+			lastActive: now.Add(-10 * time.Minute),
+			status:     instance.StatusActive,
+			want:       false,
+		},
+		{
+			name:       "idle and never archived",
+			lastActive: now.Add(-2 * time.Hour),
+			status:     instance.StatusActive,
+			want:       true,
+		},
+		{
+			name:         "idle and archived after last activity",
+			lastActive:   now.Add(-3 * time.Hour),
+			status:       instance.StatusActive,
+			lastArchived: now.Add(-2 * time.Hour).Format(time.RFC3339),
+			want:         false,
+		},
+		{
+			name:         "idle with activity since last archive",
+			lastActive:   now.Add(-2 * time.Hour),
+			status:       instance.StatusInactive,
+			lastArchived: now.Add(-3 * time.Hour).Format(time.RFC3339),
+			want:         true,
+		},
+		{
+			name:       "being deleted",
+			lastActive: now.Add(-2 * time.Hour),
+			status:     instance.StatusDeleting,
+			want:       false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inst := instance.NewContext("inst_archive")
+			inst.LastActive = tt.lastActive
+			inst.Status = tt.status
+			if tt.lastArchived != "" {
+				inst.Metadata[MetaLastArchived] = tt.lastArchived
+			}
+
+			if got := archiver.needsArchive(inst, now); got != tt.want {
+				t.Errorf("needsArchive() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
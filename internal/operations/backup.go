@@ -0,0 +1,392 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/birbparty/birb-nest/internal/archive"
+	"github.com/birbparty/birb-nest/internal/database"
+	"github.com/birbparty/birb-nest/internal/storage"
+	"github.com/jackc/pgx/v5"
+)
+
+// IncrementalOverlap widens each incremental backup's window so rows from
+// transactions that started before the previous backup but committed after
+// its snapshot are still captured. Replaying a row twice is harmless.
+const IncrementalOverlap = time.Minute
+
+// Backup policy defaults (see SetBackupPolicy)
+const (
+	DefaultFullBackupEvery    = 7
+	DefaultTombstoneRetention = 7 * 24 * time.Hour
+)
+
+// backupResult describes an archive written by writeBackup
+type backupResult struct {
+	manifest *archive.Manifest
+	trailer  archive.Trailer
+}
+
+// SetBackupPolicy controls how often incremental archiving falls back to a full
+// backup and how long tombstones for deleted keys are kept. An incremental can
+// only be taken on top of a backup newer than the tombstone retention.
+func (o *InstanceOperations) SetBackupPolicy(fullEvery int, tombstoneRetention time.Duration) {
+	o.fullBackupEvery = fullEvery
+	o.tombstoneRetention = tombstoneRetention
+}
+
+// writeBackup streams a backup in the archive format. With a nil base it
+// writes a full backup; otherwise it writes the keys changed or deleted since base.
+func (o *InstanceOperations) writeBackup(ctx context.Context, instanceID string, w io.Writer, base *storage.BackupRecord) (*backupResult, error) {
+	// A repeatable-read snapshot keeps the manifest consistent with the rows
+	tx, err := o.db.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var until time.Time
+	if err := tx.QueryRow(ctx, `SELECT now()`).Scan(&until); err != nil {
+		return nil, fmt.Errorf("failed to read snapshot time: %w", err)
+	}
+
+	manifest := &archive.Manifest{
+		Kind:       archive.KindFull,
+		InstanceID: instanceID,
+		Until:      until.UTC(),
+		SourceNode: o.nodeID,
+		CreatedAt:  time.Now().UTC(),
+	}
+	if inst, err := o.registry.Get(ctx, instanceID); err == nil {
+		manifest.Instance = inst
+	}
+
+	var since *time.Time
+	if base != nil {
+		from := base.Until.Add(-IncrementalOverlap)
+		since = &from
+		manifest.Kind = archive.KindIncremental
+		manifest.Parent = base.Key
+		manifest.Since = from.UTC()
+	}
+
+	var oldest, newest *time.Time
+	if err := tx.QueryRow(ctx, `
+        SELECT COUNT(*), MIN(updated_at), MAX(updated_at)
+        FROM cache_entries
+        WHERE instance_id = $1 AND ($2::timestamptz IS NULL OR updated_at > $2)
+    `, instanceID, since).Scan(&manifest.KeyCount, &oldest, &newest); err != nil {
+		return nil, fmt.Errorf("failed to summarize instance data: %w", err)
+	}
+	if oldest != nil && newest != nil {
+		manifest.OldestUpdate, manifest.NewestUpdate = oldest.UTC(), newest.UTC()
+	}
+
+	query := `
+        SELECT key, false, value, value_bytes, content_type, content_encoding, version, ttl, metadata, created_at, updated_at
+        FROM cache_entries
+        WHERE instance_id = $1 AND ($2::timestamptz IS NULL OR updated_at > $2)
+    `
+	if since != nil {
+		if err := tx.QueryRow(ctx, `
+            SELECT COUNT(*) FROM cache_tombstones WHERE instance_id = $1 AND deleted_at > $2
+        `, instanceID, since).Scan(&manifest.Tombstones); err != nil {
+			return nil, fmt.Errorf("failed to count tombstones: %w", err)
+		}
+		manifest.KeyCount += manifest.Tombstones
+
+		// Deleted keys are recorded by a trigger and cleared when the key is written again
+		query += `
+        UNION ALL
+        SELECT key, true, NULL::jsonb, NULL::bytea, '', '', 0, NULL::integer, NULL::jsonb, deleted_at, deleted_at
+        FROM cache_tombstones
+        WHERE instance_id = $1 AND deleted_at > $2
+    `
+	}
+
+	// Key order lets chains be compacted with a streaming merge. The merge
+	// compares keys byte by byte, so sort them that way whatever the collation.
+	rows, err := tx.Query(ctx, query+` ORDER BY key COLLATE "C"`, instanceID, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query instance data: %w", err)
+	}
+	defer rows.Close()
+
+	aw, err := archive.NewWriter(w, manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	for rows.Next() {
+		entry := database.BackupEntry{InstanceID: instanceID}
+		if err := rows.Scan(&entry.Key, &entry.Deleted, &entry.Value, &entry.ValueBytes, &entry.ContentType, &entry.Encoding,
+			&entry.Version, &entry.TTL, &entry.Metadata, &entry.CreatedAt, &entry.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		if err := aw.Write(&entry); err != nil {
+			return nil, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating instance data: %w", err)
+	}
+
+	if err := aw.Close(); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Backed up instance %s (%s): %d entries, %d tombstones",
+		instanceID, manifest.Kind, aw.Trailer().Entries, manifest.Tombstones)
+	return &backupResult{manifest: manifest, trailer: aw.Trailer()}, nil
+}
+
+// ArchiveIncremental archives the keys changed since the instance's latest backup.
+// It takes a full backup instead when there is no usable base (see SetBackupPolicy).
+func (o *InstanceOperations) ArchiveIncremental(ctx context.Context, instanceID string) (*storage.ArchiveInfo, error) {
+	return o.archiveBackup(ctx, instanceID, true)
+}
+
+// archiveBackup writes a backup into the archive store and records it in the instance's catalog
+func (o *InstanceOperations) archiveBackup(ctx context.Context, instanceID string, incremental bool) (*storage.ArchiveInfo, error) {
+	if o.archives == nil {
+		return nil, ErrArchivingDisabled
+	}
+
+	// Serialize backups of one instance so each incremental chains onto the previous one
+	unlock := o.lockCatalog(instanceID)
+	defer unlock()
+
+	catalog, err := storage.LoadCatalog(ctx, o.archives, instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var base *storage.BackupRecord
+	if incremental {
+		base = o.incrementalBase(catalog, now)
+	}
+
+	key := storage.ArchiveKey(instanceID, now)
+	metadata := map[string]string{
+		storage.MetaInstanceID:  instanceID,
+		storage.MetaArchiveTime: now.UTC().Format(time.RFC3339),
+		storage.MetaBackupKind:  archive.KindFull,
+	}
+	if base != nil {
+		metadata[storage.MetaBackupKind] = archive.KindIncremental
+		metadata[storage.MetaBackupParent] = base.Key
+	}
+
+	// Pipe the backup straight into the store so the archive is never held in memory
+	pr, pw := io.Pipe()
+	results := make(chan *backupResult, 1)
+	go func() {
+		result, err := o.writeBackup(ctx, instanceID, pw, base)
+		results <- result
+		pw.CloseWithError(err)
+	}()
+
+	info, err := o.archives.Put(ctx, key, pr, metadata)
+	pr.CloseWithError(err)
+	result := <-results
+	if err != nil {
+		return nil, fmt.Errorf("failed to archive instance: %w", err)
+	}
+
+	catalog.Add(storage.BackupRecord{
+		Key:        key,
+		Kind:       result.manifest.Kind,
+		Parent:     result.manifest.Parent,
+		Since:      result.manifest.Since,
+		Until:      result.manifest.Until,
+		Entries:    result.trailer.Entries - result.manifest.Tombstones,
+		Tombstones: result.manifest.Tombstones,
+		Size:       info.Size,
+		CreatedAt:  now.UTC(),
+	})
+	if err := storage.SaveCatalog(ctx, o.archives, catalog); err != nil {
+		return nil, err
+	}
+
+	o.recordArchive(ctx, instanceID, key, now)
+
+	log.Printf("Archived instance %s to %s (%s, %d bytes)", instanceID, key, result.manifest.Kind, info.Size)
+	return info, nil
+}
+
+// incrementalBase picks the backup an incremental should build on, or nil when a full backup is due
+func (o *InstanceOperations) incrementalBase(catalog *storage.Catalog, now time.Time) *storage.BackupRecord {
+	head := catalog.Head()
+	if head == nil || catalog.NeedsFull {
+		return nil
+	}
+	if _, err := catalog.ChainTo(head.Key); err != nil {
+		log.Printf("Warning: Backup chain for instance %s is unusable, taking a full backup: %v", catalog.InstanceID, err)
+		return nil
+	}
+	if o.fullBackupEvery > 0 && catalog.IncrementalsSinceFull() >= o.fullBackupEvery {
+		return nil
+	}
+	// Tombstones older than the retention may already be pruned
+	if o.tombstoneRetention > 0 && now.Sub(head.Until) > o.tombstoneRetention-IncrementalOverlap {
+		return nil
+	}
+	return head
+}
+
+// BackupCatalog returns an instance's backup chain catalog
+func (o *InstanceOperations) BackupCatalog(ctx context.Context, instanceID string) (*storage.Catalog, error) {
+	if o.archives == nil {
+		return nil, ErrArchivingDisabled
+	}
+	return storage.LoadCatalog(ctx, o.archives, instanceID)
+}
+
+// CompactBackups merges the chain ending at the latest backup taken at or
+// before until (zero means now) into one full backup; see storage.CompactChain
+func (o *InstanceOperations) CompactBackups(ctx context.Context, instanceID string, until time.Time, prune bool) (*storage.BackupRecord, error) {
+	if o.archives == nil {
+		return nil, ErrArchivingDisabled
+	}
+	if until.IsZero() {
+		until = time.Now()
+	}
+
+	unlock := o.lockCatalog(instanceID)
+	defer unlock()
+
+	return storage.CompactChain(ctx, o.archives, instanceID, until, prune)
+}
+
+// PruneTombstones deletes tombstones older than the tombstone retention
+func (o *InstanceOperations) PruneTombstones(ctx context.Context) (int64, error) {
+	if o.tombstoneRetention <= 0 {
+		return 0, nil
+	}
+
+	result, err := o.db.Exec(ctx, `
+        DELETE FROM cache_tombstones WHERE deleted_at < $1
+    `, time.Now().Add(-o.tombstoneRetention))
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune tombstones: %w", err)
+	}
+	return result.RowsAffected(), nil
+}
+
+// resolveChain returns the archives to replay for a restore, full backup first
+func (o *InstanceOperations) resolveChain(ctx context.Context, instanceID string, opts RestoreOptions) ([]storage.BackupRecord, error) {
+	catalog, err := storage.LoadCatalog(ctx, o.archives, instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.ArchiveKey != "" {
+		if catalog.Find(opts.ArchiveKey) != nil {
+			return catalog.ChainTo(opts.ArchiveKey)
+		}
+		// Archives taken before the catalog existed are always full
+		return []storage.BackupRecord{{Key: opts.ArchiveKey}}, nil
+	}
+
+	if len(catalog.Backups) == 0 {
+		if !opts.PointInTime.IsZero() {
+			return nil, storage.ErrNoRestorePoint
+		}
+		latest, err := storage.LatestArchive(ctx, o.archives, instanceID)
+		if err != nil {
+			return nil, err
+		}
+		return []storage.BackupRecord{{Key: latest.Key}}, nil
+	}
+
+	at := opts.PointInTime
+	if at.IsZero() {
+		at = time.Now()
+	}
+	return catalog.ChainAt(at)
+}
+
+// verifyChain checks every archive in a chain and that each incremental follows its parent
+func (o *InstanceOperations) verifyChain(ctx context.Context, chain []storage.BackupRecord) ([]*archive.Summary, error) {
+	summaries := make([]*archive.Summary, 0, len(chain))
+	for i, record := range chain {
+		rc, err := o.archives.Get(ctx, record.Key)
+		if err != nil {
+			return nil, err
+		}
+		summary, err := archive.Verify(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", record.Key, err)
+		}
+		if err := checkChainLink(summary, chain, i); err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, nil
+}
+
+// replayChain replaces an instance's data with the state captured by a chain, in one transaction
+func (o *InstanceOperations) replayChain(ctx context.Context, instanceID string, chain []storage.BackupRecord) ([]*archive.Summary, error) {
+	tx, err := o.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM cache_entries WHERE instance_id = $1`, instanceID); err != nil {
+		return nil, fmt.Errorf("failed to clear instance data: %w", err)
+	}
+
+	summaries := make([]*archive.Summary, 0, len(chain))
+	for i, record := range chain {
+		rc, err := o.archives.Get(ctx, record.Key)
+		if err != nil {
+			return nil, err
+		}
+		summary, err := applyBackup(ctx, tx, instanceID, rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", record.Key, err)
+		}
+		if err := checkChainLink(summary, chain, i); err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, summary)
+	}
+
+	// Commit transaction
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return summaries, nil
+}
+
+// checkChainLink verifies that chain[i] may be replayed after chain[i-1]
+func checkChainLink(summary *archive.Summary, chain []storage.BackupRecord, i int) error {
+	if summary.Manifest == nil || !summary.Manifest.IsIncremental() {
+		return nil
+	}
+	if i == 0 {
+		return fmt.Errorf("%w: %s is incremental and cannot be restored on its own", storage.ErrBrokenChain, chain[i].Key)
+	}
+	// A gap would silently lose changes made between the two backups
+	if summary.Manifest.Since.After(chain[i-1].Until) {
+		return fmt.Errorf("%w: %s starts after %s ends", storage.ErrBrokenChain, chain[i].Key, chain[i-1].Key)
+	}
+	return nil
+}
+
+// lockCatalog serializes catalog updates for one instance within this process
+func (o *InstanceOperations) lockCatalog(instanceID string) func() {
+	mu, _ := o.catalogLocks.LoadOrStore(instanceID, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	return mu.(*sync.Mutex).Unlock
+}
@@ -0,0 +1,86 @@
+package operations
+
+import (
+	"testing"
+	"time"
+
+	"github.com/birbparty/birb-nest/internal/archive"
+	"github.com/birbparty/birb-nest/internal/storage"
+)
+
+func TestIncrementalBase(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	chain := func(incrementals int, headAge time.Duration) *storage.Catalog {
+		c := &storage.Catalog{InstanceID: "inst_1"}
+		until := now.Add(-headAge - time.Duration(incrementals)*time.Hour)
+		c.Add(storage.BackupRecord{Key: "full", Kind: archive.KindFull, Until: until})
+		parent := "full"
+		for i := 0; i < incrementals; i++ {
+			until = until.Add(time.Hour)
+			key := parent + "+"
+			c.Add(storage.BackupRecord{Key: key, Kind: archive.KindIncremental, Parent: parent, Until: until})
+			parent = key
+		}
+		return c
+	}
+
+	ops := &InstanceOperations{fullBackupEvery: 3, tombstoneRetention: 24 * time.Hour}
+
+	tests := []struct {
+		name    string
+		catalog *storage.Catalog
+		wantKey string // empty means a full backup is due
+	}{
+		{"no backups", &storage.Catalog{}, ""},
+		{"fresh full", chain(0, time.Hour), "full"},
+		{"short chain", chain(2, time.Hour), "full++"},
+		{"chain at limit", chain(3, time.Hour), ""},
+		{"head older than tombstone retention", chain(1, 48*time.Hour), ""},
+		{"needs full after restore", func() *storage.Catalog {
+			c := chain(1, time.Hour)
+			c.NeedsFull = true
+			return c
+		}(), ""},
+		{"broken chain", func() *storage.Catalog {
+			c := chain(2, time.Hour)
+			c.Remove("full+")
+			return c
+		}(), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := ops.incrementalBase(tt.catalog, now)
+			switch {
+			case tt.wantKey == "" && base != nil:
+				t.Errorf("incrementalBase() = %s, want full backup", base.Key)
+			case tt.wantKey != "" && (base == nil || base.Key != tt.wantKey):
+				t.Errorf("incrementalBase() = %+v, want %s", base, tt.wantKey)
+			}
+		})
+	}
+}
+
+func TestCheckChainLink(t *testing.T) {
+	until := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	chain := []storage.BackupRecord{
+		{Key: "full", Kind: archive.KindFull, Until: until},
+		{Key: "inc", Kind: archive.KindIncremental, Parent: "full", Until: until.Add(time.Hour)},
+	}
+	incremental := func(since time.Time) *archive.Summary {
+		return &archive.Summary{Manifest: &archive.Manifest{Kind: archive.KindIncremental, Since: since}}
+	}
+
+	if err := checkChainLink(incremental(until.Add(-IncrementalOverlap)), chain, 1); err != nil {
+		t.Errorf("overlapping incremental rejected: %v", err)
+	}
+	if err := checkChainLink(incremental(until.Add(time.Minute)), chain, 1); err == nil {
+		t.Error("incremental with a gap accepted")
+	}
+	if err := checkChainLink(incremental(until), chain, 0); err == nil {
+		t.Error("incremental accepted as the start of a chain")
+	}
+	if err := checkChainLink(&archive.Summary{Legacy: true}, chain, 0); err != nil {
+		t.Errorf("legacy backup rejected: %v", err)
+	}
+}
@@ -0,0 +1,218 @@
+package operations
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/birbparty/birb-nest/internal/instance"
+	"github.com/jackc/pgx/v5"
+)
+
+// Instance metadata keys maintained by cloning
+const (
+	MetaClonedFrom      = "cloned_from"
+	MetaClonedAt        = "cloned_at"
+	MetaClonedKeyPrefix = "cloned_key_prefix"
+)
+
+// sourceOnlyMetadata lists metadata that describes the source instance's history
+// and must not be carried over to a clone
+var sourceOnlyMetadata = []string{
+	MetaLastArchived,
+	MetaLastArchiveKey,
+	"last_loaded",
+	"loaded_keys",
+}
+
+// CloneOptions controls CloneInstance
+type CloneOptions struct {
+	TargetID  string // empty generates an ID derived from the source
+	KeyPrefix string // only copy keys starting with this prefix
+	WarmCache bool   // load the copied keys into the cache
+}
+
+// CloneResult reports a completed clone
+type CloneResult struct {
+	SourceID    string            `json:"source_id"`
+	Instance    *instance.Context `json:"instance"`
+	KeysCopied  int64             `json:"keys_copied"`
+	CacheWarmed bool              `json:"cache_warmed"`
+}
+
+// CloneInstance creates a new instance with the source's registry context and
+// a server-side copy of its data. The copy runs as a single INSERT ... SELECT,
+// so it sees one consistent snapshot of the source. A full clone of an
+// instance created from a template shares the template; a prefix clone copies
+// the template keys it needs.
+func (o *InstanceOperations) CloneInstance(ctx context.Context, sourceID string, opts CloneOptions) (*CloneResult, error) {
+	source, err := o.registry.Get(ctx, sourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	targetID := opts.TargetID
+	if targetID == "" {
+		if targetID, err = generateCloneID(sourceID); err != nil {
+			return nil, err
+		}
+	}
+	if targetID == sourceID {
+		return nil, fmt.Errorf("%w: cannot clone an instance onto itself", instance.ErrInstanceExists)
+	}
+	if err := o.checkNewInstance(ctx, targetID); err != nil {
+		return nil, err
+	}
+
+	// Copy metadata and quota; the clone starts its own history
+	now := time.Now()
+	target := source.Clone()
+	target.InstanceID = targetID
+	target.CreatedAt = now
+	target.LastActive = now
+	target.Status = instance.StatusMigrating
+	for _, key := range sourceOnlyMetadata {
+		delete(target.Metadata, key)
+	}
+	target.Metadata[MetaClonedFrom] = sourceID
+	target.Metadata[MetaClonedAt] = now.UTC().Format(time.RFC3339)
+	target.AddDataFrom(sourceID)
+	if opts.KeyPrefix != "" {
+		target.Metadata[MetaClonedKeyPrefix] = opts.KeyPrefix
+		// Template keys are copied, so the clone is not bound to the template
+		delete(target.Metadata, MetaTemplate)
+	}
+
+	if err := o.registry.Create(ctx, target); err != nil {
+		return nil, fmt.Errorf("failed to register clone: %w", err)
+	}
+
+	copied, err := o.copyInstanceData(ctx, sourceID, targetID, opts.KeyPrefix)
+	if err != nil {
+		if delErr := o.registry.Delete(ctx, targetID); delErr != nil {
+			log.Printf("Warning: Failed to remove registry entry for failed clone %s: %v", targetID, delErr)
+		}
+		return nil, err
+	}
+
+	result := &CloneResult{SourceID: sourceID, KeysCopied: copied}
+	var loaded int
+	if opts.WarmCache {
+		if loaded, err = o.warmCache(ctx, targetID, 0, false); err != nil {
+			log.Printf("Warning: Failed to warm cache for clone %s: %v", targetID, err)
+		} else {
+			result.CacheWarmed = true
+		}
+	}
+
+	activated, err := o.registry.Transition(ctx, targetID, instance.StatusMigrating, instance.StatusActive, "cloned",
+		func(inst *instance.Context) {
+			if result.CacheWarmed {
+				inst.Metadata["last_loaded"] = time.Now().Format(time.RFC3339)
+				inst.Metadata["loaded_keys"] = fmt.Sprintf("%d", loaded)
+			}
+		})
+	if err != nil {
+		return nil, fmt.Errorf("failed to activate clone: %w", err)
+	}
+	result.Instance = activated
+
+	log.Printf("Cloned instance %s to %s: %d keys", sourceID, targetID, copied)
+	return result, nil
+}
+
+// checkNewInstance makes sure a new instance's ID is allowed and that it never
+// merges into leftover rows of a deleted instance. Registry entries are
+// claimed atomically by Registry.Create, which fails with
+// instance.ErrInstanceExists if the ID is taken.
+func (o *InstanceOperations) checkNewInstance(ctx context.Context, targetID string) error {
+	if err := o.idPolicy.Check(targetID); err != nil {
+		return err
+	}
+
+	var hasData bool
+	if err := o.db.QueryRow(ctx, `
+        SELECT EXISTS (SELECT 1 FROM cache_entries WHERE instance_id = $1)
+            OR EXISTS (SELECT 1 FROM instance_bases WHERE instance_id = $1)
+    `, targetID).Scan(&hasData); err != nil {
+		return fmt.Errorf("failed to check new instance: %w", err)
+	}
+	if hasData {
+		return fmt.Errorf("%w: %s has stored data", instance.ErrInstanceExists, targetID)
+	}
+	return nil
+}
+
+// copyInstanceData copies rows between instances without leaving the database
+func (o *InstanceOperations) copyInstanceData(ctx context.Context, sourceID, targetID, keyPrefix string) (int64, error) {
+	tx, err := o.db.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead})
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// left() avoids LIKE, whose wildcards would need escaping
+	result, err := tx.Exec(ctx, `
+        INSERT INTO cache_entries (instance_id, key, value, value_bytes, content_type, content_encoding, version, ttl, metadata, created_at, updated_at)
+        SELECT $2, key, value, value_bytes, content_type, content_encoding, version, ttl, metadata, created_at, updated_at
+        FROM cache_entries
+        WHERE instance_id = $1 AND ($3 = '' OR left(key, length($3)) = $3)
+    `, sourceID, targetID, keyPrefix)
+	if err != nil {
+		return 0, fmt.Errorf("failed to copy instance data: %w", err)
+	}
+	copied := result.RowsAffected()
+
+	if keyPrefix == "" {
+		// Share the source's template; its whiteouts keep deleted keys hidden
+		if _, err := tx.Exec(ctx, `
+            INSERT INTO instance_bases (instance_id, base_instance_id)
+            SELECT $2, base_instance_id FROM instance_bases WHERE instance_id = $1
+        `, sourceID, targetID); err != nil {
+			return 0, fmt.Errorf("failed to copy template binding: %w", err)
+		}
+		if _, err := tx.Exec(ctx, `
+            INSERT INTO instance_whiteouts (instance_id, key)
+            SELECT $2, key FROM instance_whiteouts WHERE instance_id = $1
+        `, sourceID, targetID); err != nil {
+			return 0, fmt.Errorf("failed to copy template whiteouts: %w", err)
+		}
+	} else {
+		// Materialize the visible template keys under the prefix
+		result, err := tx.Exec(ctx, `
+            INSERT INTO cache_entries (instance_id, key, value, value_bytes, content_type, content_encoding, version, ttl, metadata, created_at, updated_at)
+            SELECT $2, e.key, e.value, e.value_bytes, e.content_type, e.content_encoding, e.version, e.ttl, e.metadata, e.created_at, e.updated_at
+            FROM instance_bases b
+            JOIN cache_entries e ON e.instance_id = b.base_instance_id
+            WHERE b.instance_id = $1 AND left(e.key, length($3)) = $3
+            AND NOT EXISTS (SELECT 1 FROM cache_entries own WHERE own.instance_id = $1 AND own.key = e.key)
+            AND NOT EXISTS (SELECT 1 FROM instance_whiteouts w WHERE w.instance_id = $1 AND w.key = e.key)
+        `, sourceID, targetID, keyPrefix)
+		if err != nil {
+			return 0, fmt.Errorf("failed to copy template data: %w", err)
+		}
+		copied += result.RowsAffected()
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit instance copy: %w", err)
+	}
+	return copied, nil
+}
+
+// generateCloneID derives a unique instance ID from the source ID
+func generateCloneID(sourceID string) (string, error) {
+	return generateDerivedID(sourceID, "clone")
+}
+
+// generateDerivedID returns a unique instance ID of the form <base>-<kind>-<hex>
+func generateDerivedID(base, kind string) (string, error) {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("failed to generate %s ID: %w", kind, err)
+	}
+	return base + "-" + kind + "-" + hex.EncodeToString(suffix), nil
+}
@@ -0,0 +1,23 @@
+package operations
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateCloneID(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		id, err := generateCloneID("dungeon_42")
+		if err != nil {
+			t.Fatalf("generateCloneID() error = %v", err)
+		}
+		if !strings.HasPrefix(id, "dungeon_42-clone-") {
+			t.Errorf("generateCloneID() = %q, want source-derived prefix", id)
+		}
+		if seen[id] {
+			t.Fatalf("generateCloneID() returned duplicate %q", id)
+		}
+		seen[id] = true
+	}
+}
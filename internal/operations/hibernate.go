@@ -0,0 +1,157 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/birbparty/birb-nest/internal/instance"
+)
+
+// MetaHibernatedAt records when an instance was hibernated
+const MetaHibernatedAt = "hibernated_at"
+
+// Hibernation defaults
+const (
+	DefaultHotSetSize       = 1000
+	DefaultRehydrateTimeout = 5 * time.Minute
+)
+
+// SetHotSetSize sets how many of the most recently updated keys are loaded
+// into the cache when a hibernated instance wakes up; 0 loads every key
+func (o *InstanceOperations) SetHotSetSize(keys int) {
+	o.hotSetSize = keys
+}
+
+// HibernateInstance evicts an instance's keys from the cache and marks it
+// inactive. PostgreSQL stays authoritative; the next request wakes it up.
+func (o *InstanceOperations) HibernateInstance(ctx context.Context, instanceID string) error {
+	inst, err := o.registry.Get(ctx, instanceID)
+	if err != nil {
+		return fmt.Errorf("instance not found: %w", err)
+	}
+	if inst.Status != instance.StatusActive {
+		return fmt.Errorf("%w: %s is %s", instance.ErrInstanceNotActive, instanceID, inst.Status)
+	}
+
+	// Mark the instance first so requests during eviction trigger a reload
+	if _, err := o.registry.Transition(ctx, instanceID, instance.StatusActive, instance.StatusInactive, "hibernate",
+		func(inst *instance.Context) {
+			inst.Metadata[MetaHibernatedAt] = time.Now().UTC().Format(time.RFC3339)
+		}); err != nil {
+		return fmt.Errorf("failed to update instance status: %w", err)
+	}
+
+	evicted, err := o.deleteCacheKeys(ctx, instanceID)
+	if err != nil {
+		return fmt.Errorf("failed to evict cache keys: %w", err)
+	}
+
+	log.Printf("Hibernated instance %s: %d cache keys evicted", instanceID, evicted)
+	return nil
+}
+
+// Rehydrate loads a hibernated instance's hot set into the cache in the
+// background. Concurrent calls for the same instance start a single load.
+func (o *InstanceOperations) Rehydrate(instanceID string) {
+	if _, loading := o.rehydrating.LoadOrStore(instanceID, struct{}{}); loading {
+		return
+	}
+
+	go func() {
+		defer o.rehydrating.Delete(instanceID)
+
+		ctx, cancel := context.WithTimeout(context.Background(), DefaultRehydrateTimeout)
+		defer cancel()
+
+		start := time.Now()
+		if err := o.loadInstance(ctx, instanceID, o.hotSetSize, true, "rehydrate"); err != nil {
+			log.Printf("Warning: Failed to rehydrate instance %s: %v", instanceID, err)
+			return
+		}
+		log.Printf("Rehydrated instance %s in %s", instanceID, time.Since(start).Round(time.Millisecond))
+	}()
+}
+
+// Hibernator periodically hibernates instances that have gone idle
+type Hibernator struct {
+	ops       *InstanceOperations
+	registry  *instance.Registry
+	interval  time.Duration
+	idleAfter time.Duration
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// NewHibernator creates a hibernator that runs every interval and hibernates
+// active instances idle for longer than idleAfter
+func NewHibernator(ops *InstanceOperations, registry *instance.Registry, interval, idleAfter time.Duration) *Hibernator {
+	return &Hibernator{
+		ops:       ops,
+		registry:  registry,
+		interval:  interval,
+		idleAfter: idleAfter,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Start begins the background hibernation loop
+func (h *Hibernator) Start() {
+	go func() {
+		defer close(h.done)
+
+		ticker := time.NewTicker(h.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(instance.WithActor(context.Background(), "hibernator"), h.interval)
+				if hibernated, err := h.RunOnce(ctx); err != nil {
+					log.Printf("Hibernation failed: %v", err)
+				} else if hibernated > 0 {
+					log.Printf("Hibernated %d idle instances", hibernated)
+				}
+				cancel()
+			case <-h.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop halts the hibernation loop and waits for an in-progress run to finish
+func (h *Hibernator) Stop() {
+	close(h.stop)
+	<-h.done
+}
+
+// RunOnce hibernates every idle active instance and returns how many were hibernated
+func (h *Hibernator) RunOnce(ctx context.Context) (int, error) {
+	instances, err := h.registry.List(ctx, instance.ListFilter{Status: instance.StatusActive})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list instances: %w", err)
+	}
+
+	hibernated := 0
+	now := time.Now()
+	for _, inst := range instances {
+		if !h.shouldHibernate(inst, now) {
+			continue
+		}
+		if err := h.ops.HibernateInstance(ctx, inst.InstanceID); err != nil {
+			log.Printf("Warning: Failed to hibernate instance %s: %v", inst.InstanceID, err)
+			continue
+		}
+		hibernated++
+	}
+
+	return hibernated, nil
+}
+
+// shouldHibernate reports whether an active instance has been idle long enough
+func (h *Hibernator) shouldHibernate(inst *instance.Context, now time.Time) bool {
+	return inst.Status == instance.StatusActive && now.Sub(inst.LastActive) >= h.idleAfter
+}
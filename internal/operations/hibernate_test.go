@@ -0,0 +1,37 @@
+package operations
+
+import (
+	"testing"
+	"time"
+
+	"github.com/birbparty/birb-nest/internal/instance"
+)
+
+func TestHibernator_ShouldHibernate(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	hibernator := NewHibernator(nil, nil, time.Minute, time.Hour)
+
+	tests := []struct {
+		name       string
+		lastActive time.Time
+		status     instance.InstanceStatus
+		want       bool
+	}{
+		{"recently active", now.Add(-10 * time.Minute), instance.StatusActive, false},
+		{"idle", now.Add(-2 * time.Hour), instance.StatusActive, true},
+		{"already hibernated", now.Add(-2 * time.Hour), instance.StatusInactive, false},
+		{"loading", now.Add(-2 * time.Hour), instance.StatusMigrating, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inst := instance.NewContext("inst_hibernate")
+			inst.LastActive = tt.lastActive
+			inst.Status = tt.status
+
+			if got := hibernator.shouldHibernate(inst, now); got != tt.want {
+				t.Errorf("shouldHibernate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,15 +2,18 @@ package operations
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"io"
 	"log"
+	"os"
+	"sync"
 	"time"
 
+	"github.com/birbparty/birb-nest/internal/archive"
 	"github.com/birbparty/birb-nest/internal/cache"
 	"github.com/birbparty/birb-nest/internal/database"
 	"github.com/birbparty/birb-nest/internal/instance"
+	"github.com/birbparty/birb-nest/internal/storage"
 	"github.com/jackc/pgx/v5"
 )
 
@@ -19,6 +22,23 @@ type InstanceOperations struct {
 	cache    cache.Cache
 	db       *database.DB // Use DB directly for SQL access
 	registry *instance.Registry
+	nodeID   string // recorded as the source node in archive manifests
+
+	// Optional archive store (see SetArchiveStore)
+	archives        storage.ArchiveStore
+	archiveOnDelete bool
+
+	// Backup chain policy (see SetBackupPolicy)
+	fullBackupEvery    int
+	tombstoneRetention time.Duration
+	catalogLocks       sync.Map // instance ID -> *sync.Mutex
+
+	// Hibernation (see SetHotSetSize)
+	hotSetSize  int
+	rehydrating sync.Map // instance ID -> struct{}
+
+	// IDs allowed for new instances (see SetIDPolicy)
+	idPolicy *instance.IDPolicy
 }
 
 // NewInstanceOperations creates a new instance operations handler
@@ -27,31 +47,110 @@ func NewInstanceOperations(cache cache.Cache, db *database.DB, registry *instanc
 		cache:    cache,
 		db:       db,
 		registry: registry,
+		nodeID:   defaultNodeID(),
+
+		fullBackupEvery:    DefaultFullBackupEvery,
+		tombstoneRetention: DefaultTombstoneRetention,
+		hotSetSize:         DefaultHotSetSize,
+	}
+}
+
+// SetNodeID overrides the node name recorded in archive manifests
+func (o *InstanceOperations) SetNodeID(nodeID string) {
+	o.nodeID = nodeID
+}
+
+// SetIDPolicy restricts the IDs of instances created, cloned or instantiated
+// from templates; nil applies the naming rules only
+func (o *InstanceOperations) SetIDPolicy(policy *instance.IDPolicy) {
+	o.idPolicy = policy
+}
+
+// CreateInstance registers a new instance. It fails with
+// instance.ErrInstanceExists rather than taking over an existing instance or
+// its leftover data.
+func (o *InstanceOperations) CreateInstance(ctx context.Context, inst *instance.Context) error {
+	if err := o.checkNewInstance(ctx, inst.InstanceID); err != nil {
+		return err
+	}
+	// Only clones and templates hold copies of other instances' values
+	delete(inst.Metadata, instance.MetaDataFrom)
+	if err := o.registry.Create(ctx, inst); err != nil {
+		return fmt.Errorf("failed to register instance: %w", err)
+	}
+	return nil
+}
+
+// defaultNodeID identifies this process by hostname
+func defaultNodeID() string {
+	if hostname, err := os.Hostname(); err == nil {
+		return hostname
 	}
+	return "unknown"
 }
 
 // LoadInstance loads all data for an instance from database to cache
 func (o *InstanceOperations) LoadInstance(ctx context.Context, instanceID string) error {
+	return o.loadInstance(ctx, instanceID, 0, false, "load")
+}
+
+// loadInstance moves an instance to migrating, loads its data into the cache
+// and marks it active. If the load fails the previous status is restored.
+func (o *InstanceOperations) loadInstance(ctx context.Context, instanceID string, limit int, skipCached bool, reason string) error {
 	// Verify instance exists
 	inst, err := o.registry.Get(ctx, instanceID)
 	if err != nil {
 		return fmt.Errorf("instance not found: %w", err)
 	}
 
-	// Update status to indicate loading
-	inst.Status = instance.StatusMigrating
-	if err := o.registry.Update(ctx, inst); err != nil {
+	// Update status to indicate loading; fails if another load or a delete got there first
+	previous := inst.Status
+	if _, err := o.registry.Transition(ctx, instanceID, previous, instance.StatusMigrating, reason, nil); err != nil {
+		return fmt.Errorf("failed to update instance status: %w", err)
+	}
+
+	count, err := o.warmCache(ctx, instanceID, limit, skipCached)
+	if err != nil {
+		if _, revertErr := o.registry.Transition(ctx, instanceID, instance.StatusMigrating, previous,
+			reason+" failed", nil); revertErr != nil {
+			log.Printf("Warning: Failed to restore status of instance %s: %v", instanceID, revertErr)
+		}
+		return err
+	}
+
+	// Update instance status
+	if _, err := o.registry.Transition(ctx, instanceID, instance.StatusMigrating, instance.StatusActive,
+		reason+" complete", func(inst *instance.Context) {
+			inst.Metadata["last_loaded"] = time.Now().Format(time.RFC3339)
+			inst.Metadata["loaded_keys"] = fmt.Sprintf("%d", count)
+			delete(inst.Metadata, MetaHibernatedAt)
+		}); err != nil {
 		return fmt.Errorf("failed to update instance status: %w", err)
 	}
 
-	// Query all data for instance
-	rows, err := o.db.Query(ctx, `
-        SELECT key, value, ttl, metadata
+	return nil
+}
+
+// warmCache loads an instance's data, or its hot set, into the cache and
+// returns the number of keys loaded. A positive limit loads only the most
+// recently updated keys; the rest are read from the database on a cache miss.
+// With skipCached, keys already in the cache are left alone so writes made
+// while loading are not overwritten.
+func (o *InstanceOperations) warmCache(ctx context.Context, instanceID string, limit int, skipCached bool) (int, error) {
+	// Query all data for instance, or its hot set
+	query := `
+        SELECT key, value, value_bytes, content_type, content_encoding, ttl, metadata
         FROM cache_entries
         WHERE instance_id = $1
-    `, instanceID)
+    `
+	args := []interface{}{instanceID}
+	if limit > 0 {
+		query += ` ORDER BY updated_at DESC LIMIT $2`
+		args = append(args, limit)
+	}
+	rows, err := o.db.Query(ctx, query, args...)
 	if err != nil {
-		return fmt.Errorf("failed to query instance data: %w", err)
+		return 0, fmt.Errorf("failed to query instance data: %w", err)
 	}
 	defer rows.Close()
 
@@ -61,71 +160,104 @@ func (o *InstanceOperations) LoadInstance(ctx context.Context, instanceID string
 	// Batch load into cache
 	count := 0
 	batch := make(map[string][]byte)
+	flush := func() error {
+		if skipCached {
+			keys := make([]string, 0, len(batch))
+			for key := range batch {
+				keys = append(keys, key)
+			}
+			cached, err := o.cache.GetMultiple(ctx, keys)
+			if err != nil {
+				return fmt.Errorf("failed to check cached keys: %w", err)
+			}
+			for key := range cached {
+				delete(batch, key)
+				count--
+			}
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := o.cache.SetMultiple(ctx, batch, 0); err != nil {
+			return fmt.Errorf("failed to batch set cache: %w", err)
+		}
+		return nil
+	}
 
 	for rows.Next() {
-		var key string
-		var value json.RawMessage
-		var ttl *int
-		var metadata json.RawMessage
-
-		if err := rows.Scan(&key, &value, &ttl, &metadata); err != nil {
-			return fmt.Errorf("failed to scan row: %w", err)
+		var entry database.CacheEntry
+		if err := rows.Scan(&entry.Key, &entry.Value, &entry.ValueBytes, &entry.ContentType, &entry.ContentEncoding,
+			&entry.TTL, &entry.Metadata); err != nil {
+			return 0, fmt.Errorf("failed to scan row: %w", err)
 		}
 
 		// Build cache key
-		cacheKey := kb.CacheKey(key)
-		batch[cacheKey] = value
+		cacheKey := kb.CacheKey(entry.Key)
+		batch[cacheKey] = entry.Data()
 		count++
 
 		// Flush batch every 1000 items
 		if len(batch) >= 1000 {
-			if err := o.cache.SetMultiple(ctx, batch, 0); err != nil {
-				return fmt.Errorf("failed to batch set cache: %w", err)
+			if err := flush(); err != nil {
+				return 0, err
 			}
 			batch = make(map[string][]byte)
 		}
 	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to query instance data: %w", err)
+	}
 
 	// Flush remaining items
 	if len(batch) > 0 {
-		if err := o.cache.SetMultiple(ctx, batch, 0); err != nil {
-			return fmt.Errorf("failed to batch set cache: %w", err)
+		if err := flush(); err != nil {
+			return 0, err
 		}
 	}
 
-	// Update instance status
-	inst.Status = instance.StatusActive
-	inst.Metadata["last_loaded"] = time.Now().Format(time.RFC3339)
-	inst.Metadata["loaded_keys"] = fmt.Sprintf("%d", count)
-
-	if err := o.registry.Update(ctx, inst); err != nil {
-		return fmt.Errorf("failed to update instance status: %w", err)
+	// Bulk loads bypass usage tracking; recount so quotas see the loaded keys
+	if _, err := cache.RecountInstanceUsage(ctx, o.cache, instanceID); err != nil && err != cache.ErrUsageUnsupported {
+		log.Printf("Warning: Failed to recount cache usage of instance %s: %v", instanceID, err)
 	}
 
-	return nil
+	return count, nil
 }
 
 // DeleteInstance removes all data for an instance
 func (o *InstanceOperations) DeleteInstance(ctx context.Context, instanceID string) error {
+	return o.deleteInstance(ctx, instanceID, o.archives != nil && o.archiveOnDelete, "delete")
+}
+
+// deleteInstance removes all data for an instance, archiving it first if
+// requested. An instance already marked deleting is a retry of a failed delete.
+func (o *InstanceOperations) deleteInstance(ctx context.Context, instanceID string, archiveFirst bool, reason string) error {
 	// Update instance status
 	inst, err := o.registry.Get(ctx, instanceID)
 	if err != nil {
 		return fmt.Errorf("instance not found: %w", err)
 	}
 
-	inst.Status = instance.StatusDeleting
-	if err := o.registry.Update(ctx, inst); err != nil {
-		return fmt.Errorf("failed to update instance status: %w", err)
+	// Keep a final copy of the data before anything is removed
+	if archiveFirst {
+		if _, err := o.ArchiveInstance(ctx, instanceID); err != nil {
+			return fmt.Errorf("failed to archive instance before deletion: %w", err)
+		}
+		if inst, err = o.registry.Get(ctx, instanceID); err != nil {
+			return fmt.Errorf("instance not found: %w", err)
+		}
 	}
 
-	// 1. Delete from cache
-	// Note: Since cache interface doesn't have Scan, we'll need to handle this differently
-	// For now, we'll skip cache deletion and rely on TTL expiration
-	// In production, you'd want to implement a scan method or track keys separately
-	log.Printf("Warning: Cache deletion for instance %s requires scan implementation", instanceID)
+	if inst.Status != instance.StatusDeleting {
+		if _, err := o.registry.Transition(ctx, instanceID, inst.Status, instance.StatusDeleting, reason, nil); err != nil {
+			return fmt.Errorf("failed to update instance status: %w", err)
+		}
+	}
 
-	// Cache deletion would happen here if we had scan capability
-	// TODO: Implement key tracking or add scan to cache interface
+	// 1. Delete from cache
+	cacheKeys, err := o.deleteCacheKeys(ctx, instanceID)
+	if err != nil {
+		log.Printf("Warning: Cache deletion for instance %s failed: %v", instanceID, err)
+	}
 
 	// 2. Delete from database
 	result, err := o.db.Exec(ctx, `
@@ -137,125 +269,141 @@ func (o *InstanceOperations) DeleteInstance(ctx context.Context, instanceID stri
 
 	rowsAffected := result.RowsAffected()
 
+	// Drop the template binding and whiteouts; the template itself is kept
+	if _, err := o.db.Exec(ctx, `
+        WITH bases AS (
+            DELETE FROM instance_bases WHERE instance_id = $1
+        )
+        DELETE FROM instance_whiteouts WHERE instance_id = $1
+    `, instanceID); err != nil {
+		return fmt.Errorf("failed to delete template binding: %w", err)
+	}
+
 	// 3. Remove from registry
 	if err := o.registry.Delete(ctx, instanceID); err != nil {
 		return fmt.Errorf("failed to delete from registry: %w", err)
 	}
 
 	// Log deletion info
-	log.Printf("Deleted instance %s: %d database rows",
-		instanceID, rowsAffected)
+	log.Printf("Deleted instance %s: %d cache keys, %d database rows",
+		instanceID, cacheKeys, rowsAffected)
 
 	return nil
 }
 
-// BackupInstance exports instance data to a writer
-func (o *InstanceOperations) BackupInstance(ctx context.Context, instanceID string, w io.Writer) error {
-	// For now, we'll use a simpler approach
-	rows, err := o.db.Query(ctx, `
-        SELECT key, value, version, ttl, metadata, created_at, updated_at
-        FROM cache_entries
-        WHERE instance_id = $1
-        ORDER BY key
-    `, instanceID)
-	if err != nil {
-		return fmt.Errorf("failed to query instance data: %w", err)
+// deleteCacheKeys removes every cached key of an instance when the cache
+// backend supports scanning; otherwise keys are left to expire via TTL
+func (o *InstanceOperations) deleteCacheKeys(ctx context.Context, instanceID string) (int, error) {
+	scanner, ok := o.cache.(cache.Scanner)
+	if !ok {
+		log.Printf("Warning: Cache backend cannot scan keys; instance %s cache entries will expire via TTL", instanceID)
+		return 0, nil
 	}
-	defer rows.Close()
 
-	// Write as JSONL (JSON Lines) format for easy processing
-	encoder := json.NewEncoder(w)
-	count := 0
+	kb := instance.NewKeyBuilder(instanceID)
+	keys, err := scanner.Scan(ctx, kb.BuildPattern(""), 1000)
+	if err != nil {
+		return 0, fmt.Errorf("failed to scan cache keys: %w", err)
+	}
 
-	for rows.Next() {
-		var entry struct {
-			InstanceID string          `json:"instance_id"`
-			Key        string          `json:"key"`
-			Value      json.RawMessage `json:"value"`
-			Version    int             `json:"version"`
-			TTL        *int            `json:"ttl,omitempty"`
-			Metadata   json.RawMessage `json:"metadata,omitempty"`
-			CreatedAt  time.Time       `json:"created_at"`
-			UpdatedAt  time.Time       `json:"updated_at"`
+	// Delete in batches to keep individual commands small
+	for start := 0; start < len(keys); start += 1000 {
+		end := start + 1000
+		if end > len(keys) {
+			end = len(keys)
 		}
+		if err := o.cache.DeleteMultiple(ctx, keys[start:end]); err != nil {
+			return start, fmt.Errorf("failed to delete cache keys: %w", err)
+		}
+	}
 
-		entry.InstanceID = instanceID
+	return len(keys), nil
+}
 
-		if err := rows.Scan(&entry.Key, &entry.Value, &entry.Version,
-			&entry.TTL, &entry.Metadata, &entry.CreatedAt, &entry.UpdatedAt); err != nil {
-			return fmt.Errorf("failed to scan row: %w", err)
-		}
+// BackupInstance exports a full backup of instance data to a writer in the versioned archive format
+func (o *InstanceOperations) BackupInstance(ctx context.Context, instanceID string, w io.Writer) error {
+	_, err := o.writeBackup(ctx, instanceID, w, nil)
+	return err
+}
 
-		if err := encoder.Encode(entry); err != nil {
-			return fmt.Errorf("failed to encode entry: %w", err)
-		}
+// RestoreInstance imports instance data from a reader, merging it into existing data
+func (o *InstanceOperations) RestoreInstance(ctx context.Context, instanceID string, r io.Reader) error {
+	tx, err := o.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
 
-		count++
+	summary, err := applyBackup(ctx, tx, instanceID, r)
+	if err != nil {
+		return err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	log.Printf("Backed up instance %s: %d entries", instanceID, count)
+	if summary.Legacy {
+		log.Printf("Restored instance %s from legacy JSONL backup: %d entries (no checksums)", instanceID, summary.Entries)
+	} else {
+		log.Printf("Restored instance %s: %d entries", instanceID, summary.Entries)
+	}
 	return nil
 }
 
-// RestoreInstance imports instance data from a reader
-func (o *InstanceOperations) RestoreInstance(ctx context.Context, instanceID string, r io.Reader) error {
-	// Parse JSONL format
-	decoder := json.NewDecoder(r)
-	count := 0
+// VerifyBackup checks a backup's structure and checksums without applying it
+func (o *InstanceOperations) VerifyBackup(ctx context.Context, r io.Reader) (*archive.Summary, error) {
+	return archive.Verify(r)
+}
 
-	// Begin transaction for atomicity
-	tx, err := o.db.BeginTx(ctx, pgx.TxOptions{})
+// applyBackup replays an archive or legacy JSONL backup inside tx.
+// Tombstones delete keys. Checksums are verified before it returns, so callers
+// that commit only on success never apply a corrupt archive.
+func applyBackup(ctx context.Context, tx pgx.Tx, instanceID string, r io.Reader) (*archive.Summary, error) {
+	ar, err := archive.NewReader(r)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return nil, err
 	}
-	defer tx.Rollback(ctx)
+	defer ar.Close()
 
 	// Insert query
 	insertQuery := `
-        INSERT INTO cache_entries (instance_id, key, value, version, ttl, metadata, created_at, updated_at)
-        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        INSERT INTO cache_entries (instance_id, key, value, value_bytes, content_type, content_encoding, version, ttl, metadata, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
         ON CONFLICT (instance_id, key) DO UPDATE SET
             value = EXCLUDED.value,
+            value_bytes = EXCLUDED.value_bytes,
+            content_type = EXCLUDED.content_type,
+            content_encoding = EXCLUDED.content_encoding,
             version = EXCLUDED.version,
             ttl = EXCLUDED.ttl,
             metadata = EXCLUDED.metadata,
             updated_at = EXCLUDED.updated_at
     `
 
-	// Process each line
+	// Process each entry
 	for {
-		var entry struct {
-			InstanceID string          `json:"instance_id"`
-			Key        string          `json:"key"`
-			Value      json.RawMessage `json:"value"`
-			Version    int             `json:"version"`
-			TTL        *int            `json:"ttl,omitempty"`
-			Metadata   json.RawMessage `json:"metadata,omitempty"`
-			CreatedAt  time.Time       `json:"created_at"`
-			UpdatedAt  time.Time       `json:"updated_at"`
-		}
-
-		if err := decoder.Decode(&entry); err == io.EOF {
+		entry, err := ar.Next()
+		if err == io.EOF {
 			break
 		} else if err != nil {
-			return fmt.Errorf("failed to decode entry: %w", err)
+			return nil, fmt.Errorf("failed to read backup: %w", err)
 		}
 
 		// Use provided instanceID instead of the one in backup
-		_, err := tx.Exec(ctx, insertQuery, instanceID, entry.Key, entry.Value,
-			entry.Version, entry.TTL, entry.Metadata, entry.CreatedAt, entry.UpdatedAt)
+		if entry.Deleted {
+			_, err = tx.Exec(ctx, `DELETE FROM cache_entries WHERE instance_id = $1 AND key = $2`,
+				instanceID, entry.Key)
+		} else {
+			stored := database.SplitValue(entry.Data())
+			_, err = tx.Exec(ctx, insertQuery, instanceID, entry.Key, stored.JSON, stored.Bytes, stored.ContentType, stored.ContentEncoding,
+				entry.Version, entry.TTL, entry.Metadata, entry.CreatedAt, entry.UpdatedAt)
+		}
 		if err != nil {
-			return fmt.Errorf("failed to insert entry: %w", err)
+			return nil, fmt.Errorf("failed to apply entry: %w", err)
 		}
-
-		count++
 	}
 
-	// Commit transaction
-	if err := tx.Commit(ctx); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
-	}
-
-	log.Printf("Restored instance %s: %d entries", instanceID, count)
-	return nil
+	return ar.Summary(), nil
 }
@@ -0,0 +1,457 @@
+package operations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/birbparty/birb-nest/internal/instance"
+	"github.com/jackc/pgx/v5"
+)
+
+// Reaper audit actions
+const (
+	ReaperActionDeleted     = "deleted"
+	ReaperActionWouldDelete = "would_delete"
+	ReaperActionFailed      = "failed"
+)
+
+// MetaReaperOrphan marks a registry entry recreated by the reaper for an
+// instance whose entry had expired while its data remained
+const MetaReaperOrphan = "reaper_orphan"
+
+// Reaper errors
+var (
+	ErrReaperBusy        = errors.New("reaper run already in progress")
+	ErrTombstoneNotFound = errors.New("instance tombstone not found")
+)
+
+// ReaperPolicy controls which instances the reaper removes and how fast
+type ReaperPolicy struct {
+	IdleAfter      time.Duration            // idle threshold for instances without a per-type threshold
+	IdleByType     map[string]time.Duration // idle threshold by instance type ("type" metadata)
+	Protected      []string                 // instance IDs never reaped
+	DryRun         bool                     // record what would be deleted without deleting
+	MaxPerRun      int                      // deletions per run; 0 is unlimited
+	DeleteInterval time.Duration            // pause between deletions
+	RequireArchive bool                     // refuse to delete without an archive store
+}
+
+// idleThreshold returns the idle threshold for an instance type
+func (p ReaperPolicy) idleThreshold(instanceType string) time.Duration {
+	if threshold, ok := p.IdleByType[instanceType]; ok {
+		return threshold
+	}
+	return p.IdleAfter
+}
+
+// ReaperAuditEntry records one reaper decision
+type ReaperAuditEntry struct {
+	ID         int64     `json:"id"`
+	RunID      string    `json:"run_id"`
+	InstanceID string    `json:"instance_id"`
+	Action     string    `json:"action"`
+	Reason     string    `json:"reason"`
+	DryRun     bool      `json:"dry_run"`
+	ArchiveKey string    `json:"archive_key,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// InstanceTombstone records an instance removed by the reaper
+type InstanceTombstone struct {
+	InstanceID   string     `json:"instance_id"`
+	GameType     string     `json:"game_type,omitempty"`
+	InstanceType string     `json:"instance_type,omitempty"`
+	ArchiveKey   string     `json:"archive_key,omitempty"`
+	Reason       string     `json:"reason"`
+	LastActive   *time.Time `json:"last_active,omitempty"`
+	DeletedAt    time.Time  `json:"deleted_at"`
+}
+
+// ReapResult summarizes a reaper run
+type ReapResult struct {
+	RunID      string             `json:"run_id"`
+	DryRun     bool               `json:"dry_run"`
+	Candidates int                `json:"candidates"`
+	Deleted    int                `json:"deleted"`
+	Failed     int                `json:"failed"`
+	Deferred   int                `json:"deferred"` // eligible but over the per-run limit
+	Entries    []ReaperAuditEntry `json:"entries"`
+}
+
+// reapCandidate is an instance eligible for removal
+type reapCandidate struct {
+	inst   *instance.Context
+	orphan bool // data without a registry entry
+	reason string
+}
+
+// Reaper periodically archives and deletes idle, non-permanent instances.
+// Instances whose registry entry expired but whose rows remain in the
+// database are reaped as orphans.
+type Reaper struct {
+	ops      *InstanceOperations
+	registry *instance.Registry
+	policy   ReaperPolicy
+	interval time.Duration
+	running  sync.Mutex
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewReaper creates a reaper that runs every interval
+func NewReaper(ops *InstanceOperations, registry *instance.Registry, interval time.Duration, policy ReaperPolicy) *Reaper {
+	return &Reaper{
+		ops:      ops,
+		registry: registry,
+		policy:   policy,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Policy returns the reaper's policy
+func (r *Reaper) Policy() ReaperPolicy {
+	return r.policy
+}
+
+// Start begins the background reaper loop
+func (r *Reaper) Start() {
+	go func() {
+		defer close(r.done)
+
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(instance.WithActor(context.Background(), "reaper"), r.interval)
+				if result, err := r.RunOnce(ctx); err != nil {
+					log.Printf("Reaper run failed: %v", err)
+				} else if result.Deleted > 0 || result.Failed > 0 {
+					log.Printf("Reaper run %s: %d deleted, %d failed, %d deferred",
+						result.RunID, result.Deleted, result.Failed, result.Deferred)
+				}
+				cancel()
+			case <-r.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop halts the reaper loop and waits for an in-progress run to finish
+func (r *Reaper) Stop() {
+	close(r.stop)
+	<-r.done
+}
+
+// RunOnce runs the reaper with the configured policy
+func (r *Reaper) RunOnce(ctx context.Context) (*ReapResult, error) {
+	return r.Run(ctx, r.policy.DryRun)
+}
+
+// Run archives and deletes every eligible instance, up to the per-run limit.
+// In dry-run mode nothing is changed; decisions are still audited.
+func (r *Reaper) Run(ctx context.Context, dryRun bool) (*ReapResult, error) {
+	if !r.running.TryLock() {
+		return nil, ErrReaperBusy
+	}
+	defer r.running.Unlock()
+
+	if r.policy.RequireArchive && r.ops.archives == nil && !dryRun {
+		return nil, fmt.Errorf("reaper requires an archive store: %w", ErrArchivingDisabled)
+	}
+
+	now := time.Now()
+	runID, err := generateDerivedID(now.UTC().Format("20060102T150405"), "reap")
+	if err != nil {
+		return nil, err
+	}
+
+	candidates, err := r.candidates(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ReapResult{RunID: runID, DryRun: dryRun, Candidates: len(candidates), Entries: []ReaperAuditEntry{}}
+	for i, candidate := range candidates {
+		if r.policy.MaxPerRun > 0 && result.Deleted+result.Failed >= r.policy.MaxPerRun {
+			result.Deferred = len(candidates) - i
+			break
+		}
+
+		entry := ReaperAuditEntry{
+			RunID:      runID,
+			InstanceID: candidate.inst.InstanceID,
+			Reason:     candidate.reason,
+			DryRun:     dryRun,
+		}
+
+		if dryRun {
+			entry.Action = ReaperActionWouldDelete
+		} else {
+			if result.Deleted+result.Failed > 0 && r.policy.DeleteInterval > 0 {
+				select {
+				case <-time.After(r.policy.DeleteInterval):
+				case <-ctx.Done():
+					result.Deferred = len(candidates) - i
+					return result, nil
+				}
+			}
+
+			if entry.ArchiveKey, err = r.reap(ctx, candidate); err != nil {
+				entry.Action = ReaperActionFailed
+				entry.Error = err.Error()
+				result.Failed++
+				log.Printf("Warning: Reaper failed to remove instance %s: %v", candidate.inst.InstanceID, err)
+			} else {
+				entry.Action = ReaperActionDeleted
+				result.Deleted++
+			}
+		}
+
+		if err := r.ops.recordReaperAudit(ctx, &entry); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		result.Entries = append(result.Entries, entry)
+	}
+
+	return result, nil
+}
+
+// candidates lists idle registry instances followed by orphaned instance data
+func (r *Reaper) candidates(ctx context.Context, now time.Time) ([]reapCandidate, error) {
+	protected := make(map[string]bool, len(r.policy.Protected))
+	for _, id := range r.policy.Protected {
+		protected[id] = true
+	}
+
+	instances, err := r.registry.List(ctx, instance.ListFilter{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list instances: %w", err)
+	}
+
+	var candidates []reapCandidate
+	registered := make(map[string]bool, len(instances))
+	for _, inst := range instances {
+		registered[inst.InstanceID] = true
+		if reason, ok := r.eligible(inst, protected, now); ok {
+			candidates = append(candidates, reapCandidate{inst: inst, reason: reason})
+		}
+	}
+
+	if r.policy.IdleAfter <= 0 {
+		return candidates, nil
+	}
+	orphans, err := r.ops.idleOrphans(ctx, now.Add(-r.policy.IdleAfter))
+	if err != nil {
+		return nil, err
+	}
+	for _, orphan := range orphans {
+		if registered[orphan.InstanceID] || protected[orphan.InstanceID] {
+			continue
+		}
+		// List skips entries it cannot read, so confirm the entry is really gone
+		if _, err := r.registry.Get(ctx, orphan.InstanceID); !errors.Is(err, instance.ErrInstanceNotFound) {
+			continue
+		}
+		candidates = append(candidates, reapCandidate{
+			inst:   orphan,
+			orphan: true,
+			reason: fmt.Sprintf("orphaned data, last written %s ago", now.Sub(orphan.LastActive).Round(time.Second)),
+		})
+	}
+
+	return candidates, nil
+}
+
+// eligible reports whether a registered instance should be reaped and why
+func (r *Reaper) eligible(inst *instance.Context, protected map[string]bool, now time.Time) (string, bool) {
+	if protected[inst.InstanceID] || !inst.CanBeAutoDeleted() {
+		return "", false
+	}
+	idle := now.Sub(inst.LastActive)
+	threshold := r.policy.idleThreshold(inst.Type())
+	if threshold <= 0 || idle < threshold {
+		return "", false
+	}
+	return fmt.Sprintf("idle for %s (threshold %s)", idle.Round(time.Second), threshold), true
+}
+
+// reap archives and deletes one instance and records its tombstone
+func (r *Reaper) reap(ctx context.Context, candidate reapCandidate) (archiveKey string, err error) {
+	inst := candidate.inst
+	lastActive := inst.LastActive
+
+	if candidate.orphan {
+		// DeleteInstance works on registry entries, so recreate the expired one
+		// and drop it again if the instance cannot be removed
+		if err := r.registry.Register(ctx, inst.Clone()); err != nil {
+			return "", fmt.Errorf("failed to register orphaned instance: %w", err)
+		}
+		defer func() {
+			if err != nil {
+				if delErr := r.registry.Delete(ctx, inst.InstanceID); delErr != nil {
+					log.Printf("Warning: Failed to remove registry entry for orphan %s: %v", inst.InstanceID, delErr)
+				}
+			}
+		}()
+	} else {
+		// The instance may have been used since it was listed
+		current, err := r.registry.Get(ctx, inst.InstanceID)
+		if err != nil {
+			return "", fmt.Errorf("failed to recheck instance: %w", err)
+		}
+		if _, ok := r.eligible(current, nil, time.Now()); !ok {
+			return "", fmt.Errorf("instance %s is no longer eligible", inst.InstanceID)
+		}
+		inst = current
+	}
+
+	if r.ops.archives != nil {
+		info, err := r.ops.ArchiveInstance(ctx, inst.InstanceID)
+		if err != nil {
+			return "", fmt.Errorf("failed to archive instance: %w", err)
+		}
+		archiveKey = info.Key
+	}
+
+	if err := r.ops.deleteInstance(ctx, inst.InstanceID, false, "reaped: "+candidate.reason); err != nil {
+		return archiveKey, err
+	}
+
+	tombstone := &InstanceTombstone{
+		InstanceID:   inst.InstanceID,
+		GameType:     inst.GameType,
+		InstanceType: inst.Type(),
+		ArchiveKey:   archiveKey,
+		Reason:       candidate.reason,
+		LastActive:   &lastActive,
+	}
+	if err := r.ops.recordInstanceTombstone(ctx, tombstone); err != nil {
+		return archiveKey, err
+	}
+
+	log.Printf("Reaped instance %s (%s)", inst.InstanceID, candidate.reason)
+	return archiveKey, nil
+}
+
+// idleOrphans returns contexts for instances whose newest row was written
+// before cutoff. Template data is never included.
+func (o *InstanceOperations) idleOrphans(ctx context.Context, cutoff time.Time) ([]*instance.Context, error) {
+	rows, err := o.db.Query(ctx, `
+        SELECT instance_id, MIN(first_write), MAX(last_write)
+        FROM (
+            SELECT instance_id, created_at AS first_write, updated_at AS last_write FROM cache_entries
+            UNION ALL
+            SELECT instance_id, created_at, created_at FROM instance_bases
+        ) writes
+        WHERE left(instance_id, length($2)) <> $2
+        GROUP BY instance_id
+        HAVING MAX(last_write) < $1
+        ORDER BY MAX(last_write)
+    `, cutoff, instance.TemplateIDPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find orphaned instances: %w", err)
+	}
+	defer rows.Close()
+
+	var orphans []*instance.Context
+	for rows.Next() {
+		inst := instance.NewContext("")
+		if err := rows.Scan(&inst.InstanceID, &inst.CreatedAt, &inst.LastActive); err != nil {
+			return nil, fmt.Errorf("failed to scan orphaned instance: %w", err)
+		}
+		inst.Status = instance.StatusInactive
+		inst.Metadata[MetaReaperOrphan] = "true"
+		orphans = append(orphans, inst)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to find orphaned instances: %w", err)
+	}
+	return orphans, nil
+}
+
+// recordReaperAudit appends an entry to the reaper audit trail
+func (o *InstanceOperations) recordReaperAudit(ctx context.Context, entry *ReaperAuditEntry) error {
+	if err := o.db.QueryRow(ctx, `
+        INSERT INTO instance_reaper_audit (run_id, instance_id, action, reason, dry_run, archive_key, error_message)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        RETURNING id, created_at
+    `, entry.RunID, entry.InstanceID, entry.Action, entry.Reason, entry.DryRun, entry.ArchiveKey, entry.Error,
+	).Scan(&entry.ID, &entry.CreatedAt); err != nil {
+		return fmt.Errorf("failed to record reaper audit entry: %w", err)
+	}
+	return nil
+}
+
+// ListReaperAudit returns the newest audit entries, optionally for one instance
+func (o *InstanceOperations) ListReaperAudit(ctx context.Context, instanceID string, limit int) ([]ReaperAuditEntry, error) {
+	rows, err := o.db.Query(ctx, `
+        SELECT id, run_id, instance_id, action, reason, dry_run, archive_key, error_message, created_at
+        FROM instance_reaper_audit
+        WHERE $1 = '' OR instance_id = $1
+        ORDER BY id DESC
+        LIMIT $2
+    `, instanceID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reaper audit: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []ReaperAuditEntry{}
+	for rows.Next() {
+		var entry ReaperAuditEntry
+		if err := rows.Scan(&entry.ID, &entry.RunID, &entry.InstanceID, &entry.Action, &entry.Reason,
+			&entry.DryRun, &entry.ArchiveKey, &entry.Error, &entry.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan reaper audit entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list reaper audit: %w", err)
+	}
+	return entries, nil
+}
+
+// recordInstanceTombstone records that an instance was removed
+func (o *InstanceOperations) recordInstanceTombstone(ctx context.Context, t *InstanceTombstone) error {
+	if err := o.db.QueryRow(ctx, `
+        INSERT INTO instance_tombstones (instance_id, game_type, instance_type, archive_key, reason, last_active)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        ON CONFLICT (instance_id) DO UPDATE SET
+            game_type = EXCLUDED.game_type,
+            instance_type = EXCLUDED.instance_type,
+            archive_key = EXCLUDED.archive_key,
+            reason = EXCLUDED.reason,
+            last_active = EXCLUDED.last_active,
+            deleted_at = CURRENT_TIMESTAMP
+        RETURNING deleted_at
+    `, t.InstanceID, t.GameType, t.InstanceType, t.ArchiveKey, t.Reason, t.LastActive).Scan(&t.DeletedAt); err != nil {
+		return fmt.Errorf("failed to record instance tombstone: %w", err)
+	}
+	return nil
+}
+
+// GetInstanceTombstone returns the tombstone of a reaped instance
+func (o *InstanceOperations) GetInstanceTombstone(ctx context.Context, instanceID string) (*InstanceTombstone, error) {
+	var t InstanceTombstone
+	err := o.db.QueryRow(ctx, `
+        SELECT instance_id, game_type, instance_type, archive_key, reason, last_active, deleted_at
+        FROM instance_tombstones WHERE instance_id = $1
+    `, instanceID).Scan(&t.InstanceID, &t.GameType, &t.InstanceType, &t.ArchiveKey, &t.Reason, &t.LastActive, &t.DeletedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrTombstoneNotFound, instanceID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get instance tombstone: %w", err)
+	}
+	return &t, nil
+}
@@ -0,0 +1,51 @@
+package operations
+
+import (
+	"testing"
+	"time"
+
+	"github.com/birbparty/birb-nest/internal/instance"
+)
+
+func TestReaper_Eligible(t *testing.T) {
+	now := time.Now()
+	reaper := NewReaper(nil, nil, time.Minute, ReaperPolicy{
+		IdleAfter:  24 * time.Hour,
+		IdleByType: map[string]time.Duration{instance.InstanceTypeTemporary: 30 * time.Minute},
+	})
+	protected := map[string]bool{"global": true}
+
+	tests := []struct {
+		name      string
+		id        string
+		instType  string
+		idle      time.Duration
+		status    instance.InstanceStatus
+		permanent bool
+		want      bool
+	}{
+		{name: "temporary past its type threshold", id: "t1", instType: instance.InstanceTypeTemporary, idle: time.Hour, status: instance.StatusActive, want: true},
+		{name: "dungeon under the default threshold", id: "d1", instType: instance.InstanceTypeDungeon, idle: time.Hour, status: instance.StatusActive, want: false},
+		{name: "dungeon past the default threshold", id: "d2", instType: instance.InstanceTypeDungeon, idle: 25 * time.Hour, status: instance.StatusInactive, want: true},
+		{name: "permanent", id: "p1", idle: 48 * time.Hour, status: instance.StatusActive, permanent: true, want: false},
+		{name: "protected", id: "global", idle: 48 * time.Hour, status: instance.StatusActive, want: false},
+		{name: "being deleted", id: "x1", idle: 48 * time.Hour, status: instance.StatusDeleting, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inst := instance.NewContext(tt.id)
+			inst.CreatedAt = now.Add(-72 * time.Hour)
+			inst.LastActive = now.Add(-tt.idle)
+			inst.Status = tt.status
+			inst.IsPermanent = tt.permanent
+			if tt.instType != "" {
+				inst.Metadata["type"] = tt.instType
+			}
+
+			if _, got := reaper.eligible(inst, protected, now); got != tt.want {
+				t.Errorf("eligible() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
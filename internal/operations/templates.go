@@ -0,0 +1,349 @@
+package operations
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/birbparty/birb-nest/internal/instance"
+	"github.com/jackc/pgx/v5"
+)
+
+// MetaTemplate records the template an instance was created from
+const MetaTemplate = "template"
+
+// Template errors
+var (
+	ErrTemplateNotFound    = errors.New("template not found")
+	ErrTemplateExists      = errors.New("template already exists")
+	ErrTemplateInUse       = errors.New("template is in use by instances")
+	ErrInvalidTemplateName = errors.New("invalid template name")
+)
+
+// templateNamePattern restricts template names to URL- and key-safe characters
+var templateNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,127}$`)
+
+// Template is a frozen snapshot of an instance's data together with the
+// defaults given to instances created from it
+type Template struct {
+	Name             string                  `json:"name"`
+	SourceInstanceID string                  `json:"source_instance_id"`
+	GameType         string                  `json:"game_type,omitempty"`
+	ResourceQuota    *instance.ResourceQuota `json:"resource_quota,omitempty"`
+	Metadata         map[string]string       `json:"metadata"`
+	KeyCount         int64                   `json:"key_count"`
+	CreatedAt        time.Time               `json:"created_at"`
+}
+
+// TemplateOptions overrides the defaults a template takes from its source instance
+type TemplateOptions struct {
+	GameType      string
+	ResourceQuota *instance.ResourceQuota
+	Metadata      map[string]string
+}
+
+// FromTemplateOptions controls CreateFromTemplate
+type FromTemplateOptions struct {
+	InstanceID string            // empty generates an ID derived from the template name
+	Metadata   map[string]string // merged over the template's metadata
+}
+
+// TemplateDataID returns the reserved instance ID holding a template's data
+func TemplateDataID(name string) string {
+	return instance.TemplateIDPrefix + name
+}
+
+// CreateTemplate freezes the current data of the source instance as a named
+// template. Keys the source itself reads from a template are included, and
+// TTLs are dropped so template data never expires.
+func (o *InstanceOperations) CreateTemplate(ctx context.Context, name, sourceID string, opts TemplateOptions) (*Template, error) {
+	if !templateNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidTemplateName, name)
+	}
+
+	source, err := o.registry.Get(ctx, sourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl := &Template{
+		Name:             name,
+		SourceInstanceID: sourceID,
+		GameType:         source.GameType,
+		ResourceQuota:    source.ResourceQuota,
+		Metadata:         make(map[string]string),
+	}
+	if opts.GameType != "" {
+		tmpl.GameType = opts.GameType
+	}
+	if opts.ResourceQuota != nil {
+		tmpl.ResourceQuota = opts.ResourceQuota
+	}
+	for k, v := range opts.Metadata {
+		tmpl.Metadata[k] = v
+	}
+	// Instances created from the template read values sealed with the data
+	// keys of the source and of the instances its data was copied from
+	tmpl.Metadata[instance.MetaDataFrom] = strings.Join(append([]string{sourceID}, source.DataFrom()...), ",")
+
+	quota, err := json.Marshal(tmpl.ResourceQuota)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode resource quota: %w", err)
+	}
+	metadata, err := json.Marshal(tmpl.Metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode metadata: %w", err)
+	}
+
+	tx, err := o.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := tx.Exec(ctx, `
+        INSERT INTO instance_templates (name, source_instance_id, game_type, resource_quota, metadata)
+        VALUES ($1, $2, $3, $4::jsonb, $5::jsonb)
+        ON CONFLICT (name) DO NOTHING
+    `, name, sourceID, tmpl.GameType, string(quota), string(metadata))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create template: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrTemplateExists, name)
+	}
+
+	// Snapshot the source's effective keys: its own rows plus visible base rows
+	result, err = tx.Exec(ctx, `
+        INSERT INTO cache_entries (instance_id, key, value, value_bytes, content_type, content_encoding, version, ttl, metadata, created_at, updated_at)
+        SELECT $2, key, value, value_bytes, content_type, content_encoding, version, NULL, metadata, created_at, updated_at
+        FROM cache_entries
+        WHERE instance_id = $1
+        AND (ttl IS NULL OR updated_at + interval '1 second' * ttl > CURRENT_TIMESTAMP)
+        UNION ALL
+        SELECT $2, e.key, e.value, e.value_bytes, e.content_type, e.content_encoding, e.version, NULL, e.metadata, e.created_at, e.updated_at
+        FROM instance_bases b
+        JOIN cache_entries e ON e.instance_id = b.base_instance_id
+        WHERE b.instance_id = $1
+        AND NOT EXISTS (SELECT 1 FROM cache_entries own WHERE own.instance_id = $1 AND own.key = e.key)
+        AND NOT EXISTS (SELECT 1 FROM instance_whiteouts w WHERE w.instance_id = $1 AND w.key = e.key)
+    `, sourceID, TemplateDataID(name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to snapshot template data: %w", err)
+	}
+	tmpl.KeyCount = result.RowsAffected()
+
+	if err := tx.QueryRow(ctx, `
+        UPDATE instance_templates SET key_count = $2 WHERE name = $1
+        RETURNING created_at
+    `, name, tmpl.KeyCount).Scan(&tmpl.CreatedAt); err != nil {
+		return nil, fmt.Errorf("failed to update template: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit template: %w", err)
+	}
+
+	log.Printf("Created template %s from instance %s: %d keys", name, sourceID, tmpl.KeyCount)
+	return tmpl, nil
+}
+
+// GetTemplate returns a template by name
+func (o *InstanceOperations) GetTemplate(ctx context.Context, name string) (*Template, error) {
+	tmpl, err := scanTemplate(o.db.QueryRow(ctx, `
+        SELECT name, source_instance_id, game_type, resource_quota, metadata, key_count, created_at
+        FROM instance_templates WHERE name = $1
+    `, name))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+	}
+	return tmpl, err
+}
+
+// ListTemplates returns all templates ordered by name
+func (o *InstanceOperations) ListTemplates(ctx context.Context) ([]*Template, error) {
+	rows, err := o.db.Query(ctx, `
+        SELECT name, source_instance_id, game_type, resource_quota, metadata, key_count, created_at
+        FROM instance_templates ORDER BY name
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list templates: %w", err)
+	}
+	defer rows.Close()
+
+	templates := []*Template{}
+	for rows.Next() {
+		tmpl, err := scanTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, tmpl)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list templates: %w", err)
+	}
+	return templates, nil
+}
+
+// DeleteTemplate removes a template and its data. Templates that instances
+// still read from cannot be deleted.
+func (o *InstanceOperations) DeleteTemplate(ctx context.Context, name string) error {
+	tx, err := o.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock the template so no instance binds to it while it is removed
+	var inUse bool
+	err = tx.QueryRow(ctx, `
+        SELECT EXISTS (SELECT 1 FROM instance_bases WHERE base_instance_id = $2)
+        FROM instance_templates WHERE name = $1
+        FOR UPDATE
+    `, name, TemplateDataID(name)).Scan(&inUse)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check template usage: %w", err)
+	}
+	if inUse {
+		return fmt.Errorf("%w: %s", ErrTemplateInUse, name)
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM cache_entries WHERE instance_id = $1`, TemplateDataID(name)); err != nil {
+		return fmt.Errorf("failed to delete template data: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM cache_tombstones WHERE instance_id = $1`, TemplateDataID(name)); err != nil {
+		return fmt.Errorf("failed to delete template tombstones: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM instance_templates WHERE name = $1`, name); err != nil {
+		return fmt.Errorf("failed to delete template: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit template deletion: %w", err)
+	}
+
+	log.Printf("Deleted template %s", name)
+	return nil
+}
+
+// CreateFromTemplate creates a new instance seeded from a template. No data
+// is copied: the instance reads template keys through its base binding until
+// it writes or deletes them.
+func (o *InstanceOperations) CreateFromTemplate(ctx context.Context, name string, opts FromTemplateOptions) (*instance.Context, error) {
+	tmpl, err := o.GetTemplate(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	instanceID := opts.InstanceID
+	if instanceID == "" {
+		if instanceID, err = generateDerivedID(name, "instance"); err != nil {
+			return nil, err
+		}
+	}
+	if err := o.checkNewInstance(ctx, instanceID); err != nil {
+		return nil, err
+	}
+
+	inst := instance.NewContext(instanceID)
+	inst.GameType = tmpl.GameType
+	if tmpl.ResourceQuota != nil {
+		quota := *tmpl.ResourceQuota
+		inst.ResourceQuota = &quota
+	}
+	for k, v := range tmpl.Metadata {
+		inst.Metadata[k] = v
+	}
+	for k, v := range opts.Metadata {
+		inst.Metadata[k] = v
+	}
+	delete(inst.Metadata, instance.MetaDataFrom)
+	if sources := tmpl.Metadata[instance.MetaDataFrom]; sources != "" {
+		inst.AddDataFrom(strings.Split(sources, ",")...)
+	}
+	inst.Metadata[MetaTemplate] = name
+
+	if err := o.registry.Create(ctx, inst); err != nil {
+		return nil, fmt.Errorf("failed to register instance: %w", err)
+	}
+
+	if err := o.bindTemplate(ctx, instanceID, name); err != nil {
+		if delErr := o.registry.Delete(ctx, instanceID); delErr != nil {
+			log.Printf("Warning: Failed to remove registry entry for instance %s: %v", instanceID, delErr)
+		}
+		return nil, err
+	}
+
+	log.Printf("Created instance %s from template %s", instanceID, name)
+	return o.registry.Get(ctx, instanceID)
+}
+
+// bindTemplate makes an instance read through to a template's data
+func (o *InstanceOperations) bindTemplate(ctx context.Context, instanceID, name string) error {
+	tx, err := o.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Share-lock the template so a concurrent DeleteTemplate waits for the binding
+	var locked string
+	err = tx.QueryRow(ctx, `
+        SELECT name FROM instance_templates WHERE name = $1 FOR SHARE
+    `, name).Scan(&locked)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock template: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `
+        INSERT INTO instance_bases (instance_id, base_instance_id) VALUES ($1, $2)
+    `, instanceID, TemplateDataID(name)); err != nil {
+		return fmt.Errorf("failed to bind template: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit template binding: %w", err)
+	}
+	return nil
+}
+
+// scanTemplate reads a template row; JSON columns are decoded here so a NULL
+// quota maps to nil
+func scanTemplate(row pgx.Row) (*Template, error) {
+	var (
+		tmpl     Template
+		quota    []byte
+		metadata []byte
+	)
+	if err := row.Scan(&tmpl.Name, &tmpl.SourceInstanceID, &tmpl.GameType, &quota, &metadata,
+		&tmpl.KeyCount, &tmpl.CreatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to read template: %w", err)
+	}
+
+	if len(quota) > 0 && string(quota) != "null" {
+		if err := json.Unmarshal(quota, &tmpl.ResourceQuota); err != nil {
+			return nil, fmt.Errorf("failed to decode template quota: %w", err)
+		}
+	}
+	tmpl.Metadata = make(map[string]string)
+	if len(metadata) > 0 {
+		if err := json.Unmarshal(metadata, &tmpl.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to decode template metadata: %w", err)
+		}
+	}
+	return &tmpl, nil
+}
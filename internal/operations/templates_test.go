@@ -0,0 +1,50 @@
+package operations
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/birbparty/birb-nest/internal/instance"
+)
+
+func TestTemplateNamePattern(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{"crypt", true},
+		{"dungeon-v2.1_hard", true},
+		{"", false},
+		{"-leading-dash", false},
+		{"has space", false},
+		{"slash/name", false},
+		{strings.Repeat("a", 128), true},
+		{strings.Repeat("a", 129), false},
+	}
+
+	for _, tt := range tests {
+		if got := templateNamePattern.MatchString(tt.name); got != tt.valid {
+			t.Errorf("templateNamePattern.MatchString(%q) = %v, want %v", tt.name, got, tt.valid)
+		}
+	}
+}
+
+func TestTemplateDataID(t *testing.T) {
+	id := TemplateDataID("crypt")
+	if !instance.IsReservedID(id) {
+		t.Errorf("TemplateDataID() = %q, want a reserved instance ID", id)
+	}
+	if err := instance.NewContext(id).Validate(); err != instance.ErrReservedInstanceID {
+		t.Errorf("Validate() error = %v, want ErrReservedInstanceID", err)
+	}
+}
+
+func TestGenerateDerivedID(t *testing.T) {
+	id, err := generateDerivedID("crypt", "instance")
+	if err != nil {
+		t.Fatalf("generateDerivedID() error = %v", err)
+	}
+	if !strings.HasPrefix(id, "crypt-instance-") || len(id) != len("crypt-instance-")+8 {
+		t.Errorf("generateDerivedID() = %q", id)
+	}
+}
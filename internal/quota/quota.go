@@ -0,0 +1,302 @@
+// Package quota enforces per-instance resource quotas
+package quota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/birbparty/birb-nest/internal/cache"
+	"github.com/birbparty/birb-nest/internal/instance"
+)
+
+// Resources measured against an instance's quota
+const (
+	ResourceKeys         = "keys"
+	ResourceCacheBytes   = "cache_bytes"
+	ResourceStorageBytes = "storage_bytes"
+	ResourceConcurrent   = "concurrent_requests"
+)
+
+// Defaults for the enforcer
+const (
+	DefaultSoftLimitPercent = 80
+	DefaultStorageStatsTTL  = 30 * time.Second
+)
+
+var (
+	// ErrQuotaExceeded is returned when a write would exceed a size or key limit
+	ErrQuotaExceeded = errors.New("resource quota exceeded")
+
+	// ErrTooManyRequests is returned when an instance has too many requests in flight
+	ErrTooManyRequests = errors.New("too many concurrent requests")
+)
+
+// LimitError reports which limit a request would exceed
+type LimitError struct {
+	Resource string
+	Used     int64
+	Limit    int64
+	err      error
+}
+
+// Error implements the error interface
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%v: %s %d of %d", e.err, e.Resource, e.Used, e.Limit)
+}
+
+// Unwrap returns ErrQuotaExceeded or ErrTooManyRequests
+func (e *LimitError) Unwrap() error {
+	return e.err
+}
+
+// StorageStatsFunc measures an instance's database usage
+type StorageStatsFunc func(ctx context.Context, instanceID string) (*instance.MonitoringStats, error)
+
+// Limits is a quota converted to the units usage is measured in. Zero means unlimited.
+type Limits struct {
+	Keys         int64 `json:"keys"`
+	CacheBytes   int64 `json:"cache_bytes"`
+	StorageBytes int64 `json:"storage_bytes"`
+	Concurrent   int64 `json:"concurrent_requests"`
+}
+
+// LimitsOf converts a resource quota to limits; a nil quota is unlimited
+func LimitsOf(q *instance.ResourceQuota) Limits {
+	if q == nil {
+		return Limits{}
+	}
+	return Limits{
+		Keys:         q.MaxKeys,
+		CacheBytes:   q.MaxMemoryMB << 20,
+		StorageBytes: q.MaxStorageGB << 30,
+		Concurrent:   int64(q.MaxConcurrent),
+	}
+}
+
+// Usage is an instance's resource consumption
+type Usage struct {
+	CacheKeys    int64 `json:"cache_keys"`
+	CacheBytes   int64 `json:"cache_bytes"`
+	StorageKeys  int64 `json:"storage_keys"`
+	StorageBytes int64 `json:"storage_bytes"`
+	InFlight     int64 `json:"in_flight"`
+
+	// StorageMeasuredAt is when the database was last measured; zero if it never was
+	StorageMeasuredAt time.Time `json:"storage_measured_at,omitempty"`
+}
+
+// Keys returns the larger of the cached and stored key counts
+func (u *Usage) Keys() int64 {
+	if u.StorageKeys > u.CacheKeys {
+		return u.StorageKeys
+	}
+	return u.CacheKeys
+}
+
+// Warning reports a resource past the soft limit
+type Warning struct {
+	Resource string  `json:"resource"`
+	Used     int64   `json:"used"`
+	Limit    int64   `json:"limit"`
+	Percent  float64 `json:"percent"`
+}
+
+// String formats the warning for the X-Quota-Warning header
+func (w Warning) String() string {
+	return fmt.Sprintf("%s %.0f%% of limit", w.Resource, w.Percent)
+}
+
+// storageSample is a cached database measurement
+type storageSample struct {
+	rows  int64
+	bytes int64
+	at    time.Time
+}
+
+// Enforcer checks requests against instance quotas. Cache usage is read from
+// the counters the cache keeps per instance; database usage is measured with
+// GetInstanceStats and reused for the storage stats TTL. In-flight requests
+// are counted per node.
+type Enforcer struct {
+	cache            cache.Cache
+	contextCache     *cache.ContextCache
+	storageStats     StorageStatsFunc // nil when there is no database (replicas)
+	storageTTL       time.Duration
+	softLimitPercent int
+
+	mu        sync.Mutex
+	inFlight  map[string]int64
+	storage   map[string]*storageSample
+	measuring map[string]bool // instances whose database usage is being measured
+}
+
+// NewEnforcer creates an enforcer that warns once usage passes
+// softLimitPercent of a limit (0 disables warnings)
+func NewEnforcer(cacheClient cache.Cache, softLimitPercent int) *Enforcer {
+	return &Enforcer{
+		cache:            cacheClient,
+		contextCache:     cache.NewContextCache(cacheClient),
+		storageTTL:       DefaultStorageStatsTTL,
+		softLimitPercent: softLimitPercent,
+		inFlight:         make(map[string]int64),
+		storage:          make(map[string]*storageSample),
+		measuring:        make(map[string]bool),
+	}
+}
+
+// SetStorageStats enables database limits, measuring usage with fn at most once per ttl
+func (e *Enforcer) SetStorageStats(fn StorageStatsFunc, ttl time.Duration) {
+	e.storageStats = fn
+	if ttl > 0 {
+		e.storageTTL = ttl
+	}
+}
+
+// Acquire counts a request against the instance's concurrency limit. The
+// returned release function must be called when the request finishes.
+func (e *Enforcer) Acquire(instCtx *instance.Context) (func(), error) {
+	limit := LimitsOf(instCtx.ResourceQuota).Concurrent
+	id := instCtx.InstanceID
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if limit > 0 && e.inFlight[id] >= limit {
+		return nil, &LimitError{Resource: ResourceConcurrent, Used: e.inFlight[id], Limit: limit, err: ErrTooManyRequests}
+	}
+	e.inFlight[id]++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			e.mu.Lock()
+			defer e.mu.Unlock()
+			if e.inFlight[id]--; e.inFlight[id] <= 0 {
+				delete(e.inFlight, id)
+			}
+		})
+	}, nil
+}
+
+// CheckWrite returns a *LimitError if writing size bytes to key would exceed
+// the instance's quota. ctx must carry the instance context. Overwrites only
+// count the change in size. Usage that cannot be measured is not enforced,
+// so an accounting outage never blocks writes.
+func (e *Enforcer) CheckWrite(ctx context.Context, instCtx *instance.Context, key string, size int) (*Usage, error) {
+	usage := e.measure(ctx, instCtx.InstanceID, false)
+	limits := LimitsOf(instCtx.ResourceQuota)
+
+	keyDelta, byteDelta := int64(1), int64(size)
+	overKeys := limits.Keys > 0 && usage.Keys()+keyDelta > limits.Keys
+	overCache := limits.CacheBytes > 0 && usage.CacheBytes+byteDelta > limits.CacheBytes
+	overStorage := limits.StorageBytes > 0 && usage.StorageBytes+byteDelta > limits.StorageBytes
+	if !overKeys && !overCache && !overStorage {
+		return usage, nil
+	}
+
+	// Near a limit: an overwrite of a cached key replaces its old value
+	if old, err := e.contextCache.Get(ctx, key); err == nil {
+		keyDelta, byteDelta = 0, int64(size-len(old))
+	}
+
+	switch {
+	case limits.Keys > 0 && keyDelta > 0 && usage.Keys()+keyDelta > limits.Keys:
+		return usage, &LimitError{Resource: ResourceKeys, Used: usage.Keys(), Limit: limits.Keys, err: ErrQuotaExceeded}
+	case limits.CacheBytes > 0 && byteDelta > 0 && usage.CacheBytes+byteDelta > limits.CacheBytes:
+		return usage, &LimitError{Resource: ResourceCacheBytes, Used: usage.CacheBytes, Limit: limits.CacheBytes, err: ErrQuotaExceeded}
+	case limits.StorageBytes > 0 && byteDelta > 0 && usage.StorageBytes+byteDelta > limits.StorageBytes:
+		return usage, &LimitError{Resource: ResourceStorageBytes, Used: usage.StorageBytes, Limit: limits.StorageBytes, err: ErrQuotaExceeded}
+	}
+	return usage, nil
+}
+
+// Usage measures an instance's current usage, refreshing database stats
+func (e *Enforcer) Usage(ctx context.Context, instanceID string) *Usage {
+	return e.measure(ctx, instanceID, true)
+}
+
+// Warnings lists the resources whose usage is past the soft limit
+func (e *Enforcer) Warnings(instCtx *instance.Context, usage *Usage) []Warning {
+	if e.softLimitPercent <= 0 || usage == nil {
+		return nil
+	}
+
+	limits := LimitsOf(instCtx.ResourceQuota)
+	var warnings []Warning
+	for _, r := range []struct {
+		resource    string
+		used, limit int64
+	}{
+		{ResourceKeys, usage.Keys(), limits.Keys},
+		{ResourceCacheBytes, usage.CacheBytes, limits.CacheBytes},
+		{ResourceStorageBytes, usage.StorageBytes, limits.StorageBytes},
+		{ResourceConcurrent, usage.InFlight, limits.Concurrent},
+	} {
+		if r.limit <= 0 {
+			continue
+		}
+		percent := float64(r.used) * 100 / float64(r.limit)
+		if percent >= float64(e.softLimitPercent) {
+			warnings = append(warnings, Warning{Resource: r.resource, Used: r.used, Limit: r.limit, Percent: percent})
+		}
+	}
+	return warnings
+}
+
+// measure collects an instance's usage. Failures are logged and leave the
+// affected figures at zero.
+func (e *Enforcer) measure(ctx context.Context, instanceID string, fresh bool) *Usage {
+	usage := &Usage{}
+
+	cached, err := cache.GetInstanceUsage(ctx, e.cache, instanceID)
+	if err == nil {
+		usage.CacheKeys, usage.CacheBytes = cached.Keys, cached.Bytes
+	} else if !errors.Is(err, cache.ErrUsageUnsupported) {
+		log.Printf("Warning: Failed to read cache usage of instance %s: %v", instanceID, err)
+	}
+
+	if sample := e.storageSample(ctx, instanceID, fresh); sample != nil {
+		usage.StorageKeys, usage.StorageBytes, usage.StorageMeasuredAt = sample.rows, sample.bytes, sample.at
+	}
+
+	e.mu.Lock()
+	usage.InFlight = e.inFlight[instanceID]
+	e.mu.Unlock()
+
+	return usage
+}
+
+// storageSample returns the instance's database usage, measuring it when the
+// cached sample is older than the TTL. While one request measures, others use
+// the previous sample; a failed measurement also falls back to it.
+func (e *Enforcer) storageSample(ctx context.Context, instanceID string, fresh bool) *storageSample {
+	if e.storageStats == nil {
+		return nil
+	}
+
+	e.mu.Lock()
+	sample := e.storage[instanceID]
+	if sample != nil && (e.measuring[instanceID] || !fresh && time.Since(sample.at) < e.storageTTL) {
+		e.mu.Unlock()
+		return sample
+	}
+	e.measuring[instanceID] = true
+	e.mu.Unlock()
+
+	stats, err := e.storageStats(ctx, instanceID)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.measuring, instanceID)
+	if err != nil {
+		log.Printf("Warning: Failed to measure storage of instance %s: %v", instanceID, err)
+		return sample
+	}
+	sample = &storageSample{rows: stats.RowCount, bytes: stats.DataSizeBytes, at: time.Now()}
+	e.storage[instanceID] = sample
+	return sample
+}
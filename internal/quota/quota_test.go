@@ -0,0 +1,144 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/birbparty/birb-nest/internal/cache"
+	"github.com/birbparty/birb-nest/internal/instance"
+)
+
+func newTestEnforcer(t *testing.T) (*Enforcer, *cache.MemoryCache) {
+	t.Helper()
+	mc, err := cache.NewMemoryCache(&cache.MemoryConfig{})
+	if err != nil {
+		t.Fatalf("NewMemoryCache() error = %v", err)
+	}
+	t.Cleanup(func() { mc.Close() })
+	return NewEnforcer(mc, DefaultSoftLimitPercent), mc
+}
+
+func TestEnforcer_CheckWrite(t *testing.T) {
+	e, mc := newTestEnforcer(t)
+	inst := instance.NewContext("game-1")
+	inst.ResourceQuota.MaxKeys = 2
+	ctx := instance.InjectContext(context.Background(), inst)
+	cc := cache.NewContextCache(mc)
+
+	for _, key := range []string{"a", "b"} {
+		if _, err := e.CheckWrite(ctx, inst, key, 10); err != nil {
+			t.Fatalf("CheckWrite(%q) error = %v", key, err)
+		}
+		cc.Set(ctx, key, []byte("0123456789"), 0)
+	}
+
+	// A third key is over the limit; overwriting an existing key is not
+	_, err := e.CheckWrite(ctx, inst, "c", 10)
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || !errors.Is(err, ErrQuotaExceeded) || limitErr.Resource != ResourceKeys {
+		t.Fatalf("CheckWrite() new key error = %v, want keys LimitError", err)
+	}
+	if _, err := e.CheckWrite(ctx, inst, "a", 20); err != nil {
+		t.Errorf("CheckWrite() overwrite error = %v", err)
+	}
+
+	// Byte limits count only the growth of an overwritten value
+	inst.ResourceQuota.MaxKeys = 0
+	inst.ResourceQuota.MaxMemoryMB = 1
+	if _, err := e.CheckWrite(ctx, inst, "a", 1<<20); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("CheckWrite() oversized value error = %v, want ErrQuotaExceeded", err)
+	}
+	if _, err := e.CheckWrite(ctx, inst, "a", 1<<20-10); err != nil {
+		t.Errorf("CheckWrite() overwrite within limit error = %v", err)
+	}
+}
+
+func TestEnforcer_StorageLimit(t *testing.T) {
+	e, _ := newTestEnforcer(t)
+	calls := 0
+	e.SetStorageStats(func(ctx context.Context, instanceID string) (*instance.MonitoringStats, error) {
+		calls++
+		return &instance.MonitoringStats{InstanceID: instanceID, RowCount: 5, DataSizeBytes: 1 << 30}, nil
+	}, 0)
+
+	inst := instance.NewContext("game-1")
+	inst.ResourceQuota.MaxStorageGB = 1
+	ctx := instance.InjectContext(context.Background(), inst)
+
+	_, err := e.CheckWrite(ctx, inst, "a", 1)
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || limitErr.Resource != ResourceStorageBytes {
+		t.Fatalf("CheckWrite() error = %v, want storage_bytes LimitError", err)
+	}
+
+	// Measurements are reused until the TTL passes unless fresh usage is asked for
+	e.CheckWrite(ctx, inst, "b", 1)
+	if calls != 1 {
+		t.Errorf("storage measured %d times, want 1", calls)
+	}
+	if usage := e.Usage(ctx, "game-1"); usage.StorageKeys != 5 || calls != 2 {
+		t.Errorf("Usage() = %+v after %d measurements, want 5 storage keys after 2", usage, calls)
+	}
+}
+
+func TestEnforcer_Acquire(t *testing.T) {
+	e, _ := newTestEnforcer(t)
+	inst := instance.NewContext("game-1")
+	inst.ResourceQuota.MaxConcurrent = 1
+
+	release, err := e.Acquire(inst)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	if _, err := e.Acquire(inst); !errors.Is(err, ErrTooManyRequests) {
+		t.Errorf("Acquire() over limit error = %v, want ErrTooManyRequests", err)
+	}
+
+	release()
+	release() // releasing twice must not free a second slot
+	if _, err := e.Acquire(inst); err != nil {
+		t.Errorf("Acquire() after release error = %v", err)
+	}
+	if _, err := e.Acquire(inst); !errors.Is(err, ErrTooManyRequests) {
+		t.Errorf("Acquire() after double release error = %v, want ErrTooManyRequests", err)
+	}
+}
+
+func TestEnforcer_Warnings(t *testing.T) {
+	e, _ := newTestEnforcer(t)
+	inst := instance.NewContext("game-1")
+	inst.ResourceQuota.MaxKeys = 100
+
+	if warnings := e.Warnings(inst, &Usage{CacheKeys: 79}); len(warnings) != 0 {
+		t.Errorf("Warnings() below soft limit = %v, want none", warnings)
+	}
+	warnings := e.Warnings(inst, &Usage{CacheKeys: 10, StorageKeys: 85})
+	if len(warnings) != 1 || warnings[0].Resource != ResourceKeys || warnings[0].Used != 85 {
+		t.Fatalf("Warnings() = %v, want one keys warning at 85", warnings)
+	}
+	if got := warnings[0].String(); got != "keys 85% of limit" {
+		t.Errorf("Warning.String() = %q", got)
+	}
+}
+
+func TestRecountInstanceUsage(t *testing.T) {
+	ctx := context.Background()
+	_, mc := newTestEnforcer(t)
+
+	// Untracked writes are picked up by a recount; other instances are not
+	mc.Set(ctx, instance.NewKeyBuilder("game-1").CacheKey("a"), []byte("1234"), 0)
+	mc.Set(ctx, instance.NewKeyBuilder("game-1").CacheKey("b"), []byte("12"), 0)
+	mc.Set(ctx, instance.NewKeyBuilder("game-2").CacheKey("a"), []byte("123"), 0)
+
+	usage, err := cache.RecountInstanceUsage(ctx, mc, "game-1")
+	if err != nil {
+		t.Fatalf("RecountInstanceUsage() error = %v", err)
+	}
+	if usage.Keys != 2 || usage.Bytes != 6 {
+		t.Errorf("RecountInstanceUsage() = %+v, want 2 keys, 6 bytes", usage)
+	}
+	if got, _ := cache.GetInstanceUsage(ctx, mc, "game-1"); got.Keys != 2 || got.Bytes != 6 {
+		t.Errorf("GetInstanceUsage() = %+v, want 2 keys, 6 bytes", got)
+	}
+}
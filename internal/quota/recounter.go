@@ -0,0 +1,86 @@
+package quota
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/birbparty/birb-nest/internal/cache"
+	"github.com/birbparty/birb-nest/internal/instance"
+)
+
+// Recounter periodically recounts every instance's cache usage, correcting
+// counters that drifted through key expiry, eviction or bulk loads
+type Recounter struct {
+	cache    cache.Cache
+	registry *instance.Registry
+	interval time.Duration
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewRecounter creates a recounter that runs every interval
+func NewRecounter(cacheClient cache.Cache, registry *instance.Registry, interval time.Duration) *Recounter {
+	return &Recounter{
+		cache:    cacheClient,
+		registry: registry,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start begins the background recount loop
+func (r *Recounter) Start() {
+	go func() {
+		defer close(r.done)
+
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), r.interval)
+				if _, err := r.RunOnce(ctx); err != nil {
+					log.Printf("Quota usage recount failed: %v", err)
+				}
+				cancel()
+			case <-r.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop halts the recount loop and waits for an in-progress run to finish
+func (r *Recounter) Stop() {
+	close(r.stop)
+	<-r.done
+}
+
+// RunOnce recounts the cache usage of every registered instance and returns
+// how many were recounted
+func (r *Recounter) RunOnce(ctx context.Context) (int, error) {
+	instances, err := r.registry.List(ctx, instance.ListFilter{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list instances: %w", err)
+	}
+
+	recounted := 0
+	for _, inst := range instances {
+		if inst.Status == instance.StatusDeleting {
+			continue
+		}
+		if _, err := cache.RecountInstanceUsage(ctx, r.cache, inst.InstanceID); err != nil {
+			if err == cache.ErrUsageUnsupported {
+				return 0, err
+			}
+			log.Printf("Warning: Failed to recount cache usage of instance %s: %v", inst.InstanceID, err)
+			continue
+		}
+		recounted++
+	}
+	return recounted, nil
+}
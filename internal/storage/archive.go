@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/birbparty/birb-nest/internal/archive"
+)
+
+// Archive store backends
+const (
+	BackendNone       = "none"
+	BackendS3         = "s3"
+	BackendFilesystem = "filesystem"
+	BackendMemory     = "memory"
+)
+
+// ArchivePrefix is the root under which all instance archives are stored
+const ArchivePrefix = "instance-archives/"
+
+// Metadata keys recorded alongside every archive
+const (
+	MetaInstanceID  = "instance-id"
+	MetaArchiveTime = "archive-time"
+)
+
+// ErrArchiveNotFound is returned when an archive key does not exist
+var ErrArchiveNotFound = errors.New("archive not found")
+
+// ArchiveStore persists instance archives as opaque byte streams.
+// Implementations must stream data rather than buffer whole archives.
+type ArchiveStore interface {
+	// Put streams r into the store under key and returns the stored object's info
+	Put(ctx context.Context, key string, r io.Reader, metadata map[string]string) (*ArchiveInfo, error)
+
+	// Get opens the archive stored under key; the caller must close the reader
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+
+	// List returns archives whose key starts with prefix, sorted by key
+	List(ctx context.Context, prefix string) ([]ArchiveInfo, error)
+
+	// Delete removes the archive stored under key
+	Delete(ctx context.Context, key string) error
+}
+
+// ArchiveInfo describes a stored archive
+type ArchiveInfo struct {
+	Key          string            `json:"key"`
+	Size         int64             `json:"size"`
+	LastModified time.Time         `json:"last_modified"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+}
+
+// ArchiveConfig selects and configures an archive store backend
+type ArchiveConfig struct {
+	Backend string // none, s3, filesystem or memory
+	Path    string // root directory for the filesystem backend
+	S3      SpacesConfig
+}
+
+// NewArchiveStore creates the configured archive store.
+// It returns nil without error when archiving is disabled.
+func NewArchiveStore(cfg ArchiveConfig) (ArchiveStore, error) {
+	switch cfg.Backend {
+	case "", BackendNone:
+		return nil, nil
+	case BackendS3:
+		return NewSpacesClient(cfg.S3)
+	case BackendFilesystem:
+		return NewFilesystemStore(cfg.Path)
+	case BackendMemory:
+		return NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown archive backend: %s", cfg.Backend)
+	}
+}
+
+// InstanceArchivePrefix returns the key prefix under which an instance's archives are stored
+func InstanceArchivePrefix(instanceID string) string {
+	return ArchivePrefix + instanceID + "/"
+}
+
+// ArchiveKey builds a time-ordered archive key for an instance.
+// Keys for the same instance sort chronologically.
+func ArchiveKey(instanceID string, t time.Time) string {
+	return InstanceArchivePrefix(instanceID) + t.UTC().Format("20060102T150405.000Z") + archive.FileExtension
+}
+
+// LatestArchive returns the most recent archive for an instance
+func LatestArchive(ctx context.Context, store ArchiveStore, instanceID string) (*ArchiveInfo, error) {
+	archives, err := store.List(ctx, InstanceArchivePrefix(instanceID))
+	if err != nil {
+		return nil, err
+	}
+	if len(archives) == 0 {
+		return nil, ErrArchiveNotFound
+	}
+	latest := archives[len(archives)-1]
+	return &latest, nil
+}
+
+// validateKey rejects keys that are empty, absolute or escape the store root
+func validateKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("archive key cannot be empty")
+	}
+	if strings.HasPrefix(key, "/") || path.Clean(key) != key || strings.HasPrefix(key, "../") || key == ".." {
+		return fmt.Errorf("invalid archive key: %s", key)
+	}
+	return nil
+}
+
+// countingReader tracks how many bytes have been read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// copyMetadata returns a defensive copy of an archive metadata map
+func copyMetadata(metadata map[string]string) map[string]string {
+	if metadata == nil {
+		return nil
+	}
+	out := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,221 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+func TestArchiveStores(t *testing.T) {
+	stores := map[string]func(t *testing.T) ArchiveStore{
+		"memory": func(t *testing.T) ArchiveStore { return NewMemoryStore() },
+		"filesystem": func(t *testing.T) ArchiveStore {
+			store, err := NewFilesystemStore(t.TempDir())
+			if err != nil {
+				t.Fatalf("NewFilesystemStore() error = %v", err)
+			}
+			return store
+		},
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			t.Run("PutGetRoundTrip", func(t *testing.T) {
+				store := newStore(t)
+				ctx := context.Background()
+
+				data := strings.Repeat(`{"key":"k","value":1}`+"\n", 1000)
+				info, err := store.Put(ctx, "instance-archives/inst_1/a.jsonl", strings.NewReader(data),
+					map[string]string{MetaInstanceID: "inst_1"})
+				if err != nil {
+					t.Fatalf("Put() error = %v", err)
+				}
+				if info.Size != int64(len(data)) {
+					t.Errorf("Put() size = %d, want %d", info.Size, len(data))
+				}
+
+				rc, err := store.Get(ctx, "instance-archives/inst_1/a.jsonl")
+				if err != nil {
+					t.Fatalf("Get() error = %v", err)
+				}
+				defer rc.Close()
+				got, _ := io.ReadAll(rc)
+				if string(got) != data {
+					t.Errorf("Get() returned %d bytes, want %d", len(got), len(data))
+				}
+			})
+
+			t.Run("ListByPrefix", func(t *testing.T) {
+				store := newStore(t)
+				ctx := context.Background()
+
+				for _, key := range []string{
+					"instance-archives/inst_1/2.jsonl",
+					"instance-archives/inst_1/1.jsonl",
+					"instance-archives/inst_2/1.jsonl",
+				} {
+					if _, err := store.Put(ctx, key, strings.NewReader("x"), map[string]string{MetaInstanceID: "x"}); err != nil {
+						t.Fatalf("Put(%s) error = %v", key, err)
+					}
+				}
+
+				archives, err := store.List(ctx, InstanceArchivePrefix("inst_1"))
+				if err != nil {
+					t.Fatalf("List() error = %v", err)
+				}
+				if len(archives) != 2 {
+					t.Fatalf("List() returned %d archives, want 2", len(archives))
+				}
+				if archives[0].Key != "instance-archives/inst_1/1.jsonl" {
+					t.Errorf("List() not sorted: first key %s", archives[0].Key)
+				}
+				if archives[0].Metadata[MetaInstanceID] != "x" {
+					t.Errorf("List() metadata = %v", archives[0].Metadata)
+				}
+			})
+
+			t.Run("DeleteAndNotFound", func(t *testing.T) {
+				store := newStore(t)
+				ctx := context.Background()
+
+				if _, err := store.Get(ctx, "instance-archives/missing.jsonl"); !errors.Is(err, ErrArchiveNotFound) {
+					t.Errorf("Get() missing error = %v, want ErrArchiveNotFound", err)
+				}
+
+				_, _ = store.Put(ctx, "instance-archives/doomed.jsonl", strings.NewReader("x"), nil)
+				if err := store.Delete(ctx, "instance-archives/doomed.jsonl"); err != nil {
+					t.Fatalf("Delete() error = %v", err)
+				}
+				if err := store.Delete(ctx, "instance-archives/doomed.jsonl"); !errors.Is(err, ErrArchiveNotFound) {
+					t.Errorf("Delete() twice error = %v, want ErrArchiveNotFound", err)
+				}
+				if archives, _ := store.List(ctx, ""); len(archives) != 0 {
+					t.Errorf("List() after delete = %v", archives)
+				}
+			})
+
+			t.Run("Overwrite", func(t *testing.T) {
+				store := newStore(t)
+				ctx := context.Background()
+
+				_, _ = store.Put(ctx, "instance-archives/k.jsonl", strings.NewReader("first"), nil)
+				_, _ = store.Put(ctx, "instance-archives/k.jsonl", strings.NewReader("second"), nil)
+
+				rc, err := store.Get(ctx, "instance-archives/k.jsonl")
+				if err != nil {
+					t.Fatalf("Get() error = %v", err)
+				}
+				defer rc.Close()
+				if got, _ := io.ReadAll(rc); string(got) != "second" {
+					t.Errorf("Get() = %q, want second", got)
+				}
+			})
+
+			t.Run("InvalidKey", func(t *testing.T) {
+				store := newStore(t)
+				for _, key := range []string{"", "/abs", "../escape", "a/../../b"} {
+					if _, err := store.Put(context.Background(), key, bytes.NewReader(nil), nil); err == nil {
+						t.Errorf("Put(%q) succeeded, want error", key)
+					}
+				}
+			})
+		})
+	}
+}
+
+func TestFilesystemStore_PutFailureKeepsMetadata(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFilesystemStore(dir)
+	if err != nil {
+		t.Fatalf("NewFilesystemStore() error = %v", err)
+	}
+	ctx := context.Background()
+
+	// An archive below "a" makes the rename onto "a" fail
+	if _, err := store.Put(ctx, "a/b", strings.NewReader("x"), map[string]string{MetaInstanceID: "b"}); err != nil {
+		t.Fatalf("Put(a/b) error = %v", err)
+	}
+	if _, err := store.Put(ctx, "a", strings.NewReader("y"), map[string]string{MetaInstanceID: "a"}); err == nil {
+		t.Fatal("Put(a) over a directory succeeded, want error")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a"+metaSuffix)); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("failed Put left a metadata sidecar: %v", err)
+	}
+
+	archives, err := store.List(ctx, "")
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(archives) != 1 || archives[0].Metadata[MetaInstanceID] != "b" {
+		t.Errorf("List() = %+v, want only a/b with its metadata", archives)
+	}
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".upload-") {
+			t.Errorf("failed Put left temp file %s", entry.Name())
+		}
+	}
+}
+
+func TestLatestArchive(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+
+	if _, err := LatestArchive(ctx, store, "inst_1"); !errors.Is(err, ErrArchiveNotFound) {
+		t.Errorf("LatestArchive() empty error = %v, want ErrArchiveNotFound", err)
+	}
+
+	base := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, offset := range []time.Duration{time.Hour, 0, 2 * time.Hour} {
+		_, _ = store.Put(ctx, ArchiveKey("inst_1", base.Add(offset)), strings.NewReader("x"), nil)
+	}
+
+	latest, err := LatestArchive(ctx, store, "inst_1")
+	if err != nil {
+		t.Fatalf("LatestArchive() error = %v", err)
+	}
+	if want := ArchiveKey("inst_1", base.Add(2*time.Hour)); latest.Key != want {
+		t.Errorf("LatestArchive() = %s, want %s", latest.Key, want)
+	}
+}
+
+func TestNewArchiveStore(t *testing.T) {
+	if store, err := NewArchiveStore(ArchiveConfig{Backend: BackendNone}); err != nil || store != nil {
+		t.Errorf("NewArchiveStore(none) = %v, %v; want nil, nil", store, err)
+	}
+	if _, err := NewArchiveStore(ArchiveConfig{Backend: "tape"}); err == nil {
+		t.Error("NewArchiveStore(tape) succeeded, want error")
+	}
+	if _, err := NewArchiveStore(ArchiveConfig{Backend: BackendS3}); err == nil {
+		t.Error("NewArchiveStore(s3) without bucket succeeded, want error")
+	}
+	store, err := NewArchiveStore(ArchiveConfig{Backend: BackendFilesystem, Path: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewArchiveStore(filesystem) error = %v", err)
+	}
+	if _, ok := store.(*FilesystemStore); !ok {
+		t.Errorf("NewArchiveStore(filesystem) = %T", store)
+	}
+}
+
+func TestIsNotFound(t *testing.T) {
+	if !isNotFound(awserr.New(s3.ErrCodeNoSuchKey, "missing", nil)) {
+		t.Error("NoSuchKey should be not found")
+	}
+	if !isNotFound(awserr.New("NotFound", "missing", nil)) {
+		t.Error("HEAD NotFound should be not found")
+	}
+	if isNotFound(errors.New("boom")) {
+		t.Error("generic error should not be not found")
+	}
+}
@@ -0,0 +1,315 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/birbparty/birb-nest/internal/archive"
+)
+
+// CatalogPrefix is the root under which backup chain catalogs are stored.
+// Catalogs live outside ArchivePrefix so they never appear in archive listings.
+const CatalogPrefix = "instance-catalogs/"
+
+// Metadata keys recorded alongside chained backups
+const (
+	MetaBackupKind   = "backup-kind"
+	MetaBackupParent = "backup-parent"
+)
+
+// Errors returned while resolving backup chains
+var (
+	ErrNoRestorePoint = errors.New("no backup at or before the requested time")
+	ErrBrokenChain    = errors.New("backup chain is broken")
+)
+
+// BackupRecord describes one archive in an instance's backup chain
+type BackupRecord struct {
+	Key        string    `json:"key"`
+	Kind       string    `json:"kind"`
+	Parent     string    `json:"parent,omitempty"`
+	Since      time.Time `json:"since,omitempty"`
+	Until      time.Time `json:"until"`
+	Entries    int64     `json:"entries"`
+	Tombstones int64     `json:"tombstones,omitempty"`
+	Size       int64     `json:"size"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Catalog lists an instance's backups, oldest first, and how they chain together.
+// Each incremental backup names its parent; following parents always ends at a full backup.
+type Catalog struct {
+	InstanceID string         `json:"instance_id"`
+	Backups    []BackupRecord `json:"backups"`
+	NeedsFull  bool           `json:"needs_full,omitempty"` // set when a restore makes the chain stale
+	UpdatedAt  time.Time      `json:"updated_at"`
+}
+
+// CatalogKey returns the key under which an instance's catalog is stored
+func CatalogKey(instanceID string) string {
+	return CatalogPrefix + instanceID + ".json"
+}
+
+// LoadCatalog reads an instance's catalog, returning an empty one if none exists yet
+func LoadCatalog(ctx context.Context, store ArchiveStore, instanceID string) (*Catalog, error) {
+	rc, err := store.Get(ctx, CatalogKey(instanceID))
+	if errors.Is(err, ErrArchiveNotFound) {
+		return &Catalog{InstanceID: instanceID, Backups: []BackupRecord{}}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open backup catalog: %w", err)
+	}
+	defer rc.Close()
+
+	var catalog Catalog
+	if err := json.NewDecoder(rc).Decode(&catalog); err != nil {
+		return nil, fmt.Errorf("failed to decode backup catalog: %w", err)
+	}
+	if catalog.Backups == nil {
+		catalog.Backups = []BackupRecord{}
+	}
+	return &catalog, nil
+}
+
+// SaveCatalog writes an instance's catalog back to the store
+func SaveCatalog(ctx context.Context, store ArchiveStore, catalog *Catalog) error {
+	catalog.UpdatedAt = time.Now().UTC()
+
+	data, err := json.MarshalIndent(catalog, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode backup catalog: %w", err)
+	}
+	if _, err := store.Put(ctx, CatalogKey(catalog.InstanceID), bytes.NewReader(data), map[string]string{
+		MetaInstanceID: catalog.InstanceID,
+	}); err != nil {
+		return fmt.Errorf("failed to save backup catalog: %w", err)
+	}
+	return nil
+}
+
+// Add records a new backup; a full backup clears NeedsFull
+func (c *Catalog) Add(record BackupRecord) {
+	c.Backups = append(c.Backups, record)
+	if record.Kind == archive.KindFull {
+		c.NeedsFull = false
+	}
+}
+
+// Head returns the most recent backup, or nil if there are none
+func (c *Catalog) Head() *BackupRecord {
+	var head *BackupRecord
+	for i := range c.Backups {
+		if head == nil || supersedes(&c.Backups[i], head) {
+			head = &c.Backups[i]
+		}
+	}
+	return head
+}
+
+// Find returns the backup stored under key, or nil
+func (c *Catalog) Find(key string) *BackupRecord {
+	for i := range c.Backups {
+		if c.Backups[i].Key == key {
+			return &c.Backups[i]
+		}
+	}
+	return nil
+}
+
+// IncrementalsSinceFull counts the incremental backups layered on the latest full backup
+func (c *Catalog) IncrementalsSinceFull() int {
+	head := c.Head()
+	if head == nil {
+		return 0
+	}
+	chain, err := c.ChainTo(head.Key)
+	if err != nil {
+		return 0
+	}
+	return len(chain) - 1
+}
+
+// ChainTo returns the backups needed to restore the state captured by key, full backup first
+func (c *Catalog) ChainTo(key string) ([]BackupRecord, error) {
+	var chain []BackupRecord
+	for key != "" {
+		record := c.Find(key)
+		if record == nil {
+			return nil, fmt.Errorf("%w: %s is not in the catalog", ErrBrokenChain, key)
+		}
+		if len(chain) > len(c.Backups) {
+			return nil, fmt.Errorf("%w: cycle at %s", ErrBrokenChain, key)
+		}
+		chain = append(chain, *record)
+		if record.Kind != archive.KindIncremental {
+			break
+		}
+		if record.Parent == "" {
+			return nil, fmt.Errorf("%w: incremental %s has no parent", ErrBrokenChain, key)
+		}
+		key = record.Parent
+	}
+
+	// Reverse into replay order
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+	return chain, nil
+}
+
+// ChainAt returns the chain for the latest backup taken at or before t, full backup first
+func (c *Catalog) ChainAt(t time.Time) ([]BackupRecord, error) {
+	var best *BackupRecord
+	for i := range c.Backups {
+		record := &c.Backups[i]
+		if record.Until.After(t) {
+			continue
+		}
+		if best == nil || supersedes(record, best) {
+			best = record
+		}
+	}
+	if best == nil {
+		return nil, ErrNoRestorePoint
+	}
+	return c.ChainTo(best.Key)
+}
+
+// supersedes reports whether a is a better restore point than b.
+// A compacted full backup wins over the chain it replaced.
+func supersedes(a, b *BackupRecord) bool {
+	if a.Until.Equal(b.Until) {
+		return a.Kind == archive.KindFull && b.Kind != archive.KindFull
+	}
+	return a.Until.After(b.Until)
+}
+
+// Remove drops the given keys from the catalog
+func (c *Catalog) Remove(keys ...string) {
+	drop := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		drop[key] = true
+	}
+	kept := c.Backups[:0]
+	for _, record := range c.Backups {
+		if !drop[record.Key] {
+			kept = append(kept, record)
+		}
+	}
+	c.Backups = kept
+}
+
+// CompactChain merges the chain ending at the latest backup taken at or before
+// until into a single full backup covering the same point in time. With prune
+// set the merged archives are deleted and later incrementals are re-parented
+// onto the compacted backup; otherwise they are kept for finer-grained restores.
+func CompactChain(ctx context.Context, store ArchiveStore, instanceID string, until time.Time, prune bool) (*BackupRecord, error) {
+	catalog, err := LoadCatalog(ctx, store, instanceID)
+	if err != nil {
+		return nil, err
+	}
+	chain, err := catalog.ChainAt(until)
+	if err != nil {
+		return nil, err
+	}
+	tip := chain[len(chain)-1]
+	if len(chain) == 1 {
+		return &tip, nil // already a single full backup
+	}
+
+	// Open every archive in the chain; the merge streams through them in parallel
+	readers := make([]*archive.Reader, 0, len(chain))
+	defer func() {
+		for _, r := range readers {
+			r.Close()
+		}
+	}()
+	for _, record := range chain {
+		rc, err := store.Get(ctx, record.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %w", record.Key, err)
+		}
+		defer rc.Close()
+
+		r, err := archive.NewReader(rc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", record.Key, err)
+		}
+		readers = append(readers, r)
+	}
+
+	now := time.Now()
+	key := ArchiveKey(instanceID, now)
+	manifest := &archive.Manifest{
+		InstanceID: instanceID,
+		Until:      tip.Until,
+		SourceNode: "compaction",
+		CreatedAt:  now.UTC(),
+	}
+	if m := readers[len(readers)-1].Manifest(); m != nil {
+		manifest.Instance = m.Instance
+	}
+
+	pr, pw := io.Pipe()
+	result := make(chan *archive.Trailer, 1)
+	go func() {
+		trailer, err := archive.Merge(pw, manifest, readers...)
+		result <- trailer
+		pw.CloseWithError(err)
+	}()
+
+	info, err := store.Put(ctx, key, pr, map[string]string{
+		MetaInstanceID:  instanceID,
+		MetaArchiveTime: now.UTC().Format(time.RFC3339),
+		MetaBackupKind:  archive.KindFull,
+	})
+	pr.CloseWithError(err)
+	trailer := <-result
+	if err != nil {
+		return nil, fmt.Errorf("failed to write compacted backup: %w", err)
+	}
+
+	record := BackupRecord{
+		Key:       key,
+		Kind:      archive.KindFull,
+		Until:     tip.Until,
+		Entries:   trailer.Entries,
+		Size:      info.Size,
+		CreatedAt: now.UTC(),
+	}
+	catalog.Add(record)
+
+	if prune {
+		merged := make([]string, 0, len(chain))
+		for _, old := range chain {
+			merged = append(merged, old.Key)
+		}
+		for i := range catalog.Backups {
+			if catalog.Backups[i].Parent == tip.Key {
+				catalog.Backups[i].Parent = key
+			}
+		}
+		catalog.Remove(merged...)
+
+		// Save before deleting so a failure never leaves the catalog pointing at missing archives
+		if err := SaveCatalog(ctx, store, catalog); err != nil {
+			return nil, err
+		}
+		for _, old := range merged {
+			if err := store.Delete(ctx, old); err != nil && !errors.Is(err, ErrArchiveNotFound) {
+				return &record, fmt.Errorf("failed to delete compacted archive %s: %w", old, err)
+			}
+		}
+		return &record, nil
+	}
+
+	if err := SaveCatalog(ctx, store, catalog); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
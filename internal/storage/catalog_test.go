@@ -0,0 +1,221 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/birbparty/birb-nest/internal/archive"
+	"github.com/birbparty/birb-nest/internal/database"
+)
+
+var catalogBase = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func testCatalog() *Catalog {
+	return &Catalog{
+		InstanceID: "inst_1",
+		Backups: []BackupRecord{
+			{Key: "f1", Kind: archive.KindFull, Until: catalogBase},
+			{Key: "i1", Kind: archive.KindIncremental, Parent: "f1", Until: catalogBase.Add(time.Hour)},
+			{Key: "i2", Kind: archive.KindIncremental, Parent: "i1", Until: catalogBase.Add(2 * time.Hour)},
+			{Key: "f2", Kind: archive.KindFull, Until: catalogBase.Add(3 * time.Hour)},
+			{Key: "i3", Kind: archive.KindIncremental, Parent: "f2", Until: catalogBase.Add(4 * time.Hour)},
+		},
+	}
+}
+
+func chainKeys(chain []BackupRecord) []string {
+	keys := make([]string, len(chain))
+	for i, record := range chain {
+		keys[i] = record.Key
+	}
+	return keys
+}
+
+func TestCatalog_ChainAt(t *testing.T) {
+	tests := []struct {
+		name string
+		at   time.Time
+		want []string
+		err  error
+	}{
+		{"before first backup", catalogBase.Add(-time.Minute), nil, ErrNoRestorePoint},
+		{"exactly at full", catalogBase, []string{"f1"}, nil},
+		{"between incrementals", catalogBase.Add(90 * time.Minute), []string{"f1", "i1"}, nil},
+		{"end of first chain", catalogBase.Add(170 * time.Minute), []string{"f1", "i1", "i2"}, nil},
+		{"second chain", catalogBase.Add(10 * time.Hour), []string{"f2", "i3"}, nil},
+	}
+
+	c := testCatalog()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain, err := c.ChainAt(tt.at)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("ChainAt() error = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ChainAt() error = %v", err)
+			}
+			if got := chainKeys(chain); !equalStrings(got, tt.want) {
+				t.Errorf("ChainAt() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCatalog_BrokenChain(t *testing.T) {
+	c := testCatalog()
+	c.Remove("i1")
+
+	if _, err := c.ChainTo("i2"); !errors.Is(err, ErrBrokenChain) {
+		t.Errorf("ChainTo() error = %v, want ErrBrokenChain", err)
+	}
+	if got := c.IncrementalsSinceFull(); got != 1 {
+		t.Errorf("IncrementalsSinceFull() = %d, want 1", got)
+	}
+}
+
+func TestCatalog_SaveLoad(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	empty, err := LoadCatalog(ctx, store, "inst_1")
+	if err != nil || len(empty.Backups) != 0 {
+		t.Fatalf("LoadCatalog() on empty store = %+v, %v", empty, err)
+	}
+
+	if err := SaveCatalog(ctx, store, testCatalog()); err != nil {
+		t.Fatalf("SaveCatalog() error = %v", err)
+	}
+	loaded, err := LoadCatalog(ctx, store, "inst_1")
+	if err != nil {
+		t.Fatalf("LoadCatalog() error = %v", err)
+	}
+	if head := loaded.Head(); head == nil || head.Key != "i3" {
+		t.Errorf("Head() = %+v, want i3", head)
+	}
+
+	// Catalogs must not show up as archives
+	archives, _ := store.List(ctx, InstanceArchivePrefix("inst_1"))
+	if len(archives) != 0 {
+		t.Errorf("catalog listed as archive: %+v", archives)
+	}
+}
+
+// putArchive stores an archive built from entries and returns its catalog record
+func putArchive(t *testing.T, store ArchiveStore, record BackupRecord, entries ...*database.BackupEntry) BackupRecord {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w, err := archive.NewWriter(&buf, &archive.Manifest{
+		InstanceID: "inst_1",
+		Kind:       record.Kind,
+		Parent:     record.Parent,
+		Until:      record.Until,
+	})
+	if err != nil {
+		t.Fatalf("NewWriter() error = %v", err)
+	}
+	for _, entry := range entries {
+		if err := w.Write(entry); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if _, err := store.Put(context.Background(), record.Key, &buf, nil); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	return record
+}
+
+func TestCompactChain(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	prefix := InstanceArchivePrefix("inst_1")
+	value := func(key, v string) *database.BackupEntry {
+		return &database.BackupEntry{InstanceID: "inst_1", Key: key, Value: json.RawMessage(v)}
+	}
+
+	catalog := &Catalog{InstanceID: "inst_1"}
+	catalog.Add(putArchive(t, store, BackupRecord{Key: prefix + "1", Kind: archive.KindFull, Until: catalogBase},
+		value("a", `1`), value("b", `1`)))
+	catalog.Add(putArchive(t, store, BackupRecord{Key: prefix + "2", Kind: archive.KindIncremental, Parent: prefix + "1", Until: catalogBase.Add(time.Hour)},
+		&database.BackupEntry{Key: "a", Deleted: true}, value("c", `2`)))
+	catalog.Add(putArchive(t, store, BackupRecord{Key: prefix + "3", Kind: archive.KindIncremental, Parent: prefix + "2", Until: catalogBase.Add(2 * time.Hour)},
+		value("b", `3`)))
+	if err := SaveCatalog(ctx, store, catalog); err != nil {
+		t.Fatalf("SaveCatalog() error = %v", err)
+	}
+
+	// Compact the first two backups; the third must be re-parented
+	record, err := CompactChain(ctx, store, "inst_1", catalogBase.Add(time.Hour), true)
+	if err != nil {
+		t.Fatalf("CompactChain() error = %v", err)
+	}
+	if record.Kind != archive.KindFull || !record.Until.Equal(catalogBase.Add(time.Hour)) || record.Entries != 2 {
+		t.Errorf("CompactChain() = %+v", record)
+	}
+
+	rc, err := store.Get(ctx, record.Key)
+	if err != nil {
+		t.Fatalf("Get(compacted) error = %v", err)
+	}
+	defer rc.Close()
+	r, err := archive.NewReader(rc)
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+	var keys []string
+	for {
+		entry, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		keys = append(keys, entry.Key)
+	}
+	if !equalStrings(keys, []string{"b", "c"}) {
+		t.Errorf("compacted keys = %v, want [b c]", keys)
+	}
+
+	loaded, err := LoadCatalog(ctx, store, "inst_1")
+	if err != nil {
+		t.Fatalf("LoadCatalog() error = %v", err)
+	}
+	chain, err := loaded.ChainAt(catalogBase.Add(3 * time.Hour))
+	if err != nil {
+		t.Fatalf("ChainAt() after compaction error = %v", err)
+	}
+	if got := chainKeys(chain); !equalStrings(got, []string{record.Key, prefix + "3"}) {
+		t.Errorf("chain after compaction = %v", got)
+	}
+	for _, pruned := range []string{prefix + "1", prefix + "2"} {
+		if _, err := store.Get(ctx, pruned); !errors.Is(err, ErrArchiveNotFound) {
+			t.Errorf("Get(%s) after prune error = %v, want ErrArchiveNotFound", pruned, err)
+		}
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
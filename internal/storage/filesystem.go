@@ -0,0 +1,238 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// metaSuffix marks the sidecar file holding an archive's metadata
+const metaSuffix = ".meta.json"
+
+// FilesystemStore stores archives as files below a root directory
+type FilesystemStore struct {
+	root string
+}
+
+// NewFilesystemStore creates a filesystem archive store rooted at dir
+func NewFilesystemStore(dir string) (*FilesystemStore, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("archive path cannot be empty")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	return &FilesystemStore{root: dir}, nil
+}
+
+// Put streams an archive to disk, replacing any existing file atomically
+func (s *FilesystemStore) Put(ctx context.Context, key string, r io.Reader, metadata map[string]string) (*ArchiveInfo, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+	if strings.HasSuffix(key, metaSuffix) {
+		return nil, fmt.Errorf("invalid archive key: %s", key)
+	}
+
+	target := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".upload-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	size, err := io.Copy(tmp, &contextReader{ctx: ctx, r: r})
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to write archive: %w", err)
+	}
+
+	metaTmp, err := s.writeMetadata(target, metadata)
+	if err != nil {
+		return nil, err
+	}
+	if metaTmp != "" {
+		defer os.Remove(metaTmp)
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return nil, fmt.Errorf("failed to finalize archive: %w", err)
+	}
+
+	// The sidecar follows the archive, so it never describes an archive that
+	// was not written
+	if metaTmp == "" {
+		err = os.Remove(target + metaSuffix)
+		if errors.Is(err, fs.ErrNotExist) {
+			err = nil
+		}
+	} else {
+		err = os.Rename(metaTmp, target+metaSuffix)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to finalize archive metadata: %w", err)
+	}
+
+	stat, err := os.Stat(target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat archive: %w", err)
+	}
+
+	return &ArchiveInfo{
+		Key:          key,
+		Size:         size,
+		LastModified: stat.ModTime(),
+		Metadata:     copyMetadata(metadata),
+	}, nil
+}
+
+// Get opens an archive file for reading
+func (s *FilesystemStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(s.path(key))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrArchiveNotFound
+		}
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	return f, nil
+}
+
+// List walks the root directory for archives matching prefix
+func (s *FilesystemStore) List(ctx context.Context, prefix string) ([]ArchiveInfo, error) {
+	var archives []ArchiveInfo
+
+	err := filepath.WalkDir(s.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.root, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		name := d.Name()
+		if strings.HasSuffix(name, metaSuffix) || strings.HasPrefix(name, ".upload-") || !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		archives = append(archives, ArchiveInfo{
+			Key:          key,
+			Size:         info.Size(),
+			LastModified: info.ModTime(),
+			Metadata:     s.readMetadata(p),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list archives: %w", err)
+	}
+
+	sort.Slice(archives, func(i, j int) bool { return archives[i].Key < archives[j].Key })
+	return archives, nil
+}
+
+// Delete removes an archive file and its metadata sidecar
+func (s *FilesystemStore) Delete(ctx context.Context, key string) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	target := s.path(key)
+	if err := os.Remove(target); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return ErrArchiveNotFound
+		}
+		return fmt.Errorf("failed to delete archive: %w", err)
+	}
+	_ = os.Remove(target + metaSuffix)
+
+	return nil
+}
+
+// path maps an archive key to a file path below the root
+func (s *FilesystemStore) path(key string) string {
+	return filepath.Join(s.root, filepath.FromSlash(key))
+}
+
+// writeMetadata writes the metadata sidecar of target to a temp file and
+// returns its path, or "" when there is no metadata
+func (s *FilesystemStore) writeMetadata(target string, metadata map[string]string) (string, error) {
+	if len(metadata) == 0 {
+		return "", nil
+	}
+
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode archive metadata: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".upload-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to write archive metadata: %w", err)
+	}
+	return tmp.Name(), nil
+}
+
+func (s *FilesystemStore) readMetadata(target string) map[string]string {
+	data, err := os.ReadFile(target + metaSuffix)
+	if err != nil {
+		return nil
+	}
+
+	var metadata map[string]string
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return nil
+	}
+	return metadata
+}
+
+// contextReader aborts a copy once its context is cancelled
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
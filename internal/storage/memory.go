@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps archives in process memory; intended for tests and development
+type MemoryStore struct {
+	mu       sync.RWMutex
+	archives map[string]memoryArchive
+}
+
+type memoryArchive struct {
+	data []byte
+	info ArchiveInfo
+}
+
+// NewMemoryStore creates an empty in-memory archive store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		archives: make(map[string]memoryArchive),
+	}
+}
+
+// Put reads the archive into memory
+func (s *MemoryStore) Put(ctx context.Context, key string, r io.Reader, metadata map[string]string) (*ArchiveInfo, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, &contextReader{ctx: ctx, r: r}); err != nil {
+		return nil, err
+	}
+
+	info := ArchiveInfo{
+		Key:          key,
+		Size:         int64(buf.Len()),
+		LastModified: time.Now(),
+		Metadata:     copyMetadata(metadata),
+	}
+
+	s.mu.Lock()
+	s.archives[key] = memoryArchive{data: buf.Bytes(), info: info}
+	s.mu.Unlock()
+
+	result := info
+	result.Metadata = copyMetadata(info.Metadata)
+	return &result, nil
+}
+
+// Get returns a reader over a stored archive
+func (s *MemoryStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	s.mu.RLock()
+	archive, ok := s.archives[key]
+	s.mu.RUnlock()
+
+	if !ok {
+		return nil, ErrArchiveNotFound
+	}
+	return io.NopCloser(bytes.NewReader(archive.data)), nil
+}
+
+// List returns archives matching prefix sorted by key
+func (s *MemoryStore) List(ctx context.Context, prefix string) ([]ArchiveInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var archives []ArchiveInfo
+	for key, archive := range s.archives {
+		if strings.HasPrefix(key, prefix) {
+			info := archive.info
+			info.Metadata = copyMetadata(info.Metadata)
+			archives = append(archives, info)
+		}
+	}
+
+	sort.Slice(archives, func(i, j int) bool { return archives[i].Key < archives[j].Key })
+	return archives, nil
+}
+
+// Delete removes a stored archive
+func (s *MemoryStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.archives[key]; !ok {
+		return ErrArchiveNotFound
+	}
+	delete(s.archives, key)
+	return nil
+}
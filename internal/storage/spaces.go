@@ -2,115 +2,177 @@
 package storage
 
 import (
-	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"sort"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/birbparty/birb-nest/internal/archive"
 )
 
-// SpacesConfig contains configuration for Digital Ocean Spaces
+// SpacesConfig contains configuration for Digital Ocean Spaces or any S3-compatible store
 type SpacesConfig struct {
-	Endpoint  string
-	Region    string
-	Bucket    string
-	AccessKey string
-	SecretKey string
+	Endpoint       string // e.g. "nyc3.digitaloceanspaces.com"; empty for AWS S3
+	Region         string
+	Bucket         string
+	AccessKey      string
+	SecretKey      string
+	ForcePathStyle bool  // required by MinIO and most self-hosted S3 servers
+	PartSize       int64 // multipart upload part size in bytes (default 16 MiB)
+	Concurrency    int   // parts uploaded in parallel (default 4)
 }
 
-// SpacesClient provides operations for Digital Ocean Spaces
+// SpacesClient implements ArchiveStore on Digital Ocean Spaces / S3
 type SpacesClient struct {
-	client     *s3.S3
-	bucket     string
-	pathPrefix string
+	client   *s3.S3
+	uploader *s3manager.Uploader
+	bucket   string
 }
 
 // NewSpacesClient creates a new Digital Ocean Spaces client
 func NewSpacesClient(config SpacesConfig) (*SpacesClient, error) {
-	// Configure for Digital Ocean Spaces
-	sess, err := session.NewSession(&aws.Config{
-		Endpoint:    aws.String(config.Endpoint), // e.g., "nyc3.digitaloceanspaces.com"
-		Region:      aws.String(config.Region),
-		Credentials: credentials.NewStaticCredentials(config.AccessKey, config.SecretKey, ""),
-	})
+	if config.Bucket == "" {
+		return nil, fmt.Errorf("archive bucket cannot be empty")
+	}
+
+	awsConfig := &aws.Config{
+		Region:           aws.String(config.Region),
+		Credentials:      credentials.NewStaticCredentials(config.AccessKey, config.SecretKey, ""),
+		S3ForcePathStyle: aws.Bool(config.ForcePathStyle),
+	}
+	if config.Endpoint != "" {
+		awsConfig.Endpoint = aws.String(config.Endpoint)
+	}
+
+	sess, err := session.NewSession(awsConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create session: %w", err)
 	}
 
+	partSize := config.PartSize
+	if partSize < s3manager.MinUploadPartSize {
+		partSize = 16 << 20
+	}
+	concurrency := config.Concurrency
+	if concurrency <= 0 {
+		concurrency = 4
+	}
+
+	client := s3.New(sess)
 	return &SpacesClient{
-		client:     s3.New(sess),
-		bucket:     config.Bucket,
-		pathPrefix: "instance-archives/",
+		client: client,
+		uploader: s3manager.NewUploaderWithClient(client, func(u *s3manager.Uploader) {
+			u.PartSize = partSize
+			u.Concurrency = concurrency
+		}),
+		bucket: config.Bucket,
 	}, nil
 }
 
-// UploadArchive uploads an instance archive to Spaces
-func (s *SpacesClient) UploadArchive(instanceID string, data io.Reader) (string, error) {
-	// Create path with date-based directory structure
-	key := fmt.Sprintf("%s%s/%s.jsonl", s.pathPrefix, time.Now().Format("2006-01-02"), instanceID)
-
-	// Convert io.Reader to bytes first (AWS SDK requires io.ReadSeeker)
-	var buf bytes.Buffer
-	if _, err := io.Copy(&buf, data); err != nil {
-		return "", fmt.Errorf("failed to read data: %w", err)
+// Put streams an archive using a multipart upload; only PartSize × Concurrency bytes are buffered
+func (s *SpacesClient) Put(ctx context.Context, key string, r io.Reader, metadata map[string]string) (*ArchiveInfo, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
 	}
 
-	_, err := s.client.PutObject(&s3.PutObjectInput{
-		Bucket: aws.String(s.bucket),
-		Key:    aws.String(key),
-		Body:   bytes.NewReader(buf.Bytes()),
-		// Set metadata
-		Metadata: map[string]*string{
-			"instance-id":  aws.String(instanceID),
-			"archive-time": aws.String(time.Now().UTC().Format(time.RFC3339)),
-		},
-		// Set content type
-		ContentType: aws.String("application/x-jsonlines"),
+	body := &countingReader{r: r}
+	_, err := s.uploader.UploadWithContext(ctx, &s3manager.UploadInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		Body:        body,
+		Metadata:    aws.StringMap(metadata),
+		ContentType: aws.String(archive.ContentType),
 	})
 	if err != nil {
-		return "", fmt.Errorf("failed to upload archive: %w", err)
+		return nil, fmt.Errorf("failed to upload archive: %w", err)
 	}
 
-	return key, nil
+	return &ArchiveInfo{
+		Key:          key,
+		Size:         body.n,
+		LastModified: time.Now(),
+		Metadata:     copyMetadata(metadata),
+	}, nil
 }
 
-// GetArchive retrieves an instance archive from Spaces
-func (s *SpacesClient) GetArchive(archivePath string) (io.ReadCloser, error) {
-	result, err := s.client.GetObject(&s3.GetObjectInput{
+// UploadArchive uploads an instance archive under a time-ordered key
+func (s *SpacesClient) UploadArchive(instanceID string, data io.Reader) (string, error) {
+	now := time.Now()
+	info, err := s.Put(context.Background(), ArchiveKey(instanceID, now), data, map[string]string{
+		MetaInstanceID:  instanceID,
+		MetaArchiveTime: now.UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return "", err
+	}
+	return info.Key, nil
+}
+
+// Get retrieves an instance archive from Spaces
+func (s *SpacesClient) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	result, err := s.client.GetObjectWithContext(ctx, &s3.GetObjectInput{
 		Bucket: aws.String(s.bucket),
-		Key:    aws.String(archivePath),
+		Key:    aws.String(key),
 	})
 	if err != nil {
+		if isNotFound(err) {
+			return nil, ErrArchiveNotFound
+		}
 		return nil, fmt.Errorf("failed to get archive: %w", err)
 	}
 
 	return result.Body, nil
 }
 
-// ListArchives lists archives for a specific date
-func (s *SpacesClient) ListArchives(date time.Time) ([]*s3.Object, error) {
-	prefix := fmt.Sprintf("%s%s/", s.pathPrefix, date.Format("2006-01-02"))
+// List lists archives under a key prefix
+func (s *SpacesClient) List(ctx context.Context, prefix string) ([]ArchiveInfo, error) {
+	var archives []ArchiveInfo
 
-	result, err := s.client.ListObjectsV2(&s3.ListObjectsV2Input{
+	err := s.client.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
 		Bucket: aws.String(s.bucket),
 		Prefix: aws.String(prefix),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, obj := range page.Contents {
+			archives = append(archives, ArchiveInfo{
+				Key:          aws.StringValue(obj.Key),
+				Size:         aws.Int64Value(obj.Size),
+				LastModified: aws.TimeValue(obj.LastModified),
+			})
+		}
+		return true
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to list archives: %w", err)
 	}
 
-	return result.Contents, nil
+	sort.Slice(archives, func(i, j int) bool { return archives[i].Key < archives[j].Key })
+	return archives, nil
 }
 
-// DeleteArchive deletes an archive from Spaces
-func (s *SpacesClient) DeleteArchive(archivePath string) error {
-	_, err := s.client.DeleteObject(&s3.DeleteObjectInput{
+// Delete deletes an archive from Spaces
+func (s *SpacesClient) Delete(ctx context.Context, key string) error {
+	// S3 deletes are idempotent; check existence to report missing archives consistently
+	if _, err := s.client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	}); err != nil {
+		if isNotFound(err) {
+			return ErrArchiveNotFound
+		}
+		return fmt.Errorf("failed to stat archive: %w", err)
+	}
+
+	_, err := s.client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
 		Bucket: aws.String(s.bucket),
-		Key:    aws.String(archivePath),
+		Key:    aws.String(key),
 	})
 	if err != nil {
 		return fmt.Errorf("failed to delete archive: %w", err)
@@ -118,3 +180,15 @@ func (s *SpacesClient) DeleteArchive(archivePath string) error {
 
 	return nil
 }
+
+// isNotFound reports whether an S3 error means the object does not exist
+func isNotFound(err error) bool {
+	var aerr awserr.Error
+	if errors.As(err, &aerr) {
+		switch aerr.Code() {
+		case s3.ErrCodeNoSuchKey, "NotFound":
+			return true
+		}
+	}
+	return false
+}
@@ -1,16 +1,21 @@
 -- Birb Nest PostgreSQL initialization script
 
--- Create cache_entries table
+-- Create cache_entries table. JSON values are stored in value and any other
+-- content type in value_bytes; exactly one of them is set.
 CREATE TABLE IF NOT EXISTS cache_entries (
     key VARCHAR(255) NOT NULL,
-    value JSONB NOT NULL,
+    value JSONB,
+    value_bytes BYTEA,
+    content_type TEXT DEFAULT 'application/json' NOT NULL,
+    content_encoding TEXT DEFAULT '' NOT NULL,
     instance_id TEXT DEFAULT '' NOT NULL,
     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
     version INTEGER DEFAULT 1,
     ttl INTEGER DEFAULT NULL,
     metadata JSONB DEFAULT '{}'::jsonb,
-    PRIMARY KEY (instance_id, key)
+    PRIMARY KEY (instance_id, key),
+    CONSTRAINT cache_entries_value_check CHECK ((value IS NULL) <> (value_bytes IS NULL))
 );
 
 -- Create indexes for performance
@@ -52,6 +57,191 @@ CREATE TRIGGER increment_cache_entries_version
     FOR EACH ROW
     EXECUTE FUNCTION increment_version();
 
+-- Create tombstone table recording deleted keys for incremental backups
+CREATE TABLE IF NOT EXISTS cache_tombstones (
+    instance_id TEXT NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    deleted_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    PRIMARY KEY (instance_id, key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_cache_tombstones_instance_deleted_at ON cache_tombstones(instance_id, deleted_at);
+CREATE INDEX IF NOT EXISTS idx_cache_tombstones_deleted_at ON cache_tombstones(deleted_at);
+
+-- Create function to record a tombstone when an entry is deleted
+CREATE OR REPLACE FUNCTION record_cache_tombstone()
+RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO cache_tombstones (instance_id, key, deleted_at)
+    VALUES (OLD.instance_id, OLD.key, CURRENT_TIMESTAMP)
+    ON CONFLICT (instance_id, key) DO UPDATE SET deleted_at = EXCLUDED.deleted_at;
+    RETURN OLD;
+END;
+$$ language 'plpgsql';
+
+-- Create trigger to record tombstones
+DROP TRIGGER IF EXISTS record_cache_entries_tombstone ON cache_entries;
+CREATE TRIGGER record_cache_entries_tombstone
+    AFTER DELETE ON cache_entries
+    FOR EACH ROW
+    EXECUTE FUNCTION record_cache_tombstone();
+
+-- Create function to clear a tombstone when a deleted key is written again
+CREATE OR REPLACE FUNCTION clear_cache_tombstone()
+RETURNS TRIGGER AS $$
+BEGIN
+    DELETE FROM cache_tombstones WHERE instance_id = NEW.instance_id AND key = NEW.key;
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+-- Create trigger to clear tombstones
+DROP TRIGGER IF EXISTS clear_cache_entries_tombstone ON cache_entries;
+CREATE TRIGGER clear_cache_entries_tombstone
+    AFTER INSERT ON cache_entries
+    FOR EACH ROW
+    EXECUTE FUNCTION clear_cache_tombstone();
+
+-- Create instances table; the source of truth for the instance registry,
+-- which Redis only caches
+CREATE TABLE IF NOT EXISTS instances (
+    instance_id TEXT PRIMARY KEY,
+    game_type TEXT DEFAULT '' NOT NULL,
+    region TEXT DEFAULT '' NOT NULL,
+    status VARCHAR(32) NOT NULL,
+    is_permanent BOOLEAN DEFAULT false NOT NULL,
+    resource_quota JSONB,
+    metadata JSONB DEFAULT '{}'::jsonb NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    last_active TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    version BIGINT DEFAULT 0 NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_instances_status ON instances(status);
+CREATE INDEX IF NOT EXISTS idx_instances_last_active ON instances(last_active);
+
+-- Create trigger to automatically update instances.updated_at
+DROP TRIGGER IF EXISTS update_instances_updated_at ON instances;
+CREATE TRIGGER update_instances_updated_at
+    BEFORE UPDATE ON instances
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+-- Create table recording every instance status transition; rows outlive the
+-- instance so deleted instances keep their history
+CREATE TABLE IF NOT EXISTS instance_status_history (
+    id BIGSERIAL PRIMARY KEY,
+    instance_id TEXT NOT NULL,
+    from_status VARCHAR(32) DEFAULT '' NOT NULL,
+    to_status VARCHAR(32) NOT NULL,
+    reason TEXT DEFAULT '' NOT NULL,
+    actor TEXT DEFAULT '' NOT NULL,
+    version BIGINT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_instance_status_history_instance ON instance_status_history(instance_id, id);
+
+-- Create table for instance templates; template data lives in cache_entries
+-- under the reserved instance ID '__template__:<name>'
+CREATE TABLE IF NOT EXISTS instance_templates (
+    name VARCHAR(255) PRIMARY KEY,
+    source_instance_id TEXT NOT NULL,
+    game_type TEXT DEFAULT '' NOT NULL,
+    resource_quota JSONB,
+    metadata JSONB DEFAULT '{}'::jsonb NOT NULL,
+    key_count BIGINT DEFAULT 0 NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+-- Create table binding instances to the base (template) instance they read through to
+CREATE TABLE IF NOT EXISTS instance_bases (
+    instance_id TEXT PRIMARY KEY,
+    base_instance_id TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_instance_bases_base ON instance_bases(base_instance_id);
+
+-- Create table hiding base keys deleted by an instance
+CREATE TABLE IF NOT EXISTS instance_whiteouts (
+    instance_id TEXT NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    PRIMARY KEY (instance_id, key)
+);
+
+-- Create function to clear a whiteout when the key is written again
+CREATE OR REPLACE FUNCTION clear_instance_whiteout()
+RETURNS TRIGGER AS $$
+BEGIN
+    DELETE FROM instance_whiteouts WHERE instance_id = NEW.instance_id AND key = NEW.key;
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+-- Create trigger to clear whiteouts
+DROP TRIGGER IF EXISTS clear_cache_entries_whiteout ON cache_entries;
+CREATE TRIGGER clear_cache_entries_whiteout
+    AFTER INSERT ON cache_entries
+    FOR EACH ROW
+    EXECUTE FUNCTION clear_instance_whiteout();
+
+-- Create table recording instances removed by the reaper
+CREATE TABLE IF NOT EXISTS instance_tombstones (
+    instance_id TEXT PRIMARY KEY,
+    game_type TEXT DEFAULT '' NOT NULL,
+    instance_type TEXT DEFAULT '' NOT NULL,
+    archive_key TEXT DEFAULT '' NOT NULL,
+    reason TEXT NOT NULL,
+    last_active TIMESTAMP WITH TIME ZONE,
+    deleted_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+-- Create table for the reaper audit trail
+CREATE TABLE IF NOT EXISTS instance_reaper_audit (
+    id BIGSERIAL PRIMARY KEY,
+    run_id TEXT NOT NULL,
+    instance_id TEXT NOT NULL,
+    action VARCHAR(32) NOT NULL,
+    reason TEXT NOT NULL,
+    dry_run BOOLEAN DEFAULT false NOT NULL,
+    archive_key TEXT DEFAULT '' NOT NULL,
+    error_message TEXT DEFAULT '' NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_instance_reaper_audit_instance ON instance_reaper_audit(instance_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_instance_reaper_audit_created_at ON instance_reaper_audit(created_at);
+
+-- Create table for API keys; only the SHA-256 hash of each secret is stored
+CREATE TABLE IF NOT EXISTS api_keys (
+    id TEXT PRIMARY KEY,
+    name TEXT DEFAULT '' NOT NULL,
+    prefix TEXT NOT NULL,
+    key_hash TEXT NOT NULL UNIQUE,
+    scopes JSONB DEFAULT '[]'::jsonb NOT NULL,
+    instances JSONB DEFAULT '[]'::jsonb NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    replaced_by TEXT DEFAULT '' NOT NULL
+);
+
+-- Create table for per-instance data keys; keys are stored wrapped by a master key
+CREATE TABLE IF NOT EXISTS instance_data_keys (
+    id TEXT PRIMARY KEY,
+    instance_id TEXT NOT NULL,
+    master_key_id TEXT NOT NULL,
+    wrapped_key BYTEA NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    retired_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_instance_data_keys_instance ON instance_data_keys(instance_id, created_at DESC);
+
 -- Create table for dead letter queue entries
 CREATE TABLE IF NOT EXISTS dlq_entries (
     id SERIAL PRIMARY KEY,
@@ -8,14 +8,18 @@
 CREATE TABLE IF NOT EXISTS cache_entries (
     instance_id VARCHAR(255) NOT NULL,
     key VARCHAR(255) NOT NULL,
-    value JSONB NOT NULL,
+    value JSONB,
+    value_bytes BYTEA,
+    content_type TEXT DEFAULT 'application/json' NOT NULL,
+    content_encoding TEXT DEFAULT '' NOT NULL,
     version INTEGER DEFAULT 1,
     ttl INTEGER,
     metadata JSONB,
     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
     
-    PRIMARY KEY (instance_id, key)
+    PRIMARY KEY (instance_id, key),
+    CONSTRAINT cache_entries_value_check CHECK ((value IS NULL) <> (value_bytes IS NULL))
 ) PARTITION BY LIST (instance_id);
 
 -- Create default partition that catches all instances
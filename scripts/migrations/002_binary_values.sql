@@ -0,0 +1,11 @@
+-- scripts/migrations/002_binary_values.sql
+
+-- Store values of any content type: JSON stays in the JSONB value column and
+-- anything else goes to value_bytes. Existing rows are JSON.
+ALTER TABLE cache_entries ALTER COLUMN value DROP NOT NULL;
+ALTER TABLE cache_entries ADD COLUMN IF NOT EXISTS value_bytes BYTEA;
+ALTER TABLE cache_entries ADD COLUMN IF NOT EXISTS content_type TEXT DEFAULT 'application/json' NOT NULL;
+
+ALTER TABLE cache_entries DROP CONSTRAINT IF EXISTS cache_entries_value_check;
+ALTER TABLE cache_entries ADD CONSTRAINT cache_entries_value_check
+    CHECK ((value IS NULL) <> (value_bytes IS NULL));
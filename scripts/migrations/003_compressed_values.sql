@@ -0,0 +1,6 @@
+-- scripts/migrations/003_compressed_values.sql
+
+-- Record how a value is compressed. Compressed values are kept in value_bytes
+-- with the content encoding they were compressed with; existing rows are not
+-- compressed.
+ALTER TABLE cache_entries ADD COLUMN IF NOT EXISTS content_encoding TEXT DEFAULT '' NOT NULL;
@@ -0,0 +1,43 @@
+-- scripts/migrations/004_cache_tombstones.sql
+
+-- Record deleted keys for incremental backups. A tombstone is written when an
+-- entry is deleted and cleared when the key is written again.
+CREATE TABLE IF NOT EXISTS cache_tombstones (
+    instance_id TEXT NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    deleted_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    PRIMARY KEY (instance_id, key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_cache_tombstones_instance_deleted_at ON cache_tombstones(instance_id, deleted_at);
+CREATE INDEX IF NOT EXISTS idx_cache_tombstones_deleted_at ON cache_tombstones(deleted_at);
+
+CREATE OR REPLACE FUNCTION record_cache_tombstone()
+RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO cache_tombstones (instance_id, key, deleted_at)
+    VALUES (OLD.instance_id, OLD.key, CURRENT_TIMESTAMP)
+    ON CONFLICT (instance_id, key) DO UPDATE SET deleted_at = EXCLUDED.deleted_at;
+    RETURN OLD;
+END;
+$$ language 'plpgsql';
+
+DROP TRIGGER IF EXISTS record_cache_entries_tombstone ON cache_entries;
+CREATE TRIGGER record_cache_entries_tombstone
+    AFTER DELETE ON cache_entries
+    FOR EACH ROW
+    EXECUTE FUNCTION record_cache_tombstone();
+
+CREATE OR REPLACE FUNCTION clear_cache_tombstone()
+RETURNS TRIGGER AS $$
+BEGIN
+    DELETE FROM cache_tombstones WHERE instance_id = NEW.instance_id AND key = NEW.key;
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+DROP TRIGGER IF EXISTS clear_cache_entries_tombstone ON cache_entries;
+CREATE TRIGGER clear_cache_entries_tombstone
+    AFTER INSERT ON cache_entries
+    FOR EACH ROW
+    EXECUTE FUNCTION clear_cache_tombstone();
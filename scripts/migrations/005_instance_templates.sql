@@ -0,0 +1,44 @@
+-- scripts/migrations/005_instance_templates.sql
+
+-- Instance templates and copy-on-write layering. Template data lives in
+-- cache_entries under the reserved instance ID '__template__:<name>'.
+CREATE TABLE IF NOT EXISTS instance_templates (
+    name VARCHAR(255) PRIMARY KEY,
+    source_instance_id TEXT NOT NULL,
+    game_type TEXT DEFAULT '' NOT NULL,
+    resource_quota JSONB,
+    metadata JSONB DEFAULT '{}'::jsonb NOT NULL,
+    key_count BIGINT DEFAULT 0 NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+-- Instances reading through to the base (template) instance they were created from
+CREATE TABLE IF NOT EXISTS instance_bases (
+    instance_id TEXT PRIMARY KEY,
+    base_instance_id TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_instance_bases_base ON instance_bases(base_instance_id);
+
+-- Base keys deleted by an instance; cleared when the key is written again
+CREATE TABLE IF NOT EXISTS instance_whiteouts (
+    instance_id TEXT NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    PRIMARY KEY (instance_id, key)
+);
+
+CREATE OR REPLACE FUNCTION clear_instance_whiteout()
+RETURNS TRIGGER AS $$
+BEGIN
+    DELETE FROM instance_whiteouts WHERE instance_id = NEW.instance_id AND key = NEW.key;
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+DROP TRIGGER IF EXISTS clear_cache_entries_whiteout ON cache_entries;
+CREATE TRIGGER clear_cache_entries_whiteout
+    AFTER INSERT ON cache_entries
+    FOR EACH ROW
+    EXECUTE FUNCTION clear_instance_whiteout();
@@ -0,0 +1,28 @@
+-- scripts/migrations/006_instance_reaper.sql
+
+-- Instances removed by the reaper
+CREATE TABLE IF NOT EXISTS instance_tombstones (
+    instance_id TEXT PRIMARY KEY,
+    game_type TEXT DEFAULT '' NOT NULL,
+    instance_type TEXT DEFAULT '' NOT NULL,
+    archive_key TEXT DEFAULT '' NOT NULL,
+    reason TEXT NOT NULL,
+    last_active TIMESTAMP WITH TIME ZONE,
+    deleted_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+-- Audit trail of every reaper decision, including dry runs
+CREATE TABLE IF NOT EXISTS instance_reaper_audit (
+    id BIGSERIAL PRIMARY KEY,
+    run_id TEXT NOT NULL,
+    instance_id TEXT NOT NULL,
+    action VARCHAR(32) NOT NULL,
+    reason TEXT NOT NULL,
+    dry_run BOOLEAN DEFAULT false NOT NULL,
+    archive_key TEXT DEFAULT '' NOT NULL,
+    error_message TEXT DEFAULT '' NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_instance_reaper_audit_instance ON instance_reaper_audit(instance_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_instance_reaper_audit_created_at ON instance_reaper_audit(created_at);
@@ -0,0 +1,34 @@
+-- scripts/migrations/007_instances.sql
+
+-- Persist the instance registry; the table is the source of truth and Redis
+-- only caches it. Instances already in Redis are imported on the next start.
+CREATE TABLE IF NOT EXISTS instances (
+    instance_id TEXT PRIMARY KEY,
+    game_type TEXT DEFAULT '' NOT NULL,
+    region TEXT DEFAULT '' NOT NULL,
+    status VARCHAR(32) NOT NULL,
+    is_permanent BOOLEAN DEFAULT false NOT NULL,
+    resource_quota JSONB,
+    metadata JSONB DEFAULT '{}'::jsonb NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    last_active TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    version BIGINT DEFAULT 0 NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_instances_status ON instances(status);
+CREATE INDEX IF NOT EXISTS idx_instances_last_active ON instances(last_active);
+
+CREATE OR REPLACE FUNCTION update_updated_at_column()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.updated_at = CURRENT_TIMESTAMP;
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+DROP TRIGGER IF EXISTS update_instances_updated_at ON instances;
+CREATE TRIGGER update_instances_updated_at
+    BEFORE UPDATE ON instances
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
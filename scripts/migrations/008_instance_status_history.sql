@@ -0,0 +1,16 @@
+-- scripts/migrations/008_instance_status_history.sql
+
+-- Record every instance status transition. Rows outlive the instance so
+-- deleted instances keep their history.
+CREATE TABLE IF NOT EXISTS instance_status_history (
+    id BIGSERIAL PRIMARY KEY,
+    instance_id TEXT NOT NULL,
+    from_status VARCHAR(32) DEFAULT '' NOT NULL,
+    to_status VARCHAR(32) NOT NULL,
+    reason TEXT DEFAULT '' NOT NULL,
+    actor TEXT DEFAULT '' NOT NULL,
+    version BIGINT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_instance_status_history_instance ON instance_status_history(instance_id, id);
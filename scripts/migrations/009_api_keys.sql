@@ -0,0 +1,17 @@
+-- scripts/migrations/009_api_keys.sql
+
+-- Managed API keys. Only the SHA-256 hash of each secret is stored; the
+-- secret is shown once when the key is created or rotated.
+CREATE TABLE IF NOT EXISTS api_keys (
+    id TEXT PRIMARY KEY,
+    name TEXT DEFAULT '' NOT NULL,
+    prefix TEXT NOT NULL,
+    key_hash TEXT NOT NULL UNIQUE,
+    scopes JSONB DEFAULT '[]'::jsonb NOT NULL,
+    instances JSONB DEFAULT '[]'::jsonb NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    replaced_by TEXT DEFAULT '' NOT NULL
+);
@@ -0,0 +1,14 @@
+-- scripts/migrations/010_instance_data_keys.sql
+
+-- Per-instance data keys for encryption at rest. Each key is stored wrapped
+-- by a master key; retired keys are kept to decrypt older entries.
+CREATE TABLE IF NOT EXISTS instance_data_keys (
+    id TEXT PRIMARY KEY,
+    instance_id TEXT NOT NULL,
+    master_key_id TEXT NOT NULL,
+    wrapped_key BYTEA NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    retired_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_instance_data_keys_instance ON instance_data_keys(instance_id, created_at DESC);
@@ -3,6 +3,8 @@ package sdk
 import (
 	"context"
 	"fmt"
+	"io"
+	"net/http"
 	"sync"
 	"time"
 )
@@ -159,6 +161,38 @@ type ExtendedClient interface {
 	//	    log.Printf("%s: %v", key, value)
 	//	}
 	GetMultiple(ctx context.Context, keys []string) (map[string]interface{}, error)
+
+	// SetReader stores the contents of r as a key's value without buffering
+	// it, so values larger than memory can be uploaded. The server stores
+	// large values in chunks as they arrive. An empty contentType stores the
+	// value as application/octet-stream. Uploads are not retried since r
+	// cannot be replayed.
+	//
+	// Example:
+	//
+	//	f, _ := os.Open("world.bin")
+	//	defer f.Close()
+	//	err := client.SetReader(ctx, "world:42", f, "application/octet-stream")
+	SetReader(ctx context.Context, key string, r io.Reader, contentType string) error
+
+	// GetWriter copies a key's value to w as it is downloaded and returns the
+	// number of bytes written. Use GetRangeWriter to download part of a value.
+	//
+	// Example:
+	//
+	//	f, _ := os.Create("world.bin")
+	//	defer f.Close()
+	//	n, err := client.GetWriter(ctx, "world:42", f)
+	GetWriter(ctx context.Context, key string, w io.Writer) (int64, error)
+
+	// GetRangeWriter copies length bytes of a key's value, starting at
+	// offset, to w. A negative length reads to the end of the value.
+	//
+	// Example:
+	//
+	//	// Read the second megabyte of a value
+	//	n, err := client.GetRangeWriter(ctx, "world:42", 1<<20, 1<<20, &buf)
+	GetRangeWriter(ctx context.Context, key string, offset, length int64, w io.Writer) (int64, error)
 }
 
 // client is the concrete implementation of the Client interface
@@ -475,3 +509,62 @@ func (c *client) Exists(ctx context.Context, key string) (bool, error) {
 
 	return false, err
 }
+
+// SetReader stores a value read from r
+func (c *client) SetReader(ctx context.Context, key string, r io.Reader, contentType string) error {
+	if err := c.checkClosed(); err != nil {
+		return err
+	}
+
+	if key == "" {
+		return fmt.Errorf("key cannot be empty")
+	}
+
+	if r == nil {
+		return fmt.Errorf("reader cannot be nil")
+	}
+
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	path := fmt.Sprintf("/v1/cache/%s", key)
+	headers := map[string]string{"Content-Type": contentType}
+	_, err := c.transport.stream(ctx, http.MethodPut, path, r, headers, nil)
+	return err
+}
+
+// GetWriter copies a value to w
+func (c *client) GetWriter(ctx context.Context, key string, w io.Writer) (int64, error) {
+	return c.GetRangeWriter(ctx, key, 0, -1, w)
+}
+
+// GetRangeWriter copies part of a value to w
+func (c *client) GetRangeWriter(ctx context.Context, key string, offset, length int64, w io.Writer) (int64, error) {
+	if err := c.checkClosed(); err != nil {
+		return 0, err
+	}
+
+	if key == "" {
+		return 0, fmt.Errorf("key cannot be empty")
+	}
+
+	if w == nil {
+		return 0, fmt.Errorf("writer cannot be nil")
+	}
+
+	if offset < 0 || length == 0 {
+		return 0, fmt.Errorf("invalid range: offset %d, length %d", offset, length)
+	}
+
+	headers := map[string]string{}
+	switch {
+	case length > 0:
+		headers["Range"] = fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)
+	case offset > 0:
+		headers["Range"] = fmt.Sprintf("bytes=%d-", offset)
+	}
+
+	path := fmt.Sprintf("/v1/cache/%s", key)
+	return c.transport.stream(ctx, http.MethodGet, path, nil, headers, w)
+}
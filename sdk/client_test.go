@@ -1,9 +1,11 @@
 package sdk
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"sync"
@@ -592,3 +594,69 @@ func TestExtendedClient_Exists(t *testing.T) {
 	assert.NoError(t, err)
 	assert.False(t, exists, "Key should not exist")
 }
+
+// TestExtendedClient_Streaming tests SetReader, GetWriter and GetRangeWriter
+func TestExtendedClient_Streaming(t *testing.T) {
+	var stored []byte
+	var contentType string
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/cache/", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPut:
+			contentType = r.Header.Get("Content-Type")
+			stored, _ = io.ReadAll(r.Body)
+			w.WriteHeader(http.StatusOK)
+		case http.MethodGet:
+			if stored == nil {
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error": "Not found",
+					"code":  "NOT_FOUND",
+				})
+				return
+			}
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(stored))
+		}
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	config := DefaultConfig().WithBaseURL(server.URL)
+	client, err := NewExtendedClient(config)
+	require.NoError(t, err)
+	defer client.Close()
+
+	ctx := context.Background()
+
+	// Missing keys are not found
+	_, err = client.GetWriter(ctx, "blob", io.Discard)
+	assert.True(t, IsNotFound(err), "Missing key should not be found")
+
+	value := bytes.Repeat([]byte("0123456789"), 1000)
+	err = client.SetReader(ctx, "blob", bytes.NewReader(value), "")
+	require.NoError(t, err)
+	assert.Equal(t, "application/octet-stream", contentType)
+	assert.Equal(t, value, stored)
+
+	var buf bytes.Buffer
+	n, err := client.GetWriter(ctx, "blob", &buf)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(value)), n)
+	assert.Equal(t, value, buf.Bytes())
+
+	buf.Reset()
+	n, err = client.GetRangeWriter(ctx, "blob", 5, 10, &buf)
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), n)
+	assert.Equal(t, "5678901234", buf.String())
+
+	buf.Reset()
+	_, err = client.GetRangeWriter(ctx, "blob", int64(len(value))-3, -1, &buf)
+	require.NoError(t, err)
+	assert.Equal(t, "789", buf.String())
+
+	_, err = client.GetRangeWriter(ctx, "blob", 0, 0, &buf)
+	assert.Error(t, err, "Empty range should be rejected")
+}
@@ -47,6 +47,40 @@ var (
 
 	// ErrRetryBudgetExhausted is returned when retry budget is exhausted
 	ErrRetryBudgetExhausted = errors.New("retry budget exhausted")
+
+	// ErrInvalidKey is returned when the server rejects a key as too long,
+	// containing characters it does not allow, or reserved
+	ErrInvalidKey = errors.New("invalid key")
+
+	// ErrValueTooLarge is returned when a value exceeds the server's maximum value size
+	ErrValueTooLarge = errors.New("value too large")
+)
+
+// Error codes the server sets in APIError.Code. Codes are stable; messages
+// and details may change.
+//
+// Example:
+//
+//	if sdk.ErrorCode(err) == sdk.CodeQuotaExceeded {
+//	    // The instance is full
+//	}
+const (
+	CodeNotFound              = "NOT_FOUND"
+	CodeInvalidRequest        = "INVALID_REQUEST"
+	CodeInternalError         = "INTERNAL_ERROR"
+	CodeRateLimited           = "RATE_LIMITED"
+	CodeQuotaExceeded         = "QUOTA_EXCEEDED"
+	CodeUnauthorized          = "UNAUTHORIZED"
+	CodeInsufficientScope     = "INSUFFICIENT_SCOPE"
+	CodeInstanceForbidden     = "INSTANCE_FORBIDDEN"
+	CodeKeyForbidden          = "KEY_FORBIDDEN"
+	CodeInvalidKey            = "INVALID_KEY"
+	CodeKeyTooLong            = "KEY_TOO_LONG"
+	CodeReservedKey           = "RESERVED_KEY"
+	CodeValueTooLarge         = "VALUE_TOO_LARGE"
+	CodeRangeNotSatisfiable   = "RANGE_NOT_SATISFIABLE"
+	CodeEncryptionUnavailable = "ENCRYPTION_UNAVAILABLE"
+	CodePrimaryUnavailable    = "PRIMARY_UNAVAILABLE"
 )
 
 // ErrorType represents the type of error for categorization and handling.
@@ -293,6 +327,22 @@ func (e *APIError) IsNotFound() bool {
 	return e.StatusCode == http.StatusNotFound || e.Code == "NOT_FOUND"
 }
 
+// Is implements errors.Is, matching the sentinel errors for the server's
+// error codes
+func (e *APIError) Is(target error) bool {
+	switch e.Code {
+	case CodeNotFound:
+		return target == ErrNotFound
+	case CodeRateLimited:
+		return target == ErrRateLimited
+	case CodeInvalidKey, CodeKeyTooLong, CodeReservedKey:
+		return target == ErrInvalidKey
+	case CodeValueTooLarge:
+		return target == ErrValueTooLarge
+	}
+	return false
+}
+
 // IsServerError returns true if the error is a server error
 func (e *APIError) IsServerError() bool {
 	return e.StatusCode >= 500
@@ -329,6 +379,8 @@ func (e *APIError) ToError() *Error {
 		errType = ErrorTypeRateLimit
 	} else if e.StatusCode == http.StatusRequestTimeout || e.StatusCode == http.StatusGatewayTimeout {
 		errType = ErrorTypeTimeout
+	} else if errors.Is(e, ErrInvalidKey) || errors.Is(e, ErrValueTooLarge) {
+		errType = ErrorTypeValidation
 	}
 
 	err := NewErrorWithCode(errType, e.Code, e.Message, e)
@@ -444,6 +496,29 @@ func IsNotFound(err error) bool {
 	return false
 }
 
+// ErrorCode returns the server's error code for an error, or "" if the error
+// did not come from an API error response.
+//
+// Example:
+//
+//	switch sdk.ErrorCode(err) {
+//	case sdk.CodeKeyTooLong, sdk.CodeInvalidKey:
+//	    // Fix the key
+//	case sdk.CodeQuotaExceeded:
+//	    // Free up space
+//	}
+func ErrorCode(err error) string {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Code
+	}
+	var enhancedErr *Error
+	if errors.As(err, &enhancedErr) {
+		return enhancedErr.Code
+	}
+	return ""
+}
+
 // IsRetryable checks if an error is retryable.
 // Retryable errors include:
 //   - Network errors (connection issues)
@@ -431,6 +431,46 @@ func TestParseAPIError_EdgeCases(t *testing.T) {
 	}
 }
 
+func TestAPIErrorCodes(t *testing.T) {
+	tests := []struct {
+		body     string
+		status   int
+		sentinel error
+		errType  ErrorType
+	}{
+		{`{"error":"Invalid key","code":"KEY_TOO_LONG","details":"key is too long: 300 bytes"}`, 400, ErrInvalidKey, ErrorTypeValidation},
+		{`{"error":"Invalid key","code":"RESERVED_KEY"}`, 400, ErrInvalidKey, ErrorTypeValidation},
+		{`{"error":"Value too large","code":"VALUE_TOO_LARGE"}`, 413, ErrValueTooLarge, ErrorTypeValidation},
+		{`{"error":"Key not found","code":"NOT_FOUND"}`, 404, ErrNotFound, ErrorTypeClient},
+		{`{"error":"Rate limit exceeded","code":"RATE_LIMITED"}`, 429, ErrRateLimited, ErrorTypeRateLimit},
+	}
+
+	for _, tt := range tests {
+		apiErr := parseAPIError(tt.status, []byte(tt.body)).(*APIError)
+		if !errors.Is(apiErr, tt.sentinel) {
+			t.Errorf("errors.Is(%s, %v) = false", tt.body, tt.sentinel)
+		}
+
+		enhanced := apiErr.ToError()
+		if !errors.Is(enhanced, tt.sentinel) {
+			t.Errorf("errors.Is(ToError() of %s, %v) = false", tt.body, tt.sentinel)
+		}
+		if enhanced.Type != tt.errType {
+			t.Errorf("ToError() of %s has type %v, want %v", tt.body, enhanced.Type, tt.errType)
+		}
+		if got := ErrorCode(fmt.Errorf("wrapped: %w", enhanced)); got != apiErr.Code {
+			t.Errorf("ErrorCode() = %q, want %q", got, apiErr.Code)
+		}
+	}
+
+	if errors.Is(&APIError{StatusCode: 400, Code: CodeInvalidRequest}, ErrInvalidKey) {
+		t.Error("INVALID_REQUEST should not match ErrInvalidKey")
+	}
+	if got := ErrorCode(errors.New("plain")); got != "" {
+		t.Errorf("ErrorCode() of a plain error = %q, want empty", got)
+	}
+}
+
 // Benchmark error creation and checking
 func BenchmarkAPIError_Error(b *testing.B) {
 	err := &APIError{
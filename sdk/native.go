@@ -210,6 +210,89 @@ func (t *httpTransport) performHTTPRequest(ctx context.Context, method, path str
 	return apiErr
 }
 
+// stream performs a request with a raw body and copies a successful
+// response body to w. Streamed requests are not retried, since their body
+// cannot be replayed, and are bounded by ctx rather than the client timeout
+// so large values have time to transfer.
+func (t *httpTransport) stream(ctx context.Context, method, path string, body io.Reader, headers map[string]string, w io.Writer) (int64, error) {
+	if t.observer != nil {
+		t.observer.OnRequestStart(method, path)
+	}
+
+	start := time.Now()
+	var written int64
+	executeFn := func() error {
+		var err error
+		written, err = t.performStreamRequest(ctx, method, path, body, headers, w)
+		return err
+	}
+
+	var finalErr error
+	if t.perEndpointCircuitBreaker != nil {
+		finalErr = t.perEndpointCircuitBreaker.Execute(method+" "+path, executeFn)
+	} else {
+		finalErr = t.circuitBreaker.Execute(executeFn)
+	}
+
+	if t.observer != nil {
+		t.observer.OnRequestEnd(method, path, time.Since(start), finalErr)
+	}
+	return written, finalErr
+}
+
+// performStreamRequest performs a single streamed HTTP request
+func (t *httpTransport) performStreamRequest(ctx context.Context, method, path string, body io.Reader, headers map[string]string, w io.Writer) (int64, error) {
+	fullURL := t.baseURL.ResolveReference(&url.URL{Path: path})
+	req, err := http.NewRequestWithContext(ctx, method, fullURL.String(), body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("User-Agent", "birb-nest-go-sdk/1.0.0")
+	for key, value := range t.config.Headers {
+		req.Header.Set(key, value)
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	client := *t.client
+	client.Timeout = 0
+	resp, err := client.Do(req)
+	if err != nil {
+		netErr := &NetworkError{Op: method + " " + path, Err: err}
+		return 0, netErr.ToError()
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		apiErr := parseAPIError(resp.StatusCode, respBody)
+		if apiErrTyped, ok := apiErr.(*APIError); ok {
+			enhancedErr := apiErrTyped.ToError()
+			enhancedErr.WithContext(&ErrorContext{
+				URL:    fullURL.String(),
+				Method: method,
+			})
+			if reqID := resp.Header.Get("X-Request-ID"); reqID != "" {
+				enhancedErr.RequestID = reqID
+			}
+			return 0, enhancedErr
+		}
+		return 0, apiErr
+	}
+
+	if w == nil {
+		return 0, nil
+	}
+	written, err := io.Copy(w, resp.Body)
+	if err != nil {
+		netErr := &NetworkError{Op: "reading response", Err: err}
+		return written, netErr.ToError()
+	}
+	return written, nil
+}
+
 // get performs a GET request
 func (t *httpTransport) get(ctx context.Context, path string, result interface{}) error {
 	return t.do(ctx, http.MethodGet, path, nil, result)
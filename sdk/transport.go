@@ -46,6 +46,8 @@ type httpTransport struct {
 //   - get(ctx, path, response): Performs GET requests
 //   - post(ctx, path, body, response): Performs POST requests
 //   - delete(ctx, path): Performs DELETE requests
+//   - stream(ctx, method, path, body, headers, w): Sends and receives raw
+//     bodies without buffering them
 //   - close(): Closes the transport and releases resources
 
 // buildPath builds a URL path with proper escaping for path parameters.
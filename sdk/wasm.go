@@ -6,6 +6,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"syscall/js"
 	"time"
 )
@@ -150,6 +151,12 @@ func (t *httpTransport) fetch(ctx context.Context, url string, opts map[string]i
 	}
 }
 
+// stream is not supported in WASM builds: fetch responses are read as text,
+// which does not preserve binary values
+func (t *httpTransport) stream(ctx context.Context, method, path string, body io.Reader, headers map[string]string, w io.Writer) (int64, error) {
+	return 0, fmt.Errorf("streaming %s %s is not supported in WASM builds", method, path)
+}
+
 // get performs a GET request
 func (t *httpTransport) get(ctx context.Context, path string, result interface{}) error {
 	return t.do(ctx, "GET", path, nil, result)
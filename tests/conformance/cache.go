@@ -0,0 +1,441 @@
+// Package conformance provides reusable behavioural test suites for storage
+// backends. Any cache.Cache or database.Interface implementation can run the
+// matching suite to prove it honours the contracts the rest of the service
+// relies on: TTL semantics, instance isolation, batch operations, missing-key
+// errors, concurrency and large values.
+//
+// In-process backends run the suites directly from their package tests;
+// Redis and PostgreSQL run them from tests/integration against containers
+// started by tests/testutil.
+package conformance
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/birbparty/birb-nest/internal/cache"
+)
+
+// CacheFactory returns a fresh, empty cache for a single subtest.
+// The suite closes the cache when the subtest finishes.
+type CacheFactory func(t *testing.T) cache.Cache
+
+// CacheOptions tunes the cache suite for backend-specific limits
+type CacheOptions struct {
+	// TTL is the short expiry used by TTL tests (default 200ms)
+	TTL time.Duration
+
+	// LargeValueSize is the size of the value used by large-value tests (default 4 MiB)
+	LargeValueSize int
+
+	// Concurrency is the number of goroutines used by concurrency tests (default 32)
+	Concurrency int
+}
+
+func (o CacheOptions) withDefaults() CacheOptions {
+	if o.TTL == 0 {
+		o.TTL = 200 * time.Millisecond
+	}
+	if o.LargeValueSize == 0 {
+		o.LargeValueSize = 4 << 20
+	}
+	if o.Concurrency == 0 {
+		o.Concurrency = 32
+	}
+	return o
+}
+
+// RunCacheSuite runs the cache conformance suite against the backend produced by newCache
+func RunCacheSuite(t *testing.T, newCache CacheFactory, opts CacheOptions) {
+	opts = opts.withDefaults()
+
+	open := func(t *testing.T) cache.Cache {
+		t.Helper()
+		c := newCache(t)
+		t.Cleanup(func() { c.Close() })
+		return c
+	}
+
+	t.Run("SetGetRoundTrip", func(t *testing.T) {
+		c := open(t)
+		ctx := context.Background()
+
+		want := []byte(`{"player":"birb","score":42}`)
+		if err := c.Set(ctx, "roundtrip", want, time.Minute); err != nil {
+			t.Fatalf("Set() error = %v", err)
+		}
+		got, err := c.Get(ctx, "roundtrip")
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("Get() = %q, want %q", got, want)
+		}
+
+		// Overwrites replace the previous value
+		if err := c.Set(ctx, "roundtrip", []byte("v2"), time.Minute); err != nil {
+			t.Fatalf("Set() overwrite error = %v", err)
+		}
+		if got, _ := c.Get(ctx, "roundtrip"); string(got) != "v2" {
+			t.Errorf("Get() after overwrite = %q, want v2", got)
+		}
+	})
+
+	t.Run("MissingKey", func(t *testing.T) {
+		c := open(t)
+		ctx := context.Background()
+
+		if _, err := c.Get(ctx, "missing"); !errors.Is(err, cache.ErrKeyNotFound) {
+			t.Errorf("Get() missing error = %v, want ErrKeyNotFound", err)
+		}
+		if err := c.Delete(ctx, "missing"); !errors.Is(err, cache.ErrKeyNotFound) {
+			t.Errorf("Delete() missing error = %v, want ErrKeyNotFound", err)
+		}
+		exists, err := c.Exists(ctx, "missing")
+		if err != nil || exists {
+			t.Errorf("Exists() missing = %v, %v; want false, nil", exists, err)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		c := open(t)
+		ctx := context.Background()
+
+		if err := c.Set(ctx, "doomed", []byte("x"), time.Minute); err != nil {
+			t.Fatalf("Set() error = %v", err)
+		}
+		if err := c.Delete(ctx, "doomed"); err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+		if _, err := c.Get(ctx, "doomed"); !errors.Is(err, cache.ErrKeyNotFound) {
+			t.Errorf("Get() after Delete error = %v, want ErrKeyNotFound", err)
+		}
+	})
+
+	t.Run("TTLExpiry", func(t *testing.T) {
+		c := open(t)
+		ctx := context.Background()
+
+		if err := c.Set(ctx, "short", []byte("x"), opts.TTL); err != nil {
+			t.Fatalf("Set() error = %v", err)
+		}
+		if err := c.Set(ctx, "long", []byte("y"), time.Hour); err != nil {
+			t.Fatalf("Set() error = %v", err)
+		}
+		if exists, _ := c.Exists(ctx, "short"); !exists {
+			t.Fatal("key expired before its TTL")
+		}
+
+		time.Sleep(opts.TTL + opts.TTL/2 + 50*time.Millisecond)
+
+		if _, err := c.Get(ctx, "short"); !errors.Is(err, cache.ErrKeyNotFound) {
+			t.Errorf("Get() expired error = %v, want ErrKeyNotFound", err)
+		}
+		if exists, _ := c.Exists(ctx, "short"); exists {
+			t.Error("Exists() = true for expired key")
+		}
+		if _, err := c.Get(ctx, "long"); err != nil {
+			t.Errorf("Get() unexpired error = %v", err)
+		}
+	})
+
+	t.Run("TTLResetOnOverwrite", func(t *testing.T) {
+		c := open(t)
+		ctx := context.Background()
+
+		if err := c.Set(ctx, "reset", []byte("x"), opts.TTL); err != nil {
+			t.Fatalf("Set() error = %v", err)
+		}
+		if err := c.Set(ctx, "reset", []byte("y"), time.Hour); err != nil {
+			t.Fatalf("Set() error = %v", err)
+		}
+
+		time.Sleep(opts.TTL + opts.TTL/2 + 50*time.Millisecond)
+
+		if got, err := c.Get(ctx, "reset"); err != nil || string(got) != "y" {
+			t.Errorf("Get() = %q, %v; want y with a refreshed TTL", got, err)
+		}
+	})
+
+	t.Run("BatchOperations", func(t *testing.T) {
+		c := open(t)
+		ctx := context.Background()
+
+		items := make(map[string][]byte)
+		for i := 0; i < 50; i++ {
+			items[fmt.Sprintf("batch:%02d", i)] = []byte(fmt.Sprintf("value-%d", i))
+		}
+		if err := c.SetMultiple(ctx, items, time.Minute); err != nil {
+			t.Fatalf("SetMultiple() error = %v", err)
+		}
+
+		keys := []string{"batch:00", "batch:25", "batch:49", "batch:missing"}
+		got, err := c.GetMultiple(ctx, keys)
+		if err != nil {
+			t.Fatalf("GetMultiple() error = %v", err)
+		}
+		if len(got) != 3 {
+			t.Errorf("GetMultiple() returned %d values, want 3 (missing keys are omitted)", len(got))
+		}
+		for _, key := range keys[:3] {
+			if !bytes.Equal(got[key], items[key]) {
+				t.Errorf("GetMultiple()[%s] = %q, want %q", key, got[key], items[key])
+			}
+		}
+
+		// Deleting a mix of present and absent keys is not an error
+		if err := c.DeleteMultiple(ctx, []string{"batch:00", "batch:01", "batch:missing"}); err != nil {
+			t.Fatalf("DeleteMultiple() error = %v", err)
+		}
+		got, _ = c.GetMultiple(ctx, []string{"batch:00", "batch:01", "batch:02"})
+		if _, ok := got["batch:02"]; !ok || len(got) != 1 {
+			t.Errorf("GetMultiple() after DeleteMultiple = %v, want only batch:02", sortedKeys(got))
+		}
+
+		// Empty batches are no-ops
+		if got, err := c.GetMultiple(ctx, nil); err != nil || len(got) != 0 {
+			t.Errorf("GetMultiple(nil) = %v, %v", got, err)
+		}
+		if err := c.SetMultiple(ctx, nil, time.Minute); err != nil {
+			t.Errorf("SetMultiple(nil) error = %v", err)
+		}
+		if err := c.DeleteMultiple(ctx, nil); err != nil {
+			t.Errorf("DeleteMultiple(nil) error = %v", err)
+		}
+	})
+
+	t.Run("BatchTTL", func(t *testing.T) {
+		c := open(t)
+		ctx := context.Background()
+
+		if err := c.SetMultiple(ctx, map[string][]byte{"bttl:a": []byte("a"), "bttl:b": []byte("b")}, opts.TTL); err != nil {
+			t.Fatalf("SetMultiple() error = %v", err)
+		}
+
+		time.Sleep(opts.TTL + opts.TTL/2 + 50*time.Millisecond)
+
+		got, err := c.GetMultiple(ctx, []string{"bttl:a", "bttl:b"})
+		if err != nil {
+			t.Fatalf("GetMultiple() error = %v", err)
+		}
+		if len(got) != 0 {
+			t.Errorf("GetMultiple() returned expired keys %v", sortedKeys(got))
+		}
+	})
+
+	t.Run("InstanceIsolation", func(t *testing.T) {
+		c := open(t)
+		ctx := context.Background()
+
+		instA := cache.NewInstanceCache(c, "inst_conformance_a")
+		instB := cache.NewInstanceCache(c, "inst_conformance_b")
+
+		if err := instA.Set(ctx, "shared", []byte("a"), time.Minute); err != nil {
+			t.Fatalf("Set() a error = %v", err)
+		}
+		if err := instB.Set(ctx, "shared", []byte("b"), time.Minute); err != nil {
+			t.Fatalf("Set() b error = %v", err)
+		}
+		if err := instA.Set(ctx, "only-a", []byte("a"), time.Minute); err != nil {
+			t.Fatalf("Set() a error = %v", err)
+		}
+
+		if got, _ := instA.Get(ctx, "shared"); string(got) != "a" {
+			t.Errorf("instance a Get() = %q, want a", got)
+		}
+		if got, _ := instB.Get(ctx, "shared"); string(got) != "b" {
+			t.Errorf("instance b Get() = %q, want b", got)
+		}
+		if _, err := instB.Get(ctx, "only-a"); !errors.Is(err, cache.ErrKeyNotFound) {
+			t.Errorf("instance b read instance a key: error = %v", err)
+		}
+
+		got, err := instB.GetMultiple(ctx, []string{"shared", "only-a"})
+		if err != nil {
+			t.Fatalf("GetMultiple() error = %v", err)
+		}
+		if len(got) != 1 || string(got["shared"]) != "b" {
+			t.Errorf("instance b GetMultiple() = %v, want only its own key", sortedKeys(got))
+		}
+
+		if err := instA.DeleteMultiple(ctx, []string{"shared", "only-a"}); err != nil {
+			t.Fatalf("DeleteMultiple() error = %v", err)
+		}
+		if got, err := instB.Get(ctx, "shared"); err != nil || string(got) != "b" {
+			t.Errorf("instance a delete affected instance b: %q, %v", got, err)
+		}
+
+		if _, ok := c.(cache.Scanner); ok {
+			_ = instA.Set(ctx, "scan-a", []byte("a"), time.Minute)
+			keys, err := instB.Scan(ctx, "*", 100)
+			if err != nil {
+				t.Fatalf("Scan() error = %v", err)
+			}
+			if len(keys) != 1 || keys[0] != "shared" {
+				t.Errorf("instance b Scan() = %v, want [shared]", keys)
+			}
+		}
+	})
+
+	t.Run("LargeValues", func(t *testing.T) {
+		c := open(t)
+		ctx := context.Background()
+
+		large := make([]byte, opts.LargeValueSize)
+		for i := range large {
+			large[i] = byte(i % 251)
+		}
+
+		if err := c.Set(ctx, "large", large, time.Minute); err != nil {
+			t.Fatalf("Set() large value error = %v", err)
+		}
+		got, err := c.Get(ctx, "large")
+		if err != nil {
+			t.Fatalf("Get() large value error = %v", err)
+		}
+		if !bytes.Equal(got, large) {
+			t.Errorf("large value corrupted: got %d bytes, want %d", len(got), len(large))
+		}
+
+		// Binary-safe: zero bytes and invalid UTF-8 survive unchanged
+		binary := []byte{0x00, 0xff, 0xfe, 0x00, 0x80}
+		if err := c.Set(ctx, "binary", binary, time.Minute); err != nil {
+			t.Fatalf("Set() binary error = %v", err)
+		}
+		if got, _ := c.Get(ctx, "binary"); !bytes.Equal(got, binary) {
+			t.Errorf("binary value = %v, want %v", got, binary)
+		}
+	})
+
+	t.Run("ReturnedValuesAreIsolated", func(t *testing.T) {
+		c := open(t)
+		ctx := context.Background()
+
+		value := []byte("original")
+		if err := c.Set(ctx, "alias", value, time.Minute); err != nil {
+			t.Fatalf("Set() error = %v", err)
+		}
+		value[0] = 'X'
+
+		got, _ := c.Get(ctx, "alias")
+		if string(got) != "original" {
+			t.Errorf("cache retained caller's buffer: Get() = %q", got)
+		}
+		got[0] = 'Y'
+		if again, _ := c.Get(ctx, "alias"); string(again) != "original" {
+			t.Errorf("mutating a returned value changed the cache: Get() = %q", again)
+		}
+	})
+
+	t.Run("Concurrency", func(t *testing.T) {
+		c := open(t)
+		ctx := context.Background()
+
+		var wg sync.WaitGroup
+		errs := make(chan error, opts.Concurrency)
+		for g := 0; g < opts.Concurrency; g++ {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				for i := 0; i < 20; i++ {
+					own := fmt.Sprintf("conc:%d:%d", g, i)
+					want := []byte(own)
+					if err := c.Set(ctx, own, want, time.Minute); err != nil {
+						errs <- fmt.Errorf("Set(%s): %w", own, err)
+						return
+					}
+					got, err := c.Get(ctx, own)
+					if err != nil || !bytes.Equal(got, want) {
+						errs <- fmt.Errorf("Get(%s) = %q, %v", own, got, err)
+						return
+					}
+					// Contended key shared by every goroutine
+					if err := c.Set(ctx, "conc:shared", want, time.Minute); err != nil {
+						errs <- fmt.Errorf("Set(shared): %w", err)
+						return
+					}
+					if _, err := c.Get(ctx, "conc:shared"); err != nil {
+						errs <- fmt.Errorf("Get(shared): %w", err)
+						return
+					}
+				}
+			}(g)
+		}
+		wg.Wait()
+		close(errs)
+
+		for err := range errs {
+			t.Error(err)
+		}
+	})
+
+	t.Run("SetNX", func(t *testing.T) {
+		c := open(t)
+		setter, ok := c.(cache.ExclusiveSetter)
+		if !ok {
+			t.Skip("backend cannot create keys atomically")
+		}
+		ctx := context.Background()
+
+		// Exactly one concurrent creator wins
+		var wg sync.WaitGroup
+		var won atomic.Int32
+		for i := 0; i < opts.Concurrency; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				set, err := setter.SetNX(ctx, "claimed", []byte(fmt.Sprintf("owner-%d", i)), time.Minute)
+				if err != nil {
+					t.Errorf("SetNX() error = %v", err)
+				}
+				if set {
+					won.Add(1)
+				}
+			}(i)
+		}
+		wg.Wait()
+		if n := won.Load(); n != 1 {
+			t.Errorf("%d concurrent SetNX() calls succeeded, want 1", n)
+		}
+
+		if set, err := setter.SetNX(ctx, "claimed", []byte("late"), time.Minute); err != nil || set {
+			t.Errorf("SetNX() on existing key = %v, %v; want false", set, err)
+		}
+		if got, _ := c.Get(ctx, "claimed"); !bytes.HasPrefix(got, []byte("owner-")) {
+			t.Errorf("Get() = %q, want the winner's value", got)
+		}
+	})
+
+	t.Run("Ping", func(t *testing.T) {
+		c := open(t)
+		if err := c.Ping(context.Background()); err != nil {
+			t.Errorf("Ping() error = %v", err)
+		}
+	})
+
+	t.Run("ClosedCache", func(t *testing.T) {
+		c := newCache(t)
+		if err := c.Close(); err != nil {
+			t.Fatalf("Close() error = %v", err)
+		}
+		if err := c.Set(context.Background(), "after-close", []byte("x"), time.Minute); err == nil {
+			t.Error("Set() after Close succeeded")
+		}
+	})
+}
+
+func sortedKeys(m map[string][]byte) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
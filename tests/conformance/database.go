@@ -0,0 +1,459 @@
+package conformance
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/birbparty/birb-nest/internal/cache"
+	"github.com/birbparty/birb-nest/internal/database"
+	"github.com/birbparty/birb-nest/internal/instance"
+)
+
+// DatabaseFactory returns a fresh, empty database for a single subtest.
+// The suite closes the database when the subtest finishes.
+type DatabaseFactory func(t *testing.T) database.Interface
+
+// DatabaseOptions tunes the database suite for backend-specific limits
+type DatabaseOptions struct {
+	// LargeValueSize is the size of the JSON value used by large-value tests (default 4 MiB)
+	LargeValueSize int
+
+	// Concurrency is the number of goroutines used by concurrency tests (default 16)
+	Concurrency int
+}
+
+func (o DatabaseOptions) withDefaults() DatabaseOptions {
+	if o.LargeValueSize == 0 {
+		o.LargeValueSize = 4 << 20
+	}
+	if o.Concurrency == 0 {
+		o.Concurrency = 16
+	}
+	return o
+}
+
+// entrySetter is implemented by databases that can store entries with a TTL in seconds
+type entrySetter interface {
+	SetEntry(ctx context.Context, key, instanceID string, value json.RawMessage, ttl *int, metadata json.RawMessage) error
+}
+
+// batchGetter is implemented by databases that read several keys in one query
+type batchGetter interface {
+	BatchGetWithInstance(ctx context.Context, keys []string, instanceID string) ([]*database.CacheEntry, error)
+}
+
+// RunDatabaseSuite runs the database conformance suite against the backend produced by newDB
+func RunDatabaseSuite(t *testing.T, newDB DatabaseFactory, opts DatabaseOptions) {
+	opts = opts.withDefaults()
+
+	open := func(t *testing.T) database.Interface {
+		t.Helper()
+		db := newDB(t)
+		t.Cleanup(func() { db.Close() })
+		return db
+	}
+
+	t.Run("SetGetRoundTrip", func(t *testing.T) {
+		db := open(t)
+		ctx := context.Background()
+
+		want := `{"player":"birb","score":42}`
+		if err := db.SetWithInstance(ctx, "roundtrip", "inst_conf", []byte(want)); err != nil {
+			t.Fatalf("SetWithInstance() error = %v", err)
+		}
+		got, err := db.GetWithInstance(ctx, "roundtrip", "inst_conf")
+		if err != nil {
+			t.Fatalf("GetWithInstance() error = %v", err)
+		}
+		assertJSONEqual(t, got, want)
+
+		if err := db.SetWithInstance(ctx, "roundtrip", "inst_conf", []byte(`[1,2,3]`)); err != nil {
+			t.Fatalf("SetWithInstance() overwrite error = %v", err)
+		}
+		got, _ = db.GetWithInstance(ctx, "roundtrip", "inst_conf")
+		assertJSONEqual(t, got, `[1,2,3]`)
+	})
+
+	t.Run("NonJSONValue", func(t *testing.T) {
+		db := open(t)
+		ctx := context.Background()
+
+		if err := db.SetWithInstance(ctx, "raw", "inst_conf", []byte("9821f3fe")); err != nil {
+			t.Fatalf("SetWithInstance() non-JSON error = %v", err)
+		}
+		got, err := db.GetWithInstance(ctx, "raw", "inst_conf")
+		if err != nil {
+			t.Fatalf("GetWithInstance() error = %v", err)
+		}
+		if string(got) != "9821f3fe" {
+			t.Errorf("non-JSON value = %q, want it stored byte for byte", got)
+		}
+	})
+
+	t.Run("TypedValues", func(t *testing.T) {
+		db := open(t)
+		ctx := context.Background()
+
+		values := map[string][]byte{
+			"png":     cache.EncodeValue("image/png", []byte{0x89, 'P', 'N', 'G', 0x00, 0xff}),
+			"text":    cache.EncodeValue("text/plain; charset=utf-8", []byte(`"quoted"`)),
+			"problem": cache.EncodeValue("application/problem+json", []byte(`{"title":"oops"}`)),
+		}
+		compressed, err := cache.Compress(cache.EncodingGzip, []byte(`{"title":"packed"}`))
+		if err != nil {
+			t.Fatalf("Compress() error = %v", err)
+		}
+		values["compressed"] = cache.EncodeCompressed(cache.ContentTypeJSON, cache.EncodingGzip, compressed)
+
+		for key, value := range values {
+			if err := db.SetWithInstance(ctx, key, "inst_conf", value); err != nil {
+				t.Fatalf("SetWithInstance(%s) error = %v", key, err)
+			}
+		}
+		for key, value := range values {
+			got, err := db.GetWithInstance(ctx, key, "inst_conf")
+			if err != nil {
+				t.Fatalf("GetWithInstance(%s) error = %v", key, err)
+			}
+			// Compressed values are stored as they were compressed
+			if _, encoding, _ := cache.ParseValue(value); encoding != "" && !bytes.Equal(got, value) {
+				t.Errorf("%s compressed value = %q, want %q", key, got, value)
+			}
+			wantType, wantBody, _ := cache.DecodeValue(value)
+			gotType, gotBody, err := cache.DecodeValue(got)
+			if err != nil {
+				t.Fatalf("DecodeValue(%s) error = %v", key, err)
+			}
+			if gotType != wantType {
+				t.Errorf("%s content type = %q, want %q", key, gotType, wantType)
+			}
+			if cache.IsJSONContentType(wantType) {
+				assertJSONEqual(t, gotBody, string(wantBody))
+			} else if !bytes.Equal(gotBody, wantBody) {
+				t.Errorf("%s value = %q, want %q", key, gotBody, wantBody)
+			}
+		}
+	})
+
+	t.Run("MissingKey", func(t *testing.T) {
+		db := open(t)
+		ctx := context.Background()
+
+		if _, err := db.GetWithInstance(ctx, "missing", "inst_conf"); !errors.Is(err, database.ErrNotFound) {
+			t.Errorf("GetWithInstance() missing error = %v, want ErrNotFound", err)
+		}
+		if err := db.DeleteWithInstance(ctx, "missing", "inst_conf"); !errors.Is(err, database.ErrNotFound) {
+			t.Errorf("DeleteWithInstance() missing error = %v, want ErrNotFound", err)
+		}
+		exists, err := db.ExistsWithInstance(ctx, "missing", "inst_conf")
+		if err != nil || exists {
+			t.Errorf("ExistsWithInstance() missing = %v, %v; want false, nil", exists, err)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		db := open(t)
+		ctx := context.Background()
+
+		if err := db.SetWithInstance(ctx, "doomed", "inst_conf", []byte(`1`)); err != nil {
+			t.Fatalf("SetWithInstance() error = %v", err)
+		}
+		if err := db.DeleteWithInstance(ctx, "doomed", "inst_conf"); err != nil {
+			t.Fatalf("DeleteWithInstance() error = %v", err)
+		}
+		if _, err := db.GetWithInstance(ctx, "doomed", "inst_conf"); !errors.Is(err, database.ErrNotFound) {
+			t.Errorf("GetWithInstance() after delete error = %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("TTLExpiry", func(t *testing.T) {
+		db := open(t)
+		setter, ok := db.(entrySetter)
+		if !ok {
+			t.Skip("backend cannot store entries with a TTL")
+		}
+		ctx := context.Background()
+
+		ttl := 1
+		if err := setter.SetEntry(ctx, "short", "inst_conf", json.RawMessage(`1`), &ttl, nil); err != nil {
+			t.Fatalf("SetEntry() error = %v", err)
+		}
+		if err := db.SetWithInstance(ctx, "forever", "inst_conf", []byte(`2`)); err != nil {
+			t.Fatalf("SetWithInstance() error = %v", err)
+		}
+		if exists, _ := db.ExistsWithInstance(ctx, "short", "inst_conf"); !exists {
+			t.Fatal("entry expired before its TTL")
+		}
+
+		time.Sleep(time.Duration(ttl)*time.Second + 500*time.Millisecond)
+
+		if exists, _ := db.ExistsWithInstance(ctx, "short", "inst_conf"); exists {
+			t.Error("ExistsWithInstance() = true for expired entry")
+		}
+		if _, err := db.GetWithInstance(ctx, "short", "inst_conf"); !errors.Is(err, database.ErrNotFound) {
+			t.Errorf("GetWithInstance() expired error = %v, want ErrNotFound", err)
+		}
+		if _, err := db.GetWithInstance(ctx, "forever", "inst_conf"); err != nil {
+			t.Errorf("GetWithInstance() without TTL error = %v", err)
+		}
+	})
+
+	t.Run("InstanceIsolation", func(t *testing.T) {
+		db := open(t)
+		ctx := context.Background()
+
+		if err := db.SetWithInstance(ctx, "shared", "inst_conf_a", []byte(`"a"`)); err != nil {
+			t.Fatalf("SetWithInstance() a error = %v", err)
+		}
+		if err := db.SetWithInstance(ctx, "shared", "inst_conf_b", []byte(`"b"`)); err != nil {
+			t.Fatalf("SetWithInstance() b error = %v", err)
+		}
+		if err := db.SetWithInstance(ctx, "only-a", "inst_conf_a", []byte(`"a"`)); err != nil {
+			t.Fatalf("SetWithInstance() a error = %v", err)
+		}
+
+		got, _ := db.GetWithInstance(ctx, "shared", "inst_conf_a")
+		assertJSONEqual(t, got, `"a"`)
+		got, _ = db.GetWithInstance(ctx, "shared", "inst_conf_b")
+		assertJSONEqual(t, got, `"b"`)
+
+		if _, err := db.GetWithInstance(ctx, "only-a", "inst_conf_b"); !errors.Is(err, database.ErrNotFound) {
+			t.Errorf("instance b read instance a key: error = %v", err)
+		}
+
+		if err := db.DeleteWithInstance(ctx, "shared", "inst_conf_a"); err != nil {
+			t.Fatalf("DeleteWithInstance() error = %v", err)
+		}
+		if exists, _ := db.ExistsWithInstance(ctx, "shared", "inst_conf_b"); !exists {
+			t.Error("deleting from instance a removed instance b's entry")
+		}
+
+		// Context-aware methods resolve the instance from the request context
+		instCtx := instance.InjectContext(ctx, instance.NewContext("inst_conf_b"))
+		got, err := db.GetFromContext(instCtx, "shared")
+		if err != nil {
+			t.Fatalf("GetFromContext() error = %v", err)
+		}
+		assertJSONEqual(t, got, `"b"`)
+
+		if err := db.SetFromContext(instCtx, "ctx-key", []byte(`true`)); err != nil {
+			t.Fatalf("SetFromContext() error = %v", err)
+		}
+		if exists, _ := db.ExistsWithInstance(ctx, "ctx-key", "inst_conf_b"); !exists {
+			t.Error("SetFromContext() did not write to the context instance")
+		}
+		if exists, _ := db.ExistsWithInstance(ctx, "ctx-key", "inst_conf_a"); exists {
+			t.Error("SetFromContext() leaked into another instance")
+		}
+	})
+
+	t.Run("BatchOperations", func(t *testing.T) {
+		db := open(t)
+		ctx := context.Background()
+
+		// The Interface has no batch methods; batches are expressed as
+		// sequences of single operations and must not interfere
+		for i := 0; i < 50; i++ {
+			key := fmt.Sprintf("batch:%02d", i)
+			if err := db.SetWithInstance(ctx, key, "inst_conf", []byte(fmt.Sprintf(`{"n":%d}`, i))); err != nil {
+				t.Fatalf("SetWithInstance(%s) error = %v", key, err)
+			}
+		}
+		for i := 0; i < 50; i += 2 {
+			key := fmt.Sprintf("batch:%02d", i)
+			if err := db.DeleteWithInstance(ctx, key, "inst_conf"); err != nil {
+				t.Fatalf("DeleteWithInstance(%s) error = %v", key, err)
+			}
+		}
+		for i := 0; i < 50; i++ {
+			key := fmt.Sprintf("batch:%02d", i)
+			exists, err := db.ExistsWithInstance(ctx, key, "inst_conf")
+			if err != nil {
+				t.Fatalf("ExistsWithInstance(%s) error = %v", key, err)
+			}
+			if want := i%2 == 1; exists != want {
+				t.Errorf("ExistsWithInstance(%s) = %v, want %v", key, exists, want)
+			}
+		}
+	})
+
+	t.Run("BatchGetIsolation", func(t *testing.T) {
+		db := open(t)
+		getter, ok := db.(batchGetter)
+		if !ok {
+			t.Skip("backend has no batch reads")
+		}
+		ctx := context.Background()
+
+		for _, write := range []struct{ key, instanceID, value string }{
+			{"shared", "inst_conf_a", `"a"`},
+			{"shared", "inst_conf_b", `"b"`},
+			{"only-a", "inst_conf_a", `1`},
+			{"only-b", "inst_conf_b", `2`},
+		} {
+			if err := db.SetWithInstance(ctx, write.key, write.instanceID, []byte(write.value)); err != nil {
+				t.Fatalf("SetWithInstance(%s, %s) error = %v", write.key, write.instanceID, err)
+			}
+		}
+
+		entries, err := getter.BatchGetWithInstance(ctx, []string{"shared", "only-a", "only-b", "missing"}, "inst_conf_a")
+		if err != nil {
+			t.Fatalf("BatchGetWithInstance() error = %v", err)
+		}
+		got := make(map[string][]byte)
+		for _, entry := range entries {
+			if entry.InstanceID != "inst_conf_a" {
+				t.Errorf("BatchGetWithInstance() entry %s has instance %q", entry.Key, entry.InstanceID)
+			}
+			got[entry.Key] = entry.Data()
+		}
+		if len(got) != 2 {
+			t.Fatalf("BatchGetWithInstance() returned keys %v, want shared and only-a", got)
+		}
+		assertJSONEqual(t, got["shared"], `"a"`)
+		assertJSONEqual(t, got["only-a"], `1`)
+
+		entries, err = getter.BatchGetWithInstance(ctx, nil, "inst_conf_a")
+		if err != nil || len(entries) != 0 {
+			t.Errorf("BatchGetWithInstance() of no keys = %v, %v; want empty", entries, err)
+		}
+	})
+
+	t.Run("LargeValues", func(t *testing.T) {
+		db := open(t)
+		ctx := context.Background()
+
+		payload := strings.Repeat("birb", opts.LargeValueSize/4)
+		large, err := json.Marshal(map[string]string{"payload": payload})
+		if err != nil {
+			t.Fatalf("failed to build large value: %v", err)
+		}
+
+		if err := db.SetWithInstance(ctx, "large", "inst_conf", large); err != nil {
+			t.Fatalf("SetWithInstance() large value error = %v", err)
+		}
+		got, err := db.GetWithInstance(ctx, "large", "inst_conf")
+		if err != nil {
+			t.Fatalf("GetWithInstance() large value error = %v", err)
+		}
+
+		var decoded map[string]string
+		if err := json.Unmarshal(got, &decoded); err != nil {
+			t.Fatalf("large value is not valid JSON: %v", err)
+		}
+		if decoded["payload"] != payload {
+			t.Errorf("large value corrupted: got %d bytes, want %d", len(decoded["payload"]), len(payload))
+		}
+	})
+
+	t.Run("Concurrency", func(t *testing.T) {
+		db := open(t)
+		ctx := context.Background()
+
+		var wg sync.WaitGroup
+		errs := make(chan error, opts.Concurrency)
+		for g := 0; g < opts.Concurrency; g++ {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				instanceID := fmt.Sprintf("inst_conf_%d", g%4)
+				for i := 0; i < 10; i++ {
+					key := fmt.Sprintf("conc:%d:%d", g, i)
+					if err := db.SetWithInstance(ctx, key, instanceID, []byte(fmt.Sprintf(`%d`, i))); err != nil {
+						errs <- fmt.Errorf("SetWithInstance(%s): %w", key, err)
+						return
+					}
+					if _, err := db.GetWithInstance(ctx, key, instanceID); err != nil {
+						errs <- fmt.Errorf("GetWithInstance(%s): %w", key, err)
+						return
+					}
+					// Contended upsert of one row
+					if err := db.SetWithInstance(ctx, "conc:shared", "inst_conf", []byte(fmt.Sprintf(`%d`, g))); err != nil {
+						errs <- fmt.Errorf("SetWithInstance(shared): %w", err)
+						return
+					}
+				}
+			}(g)
+		}
+		wg.Wait()
+		close(errs)
+
+		for err := range errs {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Features", func(t *testing.T) {
+		db := open(t)
+		features, ok := db.(database.Features)
+		if !ok {
+			t.Skip("backend provides no features beyond cache entries")
+		}
+		ctx := context.Background()
+
+		// Every feature either works or reports ErrUnsupported
+		check := func(name string, store interface{}, err error) {
+			t.Helper()
+			switch {
+			case errors.Is(err, database.ErrUnsupported):
+				t.Logf("%s: %v", name, err)
+			case err != nil:
+				t.Errorf("%s error = %v, want nil or ErrUnsupported", name, err)
+			case store == nil:
+				t.Errorf("%s returned no store and no error", name)
+			}
+		}
+		instances, err := features.InstanceStore()
+		check("InstanceStore()", instances, err)
+		keys, err := features.APIKeyStore()
+		check("APIKeyStore()", keys, err)
+		encryption, err := features.EncryptionStore()
+		check("EncryptionStore()", encryption, err)
+		opsDB, err := features.OperationsDB()
+		check("OperationsDB()", opsDB, err)
+
+		for i := 0; i < 3; i++ {
+			if err := db.SetWithInstance(ctx, fmt.Sprintf("stats:%d", i), "inst_conf_stats", []byte(`"birb"`)); err != nil {
+				t.Fatalf("SetWithInstance() error = %v", err)
+			}
+		}
+		if err := db.SetWithInstance(ctx, "stats:other", "inst_conf_other", []byte(`"birb"`)); err != nil {
+			t.Fatalf("SetWithInstance() error = %v", err)
+		}
+		stats, err := features.StorageStats(ctx, "inst_conf_stats")
+		check("StorageStats()", stats, err)
+		if err == nil && (stats.RowCount != 3 || stats.DataSizeBytes <= 0) {
+			t.Errorf("StorageStats() = %d rows, %d bytes; want 3 rows of data", stats.RowCount, stats.DataSizeBytes)
+		}
+	})
+
+	t.Run("Health", func(t *testing.T) {
+		db := open(t)
+		if err := db.Health(context.Background()); err != nil {
+			t.Errorf("Health() error = %v", err)
+		}
+	})
+}
+
+func assertJSONEqual(t *testing.T, got []byte, want string) {
+	t.Helper()
+
+	var gotValue, wantValue interface{}
+	if err := json.Unmarshal(got, &gotValue); err != nil {
+		t.Errorf("value %q is not valid JSON: %v", got, err)
+		return
+	}
+	if err := json.Unmarshal([]byte(want), &wantValue); err != nil {
+		t.Fatalf("invalid expected JSON %q: %v", want, err)
+	}
+	if fmt.Sprint(gotValue) != fmt.Sprint(wantValue) {
+		t.Errorf("value = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,76 @@
+//go:build integration
+// +build integration
+
+package integration
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/birbparty/birb-nest/internal/cache"
+	"github.com/birbparty/birb-nest/internal/database"
+	"github.com/birbparty/birb-nest/tests/conformance"
+	"github.com/birbparty/birb-nest/tests/testutil"
+)
+
+// Storage conformance against real Redis and PostgreSQL containers
+// Run with: go test -tags=integration ./tests/integration/...
+
+var containers *testutil.TestContainers
+
+func TestMain(m *testing.M) {
+	ctx := context.Background()
+
+	tc, err := testutil.StartContainers(ctx)
+	if err != nil {
+		panic(err)
+	}
+	containers = tc
+
+	if err := tc.WaitForHealthy(ctx); err != nil {
+		tc.Cleanup(ctx)
+		panic(err)
+	}
+	if err := tc.ApplySchema(ctx); err != nil {
+		tc.Cleanup(ctx)
+		panic(err)
+	}
+
+	code := m.Run()
+	tc.Cleanup(ctx)
+	os.Exit(code)
+}
+
+func TestRedisCache_Conformance(t *testing.T) {
+	conformance.RunCacheSuite(t, func(t *testing.T) cache.Cache {
+		c, err := cache.NewRedisCache(containers.CacheConfig())
+		if err != nil {
+			t.Fatalf("NewRedisCache() error = %v", err)
+		}
+		if err := c.FlushDB(context.Background()); err != nil {
+			t.Fatalf("FlushDB() error = %v", err)
+		}
+		return c
+	}, conformance.CacheOptions{})
+}
+
+func TestPostgreSQLClient_Conformance(t *testing.T) {
+	conformance.RunDatabaseSuite(t, func(t *testing.T) database.Interface {
+		db, err := database.NewPostgreSQLClient(containers.DatabaseConfig(), "global")
+		if err != nil {
+			t.Fatalf("NewPostgreSQLClient() error = %v", err)
+		}
+
+		// Start every subtest from an empty table
+		raw, err := database.NewDB(containers.DatabaseConfig())
+		if err != nil {
+			t.Fatalf("NewDB() error = %v", err)
+		}
+		defer raw.Close()
+		if _, err := raw.Exec(context.Background(), "TRUNCATE cache_entries"); err != nil {
+			t.Fatalf("failed to truncate cache_entries: %v", err)
+		}
+		return db
+	}, conformance.DatabaseOptions{})
+}
@@ -0,0 +1,528 @@
+//go:build integration
+// +build integration
+
+package integration
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/birbparty/birb-nest/internal/api"
+	"github.com/birbparty/birb-nest/internal/archive"
+	"github.com/birbparty/birb-nest/internal/cache"
+	"github.com/birbparty/birb-nest/internal/database"
+	"github.com/birbparty/birb-nest/internal/encryption"
+	"github.com/birbparty/birb-nest/internal/instance"
+	"github.com/birbparty/birb-nest/internal/operations"
+	"github.com/gofiber/fiber/v2"
+)
+
+// opsEnv is instance operations against the PostgreSQL container, with the
+// registry and cache in memory
+type opsEnv struct {
+	ops      *operations.InstanceOperations
+	db       *database.DB
+	repo     *database.CacheRepository
+	registry *instance.Registry
+	cache    cache.Cache
+}
+
+func newOpsEnv(t *testing.T) *opsEnv {
+	t.Helper()
+	db, err := database.NewDB(containers.DatabaseConfig())
+	if err != nil {
+		t.Fatalf("NewDB() error = %v", err)
+	}
+	t.Cleanup(db.Close)
+
+	memCache, err := cache.NewMemoryCache(nil)
+	if err != nil {
+		t.Fatalf("NewMemoryCache() error = %v", err)
+	}
+	t.Cleanup(func() { memCache.Close() })
+
+	registry := instance.NewRegistry(memCache)
+	return &opsEnv{
+		ops:      operations.NewInstanceOperations(memCache, db, registry),
+		db:       db,
+		repo:     database.NewCacheRepository(db),
+		registry: registry,
+		cache:    memCache,
+	}
+}
+
+// register creates an instance in the registry
+func (e *opsEnv) register(t *testing.T, instanceID string) *instance.Context {
+	t.Helper()
+	inst := instance.NewContext(instanceID)
+	if err := e.registry.Register(context.Background(), inst); err != nil {
+		t.Fatalf("Register(%s) error = %v", instanceID, err)
+	}
+	return inst
+}
+
+// set writes a value straight to the database
+func (e *opsEnv) set(t *testing.T, instanceID, key, value string) {
+	t.Helper()
+	if err := e.repo.SetWithInstance(context.Background(), key, instanceID, []byte(value), nil, nil); err != nil {
+		t.Fatalf("SetWithInstance(%s, %s) error = %v", instanceID, key, err)
+	}
+}
+
+func TestBackupInstance_ByteOrderedKeys(t *testing.T) {
+	env := newOpsEnv(t)
+	ctx := context.Background()
+	env.register(t, "backup-order")
+
+	// The container's default collation sorts these case-insensitively and
+	// ignores punctuation; archives must be in byte order
+	keys := []string{"zone", "a_b", "Zone", "ab", "a.b", "aB", "Player:1", "a-b", "a:b"}
+	for _, key := range keys {
+		env.set(t, "backup-order", key, `1`)
+	}
+
+	var buf bytes.Buffer
+	if err := env.ops.BackupInstance(ctx, "backup-order", &buf); err != nil {
+		t.Fatalf("BackupInstance() error = %v", err)
+	}
+
+	r, err := archive.NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+	var got []string
+	for {
+		entry, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		got = append(got, entry.Key)
+	}
+	r.Close()
+
+	want := []string{"Player:1", "Zone", "a-b", "a.b", "a:b", "aB", "a_b", "ab", "zone"}
+	if len(got) != len(want) {
+		t.Fatalf("backup keys = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("backup keys = %v, want %v", got, want)
+		}
+	}
+
+	// A backup merges on its own, which checks the order again
+	src, err := archive.NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+	defer src.Close()
+	if _, err := archive.Merge(io.Discard, &archive.Manifest{InstanceID: "backup-order"}, src); err != nil {
+		t.Errorf("Merge() error = %v", err)
+	}
+}
+
+// get reads a value through the instance's layers; missing keys read as ""
+func (e *opsEnv) get(t *testing.T, instanceID, key string) string {
+	t.Helper()
+	entry, err := e.repo.GetWithInstance(context.Background(), key, instanceID)
+	if errors.Is(err, database.ErrNotFound) {
+		return ""
+	}
+	if err != nil {
+		t.Fatalf("GetWithInstance(%s, %s) error = %v", instanceID, key, err)
+	}
+	return string(entry.Data())
+}
+
+func TestCloneInstance_CopiesContextAndData(t *testing.T) {
+	env := newOpsEnv(t)
+	ctx := context.Background()
+
+	source := instance.NewContext("clone-src")
+	source.GameType = "dungeon"
+	source.Region = "eu"
+	source.ResourceQuota = &instance.ResourceQuota{MaxMemoryMB: 64, MaxKeys: 10}
+	source.Metadata["owner"] = "guild-7"
+	source.Metadata[operations.MetaLastArchived] = time.Now().UTC().Format(time.RFC3339)
+	if err := env.registry.Register(ctx, source); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	env.set(t, "clone-src", "player:1", `"alice"`)
+	env.set(t, "clone-src", "world:seed", `42`)
+
+	result, err := env.ops.CloneInstance(ctx, "clone-src", operations.CloneOptions{TargetID: "clone-dst"})
+	if err != nil {
+		t.Fatalf("CloneInstance() error = %v", err)
+	}
+	if result.KeysCopied != 2 {
+		t.Errorf("KeysCopied = %d, want 2", result.KeysCopied)
+	}
+
+	clone, err := env.registry.Get(ctx, "clone-dst")
+	if err != nil {
+		t.Fatalf("Get(clone-dst) error = %v", err)
+	}
+	if clone.Status != instance.StatusActive {
+		t.Errorf("clone status = %s, want %s", clone.Status, instance.StatusActive)
+	}
+	if clone.GameType != "dungeon" || clone.Region != "eu" {
+		t.Errorf("clone game type/region = %s/%s, want dungeon/eu", clone.GameType, clone.Region)
+	}
+	if clone.ResourceQuota == nil || *clone.ResourceQuota != *source.ResourceQuota {
+		t.Errorf("clone quota = %+v, want %+v", clone.ResourceQuota, source.ResourceQuota)
+	}
+	if clone.Metadata["owner"] != "guild-7" {
+		t.Errorf("clone owner metadata = %q, want guild-7", clone.Metadata["owner"])
+	}
+	if clone.Metadata[operations.MetaClonedFrom] != "clone-src" {
+		t.Errorf("clone %s = %q, want clone-src", operations.MetaClonedFrom, clone.Metadata[operations.MetaClonedFrom])
+	}
+	if sources := clone.DataFrom(); len(sources) != 1 || sources[0] != "clone-src" {
+		t.Errorf("clone DataFrom() = %v, want [clone-src]", sources)
+	}
+	if _, ok := clone.Metadata[operations.MetaLastArchived]; ok {
+		t.Errorf("clone kept source-only metadata %s", operations.MetaLastArchived)
+	}
+
+	if got := env.get(t, "clone-dst", "player:1"); got != `"alice"` {
+		t.Errorf("clone player:1 = %s, want \"alice\"", got)
+	}
+	if got := env.get(t, "clone-dst", "world:seed"); got != `42` {
+		t.Errorf("clone world:seed = %s, want 42", got)
+	}
+
+	// The copy is independent of the source
+	env.set(t, "clone-dst", "player:1", `"bob"`)
+	if got := env.get(t, "clone-src", "player:1"); got != `"alice"` {
+		t.Errorf("source player:1 = %s after writing the clone, want \"alice\"", got)
+	}
+}
+
+func TestCloneInstance_KeyPrefix(t *testing.T) {
+	env := newOpsEnv(t)
+	ctx := context.Background()
+	env.register(t, "prefix-src")
+	for _, key := range []string{"player:1", "player:2", "players", "world:seed", "p%layer"} {
+		env.set(t, "prefix-src", key, `1`)
+	}
+
+	result, err := env.ops.CloneInstance(ctx, "prefix-src", operations.CloneOptions{
+		TargetID:  "prefix-dst",
+		KeyPrefix: "player:",
+	})
+	if err != nil {
+		t.Fatalf("CloneInstance() error = %v", err)
+	}
+	if result.KeysCopied != 2 {
+		t.Errorf("KeysCopied = %d, want 2", result.KeysCopied)
+	}
+
+	keys, err := env.repo.GetKeysByInstance(ctx, "prefix-dst", 0, 100)
+	if err != nil {
+		t.Fatalf("GetKeysByInstance() error = %v", err)
+	}
+	sort.Strings(keys)
+	if len(keys) != 2 || keys[0] != "player:1" || keys[1] != "player:2" {
+		t.Errorf("cloned keys = %v, want [player:1 player:2]", keys)
+	}
+	if result.Instance.Metadata[operations.MetaClonedKeyPrefix] != "player:" {
+		t.Errorf("clone %s = %q, want player:", operations.MetaClonedKeyPrefix,
+			result.Instance.Metadata[operations.MetaClonedKeyPrefix])
+	}
+}
+
+func TestCloneInstance_TargetExists(t *testing.T) {
+	env := newOpsEnv(t)
+	env.register(t, "exists-src")
+	env.register(t, "exists-dst")
+	env.set(t, "exists-src", "k", `1`)
+	// Leftover rows count as an existing instance even without a registry entry
+	env.set(t, "exists-orphan", "k", `1`)
+
+	h := api.NewHandlers(&api.Config{Mode: "primary"}, env.cache, nil, env.registry)
+	h.SetInstanceOperations(env.ops)
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+	app.Post("/v1/instances/:id/clone", h.CloneInstance)
+
+	for _, target := range []string{"exists-dst", "exists-orphan", "exists-src"} {
+		t.Run(target, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1/instances/exists-src/clone",
+				strings.NewReader(`{"target_id":"`+target+`"}`))
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("app.Test() error = %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != fiber.StatusConflict {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, fiber.StatusConflict)
+			}
+			var body api.ErrorResponse
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if body.Code != api.ErrCodeConflict {
+				t.Errorf("code = %q, want %q", body.Code, api.ErrCodeConflict)
+			}
+		})
+	}
+
+	// The existing target was left alone
+	if got := env.get(t, "exists-dst", "k"); got != "" {
+		t.Errorf("existing target gained key k = %s", got)
+	}
+}
+
+func TestCloneInstance_Encrypted(t *testing.T) {
+	env := newOpsEnv(t)
+	ctx := context.Background()
+	keys := database.NewEncryptionRepository(env.db)
+	master := make([]byte, encryption.KeySize)
+	rand.Read(master)
+	wrapper, err := encryption.NewLocalWrapper("master-1", master)
+	if err != nil {
+		t.Fatalf("NewLocalWrapper() error = %v", err)
+	}
+	manager := encryption.NewManager(keys, wrapper)
+
+	source := instance.NewContext("enc-src")
+	source.Metadata[encryption.MetaEncryption] = "on"
+	if err := env.registry.Register(ctx, source); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	sealed, err := manager.Seal(instance.InjectContext(ctx, source), "save", []byte(`"gold"`))
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+	env.set(t, "enc-src", "save", string(sealed))
+	env.set(t, "enc-src", "plain", `"copper"`)
+
+	if _, err := env.ops.CloneInstance(ctx, "enc-src", operations.CloneOptions{TargetID: "enc-dst"}); err != nil {
+		t.Fatalf("CloneInstance() error = %v", err)
+	}
+	clone, err := env.registry.Get(ctx, "enc-dst")
+	if err != nil {
+		t.Fatalf("Get(enc-dst) error = %v", err)
+	}
+
+	// The copy stays sealed with the source's key, which only the clone may use
+	copied := []byte(env.get(t, "enc-dst", "save"))
+	if opened, err := manager.Open(instance.InjectContext(ctx, clone), "save", copied); err != nil || string(opened) != `"gold"` {
+		t.Errorf("Open() in the clone = %s, %v, want \"gold\"", opened, err)
+	}
+	other := instance.NewContext("enc-other")
+	if _, err := manager.Open(instance.InjectContext(ctx, other), "save", copied); !errors.Is(err, encryption.ErrInvalidEnvelope) {
+		t.Errorf("Open() in an unrelated instance error = %v, want ErrInvalidEnvelope", err)
+	}
+
+	// Values not sealed with the clone's own key are stale until re-encrypted
+	active, err := manager.ActiveKey(ctx, "enc-dst")
+	if err != nil {
+		t.Fatalf("ActiveKey() error = %v", err)
+	}
+	stale, err := keys.StaleEntries(ctx, "enc-dst", active.ID, "", 10)
+	if err != nil {
+		t.Fatalf("StaleEntries() error = %v", err)
+	}
+	if len(stale) != 2 {
+		t.Fatalf("StaleEntries() = %d entries, want 2", len(stale))
+	}
+	r := encryption.NewReencryptor(manager, env.cache, keys, env.registry, time.Minute)
+	if rewritten, err := r.ReencryptInstance(ctx, "enc-dst"); err != nil || rewritten != 2 {
+		t.Fatalf("ReencryptInstance() = %d, %v, want 2 values rewritten", rewritten, err)
+	}
+	if stale, _ := keys.StaleEntries(ctx, "enc-dst", active.ID, "", 10); len(stale) != 0 {
+		t.Errorf("StaleEntries() after re-encryption = %d entries, want none", len(stale))
+	}
+	if opened, err := manager.Open(instance.InjectContext(ctx, clone), "plain", []byte(env.get(t, "enc-dst", "plain"))); err != nil || string(opened) != `"copper"` {
+		t.Errorf("re-encrypted plain = %s, %v, want \"copper\"", opened, err)
+	}
+}
+
+func TestTemplates_CopyOnWrite(t *testing.T) {
+	env := newOpsEnv(t)
+	ctx := context.Background()
+
+	source := instance.NewContext("tmpl-src")
+	source.GameType = "dungeon"
+	if err := env.registry.Register(ctx, source); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	env.set(t, "tmpl-src", "boss:hp", `100`)
+	env.set(t, "tmpl-src", "boss:name", `"dragon"`)
+	env.set(t, "tmpl-src", "loot", `"gold"`)
+
+	tmpl, err := env.ops.CreateTemplate(ctx, "cow-dungeon", "tmpl-src", operations.TemplateOptions{
+		Metadata: map[string]string{"difficulty": "hard"},
+	})
+	if err != nil {
+		t.Fatalf("CreateTemplate() error = %v", err)
+	}
+	if tmpl.KeyCount != 3 {
+		t.Errorf("KeyCount = %d, want 3", tmpl.KeyCount)
+	}
+
+	inst, err := env.ops.CreateFromTemplate(ctx, "cow-dungeon", operations.FromTemplateOptions{
+		InstanceID: "tmpl-run",
+		Metadata:   map[string]string{"party": "p1", instance.MetaDataFrom: "someone-else"},
+	})
+	if err != nil {
+		t.Fatalf("CreateFromTemplate() error = %v", err)
+	}
+	if inst.GameType != "dungeon" {
+		t.Errorf("GameType = %q, want dungeon", inst.GameType)
+	}
+	if inst.Metadata[operations.MetaTemplate] != "cow-dungeon" || inst.Metadata["difficulty"] != "hard" || inst.Metadata["party"] != "p1" {
+		t.Errorf("Metadata = %v, want template, difficulty and party", inst.Metadata)
+	}
+	if sources := inst.DataFrom(); len(sources) != 1 || sources[0] != "tmpl-src" {
+		t.Errorf("DataFrom() = %v, want only the template source", sources)
+	}
+
+	// No rows are copied; reads fall through to the template
+	own, err := env.repo.GetKeysByInstance(ctx, "tmpl-run", 0, 100)
+	if err != nil {
+		t.Fatalf("GetKeysByInstance() error = %v", err)
+	}
+	if len(own) != 0 {
+		t.Errorf("instance owns keys %v, want none", own)
+	}
+	if got := env.get(t, "tmpl-run", "boss:hp"); got != `100` {
+		t.Errorf("boss:hp = %s, want 100 from the template", got)
+	}
+
+	// Writes shadow the template without changing it
+	env.set(t, "tmpl-run", "boss:hp", `40`)
+	if got := env.get(t, "tmpl-run", "boss:hp"); got != `40` {
+		t.Errorf("boss:hp = %s after write, want 40", got)
+	}
+	if got := env.get(t, operations.TemplateDataID("cow-dungeon"), "boss:hp"); got != `100` {
+		t.Errorf("template boss:hp = %s, want 100", got)
+	}
+
+	// Deleting an inherited key leaves a whiteout that hides it
+	if err := env.repo.DeleteWithInstance(ctx, "loot", "tmpl-run"); err != nil {
+		t.Fatalf("DeleteWithInstance(loot) error = %v", err)
+	}
+	if got := env.get(t, "tmpl-run", "loot"); got != "" {
+		t.Errorf("loot = %s after delete, want not found", got)
+	}
+	if exists, err := env.repo.ExistsWithInstance(ctx, "loot", "tmpl-run"); err != nil || exists {
+		t.Errorf("ExistsWithInstance(loot) = %v, %v; want false", exists, err)
+	}
+	if err := env.repo.DeleteWithInstance(ctx, "loot", "tmpl-run"); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("second DeleteWithInstance(loot) error = %v, want ErrNotFound", err)
+	}
+
+	// Deleting a shadowed key removes the own row and hides the template row
+	if err := env.repo.DeleteWithInstance(ctx, "boss:hp", "tmpl-run"); err != nil {
+		t.Fatalf("DeleteWithInstance(boss:hp) error = %v", err)
+	}
+	if got := env.get(t, "tmpl-run", "boss:hp"); got != "" {
+		t.Errorf("boss:hp = %s after delete, want not found", got)
+	}
+
+	// Batch reads see the same layers
+	entries, err := env.repo.BatchGetWithInstance(ctx, []string{"boss:hp", "boss:name", "loot"}, "tmpl-run")
+	if err != nil {
+		t.Fatalf("BatchGetWithInstance() error = %v", err)
+	}
+	if len(entries) != 1 || entries[0].Key != "boss:name" {
+		keys := make([]string, len(entries))
+		for i, entry := range entries {
+			keys[i] = entry.Key
+		}
+		t.Errorf("BatchGetWithInstance() keys = %v, want [boss:name]", keys)
+	}
+
+	// A second instance from the same template is unaffected
+	if _, err := env.ops.CreateFromTemplate(ctx, "cow-dungeon", operations.FromTemplateOptions{InstanceID: "tmpl-run2"}); err != nil {
+		t.Fatalf("CreateFromTemplate(tmpl-run2) error = %v", err)
+	}
+	if got := env.get(t, "tmpl-run2", "loot"); got != `"gold"` {
+		t.Errorf("tmpl-run2 loot = %s, want \"gold\"", got)
+	}
+}
+
+func TestDeleteTemplate_InUse(t *testing.T) {
+	env := newOpsEnv(t)
+	ctx := context.Background()
+	env.register(t, "inuse-src")
+	env.set(t, "inuse-src", "k", `1`)
+
+	if _, err := env.ops.CreateTemplate(ctx, "inuse", "inuse-src", operations.TemplateOptions{}); err != nil {
+		t.Fatalf("CreateTemplate() error = %v", err)
+	}
+	if _, err := env.ops.CreateFromTemplate(ctx, "inuse", operations.FromTemplateOptions{InstanceID: "inuse-run"}); err != nil {
+		t.Fatalf("CreateFromTemplate() error = %v", err)
+	}
+
+	if err := env.ops.DeleteTemplate(ctx, "inuse"); !errors.Is(err, operations.ErrTemplateInUse) {
+		t.Fatalf("DeleteTemplate() error = %v, want ErrTemplateInUse", err)
+	}
+	if got := env.get(t, "inuse-run", "k"); got != `1` {
+		t.Errorf("inuse-run k = %s after rejected delete, want 1", got)
+	}
+
+	// Once the last instance is gone the template can be deleted with its data
+	if err := env.ops.DeleteInstance(ctx, "inuse-run"); err != nil {
+		t.Fatalf("DeleteInstance() error = %v", err)
+	}
+	if err := env.ops.DeleteTemplate(ctx, "inuse"); err != nil {
+		t.Fatalf("DeleteTemplate() error = %v", err)
+	}
+	if _, err := env.ops.GetTemplate(ctx, "inuse"); !errors.Is(err, operations.ErrTemplateNotFound) {
+		t.Errorf("GetTemplate() error = %v, want ErrTemplateNotFound", err)
+	}
+	keys, err := env.repo.GetKeysByInstance(ctx, operations.TemplateDataID("inuse"), 0, 100)
+	if err != nil {
+		t.Fatalf("GetKeysByInstance() error = %v", err)
+	}
+	if len(keys) != 0 {
+		t.Errorf("template data keys = %v after delete, want none", keys)
+	}
+}
+
+func TestCreateInstance_Concurrent(t *testing.T) {
+	env := newOpsEnv(t)
+	env.registry.SetStore(database.NewInstanceRepository(env.db))
+
+	var wg sync.WaitGroup
+	var created, exists atomic.Int32
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := env.ops.CreateInstance(context.Background(), instance.NewContext("create-race"))
+			switch {
+			case err == nil:
+				created.Add(1)
+			case errors.Is(err, instance.ErrInstanceExists):
+				exists.Add(1)
+			default:
+				t.Errorf("CreateInstance() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if created.Load() != 1 || exists.Load() != 7 {
+		t.Errorf("created = %d, exists = %d; want 1 and 7", created.Load(), exists.Load())
+	}
+}
@@ -3,8 +3,14 @@ package testutil
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
 	"time"
 
+	"github.com/birbparty/birb-nest/internal/cache"
+	"github.com/birbparty/birb-nest/internal/database"
+
 	"github.com/testcontainers/testcontainers-go"
 	"github.com/testcontainers/testcontainers-go/modules/postgres"
 	"github.com/testcontainers/testcontainers-go/modules/redis"
@@ -17,6 +23,11 @@ type TestContainers struct {
 	RedisContainer    testcontainers.Container
 	PostgresURL       string
 	RedisURL          string
+
+	postgresHost string
+	postgresPort int
+	redisHost    string
+	redisPort    int
 }
 
 // StartContainers starts all required containers for testing
@@ -50,6 +61,8 @@ func StartContainers(ctx context.Context) (*TestContainers, error) {
 		return nil, fmt.Errorf("failed to get postgres port: %w", err)
 	}
 
+	tc.postgresHost = pgHost
+	tc.postgresPort = pgPort.Int()
 	tc.PostgresURL = fmt.Sprintf("postgres://testuser:testpass@%s:%s/testdb?sslmode=disable", pgHost, pgPort.Port())
 
 	// Start Redis
@@ -72,6 +85,8 @@ func StartContainers(ctx context.Context) (*TestContainers, error) {
 		return nil, fmt.Errorf("failed to get redis port: %w", err)
 	}
 
+	tc.redisHost = redisHost
+	tc.redisPort = redisPort.Int()
 	tc.RedisURL = fmt.Sprintf("redis://%s:%s", redisHost, redisPort.Port())
 
 	return tc, nil
@@ -107,3 +122,58 @@ func (tc *TestContainers) WaitForHealthy(ctx context.Context) error {
 	time.Sleep(2 * time.Second) // Give services a moment to fully initialize
 	return nil
 }
+
+// CacheConfig returns a Redis cache configuration pointing at the test container
+func (tc *TestContainers) CacheConfig() *cache.Config {
+	return &cache.Config{
+		Mode:         cache.ModeStandalone,
+		Host:         tc.redisHost,
+		Port:         tc.redisPort,
+		DialTimeout:  5 * time.Second,
+		ReadTimeout:  3 * time.Second,
+		WriteTimeout: 3 * time.Second,
+		PoolSize:     20,
+		DefaultTTL:   time.Hour,
+	}
+}
+
+// DatabaseConfig returns a PostgreSQL configuration pointing at the test container
+func (tc *TestContainers) DatabaseConfig() *database.Config {
+	return &database.Config{
+		Host:            tc.postgresHost,
+		Port:            tc.postgresPort,
+		User:            "testuser",
+		Password:        "testpass",
+		Database:        "testdb",
+		MaxConns:        10,
+		MinConns:        1,
+		MaxConnLifetime: time.Hour,
+		MaxConnIdleTime: 5 * time.Minute,
+	}
+}
+
+// ApplySchema runs scripts/init-db.sql against the PostgreSQL container
+func (tc *TestContainers) ApplySchema(ctx context.Context) error {
+	_, file, _, ok := runtime.Caller(0)
+	if !ok {
+		return fmt.Errorf("failed to locate testutil source directory")
+	}
+	schemaPath := filepath.Join(filepath.Dir(file), "..", "..", "scripts", "init-db.sql")
+
+	schema, err := os.ReadFile(schemaPath)
+	if err != nil {
+		return fmt.Errorf("failed to read schema: %w", err)
+	}
+
+	db, err := database.NewDB(tc.DatabaseConfig())
+	if err != nil {
+		return fmt.Errorf("failed to connect to postgres: %w", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(ctx, string(schema)); err != nil {
+		return fmt.Errorf("failed to apply schema: %w", err)
+	}
+
+	return nil
+}